@@ -1,12 +1,16 @@
 package accesslog
 
 import (
+	"bufio"
 	"io"
 	"log/syslog"
 	"regexp"
+	"sync"
+	"time"
 
 	"strconv"
 
+	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/uber-go/zap"
 
 	"code.cloudfoundry.org/gorouter/accesslog/schema"
@@ -16,6 +20,9 @@ import (
 	"os"
 )
 
+// DroppedAccessLogs counts the records dropped because the queue was full.
+const DroppedAccessLogs = "dropped_access_logs"
+
 //go:generate counterfeiter -o fakes/accesslogger.go . AccessLogger
 type AccessLogger interface {
 	Run()
@@ -38,19 +45,38 @@ type FileAndLoggregatorAccessLogger struct {
 	dropsondeSourceInstance string
 	channel                 chan schema.AccessLogRecord
 	stopCh                  chan struct{}
+	doneCh                  chan struct{}
+	doneOnce                sync.Once
 	writer                  io.Writer
 	writerCount             int
+	writerLock              sync.Mutex
+	fileBuffer              *bufio.Writer
+	syslogSink              *SyslogSink
+	kafkaSink               *KafkaSink
+	flushInterval           time.Duration
 	disableXFFLogging       bool
 	disableSourceIPLogging  bool
+	jsonFormat              bool
+	template                *schema.Template
 	logger                  logger.Logger
 	ls                      logsender
 }
 
 func CreateRunningAccessLogger(logger logger.Logger, ls logsender, config *config.Config) (AccessLogger, error) {
-	if config.AccessLog.File == "" && !config.Logging.LoggregatorEnabled {
+	if config.AccessLog.File == "" && config.AccessLog.Syslog.Address == "" && len(config.AccessLog.Kafka.Brokers) == 0 && !config.Logging.LoggregatorEnabled {
 		return &NullAccessLogger{}, nil
 	}
 
+	var template *schema.Template
+	if config.AccessLog.Template != "" {
+		var err error
+		template, err = schema.ParseTemplate(config.AccessLog.Template)
+		if err != nil {
+			logger.Error("error-parsing-access-log-template", zap.Error(err))
+			return nil, err
+		}
+	}
+
 	var err error
 	var file *os.File
 	var writers []io.Writer
@@ -63,6 +89,14 @@ func CreateRunningAccessLogger(logger logger.Logger, ls logsender, config *confi
 		writers = append(writers, file)
 	}
 
+	// Only the file is buffered, since each write to syslog is sent as a
+	// separate message.
+	var fileBuffer *bufio.Writer
+	if file != nil && config.AccessLog.FlushInterval > 0 {
+		fileBuffer = bufio.NewWriter(file)
+		writers[0] = fileBuffer
+	}
+
 	if config.AccessLog.EnableStreaming {
 		syslogWriter, err := syslog.Dial(config.Logging.SyslogNetwork, config.Logging.SyslogAddr, syslog.LOG_INFO, config.Logging.Syslog)
 		if err != nil {
@@ -72,6 +106,26 @@ func CreateRunningAccessLogger(logger logger.Logger, ls logsender, config *confi
 		writers = append(writers, syslogWriter)
 	}
 
+	var syslogSink *SyslogSink
+	if config.AccessLog.Syslog.Address != "" {
+		syslogSink, err = NewSyslogSink(config.AccessLog.Syslog, logger)
+		if err != nil {
+			logger.Error("error-creating-syslog-sink", zap.Error(err))
+			return nil, err
+		}
+		writers = append(writers, syslogSink)
+	}
+
+	var kafkaSink *KafkaSink
+	if len(config.AccessLog.Kafka.Brokers) > 0 {
+		kafkaSink, err = NewKafkaSink(config.AccessLog.Kafka, logger)
+		if err != nil {
+			logger.Error("error-creating-kafka-sink", zap.Error(err))
+			return nil, err
+		}
+		writers = append(writers, kafkaSink)
+	}
+
 	var dropsondeSourceInstance string
 	if config.Logging.LoggregatorEnabled {
 		dropsondeSourceInstance = strconv.FormatUint(uint64(config.Index), 10)
@@ -79,10 +133,17 @@ func CreateRunningAccessLogger(logger logger.Logger, ls logsender, config *confi
 
 	accessLogger := &FileAndLoggregatorAccessLogger{
 		dropsondeSourceInstance: dropsondeSourceInstance,
-		channel:                 make(chan schema.AccessLogRecord, 1024),
+		channel:                 make(chan schema.AccessLogRecord, config.AccessLog.BufferSize),
 		stopCh:                  make(chan struct{}),
+		doneCh:                  make(chan struct{}),
+		fileBuffer:              fileBuffer,
+		syslogSink:              syslogSink,
+		kafkaSink:               kafkaSink,
+		flushInterval:           config.AccessLog.FlushInterval,
 		disableXFFLogging:       config.Logging.DisableLogForwardedFor,
 		disableSourceIPLogging:  config.Logging.DisableLogSourceIP,
+		jsonFormat:              isJSONFormat(config),
+		template:                template,
 		logger:                  logger,
 		ls:                      ls,
 	}
@@ -92,28 +153,81 @@ func CreateRunningAccessLogger(logger logger.Logger, ls logsender, config *confi
 	return accessLogger, nil
 }
 
+func isJSONFormat(c *config.Config) bool {
+	return c.AccessLog.Format == config.AccessLogFormatJSON
+}
+
 func (x *FileAndLoggregatorAccessLogger) Run() {
+	var flushCh <-chan time.Time
+	if x.fileBuffer != nil {
+		ticker := time.NewTicker(x.flushInterval)
+		defer ticker.Stop()
+		flushCh = ticker.C
+	}
+
+	for {
+		select {
+		case record := <-x.channel:
+			x.write(record)
+		case <-flushCh:
+			x.flush()
+		case <-x.stopCh:
+			x.drain()
+			return
+		}
+	}
+}
+
+// drain writes the records left in the queue and flushes the file.
+func (x *FileAndLoggregatorAccessLogger) drain() {
 	for {
 		select {
 		case record := <-x.channel:
-			if x.writer != nil {
-				_, err := record.WriteTo(x.writer)
-				if err != nil {
-					x.logger.Error("error-emitting-access-log-to-writers", zap.Error(err))
-				}
+			x.write(record)
+		default:
+			x.flush()
+			if x.syslogSink != nil {
+				x.syslogSink.Close()
 			}
-			if x.dropsondeSourceInstance != "" && record.ApplicationID() != "" {
-				err := x.ls.SendAppLog(record.ApplicationID(), record.LogMessage(), "RTR", x.dropsondeSourceInstance)
-				if err != nil {
-					x.logger.Error("error-emitting-access-log-to-writers", zap.Error(err))
-				}
+			if x.kafkaSink != nil {
+				x.kafkaSink.Close()
 			}
-		case <-x.stopCh:
+			x.doneOnce.Do(func() { close(x.doneCh) })
 			return
 		}
 	}
 }
 
+func (x *FileAndLoggregatorAccessLogger) write(record schema.AccessLogRecord) {
+	if x.writer != nil {
+		x.writerLock.Lock()
+		_, err := record.WriteTo(x.writer)
+		x.writerLock.Unlock()
+		if err != nil {
+			x.logger.Error("error-emitting-access-log-to-writers", zap.Error(err))
+		}
+	}
+	if x.dropsondeSourceInstance != "" && record.ApplicationID() != "" {
+		err := x.ls.SendAppLog(record.ApplicationID(), record.LogMessage(), "RTR", x.dropsondeSourceInstance)
+		if err != nil {
+			x.logger.Error("error-emitting-access-log-to-writers", zap.Error(err))
+		}
+	}
+}
+
+func (x *FileAndLoggregatorAccessLogger) flush() {
+	if x.fileBuffer == nil {
+		return
+	}
+
+	x.writerLock.Lock()
+	defer x.writerLock.Unlock()
+	err := x.fileBuffer.Flush()
+	if err != nil {
+		x.logger.Error("error-flushing-access-log", zap.Error(err))
+	}
+}
+
 func (x *FileAndLoggregatorAccessLogger) FileWriter() io.Writer {
 	return x.writer
 }
@@ -125,14 +239,25 @@ func (x *FileAndLoggregatorAccessLogger) DropsondeSourceInstance() string {
 	return x.dropsondeSourceInstance
 }
 
+// Stop waits for the queued records to be written, the file to be flushed
+// and the records queued for the syslog server and Kafka to be sent.
 func (x *FileAndLoggregatorAccessLogger) Stop() {
 	close(x.stopCh)
+	<-x.doneCh
 }
 
+// Log queues the record for writing. The record is dropped rather than
+// blocking the request when the queue is full.
 func (x *FileAndLoggregatorAccessLogger) Log(r schema.AccessLogRecord) {
 	r.DisableXFFLogging = x.disableXFFLogging
 	r.DisableSourceIPLogging = x.disableSourceIPLogging
-	x.channel <- r
+	r.JSONFormat = x.jsonFormat
+	r.Template = x.template
+	select {
+	case x.channel <- r:
+	default:
+		metrics.IncrementCounter(DroppedAccessLogs)
+	}
 }
 
 var ipAddressRegex, _ = regexp.Compile(`^(([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])(:[0-9]{1,5}){1}$`)
@@ -16,6 +16,8 @@ import (
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
 	"github.com/cloudfoundry/dropsonde/log_sender/fake"
+	metrics_fakes "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -152,6 +154,167 @@ var _ = Describe("AccessLog", func() {
 			})
 		})
 
+		Context("when the access log format is json", func() {
+			BeforeEach(func() {
+				logger = test_util.NewTestZapLogger("test")
+				ls = fake.NewFakeLogSender()
+				var err error
+				cfg, err = config.DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				cfg.AccessLog.Format = config.AccessLogFormatJSON
+			})
+
+			It("writes JSON records to the log file and text to dropsonde", func() {
+				file, err := ioutil.TempFile("", "access_log")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.Remove(file.Name())
+
+				cfg.AccessLog.File = file.Name()
+				cfg.Logging.LoggregatorEnabled = true
+				accessLogger, err := accesslog.CreateRunningAccessLogger(logger, ls, cfg)
+				Expect(err).ToNot(HaveOccurred())
+
+				accessLogger.Log(*CreateAccessLogRecord())
+				accessLogger.Stop()
+
+				b, err := ioutil.ReadFile(file.Name())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(b)).To(HavePrefix(`{"host":"foo.bar",`))
+
+				Expect(ls.GetLogs()).To(HaveLen(1))
+				Expect(ls.GetLogs()[0].Message).To(HavePrefix("foo.bar - ["))
+			})
+		})
+
+		Context("when an access log template is set", func() {
+			BeforeEach(func() {
+				logger = test_util.NewTestZapLogger("test")
+				ls = fake.NewFakeLogSender()
+				var err error
+				cfg, err = config.DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				cfg.AccessLog.Template = `$host "$method $request_uri" $status_code $http_user_agent`
+			})
+
+			It("writes templated records to the log file and the fixed layout to dropsonde", func() {
+				file, err := ioutil.TempFile("", "access_log")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.Remove(file.Name())
+
+				cfg.AccessLog.File = file.Name()
+				cfg.Logging.LoggregatorEnabled = true
+				accessLogger, err := accesslog.CreateRunningAccessLogger(logger, ls, cfg)
+				Expect(err).ToNot(HaveOccurred())
+
+				accessLogger.Log(*CreateAccessLogRecord())
+				accessLogger.Stop()
+
+				b, err := ioutil.ReadFile(file.Name())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(b)).To(Equal("foo.bar \"GET /quz?wat\" 200 user-agent\n"))
+
+				Expect(ls.GetLogs()).To(HaveLen(1))
+				Expect(ls.GetLogs()[0].Message).To(HavePrefix("foo.bar - ["))
+			})
+
+			It("reports an error if the template is invalid", func() {
+				cfg.AccessLog.File = "/dev/null"
+				cfg.AccessLog.Template = "$unknown"
+
+				a, err := accesslog.CreateRunningAccessLogger(logger, ls, cfg)
+				Expect(err).To(MatchError("access log template: unknown variable $unknown"))
+				Expect(a).To(BeNil())
+			})
+		})
+
+		Context("when the access log file is buffered", func() {
+			var file *os.File
+
+			readFile := func() (string, error) {
+				b, err := ioutil.ReadFile(file.Name())
+				return string(b), err
+			}
+
+			BeforeEach(func() {
+				logger = test_util.NewTestZapLogger("test")
+				ls = fake.NewFakeLogSender()
+				var err error
+				cfg, err = config.DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+
+				file, err = ioutil.TempFile("", "access_log")
+				Expect(err).NotTo(HaveOccurred())
+				cfg.AccessLog.File = file.Name()
+			})
+
+			AfterEach(func() {
+				os.Remove(file.Name())
+			})
+
+			It("flushes the file on the interval", func() {
+				cfg.AccessLog.FlushInterval = 100 * time.Millisecond
+				accessLogger, err := accesslog.CreateRunningAccessLogger(logger, ls, cfg)
+				Expect(err).ToNot(HaveOccurred())
+				defer accessLogger.Stop()
+
+				accessLogger.Log(*CreateAccessLogRecord())
+
+				Eventually(readFile).Should(ContainSubstring("foo.bar"))
+			})
+
+			It("flushes the file when stopped", func() {
+				cfg.AccessLog.FlushInterval = time.Hour
+				accessLogger, err := accesslog.CreateRunningAccessLogger(logger, ls, cfg)
+				Expect(err).ToNot(HaveOccurred())
+
+				accessLogger.Log(*CreateAccessLogRecord())
+				Consistently(readFile, 200*time.Millisecond).Should(BeEmpty())
+
+				accessLogger.Stop()
+				Expect(readFile()).To(ContainSubstring("foo.bar"))
+			})
+		})
+
+		Context("when the queue is full", func() {
+			var (
+				sender *metrics_fakes.FakeMetricSender
+				bls    *blockingLogSender
+			)
+
+			BeforeEach(func() {
+				logger = test_util.NewTestZapLogger("test")
+				bls = &blockingLogSender{unblock: make(chan struct{})}
+				sender = metrics_fakes.NewFakeMetricSender()
+				metrics.Initialize(sender, nil)
+
+				var err error
+				cfg, err = config.DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				cfg.Logging.LoggregatorEnabled = true
+				cfg.AccessLog.BufferSize = 1
+			})
+
+			It("drops records and counts them without blocking", func() {
+				accessLogger, err := accesslog.CreateRunningAccessLogger(logger, bls, cfg)
+				Expect(err).ToNot(HaveOccurred())
+
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					for i := 0; i < 5; i++ {
+						accessLogger.Log(*CreateAccessLogRecord())
+					}
+				}()
+				Eventually(done).Should(BeClosed())
+
+				// At most one record is being sent and one is queued.
+				Expect(sender.GetCounter(accesslog.DroppedAccessLogs)).To(BeNumerically(">=", 3))
+
+				close(bls.unblock)
+				accessLogger.Stop()
+			})
+		})
+
 		Context("when DisableLogForwardedFor is set to true", func() {
 			var (
 				syslogServer net.Listener
@@ -344,6 +507,25 @@ var _ = Describe("AccessLog", func() {
 			Expect(accessLogger.(*accesslog.FileAndLoggregatorAccessLogger).DropsondeSourceInstance()).ToNot(BeEmpty())
 		})
 
+		It("should have one writer configured if only a syslog address is set", func() {
+			cfg.AccessLog.Syslog.Address = "127.0.0.1:6514"
+
+			accessLogger, err := accesslog.CreateRunningAccessLogger(baseLogger, ls, cfg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(accessLogger.(*accesslog.FileAndLoggregatorAccessLogger).WriterCount()).To(Equal(1))
+			accessLogger.Stop()
+		})
+
+		It("should have one writer configured if only kafka brokers are set", func() {
+			cfg.AccessLog.Kafka.Brokers = []string{"127.0.0.1:9092"}
+			cfg.AccessLog.Kafka.Topic = "access-logs"
+
+			accessLogger, err := accesslog.CreateRunningAccessLogger(baseLogger, ls, cfg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(accessLogger.(*accesslog.FileAndLoggregatorAccessLogger).WriterCount()).To(Equal(1))
+			accessLogger.Stop()
+		})
+
 		It("reports an error if the access log location is invalid", func() {
 			cfg.AccessLog.File = "/this\\is/illegal"
 
@@ -416,6 +598,15 @@ func CreateAccessLogRecord() *schema.AccessLogRecord {
 	return &r
 }
 
+type blockingLogSender struct {
+	unblock chan struct{}
+}
+
+func (b *blockingLogSender) SendAppLog(appID, message, sourceType, sourceInstance string) error {
+	<-b.unblock
+	return nil
+}
+
 type nullWriter struct{}
 
 func (n nullWriter) Write(b []byte) (int, error) {
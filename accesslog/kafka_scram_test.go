@@ -0,0 +1,54 @@
+package accesslog
+
+import (
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/xdg-go/scram"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("newSCRAMClient", func() {
+	// The SCRAM-SHA-256 example exchange of RFC 7677.
+	const (
+		serverFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+		serverFinal = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+	)
+
+	var conversation *scram.ClientConversation
+
+	BeforeEach(func() {
+		client, err := newSCRAMClient(config.KafkaSASLMechanismSCRAMSHA256, "user", "pencil")
+		Expect(err).ToNot(HaveOccurred())
+		client = client.WithNonceGenerator(func() string { return "rOprNGfwEbeRWgbNEkqO" })
+		conversation = client.NewConversation()
+	})
+
+	It("computes the client proof and verifies the server signature", func() {
+		Expect(conversation.Step("")).To(Equal("n,,n=user,r=rOprNGfwEbeRWgbNEkqO"))
+
+		clientFinal, err := conversation.Step(serverFirst)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clientFinal).To(Equal("c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="))
+
+		_, err = conversation.Step(serverFinal)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conversation.Done()).To(BeTrue())
+		Expect(conversation.Valid()).To(BeTrue())
+	})
+
+	It("rejects a server nonce that does not extend the client nonce", func() {
+		conversation.Step("")
+		_, err := conversation.Step("r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects a wrong server signature", func() {
+		conversation.Step("")
+		_, err := conversation.Step(serverFirst)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = conversation.Step("v=AAAA")
+		Expect(err).To(HaveOccurred())
+	})
+})
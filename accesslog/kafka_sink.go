@@ -0,0 +1,196 @@
+package accesslog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/uber-go/zap"
+	"github.com/xdg-go/scram"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// DroppedKafkaAccessLogs counts the records dropped because the Kafka
+// cluster could not be reached or did not keep up.
+const DroppedKafkaAccessLogs = "dropped_kafka_access_logs"
+
+const kafkaDialTimeout = 5 * time.Second
+
+// KafkaSink produces access log records to a Kafka topic with the
+// asynchronous producer of sarama. Records are queued and handed to the
+// producer by a goroutine. The producer spreads the records over the
+// partitions of the topic in turn and sends them in batches, so that a slow or
+// unreachable cluster never holds up the other writers. A record that fails
+// is retried once, and is dropped if it fails again.
+type KafkaSink struct {
+	cfg      config.AccessLogKafkaConfig
+	producer sarama.AsyncProducer
+	logger   logger.Logger
+
+	queue  chan []byte
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func NewKafkaSink(cfg config.AccessLogKafkaConfig, logger logger.Logger) (*KafkaSink, error) {
+	producerConfig, err := newKafkaProducerConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewAsyncProducer(cfg.Brokers, producerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &KafkaSink{
+		cfg:      cfg,
+		producer: producer,
+		logger:   logger,
+		queue:    make(chan []byte, cfg.BufferSize),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	go s.run()
+	go s.countErrors()
+	return s, nil
+}
+
+// newKafkaProducerConfig returns the config of the producer. The producer
+// does not fetch the metadata of the cluster until it sends the first batch,
+// so that the router starts while the cluster is unreachable.
+func newKafkaProducerConfig(cfg config.AccessLogKafkaConfig) (*sarama.Config, error) {
+	c := sarama.NewConfig()
+	c.ClientID = cfg.ClientID
+	c.Version = sarama.V1_0_0_0
+	c.Metadata.Full = false
+	c.Net.DialTimeout = kafkaDialTimeout
+
+	c.Producer.RequiredAcks = sarama.WaitForLocal
+	c.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	c.Producer.Flush.Messages = cfg.BatchSize
+	c.Producer.Flush.MaxMessages = cfg.BatchSize
+	c.Producer.Flush.Frequency = cfg.FlushInterval
+	c.Producer.Retry.Max = 1
+	c.Producer.Return.Errors = true
+
+	if cfg.TLS {
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.SkipSSLValidation}
+		if cfg.CACerts != "" {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM([]byte(cfg.CACerts)) {
+				return nil, errors.New("invalid access log kafka ca_certs")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		c.Net.TLS.Enable = true
+		c.Net.TLS.Config = tlsConfig
+	}
+
+	if cfg.SASL.Mechanism != "" {
+		c.Net.SASL.Enable = true
+		c.Net.SASL.Mechanism = sarama.SASLMechanism(cfg.SASL.Mechanism)
+		c.Net.SASL.User = cfg.SASL.Username
+		c.Net.SASL.Password = cfg.SASL.Password
+		if cfg.SASL.Mechanism != config.KafkaSASLMechanismPlain {
+			c.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{mechanism: cfg.SASL.Mechanism}
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// Write queues the record, without its trailing newline. The record is
+// dropped when the queue is full.
+func (s *KafkaSink) Write(p []byte) (int, error) {
+	record := append([]byte(nil), bytes.TrimRight(p, "\n")...)
+	select {
+	case s.queue <- record:
+	default:
+		metrics.IncrementCounter(DroppedKafkaAccessLogs)
+	}
+	return len(p), nil
+}
+
+// Close sends the queued records and closes the connections to the brokers.
+// It waits up to the flush interval for the last batch to be sent.
+func (s *KafkaSink) Close() error {
+	close(s.stopCh)
+	<-s.doneCh
+	return nil
+}
+
+// run hands the queued records to the producer, which blocks while the
+// producer does not keep up, until the sink is closed.
+func (s *KafkaSink) run() {
+	defer s.producer.AsyncClose()
+
+	for {
+		select {
+		case record := <-s.queue:
+			s.produce(record)
+		case <-s.stopCh:
+			for len(s.queue) > 0 {
+				s.produce(<-s.queue)
+			}
+			return
+		}
+	}
+}
+
+func (s *KafkaSink) produce(record []byte) {
+	s.producer.Input() <- &sarama.ProducerMessage{Topic: s.cfg.Topic, Value: sarama.ByteEncoder(record)}
+}
+
+// countErrors logs the records that could not be produced and counts them as
+// dropped, until the producer is closed.
+func (s *KafkaSink) countErrors() {
+	defer close(s.doneCh)
+	for err := range s.producer.Errors() {
+		s.logger.Error("error-producing-access-logs-to-kafka", zap.String("topic", s.cfg.Topic), zap.Error(err.Err))
+		metrics.IncrementCounter(DroppedKafkaAccessLogs)
+	}
+}
+
+// scramClient is the SCRAM exchange of xdg-go/scram, driven by sarama.
+type scramClient struct {
+	mechanism    string
+	conversation *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(username, password, authzID string) error {
+	client, err := newSCRAMClient(c.mechanism, username, password)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
+
+// newSCRAMClient returns the SCRAM client (RFC 5802) of the mechanism, which
+// authenticates without channel binding.
+func newSCRAMClient(mechanism, username, password string) (*scram.Client, error) {
+	hash := scram.SHA256
+	if mechanism == config.KafkaSASLMechanismSCRAMSHA512 {
+		hash = scram.SHA512
+	}
+	return hash.NewClient(username, password, "")
+}
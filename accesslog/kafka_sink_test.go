@@ -0,0 +1,448 @@
+package accesslog_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/accesslog"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"github.com/xdg-go/scram"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type producedBatch struct {
+	partition int32
+	records   []string
+}
+
+// fakeKafkaBroker is a single broker that leads both partitions of a topic.
+// It answers the Metadata, Produce and SASL requests that the producer makes,
+// in the versions of Kafka 1.0, and sends the batch of each partition it
+// receives to the batches channel. With SCRAM, it knows the password "secret"
+// of the user "router".
+type fakeKafkaBroker struct {
+	listener net.Listener
+	topic    string
+	batches  chan producedBatch
+
+	mu         sync.Mutex
+	auth       []string
+	scramValid bool
+}
+
+func newFakeKafkaBroker(topic string) *fakeKafkaBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	b := &fakeKafkaBroker{
+		listener: ln,
+		topic:    topic,
+		batches:  make(chan producedBatch, 10),
+	}
+	go b.serve()
+	return b
+}
+
+func (b *fakeKafkaBroker) addr() string {
+	return b.listener.Addr().String()
+}
+
+func (b *fakeKafkaBroker) authentications() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.auth...)
+}
+
+func (b *fakeKafkaBroker) authenticatedWithSCRAM() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.scramValid
+}
+
+func (b *fakeKafkaBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *fakeKafkaBroker) handle(conn net.Conn) {
+	defer GinkgoRecover()
+	defer conn.Close()
+
+	var mechanism string
+	var conversation *scram.ServerConversation
+	for {
+		var size int32
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		req := make([]byte, size)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+
+		r := bytes.NewReader(req)
+		apiKey := readInt16(r)
+		readInt16(r) // version
+		correlationID := readInt32(r)
+		readString(r) // client id
+
+		resp := &bytes.Buffer{}
+		writeInt32(resp, correlationID)
+		switch apiKey {
+		case 3:
+			b.metadata(resp)
+		case 0:
+			b.produce(r, resp)
+		case 17:
+			mechanism = readString(r)
+			if mechanism == "SCRAM-SHA-512" {
+				conversation = newSCRAMServerConversation()
+			}
+			writeInt16(resp, 0)
+			writeInt32(resp, 1)
+			writeString(resp, mechanism)
+		case 36:
+			auth := readBytes(r)
+			b.mu.Lock()
+			b.auth = append(b.auth, mechanism+":"+string(auth))
+			b.mu.Unlock()
+			if conversation == nil {
+				writeInt16(resp, 0)
+				writeInt16(resp, -1)
+				writeInt32(resp, 0)
+				break
+			}
+			challenge, err := conversation.Step(string(auth))
+			if err != nil {
+				writeInt16(resp, 58) // SASL authentication failed
+				writeString(resp, err.Error())
+				writeInt32(resp, 0)
+				break
+			}
+			if conversation.Valid() {
+				b.mu.Lock()
+				b.scramValid = true
+				b.mu.Unlock()
+			}
+			writeInt16(resp, 0)
+			writeInt16(resp, -1)
+			writeInt32(resp, int32(len(challenge)))
+			io.WriteString(resp, challenge)
+		default:
+			Fail("unexpected Kafka request " + strconv.Itoa(int(apiKey)))
+		}
+
+		writeInt32(conn, int32(resp.Len()))
+		conn.Write(resp.Bytes())
+	}
+}
+
+func newSCRAMServerConversation() *scram.ServerConversation {
+	client, err := scram.SHA512.NewClient("router", "secret", "")
+	Expect(err).ToNot(HaveOccurred())
+	credentials := client.GetStoredCredentials(scram.KeyFactors{Salt: "salt", Iters: 4096})
+	server, err := scram.SHA512.NewServer(func(string) (scram.StoredCredentials, error) {
+		return credentials, nil
+	})
+	Expect(err).ToNot(HaveOccurred())
+	return server.NewConversation()
+}
+
+// metadata writes a Metadata response of version 5.
+func (b *fakeKafkaBroker) metadata(resp *bytes.Buffer) {
+	host, port, _ := net.SplitHostPort(b.addr())
+	p, _ := strconv.Atoi(port)
+
+	writeInt32(resp, 0) // throttle time
+	writeInt32(resp, 1)
+	writeInt32(resp, 0) // broker id
+	writeString(resp, host)
+	writeInt32(resp, int32(p))
+	writeInt16(resp, -1) // rack
+	writeInt16(resp, -1) // cluster id
+	writeInt32(resp, 0)  // controller id
+
+	writeInt32(resp, 1)
+	writeInt16(resp, 0)
+	writeString(resp, b.topic)
+	resp.WriteByte(0) // is internal
+	writeInt32(resp, 2)
+	for partition := int32(0); partition < 2; partition++ {
+		writeInt16(resp, 0)
+		writeInt32(resp, partition)
+		writeInt32(resp, 0) // leader
+		writeInt32(resp, 1)
+		writeInt32(resp, 0) // replica
+		writeInt32(resp, 1)
+		writeInt32(resp, 0) // in-sync replica
+		writeInt32(resp, 0) // offline replicas
+	}
+}
+
+// produce reads a Produce request of version 5 and writes its response.
+func (b *fakeKafkaBroker) produce(r *bytes.Reader, resp *bytes.Buffer) {
+	readString(r) // transactional id
+	Expect(readInt16(r)).To(Equal(int16(1)))
+	readInt32(r) // timeout
+	Expect(readInt32(r)).To(Equal(int32(1)))
+	Expect(readString(r)).To(Equal(b.topic))
+	partitions := readInt32(r)
+
+	writeInt32(resp, 1)
+	writeString(resp, b.topic)
+	writeInt32(resp, partitions)
+	for i := int32(0); i < partitions; i++ {
+		partition := readInt32(r)
+		batch := readBytes(r)
+		b.batches <- producedBatch{partition: partition, records: decodeRecordBatch(batch)}
+
+		writeInt32(resp, partition)
+		writeInt16(resp, 0)
+		writeInt64(resp, 0)  // base offset
+		writeInt64(resp, -1) // log append time
+		writeInt64(resp, 0)  // log start offset
+	}
+	writeInt32(resp, 0) // throttle time
+}
+
+func decodeRecordBatch(batch []byte) []string {
+	Expect(len(batch)).To(BeNumerically(">", 61))
+	Expect(binary.BigEndian.Uint32(batch[8:])).To(Equal(uint32(len(batch) - 12)))
+	Expect(batch[16]).To(Equal(byte(2)))
+	crc := crc32.Checksum(batch[21:], crc32.MakeTable(crc32.Castagnoli))
+	Expect(binary.BigEndian.Uint32(batch[17:])).To(Equal(crc))
+
+	r := bytes.NewReader(batch[57:])
+	count := int(readInt32(r))
+	records := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		readVarint(r) // length
+		r.ReadByte()  // attributes
+		readVarint(r) // timestamp delta
+		Expect(readVarint(r)).To(Equal(int64(i)))
+		Expect(readVarint(r)).To(Equal(int64(-1)))
+		value := make([]byte, readVarint(r))
+		io.ReadFull(r, value)
+		Expect(readVarint(r)).To(Equal(int64(0)))
+		records = append(records, string(value))
+	}
+	return records
+}
+
+func readInt16(r io.Reader) int16 {
+	var v int16
+	binary.Read(r, binary.BigEndian, &v)
+	return v
+}
+
+func readInt32(r io.Reader) int32 {
+	var v int32
+	binary.Read(r, binary.BigEndian, &v)
+	return v
+}
+
+func readVarint(r *bytes.Reader) int64 {
+	v, _ := binary.ReadVarint(r)
+	return v
+}
+
+func readString(r io.Reader) string {
+	n := readInt16(r)
+	if n < 0 {
+		return ""
+	}
+	b := make([]byte, n)
+	io.ReadFull(r, b)
+	return string(b)
+}
+
+func readBytes(r io.Reader) []byte {
+	b := make([]byte, readInt32(r))
+	io.ReadFull(r, b)
+	return b
+}
+
+func writeInt16(w io.Writer, v int16) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func writeInt32(w io.Writer, v int32) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func writeInt64(w io.Writer, v int64) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func writeString(w io.Writer, s string) {
+	writeInt16(w, int16(len(s)))
+	io.WriteString(w, s)
+}
+
+var _ = Describe("KafkaSink", func() {
+	var (
+		logger logger.Logger
+		cfg    config.AccessLogKafkaConfig
+		broker *fakeKafkaBroker
+	)
+
+	BeforeEach(func() {
+		logger = test_util.NewTestZapLogger("test")
+		broker = newFakeKafkaBroker("access-logs")
+
+		cfg = config.AccessLogKafkaConfig{
+			Brokers:       []string{broker.addr()},
+			Topic:         "access-logs",
+			ClientID:      "gorouter",
+			BatchSize:     2,
+			FlushInterval: time.Hour,
+			BufferSize:    10,
+		}
+	})
+
+	AfterEach(func() {
+		broker.listener.Close()
+	})
+
+	It("produces the records in batches spread over the partitions of the topic", func() {
+		sink, err := accesslog.NewKafkaSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		n, err := sink.Write([]byte("foo.bar - [first]\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(18))
+		sink.Write([]byte("foo.bar - [second]\n"))
+		sink.Write([]byte("foo.bar - [third]\n"))
+		sink.Write([]byte("foo.bar - [fourth]\n"))
+
+		byPartition := map[int32][]string{}
+		for produced := 0; produced < 4; {
+			var batch producedBatch
+			Eventually(broker.batches).Should(Receive(&batch))
+			Expect(len(batch.records)).To(BeNumerically("<=", cfg.BatchSize))
+			byPartition[batch.partition] = append(byPartition[batch.partition], batch.records...)
+			produced += len(batch.records)
+		}
+		Expect(byPartition).To(Equal(map[int32][]string{
+			0: {"foo.bar - [first]", "foo.bar - [third]"},
+			1: {"foo.bar - [second]", "foo.bar - [fourth]"},
+		}))
+	})
+
+	It("produces a partial batch on the flush interval", func() {
+		cfg.FlushInterval = 50 * time.Millisecond
+		sink, err := accesslog.NewKafkaSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		sink.Write([]byte("record\n"))
+
+		var batch producedBatch
+		Eventually(broker.batches).Should(Receive(&batch))
+		Expect(batch.records).To(Equal([]string{"record"}))
+	})
+
+	It("produces the queued records when closed", func() {
+		cfg.FlushInterval = 50 * time.Millisecond
+		sink, err := accesslog.NewKafkaSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		sink.Write([]byte("record\n"))
+		Expect(sink.Close()).To(Succeed())
+
+		var batch producedBatch
+		Expect(broker.batches).To(Receive(&batch))
+		Expect(batch.records).To(Equal([]string{"record"}))
+	})
+
+	It("authenticates with SASL PLAIN", func() {
+		cfg.SASL = config.AccessLogKafkaSASLConfig{
+			Mechanism: config.KafkaSASLMechanismPlain,
+			Username:  "router",
+			Password:  "secret",
+		}
+		cfg.FlushInterval = 50 * time.Millisecond
+		sink, err := accesslog.NewKafkaSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		sink.Write([]byte("record\n"))
+		Expect(sink.Close()).To(Succeed())
+
+		Expect(broker.batches).To(Receive())
+		Expect(broker.authentications()).ToNot(BeEmpty())
+		for _, auth := range broker.authentications() {
+			Expect(auth).To(Equal("PLAIN:\x00router\x00secret"))
+		}
+	})
+
+	It("authenticates with SASL SCRAM-SHA-512", func() {
+		cfg.SASL = config.AccessLogKafkaSASLConfig{
+			Mechanism: config.KafkaSASLMechanismSCRAMSHA512,
+			Username:  "router",
+			Password:  "secret",
+		}
+		cfg.FlushInterval = 50 * time.Millisecond
+		sink, err := accesslog.NewKafkaSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		sink.Write([]byte("record\n"))
+		Expect(sink.Close()).To(Succeed())
+
+		Expect(broker.batches).To(Receive())
+		Expect(broker.authenticatedWithSCRAM()).To(BeTrue())
+	})
+
+	It("does not produce records when SCRAM authentication fails", func() {
+		cfg.SASL = config.AccessLogKafkaSASLConfig{
+			Mechanism: config.KafkaSASLMechanismSCRAMSHA512,
+			Username:  "router",
+			Password:  "wrong",
+		}
+		sink, err := accesslog.NewKafkaSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		sink.Write([]byte("record\n"))
+		Expect(sink.Close()).To(Succeed())
+
+		Expect(broker.batches).ToNot(Receive())
+	})
+
+	It("drops records without blocking when the queue is full", func() {
+		broker.listener.Close()
+		cfg.BufferSize = 1
+		sink, err := accesslog.NewKafkaSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				sink.Write([]byte("record\n"))
+			}
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("rejects invalid CA certificates", func() {
+		cfg.TLS = true
+		cfg.CACerts = "not a certificate"
+		_, err := accesslog.NewKafkaSink(cfg, logger)
+		Expect(err).To(HaveOccurred())
+	})
+})
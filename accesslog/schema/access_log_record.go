@@ -2,6 +2,7 @@ package schema
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"net/http"
 	"strconv"
@@ -80,6 +81,7 @@ func (b *recordBuffer) WriteDashOrStringValue(s string) {
 type AccessLogRecord struct {
 	Request                *http.Request
 	HeadersOverride        http.Header
+	ResponseHeaders        http.Header
 	StatusCode             int
 	RouteEndpoint          *route.Endpoint
 	StartedAt              time.Time
@@ -90,13 +92,29 @@ type AccessLogRecord struct {
 	ExtraHeadersToLog      []string
 	DisableXFFLogging      bool
 	DisableSourceIPLogging bool
-	record                 []byte
+	// JSONFormat writes the record as a JSON object rather than as text. The
+	// log message sent to the app is always text.
+	JSONFormat bool
+	// Template, when set, lays out the record written as text. The log
+	// message sent to the app keeps the fixed layout.
+	Template       *Template
+	record         []byte
+	jsonRecord     []byte
+	templateRecord []byte
 }
 
 func (r *AccessLogRecord) formatStartedAt() string {
 	return r.StartedAt.Format("2006-01-02T15:04:05.000-0700")
 }
 
+// headers returns the headers of the request as sent to the backend.
+func (r *AccessLogRecord) headers() http.Header {
+	if r.HeadersOverride != nil {
+		return r.HeadersOverride
+	}
+	return r.Request.Header
+}
+
 func (r *AccessLogRecord) responseTime() float64 {
 	return float64(r.FinishedAt.UnixNano()-r.StartedAt.UnixNano()) / float64(time.Second)
 }
@@ -119,10 +137,7 @@ func (r *AccessLogRecord) makeRecord() []byte {
 		destIPandPort = r.RouteEndpoint.CanonicalAddr()
 	}
 
-	headers := r.Request.Header
-	if r.HeadersOverride != nil {
-		headers = r.HeadersOverride
-	}
+	headers := r.headers()
 
 	b := new(recordBuffer)
 
@@ -176,9 +191,121 @@ func (r *AccessLogRecord) makeRecord() []byte {
 	return b.Bytes()
 }
 
+// getJSONRecord memoizes makeJSONRecord()
+func (r *AccessLogRecord) getJSONRecord() []byte {
+	if len(r.jsonRecord) == 0 {
+		r.jsonRecord = r.makeJSONRecord()
+	}
+
+	return r.jsonRecord
+}
+
+// makeJSONRecord makes a JSON object of the values of makeRecord on one line,
+// with the same names, and with null for the values written as "-". The
+// extra headers follow, unless they have the name of a value.
+func (r *AccessLogRecord) makeJSONRecord() []byte {
+	var appID, destIPandPort, appIndex string
+
+	if r.RouteEndpoint != nil {
+		appID = r.RouteEndpoint.ApplicationId
+		appIndex = r.RouteEndpoint.PrivateInstanceIndex
+		destIPandPort = r.RouteEndpoint.CanonicalAddr()
+	}
+
+	headers := r.headers()
+
+	remoteAddr := r.Request.RemoteAddr
+	if r.DisableSourceIPLogging {
+		remoteAddr = ""
+	}
+	forwardedFor := headers.Get("X-Forwarded-For")
+	if r.DisableXFFLogging {
+		forwardedFor = ""
+	}
+
+	var statusCode, responseTime interface{}
+	if r.StatusCode != 0 {
+		statusCode = r.StatusCode
+	}
+	if t := r.responseTime(); t >= 0 {
+		responseTime = t
+	}
+
+	fields := []jsonField{
+		{"host", r.Request.Host},
+		{"timestamp", r.formatStartedAt()},
+		{"method", r.Request.Method},
+		{"request_uri", r.Request.URL.RequestURI()},
+		{"protocol", r.Request.Proto},
+		{"status_code", statusCode},
+		{"request_bytes_received", r.RequestBytesReceived},
+		{"body_bytes_sent", r.BodyBytesSent},
+		{"referer", nullIfEmpty(headers.Get("Referer"))},
+		{"user_agent", nullIfEmpty(headers.Get("User-Agent"))},
+		{"remote_addr", nullIfEmpty(remoteAddr)},
+		{"backend_addr", nullIfEmpty(destIPandPort)},
+		{"x_forwarded_for", nullIfEmpty(forwardedFor)},
+		{"x_forwarded_proto", nullIfEmpty(headers.Get("X-Forwarded-Proto"))},
+		{"vcap_request_id", nullIfEmpty(headers.Get("X-Vcap-Request-Id"))},
+		{"response_time", responseTime},
+		{"app_id", nullIfEmpty(appID)},
+		{"app_index", nullIfEmpty(appIndex)},
+	}
+
+	names := make(map[string]bool, len(fields)+len(r.ExtraHeadersToLog))
+	for _, f := range fields {
+		names[f.name] = true
+	}
+	for _, header := range r.ExtraHeadersToLog {
+		headerName := strings.Replace(strings.ToLower(header), "-", "_", -1)
+		if names[headerName] {
+			continue
+		}
+		names[headerName] = true
+		fields = append(fields, jsonField{headerName, nullIfEmpty(r.Request.Header.Get(header))})
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.name)
+		value, _ := json.Marshal(f.value)
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteString("}\n")
+
+	return b.Bytes()
+}
+
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
 // WriteTo allows the AccessLogRecord to implement the io.WriterTo interface
 func (r *AccessLogRecord) WriteTo(w io.Writer) (int64, error) {
-	bytesWritten, err := w.Write(r.getRecord())
+	var record []byte
+	switch {
+	case r.JSONFormat:
+		record = r.getJSONRecord()
+	case r.Template != nil:
+		record = r.getTemplateRecord()
+	default:
+		record = r.getRecord()
+	}
+	bytesWritten, err := w.Write(record)
 	return int64(bytesWritten), err
 }
 
@@ -2,6 +2,7 @@ package schema_test
 
 import (
 	"bytes"
+	"encoding/json"
 
 	"code.cloudfoundry.org/gorouter/accesslog/schema"
 	"code.cloudfoundry.org/gorouter/handlers"
@@ -223,6 +224,73 @@ var _ = Describe("AccessLogRecord", func() {
 			Eventually(r).Should(gbytes.Say(`vcap_request_id:"abc-123-xyz-pdq" response_time:60 app_id:"FakeApplicationId" `))
 			Eventually(r).Should(gbytes.Say(`app_index:"3"\n`))
 		})
+
+		Context("in the JSON format", func() {
+			writeJSON := func() map[string]interface{} {
+				b := new(bytes.Buffer)
+				_, err := record.WriteTo(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b.String()).To(HaveSuffix("}\n"))
+				Expect(b.String()).To(HaveLen(len(bytes.TrimSpace(b.Bytes())) + 1))
+
+				var fields map[string]interface{}
+				Expect(json.Unmarshal(b.Bytes(), &fields)).To(Succeed())
+				return fields
+			}
+
+			BeforeEach(func() {
+				record.JSONFormat = true
+			})
+
+			It("writes a JSON object with all values", func() {
+				Expect(writeJSON()).To(Equal(map[string]interface{}{
+					"host":                   "FakeRequestHost",
+					"timestamp":              "2000-01-01T00:00:00.000+0000",
+					"method":                 "FakeRequestMethod",
+					"request_uri":            "http://example.com/request",
+					"protocol":               "FakeRequestProto",
+					"status_code":            float64(200),
+					"request_bytes_received": float64(30),
+					"body_bytes_sent":        float64(23),
+					"referer":                "FakeReferer",
+					"user_agent":             "FakeUserAgent",
+					"remote_addr":            "FakeRemoteAddr",
+					"backend_addr":           "1.2.3.4:1234",
+					"x_forwarded_for":        "FakeProxy1, FakeProxy2",
+					"x_forwarded_proto":      "FakeOriginalRequestProto",
+					"vcap_request_id":        "abc-123-xyz-pdq",
+					"response_time":          float64(60),
+					"app_id":                 "FakeApplicationId",
+					"app_index":              "3",
+				}))
+			})
+
+			It("writes null for missing values", func() {
+				record.Request.Header = http.Header{}
+				record.StatusCode = 0
+				record.FinishedAt = time.Time{}
+				record.DisableSourceIPLogging = true
+
+				fields := writeJSON()
+				for _, name := range []string{"status_code", "referer", "user_agent", "remote_addr", "x_forwarded_for", "vcap_request_id", "response_time"} {
+					Expect(fields).To(HaveKeyWithValue(name, BeNil()), name)
+				}
+			})
+
+			It("appends extra headers that do not have the name of a value", func() {
+				record.Request.Header.Set("Cache-Control", "no-cache")
+				record.ExtraHeadersToLog = []string{"Cache-Control", "Doesnt-Exist", "Host"}
+
+				fields := writeJSON()
+				Expect(fields).To(HaveKeyWithValue("cache_control", "no-cache"))
+				Expect(fields).To(HaveKeyWithValue("doesnt_exist", BeNil()))
+				Expect(fields).To(HaveKeyWithValue("host", "FakeRequestHost"))
+			})
+
+			It("keeps the text format for the log message", func() {
+				Expect(record.LogMessage()).To(HavePrefix("FakeRequestHost - ["))
+			})
+		})
 	})
 
 	Describe("ApplicationID", func() {
@@ -0,0 +1,204 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Template lays out the text of access log records, as the log_format of
+// nginx does.
+type Template struct {
+	parts []templatePart
+}
+
+// templatePart is either literal text or, when value is set, a variable.
+type templatePart struct {
+	literal string
+	value   func(r *AccessLogRecord) string
+}
+
+// templateFields are the variables for the values of a record, named as in
+// the JSON format.
+var templateFields = map[string]func(r *AccessLogRecord) string{
+	"host":        func(r *AccessLogRecord) string { return r.Request.Host },
+	"timestamp":   func(r *AccessLogRecord) string { return r.formatStartedAt() },
+	"method":      func(r *AccessLogRecord) string { return r.Request.Method },
+	"request_uri": func(r *AccessLogRecord) string { return r.Request.URL.RequestURI() },
+	"protocol":    func(r *AccessLogRecord) string { return r.Request.Proto },
+	"status_code": func(r *AccessLogRecord) string {
+		if r.StatusCode == 0 {
+			return ""
+		}
+		return strconv.Itoa(r.StatusCode)
+	},
+	"request_bytes_received": func(r *AccessLogRecord) string { return strconv.Itoa(r.RequestBytesReceived) },
+	"body_bytes_sent":        func(r *AccessLogRecord) string { return strconv.Itoa(r.BodyBytesSent) },
+	"referer":                func(r *AccessLogRecord) string { return r.headers().Get("Referer") },
+	"user_agent":             func(r *AccessLogRecord) string { return r.headers().Get("User-Agent") },
+	"remote_addr": func(r *AccessLogRecord) string {
+		if r.DisableSourceIPLogging {
+			return ""
+		}
+		return r.Request.RemoteAddr
+	},
+	"backend_addr": func(r *AccessLogRecord) string {
+		if r.RouteEndpoint == nil {
+			return ""
+		}
+		return r.RouteEndpoint.CanonicalAddr()
+	},
+	"x_forwarded_for": func(r *AccessLogRecord) string {
+		if r.DisableXFFLogging {
+			return ""
+		}
+		return r.headers().Get("X-Forwarded-For")
+	},
+	"x_forwarded_proto": func(r *AccessLogRecord) string { return r.headers().Get("X-Forwarded-Proto") },
+	"vcap_request_id":   func(r *AccessLogRecord) string { return r.headers().Get("X-Vcap-Request-Id") },
+	"response_time": func(r *AccessLogRecord) string {
+		t := r.responseTime()
+		if t < 0 {
+			return ""
+		}
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	},
+	"app_id": func(r *AccessLogRecord) string { return r.ApplicationID() },
+	"app_index": func(r *AccessLogRecord) string {
+		if r.RouteEndpoint == nil {
+			return ""
+		}
+		return r.RouteEndpoint.PrivateInstanceIndex
+	},
+}
+
+// ParseTemplate parses a template of text and variables, which are written
+// as $name or ${name}, and $$ for a dollar sign. The variables are:
+//
+//   - the values of the JSON format, such as $host, $status_code and
+//     $response_time
+//   - $http_<name> for a header of the request as sent to the backend, such
+//     as $http_x_b3_traceid for X-B3-Traceid
+//   - $sent_http_<name> for a header of the response, such as
+//     $sent_http_content_type for Content-Type
+//
+// Missing values are written as "-". Quotes, backslashes and control
+// characters in values are escaped as \xHH, so that a value cannot break the
+// record or the line.
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{}
+	var literal strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			literal.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '$' {
+			literal.WriteByte('$')
+			i++
+			continue
+		}
+
+		var name string
+		if i+1 < len(s) && s[i+1] == '{' {
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("access log template: unterminated ${ at offset %d", i)
+			}
+			name = s[i+2 : i+2+end]
+			i += 2 + end
+		} else {
+			end := i + 1
+			for end < len(s) && isTemplateNameByte(s[end]) {
+				end++
+			}
+			name = s[i+1 : end]
+			i = end - 1
+		}
+
+		value, err := templateVariable(strings.ToLower(name))
+		if err != nil {
+			return nil, err
+		}
+		if literal.Len() > 0 {
+			t.parts = append(t.parts, templatePart{literal: literal.String()})
+			literal.Reset()
+		}
+		t.parts = append(t.parts, templatePart{value: value})
+	}
+	if literal.Len() > 0 {
+		t.parts = append(t.parts, templatePart{literal: literal.String()})
+	}
+	return t, nil
+}
+
+func isTemplateNameByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func templateVariable(name string) (func(r *AccessLogRecord) string, error) {
+	if name == "" {
+		return nil, fmt.Errorf("access log template: $ must be followed by a variable name, or be written as $$")
+	}
+	if value, ok := templateFields[name]; ok {
+		return value, nil
+	}
+	if header := strings.TrimPrefix(name, "sent_http_"); header != name && header != "" {
+		header = templateHeaderName(header)
+		return func(r *AccessLogRecord) string { return r.ResponseHeaders.Get(header) }, nil
+	}
+	if header := strings.TrimPrefix(name, "http_"); header != name && header != "" {
+		header = templateHeaderName(header)
+		return func(r *AccessLogRecord) string { return r.headers().Get(header) }, nil
+	}
+	return nil, fmt.Errorf("access log template: unknown variable $%s", name)
+}
+
+// templateHeaderName turns x_b3_traceid into X-B3-Traceid.
+func templateHeaderName(name string) string {
+	return http.CanonicalHeaderKey(strings.Replace(name, "_", "-", -1))
+}
+
+// getTemplateRecord memoizes makeTemplateRecord()
+func (r *AccessLogRecord) getTemplateRecord() []byte {
+	if len(r.templateRecord) == 0 {
+		r.templateRecord = r.makeTemplateRecord()
+	}
+
+	return r.templateRecord
+}
+
+func (r *AccessLogRecord) makeTemplateRecord() []byte {
+	b := new(bytes.Buffer)
+	for _, p := range r.Template.parts {
+		if p.value == nil {
+			b.WriteString(p.literal)
+			continue
+		}
+		value := p.value(r)
+		if value == "" {
+			b.WriteByte('-')
+			continue
+		}
+		writeTemplateValue(b, value)
+	}
+	b.WriteByte('\n')
+
+	return b.Bytes()
+}
+
+func writeTemplateValue(b *bytes.Buffer, value string) {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c == 0x7f || c == '"' || c == '\\' {
+			b.WriteString(`\x`)
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+			continue
+		}
+		b.WriteByte(c)
+	}
+}
@@ -0,0 +1,120 @@
+package schema_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/accesslog/schema"
+	"code.cloudfoundry.org/gorouter/route"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Template", func() {
+	var record *schema.AccessLogRecord
+
+	BeforeEach(func() {
+		record = &schema.AccessLogRecord{
+			Request: &http.Request{
+				Host:   "FakeRequestHost",
+				Method: "GET",
+				Proto:  "HTTP/1.1",
+				URL: &url.URL{
+					Path:     "/request",
+					RawQuery: "a=b",
+				},
+				Header: http.Header{
+					"User-Agent":        []string{"FakeUserAgent"},
+					"X-Forwarded-For":   []string{"FakeProxy1, FakeProxy2"},
+					"X-B3-Traceid":      []string{"trace-id"},
+					"X-Vcap-Request-Id": []string{"abc-123-xyz-pdq"},
+				},
+				RemoteAddr: "FakeRemoteAddr",
+			},
+			ResponseHeaders: http.Header{
+				"Content-Type": []string{"text/plain"},
+			},
+			BodyBytesSent:        23,
+			StatusCode:           200,
+			RequestBytesReceived: 30,
+			RouteEndpoint: route.NewEndpoint(&route.EndpointOpts{
+				AppId:                "FakeApplicationId",
+				Host:                 "1.2.3.4",
+				Port:                 1234,
+				PrivateInstanceIndex: "3",
+			}),
+			StartedAt:  time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC),
+			FinishedAt: time.Date(2000, time.January, 1, 0, 0, 1, 500000000, time.UTC),
+		}
+	})
+
+	write := func(template string) string {
+		t, err := schema.ParseTemplate(template)
+		Expect(err).ToNot(HaveOccurred())
+		r := *record
+		r.Template = t
+
+		b := new(bytes.Buffer)
+		_, err = r.WriteTo(b)
+		Expect(err).ToNot(HaveOccurred())
+		return b.String()
+	}
+
+	It("writes the values of the record", func() {
+		Expect(write(`$host [$timestamp] "$method $request_uri $protocol" $status_code $request_bytes_received $body_bytes_sent`)).To(Equal(
+			"FakeRequestHost [2000-01-01T00:00:00.000+0000] \"GET /request?a=b HTTP/1.1\" 200 30 23\n"))
+		Expect(write(`$remote_addr $backend_addr $x_forwarded_for $vcap_request_id $response_time $app_id $app_index`)).To(Equal(
+			"FakeRemoteAddr 1.2.3.4:1234 FakeProxy1, FakeProxy2 abc-123-xyz-pdq 1.5 FakeApplicationId 3\n"))
+	})
+
+	It("writes the headers of the request and the response by name", func() {
+		Expect(write(`$http_user_agent ${http_x_b3_traceid}s $sent_http_content_type`)).To(Equal(
+			"FakeUserAgent trace-ids text/plain\n"))
+	})
+
+	It("uses the headers sent to the backend when they are set", func() {
+		record.HeadersOverride = http.Header{"User-Agent": []string{"BackendUserAgent"}}
+		Expect(write(`$user_agent $http_user_agent`)).To(Equal("BackendUserAgent BackendUserAgent\n"))
+	})
+
+	It("writes - for missing values", func() {
+		record.StatusCode = 0
+		record.RouteEndpoint = nil
+		record.DisableSourceIPLogging = true
+		record.DisableXFFLogging = true
+		record.ResponseHeaders = nil
+		Expect(write(`$status_code $remote_addr $x_forwarded_for $app_id $http_referer $sent_http_location`)).To(Equal(
+			"- - - - - -\n"))
+	})
+
+	It("escapes quotes, backslashes and control characters in values", func() {
+		record.Request.Header.Set("User-Agent", "evil\" agent\\\nfoo")
+		Expect(write(`"$http_user_agent"`)).To(Equal(`"evil\x22 agent\x5C\x0Afoo"` + "\n"))
+	})
+
+	It("writes $$ as a dollar sign", func() {
+		Expect(write(`$$host costs $$5`)).To(Equal("$host costs $5\n"))
+	})
+
+	It("keeps the fixed layout for the log message sent to the app", func() {
+		t, err := schema.ParseTemplate(`$host`)
+		Expect(err).ToNot(HaveOccurred())
+		record.Template = t
+		Expect(record.LogMessage()).To(HavePrefix("FakeRequestHost - [2000-01-01T00:00:00.000+0000] "))
+	})
+
+	It("rejects unknown variables", func() {
+		_, err := schema.ParseTemplate(`$host $unknown`)
+		Expect(err).To(MatchError("access log template: unknown variable $unknown"))
+	})
+
+	It("rejects a $ without a variable name", func() {
+		_, err := schema.ParseTemplate(`$host $ $status_code`)
+		Expect(err).To(HaveOccurred())
+		_, err = schema.ParseTemplate(`${host`)
+		Expect(err).To(HaveOccurred())
+	})
+})
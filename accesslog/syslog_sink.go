@@ -0,0 +1,210 @@
+package accesslog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+)
+
+// DroppedSyslogAccessLogs counts the records dropped because the syslog
+// server could not be reached or did not keep up.
+const DroppedSyslogAccessLogs = "dropped_syslog_access_logs"
+
+const (
+	// syslogPriority is the user facility with the informational severity.
+	syslogPriority     = 14
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+	syslogMinBackoff   = 100 * time.Millisecond
+	syslogMaxBackoff   = 30 * time.Second
+)
+
+// SyslogSink sends access log records to a syslog server as RFC 5424
+// messages over TCP or TLS, framed by octet counting (RFC 6587). Records are
+// queued and sent by a goroutine that reconnects with a growing backoff when
+// the connection fails, so that a slow or unreachable server never holds up
+// the other writers.
+type SyslogSink struct {
+	address   string
+	tlsConfig *tls.Config
+	hostname  string
+	appName   string
+	procID    string
+	logger    logger.Logger
+
+	queue  chan []byte
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func NewSyslogSink(cfg config.AccessLogSyslogConfig, logger logger.Logger) (*SyslogSink, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &SyslogSink{
+		address:  cfg.Address,
+		hostname: hostname,
+		appName:  cfg.AppName,
+		procID:   strconv.Itoa(os.Getpid()),
+		logger:   logger,
+		queue:    make(chan []byte, cfg.BufferSize),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+
+	if cfg.TLS {
+		host, _, _ := net.SplitHostPort(cfg.Address)
+		s.tlsConfig = &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: cfg.SkipSSLValidation,
+		}
+		if cfg.CACerts != "" {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM([]byte(cfg.CACerts)) {
+				return nil, errors.New("invalid access log syslog ca_certs")
+			}
+			s.tlsConfig.RootCAs = pool
+		}
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Write queues the record, without its trailing newline, as a syslog
+// message. The record is dropped when the queue is full.
+func (s *SyslogSink) Write(p []byte) (int, error) {
+	msg := s.message(bytes.TrimRight(p, "\n"))
+	select {
+	case s.queue <- msg:
+	default:
+		metrics.IncrementCounter(DroppedSyslogAccessLogs)
+	}
+	return len(p), nil
+}
+
+// Close sends the queued messages if the server is connected, and closes the
+// connection.
+func (s *SyslogSink) Close() error {
+	close(s.stopCh)
+	<-s.doneCh
+	return nil
+}
+
+// message frames the record as an RFC 5424 message, prefixed by its length.
+func (s *SyslogSink) message(record []byte) []byte {
+	header := fmt.Sprintf("<%d>1 %s %s %s %s - - ",
+		syslogPriority,
+		time.Now().UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname,
+		s.appName,
+		s.procID,
+	)
+
+	msg := make([]byte, 0, len(header)+len(record)+8)
+	msg = strconv.AppendInt(msg, int64(len(header)+len(record)), 10)
+	msg = append(msg, ' ')
+	msg = append(msg, header...)
+	return append(msg, record...)
+}
+
+func (s *SyslogSink) run() {
+	defer close(s.doneCh)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	backoff := syslogMinBackoff
+	for {
+		select {
+		case msg := <-s.queue:
+			// the message is retried on a new connection until it is sent or
+			// the sink is closed
+			for {
+				if conn == nil {
+					var err error
+					conn, err = s.dial()
+					if err != nil {
+						s.logger.Error("error-connecting-to-syslog", zap.String("address", s.address), zap.Error(err))
+						select {
+						case <-time.After(backoff):
+						case <-s.stopCh:
+							return
+						}
+						if backoff *= 2; backoff > syslogMaxBackoff {
+							backoff = syslogMaxBackoff
+						}
+						continue
+					}
+					backoff = syslogMinBackoff
+				}
+
+				if err := s.send(conn, msg); err != nil {
+					s.logger.Error("error-writing-to-syslog", zap.String("address", s.address), zap.Error(err))
+					conn.Close()
+					conn = nil
+					continue
+				}
+				break
+			}
+		case <-s.stopCh:
+			if conn != nil {
+				s.drain(conn)
+			}
+			return
+		}
+	}
+}
+
+// drain sends the queued messages until the queue is empty or a write fails.
+func (s *SyslogSink) drain(conn net.Conn) {
+	for {
+		select {
+		case msg := <-s.queue:
+			if err := s.send(conn, msg); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if s.tlsConfig != nil {
+		conn, err := tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+	return dialer.Dial("tcp", s.address)
+}
+
+func (s *SyslogSink) send(conn net.Conn, msg []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	_, err := conn.Write(msg)
+	return err
+}
@@ -0,0 +1,164 @@
+package accesslog_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/accesslog"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyslogSink", func() {
+	var (
+		logger   logger.Logger
+		cfg      config.AccessLogSyslogConfig
+		listener net.Listener
+		messages chan string
+	)
+
+	// serve accepts connections and sends the messages received on them,
+	// framed by octet counting, to the messages channel.
+	serve := func(ln net.Listener) {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					length, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, err := strconv.Atoi(strings.TrimSpace(length))
+					if err != nil {
+						return
+					}
+					msg := make([]byte, n)
+					if _, err := io.ReadFull(r, msg); err != nil {
+						return
+					}
+					messages <- string(msg)
+				}
+			}()
+		}
+	}
+
+	BeforeEach(func() {
+		logger = test_util.NewTestZapLogger("test")
+		messages = make(chan string, 10)
+
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg = config.AccessLogSyslogConfig{
+			Address:    listener.Addr().String(),
+			AppName:    "gorouter",
+			BufferSize: 10,
+		}
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("sends each record as an RFC 5424 message", func() {
+		go serve(listener)
+		sink, err := accesslog.NewSyslogSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		n, err := sink.Write([]byte("foo.bar - [record]\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(19))
+
+		var msg string
+		Eventually(messages).Should(Receive(&msg))
+		Expect(msg).To(MatchRegexp(`^<14>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}Z \S+ gorouter \d+ - - foo\.bar - \[record\]$`))
+	})
+
+	It("reconnects and sends the records queued while the server was unreachable", func() {
+		listener.Close()
+		sink, err := accesslog.NewSyslogSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		sink.Write([]byte("first\n"))
+		sink.Write([]byte("second\n"))
+		time.Sleep(200 * time.Millisecond)
+
+		listener, err = net.Listen("tcp", cfg.Address)
+		Expect(err).ToNot(HaveOccurred())
+		go serve(listener)
+
+		var msg string
+		Eventually(messages, 5*time.Second).Should(Receive(&msg))
+		Expect(msg).To(HaveSuffix(" first"))
+		Eventually(messages).Should(Receive(&msg))
+		Expect(msg).To(HaveSuffix(" second"))
+	})
+
+	It("drops records without blocking when the queue is full", func() {
+		listener.Close()
+		cfg.BufferSize = 1
+		sink, err := accesslog.NewSyslogSink(cfg, logger)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				sink.Write([]byte("record\n"))
+			}
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+
+	Context("over TLS", func() {
+		var certChain test_util.CertChain
+
+		BeforeEach(func() {
+			certChain = test_util.CreateSignedCertWithRootCA(test_util.CertNames{
+				CommonName: "127.0.0.1",
+				SANs:       test_util.SubjectAltNames{IP: "127.0.0.1"},
+			})
+			listener = tls.NewListener(listener, certChain.AsTLSConfig())
+
+			cfg.TLS = true
+			cfg.CACerts = string(certChain.CACertPEM)
+		})
+
+		It("sends the records to a server with a trusted certificate", func() {
+			go serve(listener)
+			sink, err := accesslog.NewSyslogSink(cfg, logger)
+			Expect(err).ToNot(HaveOccurred())
+			defer sink.Close()
+
+			sink.Write([]byte("secure\n"))
+
+			var msg string
+			Eventually(messages).Should(Receive(&msg))
+			Expect(msg).To(HaveSuffix(" secure"))
+		})
+
+		It("rejects invalid CA certificates", func() {
+			cfg.CACerts = "not a certificate"
+			_, err := accesslog.NewSyslogSink(cfg, logger)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
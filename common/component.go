@@ -89,6 +89,7 @@ type VcapComponent struct {
 	Healthz    *health.Healthz `json:"-"`
 	Health     http.Handler
 	InfoRoutes map[string]json.Marshaler `json:"-"`
+	Handlers   map[string]http.Handler   `json:"-"`
 	Logger     logger.Logger             `json:"-"`
 
 	listener net.Listener
@@ -212,7 +213,7 @@ func (c *VcapComponent) ListenAndServe() {
 
 	hs.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Connection", "close")
-		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Type", c.Healthz.ContentType())
 		w.WriteHeader(http.StatusOK)
 
 		fmt.Fprintf(w, c.Healthz.Value())
@@ -240,6 +241,10 @@ func (c *VcapComponent) ListenAndServe() {
 		})
 	}
 
+	for path, handler := range c.Handlers {
+		hs.Handle(path, handler)
+	}
+
 	f := func(user, password string) bool {
 		return user == c.Varz.Credentials[0] && password == c.Varz.Credentials[1]
 	}
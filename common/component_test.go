@@ -16,6 +16,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"time"
 )
 
@@ -116,6 +117,68 @@ var _ = Describe("Component", func() {
 		Expect(body).To(Equal(`{"key":"value"}` + "\n"))
 	})
 
+	It("serves handlers behind basic auth", func() {
+		path := "/test"
+
+		component.Handlers = map[string]http.Handler{
+			path: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Write([]byte("handled"))
+			}),
+		}
+		serveComponent(component)
+
+		req := buildGetRequest(component, path)
+		code, _, _ := doGetRequest(req)
+		Expect(code).To(Equal(401))
+
+		req = buildGetRequest(component, path)
+		req.SetBasicAuth("username", "password")
+
+		code, header, body := doGetRequest(req)
+		Expect(code).To(Equal(200))
+		Expect(header.Get("Content-Type")).To(Equal("text/plain"))
+		Expect(body).To(Equal("handled"))
+	})
+
+	It("serves the pprof profiles behind basic auth", func() {
+		component.Handlers = PprofHandlers()
+		serveComponent(component)
+
+		req := buildGetRequest(component, "/debug/pprof/goroutine?debug=1")
+		code, _, _ := doGetRequest(req)
+		Expect(code).To(Equal(401))
+
+		req = buildGetRequest(component, "/debug/pprof/goroutine?debug=1")
+		req.SetBasicAuth("username", "password")
+
+		code, _, body := doGetRequest(req)
+		Expect(code).To(Equal(200))
+		Expect(body).To(ContainSubstring("goroutine profile:"))
+
+		req = buildGetRequest(component, "/debug/pprof/heap")
+		req.SetBasicAuth("username", "password")
+
+		code, header, _ := doGetRequest(req)
+		Expect(code).To(Equal(200))
+		Expect(header.Get("Content-Type")).To(Equal("application/octet-stream"))
+	})
+
+	It("serves CPU profiles that take longer than the write timeout", func() {
+		server := httptest.NewUnstartedServer(PprofHandlers()["/debug/pprof/profile"])
+		server.Config.WriteTimeout = 500 * time.Millisecond
+		server.Start()
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/debug/pprof/profile?seconds=1")
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).ToNot(BeEmpty())
+	})
+
 	It("updates the uptime statistic", func() {
 		stringMap := make(map[string]interface{})
 		path := "/varz"
@@ -1,8 +1,40 @@
 package health
 
+import "encoding/json"
+
 type Healthz struct {
+	// NATSConnected is optional. When set, the health is reported as JSON
+	// with a nats_connected field, and a status of "degraded" while NATS is
+	// not connected.
+	NATSConnected func() bool
 }
 
 func (v *Healthz) Value() string {
-	return "ok"
+	if v == nil || v.NATSConnected == nil {
+		return "ok"
+	}
+
+	body := struct {
+		Status        string `json:"status"`
+		NATSConnected bool   `json:"nats_connected"`
+	}{
+		Status:        "ok",
+		NATSConnected: v.NATSConnected(),
+	}
+	if !body.NATSConnected {
+		body.Status = "degraded"
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "ok"
+	}
+	return string(b)
+}
+
+func (v *Healthz) ContentType() string {
+	if v == nil || v.NATSConnected == nil {
+		return "text/plain"
+	}
+	return "application/json"
 }
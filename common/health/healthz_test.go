@@ -11,5 +11,30 @@ var _ = Describe("Healthz", func() {
 		healthz := &health.Healthz{}
 		ok := healthz.Value()
 		Expect(ok).To(Equal("ok"))
+		Expect(healthz.ContentType()).To(Equal("text/plain"))
+	})
+
+	Context("when NATS health is reported", func() {
+		var (
+			healthz   *health.Healthz
+			connected bool
+		)
+
+		BeforeEach(func() {
+			connected = true
+			healthz = &health.Healthz{
+				NATSConnected: func() bool { return connected },
+			}
+		})
+
+		It("reports that NATS is connected", func() {
+			Expect(healthz.Value()).To(MatchJSON(`{"status": "ok", "nats_connected": true}`))
+			Expect(healthz.ContentType()).To(Equal("application/json"))
+		})
+
+		It("reports a degraded state when NATS is disconnected", func() {
+			connected = false
+			Expect(healthz.Value()).To(MatchJSON(`{"status": "degraded", "nats_connected": false}`))
+		})
 	})
 })
@@ -10,6 +10,7 @@ const (
 	CfInstanceIdHeader    = "X-CF-InstanceID"
 	CfAppInstance         = "X-CF-APP-INSTANCE"
 	CfRouterError         = "X-Cf-RouterError"
+	CfMirroredFrom        = "X-Cf-Mirrored-From"
 )
 
 func SetTraceHeaders(responseWriter http.ResponseWriter, routerIp, addr string) {
@@ -0,0 +1,78 @@
+package jwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+
+	. "github.com/onsi/gomega"
+)
+
+// sign creates a token with the given header and claims, signed with key.
+func sign(alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	Expect(err).ToNot(HaveOccurred())
+	payload, err := json.Marshal(claims)
+	Expect(err).ToNot(HaveOccurred())
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	default:
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		Expect(err).ToNot(HaveOccurred())
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		Expect(err).ToNot(HaveOccurred())
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   encodeInt(key.N),
+		"e":   encodeInt(big.NewInt(int64(key.E))),
+	}
+}
+
+func ecJWK(kid, crv string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": crv,
+		"x":   encodeInt(key.X),
+		"y":   encodeInt(key.Y),
+	}
+}
+
+func keySet(keys ...map[string]string) []byte {
+	b, err := json.Marshal(map[string]interface{}{"keys": keys})
+	Expect(err).ToNot(HaveOccurred())
+	return b
+}
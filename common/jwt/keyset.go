@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	maxKeySetSize = 1024 * 1024
+
+	// minRefetchInterval limits how often tokens signed with unknown keys
+	// make the key set be fetched again.
+	minRefetchInterval = 10 * time.Second
+)
+
+// ErrKeySetUnavailable is returned when the keys have never been fetched
+// successfully.
+var ErrKeySetUnavailable = errors.New("jwt: key set unavailable")
+
+// KeySet is a JSON Web Key Set fetched from a URL. The keys are cached and
+// fetched again every refresh interval, or when a token is signed with a key
+// the set does not have. A failed fetch keeps the keys fetched before.
+type KeySet struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	// fetched holds the *fetchedKeys of the last fetch. It is replaced
+	// rather than modified, so that keys are looked up without waiting for
+	// a fetch.
+	fetched atomic.Value
+	// fetches makes callers that need the keys fetched at the same time
+	// share one fetch.
+	fetches singleflight.Group
+}
+
+type fetchedKeys struct {
+	// keys is nil until a fetch succeeds
+	keys map[string]crypto.PublicKey
+	at   time.Time
+}
+
+func NewKeySet(url string, client *http.Client, refreshInterval time.Duration) *KeySet {
+	k := &KeySet{
+		url:             url,
+		client:          client,
+		refreshInterval: refreshInterval,
+	}
+	k.fetched.Store(&fetchedKeys{})
+	return k
+}
+
+// Key returns the key with the given ID. A token without a key ID is
+// verified with the only key of a set that has one. Keys that are due to be
+// fetched again are still returned while they are, and only callers that
+// have no key to use wait for the fetch.
+func (k *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	now := time.Now()
+	fetched := k.fetched.Load().(*fetchedKeys)
+	age := now.Sub(fetched.at)
+	switch {
+	case fetched.keys == nil && (age >= k.refreshInterval || age >= minRefetchInterval):
+		fetched = k.refresh()
+	case age >= k.refreshInterval:
+		// the channel is buffered, so nothing waits for the result
+		k.fetches.DoChan("", k.fetch)
+	}
+	if fetched.keys == nil {
+		return nil, ErrKeySetUnavailable
+	}
+
+	key, ok := fetched.lookup(kid)
+	if !ok && now.Sub(fetched.at) >= minRefetchInterval {
+		fetched = k.refresh()
+		key, ok = fetched.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("jwt: unknown key %q", kid)
+	}
+	return key, nil
+}
+
+func (f *fetchedKeys) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(f.keys) == 1 {
+		for _, key := range f.keys {
+			return key, true
+		}
+	}
+	key, ok := f.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the keys, or waits for the fetch in progress, and returns
+// them.
+func (k *KeySet) refresh() *fetchedKeys {
+	fetched, _, _ := k.fetches.Do("", k.fetch)
+	return fetched.(*fetchedKeys)
+}
+
+// fetch fetches the keys, and keeps the previous ones if that fails. The
+// fetch time is updated either way so that an unavailable endpoint is not
+// asked on every request.
+func (k *KeySet) fetch() (interface{}, error) {
+	fetched := &fetchedKeys{
+		keys: k.fetched.Load().(*fetchedKeys).keys,
+		at:   time.Now(),
+	}
+	if keys, err := k.get(); err == nil {
+		fetched.keys = keys
+	}
+	k.fetched.Store(fetched)
+	return fetched, nil
+}
+
+func (k *KeySet) get() (map[string]crypto.PublicKey, error) {
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwt: key set endpoint returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseKeySet(body)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseKeySet parses the RSA and EC signing keys of a JSON Web Key Set.
+// Keys of other types, and encryption keys, are skipped.
+func parseKeySet(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwt: invalid key set: %s", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwt: invalid key %q: %s", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeInt(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeInt(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (jwk jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	x, err := decodeInt(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeInt(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
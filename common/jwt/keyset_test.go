@@ -0,0 +1,169 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/jwt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KeySet", func() {
+	var (
+		rsaKey  *rsa.PrivateKey
+		ecKey   *ecdsa.PrivateKey
+		body    []byte
+		status  int32
+		fetches int32
+		hold    int32
+		release chan struct{}
+		server  *httptest.Server
+	)
+
+	BeforeEach(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+
+		body = keySet(
+			rsaJWK("rsa-1", &rsaKey.PublicKey),
+			ecJWK("ec-1", "P-256", &ecKey.PublicKey),
+			map[string]string{"kty": "oct", "kid": "hmac-1", "k": "c2VjcmV0"},
+		)
+		atomic.StoreInt32(&status, http.StatusOK)
+		atomic.StoreInt32(&fetches, 0)
+		atomic.StoreInt32(&hold, 0)
+		release = make(chan struct{})
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&fetches, 1)
+			if atomic.LoadInt32(&hold) == 1 {
+				<-release
+			}
+			w.WriteHeader(int(atomic.LoadInt32(&status)))
+			w.Write(body)
+		}))
+	})
+
+	AfterEach(func() {
+		if atomic.LoadInt32(&hold) == 1 {
+			close(release)
+		}
+		server.Close()
+	})
+
+	It("returns the RSA and EC keys of the set", func() {
+		keys := jwt.NewKeySet(server.URL, http.DefaultClient, time.Hour)
+
+		key, err := keys.Key("rsa-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key).To(Equal(&rsaKey.PublicKey))
+
+		key, err = keys.Key("ec-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key.(*ecdsa.PublicKey).Equal(&ecKey.PublicKey)).To(BeTrue())
+
+		_, err = keys.Key("hmac-1")
+		Expect(err).To(MatchError(`jwt: unknown key "hmac-1"`))
+	})
+
+	It("caches the keys until the refresh interval passes", func() {
+		keys := jwt.NewKeySet(server.URL, http.DefaultClient, 100*time.Millisecond)
+
+		_, err := keys.Key("rsa-1")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = keys.Key("ec-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(atomic.LoadInt32(&fetches)).To(Equal(int32(1)))
+
+		Eventually(func() int32 {
+			keys.Key("rsa-1")
+			return atomic.LoadInt32(&fetches)
+		}).Should(Equal(int32(2)))
+	})
+
+	It("returns the cached keys while it fetches them again", func() {
+		keys := jwt.NewKeySet(server.URL, http.DefaultClient, 100*time.Millisecond)
+		_, err := keys.Key("rsa-1")
+		Expect(err).ToNot(HaveOccurred())
+
+		atomic.StoreInt32(&hold, 1)
+		time.Sleep(100 * time.Millisecond)
+		for i := 0; i < 10; i++ {
+			key, err := keys.Key("rsa-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).To(Equal(&rsaKey.PublicKey))
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&fetches) }).Should(Equal(int32(2)))
+		Consistently(func() int32 { return atomic.LoadInt32(&fetches) }, 100*time.Millisecond).Should(Equal(int32(2)))
+	})
+
+	It("fetches the keys once for callers that need them at the same time", func() {
+		keys := jwt.NewKeySet(server.URL, http.DefaultClient, time.Hour)
+		atomic.StoreInt32(&hold, 1)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				_, err := keys.Key("rsa-1")
+				Expect(err).ToNot(HaveOccurred())
+			}()
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&fetches) }).Should(Equal(int32(1)))
+
+		atomic.StoreInt32(&hold, 0)
+		close(release)
+		wg.Wait()
+		Expect(atomic.LoadInt32(&fetches)).To(Equal(int32(1)))
+	})
+
+	It("keeps the keys when fetching them again fails", func() {
+		keys := jwt.NewKeySet(server.URL, http.DefaultClient, 100*time.Millisecond)
+		_, err := keys.Key("rsa-1")
+		Expect(err).ToNot(HaveOccurred())
+
+		atomic.StoreInt32(&status, http.StatusInternalServerError)
+		Eventually(func() int32 {
+			_, err := keys.Key("rsa-1")
+			Expect(err).ToNot(HaveOccurred())
+			return atomic.LoadInt32(&fetches)
+		}).Should(BeNumerically(">", 1))
+	})
+
+	It("uses the only key of the set for tokens without a key ID", func() {
+		body = keySet(rsaJWK("rsa-1", &rsaKey.PublicKey))
+		keys := jwt.NewKeySet(server.URL, http.DefaultClient, time.Hour)
+
+		key, err := keys.Key("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key).To(Equal(&rsaKey.PublicKey))
+	})
+
+	It("is unavailable when the keys were never fetched", func() {
+		atomic.StoreInt32(&status, http.StatusInternalServerError)
+		keys := jwt.NewKeySet(server.URL, http.DefaultClient, time.Hour)
+
+		_, err := keys.Key("rsa-1")
+		Expect(err).To(Equal(jwt.ErrKeySetUnavailable))
+	})
+
+	It("is unavailable when the set has an invalid key", func() {
+		body = keySet(map[string]string{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": "AQ", "y": "AQ"})
+		keys := jwt.NewKeySet(server.URL, http.DefaultClient, time.Hour)
+
+		_, err := keys.Key("ec-1")
+		Expect(err).To(Equal(jwt.ErrKeySetUnavailable))
+	})
+})
@@ -0,0 +1,206 @@
+// Package jwt validates JSON Web Tokens signed with RSA or ECDSA keys
+// published as a JSON Web Key Set.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Keys looks up the keys that tokens are signed with.
+type Keys interface {
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// Validator validates tokens of one issuer.
+type Validator struct {
+	keys      Keys
+	issuer    string
+	clockSkew time.Duration
+}
+
+func NewValidator(keys Keys, issuer string, clockSkew time.Duration) *Validator {
+	return &Validator{
+		keys:      keys,
+		issuer:    issuer,
+		clockSkew: clockSkew,
+	}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Claims are the claims of a validated token.
+type Claims map[string]interface{}
+
+// Validate checks the signature of token, that it was issued by the issuer
+// of the validator for one of audiences, and that it is valid now. No
+// audience is checked when audiences is empty. It returns the claims of the
+// token, or ErrKeySetUnavailable if the keys could not be fetched.
+func (v *Validator) Validate(token string, audiences []string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("malformed token")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, invalid("malformed header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed signature")
+	}
+
+	key, err := v.keys.Key(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(h.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalid("malformed claims")
+	}
+	if err := v.validateClaims(claims, audiences); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Validator) validateClaims(claims Claims, audiences []string) error {
+	if iss, _ := claims["iss"].(string); iss != v.issuer {
+		return invalid("unexpected issuer")
+	}
+
+	now := time.Now()
+	exp, ok := claims.time("exp")
+	if !ok {
+		return invalid("missing expiration time")
+	}
+	if !now.Before(exp.Add(v.clockSkew)) {
+		return invalid("token is expired")
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(v.clockSkew).Before(nbf) {
+		return invalid("token is not valid yet")
+	}
+
+	if len(audiences) > 0 && !claims.hasAudience(audiences) {
+		return invalid("unexpected audience")
+	}
+	return nil
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	n, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(n), 0), true
+}
+
+func (c Claims) hasAudience(audiences []string) bool {
+	var tokenAudiences []string
+	switch aud := c["aud"].(type) {
+	case string:
+		tokenAudiences = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				tokenAudiences = append(tokenAudiences, s)
+			}
+		}
+	}
+
+	for _, want := range audiences {
+		for _, got := range tokenAudiences {
+			if want == got {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var hashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+func verify(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	hash, ok := hashes[alg]
+	if !ok {
+		return invalid(fmt.Sprintf("unsupported algorithm %q", alg))
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return invalid("algorithm does not match key")
+		}
+		if rsa.VerifyPKCS1v15(k, hash, digest, signature) != nil {
+			return invalid("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || hash.Size()*8 != ecdsaHashBits(size) {
+			return invalid("algorithm does not match key")
+		}
+		if len(signature) != 2*size {
+			return invalid("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return invalid("invalid signature")
+		}
+	default:
+		return invalid("unsupported key")
+	}
+	return nil
+}
+
+// ecdsaHashBits returns the size of the hash used with a curve whose
+// coordinates are size bytes long, which pairs P-256 with SHA-256, P-384
+// with SHA-384 and P-521 with SHA-512.
+func ecdsaHashBits(size int) int {
+	switch size {
+	case 32:
+		return 256
+	case 48:
+		return 384
+	default:
+		return 512
+	}
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func invalid(reason string) error {
+	return errors.New("jwt: invalid token: " + reason)
+}
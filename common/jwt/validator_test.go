@@ -0,0 +1,143 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/jwt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validator", func() {
+	const issuer = "https://uaa.example.com/oauth/token"
+
+	var (
+		rsaKey    *rsa.PrivateKey
+		ecKey     *ecdsa.PrivateKey
+		server    *httptest.Server
+		validator *jwt.Validator
+		claims    map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		ecKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+
+		body := keySet(rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", "P-384", &ecKey.PublicKey))
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(body)
+		}))
+		validator = jwt.NewValidator(jwt.NewKeySet(server.URL, http.DefaultClient, time.Hour), issuer, time.Minute)
+
+		claims = map[string]interface{}{
+			"iss": issuer,
+			"sub": "billing-client",
+			"aud": []string{"billing", "reports"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("accepts a token signed with an RSA key", func() {
+		got, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), []string{"billing"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got["sub"]).To(Equal("billing-client"))
+	})
+
+	It("accepts a token signed with an EC key", func() {
+		_, err := validator.Validate(sign("ES384", "ec-1", ecKey, claims), []string{"reports"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("accepts a token with a single audience", func() {
+		claims["aud"] = "billing"
+		_, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), []string{"other", "billing"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("does not check the audience when none is expected", func() {
+		delete(claims, "aud")
+		_, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects a token for another audience", func() {
+		_, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), []string{"other"})
+		Expect(err).To(MatchError("jwt: invalid token: unexpected audience"))
+	})
+
+	It("rejects a token of another issuer", func() {
+		claims["iss"] = "https://evil.example.com"
+		_, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), nil)
+		Expect(err).To(MatchError("jwt: invalid token: unexpected issuer"))
+	})
+
+	It("rejects an expired token", func() {
+		claims["exp"] = time.Now().Add(-2 * time.Minute).Unix()
+		_, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), nil)
+		Expect(err).To(MatchError("jwt: invalid token: token is expired"))
+	})
+
+	It("allows for the clock skew", func() {
+		claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+		claims["nbf"] = time.Now().Add(30 * time.Second).Unix()
+		_, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects a token without an expiration time", func() {
+		delete(claims, "exp")
+		_, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), nil)
+		Expect(err).To(MatchError("jwt: invalid token: missing expiration time"))
+	})
+
+	It("rejects a token that is not valid yet", func() {
+		claims["nbf"] = time.Now().Add(5 * time.Minute).Unix()
+		_, err := validator.Validate(sign("RS256", "rsa-1", rsaKey, claims), nil)
+		Expect(err).To(MatchError("jwt: invalid token: token is not valid yet"))
+	})
+
+	It("rejects a token whose claims were changed", func() {
+		token := sign("RS256", "rsa-1", rsaKey, claims)
+		claims["sub"] = "admin"
+		forged := strings.Split(sign("RS256", "rsa-1", rsaKey, claims), ".")[1]
+		parts := strings.Split(token, ".")
+
+		_, err := validator.Validate(parts[0]+"."+forged+"."+parts[2], nil)
+		Expect(err).To(MatchError("jwt: invalid token: invalid signature"))
+	})
+
+	It("rejects a token signed with an algorithm that does not match the key", func() {
+		_, err := validator.Validate(sign("ES384", "rsa-1", ecKey, claims), nil)
+		Expect(err).To(MatchError("jwt: invalid token: algorithm does not match key"))
+	})
+
+	It("rejects unsigned tokens", func() {
+		parts := strings.Split(sign("RS256", "rsa-1", rsaKey, claims), ".")
+		_, err := validator.Validate("eyJhbGciOiJub25lIiwia2lkIjoicnNhLTEifQ."+parts[1]+".", nil)
+		Expect(err).To(MatchError(`jwt: invalid token: unsupported algorithm "none"`))
+	})
+
+	It("rejects a token signed with an unknown key", func() {
+		_, err := validator.Validate(sign("RS256", "rsa-2", rsaKey, claims), nil)
+		Expect(err).To(MatchError(`jwt: unknown key "rsa-2"`))
+	})
+
+	It("rejects malformed tokens", func() {
+		_, err := validator.Validate("not-a-token", nil)
+		Expect(err).To(MatchError("jwt: invalid token: malformed token"))
+	})
+})
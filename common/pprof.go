@@ -0,0 +1,48 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+)
+
+// profileWriteMargin is the time a CPU profile or trace has to be written
+// once it has been collected.
+const profileWriteMargin = 10 * time.Second
+
+// PprofHandlers returns the handlers of net/http/pprof by their paths under
+// /debug/pprof/, such as /debug/pprof/heap, /debug/pprof/goroutine and
+// /debug/pprof/profile, for the status listener to serve behind its basic
+// auth.
+func PprofHandlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/debug/pprof/":        http.HandlerFunc(pprof.Index),
+		"/debug/pprof/cmdline": http.HandlerFunc(pprof.Cmdline),
+		"/debug/pprof/profile": withProfileDeadline(pprof.Profile, 30),
+		"/debug/pprof/symbol":  http.HandlerFunc(pprof.Symbol),
+		"/debug/pprof/trace":   withProfileDeadline(pprof.Trace, 1),
+	}
+}
+
+// withProfileDeadline extends the write deadline of the status listener for
+// a CPU profile or trace, which is collected for the number of seconds of the
+// request, or defaultSeconds, before it is written.
+func withProfileDeadline(handler http.HandlerFunc, defaultSeconds int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seconds, err := strconv.ParseInt(r.FormValue("seconds"), 10, 64)
+		if err != nil || seconds <= 0 {
+			seconds = defaultSeconds
+		}
+		deadline := time.Now().Add(time.Duration(seconds)*time.Second + profileWriteMargin)
+		if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// pprof refuses durations longer than the write timeout of the server
+		// it finds in the context, which no longer applies
+		handler(w, r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, nil)))
+	})
+}
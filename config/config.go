@@ -4,9 +4,11 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"net"
 	"net/url"
 
 	"io/ioutil"
+	"reflect"
 	"runtime"
 	"strings"
 	"time"
@@ -18,23 +20,62 @@ import (
 const (
 	LOAD_BALANCE_RR           string = "round-robin"
 	LOAD_BALANCE_LC           string = "least-connection"
+	LOAD_BALANCE_LL           string = "least-latency"
+	LOAD_BALANCE_CH           string = "consistent-hash"
+	HEALTH_CHECK_HTTP         string = "http"
+	HEALTH_CHECK_TCP          string = "tcp"
 	SHARD_ALL                 string = "all"
 	SHARD_SEGMENTS            string = "segments"
 	SHARD_SHARED_AND_SEGMENTS string = "shared-and-segments"
 	ALWAYS_FORWARD            string = "always_forward"
 	SANITIZE_SET              string = "sanitize_set"
 	FORWARD                   string = "forward"
+	GET_BODY_FORWARD          string = "forward"
+	GET_BODY_DROP             string = "drop"
+	GET_BODY_REJECT           string = "reject"
+	HTTP10_REJECT             string = "reject"
+	HTTP10_ROUTE              string = "route"
 )
 
-var LoadBalancingStrategies = []string{LOAD_BALANCE_RR, LOAD_BALANCE_LC}
+var LoadBalancingStrategies = []string{LOAD_BALANCE_RR, LOAD_BALANCE_LC, LOAD_BALANCE_LL, LOAD_BALANCE_CH}
 var AllowedShardingModes = []string{SHARD_ALL, SHARD_SEGMENTS, SHARD_SHARED_AND_SEGMENTS}
 var AllowedForwardedClientCertModes = []string{ALWAYS_FORWARD, FORWARD, SANITIZE_SET}
+var AllowedGetRequestBodyPolicies = []string{GET_BODY_FORWARD, GET_BODY_DROP, GET_BODY_REJECT}
+var AllowedHTTP10Policies = []string{HTTP10_REJECT, HTTP10_ROUTE}
 
 type StatusConfig struct {
 	Host string `yaml:"host"`
 	Port uint16 `yaml:"port"`
 	User string `yaml:"user"`
 	Pass string `yaml:"pass"`
+
+	// EnableBulkRoutes serves POST /routes/bulk, which registers many routes
+	// at once, on the status listener.
+	EnableBulkRoutes bool `yaml:"enable_bulk_routes"`
+	// EnableRouteRegistration serves POST /routes/register and
+	// /routes/unregister, which take the messages of router.register and
+	// router.unregister, on the status listener.
+	EnableRouteRegistration bool `yaml:"enable_route_registration"`
+	// EnableDrain serves POST /drain, which drains and stops the router like
+	// SIGUSR1, and GET /drain, which reports the progress of the drain, on
+	// the status listener.
+	EnableDrain bool `yaml:"enable_drain"`
+	// EnableLameDuck serves PUT /lame_duck, which fails the health check
+	// while the router keeps serving requests and refreshing its routes,
+	// DELETE /lame_duck, which passes it again, and GET /lame_duck on the
+	// status listener.
+	EnableLameDuck bool `yaml:"enable_lame_duck"`
+	// EnableLogLevel serves PUT /log-level, which sets the log level to the
+	// level in the request body, on the status listener.
+	EnableLogLevel bool `yaml:"enable_log_level"`
+	// EnablePprof serves the profiles of net/http/pprof under /debug/pprof/
+	// on the status listener.
+	EnablePprof bool `yaml:"enable_pprof"`
+	// RegistrationGRPCPort serves the gorouter.RouteRegistration gRPC
+	// service on the status host, with the credentials of the status
+	// listener. Zero does not serve it. The service is only built with the
+	// grpc tag.
+	RegistrationGRPCPort uint16 `yaml:"registration_grpc_port"`
 }
 
 var defaultStatusConfig = StatusConfig{
@@ -44,6 +85,46 @@ var defaultStatusConfig = StatusConfig{
 	Pass: "",
 }
 
+// PrometheusConfig enables a Prometheus endpoint for the router's metrics.
+// When Port is zero the endpoint is served on the status listener, behind
+// its basic auth.
+type PrometheusConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    uint16 `yaml:"port"`
+	Path    string `yaml:"path"`
+}
+
+var defaultPrometheusConfig = PrometheusConfig{
+	Path: "/metrics",
+}
+
+// The formats of the tags of StatsD metrics.
+const (
+	StatsDTagFormatNone      = "none"
+	StatsDTagFormatDogStatsD = "dogstatsd"
+	StatsDTagFormatInfluxDB  = "influxdb"
+)
+
+// StatsDConfig sends the router's metrics to a StatsD server, alongside
+// dropsonde. Address is a host:port to send to over UDP, or a unix:// URL of
+// a datagram socket. Metrics are sent every FlushInterval, with the Tags in
+// TagFormat, which is either none, dogstatsd, or influxdb as Telegraf reads.
+type StatsDConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Address       string            `yaml:"address"`
+	Prefix        string            `yaml:"prefix"`
+	TagFormat     string            `yaml:"tag_format"`
+	Tags          map[string]string `yaml:"tags"`
+	FlushInterval time.Duration     `yaml:"flush_interval"`
+}
+
+var defaultStatsDConfig = StatsDConfig{
+	Address:       "127.0.0.1:8125",
+	Prefix:        "gorouter.",
+	TagFormat:     StatsDTagFormatNone,
+	FlushInterval: time.Second,
+}
+
 type NatsConfig struct {
 	Host string `yaml:"host"`
 	Port uint16 `yaml:"port"`
@@ -55,6 +136,15 @@ type RoutingApiConfig struct {
 	Uri          string `yaml:"uri"`
 	Port         int    `yaml:"port"`
 	AuthDisabled bool   `yaml:"auth_disabled"`
+
+	// FetchJitter is the fraction of the interval between fetches of the
+	// routing table by which each fetch is randomly brought forward or
+	// delayed, so that a fleet of gorouters does not fetch it all at once.
+	FetchJitter float64 `yaml:"fetch_jitter"`
+}
+
+var defaultRoutingApiConfig = RoutingApiConfig{
+	FetchJitter: 0.2,
 }
 
 var defaultNatsConfig = NatsConfig{
@@ -64,6 +154,29 @@ var defaultNatsConfig = NatsConfig{
 	Pass: "",
 }
 
+// NatsJetStreamConfig configures receiving route registrations from the
+// JetStream stream Stream, which captures router.register and
+// router.unregister, through the durable consumer Durable instead of a plain
+// subscription. Registrations published while gorouter is disconnected from
+// NATS are then delivered when it reconnects. Each gorouter needs a Durable
+// of its own, since a consumer delivers every message once.
+type NatsJetStreamConfig struct {
+	Stream  string        `yaml:"stream"`
+	Durable string        `yaml:"durable"`
+	AckWait time.Duration `yaml:"ack_wait"`
+}
+
+var defaultNatsJetStreamConfig = NatsJetStreamConfig{
+	AckWait: 30 * time.Second,
+}
+
+const (
+	OAuthFailureModeFailClosed = "fail_closed"
+	OAuthFailureModeFailOpen   = "fail_open"
+)
+
+var OAuthFailureModes = []string{OAuthFailureModeFailClosed, OAuthFailureModeFailOpen}
+
 type OAuthConfig struct {
 	TokenEndpoint     string `yaml:"token_endpoint"`
 	Port              int    `yaml:"port"`
@@ -71,12 +184,51 @@ type OAuthConfig struct {
 	ClientName        string `yaml:"client_name"`
 	ClientSecret      string `yaml:"client_secret"`
 	CACerts           string `yaml:"ca_certs"`
+
+	// FailureMode is what Gorouter does when UAA cannot be reached. With
+	// fail_closed, requests whose bearer token cannot be validated get a
+	// 503, and Gorouter exits when it cannot fetch its token for the routing
+	// API at startup. With fail_open, those requests are proxied without
+	// validation, and Gorouter starts without a token.
+	FailureMode string `yaml:"failure_mode"`
 }
 
 type BackendConfig struct {
 	ClientAuthCertificate tls.Certificate
 	MaxConns              int64            `yaml:"max_conns"`
 	TLSPem                `yaml:",inline"` // embed to get cert_chain and private_key for client authentication
+
+	// ClientCerts are additional client certificates that a route
+	// registration can select by name with backend_client_cert_name.
+	ClientCerts                 []BackendClientCert        `yaml:"client_certs"`
+	NamedClientAuthCertificates map[string]tls.Certificate `yaml:"-"`
+
+	// ResponseHeaderTimeout limits the time to wait for a backend's response
+	// headers once the request has been written. Unlike endpoint_timeout it
+	// does not include the time spent streaming the response body.
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"`
+
+	// MaxRequestsPerConn closes a backend connection after it has served
+	// this many requests, instead of returning it to the idle pool. Disabled
+	// when zero.
+	MaxRequestsPerConn int `yaml:"max_requests_per_conn"`
+
+	// ProxyProtocol prepends a PROXY protocol header of this version, v1 or
+	// v2, to backend connections, unless a route registration chooses
+	// otherwise. Disabled when empty.
+	ProxyProtocol string `yaml:"proxy_protocol"`
+
+	// The TLS versions that the router offers backends. An empty version
+	// leaves the choice to crypto/tls.
+	MinTLSVersionString string `yaml:"min_tls_version"`
+	MinTLSVersion       uint16 `yaml:"-"`
+	MaxTLSVersionString string `yaml:"max_tls_version"`
+	MaxTLSVersion       uint16 `yaml:"-"`
+}
+
+type BackendClientCert struct {
+	Name   string `yaml:"name"`
+	TLSPem `yaml:",inline"`
 }
 
 type LoggingConfig struct {
@@ -93,18 +245,567 @@ type LoggingConfig struct {
 	JobName string `yaml:"-"`
 }
 
+const (
+	AccessLogFormatText = "text"
+	AccessLogFormatJSON = "json"
+)
+
 type AccessLog struct {
 	File            string `yaml:"file"`
 	EnableStreaming bool   `yaml:"enable_streaming"`
+
+	// Format is the format of the records written to the file and to syslog:
+	// text, or json for one JSON object per record. App logs sent through
+	// Loggregator are always text.
+	Format string `yaml:"format"`
+
+	// Template, when set, lays out the text records written to the file and
+	// to syslog, such as `$host [$timestamp] "$method $request_uri" $status_code`.
+	// See schema.ParseTemplate for the variables.
+	Template string `yaml:"template"`
+
+	// BufferSize is the number of records queued for writing. Records are
+	// dropped when the queue is full. When FlushInterval is set, writes to
+	// the file are buffered and flushed on that interval.
+	BufferSize    int           `yaml:"buffer_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+
+	Syslog AccessLogSyslogConfig `yaml:"syslog"`
+	Kafka  AccessLogKafkaConfig  `yaml:"kafka"`
+}
+
+var defaultAccessLogConfig = AccessLog{
+	Format:     AccessLogFormatText,
+	BufferSize: 1024,
+	Syslog:     defaultAccessLogSyslogConfig,
+	Kafka:      defaultAccessLogKafkaConfig,
+}
+
+// AccessLogSyslogConfig configures sending the access log records to the
+// syslog server at Address, when set, in the RFC 5424 format over TCP, or
+// over TLS when TLS is set. CACerts are trusted in addition to the system
+// certificates. Up to BufferSize records are queued while the server is
+// unreachable, and the records beyond that are dropped.
+type AccessLogSyslogConfig struct {
+	Address           string `yaml:"address"`
+	TLS               bool   `yaml:"tls"`
+	CACerts           string `yaml:"ca_certs"`
+	SkipSSLValidation bool   `yaml:"skip_ssl_validation"`
+	AppName           string `yaml:"app_name"`
+	BufferSize        int    `yaml:"buffer_size"`
+}
+
+var defaultAccessLogSyslogConfig = AccessLogSyslogConfig{
+	AppName:    "gorouter",
+	BufferSize: 10000,
+}
+
+const (
+	KafkaSASLMechanismPlain       = "PLAIN"
+	KafkaSASLMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	KafkaSASLMechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// AccessLogKafkaConfig configures producing the access log records to Topic
+// on the Kafka cluster of Brokers, when set, over TLS when TLS is set, and
+// authenticated with SASL when a SASL mechanism is set. Records are sent in
+// batches of up to BatchSize records, at least every FlushInterval. Up to
+// BufferSize records are queued while a batch is sent, and the records beyond
+// that are dropped.
+type AccessLogKafkaConfig struct {
+	Brokers           []string                 `yaml:"brokers"`
+	Topic             string                   `yaml:"topic"`
+	ClientID          string                   `yaml:"client_id"`
+	TLS               bool                     `yaml:"tls"`
+	CACerts           string                   `yaml:"ca_certs"`
+	SkipSSLValidation bool                     `yaml:"skip_ssl_validation"`
+	SASL              AccessLogKafkaSASLConfig `yaml:"sasl"`
+	BatchSize         int                      `yaml:"batch_size"`
+	FlushInterval     time.Duration            `yaml:"flush_interval"`
+	BufferSize        int                      `yaml:"buffer_size"`
+}
+
+// AccessLogKafkaSASLConfig selects the SASL mechanism, PLAIN, SCRAM-SHA-256
+// or SCRAM-SHA-512, and the credentials that gorouter authenticates to Kafka
+// brokers with.
+type AccessLogKafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+var defaultAccessLogKafkaConfig = AccessLogKafkaConfig{
+	ClientID:      "gorouter",
+	BatchSize:     500,
+	FlushInterval: time.Second,
+	BufferSize:    10000,
+}
+
+// RouteSnapshotConfig configures writing the routing table to Path every
+// Interval, and loading it at startup unless it was written more than MaxAge
+// ago. Zero MaxAge loads snapshots of any age.
+type RouteSnapshotConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+	MaxAge   time.Duration `yaml:"max_age"`
+}
+
+var defaultRouteSnapshotConfig = RouteSnapshotConfig{
+	Interval: 60 * time.Second,
+	MaxAge:   5 * time.Minute,
+}
+
+// ConsulConfig configures watching the catalog of the Consul agent at
+// Address, when set, and registering the healthy instances of services with
+// a tag of RouteTagPrefix followed by a route, such as
+// "gorouter.route=app.example.com/path". Token is sent as the ACL token of
+// the requests, and Datacenter selects a datacenter other than the agent's.
+type ConsulConfig struct {
+	Address        string        `yaml:"address"`
+	Token          string        `yaml:"token"`
+	Datacenter     string        `yaml:"datacenter"`
+	RouteTagPrefix string        `yaml:"route_tag_prefix"`
+	RetryInterval  time.Duration `yaml:"retry_interval"`
+}
+
+var defaultConsulConfig = ConsulConfig{
+	RouteTagPrefix: "gorouter.route=",
+	RetryInterval:  5 * time.Second,
+}
+
+// KubernetesConfig configures watching the Services of a Kubernetes cluster,
+// when Enabled, and registering the ready endpoints of the EndpointSlices of
+// those annotated with RouteAnnotation, a comma-separated list of routes.
+// PortAnnotation names the port of the EndpointSlices to route to, when they
+// have more than one. APIServer, TokenFile and CAFile default to those of the
+// pod that gorouter runs in, and Namespace restricts the watch to one
+// namespace.
+type KubernetesConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	APIServer       string        `yaml:"api_server"`
+	TokenFile       string        `yaml:"token_file"`
+	CAFile          string        `yaml:"ca_file"`
+	Namespace       string        `yaml:"namespace"`
+	RouteAnnotation string        `yaml:"route_annotation"`
+	PortAnnotation  string        `yaml:"port_annotation"`
+	RetryInterval   time.Duration `yaml:"retry_interval"`
+}
+
+var defaultKubernetesConfig = KubernetesConfig{
+	TokenFile:       "/var/run/secrets/kubernetes.io/serviceaccount/token",
+	CAFile:          "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt",
+	RouteAnnotation: "gorouter.cloudfoundry.org/routes",
+	PortAnnotation:  "gorouter.cloudfoundry.org/port",
+	RetryInterval:   5 * time.Second,
+}
+
+const (
+	ForwardedHeaderXForwarded = "x-forwarded"
+	ForwardedHeaderForwarded  = "forwarded"
+)
+
+var ForwardedHeaders = []string{ForwardedHeaderXForwarded, ForwardedHeaderForwarded}
+
+// TerminatingProxyConfig describes a load balancer in front of gorouter that
+// terminates TLS. Requests from trusted proxies carry the original scheme in
+// SchemeHeader, which is then used as the effective scheme of the request.
+// When ForwardedHeader is "forwarded", the client and scheme are instead
+// taken from the Forwarded header. TrustedCIDRs are added to the trusted
+// proxies of Config.TrustedProxyCIDRs.
+type TerminatingProxyConfig struct {
+	Enabled         bool     `yaml:"enabled"`
+	TrustedCIDRs    []string `yaml:"trusted_cidrs"`
+	SchemeHeader    string   `yaml:"scheme_header"`
+	ForwardedHeader string   `yaml:"forwarded_header"`
+}
+
+var defaultTerminatingProxyConfig = TerminatingProxyConfig{
+	SchemeHeader:    "X-Forwarded-Proto",
+	ForwardedHeader: ForwardedHeaderXForwarded,
+}
+
+// ClientIPAccessConfig restricts the client IPs that may send requests to any
+// route. IPs in DeniedCIDRs are rejected, and so are IPs outside of
+// AllowedCIDRs unless it is empty. Routes can register lists of their own,
+// which clients must pass as well.
+type ClientIPAccessConfig struct {
+	AllowedCIDRs []string `yaml:"allowed_cidrs"`
+	DeniedCIDRs  []string `yaml:"denied_cidrs"`
+
+	AllowedNets []*net.IPNet `yaml:"-"`
+	DeniedNets  []*net.IPNet `yaml:"-"`
+}
+
+const (
+	FrameOptionsDeny       = "DENY"
+	FrameOptionsSameOrigin = "SAMEORIGIN"
+)
+
+// SecurityHeadersConfig is a policy of security headers that are set on the
+// responses to requests for Domains, replacing those sent by backends.
+// Domains are host names, such as example.com, wildcards that match their
+// subdomains, such as *.example.com, or * for every domain. HSTS is only sent
+// when its MaxAge is set, and X-Frame-Options when FrameOptions is.
+type SecurityHeadersConfig struct {
+	Domains            []string   `yaml:"domains"`
+	HSTS               HSTSConfig `yaml:"hsts,omitempty"`
+	ContentTypeOptions bool       `yaml:"content_type_options,omitempty"`
+	FrameOptions       string     `yaml:"frame_options,omitempty"`
+}
+
+// HeaderRule adds, sets and removes the request and response headers of
+// requests that match its Domains and Routes. Domains are matched like those
+// of SecurityHeadersConfig, and Routes are registered routes such as
+// app.example.com/api. A rule with neither applies to every request.
+type HeaderRule struct {
+	Domains  []string          `yaml:"domains,omitempty"`
+	Routes   []string          `yaml:"routes,omitempty"`
+	Request  HeaderRuleActions `yaml:"request,omitempty"`
+	Response HeaderRuleActions `yaml:"response,omitempty"`
+}
+
+// HeaderRuleActions are applied in order: headers in Remove are removed,
+// those in Set replace any values, and those in Add are appended.
+type HeaderRuleActions struct {
+	Remove []string          `yaml:"remove,omitempty"`
+	Set    []HeaderNameValue `yaml:"set,omitempty"`
+	Add    []HeaderNameValue `yaml:"add,omitempty"`
+}
+
+const (
+	PrunePolicyStale       = "stale"
+	PrunePolicyNever       = "never"
+	PrunePolicyQuorum      = "quorum"
+	PrunePolicyGracePeriod = "grace_period"
+)
+
+// PrunePolicyConfig decides when the stale endpoints of routes in Domains,
+// which are matched like those of SecurityHeadersConfig, are pruned. The
+// stale policy prunes them as soon as they are stale. The never policy keeps
+// them until they are unregistered. The quorum policy prunes them only once
+// Quorum gorouters, this one included, have found them stale within the
+// droplet stale threshold, which they tell each other over NATS. The
+// grace_period policy does not prune them while gorouter is disconnected from
+// NATS, nor until GracePeriod has passed since it reconnected.
+type PrunePolicyConfig struct {
+	Domains     []string      `yaml:"domains"`
+	Policy      string        `yaml:"policy"`
+	Quorum      int           `yaml:"quorum,omitempty"`
+	GracePeriod time.Duration `yaml:"grace_period,omitempty"`
+}
+
+type HSTSConfig struct {
+	MaxAge            time.Duration `yaml:"max_age"`
+	IncludeSubDomains bool          `yaml:"include_subdomains"`
+}
+
+const (
+	CompressionEncodingBrotli = "br"
+	CompressionEncodingGzip   = "gzip"
+)
+
+var CompressionEncodings = []string{CompressionEncodingBrotli, CompressionEncodingGzip}
+
+// CompressionConfig enables compression of backend responses with the one of
+// Encodings that the client gives the highest quality in Accept-Encoding,
+// or the first of those it gives the same quality. Only responses with one of
+// ContentTypes, which may end in /* to match any subtype, and with bodies of
+// at least MinSize bytes are compressed.
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Encodings    []string `yaml:"encodings"`
+	ContentTypes []string `yaml:"content_types"`
+	MinSize      int      `yaml:"min_size"`
+}
+
+var defaultCompressionConfig = CompressionConfig{
+	Encodings: []string{CompressionEncodingBrotli, CompressionEncodingGzip},
+	ContentTypes: []string{
+		"application/javascript",
+		"application/json",
+		"application/xml",
+		"image/svg+xml",
+		"text/*",
+	},
+	MinSize: 1024,
+}
+
+// HTMLInjectionConfig describes content added to text/html responses.
+// Snippet is inserted before the closing </head> tag and Headers are added to
+// the response. The placeholder {{nonce}} in either is replaced with a random
+// value generated for each response. Bodies larger than MaxBodySize are left
+// untouched.
+type HTMLInjectionConfig struct {
+	Snippet     string            `yaml:"snippet"`
+	Headers     map[string]string `yaml:"headers"`
+	MaxBodySize int64             `yaml:"max_body_size"`
+}
+
+var defaultHTMLInjectionConfig = HTMLInjectionConfig{
+	MaxBodySize: 1024 * 1024,
+}
+
+// ConsistentHashConfig selects the request attribute that endpoints are
+// hashed on when the load balancing algorithm is consistent-hash: the value
+// of the Header, the value of the Cookie, or the client IP address. Exactly
+// one of them must be set.
+type ConsistentHashConfig struct {
+	Header   string `yaml:"header,omitempty"`
+	Cookie   string `yaml:"cookie,omitempty"`
+	ClientIP bool   `yaml:"client_ip,omitempty"`
+}
+
+// HealthCheckConfig configures active health checks of endpoints. Every
+// Interval, each endpoint is sent a GET request for Path when Type is http,
+// or is connected to when Type is tcp. Endpoints that fail
+// UnhealthyThreshold checks in a row are taken out of rotation until they
+// pass HealthyThreshold checks in a row.
+type HealthCheckConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	Type               string        `yaml:"type"`
+	Path               string        `yaml:"path"`
+	Interval           time.Duration `yaml:"interval"`
+	Timeout            time.Duration `yaml:"timeout"`
+	HealthyThreshold   int           `yaml:"healthy_threshold"`
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold"`
+}
+
+var defaultHealthCheckConfig = HealthCheckConfig{
+	Type:               HEALTH_CHECK_HTTP,
+	Path:               "/",
+	Interval:           10 * time.Second,
+	Timeout:            2 * time.Second,
+	HealthyThreshold:   2,
+	UnhealthyThreshold: 3,
+}
+
+// OutlierDetectionConfig configures passive outlier detection. Endpoints
+// whose requests fail to connect or respond with a 5xx status
+// ConsecutiveFailures times in a row are ejected from their pool. The first
+// ejection lasts BaseEjectionTime, and every ejection that follows without a
+// successful request in between lasts BaseEjectionTime longer, up to
+// MaxEjectionTime.
+type OutlierDetectionConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	ConsecutiveFailures int           `yaml:"consecutive_failures"`
+	BaseEjectionTime    time.Duration `yaml:"base_ejection_time"`
+	MaxEjectionTime     time.Duration `yaml:"max_ejection_time"`
+}
+
+var defaultOutlierDetectionConfig = OutlierDetectionConfig{
+	ConsecutiveFailures: 5,
+	BaseEjectionTime:    30 * time.Second,
+	MaxEjectionTime:     5 * time.Minute,
+}
+
+// CircuitBreakerConfig configures a circuit breaker around each endpoint.
+// The circuit of an endpoint opens after ConsecutiveFailures failed requests
+// in a row, and no request is sent to the endpoint for OpenTimeout. Up to
+// HalfOpenMaxRequests requests at a time then probe the endpoint, and the
+// circuit closes after SuccessThreshold of them succeed.
+type CircuitBreakerConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	ConsecutiveFailures int           `yaml:"consecutive_failures"`
+	OpenTimeout         time.Duration `yaml:"open_timeout"`
+	HalfOpenMaxRequests int           `yaml:"half_open_max_requests"`
+	SuccessThreshold    int           `yaml:"success_threshold"`
+}
+
+var defaultCircuitBreakerConfig = CircuitBreakerConfig{
+	ConsecutiveFailures: 5,
+	OpenTimeout:         30 * time.Second,
+	HalfOpenMaxRequests: 1,
+	SuccessThreshold:    2,
+}
+
+// RetryPolicyConfig configures how requests to endpoints are retried. A
+// request is made at most MaxAttempts times, each attempt limited to
+// PerTryTimeout, or to endpoint_timeout when it is zero. Attempts that fail
+// to reach the endpoint are retried, and so are responses with one of the
+// RetryableStatusCodes when the request has no body. When
+// IdempotentMethodsOnly is set, only GET, HEAD and OPTIONS requests are
+// retried unless the request or its route opts in.
+type RetryPolicyConfig struct {
+	MaxAttempts           int               `yaml:"max_attempts"`
+	PerTryTimeout         time.Duration     `yaml:"per_try_timeout"`
+	RetryableStatusCodes  []int             `yaml:"retryable_status_codes"`
+	IdempotentMethodsOnly bool              `yaml:"idempotent_methods_only"`
+	Budget                RetryBudgetConfig `yaml:"budget"`
+}
+
+// RetryBudgetConfig limits the retries made over the last ten seconds to
+// MinRetriesPerSecond plus Percent of the requests, so that retries do not
+// multiply the load on endpoints that are already failing.
+type RetryBudgetConfig struct {
+	Enabled             bool    `yaml:"enabled"`
+	Percent             float64 `yaml:"percent"`
+	MinRetriesPerSecond int     `yaml:"min_retries_per_second"`
+}
+
+var defaultRetryPolicyConfig = RetryPolicyConfig{
+	MaxAttempts:           3,
+	IdempotentMethodsOnly: true,
+	Budget: RetryBudgetConfig{
+		Percent:             20,
+		MinRetriesPerSecond: 10,
+	},
+}
+
+// StreamingConfig configures how streamed responses are sent to clients.
+// Responses of routes tagged Streaming are flushed every FlushInterval, or
+// after every write when it is zero. Server-Sent Events are always flushed
+// after every write.
+type StreamingConfig struct {
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+// ResponseCacheConfig bounds the memory used to cache responses of routes
+// that opt in with the CacheTTL tag. Caching is disabled when MaxSizeBytes is
+// zero. When DiskPath is set, responses evicted from memory are kept in
+// files in that directory, using at most DiskMaxSizeBytes.
+type ResponseCacheConfig struct {
+	MaxSizeBytes     int64  `yaml:"max_size_bytes"`
+	DiskPath         string `yaml:"disk_path,omitempty"`
+	DiskMaxSizeBytes int64  `yaml:"disk_max_size_bytes,omitempty"`
+}
+
+// OCSPStaplingConfig enables stapling OCSP responses for the certificates
+// of the TLS listeners. Responses are fetched from the responders named in
+// the certificates, each within Timeout, and again every RefreshInterval.
+type OCSPStaplingConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	Timeout         time.Duration `yaml:"timeout"`
+}
+
+var defaultOCSPStaplingConfig = OCSPStaplingConfig{
+	RefreshInterval: time.Hour,
+	Timeout:         10 * time.Second,
+}
+
+// JWTConfig configures validating the bearer tokens of requests against the
+// keys published at JWKSURL. When Enabled, requests to every route must carry
+// a valid token; otherwise only routes that register to require one. The
+// claims of valid tokens are forwarded to backends in ClaimsHeader.
+type JWTConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	Issuer              string        `yaml:"issuer"`
+	Audiences           []string      `yaml:"audiences"`
+	JWKSURL             string        `yaml:"jwks_url"`
+	JWKSRefreshInterval time.Duration `yaml:"jwks_refresh_interval"`
+	JWKSTimeout         time.Duration `yaml:"jwks_timeout"`
+	ClockSkew           time.Duration `yaml:"clock_skew"`
+	ClaimsHeader        string        `yaml:"claims_header"`
+}
+
+var defaultJWTConfig = JWTConfig{
+	JWKSRefreshInterval: 10 * time.Minute,
+	JWKSTimeout:         10 * time.Second,
+	ClockSkew:           time.Minute,
+	ClaimsHeader:        "X-Jwt-Claims",
+}
+
+const (
+	RateLimitKeyRoute    = "route"
+	RateLimitKeyClientIP = "client_ip"
+	RateLimitKeyHeader   = "header"
+)
+
+var RateLimitKeys = []string{RateLimitKeyRoute, RateLimitKeyClientIP, RateLimitKeyHeader}
+
+// RateLimitConfig limits the rate of requests to each route with a token
+// bucket that fills with RequestsPerSecond tokens a second and holds up to
+// Burst tokens. Key chooses whether all clients of a route share a bucket,
+// or each client IP or value of Header has its own. Routes can register
+// their own rate and burst; a rate of zero does not limit requests.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+	Key               string  `yaml:"key"`
+	Header            string  `yaml:"header"`
+}
+
+var defaultRateLimitConfig = RateLimitConfig{
+	Key: RateLimitKeyClientIP,
+}
+
+// LoadSheddingConfig caps the number of requests that are handled at once.
+// Requests beyond MaxInFlightRequests are rejected right away, and clients
+// are asked to retry after RetryAfter. Zero does not cap requests.
+type LoadSheddingConfig struct {
+	MaxInFlightRequests int           `yaml:"max_in_flight_requests"`
+	RetryAfter          time.Duration `yaml:"retry_after"`
+}
+
+var defaultLoadSheddingConfig = LoadSheddingConfig{
+	RetryAfter: time.Second,
+}
+
+// PerRouteMetricsConfig enables latency and response metrics for each route.
+// Only the TopN routes with the most requests in the previous Window get
+// metrics of their own, and the others are aggregated as "other". At most
+// MaxRoutes routes ever get metrics of their own, since metrics that routes
+// have emitted are not forgotten when they leave the top.
+type PerRouteMetricsConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	TopN      int           `yaml:"top_n"`
+	MaxRoutes int           `yaml:"max_routes"`
+	Window    time.Duration `yaml:"window"`
+}
+
+var defaultPerRouteMetricsConfig = PerRouteMetricsConfig{
+	TopN:      20,
+	MaxRoutes: 200,
+	Window:    time.Minute,
+}
+
+// TrafficMirroringConfig bounds the copies of requests that routes mirror
+// to another route. At most MaxInFlightRequests copies are sent at once, and
+// each within Timeout. Requests with bodies larger than MaxBodyBytes are not
+// mirrored, since their bodies are held in memory to be sent twice. The
+// credentials of requests are removed from the copies unless
+// ForwardCredentials is set.
+type TrafficMirroringConfig struct {
+	MaxInFlightRequests int           `yaml:"max_in_flight_requests"`
+	MaxBodyBytes        int64         `yaml:"max_body_bytes"`
+	Timeout             time.Duration `yaml:"timeout"`
+	ForwardCredentials  bool          `yaml:"forward_credentials"`
+}
+
+var defaultTrafficMirroringConfig = TrafficMirroringConfig{
+	MaxInFlightRequests: 100,
+	MaxBodyBytes:        1024 * 1024,
+	Timeout:             5 * time.Second,
 }
 
 type Tracing struct {
-	EnableZipkin bool `yaml:"enable_zipkin"`
+	EnableZipkin bool       `yaml:"enable_zipkin"`
+	OTLP         OTLPConfig `yaml:"otlp,omitempty"`
+}
+
+// OTLPConfig enables the export of request spans to an OpenTelemetry
+// collector. Endpoint is the collector's OTLP/HTTP traces URL, such as
+// http://localhost:4318/v1/traces.
+type OTLPConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"service_name"`
+}
+
+var defaultTracingConfig = Tracing{
+	OTLP: OTLPConfig{
+		ServiceName: "gorouter",
+	},
 }
 
 type TLSPem struct {
 	CertChain  string `yaml:"cert_chain"`
 	PrivateKey string `yaml:"private_key"`
+
+	// Default makes this the certificate of router.tls_pem that is served
+	// to clients whose server name matches no certificate. The first
+	// certificate is served when none is the default.
+	Default bool `yaml:"default,omitempty"`
 }
 
 var defaultLoggingConfig = LoggingConfig{
@@ -128,6 +829,8 @@ type HTTPRewriteResponses struct {
 
 type Config struct {
 	Status                   StatusConfig      `yaml:"status,omitempty"`
+	Prometheus               PrometheusConfig  `yaml:"prometheus,omitempty"`
+	StatsD                   StatsDConfig      `yaml:"statsd,omitempty"`
 	Nats                     []NatsConfig      `yaml:"nats,omitempty"`
 	Logging                  LoggingConfig     `yaml:"logging,omitempty"`
 	Port                     uint16            `yaml:"port,omitempty"`
@@ -157,21 +860,37 @@ type Config struct {
 	CipherSuites                      []uint16           `yaml:"-"`
 	MinTLSVersionString               string             `yaml:"min_tls_version,omitempty"`
 	MinTLSVersion                     uint16             `yaml:"-"`
+	MaxTLSVersionString               string             `yaml:"max_tls_version,omitempty"`
+	MaxTLSVersion                     uint16             `yaml:"-"`
 	ClientCertificateValidationString string             `yaml:"client_cert_validation,omitempty"`
 	ClientCertificateValidation       tls.ClientAuthType `yaml:"-"`
+	EnableHTTP2                       bool               `yaml:"enable_http2,omitempty"`
+	HTTP2MaxConcurrentStreams         uint32             `yaml:"http2_max_concurrent_streams,omitempty"`
+	EnableHTTP3                       bool               `yaml:"enable_http3,omitempty"`
+	HTTP3Port                         uint16             `yaml:"http3_port,omitempty"`
+	HTTP3AltSvcMaxAge                 time.Duration      `yaml:"http3_alt_svc_max_age,omitempty"`
 
 	LoadBalancerHealthyThreshold    time.Duration `yaml:"load_balancer_healthy_threshold,omitempty"`
 	PublishStartMessageInterval     time.Duration `yaml:"publish_start_message_interval,omitempty"`
 	SuspendPruningIfNatsUnavailable bool          `yaml:"suspend_pruning_if_nats_unavailable,omitempty"`
+	EmitNatsHealth                  bool          `yaml:"emit_nats_health,omitempty"`
 	PruneStaleDropletsInterval      time.Duration `yaml:"prune_stale_droplets_interval,omitempty"`
 	DropletStaleThreshold           time.Duration `yaml:"droplet_stale_threshold,omitempty"`
 	PublishActiveAppsInterval       time.Duration `yaml:"publish_active_apps_interval,omitempty"`
 	StartResponseDelayInterval      time.Duration `yaml:"start_response_delay_interval,omitempty"`
 	EndpointTimeout                 time.Duration `yaml:"endpoint_timeout,omitempty"`
+	MaxRouteEndpointTimeout         time.Duration `yaml:"max_route_endpoint_timeout,omitempty"`
+	PropagateTimeoutHeader          string        `yaml:"propagate_timeout_header,omitempty"`
 	EndpointDialTimeout             time.Duration `yaml:"-"`
 	RouteServiceTimeout             time.Duration `yaml:"route_services_timeout,omitempty"`
 	FrontendIdleTimeout             time.Duration `yaml:"frontend_idle_timeout,omitempty"`
 
+	// MinStaleThreshold and MaxStaleThreshold bound the stale thresholds that
+	// registrations ask for. A zero MinStaleThreshold does not raise them,
+	// and a zero MaxStaleThreshold lowers them to DropletStaleThreshold.
+	MinStaleThreshold time.Duration `yaml:"min_stale_threshold,omitempty"`
+	MaxStaleThreshold time.Duration `yaml:"max_stale_threshold,omitempty"`
+
 	RouteLatencyMetricMuzzleDuration time.Duration `yaml:"route_latency_metric_muzzle_duration,omitempty"`
 
 	DrainWait            time.Duration `yaml:"drain_wait,omitempty"`
@@ -184,6 +903,15 @@ type Config struct {
 	RouteServiceSecret         string           `yaml:"route_services_secret,omitempty"`
 	RouteServiceSecretPrev     string           `yaml:"route_services_secret_decrypt_only,omitempty"`
 	RouteServiceRecommendHttps bool             `yaml:"route_services_recommend_https,omitempty"`
+	RewriteRedirectLocation    bool             `yaml:"rewrite_redirect_location,omitempty"`
+
+	// The TLS versions that the router offers route services. An empty
+	// version leaves the choice to crypto/tls.
+	RouteServicesMinTLSVersionString string `yaml:"route_services_min_tls_version,omitempty"`
+	RouteServicesMinTLSVersion       uint16 `yaml:"-"`
+	RouteServicesMaxTLSVersionString string `yaml:"route_services_max_tls_version,omitempty"`
+	RouteServicesMaxTLSVersion       uint16 `yaml:"-"`
+
 	// These fields are populated by the `Process` function.
 	Ip                          string        `yaml:"-"`
 	RouteServiceEnabled         bool          `yaml:"-"`
@@ -199,16 +927,164 @@ type Config struct {
 	PidFile     string `yaml:"pid_file,omitempty"`
 	LoadBalance string `yaml:"balancing_algorithm,omitempty"`
 
+	ConsistentHash ConsistentHashConfig `yaml:"consistent_hash,omitempty"`
+
+	// AvailabilityZone is the availability zone of the router. When
+	// PreferLocalAvailabilityZone is set, endpoints in the same zone are
+	// selected while any of them is neither overloaded nor failed.
+	AvailabilityZone            string `yaml:"availability_zone,omitempty"`
+	PreferLocalAvailabilityZone bool   `yaml:"prefer_local_availability_zone,omitempty"`
+
 	DisableKeepAlives   bool `yaml:"disable_keep_alives"`
 	MaxIdleConns        int  `yaml:"max_idle_conns,omitempty"`
 	MaxIdleConnsPerHost int  `yaml:"max_idle_conns_per_host,omitempty"`
 
 	HTTPRewrite HTTPRewrite `yaml:"http_rewrite,omitempty"`
+
+	PreloadRoutesFile string              `yaml:"preload_routes_file,omitempty"`
+	RouteSnapshot     RouteSnapshotConfig `yaml:"route_snapshot,omitempty"`
+	Consul            ConsulConfig        `yaml:"consul,omitempty"`
+	Kubernetes        KubernetesConfig    `yaml:"kubernetes,omitempty"`
+
+	GetRequestBodyPolicy string `yaml:"get_request_body_policy,omitempty"`
+
+	// HTTP10Policy applies to HTTP/1.0 requests without a Host header. They
+	// are either rejected, or routed to HTTP10DefaultHost.
+	HTTP10Policy      string `yaml:"http10_policy,omitempty"`
+	HTTP10DefaultHost string `yaml:"http10_default_host,omitempty"`
+
+	DrainRequestBodyOnError         bool  `yaml:"drain_request_body_on_error,omitempty"`
+	DrainRequestBodyOnErrorMaxBytes int64 `yaml:"drain_request_body_on_error_max_bytes,omitempty"`
+
+	FailedEndpointCooldown time.Duration `yaml:"failed_endpoint_cooldown,omitempty"`
+
+	// Endpoints that have not served a request within IdleEndpointPruneThreshold
+	// are health checked and pruned if the check fails. Disabled when zero.
+	IdleEndpointPruneThreshold     time.Duration `yaml:"idle_endpoint_prune_threshold,omitempty"`
+	IdleEndpointHealthCheckTimeout time.Duration `yaml:"idle_endpoint_health_check_timeout,omitempty"`
+
+	HealthCheck HealthCheckConfig `yaml:"health_check,omitempty"`
+
+	OutlierDetection OutlierDetectionConfig `yaml:"outlier_detection,omitempty"`
+
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker,omitempty"`
+
+	RetryPolicy RetryPolicyConfig `yaml:"retry_policy,omitempty"`
+
+	// RetryOnStatusCodes are added to the retryable status codes of the
+	// retry policy.
+	RetryOnStatusCodes []int `yaml:"retry_on_status_codes,omitempty"`
+
+	Streaming StreamingConfig `yaml:"streaming,omitempty"`
+
+	NatsMaxRegistrationRate int                 `yaml:"nats_max_registration_rate,omitempty"`
+	NatsJetStream           NatsJetStreamConfig `yaml:"nats_jetstream,omitempty"`
+
+	// NatsCredentialsFile is a credentials file with the user JWT and NKey
+	// seed that authenticate gorouter to NATS servers in operator mode.
+	// NatsNkeySeedFile instead holds the seed of an NKey user. The files are
+	// read again whenever gorouter connects to NATS.
+	NatsCredentialsFile string `yaml:"nats_credentials_file,omitempty"`
+	NatsNkeySeedFile    string `yaml:"nats_nkey_seed_file,omitempty"`
+
+	// NatsSecondary are the servers of a NATS cluster that the subscriber
+	// fails over to once the servers in Nats have been unreachable for
+	// NatsFailoverAfter, and fails back from once they have been reachable
+	// again for as long.
+	NatsSecondary     []NatsConfig  `yaml:"nats_secondary,omitempty"`
+	NatsFailoverAfter time.Duration `yaml:"nats_failover_after,omitempty"`
+
+	// ValidateRegistrationMessages rejects registrations that would create
+	// endpoints which cannot be routed to.
+	ValidateRegistrationMessages bool `yaml:"validate_registration_messages,omitempty"`
+
+	TerminatingProxy TerminatingProxyConfig `yaml:"terminating_proxy,omitempty"`
+
+	// TrustedProxyCIDRs are the load balancers that may report the client of
+	// a request. X-Forwarded-For, X-Forwarded-Proto and Forwarded sent by
+	// other peers are removed or replaced when it is set. TrustedProxyNets
+	// holds them together with the trusted CIDRs of TerminatingProxy.
+	TrustedProxyCIDRs []string     `yaml:"trusted_proxy_cidrs,omitempty"`
+	TrustedProxyNets  []*net.IPNet `yaml:"-"`
+
+	// EmitForwardedHeaders are the headers that report the client of a
+	// request to backends: X-Forwarded-For and X-Forwarded-Proto for
+	// "x-forwarded", and Forwarded for "forwarded".
+	EmitForwardedHeaders []string `yaml:"emit_forwarded_headers,omitempty"`
+
+	// ListenerBacklog and EnableReusePort are only supported on Linux.
+	ListenerBacklog int  `yaml:"listener_backlog,omitempty"`
+	EnableReusePort bool `yaml:"enable_reuse_port,omitempty"`
+
+	HTMLInjection HTMLInjectionConfig `yaml:"html_injection,omitempty"`
+
+	Compression CompressionConfig `yaml:"compression,omitempty"`
+
+	ResponseCache ResponseCacheConfig `yaml:"response_cache,omitempty"`
+
+	OCSPStapling OCSPStaplingConfig `yaml:"ocsp_stapling,omitempty"`
+
+	JWT JWTConfig `yaml:"jwt,omitempty"`
+
+	RateLimit RateLimitConfig `yaml:"rate_limit,omitempty"`
+
+	LoadShedding LoadSheddingConfig `yaml:"load_shedding,omitempty"`
+
+	TrafficMirroring TrafficMirroringConfig `yaml:"traffic_mirroring,omitempty"`
+
+	ClientIPAccess ClientIPAccessConfig `yaml:"client_ip_access,omitempty"`
+
+	// MaxRequestBodyBytes limits the size of request bodies. Routes can
+	// register a limit of their own. Zero does not limit them.
+	MaxRequestBodyBytes int64 `yaml:"max_request_body_bytes,omitempty"`
+
+	// MaxDecompressedRequestBodyBytes limits the size that the gzip request
+	// bodies of routes that have them decompressed may grow to.
+	MaxDecompressedRequestBodyBytes int64 `yaml:"max_decompressed_request_body_bytes,omitempty"`
+
+	// MaxHeaderBytes limits the size of the request line and headers of
+	// requests. Zero uses the default of net/http, which is 1 MB.
+	MaxHeaderBytes int `yaml:"max_header_bytes,omitempty"`
+
+	// MaxHeaderCount limits the number of request headers. Zero does not
+	// limit it.
+	MaxHeaderCount int `yaml:"max_header_count,omitempty"`
+
+	// StrictRequestFraming rejects requests on the HTTP listener with more
+	// than one Content-Length header, an invalid Content-Length, or both
+	// Content-Length and Transfer-Encoding, instead of normalizing their
+	// framing.
+	StrictRequestFraming bool `yaml:"strict_request_framing,omitempty"`
+
+	// SecurityHeaders are applied to the responses to requests for their
+	// domains. The first policy with a matching domain applies.
+	SecurityHeaders []SecurityHeadersConfig `yaml:"security_headers,omitempty"`
+
+	// HeaderRules are applied to requests to a route, and their responses,
+	// in order.
+	HeaderRules []HeaderRule `yaml:"header_rules,omitempty"`
+
+	// PrunePolicies decide when the stale endpoints of routes in their
+	// domains are pruned. The first policy with a matching domain applies,
+	// and the endpoints of routes in other domains are pruned when stale.
+	PrunePolicies []PrunePolicyConfig `yaml:"prune_policies,omitempty"`
+
+	// PerRouteMetricsAllowlist lists the routes for which per-route metrics,
+	// such as the number of endpoints, are emitted.
+	PerRouteMetricsAllowlist []string `yaml:"per_route_metrics_allowlist,omitempty"`
+
+	// PerRouteMetrics emits the latency and responses of the busiest routes,
+	// along with those of PerRouteMetricsAllowlist.
+	PerRouteMetrics PerRouteMetricsConfig `yaml:"per_route_metrics,omitempty"`
 }
 
 var defaultConfig = Config{
 	Status:        defaultStatusConfig,
+	Prometheus:    defaultPrometheusConfig,
+	StatsD:        defaultStatsDConfig,
+	Tracing:       defaultTracingConfig,
 	Nats:          []NatsConfig{defaultNatsConfig},
+	RoutingApi:    defaultRoutingApiConfig,
 	Logging:       defaultLoggingConfig,
 	Port:          8081,
 	Index:         0,
@@ -219,6 +1095,9 @@ var defaultConfig = Config{
 	DisableHTTP:   false,
 	MinTLSVersion: tls.VersionTLS12,
 
+	HTTP2MaxConcurrentStreams: 100,
+	HTTP3AltSvcMaxAge:         24 * time.Hour,
+
 	EndpointTimeout:     60 * time.Second,
 	EndpointDialTimeout: 5 * time.Second,
 	RouteServiceTimeout: 60 * time.Second,
@@ -252,6 +1131,53 @@ var defaultConfig = Config{
 	DisableKeepAlives:   true,
 	MaxIdleConns:        100,
 	MaxIdleConnsPerHost: 2,
+
+	RouteSnapshot: defaultRouteSnapshotConfig,
+	Consul:        defaultConsulConfig,
+	Kubernetes:    defaultKubernetesConfig,
+
+	NatsJetStream:     defaultNatsJetStreamConfig,
+	NatsFailoverAfter: 30 * time.Second,
+
+	AccessLog: defaultAccessLogConfig,
+
+	GetRequestBodyPolicy: GET_BODY_FORWARD,
+
+	HTTP10Policy: HTTP10_REJECT,
+
+	DrainRequestBodyOnErrorMaxBytes: 1024 * 1024,
+
+	IdleEndpointHealthCheckTimeout: 5 * time.Second,
+
+	HealthCheck: defaultHealthCheckConfig,
+
+	OutlierDetection: defaultOutlierDetectionConfig,
+
+	CircuitBreaker: defaultCircuitBreakerConfig,
+
+	RetryPolicy: defaultRetryPolicyConfig,
+
+	TerminatingProxy: defaultTerminatingProxyConfig,
+
+	EmitForwardedHeaders: []string{ForwardedHeaderXForwarded},
+
+	HTMLInjection: defaultHTMLInjectionConfig,
+
+	Compression: defaultCompressionConfig,
+
+	MaxDecompressedRequestBodyBytes: 10 * 1024 * 1024,
+
+	OCSPStapling: defaultOCSPStaplingConfig,
+
+	JWT: defaultJWTConfig,
+
+	RateLimit: defaultRateLimitConfig,
+
+	LoadShedding: defaultLoadSheddingConfig,
+
+	TrafficMirroring: defaultTrafficMirroringConfig,
+
+	PerRouteMetrics: defaultPerRouteMetricsConfig,
 }
 
 func DefaultConfig() (*Config, error) {
@@ -279,6 +1205,26 @@ func (c *Config) Process() error {
 		c.DrainTimeout = c.EndpointTimeout
 	}
 
+	if c.MinStaleThreshold < 0 {
+		return fmt.Errorf("router.min_stale_threshold must not be negative")
+	}
+	if c.MinStaleThreshold > c.DropletStaleThreshold {
+		return fmt.Errorf("router.min_stale_threshold must not be greater than router.droplet_stale_threshold")
+	}
+	if c.MaxStaleThreshold < 0 {
+		return fmt.Errorf("router.max_stale_threshold must not be negative")
+	}
+	if c.MaxStaleThreshold != 0 && c.MaxStaleThreshold < c.DropletStaleThreshold {
+		return fmt.Errorf("router.max_stale_threshold must not be less than router.droplet_stale_threshold")
+	}
+
+	if c.MaxRouteEndpointTimeout < 0 {
+		return fmt.Errorf("router.max_route_endpoint_timeout must not be negative")
+	}
+	if c.MaxRouteEndpointTimeout == 0 {
+		c.MaxRouteEndpointTimeout = c.EndpointTimeout
+	}
+
 	var localIPErr error
 	c.Ip, localIPErr = localip.LocalIP()
 	if localIPErr != nil {
@@ -294,6 +1240,25 @@ func (c *Config) Process() error {
 		c.Backends.ClientAuthCertificate = certificate
 	}
 
+	if len(c.Backends.ClientCerts) > 0 {
+		c.Backends.NamedClientAuthCertificates = make(map[string]tls.Certificate, len(c.Backends.ClientCerts))
+		for _, clientCert := range c.Backends.ClientCerts {
+			if clientCert.Name == "" {
+				return fmt.Errorf("Backend client certificates must have a name")
+			}
+			if _, ok := c.Backends.NamedClientAuthCertificates[clientCert.Name]; ok {
+				errMsg := fmt.Sprintf("Duplicate backend client certificate name: %s", clientCert.Name)
+				return fmt.Errorf(errMsg)
+			}
+			certificate, err := tls.X509KeyPair([]byte(clientCert.CertChain), []byte(clientCert.PrivateKey))
+			if err != nil {
+				errMsg := fmt.Sprintf("Error loading key pair for backend client certificate %s: %s", clientCert.Name, err.Error())
+				return fmt.Errorf(errMsg)
+			}
+			c.Backends.NamedClientAuthCertificates[clientCert.Name] = certificate
+		}
+	}
+
 	if c.EnableSSL {
 		switch c.ClientCertificateValidationString {
 		case "none":
@@ -306,21 +1271,24 @@ func (c *Config) Process() error {
 			return fmt.Errorf(`router.client_cert_validation must be one of 'none', 'request' or 'require'.`)
 		}
 
-		switch c.MinTLSVersionString {
-		case "TLSv1.0":
-			c.MinTLSVersion = tls.VersionTLS10
-		case "TLSv1.1":
-			c.MinTLSVersion = tls.VersionTLS11
-		case "TLSv1.2", "":
-			c.MinTLSVersion = tls.VersionTLS12
-		default:
-			return fmt.Errorf(`router.min_tls_version should be one of "", "TLSv1.2", "TLSv1.1", "TLSv1.0"`)
+		var err error
+		c.MinTLSVersion, c.MaxTLSVersion, err = parseTLSVersions(
+			"router.min_tls_version", c.MinTLSVersionString,
+			"router.max_tls_version", c.MaxTLSVersionString,
+			tls.VersionTLS12,
+		)
+		if err != nil {
+			return err
 		}
 
 		if len(c.TLSPEM) == 0 {
 			return fmt.Errorf("router.tls_pem must be provided if router.enable_ssl is set to true")
 		}
 
+		// crypto/tls selects a certificate by server name, including
+		// wildcard names, and falls back to the first one
+		var certificates []tls.Certificate
+		hasDefault := false
 		for _, v := range c.TLSPEM {
 			if len(v.PrivateKey) == 0 || len(v.CertChain) == 0 {
 				return fmt.Errorf("Error parsing PEM blocks of router.tls_pem, missing cert or key.")
@@ -331,22 +1299,60 @@ func (c *Config) Process() error {
 				errMsg := fmt.Sprintf("Error loading key pair: %s", err.Error())
 				return fmt.Errorf(errMsg)
 			}
-			c.SSLCertificates = append(c.SSLCertificates, certificate)
 
+			if v.Default {
+				if hasDefault {
+					return fmt.Errorf("router.tls_pem must not have more than one default certificate")
+				}
+				hasDefault = true
+				certificates = append([]tls.Certificate{certificate}, certificates...)
+				continue
+			}
+			certificates = append(certificates, certificate)
 		}
-		var err error
+		c.SSLCertificates = certificates
+
 		c.CipherSuites, err = c.processCipherSuites()
 		if err != nil {
 			return err
 		}
-	} else {
-		if c.DisableHTTP {
-			errMsg := fmt.Sprintf("neither http nor https listener is enabled: router.enable_ssl: %t, router.disable_http: %t", c.EnableSSL, c.DisableHTTP)
-			return fmt.Errorf(errMsg)
+
+		if c.EnableHTTP2 {
+			if c.HTTP2MaxConcurrentStreams == 0 {
+				return fmt.Errorf("router.http2_max_concurrent_streams must be greater than zero if router.enable_http2 is set to true")
+			}
+			// cipher_suites do not apply to TLS 1.3, which always offers
+			// suites that HTTP/2 allows
+			if c.MinTLSVersion < tls.VersionTLS13 && !supportsHTTP2(c.CipherSuites) {
+				return fmt.Errorf("router.cipher_suites must include ECDHE-RSA-AES128-GCM-SHA256 or ECDHE-ECDSA-AES128-GCM-SHA256 if router.enable_http2 is set to true")
+			}
 		}
-	}
 
-	if c.RouteServiceSecret != "" {
+		if c.EnableHTTP3 {
+			if c.HTTP3Port == 0 {
+				c.HTTP3Port = c.SSLPort
+			}
+			if c.HTTP3AltSvcMaxAge < 0 {
+				return fmt.Errorf("router.http3_alt_svc_max_age must not be negative")
+			}
+			if c.MaxTLSVersion != 0 && c.MaxTLSVersion < tls.VersionTLS13 {
+				return fmt.Errorf("router.max_tls_version must be TLSv1.3 if router.enable_http3 is set to true")
+			}
+		}
+	} else {
+		if c.EnableHTTP3 {
+			return fmt.Errorf("router.enable_ssl must be set to true if router.enable_http3 is set to true")
+		}
+		if c.OCSPStapling.Enabled {
+			return fmt.Errorf("router.enable_ssl must be set to true if router.ocsp_stapling.enabled is set to true")
+		}
+		if c.DisableHTTP {
+			errMsg := fmt.Sprintf("neither http nor https listener is enabled: router.enable_ssl: %t, router.disable_http: %t", c.EnableSSL, c.DisableHTTP)
+			return fmt.Errorf(errMsg)
+		}
+	}
+
+	if c.RouteServiceSecret != "" {
 		c.RouteServiceEnabled = true
 	}
 
@@ -362,6 +1368,17 @@ func (c *Config) Process() error {
 		errMsg := fmt.Sprintf("Invalid load balancing algorithm %s. Allowed values are %s", c.LoadBalance, LoadBalancingStrategies)
 		return fmt.Errorf(errMsg)
 	}
+	if c.LoadBalance == LOAD_BALANCE_CH {
+		keys := 0
+		for _, set := range []bool{c.ConsistentHash.Header != "", c.ConsistentHash.Cookie != "", c.ConsistentHash.ClientIP} {
+			if set {
+				keys++
+			}
+		}
+		if keys != 1 {
+			return fmt.Errorf("router.consistent_hash must set exactly one of header, cookie or client_ip if router.balancing_algorithm is consistent-hash")
+		}
+	}
 	if c.LoadBalancerHealthyThreshold < 0 {
 		errMsg := fmt.Sprintf("Invalid load balancer healthy threshold: %s", c.LoadBalancerHealthyThreshold)
 		return fmt.Errorf(errMsg)
@@ -395,12 +1412,641 @@ func (c *Config) Process() error {
 		return fmt.Errorf("Expected isolation segments; routing table sharding mode set to segments and none provided.")
 	}
 
+	if c.PreferLocalAvailabilityZone && c.AvailabilityZone == "" {
+		return fmt.Errorf("router.availability_zone must be set if router.prefer_local_availability_zone is set to true")
+	}
+
+	if c.Prometheus.Enabled && !strings.HasPrefix(c.Prometheus.Path, "/") {
+		errMsg := fmt.Sprintf("Invalid prometheus path: %s. Must start with /", c.Prometheus.Path)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.StatsD.Enabled {
+		if c.StatsD.Address == "" {
+			return fmt.Errorf("router.statsd.address must be set if router.statsd.enabled is set to true")
+		}
+		switch c.StatsD.TagFormat {
+		case StatsDTagFormatNone, StatsDTagFormatDogStatsD, StatsDTagFormatInfluxDB:
+		default:
+			return fmt.Errorf("router.statsd.tag_format must be one of 'none', 'dogstatsd' or 'influxdb'")
+		}
+		if c.StatsD.TagFormat == StatsDTagFormatNone && len(c.StatsD.Tags) > 0 {
+			return fmt.Errorf("router.statsd.tag_format must be set if router.statsd.tags are set")
+		}
+		if c.StatsD.FlushInterval <= 0 {
+			return fmt.Errorf("router.statsd.flush_interval must be greater than zero")
+		}
+	}
+
+	if c.Tracing.OTLP.Enabled {
+		u, err := url.Parse(c.Tracing.OTLP.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errMsg := fmt.Sprintf("Invalid OTLP endpoint: %s. Must be an http or https URL", c.Tracing.OTLP.Endpoint)
+			return fmt.Errorf(errMsg)
+		}
+	}
+
+	switch c.OAuth.FailureMode {
+	case "":
+		c.OAuth.FailureMode = OAuthFailureModeFailClosed
+	case OAuthFailureModeFailClosed, OAuthFailureModeFailOpen:
+	default:
+		return fmt.Errorf("router.oauth.failure_mode must be one of %v", OAuthFailureModes)
+	}
+
+	if c.JWT.Enabled && c.JWT.JWKSURL == "" {
+		return fmt.Errorf("router.jwt.jwks_url must be set if router.jwt.enabled is set to true")
+	}
+	if c.JWT.JWKSURL != "" {
+		u, err := url.Parse(c.JWT.JWKSURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("router.jwt.jwks_url must be an http or https URL")
+		}
+		if c.JWT.Issuer == "" {
+			return fmt.Errorf("router.jwt.issuer must be set if router.jwt.jwks_url is set")
+		}
+		if c.JWT.JWKSRefreshInterval <= 0 {
+			return fmt.Errorf("router.jwt.jwks_refresh_interval must be greater than zero")
+		}
+		if c.JWT.JWKSTimeout <= 0 {
+			return fmt.Errorf("router.jwt.jwks_timeout must be greater than zero")
+		}
+		if c.JWT.ClockSkew < 0 {
+			return fmt.Errorf("router.jwt.clock_skew must not be negative")
+		}
+		if c.JWT.ClaimsHeader == "" {
+			return fmt.Errorf("router.jwt.claims_header must not be empty")
+		}
+	}
+
+	if c.RateLimit.RequestsPerSecond < 0 {
+		return fmt.Errorf("router.rate_limit.requests_per_second must not be negative")
+	}
+	if c.RateLimit.Burst < 0 {
+		return fmt.Errorf("router.rate_limit.burst must not be negative")
+	}
+	validRateLimitKey := false
+	for _, k := range RateLimitKeys {
+		if c.RateLimit.Key == k {
+			validRateLimitKey = true
+			break
+		}
+	}
+	if !validRateLimitKey {
+		return fmt.Errorf("router.rate_limit.key must be one of %v", RateLimitKeys)
+	}
+	if c.RateLimit.Key == RateLimitKeyHeader && c.RateLimit.Header == "" {
+		return fmt.Errorf("router.rate_limit.header must be set if router.rate_limit.key is header")
+	}
+
+	if c.MaxRequestBodyBytes < 0 {
+		return fmt.Errorf("router.max_request_body_bytes must not be negative")
+	}
+	if c.MaxDecompressedRequestBodyBytes <= 0 {
+		return fmt.Errorf("router.max_decompressed_request_body_bytes must be greater than zero")
+	}
+	if c.MaxHeaderBytes < 0 {
+		return fmt.Errorf("router.max_header_bytes must not be negative")
+	}
+	if c.MaxHeaderCount < 0 {
+		return fmt.Errorf("router.max_header_count must not be negative")
+	}
+	for i, policy := range c.SecurityHeaders {
+		if len(policy.Domains) == 0 {
+			return fmt.Errorf("router.security_headers[%d].domains must not be empty", i)
+		}
+		for _, domain := range policy.Domains {
+			if domain != "*" && (domain == "" || domain == "*." || strings.Contains(strings.TrimPrefix(domain, "*."), "*")) {
+				return fmt.Errorf("router.security_headers[%d].domains contains an invalid domain: %q", i, domain)
+			}
+		}
+		if policy.HSTS.MaxAge < 0 {
+			return fmt.Errorf("router.security_headers[%d].hsts.max_age must not be negative", i)
+		}
+		switch policy.FrameOptions {
+		case "", FrameOptionsDeny, FrameOptionsSameOrigin:
+		default:
+			return fmt.Errorf("router.security_headers[%d].frame_options must be %s or %s", i, FrameOptionsDeny, FrameOptionsSameOrigin)
+		}
+	}
+
+	for i, rule := range c.HeaderRules {
+		for _, domain := range rule.Domains {
+			if domain != "*" && (domain == "" || domain == "*." || strings.Contains(strings.TrimPrefix(domain, "*."), "*")) {
+				return fmt.Errorf("router.header_rules[%d].domains contains an invalid domain: %q", i, domain)
+			}
+		}
+		for _, route := range rule.Routes {
+			if route == "" {
+				return fmt.Errorf("router.header_rules[%d].routes must not contain empty values", i)
+			}
+		}
+		for _, actions := range []HeaderRuleActions{rule.Request, rule.Response} {
+			names := append([]string(nil), actions.Remove...)
+			for _, header := range actions.Set {
+				names = append(names, header.Name)
+			}
+			for _, header := range actions.Add {
+				names = append(names, header.Name)
+			}
+			for _, name := range names {
+				if name == "" {
+					return fmt.Errorf("router.header_rules[%d] must not contain headers without a name", i)
+				}
+				if strings.EqualFold(name, "Host") {
+					return fmt.Errorf("router.header_rules[%d] must not change the Host header", i)
+				}
+			}
+		}
+	}
+
+	for i, policy := range c.PrunePolicies {
+		if len(policy.Domains) == 0 {
+			return fmt.Errorf("router.prune_policies[%d].domains must not be empty", i)
+		}
+		for _, domain := range policy.Domains {
+			if domain != "*" && (domain == "" || domain == "*." || strings.Contains(strings.TrimPrefix(domain, "*."), "*")) {
+				return fmt.Errorf("router.prune_policies[%d].domains contains an invalid domain: %q", i, domain)
+			}
+		}
+		switch policy.Policy {
+		case PrunePolicyStale, PrunePolicyNever:
+		case PrunePolicyQuorum:
+			if policy.Quorum < 1 {
+				return fmt.Errorf("router.prune_policies[%d].quorum must be at least 1", i)
+			}
+			if len(c.Nats) == 0 {
+				return fmt.Errorf("router.prune_policies[%d] requires router.nats to reach a quorum", i)
+			}
+		case PrunePolicyGracePeriod:
+			if policy.GracePeriod <= 0 {
+				return fmt.Errorf("router.prune_policies[%d].grace_period must be greater than zero", i)
+			}
+		default:
+			return fmt.Errorf("router.prune_policies[%d].policy must be one of %s, %s, %s or %s", i, PrunePolicyStale, PrunePolicyNever, PrunePolicyQuorum, PrunePolicyGracePeriod)
+		}
+	}
+
+	if c.LoadShedding.MaxInFlightRequests < 0 {
+		return fmt.Errorf("router.load_shedding.max_in_flight_requests must not be negative")
+	}
+	if c.LoadShedding.MaxInFlightRequests > 0 && c.LoadShedding.RetryAfter < time.Second {
+		return fmt.Errorf("router.load_shedding.retry_after must be at least one second")
+	}
+
+	if c.TrafficMirroring.MaxInFlightRequests < 0 {
+		return fmt.Errorf("router.traffic_mirroring.max_in_flight_requests must not be negative")
+	}
+	if c.TrafficMirroring.MaxBodyBytes < 0 {
+		return fmt.Errorf("router.traffic_mirroring.max_body_bytes must not be negative")
+	}
+	if c.TrafficMirroring.Timeout <= 0 {
+		return fmt.Errorf("router.traffic_mirroring.timeout must be greater than zero")
+	}
+
+	if c.PerRouteMetrics.Enabled {
+		if c.PerRouteMetrics.TopN <= 0 {
+			return fmt.Errorf("router.per_route_metrics.top_n must be greater than zero")
+		}
+		if c.PerRouteMetrics.MaxRoutes < c.PerRouteMetrics.TopN {
+			return fmt.Errorf("router.per_route_metrics.max_routes must not be less than router.per_route_metrics.top_n")
+		}
+		if c.PerRouteMetrics.Window <= 0 {
+			return fmt.Errorf("router.per_route_metrics.window must be greater than zero")
+		}
+	}
+
+	if c.AccessLog.BufferSize <= 0 {
+		errMsg := fmt.Sprintf("Invalid access log buffer size: %d. Must be greater than zero", c.AccessLog.BufferSize)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.AccessLog.FlushInterval < 0 {
+		errMsg := fmt.Sprintf("Invalid access log flush interval: %s", c.AccessLog.FlushInterval)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.AccessLog.Format != AccessLogFormatText && c.AccessLog.Format != AccessLogFormatJSON {
+		return fmt.Errorf("Invalid access log format: %s. Must be %s or %s", c.AccessLog.Format, AccessLogFormatText, AccessLogFormatJSON)
+	}
+
+	if c.AccessLog.Template != "" && c.AccessLog.Format != AccessLogFormatText {
+		return fmt.Errorf("Invalid access log config: template requires the %s format", AccessLogFormatText)
+	}
+
+	if c.AccessLog.Syslog.Address != "" {
+		if _, _, err := net.SplitHostPort(c.AccessLog.Syslog.Address); err != nil {
+			return fmt.Errorf("Invalid access log syslog address: %s", err)
+		}
+		if c.AccessLog.Syslog.BufferSize <= 0 {
+			return fmt.Errorf("Invalid access log syslog buffer size: %d. Must be greater than zero", c.AccessLog.Syslog.BufferSize)
+		}
+	}
+
+	if kafka := c.AccessLog.Kafka; len(kafka.Brokers) > 0 {
+		if kafka.Topic == "" {
+			return fmt.Errorf("Invalid access log kafka config: topic is required")
+		}
+		for _, broker := range kafka.Brokers {
+			if _, _, err := net.SplitHostPort(broker); err != nil {
+				return fmt.Errorf("Invalid access log kafka broker: %s", err)
+			}
+		}
+		switch kafka.SASL.Mechanism {
+		case "":
+		case KafkaSASLMechanismPlain, KafkaSASLMechanismSCRAMSHA256, KafkaSASLMechanismSCRAMSHA512:
+			if kafka.SASL.Username == "" {
+				return fmt.Errorf("Invalid access log kafka config: sasl.username is required")
+			}
+		default:
+			return fmt.Errorf("Invalid access log kafka SASL mechanism: %s. Must be %s, %s or %s", kafka.SASL.Mechanism, KafkaSASLMechanismPlain, KafkaSASLMechanismSCRAMSHA256, KafkaSASLMechanismSCRAMSHA512)
+		}
+		if kafka.BatchSize <= 0 || kafka.BufferSize <= 0 || kafka.FlushInterval <= 0 {
+			return fmt.Errorf("Invalid access log kafka config: batch_size, buffer_size and flush_interval must be greater than zero")
+		}
+	}
+
+	validGetRequestBodyPolicy := false
+	for _, p := range AllowedGetRequestBodyPolicies {
+		if c.GetRequestBodyPolicy == p {
+			validGetRequestBodyPolicy = true
+			break
+		}
+	}
+	if !validGetRequestBodyPolicy {
+		errMsg := fmt.Sprintf("Invalid GET request body policy: %s. Allowed values are %s", c.GetRequestBodyPolicy, AllowedGetRequestBodyPolicies)
+		return fmt.Errorf(errMsg)
+	}
+
+	validHTTP10Policy := false
+	for _, p := range AllowedHTTP10Policies {
+		if c.HTTP10Policy == p {
+			validHTTP10Policy = true
+			break
+		}
+	}
+	if !validHTTP10Policy {
+		errMsg := fmt.Sprintf("Invalid HTTP/1.0 policy: %s. Allowed values are %s", c.HTTP10Policy, AllowedHTTP10Policies)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.HTTP10Policy == HTTP10_ROUTE && c.HTTP10DefaultHost == "" {
+		return fmt.Errorf("Expected an HTTP/1.0 default host; HTTP/1.0 policy set to route and none provided.")
+	}
+
+	if c.FailedEndpointCooldown < 0 {
+		errMsg := fmt.Sprintf("Invalid failed endpoint cooldown: %s", c.FailedEndpointCooldown)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.IdleEndpointPruneThreshold < 0 {
+		errMsg := fmt.Sprintf("Invalid idle endpoint prune threshold: %s", c.IdleEndpointPruneThreshold)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.IdleEndpointPruneThreshold > 0 && c.IdleEndpointHealthCheckTimeout <= 0 {
+		errMsg := fmt.Sprintf("Invalid idle endpoint health check timeout: %s. Must be greater than zero", c.IdleEndpointHealthCheckTimeout)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.HealthCheck.Enabled {
+		if c.HealthCheck.Type != HEALTH_CHECK_HTTP && c.HealthCheck.Type != HEALTH_CHECK_TCP {
+			return fmt.Errorf("router.health_check.type must be one of 'http' or 'tcp'")
+		}
+		if c.HealthCheck.Type == HEALTH_CHECK_HTTP && !strings.HasPrefix(c.HealthCheck.Path, "/") {
+			return fmt.Errorf("router.health_check.path must start with /")
+		}
+		if c.HealthCheck.Interval <= 0 || c.HealthCheck.Timeout <= 0 {
+			return fmt.Errorf("router.health_check.interval and router.health_check.timeout must be greater than zero")
+		}
+		if c.HealthCheck.HealthyThreshold <= 0 || c.HealthCheck.UnhealthyThreshold <= 0 {
+			return fmt.Errorf("router.health_check.healthy_threshold and router.health_check.unhealthy_threshold must be greater than zero")
+		}
+	}
+
+	if c.OutlierDetection.Enabled {
+		if c.OutlierDetection.ConsecutiveFailures <= 0 {
+			return fmt.Errorf("router.outlier_detection.consecutive_failures must be greater than zero")
+		}
+		if c.OutlierDetection.BaseEjectionTime <= 0 {
+			return fmt.Errorf("router.outlier_detection.base_ejection_time must be greater than zero")
+		}
+		if c.OutlierDetection.MaxEjectionTime < c.OutlierDetection.BaseEjectionTime {
+			return fmt.Errorf("router.outlier_detection.max_ejection_time must not be less than router.outlier_detection.base_ejection_time")
+		}
+	}
+
+	if c.CircuitBreaker.Enabled {
+		if c.CircuitBreaker.ConsecutiveFailures <= 0 {
+			return fmt.Errorf("router.circuit_breaker.consecutive_failures must be greater than zero")
+		}
+		if c.CircuitBreaker.OpenTimeout <= 0 {
+			return fmt.Errorf("router.circuit_breaker.open_timeout must be greater than zero")
+		}
+		if c.CircuitBreaker.HalfOpenMaxRequests <= 0 || c.CircuitBreaker.SuccessThreshold <= 0 {
+			return fmt.Errorf("router.circuit_breaker.half_open_max_requests and router.circuit_breaker.success_threshold must be greater than zero")
+		}
+	}
+
+	if c.RetryPolicy.MaxAttempts <= 0 {
+		return fmt.Errorf("router.retry_policy.max_attempts must be greater than zero")
+	}
+	if c.RetryPolicy.PerTryTimeout < 0 {
+		return fmt.Errorf("router.retry_policy.per_try_timeout must not be negative")
+	}
+	for _, code := range c.RetryOnStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("router.retry_on_status_codes must be HTTP status codes, got %d", code)
+		}
+		if !containsStatusCode(c.RetryPolicy.RetryableStatusCodes, code) {
+			c.RetryPolicy.RetryableStatusCodes = append(c.RetryPolicy.RetryableStatusCodes, code)
+		}
+	}
+	for _, code := range c.RetryPolicy.RetryableStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("router.retry_policy.retryable_status_codes must be HTTP status codes, got %d", code)
+		}
+	}
+	if c.RetryPolicy.Budget.Enabled {
+		if c.RetryPolicy.Budget.Percent < 0 || c.RetryPolicy.Budget.MinRetriesPerSecond < 0 {
+			return fmt.Errorf("router.retry_policy.budget.percent and router.retry_policy.budget.min_retries_per_second must not be negative")
+		}
+	}
+
+	if c.Streaming.FlushInterval < 0 {
+		return fmt.Errorf("router.streaming.flush_interval must not be negative")
+	}
+
+	if c.NatsMaxRegistrationRate < 0 {
+		errMsg := fmt.Sprintf("Invalid NATS max registration rate: %d. Must not be negative", c.NatsMaxRegistrationRate)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.NatsCredentialsFile != "" && c.NatsNkeySeedFile != "" {
+		return fmt.Errorf("router.nats_credentials_file and router.nats_nkey_seed_file are mutually exclusive")
+	}
+
+	if len(c.NatsSecondary) > 0 {
+		if len(c.Nats) == 0 {
+			return fmt.Errorf("router.nats_secondary requires router.nats")
+		}
+		if c.NatsFailoverAfter <= 0 {
+			return fmt.Errorf("router.nats_failover_after must be greater than zero")
+		}
+	}
+
+	if c.NatsJetStream.Stream != "" {
+		if !validJetStreamName(c.NatsJetStream.Stream) {
+			return fmt.Errorf("router.nats_jetstream.stream is not a valid stream name: %q", c.NatsJetStream.Stream)
+		}
+		if !validJetStreamName(c.NatsJetStream.Durable) {
+			return fmt.Errorf("router.nats_jetstream.durable is not a valid consumer name: %q", c.NatsJetStream.Durable)
+		}
+		if c.NatsJetStream.AckWait <= 0 {
+			return fmt.Errorf("router.nats_jetstream.ack_wait must be greater than zero")
+		}
+	}
+
+	if c.RoutingApi.FetchJitter < 0 || c.RoutingApi.FetchJitter >= 1 {
+		return fmt.Errorf("router.routing_api.fetch_jitter must be at least 0 and less than 1: %v", c.RoutingApi.FetchJitter)
+	}
+
+	if c.ListenerBacklog < 0 {
+		errMsg := fmt.Sprintf("Invalid listener backlog: %d. Must not be negative", c.ListenerBacklog)
+		return fmt.Errorf(errMsg)
+	}
+
+	if (c.ListenerBacklog > 0 || c.EnableReusePort) && runtime.GOOS != "linux" {
+		errMsg := fmt.Sprintf("listener_backlog and enable_reuse_port are not supported on %s", runtime.GOOS)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.Compression.Enabled {
+		if len(c.Compression.Encodings) == 0 {
+			return fmt.Errorf("router.compression.encodings must not be empty")
+		}
+		for _, encoding := range c.Compression.Encodings {
+			if encoding != CompressionEncodingBrotli && encoding != CompressionEncodingGzip {
+				return fmt.Errorf("router.compression.encodings must be one of %v", CompressionEncodings)
+			}
+		}
+		if c.Compression.MinSize < 0 {
+			return fmt.Errorf("router.compression.min_size must not be negative")
+		}
+	}
+
+	if c.HTMLInjection.Snippet != "" && c.HTMLInjection.MaxBodySize <= 0 {
+		errMsg := fmt.Sprintf("Invalid HTML injection max body size: %d. Must be greater than zero", c.HTMLInjection.MaxBodySize)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.Backends.ResponseHeaderTimeout < 0 {
+		errMsg := fmt.Sprintf("Invalid backend response header timeout: %s", c.Backends.ResponseHeaderTimeout)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.Backends.MaxRequestsPerConn < 0 {
+		errMsg := fmt.Sprintf("Invalid backend max requests per connection: %d", c.Backends.MaxRequestsPerConn)
+		return fmt.Errorf(errMsg)
+	}
+
+	var err error
+	c.Backends.MinTLSVersion, c.Backends.MaxTLSVersion, err = parseTLSVersions(
+		"router.backends.min_tls_version", c.Backends.MinTLSVersionString,
+		"router.backends.max_tls_version", c.Backends.MaxTLSVersionString,
+		0,
+	)
+	if err != nil {
+		return err
+	}
+
+	c.RouteServicesMinTLSVersion, c.RouteServicesMaxTLSVersion, err = parseTLSVersions(
+		"router.route_services_min_tls_version", c.RouteServicesMinTLSVersionString,
+		"router.route_services_max_tls_version", c.RouteServicesMaxTLSVersionString,
+		0,
+	)
+	if err != nil {
+		return err
+	}
+
+	switch c.Backends.ProxyProtocol {
+	case "", "v1", "v2":
+	default:
+		return fmt.Errorf("router.backends.proxy_protocol must be one of '', 'v1' or 'v2'")
+	}
+
+	if c.OCSPStapling.Enabled {
+		if c.OCSPStapling.RefreshInterval <= 0 {
+			return fmt.Errorf("router.ocsp_stapling.refresh_interval must be greater than zero")
+		}
+		if c.OCSPStapling.Timeout <= 0 {
+			return fmt.Errorf("router.ocsp_stapling.timeout must be greater than zero")
+		}
+	}
+
+	if c.ResponseCache.MaxSizeBytes < 0 {
+		errMsg := fmt.Sprintf("Invalid response cache max size: %d. Must not be negative", c.ResponseCache.MaxSizeBytes)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.ResponseCache.DiskPath != "" {
+		if c.ResponseCache.MaxSizeBytes == 0 {
+			return fmt.Errorf("router.response_cache.disk_path requires router.response_cache.max_size_bytes to be set")
+		}
+		if c.ResponseCache.DiskMaxSizeBytes <= 0 {
+			return fmt.Errorf("router.response_cache.disk_max_size_bytes must be greater than zero when disk_path is set")
+		}
+	}
+
+	c.TrustedProxyNets = nil
+	for _, cidr := range c.TrustedProxyCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			errMsg := fmt.Sprintf("Invalid trusted proxy CIDR: %s", cidr)
+			return fmt.Errorf(errMsg)
+		}
+		c.TrustedProxyNets = append(c.TrustedProxyNets, ipNet)
+	}
+
+	for _, header := range c.EmitForwardedHeaders {
+		if header != ForwardedHeaderXForwarded && header != ForwardedHeaderForwarded {
+			return fmt.Errorf("router.emit_forwarded_headers must be one of %v", ForwardedHeaders)
+		}
+	}
+
+	if c.TerminatingProxy.Enabled {
+		if len(c.TerminatingProxy.TrustedCIDRs) == 0 && len(c.TrustedProxyCIDRs) == 0 {
+			return fmt.Errorf("Terminating proxy is enabled but no trusted CIDRs are configured")
+		}
+		if c.TerminatingProxy.SchemeHeader == "" {
+			return fmt.Errorf("Terminating proxy is enabled but no scheme header is configured")
+		}
+		if c.TerminatingProxy.ForwardedHeader != ForwardedHeaderXForwarded && c.TerminatingProxy.ForwardedHeader != ForwardedHeaderForwarded {
+			return fmt.Errorf("router.terminating_proxy.forwarded_header must be one of %v", ForwardedHeaders)
+		}
+		for _, cidr := range c.TerminatingProxy.TrustedCIDRs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				errMsg := fmt.Sprintf("Invalid terminating proxy trusted CIDR: %s", cidr)
+				return fmt.Errorf(errMsg)
+			}
+			c.TrustedProxyNets = append(c.TrustedProxyNets, ipNet)
+		}
+	}
+
+	c.ClientIPAccess.AllowedNets = nil
+	for _, cidr := range c.ClientIPAccess.AllowedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("router.client_ip_access.allowed_cidrs contains an invalid CIDR: %s", cidr)
+		}
+		c.ClientIPAccess.AllowedNets = append(c.ClientIPAccess.AllowedNets, ipNet)
+	}
+	c.ClientIPAccess.DeniedNets = nil
+	for _, cidr := range c.ClientIPAccess.DeniedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("router.client_ip_access.denied_cidrs contains an invalid CIDR: %s", cidr)
+		}
+		c.ClientIPAccess.DeniedNets = append(c.ClientIPAccess.DeniedNets, ipNet)
+	}
+
+	if c.DrainRequestBodyOnError && c.DrainRequestBodyOnErrorMaxBytes <= 0 {
+		errMsg := fmt.Sprintf("Invalid drain request body on error max bytes: %d. Must be greater than zero", c.DrainRequestBodyOnErrorMaxBytes)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.RouteSnapshot.Path != "" && c.RouteSnapshot.Interval <= 0 {
+		errMsg := fmt.Sprintf("Invalid route snapshot interval: %s. Must be greater than zero", c.RouteSnapshot.Interval)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.RouteSnapshot.MaxAge < 0 {
+		errMsg := fmt.Sprintf("Invalid route snapshot max age: %s. Must not be negative", c.RouteSnapshot.MaxAge)
+		return fmt.Errorf(errMsg)
+	}
+
+	if c.Consul.Address != "" {
+		u, err := url.Parse(c.Consul.Address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("router.consul.address must be an http or https URL: %q", c.Consul.Address)
+		}
+		if c.Consul.RouteTagPrefix == "" {
+			return fmt.Errorf("router.consul.route_tag_prefix must not be empty")
+		}
+		if c.Consul.RetryInterval <= 0 {
+			return fmt.Errorf("router.consul.retry_interval must be greater than zero")
+		}
+	}
+
+	if c.Kubernetes.Enabled {
+		if c.Kubernetes.APIServer != "" {
+			u, err := url.Parse(c.Kubernetes.APIServer)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("router.kubernetes.api_server must be an http or https URL: %q", c.Kubernetes.APIServer)
+			}
+		}
+		if c.Kubernetes.RouteAnnotation == "" {
+			return fmt.Errorf("router.kubernetes.route_annotation must not be empty")
+		}
+		if c.Kubernetes.RetryInterval <= 0 {
+			return fmt.Errorf("router.kubernetes.retry_interval must be greater than zero")
+		}
+	}
+
 	if err := c.buildCertPool(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// validJetStreamName reports whether name can name a JetStream stream or
+// consumer, which are tokens of the subjects of the JetStream API.
+func validJetStreamName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ".*> \t\r\n")
+}
+
+// supportsHTTP2 reports whether the cipher suites include one of those that
+// HTTP/2 requires TLS 1.2 connections to support.
+// parseTLSVersions converts the minimum and maximum TLS versions of the
+// named fields. An empty minimum becomes defaultMin, and an empty maximum
+// becomes zero, which leaves the maximum to crypto/tls.
+func parseTLSVersions(minField, minVersion, maxField, maxVersion string, defaultMin uint16) (uint16, uint16, error) {
+	min, ok := tlsVersions[minVersion]
+	if !ok {
+		return 0, 0, fmt.Errorf(`%s should be one of "", "TLSv1.3", "TLSv1.2", "TLSv1.1", "TLSv1.0"`, minField)
+	}
+	if minVersion == "" {
+		min = defaultMin
+	}
+
+	max, ok := tlsVersions[maxVersion]
+	if !ok {
+		return 0, 0, fmt.Errorf(`%s should be one of "", "TLSv1.3", "TLSv1.2", "TLSv1.1", "TLSv1.0"`, maxField)
+	}
+	if max != 0 && min > max {
+		return 0, 0, fmt.Errorf("%s must not be lower than %s", maxField, minField)
+	}
+	return min, max, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"":        0,
+	"TLSv1.0": tls.VersionTLS10,
+	"TLSv1.1": tls.VersionTLS11,
+	"TLSv1.2": tls.VersionTLS12,
+	"TLSv1.3": tls.VersionTLS13,
+}
+
+func supportsHTTP2(cipherSuites []uint16) bool {
+	for _, suite := range cipherSuites {
+		if suite == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || suite == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Config) processCipherSuites() ([]uint16, error) {
 	cipherMap := map[string]uint16{
 		"RC4-SHA":                                 0x0005, // openssl formatted values
@@ -494,8 +2140,18 @@ func convertCipherStringToInt(cipherStrs []string, cipherMap map[string]uint16)
 }
 
 func (c *Config) NatsServers() []string {
+	return natsServers(c.Nats)
+}
+
+// NatsSecondaryServers returns the URLs of the servers of the secondary NATS
+// cluster.
+func (c *Config) NatsSecondaryServers() []string {
+	return natsServers(c.NatsSecondary)
+}
+
+func natsServers(nats []NatsConfig) []string {
 	var natsServers []string
-	for _, info := range c.Nats {
+	for _, info := range nats {
 		uri := url.URL{
 			Scheme: "nats",
 			User:   url.UserPassword(info.User, info.Pass),
@@ -511,6 +2167,33 @@ func (c *Config) RoutingApiEnabled() bool {
 	return (c.RoutingApi.Uri != "") && (c.RoutingApi.Port != 0)
 }
 
+// ReloadableChanges returns the settings that gorouter applies on SIGHUP
+// without a restart and that differ between c and n: the endpoint timeouts,
+// the log level, the cipher suites, the rate limit and the header rules.
+// Changes to other settings take effect on the next restart.
+func (c *Config) ReloadableChanges(n *Config) []string {
+	var changed []string
+	if c.EndpointTimeout != n.EndpointTimeout {
+		changed = append(changed, "endpoint_timeout")
+	}
+	if c.MaxRouteEndpointTimeout != n.MaxRouteEndpointTimeout {
+		changed = append(changed, "max_route_endpoint_timeout")
+	}
+	if c.Logging.Level != n.Logging.Level {
+		changed = append(changed, "logging.level")
+	}
+	if !reflect.DeepEqual(c.CipherSuites, n.CipherSuites) {
+		changed = append(changed, "cipher_suites")
+	}
+	if c.RateLimit != n.RateLimit {
+		changed = append(changed, "rate_limit")
+	}
+	if !reflect.DeepEqual(c.HeaderRules, n.HeaderRules) {
+		changed = append(changed, "header_rules")
+	}
+	return changed
+}
+
 func (c *Config) Initialize(configYAML []byte) error {
 	c.Nats = []NatsConfig{}
 	return yaml.Unmarshal(configYAML, &c)
@@ -539,3 +2222,12 @@ func InitConfigFromFile(path string) (*Config, error) {
 
 	return c, nil
 }
+
+func containsStatusCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
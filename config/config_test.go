@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"net"
 	"strings"
 
 	yaml "gopkg.in/yaml.v2"
@@ -46,6 +47,280 @@ balancing_algorithm: least-connection
 				Expect(cfg.LoadBalance).To(Equal(LOAD_BALANCE_LC))
 			})
 
+			It("allows the least-latency load balance strategy", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				var b = []byte(`
+balancing_algorithm: least-latency
+`)
+				cfg.Initialize(b)
+				Expect(cfg.Process()).To(Succeed())
+				Expect(cfg.LoadBalance).To(Equal(LOAD_BALANCE_LL))
+			})
+
+			It("defaults active health checks to disabled", func() {
+				Expect(config.HealthCheck).To(Equal(HealthCheckConfig{
+					Type:               HEALTH_CHECK_HTTP,
+					Path:               "/",
+					Interval:           10 * time.Second,
+					Timeout:            2 * time.Second,
+					HealthyThreshold:   2,
+					UnhealthyThreshold: 3,
+				}))
+			})
+
+			It("sets active health checks", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				var b = []byte(`
+health_check:
+  enabled: true
+  type: tcp
+  interval: 5s
+  timeout: 1s
+  healthy_threshold: 1
+  unhealthy_threshold: 2
+`)
+				Expect(cfg.Initialize(b)).To(Succeed())
+				Expect(cfg.Process()).To(Succeed())
+				Expect(cfg.HealthCheck).To(Equal(HealthCheckConfig{
+					Enabled:            true,
+					Type:               HEALTH_CHECK_TCP,
+					Path:               "/",
+					Interval:           5 * time.Second,
+					Timeout:            1 * time.Second,
+					HealthyThreshold:   1,
+					UnhealthyThreshold: 2,
+				}))
+			})
+
+			DescribeTable("invalid active health checks",
+				func(yaml, message string) {
+					cfg, err := DefaultConfig()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(cfg.Initialize([]byte(yaml))).To(Succeed())
+					Expect(cfg.Process()).To(MatchError(ContainSubstring(message)))
+				},
+				Entry("with an unknown type", "health_check: {enabled: true, type: udp}", "router.health_check.type"),
+				Entry("with a relative path", "health_check: {enabled: true, path: health}", "router.health_check.path"),
+				Entry("with a zero interval", "health_check: {enabled: true, interval: 0s}", "router.health_check.interval"),
+				Entry("with a zero threshold", "health_check: {enabled: true, unhealthy_threshold: 0}", "router.health_check.healthy_threshold"),
+			)
+
+			It("defaults outlier detection to disabled", func() {
+				Expect(config.OutlierDetection).To(Equal(OutlierDetectionConfig{
+					ConsecutiveFailures: 5,
+					BaseEjectionTime:    30 * time.Second,
+					MaxEjectionTime:     5 * time.Minute,
+				}))
+			})
+
+			DescribeTable("invalid outlier detection",
+				func(yaml, message string) {
+					cfg, err := DefaultConfig()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(cfg.Initialize([]byte(yaml))).To(Succeed())
+					Expect(cfg.Process()).To(MatchError(ContainSubstring(message)))
+				},
+				Entry("with zero consecutive failures", "outlier_detection: {enabled: true, consecutive_failures: 0}", "router.outlier_detection.consecutive_failures"),
+				Entry("with a zero ejection time", "outlier_detection: {enabled: true, base_ejection_time: 0s}", "router.outlier_detection.base_ejection_time"),
+				Entry("with a max ejection time below the base", "outlier_detection: {enabled: true, base_ejection_time: 1m, max_ejection_time: 30s}", "router.outlier_detection.max_ejection_time"),
+			)
+
+			It("defaults circuit breaking to disabled", func() {
+				Expect(config.CircuitBreaker).To(Equal(CircuitBreakerConfig{
+					ConsecutiveFailures: 5,
+					OpenTimeout:         30 * time.Second,
+					HalfOpenMaxRequests: 1,
+					SuccessThreshold:    2,
+				}))
+			})
+
+			DescribeTable("invalid circuit breaking",
+				func(yaml, message string) {
+					cfg, err := DefaultConfig()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(cfg.Initialize([]byte(yaml))).To(Succeed())
+					Expect(cfg.Process()).To(MatchError(ContainSubstring(message)))
+				},
+				Entry("with zero consecutive failures", "circuit_breaker: {enabled: true, consecutive_failures: 0}", "router.circuit_breaker.consecutive_failures"),
+				Entry("with a zero open timeout", "circuit_breaker: {enabled: true, open_timeout: 0s}", "router.circuit_breaker.open_timeout"),
+				Entry("with zero half-open requests", "circuit_breaker: {enabled: true, half_open_max_requests: 0}", "router.circuit_breaker.half_open_max_requests"),
+				Entry("with a zero success threshold", "circuit_breaker: {enabled: true, success_threshold: 0}", "router.circuit_breaker.success_threshold"),
+			)
+
+			It("defaults the maximum route endpoint timeout to the endpoint timeout", func() {
+				Expect(config.MaxRouteEndpointTimeout).To(Equal(config.EndpointTimeout))
+			})
+
+			It("sets the maximum route endpoint timeout", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.Initialize([]byte("max_route_endpoint_timeout: 10m"))).To(Succeed())
+				Expect(cfg.Process()).To(Succeed())
+				Expect(cfg.MaxRouteEndpointTimeout).To(Equal(10 * time.Minute))
+			})
+
+			It("rejects a negative maximum route endpoint timeout", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.Initialize([]byte("max_route_endpoint_timeout: -1s"))).To(Succeed())
+				Expect(cfg.Process()).To(MatchError(ContainSubstring("router.max_route_endpoint_timeout")))
+			})
+
+			It("defaults the retry policy", func() {
+				Expect(config.RetryPolicy).To(Equal(RetryPolicyConfig{
+					MaxAttempts:           3,
+					IdempotentMethodsOnly: true,
+					Budget: RetryBudgetConfig{
+						Percent:             20,
+						MinRetriesPerSecond: 10,
+					},
+				}))
+			})
+
+			It("sets the retry policy", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				var b = []byte(`
+retry_policy:
+  max_attempts: 2
+  per_try_timeout: 5s
+  retryable_status_codes: [502, 503]
+  idempotent_methods_only: false
+  budget:
+    enabled: true
+    percent: 10
+    min_retries_per_second: 1
+`)
+				Expect(cfg.Initialize(b)).To(Succeed())
+				Expect(cfg.Process()).To(Succeed())
+				Expect(cfg.RetryPolicy).To(Equal(RetryPolicyConfig{
+					MaxAttempts:          2,
+					PerTryTimeout:        5 * time.Second,
+					RetryableStatusCodes: []int{502, 503},
+					Budget: RetryBudgetConfig{
+						Enabled:             true,
+						Percent:             10,
+						MinRetriesPerSecond: 1,
+					},
+				}))
+			})
+
+			It("adds the status codes to retry on to the retry policy", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				var b = []byte(`
+retry_on_status_codes: [502, 503]
+retry_policy:
+  max_attempts: 3
+  retryable_status_codes: [503, 504]
+`)
+				Expect(cfg.Initialize(b)).To(Succeed())
+				Expect(cfg.Process()).To(Succeed())
+				Expect(cfg.Process()).To(Succeed())
+				Expect(cfg.RetryPolicy.RetryableStatusCodes).To(ConsistOf(502, 503, 504))
+			})
+
+			It("rejects status codes to retry on that are not HTTP status codes", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.Initialize([]byte("retry_on_status_codes: [42]"))).To(Succeed())
+				Expect(cfg.Process()).To(MatchError(ContainSubstring("router.retry_on_status_codes")))
+			})
+
+			DescribeTable("invalid retry policies",
+				func(yaml, message string) {
+					cfg, err := DefaultConfig()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(cfg.Initialize([]byte(yaml))).To(Succeed())
+					Expect(cfg.Process()).To(MatchError(ContainSubstring(message)))
+				},
+				Entry("with zero attempts", "retry_policy: {max_attempts: 0}", "router.retry_policy.max_attempts"),
+				Entry("with a negative per-try timeout", "retry_policy: {max_attempts: 1, per_try_timeout: -1s}", "router.retry_policy.per_try_timeout"),
+				Entry("with an invalid status code", "retry_policy: {max_attempts: 1, retryable_status_codes: [1000]}", "router.retry_policy.retryable_status_codes"),
+				Entry("with a negative budget", "retry_policy: {max_attempts: 1, budget: {enabled: true, percent: -1}}", "router.retry_policy.budget.percent"),
+			)
+
+			It("sets the flush interval of streaming routes", func() {
+				Expect(config.Streaming.FlushInterval).To(BeZero())
+
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.Initialize([]byte("streaming: {flush_interval: 100ms}"))).To(Succeed())
+				Expect(cfg.Process()).To(Succeed())
+				Expect(cfg.Streaming.FlushInterval).To(Equal(100 * time.Millisecond))
+			})
+
+			It("rejects a negative flush interval of streaming routes", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.Initialize([]byte("streaming: {flush_interval: -1s}"))).To(Succeed())
+				Expect(cfg.Process()).To(MatchError(ContainSubstring("router.streaming.flush_interval")))
+			})
+
+			It("requires an availability zone to prefer local endpoints", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				var b = []byte(`
+prefer_local_availability_zone: true
+`)
+				Expect(cfg.Initialize(b)).To(Succeed())
+				Expect(cfg.Process()).To(MatchError("router.availability_zone must be set if router.prefer_local_availability_zone is set to true"))
+			})
+
+			It("sets the availability zone to prefer", func() {
+				cfg, err := DefaultConfig()
+				Expect(err).ToNot(HaveOccurred())
+				var b = []byte(`
+availability_zone: z1
+prefer_local_availability_zone: true
+`)
+				Expect(cfg.Initialize(b)).To(Succeed())
+				Expect(cfg.Process()).To(Succeed())
+				Expect(cfg.AvailabilityZone).To(Equal("z1"))
+				Expect(cfg.PreferLocalAvailabilityZone).To(BeTrue())
+			})
+
+			Context("when the load balance strategy is consistent-hash", func() {
+				It("sets the request attribute to hash on", func() {
+					cfg, err := DefaultConfig()
+					Expect(err).ToNot(HaveOccurred())
+					var b = []byte(`
+balancing_algorithm: consistent-hash
+consistent_hash:
+  header: X-Tenant
+`)
+					Expect(cfg.Initialize(b)).To(Succeed())
+					Expect(cfg.Process()).To(Succeed())
+					Expect(cfg.LoadBalance).To(Equal(LOAD_BALANCE_CH))
+					Expect(cfg.ConsistentHash).To(Equal(ConsistentHashConfig{Header: "X-Tenant"}))
+				})
+
+				It("requires a request attribute", func() {
+					cfg, err := DefaultConfig()
+					Expect(err).ToNot(HaveOccurred())
+					var b = []byte(`
+balancing_algorithm: consistent-hash
+`)
+					Expect(cfg.Initialize(b)).To(Succeed())
+					Expect(cfg.Process()).To(MatchError(ContainSubstring("router.consistent_hash must set exactly one of")))
+				})
+
+				It("does not allow more than one request attribute", func() {
+					cfg, err := DefaultConfig()
+					Expect(err).ToNot(HaveOccurred())
+					var b = []byte(`
+balancing_algorithm: consistent-hash
+consistent_hash:
+  cookie: session
+  client_ip: true
+`)
+					Expect(cfg.Initialize(b)).To(Succeed())
+					Expect(cfg.Process()).To(MatchError(ContainSubstring("router.consistent_hash must set exactly one of")))
+				})
+			})
+
 			It("does not allow an invalid load balance strategy", func() {
 				cfg, err := DefaultConfig()
 				Expect(err).ToNot(HaveOccurred())
@@ -53,7 +328,7 @@ balancing_algorithm: least-connection
 balancing_algorithm: foo-bar
 `)
 				cfg.Initialize(b)
-				Expect(cfg.Process()).To(MatchError("Invalid load balancing algorithm foo-bar. Allowed values are [round-robin least-connection]"))
+				Expect(cfg.Process()).To(MatchError("Invalid load balancing algorithm foo-bar. Allowed values are [round-robin least-connection least-latency consistent-hash]"))
 			})
 		})
 
@@ -134,6 +409,22 @@ suspend_pruning_if_nats_unavailable: true
 			})
 		})
 
+		Context("NATS health option", func() {
+			It("does not emit NATS health by default", func() {
+				Expect(config.EmitNatsHealth).To(BeFalse())
+			})
+
+			It("sets emit_nats_health", func() {
+				var b = []byte(`
+emit_nats_health: true
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.EmitNatsHealth).To(BeTrue())
+			})
+		})
+
 		It("sets default logging configs", func() {
 			Expect(config.Logging.Syslog).To(Equal(""))
 			Expect(config.Logging.Level).To(Equal("debug"))
@@ -146,6 +437,9 @@ suspend_pruning_if_nats_unavailable: true
 			// access entries not present in config
 			Expect(config.AccessLog.File).To(Equal(""))
 			Expect(config.AccessLog.EnableStreaming).To(BeFalse())
+			Expect(config.AccessLog.Format).To(Equal("text"))
+			Expect(config.AccessLog.BufferSize).To(Equal(1024))
+			Expect(config.AccessLog.FlushInterval).To(BeZero())
 		})
 
 		It("sets default sharding mode config", func() {
@@ -200,6 +494,121 @@ access_log:
 			Expect(config.AccessLog.EnableStreaming).To(BeTrue())
 		})
 
+		It("defaults the prometheus config", func() {
+			Expect(config.Prometheus.Enabled).To(BeFalse())
+			Expect(config.Prometheus.Port).To(BeZero())
+			Expect(config.Prometheus.Path).To(Equal("/metrics"))
+		})
+
+		It("sets the prometheus config", func() {
+			var b = []byte(`
+prometheus:
+  enabled: true
+  port: 9090
+  path: /prom
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Prometheus.Enabled).To(BeTrue())
+			Expect(config.Prometheus.Port).To(Equal(uint16(9090)))
+			Expect(config.Prometheus.Path).To(Equal("/prom"))
+		})
+
+		It("sets access log buffering config", func() {
+			var b = []byte(`
+access_log:
+  file: "/var/vcap/sys/log/gorouter/access.log"
+  buffer_size: 4096
+  flush_interval: 1s
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.AccessLog.BufferSize).To(Equal(4096))
+			Expect(config.AccessLog.FlushInterval).To(Equal(1 * time.Second))
+		})
+
+		It("sets the access log syslog config", func() {
+			Expect(config.AccessLog.Syslog.AppName).To(Equal("gorouter"))
+			Expect(config.AccessLog.Syslog.BufferSize).To(Equal(10000))
+
+			var b = []byte(`
+access_log:
+  syslog:
+    address: syslog.example.com:6514
+    tls: true
+    ca_certs: some-ca
+    app_name: router
+    buffer_size: 500
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.AccessLog.Syslog.Address).To(Equal("syslog.example.com:6514"))
+			Expect(config.AccessLog.Syslog.TLS).To(BeTrue())
+			Expect(config.AccessLog.Syslog.CACerts).To(Equal("some-ca"))
+			Expect(config.AccessLog.Syslog.AppName).To(Equal("router"))
+			Expect(config.AccessLog.Syslog.BufferSize).To(Equal(500))
+		})
+
+		It("sets the access log kafka config", func() {
+			Expect(config.AccessLog.Kafka.ClientID).To(Equal("gorouter"))
+			Expect(config.AccessLog.Kafka.BatchSize).To(Equal(500))
+			Expect(config.AccessLog.Kafka.FlushInterval).To(Equal(time.Second))
+			Expect(config.AccessLog.Kafka.BufferSize).To(Equal(10000))
+
+			var b = []byte(`
+access_log:
+  kafka:
+    brokers: [kafka-0.example.com:9093, kafka-1.example.com:9093]
+    topic: access-logs
+    tls: true
+    sasl:
+      mechanism: SCRAM-SHA-512
+      username: router
+      password: secret
+    batch_size: 100
+    flush_interval: 5s
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.AccessLog.Kafka.Brokers).To(Equal([]string{"kafka-0.example.com:9093", "kafka-1.example.com:9093"}))
+			Expect(config.AccessLog.Kafka.Topic).To(Equal("access-logs"))
+			Expect(config.AccessLog.Kafka.TLS).To(BeTrue())
+			Expect(config.AccessLog.Kafka.SASL.Mechanism).To(Equal("SCRAM-SHA-512"))
+			Expect(config.AccessLog.Kafka.SASL.Username).To(Equal("router"))
+			Expect(config.AccessLog.Kafka.SASL.Password).To(Equal("secret"))
+			Expect(config.AccessLog.Kafka.BatchSize).To(Equal(100))
+			Expect(config.AccessLog.Kafka.FlushInterval).To(Equal(5 * time.Second))
+			Expect(config.AccessLog.Kafka.BufferSize).To(Equal(10000))
+			Expect(config.Process()).To(Succeed())
+		})
+
+		It("sets the access log template", func() {
+			var b = []byte(`
+access_log:
+  template: '$host "$method $request_uri" $status_code $http_x_b3_traceid'
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.AccessLog.Template).To(Equal(`$host "$method $request_uri" $status_code $http_x_b3_traceid`))
+			Expect(config.Process()).To(Succeed())
+		})
+
+		It("sets the access log format", func() {
+			var b = []byte(`
+access_log:
+  format: json
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.AccessLog.Format).To(Equal("json"))
+		})
+
 		It("sets logging config", func() {
 			var b = []byte(`
 logging:
@@ -277,6 +686,34 @@ routing_api:
 			Expect(config.RoutingApi.AuthDisabled).To(BeTrue())
 		})
 
+		It("defaults the Routing Api fetch jitter", func() {
+			var b = []byte(`
+routing_api:
+  uri: http://bob.url/token
+  port: 1234
+`)
+
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Process()).To(Succeed())
+			Expect(config.RoutingApi.FetchJitter).To(Equal(0.2))
+		})
+
+		It("returns a meaningful error when the Routing Api fetch jitter is out of range", func() {
+			var b = []byte(`
+routing_api:
+  uri: http://bob.url/token
+  port: 1234
+  fetch_jitter: 1.5
+`)
+
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Process()).To(MatchError("router.routing_api.fetch_jitter must be at least 0 and less than 1: 1.5"))
+		})
+
 		It("sets the OAuth config", func() {
 			var b = []byte(`
 oauth:
@@ -299,6 +736,28 @@ oauth:
 			Expect(config.OAuth.CACerts).To(Equal("ca-cert"))
 		})
 
+		It("fails closed when UAA cannot be reached by default", func() {
+			Expect(config.Process()).To(Succeed())
+			Expect(config.OAuth.FailureMode).To(Equal("fail_closed"))
+		})
+
+		It("sets the OAuth failure mode", func() {
+			var b = []byte("oauth:\n  failure_mode: fail_open")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Process()).To(Succeed())
+			Expect(config.OAuth.FailureMode).To(Equal("fail_open"))
+		})
+
+		It("returns a meaningful error for an unknown OAuth failure mode", func() {
+			var b = []byte("oauth:\n  failure_mode: ignore")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Process()).To(MatchError("router.oauth.failure_mode must be one of [fail_closed fail_open]"))
+		})
+
 		It("sets the SkipSSLValidation config", func() {
 			var b = []byte(`
 skip_ssl_validation: true
@@ -415,6 +874,32 @@ enable_proxy: true
 			Expect(config.Tracing.EnableZipkin).To(BeFalse())
 		})
 
+		It("sets Tracing.OTLP", func() {
+			var b = []byte(`
+tracing:
+  otlp:
+    enabled: true
+    endpoint: http://localhost:4318/v1/traces
+    service_name: router-z1
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Tracing.OTLP.Enabled).To(BeTrue())
+			Expect(config.Tracing.OTLP.Endpoint).To(Equal("http://localhost:4318/v1/traces"))
+			Expect(config.Tracing.OTLP.ServiceName).To(Equal("router-z1"))
+		})
+
+		It("defaults Tracing.OTLP", func() {
+			var b = []byte(``)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Tracing.OTLP.Enabled).To(BeFalse())
+			Expect(config.Tracing.OTLP.Endpoint).To(BeEmpty())
+			Expect(config.Tracing.OTLP.ServiceName).To(Equal("gorouter"))
+		})
+
 		It("sets the proxy forwarded proto header", func() {
 			var b = []byte("force_forwarded_proto_https: true")
 			config.Initialize(b)
@@ -497,86 +982,1489 @@ backends:
 			Expect(err).ToNot(HaveOccurred())
 			Expect(config.DisableHTTP).To(BeTrue())
 		})
-	})
 
-	Describe("Process", func() {
-		It("converts intervals to durations", func() {
+		It("defaults EnableHTTP2 to false", func() {
+			Expect(config.EnableHTTP2).To(BeFalse())
+			Expect(config.HTTP2MaxConcurrentStreams).To(Equal(uint32(100)))
+		})
+
+		It("sets EnableHTTP2 and HTTP2MaxConcurrentStreams", func() {
 			var b = []byte(`
-publish_start_message_interval: 1s
-prune_stale_droplets_interval: 2s
-droplet_stale_threshold: 30s
-publish_active_apps_interval: 4s
-start_response_delay_interval: 15s
-secure_cookies: true
-token_fetcher_retry_interval: 10s
+enable_http2: true
+http2_max_concurrent_streams: 250
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.EnableHTTP2).To(BeTrue())
+			Expect(config.HTTP2MaxConcurrentStreams).To(Equal(uint32(250)))
+		})
+
+		It("defaults EnableHTTP3 to false", func() {
+			Expect(config.EnableHTTP3).To(BeFalse())
+			Expect(config.HTTP3Port).To(Equal(uint16(0)))
+			Expect(config.HTTP3AltSvcMaxAge).To(Equal(24 * time.Hour))
+		})
+
+		It("sets EnableHTTP3, HTTP3Port and HTTP3AltSvcMaxAge", func() {
+			var b = []byte(`
+enable_http3: true
+http3_port: 8443
+http3_alt_svc_max_age: 1h
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.EnableHTTP3).To(BeTrue())
+			Expect(config.HTTP3Port).To(Equal(uint16(8443)))
+			Expect(config.HTTP3AltSvcMaxAge).To(Equal(time.Hour))
+		})
+
+		It("sets PreloadRoutesFile", func() {
+			var b = []byte("preload_routes_file: /var/vcap/data/gorouter/routes.json")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.PreloadRoutesFile).To(Equal("/var/vcap/data/gorouter/routes.json"))
+		})
+
+		It("sets RouteSnapshot", func() {
+			var b = []byte(`
+route_snapshot:
+  path: /var/vcap/data/gorouter/routes.json
+  interval: 10s
+  max_age: 1m
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.RouteSnapshot.Path).To(Equal("/var/vcap/data/gorouter/routes.json"))
+			Expect(config.RouteSnapshot.Interval).To(Equal(10 * time.Second))
+			Expect(config.RouteSnapshot.MaxAge).To(Equal(time.Minute))
+		})
+
+		It("defaults RouteSnapshot", func() {
+			Expect(config.RouteSnapshot.Path).To(BeEmpty())
+			Expect(config.RouteSnapshot.Interval).To(Equal(60 * time.Second))
+			Expect(config.RouteSnapshot.MaxAge).To(Equal(5 * time.Minute))
+		})
+
+		It("defaults GetRequestBodyPolicy to forward", func() {
+			Expect(config.GetRequestBodyPolicy).To(Equal("forward"))
+		})
+
+		It("sets GetRequestBodyPolicy", func() {
+			var b = []byte("get_request_body_policy: reject")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.GetRequestBodyPolicy).To(Equal("reject"))
+		})
+
+		It("defaults HTTP10Policy to reject", func() {
+			Expect(config.HTTP10Policy).To(Equal("reject"))
+			Expect(config.HTTP10DefaultHost).To(BeEmpty())
+		})
+
+		It("sets HTTP10Policy", func() {
+			var b = []byte(`
+http10_policy: route
+http10_default_host: default.example.com
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.HTTP10Policy).To(Equal("route"))
+			Expect(config.HTTP10DefaultHost).To(Equal("default.example.com"))
+		})
+
+		It("sets DrainRequestBodyOnError", func() {
+			var b = []byte(`
+drain_request_body_on_error: true
+drain_request_body_on_error_max_bytes: 2048
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.DrainRequestBodyOnError).To(BeTrue())
+			Expect(config.DrainRequestBodyOnErrorMaxBytes).To(Equal(int64(2048)))
+		})
+
+		It("defaults DrainRequestBodyOnError", func() {
+			Expect(config.DrainRequestBodyOnError).To(BeFalse())
+			Expect(config.DrainRequestBodyOnErrorMaxBytes).To(Equal(int64(1024 * 1024)))
+		})
+
+		It("sets FailedEndpointCooldown", func() {
+			var b = []byte("failed_endpoint_cooldown: 5s")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.FailedEndpointCooldown).To(Equal(5 * time.Second))
+		})
+
+		It("sets IdleEndpointPruneThreshold and IdleEndpointHealthCheckTimeout", func() {
+			var b = []byte("idle_endpoint_prune_threshold: 10m\nidle_endpoint_health_check_timeout: 2s")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.IdleEndpointPruneThreshold).To(Equal(10 * time.Minute))
+			Expect(config.IdleEndpointHealthCheckTimeout).To(Equal(2 * time.Second))
+		})
+
+		It("defaults IdleEndpointHealthCheckTimeout", func() {
+			Expect(config.IdleEndpointPruneThreshold).To(BeZero())
+			Expect(config.IdleEndpointHealthCheckTimeout).To(Equal(5 * time.Second))
+		})
+
+		It("sets ValidateRegistrationMessages", func() {
+			Expect(config.ValidateRegistrationMessages).To(BeFalse())
+
+			var b = []byte("validate_registration_messages: true")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ValidateRegistrationMessages).To(BeTrue())
+		})
+
+		It("sets ResponseCache", func() {
+			Expect(config.ResponseCache.MaxSizeBytes).To(BeZero())
+
+			var b = []byte("response_cache:\n  max_size_bytes: 1048576")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ResponseCache.MaxSizeBytes).To(Equal(int64(1048576)))
+		})
+
+		It("sets the ResponseCache disk tier", func() {
+			var b = []byte("response_cache:\n  max_size_bytes: 1048576\n  disk_path: /var/cache/gorouter\n  disk_max_size_bytes: 10485760")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ResponseCache.DiskPath).To(Equal("/var/cache/gorouter"))
+			Expect(config.ResponseCache.DiskMaxSizeBytes).To(Equal(int64(10485760)))
+		})
+
+		It("sets OCSPStapling", func() {
+			Expect(config.OCSPStapling.Enabled).To(BeFalse())
+			Expect(config.OCSPStapling.RefreshInterval).To(Equal(time.Hour))
+			Expect(config.OCSPStapling.Timeout).To(Equal(10 * time.Second))
+
+			var b = []byte("ocsp_stapling:\n  enabled: true\n  refresh_interval: 30m")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.OCSPStapling.Enabled).To(BeTrue())
+			Expect(config.OCSPStapling.RefreshInterval).To(Equal(30 * time.Minute))
+			Expect(config.OCSPStapling.Timeout).To(Equal(10 * time.Second))
+		})
+
+		It("sets PerRouteMetricsAllowlist", func() {
+			var b = []byte("per_route_metrics_allowlist:\n- foo.example.com\n- bar.example.com/path")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.PerRouteMetricsAllowlist).To(ConsistOf("foo.example.com", "bar.example.com/path"))
+		})
+
+		It("does not propagate the request timeout by default", func() {
+			Expect(config.PropagateTimeoutHeader).To(BeEmpty())
+		})
+
+		It("sets PropagateTimeoutHeader", func() {
+			var b = []byte("propagate_timeout_header: X-Request-Timeout-Ms")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.PropagateTimeoutHeader).To(Equal("X-Request-Timeout-Ms"))
+		})
+
+		It("sets RewriteRedirectLocation", func() {
+			var b = []byte("rewrite_redirect_location: true")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.RewriteRedirectLocation).To(BeTrue())
+		})
+
+		It("sets NatsMaxRegistrationRate", func() {
+			var b = []byte("nats_max_registration_rate: 500")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.NatsMaxRegistrationRate).To(Equal(500))
+		})
+
+		It("sets TerminatingProxy", func() {
+			var b = []byte(`
+terminating_proxy:
+  enabled: true
+  trusted_cidrs: ["10.0.0.0/8"]
+  scheme_header: X-Forwarded-Scheme
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.TerminatingProxy.Enabled).To(BeTrue())
+			Expect(config.TerminatingProxy.TrustedCIDRs).To(ConsistOf("10.0.0.0/8"))
+			Expect(config.TerminatingProxy.SchemeHeader).To(Equal("X-Forwarded-Scheme"))
+		})
+
+		It("defaults the TerminatingProxy scheme header to X-Forwarded-Proto", func() {
+			Expect(config.TerminatingProxy.Enabled).To(BeFalse())
+			Expect(config.TerminatingProxy.SchemeHeader).To(Equal("X-Forwarded-Proto"))
+			Expect(config.TerminatingProxy.ForwardedHeader).To(Equal("x-forwarded"))
+		})
+
+		It("sets EmitForwardedHeaders", func() {
+			Expect(config.EmitForwardedHeaders).To(Equal([]string{"x-forwarded"}))
+
+			var b = []byte("emit_forwarded_headers: [forwarded]")
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.EmitForwardedHeaders).To(Equal([]string{"forwarded"}))
+		})
+
+		It("sets ListenerBacklog and EnableReusePort", func() {
+			var b = []byte(`
+listener_backlog: 4096
+enable_reuse_port: true
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ListenerBacklog).To(Equal(4096))
+			Expect(config.EnableReusePort).To(BeTrue())
+		})
+
+		It("sets HTMLInjection", func() {
+			var b = []byte(`
+html_injection:
+  snippet: <script src="/inject.js"></script>
+  headers:
+    Content-Security-Policy: script-src 'nonce-{{nonce}}'
+  max_body_size: 2048
+`)
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.HTMLInjection.Snippet).To(Equal(`<script src="/inject.js"></script>`))
+			Expect(config.HTMLInjection.Headers).To(HaveKeyWithValue("Content-Security-Policy", "script-src 'nonce-{{nonce}}'"))
+			Expect(config.HTMLInjection.MaxBodySize).To(Equal(int64(2048)))
+		})
+
+		It("defaults the HTMLInjection max body size", func() {
+			Expect(config.HTMLInjection.MaxBodySize).To(Equal(int64(1024 * 1024)))
+		})
+	})
+
+	Describe("Process", func() {
+		It("converts intervals to durations", func() {
+			var b = []byte(`
+publish_start_message_interval: 1s
+prune_stale_droplets_interval: 2s
+droplet_stale_threshold: 30s
+publish_active_apps_interval: 4s
+start_response_delay_interval: 15s
+secure_cookies: true
+token_fetcher_retry_interval: 10s
+`)
+
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.Process()).To(Succeed())
+
+			Expect(config.PublishStartMessageInterval).To(Equal(1 * time.Second))
+			Expect(config.PruneStaleDropletsInterval).To(Equal(2 * time.Second))
+			Expect(config.DropletStaleThreshold).To(Equal(30 * time.Second))
+			Expect(config.PublishActiveAppsInterval).To(Equal(4 * time.Second))
+			Expect(config.StartResponseDelayInterval).To(Equal(15 * time.Second))
+			Expect(config.TokenFetcherRetryInterval).To(Equal(10 * time.Second))
+			Expect(config.NatsClientPingInterval).To(Equal(20 * time.Second))
+			Expect(config.SecureCookies).To(BeTrue())
+		})
+
+		Context("When LoadBalancerHealthyThreshold is provided", func() {
+			It("returns a meaningful error when an invalid duration string is given", func() {
+				var b = []byte("load_balancer_healthy_threshold: -5s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid load balancer healthy threshold: -5s"))
+			})
+
+			It("fails to initialize a non time string", func() {
+				var b = []byte("load_balancer_healthy_threshold: test")
+				Expect(config.Initialize(b)).To(MatchError(ContainSubstring("cannot unmarshal")))
+			})
+
+			It("process the string into a valid duration", func() {
+				var b = []byte("load_balancer_healthy_threshold: 10s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		It("converts extra headers to log into a map", func() {
+			var b = []byte(`
+extra_headers_to_log:
+  - x-b3-trace-id
+  - something
+  - something
+`)
+
+			err := config.Initialize(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Process()).To(Succeed())
+
+			Expect(config.ExtraHeadersToLog).To(ContainElement("something"))
+			Expect(config.ExtraHeadersToLog).To(ContainElement("x-b3-trace-id"))
+		})
+
+		Context("When secure cookies is set to false", func() {
+			It("set DropletStaleThreshold equal to StartResponseDelayInterval", func() {
+				var b = []byte(`
+secure_cookies: false
+`)
+
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+
+				Expect(config.SecureCookies).To(BeFalse())
+			})
+
+		})
+
+		Context("When a route snapshot path is provided", func() {
+			It("returns a meaningful error when the interval is not positive", func() {
+				var b = []byte(`
+route_snapshot:
+  path: /tmp/routes.json
+  interval: 0s
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid route snapshot interval: 0s. Must be greater than zero"))
+			})
+
+			It("returns a meaningful error when the max age is negative", func() {
+				var b = []byte(`
+route_snapshot:
+  path: /tmp/routes.json
+  max_age: -1s
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid route snapshot max age: -1s. Must not be negative"))
+			})
+		})
+
+		Context("When the prometheus path is invalid", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+prometheus:
+  enabled: true
+  path: metrics
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid prometheus path: metrics. Must start with /"))
+			})
+		})
+
+		Context("When OTLP tracing is enabled without a valid endpoint", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+tracing:
+  otlp:
+    enabled: true
+    endpoint: localhost:4318
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid OTLP endpoint: localhost:4318. Must be an http or https URL"))
+			})
+		})
+
+		Context("When the access log buffer size is not positive", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+access_log:
+  buffer_size: 0
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid access log buffer size: 0. Must be greater than zero"))
+			})
+		})
+
+		Context("When the access log flush interval is negative", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+access_log:
+  flush_interval: -1s
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid access log flush interval: -1s"))
+			})
+		})
+
+		Context("When the access log syslog address has no port", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+access_log:
+  syslog:
+    address: syslog.example.com
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(ContainSubstring("Invalid access log syslog address")))
+			})
+		})
+
+		Context("When the access log kafka config has no topic", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+access_log:
+  kafka:
+    brokers: [kafka.example.com:9092]
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid access log kafka config: topic is required"))
+			})
+		})
+
+		Context("When the access log kafka SASL mechanism is unknown", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+access_log:
+  kafka:
+    brokers: [kafka.example.com:9092]
+    topic: access-logs
+    sasl:
+      mechanism: GSSAPI
+      username: router
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid access log kafka SASL mechanism: GSSAPI. Must be PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512"))
+			})
+		})
+
+		Context("When an access log template is set with the json format", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+access_log:
+  format: json
+  template: $host $status_code
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid access log config: template requires the text format"))
+			})
+		})
+
+		Context("When the access log format is unknown", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte(`
+access_log:
+  format: csv
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid access log format: csv. Must be text or json"))
+			})
+		})
+
+		Context("When an invalid GET request body policy is provided", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte("get_request_body_policy: ignore")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid GET request body policy: ignore. Allowed values are [forward drop reject]"))
+			})
+		})
+
+		Context("When an invalid HTTP/1.0 policy is provided", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte("http10_policy: ignore")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid HTTP/1.0 policy: ignore. Allowed values are [reject route]"))
+			})
+		})
+
+		Context("When the HTTP/1.0 policy is route and no default host is provided", func() {
+			It("returns a meaningful error", func() {
+				var b = []byte("http10_policy: route")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Expected an HTTP/1.0 default host; HTTP/1.0 policy set to route and none provided."))
+			})
+		})
+
+		Context("When draining request bodies on error is enabled", func() {
+			It("returns a meaningful error when the max bytes is not positive", func() {
+				var b = []byte(`
+drain_request_body_on_error: true
+drain_request_body_on_error_max_bytes: -1
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid drain request body on error max bytes: -1. Must be greater than zero"))
+			})
+		})
+
+		Context("When IdleEndpointPruneThreshold is provided", func() {
+			It("returns a meaningful error when it is negative", func() {
+				var b = []byte("idle_endpoint_prune_threshold: -5s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid idle endpoint prune threshold: -5s"))
+			})
+
+			It("returns a meaningful error when the health check timeout is not positive", func() {
+				var b = []byte("idle_endpoint_prune_threshold: 5m\nidle_endpoint_health_check_timeout: 0s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid idle endpoint health check timeout: 0s. Must be greater than zero"))
+			})
+		})
+
+		Context("When a backend response header timeout is provided", func() {
+			It("sets the timeout", func() {
+				var b = []byte("backends:\n  response_header_timeout: 5s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.Backends.ResponseHeaderTimeout).To(Equal(5 * time.Second))
+			})
+
+			It("returns a meaningful error when it is negative", func() {
+				var b = []byte("backends:\n  response_header_timeout: -5s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid backend response header timeout: -5s"))
+			})
+		})
+
+		Context("When a backend max requests per connection is provided", func() {
+			It("sets the limit", func() {
+				var b = []byte("backends:\n  max_requests_per_conn: 100")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.Backends.MaxRequestsPerConn).To(Equal(100))
+			})
+
+			It("returns a meaningful error when it is negative", func() {
+				var b = []byte("backends:\n  max_requests_per_conn: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid backend max requests per connection: -1"))
+			})
+		})
+
+		Context("When a backend PROXY protocol version is provided", func() {
+			It("sets the version", func() {
+				var b = []byte("backends:\n  proxy_protocol: v2")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.Backends.ProxyProtocol).To(Equal("v2"))
+			})
+
+			It("returns a meaningful error when the version is unknown", func() {
+				var b = []byte("backends:\n  proxy_protocol: v3")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.backends.proxy_protocol must be one of '', 'v1' or 'v2'"))
+			})
+		})
+
+		Context("When backend TLS versions are provided", func() {
+			It("sets the versions", func() {
+				var b = []byte("backends:\n  min_tls_version: TLSv1.2\n  max_tls_version: TLSv1.2")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.Backends.MinTLSVersion).To(Equal(uint16(tls.VersionTLS12)))
+				Expect(config.Backends.MaxTLSVersion).To(Equal(uint16(tls.VersionTLS12)))
+			})
+
+			It("leaves the versions to crypto/tls by default", func() {
+				Expect(config.Process()).To(Succeed())
+				Expect(config.Backends.MinTLSVersion).To(BeZero())
+				Expect(config.Backends.MaxTLSVersion).To(BeZero())
+			})
+
+			It("returns a meaningful error when a version is unknown", func() {
+				var b = []byte("backends:\n  max_tls_version: TLSv2")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(`router.backends.max_tls_version should be one of "", "TLSv1.3", "TLSv1.2", "TLSv1.1", "TLSv1.0"`))
+			})
+		})
+
+		Context("When route service TLS versions are provided", func() {
+			It("sets the versions", func() {
+				var b = []byte("route_services_min_tls_version: TLSv1.3")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.RouteServicesMinTLSVersion).To(Equal(uint16(tls.VersionTLS13)))
+				Expect(config.RouteServicesMaxTLSVersion).To(BeZero())
+			})
+
+			It("returns a meaningful error when the maximum is lower than the minimum", func() {
+				var b = []byte("route_services_min_tls_version: TLSv1.3\nroute_services_max_tls_version: TLSv1.2")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.route_services_max_tls_version must not be lower than router.route_services_min_tls_version"))
+			})
+		})
+
+		Context("When ResponseCache is provided", func() {
+			It("returns a meaningful error when the max size is negative", func() {
+				var b = []byte("response_cache:\n  max_size_bytes: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid response cache max size: -1. Must not be negative"))
+			})
+
+			It("returns a meaningful error when a disk path is set without a memory cache", func() {
+				var b = []byte("response_cache:\n  disk_path: /var/cache/gorouter\n  disk_max_size_bytes: 1024")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.response_cache.disk_path requires router.response_cache.max_size_bytes to be set"))
+			})
+
+			It("returns a meaningful error when a disk path is set without a disk size", func() {
+				var b = []byte("response_cache:\n  max_size_bytes: 1024\n  disk_path: /var/cache/gorouter")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.response_cache.disk_max_size_bytes must be greater than zero when disk_path is set"))
+			})
+		})
+
+		Context("When a request body limit is configured", func() {
+			It("returns a meaningful error for a negative limit", func() {
+				var b = []byte("max_request_body_bytes: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.max_request_body_bytes must not be negative"))
+			})
+		})
+
+		Context("When a decompressed request body limit is configured", func() {
+			It("defaults to 10 MB", func() {
+				Expect(config.Process()).To(Succeed())
+				Expect(config.MaxDecompressedRequestBodyBytes).To(BeEquivalentTo(10 * 1024 * 1024))
+			})
+
+			It("returns a meaningful error for a limit that is not positive", func() {
+				var b = []byte("max_decompressed_request_body_bytes: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.max_decompressed_request_body_bytes must be greater than zero"))
+			})
+		})
+
+		Context("When strict request framing is configured", func() {
+			It("defaults to false", func() {
+				Expect(config.StrictRequestFraming).To(BeFalse())
+			})
+
+			It("sets it", func() {
+				var b = []byte("strict_request_framing: true")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.StrictRequestFraming).To(BeTrue())
+			})
+		})
+
+		Context("When request header limits are configured", func() {
+			It("sets them", func() {
+				var b = []byte("max_header_bytes: 16384\nmax_header_count: 100")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.MaxHeaderBytes).To(Equal(16384))
+				Expect(config.MaxHeaderCount).To(Equal(100))
+			})
+
+			It("returns a meaningful error for a negative size", func() {
+				var b = []byte("max_header_bytes: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.max_header_bytes must not be negative"))
+			})
+
+			It("returns a meaningful error for a negative count", func() {
+				var b = []byte("max_header_count: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.max_header_count must not be negative"))
+			})
+		})
+
+		Context("When compression is enabled", func() {
+			It("uses the default encodings, content types and minimum size", func() {
+				var b = []byte("compression:\n  enabled: true")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.Compression.Encodings).To(Equal([]string{"br", "gzip"}))
+				Expect(config.Compression.ContentTypes).To(ContainElement("application/json"))
+				Expect(config.Compression.MinSize).To(Equal(1024))
+			})
+
+			It("returns a meaningful error for an unknown encoding", func() {
+				var b = []byte("compression:\n  enabled: true\n  encodings: [deflate]")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.compression.encodings must be one of [br gzip]"))
+			})
+
+			It("returns a meaningful error for a negative minimum size", func() {
+				var b = []byte("compression:\n  enabled: true\n  min_size: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.compression.min_size must not be negative"))
+			})
+		})
+
+		Context("When security headers are configured", func() {
+			It("parses the policies", func() {
+				var b = []byte(`
+security_headers:
+- domains: ["*.apps.example.com"]
+  hsts:
+    max_age: 8760h
+    include_subdomains: true
+  content_type_options: true
+  frame_options: SAMEORIGIN
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.SecurityHeaders).To(Equal([]SecurityHeadersConfig{{
+					Domains:            []string{"*.apps.example.com"},
+					HSTS:               HSTSConfig{MaxAge: 8760 * time.Hour, IncludeSubDomains: true},
+					ContentTypeOptions: true,
+					FrameOptions:       FrameOptionsSameOrigin,
+				}}))
+			})
+
+			It("returns a meaningful error for a policy without domains", func() {
+				var b = []byte("security_headers:\n- content_type_options: true")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.security_headers[0].domains must not be empty"))
+			})
+
+			It("returns a meaningful error for an invalid domain", func() {
+				var b = []byte("security_headers:\n- domains: [\"apps.*.example.com\"]")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(`router.security_headers[0].domains contains an invalid domain: "apps.*.example.com"`))
+			})
+
+			It("returns a meaningful error for an invalid frame option", func() {
+				var b = []byte("security_headers:\n- domains: [\"*\"]\n  frame_options: ALLOW")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.security_headers[0].frame_options must be DENY or SAMEORIGIN"))
+			})
+		})
+
+		Context("When header rules are configured", func() {
+			It("parses the rules", func() {
+				var b = []byte(`
+header_rules:
+- domains: ["*.apps.example.com"]
+  routes: ["app.apps.example.com/api"]
+  request:
+    remove: [X-Internal-Token]
+    set:
+    - name: X-Platform
+      value: cf
+  response:
+    add:
+    - name: Cache-Control
+      value: private
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.HeaderRules).To(Equal([]HeaderRule{{
+					Domains: []string{"*.apps.example.com"},
+					Routes:  []string{"app.apps.example.com/api"},
+					Request: HeaderRuleActions{
+						Remove: []string{"X-Internal-Token"},
+						Set:    []HeaderNameValue{{Name: "X-Platform", Value: "cf"}},
+					},
+					Response: HeaderRuleActions{
+						Add: []HeaderNameValue{{Name: "Cache-Control", Value: "private"}},
+					},
+				}}))
+			})
+
+			It("returns a meaningful error for an invalid domain", func() {
+				var b = []byte("header_rules:\n- domains: [\"\"]")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(`router.header_rules[0].domains contains an invalid domain: ""`))
+			})
+
+			It("returns a meaningful error for a header without a name", func() {
+				var b = []byte("header_rules:\n- response:\n    set:\n    - value: cf")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.header_rules[0] must not contain headers without a name"))
+			})
+
+			It("returns a meaningful error for a rule that changes Host", func() {
+				var b = []byte("header_rules:\n- request:\n    remove: [host]")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.header_rules[0] must not change the Host header"))
+			})
+		})
+
+		Context("When client IP access lists are configured", func() {
+			It("parses the CIDRs", func() {
+				var b = []byte("client_ip_access:\n  allowed_cidrs: [10.0.0.0/8]\n  denied_cidrs: [10.1.0.0/16, 10.2.0.0/16]")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.ClientIPAccess.AllowedNets).To(HaveLen(1))
+				Expect(config.ClientIPAccess.AllowedNets[0].String()).To(Equal("10.0.0.0/8"))
+				Expect(config.ClientIPAccess.DeniedNets).To(HaveLen(2))
+			})
+
+			It("returns a meaningful error for an invalid CIDR", func() {
+				var b = []byte("client_ip_access:\n  denied_cidrs: [10.1.0.1]")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.client_ip_access.denied_cidrs contains an invalid CIDR: 10.1.0.1"))
+			})
+		})
+
+		Context("When load shedding is configured", func() {
+			It("defaults retry_after to one second", func() {
+				var b = []byte("load_shedding:\n  max_in_flight_requests: 5000")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.LoadShedding.MaxInFlightRequests).To(Equal(5000))
+				Expect(config.LoadShedding.RetryAfter).To(Equal(time.Second))
+			})
+
+			It("returns a meaningful error when the cap is negative", func() {
+				var b = []byte("load_shedding:\n  max_in_flight_requests: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.load_shedding.max_in_flight_requests must not be negative"))
+			})
+
+			It("returns a meaningful error when retry_after is less than a second", func() {
+				var b = []byte("load_shedding:\n  max_in_flight_requests: 10\n  retry_after: 500ms")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.load_shedding.retry_after must be at least one second"))
+			})
+		})
+
+		Context("When traffic mirroring is configured", func() {
+			It("defaults the bounds of mirrored requests", func() {
+				Expect(config.Process()).To(Succeed())
+				Expect(config.TrafficMirroring.MaxInFlightRequests).To(Equal(100))
+				Expect(config.TrafficMirroring.MaxBodyBytes).To(Equal(int64(1048576)))
+				Expect(config.TrafficMirroring.Timeout).To(Equal(5 * time.Second))
+				Expect(config.TrafficMirroring.ForwardCredentials).To(BeFalse())
+			})
+
+			It("forwards credentials to the mirror routes when configured", func() {
+				var b = []byte("traffic_mirroring:\n  forward_credentials: true")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.TrafficMirroring.ForwardCredentials).To(BeTrue())
+			})
+
+			It("returns a meaningful error when the cap is negative", func() {
+				var b = []byte("traffic_mirroring:\n  max_in_flight_requests: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.traffic_mirroring.max_in_flight_requests must not be negative"))
+			})
+
+			It("returns a meaningful error when the timeout is zero", func() {
+				var b = []byte("traffic_mirroring:\n  timeout: 0s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.traffic_mirroring.timeout must be greater than zero"))
+			})
+		})
+
+		Context("When StatsD is enabled", func() {
+			It("defaults the address, prefix and flush interval", func() {
+				var b = []byte("statsd:\n  enabled: true")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.StatsD.Address).To(Equal("127.0.0.1:8125"))
+				Expect(config.StatsD.Prefix).To(Equal("gorouter."))
+				Expect(config.StatsD.TagFormat).To(Equal("none"))
+				Expect(config.StatsD.FlushInterval).To(Equal(time.Second))
+			})
+
+			It("returns a meaningful error when the tag format is unknown", func() {
+				var b = []byte("statsd:\n  enabled: true\n  tag_format: graphite")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.statsd.tag_format must be one of 'none', 'dogstatsd' or 'influxdb'"))
+			})
+
+			It("returns a meaningful error when tags are set without a tag format", func() {
+				var b = []byte("statsd:\n  enabled: true\n  tags:\n    deployment: cf")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.statsd.tag_format must be set if router.statsd.tags are set"))
+			})
+		})
+
+		Context("When per-route metrics are enabled", func() {
+			It("defaults the number of routes with metrics of their own", func() {
+				var b = []byte("per_route_metrics:\n  enabled: true")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.PerRouteMetrics.TopN).To(Equal(20))
+				Expect(config.PerRouteMetrics.MaxRoutes).To(Equal(200))
+				Expect(config.PerRouteMetrics.Window).To(Equal(time.Minute))
+			})
+
+			It("sets the window in which the requests to routes are counted", func() {
+				var b = []byte("per_route_metrics:\n  enabled: true\n  window: 5m")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.PerRouteMetrics.Window).To(Equal(5 * time.Minute))
+			})
+
+			It("returns a meaningful error when top_n is zero", func() {
+				var b = []byte("per_route_metrics:\n  enabled: true\n  top_n: 0")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.per_route_metrics.top_n must be greater than zero"))
+			})
+
+			It("returns a meaningful error when max_routes is less than top_n", func() {
+				var b = []byte("per_route_metrics:\n  enabled: true\n  top_n: 10\n  max_routes: 5")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.per_route_metrics.max_routes must not be less than router.per_route_metrics.top_n"))
+			})
+
+			It("returns a meaningful error when the window is zero", func() {
+				var b = []byte("per_route_metrics:\n  enabled: true\n  window: 0s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.per_route_metrics.window must be greater than zero"))
+			})
+		})
+
+		Context("When a Consul address is configured", func() {
+			It("defaults the route tag prefix and retry interval", func() {
+				var b = []byte("consul:\n  address: http://127.0.0.1:8500")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.Consul.RouteTagPrefix).To(Equal("gorouter.route="))
+				Expect(config.Consul.RetryInterval).To(Equal(5 * time.Second))
+			})
+
+			It("returns a meaningful error when the address is not a URL", func() {
+				var b = []byte("consul:\n  address: 127.0.0.1:8500")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(`router.consul.address must be an http or https URL: "127.0.0.1:8500"`))
+			})
+
+			It("returns a meaningful error when the route tag prefix is empty", func() {
+				var b = []byte("consul:\n  address: http://127.0.0.1:8500\n  route_tag_prefix: ''")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.consul.route_tag_prefix must not be empty"))
+			})
+		})
+
+		Context("When NATS credentials are configured", func() {
+			It("sets the credentials file", func() {
+				var b = []byte("nats_credentials_file: /var/vcap/jobs/gorouter/config/nats.creds")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.NatsCredentialsFile).To(Equal("/var/vcap/jobs/gorouter/config/nats.creds"))
+			})
+
+			It("returns a meaningful error when a credentials file and an NKey seed are both set", func() {
+				var b = []byte("nats_credentials_file: nats.creds\nnats_nkey_seed_file: nats.nk")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.nats_credentials_file and router.nats_nkey_seed_file are mutually exclusive"))
+			})
+		})
+
+		Context("When a NATS JetStream stream is configured", func() {
+			It("defaults the ack wait", func() {
+				var b = []byte("nats_jetstream:\n  stream: routes\n  durable: gorouter-0")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.NatsJetStream.AckWait).To(Equal(30 * time.Second))
+			})
+
+			It("returns a meaningful error when the durable is not set", func() {
+				var b = []byte("nats_jetstream:\n  stream: routes")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(`router.nats_jetstream.durable is not a valid consumer name: ""`))
+			})
+
+			It("returns a meaningful error when the stream is not a valid name", func() {
+				var b = []byte("nats_jetstream:\n  stream: router.*\n  durable: gorouter-0")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(`router.nats_jetstream.stream is not a valid stream name: "router.*"`))
+			})
+		})
+
+		Context("When a secondary NATS cluster is configured", func() {
+			It("defaults the failover delay", func() {
+				var b = []byte("nats:\n- host: 10.0.0.1\n  port: 4222\nnats_secondary:\n- host: 10.0.1.1\n  port: 4222")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.NatsFailoverAfter).To(Equal(30 * time.Second))
+				Expect(config.NatsSecondaryServers()).To(ConsistOf("nats://:@10.0.1.1:4222"))
+			})
+
+			It("returns a meaningful error when there is no primary cluster", func() {
+				var b = []byte("nats_secondary:\n- host: 10.0.1.1\n  port: 4222")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.nats_secondary requires router.nats"))
+			})
+
+			It("returns a meaningful error when the failover delay is not positive", func() {
+				var b = []byte("nats:\n- host: 10.0.0.1\n  port: 4222\nnats_secondary:\n- host: 10.0.1.1\n  port: 4222\nnats_failover_after: -1s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.nats_failover_after must be greater than zero"))
+			})
+		})
+
+		Context("When stale thresholds are bounded", func() {
+			It("succeeds with bounds around the droplet stale threshold", func() {
+				var b = []byte("droplet_stale_threshold: 2m\nmin_stale_threshold: 30s\nmax_stale_threshold: 1h")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.MinStaleThreshold).To(Equal(30 * time.Second))
+				Expect(config.MaxStaleThreshold).To(Equal(time.Hour))
+			})
+
+			It("returns a meaningful error when the minimum is above the droplet stale threshold", func() {
+				var b = []byte("droplet_stale_threshold: 2m\nmin_stale_threshold: 5m")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.min_stale_threshold must not be greater than router.droplet_stale_threshold"))
+			})
+
+			It("returns a meaningful error when the maximum is below the droplet stale threshold", func() {
+				var b = []byte("droplet_stale_threshold: 2m\nmax_stale_threshold: 1m")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.max_stale_threshold must not be less than router.droplet_stale_threshold"))
+			})
+		})
+
+		Context("When prune policies are configured", func() {
+			It("succeeds with valid policies", func() {
+				var b = []byte(`
+prune_policies:
+- domains: ["*.internal.example.com"]
+  policy: never
+- domains: ["api.example.com"]
+  policy: quorum
+  quorum: 2
+- domains: ["*"]
+  policy: grace_period
+  grace_period: 2m
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+				config.Nats = []NatsConfig{{Host: "10.0.0.1", Port: 4222}}
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.PrunePolicies).To(HaveLen(3))
+				Expect(config.PrunePolicies[1].Quorum).To(Equal(2))
+				Expect(config.PrunePolicies[2].GracePeriod).To(Equal(2 * time.Minute))
+			})
+
+			It("returns a meaningful error when the policy is unknown", func() {
+				var b = []byte("prune_policies:\n- domains: [example.com]\n  policy: sometimes")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.prune_policies[0].policy must be one of stale, never, quorum or grace_period"))
+			})
+
+			It("returns a meaningful error when a quorum policy has no quorum", func() {
+				var b = []byte("prune_policies:\n- domains: [example.com]\n  policy: quorum")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.prune_policies[0].quorum must be at least 1"))
+			})
+
+			It("returns a meaningful error when a quorum policy has no NATS to reach it over", func() {
+				var b = []byte("prune_policies:\n- domains: [example.com]\n  policy: quorum\n  quorum: 2")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.prune_policies[0] requires router.nats to reach a quorum"))
+			})
+
+			It("returns a meaningful error when a grace period policy has no grace period", func() {
+				var b = []byte("prune_policies:\n- domains: [example.com]\n  policy: grace_period")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.prune_policies[0].grace_period must be greater than zero"))
+			})
+
+			It("returns a meaningful error when a domain is invalid", func() {
+				var b = []byte("prune_policies:\n- domains: [\"*.*.example.com\"]\n  policy: never")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(`router.prune_policies[0].domains contains an invalid domain: "*.*.example.com"`))
+			})
+		})
+
+		Context("When the Kubernetes watcher is enabled", func() {
+			It("defaults to the credentials of the pod", func() {
+				var b = []byte("kubernetes:\n  enabled: true")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.Kubernetes.TokenFile).To(Equal("/var/run/secrets/kubernetes.io/serviceaccount/token"))
+				Expect(config.Kubernetes.CAFile).To(Equal("/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"))
+				Expect(config.Kubernetes.RouteAnnotation).To(Equal("gorouter.cloudfoundry.org/routes"))
+			})
+
+			It("returns a meaningful error when the API server is not a URL", func() {
+				var b = []byte("kubernetes:\n  enabled: true\n  api_server: kubernetes.default")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError(`router.kubernetes.api_server must be an http or https URL: "kubernetes.default"`))
+			})
+		})
+
+		Context("When rate limiting is configured", func() {
+			It("succeeds with a rate and a burst", func() {
+				var b = []byte("rate_limit:\n  requests_per_second: 100\n  burst: 200")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.RateLimit.RequestsPerSecond).To(Equal(100.0))
+				Expect(config.RateLimit.Burst).To(Equal(200))
+				Expect(config.RateLimit.Key).To(Equal("client_ip"))
+			})
+
+			It("returns a meaningful error when the rate is negative", func() {
+				var b = []byte("rate_limit:\n  requests_per_second: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.rate_limit.requests_per_second must not be negative"))
+			})
+
+			It("returns a meaningful error when the key is unknown", func() {
+				var b = []byte("rate_limit:\n  requests_per_second: 1\n  key: cookie")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.rate_limit.key must be one of [route client_ip header]"))
+			})
+
+			It("returns a meaningful error when the key is a header without a name", func() {
+				var b = []byte("rate_limit:\n  requests_per_second: 1\n  key: header")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.rate_limit.header must be set if router.rate_limit.key is header"))
+			})
+		})
+
+		Context("When JWT validation is configured", func() {
+			It("succeeds with a key set URL and an issuer", func() {
+				var b = []byte("jwt:\n  enabled: true\n  issuer: https://uaa.example.com/oauth/token\n  jwks_url: https://uaa.example.com/token_keys\n  audiences: [billing]")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(Succeed())
+				Expect(config.JWT.Audiences).To(Equal([]string{"billing"}))
+				Expect(config.JWT.JWKSRefreshInterval).To(Equal(10 * time.Minute))
+				Expect(config.JWT.JWKSTimeout).To(Equal(10 * time.Second))
+				Expect(config.JWT.ClockSkew).To(Equal(time.Minute))
+				Expect(config.JWT.ClaimsHeader).To(Equal("X-Jwt-Claims"))
+			})
+
+			It("returns a meaningful error when enabled without a key set URL", func() {
+				var b = []byte("jwt:\n  enabled: true\n  issuer: https://uaa.example.com/oauth/token")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.jwt.jwks_url must be set if router.jwt.enabled is set to true"))
+			})
+
+			It("returns a meaningful error when the key set URL is not an http URL", func() {
+				var b = []byte("jwt:\n  issuer: https://uaa.example.com/oauth/token\n  jwks_url: uaa.example.com/token_keys")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.jwt.jwks_url must be an http or https URL"))
+			})
+
+			It("returns a meaningful error when the issuer is missing", func() {
+				var b = []byte("jwt:\n  jwks_url: https://uaa.example.com/token_keys")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.jwt.issuer must be set if router.jwt.jwks_url is set"))
+			})
+
+			It("returns a meaningful error when the claims header is empty", func() {
+				var b = []byte("jwt:\n  issuer: https://uaa.example.com/oauth/token\n  jwks_url: https://uaa.example.com/token_keys\n  claims_header: ''")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("router.jwt.claims_header must not be empty"))
+			})
+		})
+
+		Context("When FailedEndpointCooldown is provided", func() {
+			It("returns a meaningful error when it is negative", func() {
+				var b = []byte("failed_endpoint_cooldown: -5s")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid failed endpoint cooldown: -5s"))
+			})
+		})
+
+		Context("When NatsMaxRegistrationRate is provided", func() {
+			It("returns a meaningful error when it is negative", func() {
+				var b = []byte("nats_max_registration_rate: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid NATS max registration rate: -1. Must not be negative"))
+			})
+		})
+
+		Context("When TerminatingProxy is enabled", func() {
+			It("adds the trusted CIDRs to the trusted proxies", func() {
+				var b = []byte(`
+trusted_proxy_cidrs: ["192.168.0.0/16"]
+terminating_proxy:
+  enabled: true
+  trusted_cidrs: ["10.0.0.0/8", "fd00::/8"]
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(config.Process()).To(Succeed())
+
+				Expect(config.TrustedProxyNets).To(HaveLen(3))
+				Expect(config.TrustedProxyNets[0].Contains(net.ParseIP("192.168.1.2"))).To(BeTrue())
+				Expect(config.TrustedProxyNets[1].Contains(net.ParseIP("10.1.2.3"))).To(BeTrue())
+			})
+
+			It("trusts the trusted proxies when it has no CIDRs of its own", func() {
+				var b = []byte(`
+trusted_proxy_cidrs: ["10.0.0.0/8"]
+terminating_proxy:
+  enabled: true
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(config.Process()).To(Succeed())
+
+				Expect(config.TrustedProxyNets).To(HaveLen(1))
+			})
+
+			It("returns a meaningful error when a CIDR is invalid", func() {
+				var b = []byte(`
+terminating_proxy:
+  enabled: true
+  trusted_cidrs: ["not-a-cidr"]
 `)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
 
-			err := config.Initialize(b)
-			Expect(err).ToNot(HaveOccurred())
+				Expect(config.Process()).To(MatchError("Invalid terminating proxy trusted CIDR: not-a-cidr"))
+			})
 
-			Expect(config.Process()).To(Succeed())
+			It("returns a meaningful error when no CIDRs are configured", func() {
+				var b = []byte(`
+terminating_proxy:
+  enabled: true
+`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
 
-			Expect(config.PublishStartMessageInterval).To(Equal(1 * time.Second))
-			Expect(config.PruneStaleDropletsInterval).To(Equal(2 * time.Second))
-			Expect(config.DropletStaleThreshold).To(Equal(30 * time.Second))
-			Expect(config.PublishActiveAppsInterval).To(Equal(4 * time.Second))
-			Expect(config.StartResponseDelayInterval).To(Equal(15 * time.Second))
-			Expect(config.TokenFetcherRetryInterval).To(Equal(10 * time.Second))
-			Expect(config.NatsClientPingInterval).To(Equal(20 * time.Second))
-			Expect(config.SecureCookies).To(BeTrue())
-		})
+				Expect(config.Process()).To(MatchError("Terminating proxy is enabled but no trusted CIDRs are configured"))
+			})
 
-		Context("When LoadBalancerHealthyThreshold is provided", func() {
-			It("returns a meaningful error when an invalid duration string is given", func() {
-				var b = []byte("load_balancer_healthy_threshold: -5s")
+			It("returns a meaningful error when the forwarded header is unknown", func() {
+				var b = []byte(`
+terminating_proxy:
+  enabled: true
+  trusted_cidrs: ["10.0.0.0/8"]
+  forwarded_header: x-real-ip
+`)
 				err := config.Initialize(b)
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(config.Process()).To(MatchError("Invalid load balancer healthy threshold: -5s"))
+				Expect(config.Process()).To(MatchError("router.terminating_proxy.forwarded_header must be one of [x-forwarded forwarded]"))
 			})
+		})
 
-			It("fails to initialize a non time string", func() {
-				var b = []byte("load_balancer_healthy_threshold: test")
-				Expect(config.Initialize(b)).To(MatchError(ContainSubstring("cannot unmarshal")))
+		Context("When TrustedProxyCIDRs is provided", func() {
+			It("parses the CIDRs", func() {
+				var b = []byte(`trusted_proxy_cidrs: ["10.0.0.0/8", "fd00::/8"]`)
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(config.Process()).To(Succeed())
+
+				Expect(config.TrustedProxyNets).To(HaveLen(2))
+				Expect(config.TrustedProxyNets[1].Contains(net.ParseIP("fd00::1"))).To(BeTrue())
 			})
 
-			It("process the string into a valid duration", func() {
-				var b = []byte("load_balancer_healthy_threshold: 10s")
+			It("returns a meaningful error when a CIDR is invalid", func() {
+				var b = []byte(`trusted_proxy_cidrs: ["not-a-cidr"]`)
 				err := config.Initialize(b)
 				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid trusted proxy CIDR: not-a-cidr"))
 			})
 		})
 
-		It("converts extra headers to log into a map", func() {
-			var b = []byte(`
-extra_headers_to_log:
-  - x-b3-trace-id
-  - something
-  - something
-`)
+		Context("When EmitForwardedHeaders is provided", func() {
+			It("returns a meaningful error for an unknown header", func() {
+				var b = []byte("emit_forwarded_headers: [x-forwarded, x-real-ip]")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
 
-			err := config.Initialize(b)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(config.Process()).To(Succeed())
+				Expect(config.Process()).To(MatchError("router.emit_forwarded_headers must be one of [x-forwarded forwarded]"))
+			})
+		})
 
-			Expect(config.ExtraHeadersToLog).To(ContainElement("something"))
-			Expect(config.ExtraHeadersToLog).To(ContainElement("x-b3-trace-id"))
+		Context("When ListenerBacklog is provided", func() {
+			It("returns a meaningful error when it is negative", func() {
+				var b = []byte("listener_backlog: -1")
+				err := config.Initialize(b)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Process()).To(MatchError("Invalid listener backlog: -1. Must not be negative"))
+			})
 		})
 
-		Context("When secure cookies is set to false", func() {
-			It("set DropletStaleThreshold equal to StartResponseDelayInterval", func() {
+		Context("When HTMLInjection is configured", func() {
+			It("returns a meaningful error when the max body size is not positive", func() {
 				var b = []byte(`
-secure_cookies: false
+html_injection:
+  snippet: <meta>
+  max_body_size: 0
 `)
-
 				err := config.Initialize(b)
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(config.Process()).To(Succeed())
-
-				Expect(config.SecureCookies).To(BeFalse())
+				Expect(config.Process()).To(MatchError("Invalid HTML injection max body size: 0. Must be greater than zero"))
 			})
-
 		})
 
 		Describe("NatsServers", func() {
@@ -814,7 +2702,7 @@ routing_api:
 					configBytes := createYMLSnippet(configSnippet)
 					err := config.Initialize(configBytes)
 					Expect(err).NotTo(HaveOccurred())
-					Expect(config.Process()).To(MatchError(`router.min_tls_version should be one of "", "TLSv1.2", "TLSv1.1", "TLSv1.0"`))
+					Expect(config.Process()).To(MatchError(`router.min_tls_version should be one of "", "TLSv1.3", "TLSv1.2", "TLSv1.1", "TLSv1.0"`))
 				})
 			})
 			Context("when min_tls_version is not set", func() {
@@ -829,6 +2717,52 @@ routing_api:
 					Expect(config.MinTLSVersion).To(Equal(uint16(tls.VersionTLS12)))
 				})
 			})
+			Context("when valid value for max_tls_version is set", func() {
+				BeforeEach(func() {
+					configSnippet.MinTLSVersionString = "TLSv1.3"
+					configSnippet.MaxTLSVersionString = "TLSv1.3"
+				})
+				It("populates MinTLSVersion and MaxTLSVersion", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(config.Process()).To(Succeed())
+					Expect(config.MinTLSVersion).To(Equal(uint16(tls.VersionTLS13)))
+					Expect(config.MaxTLSVersion).To(Equal(uint16(tls.VersionTLS13)))
+				})
+			})
+			Context("when invalid value for max_tls_version is set", func() {
+				BeforeEach(func() {
+					configSnippet.MaxTLSVersionString = "fake-tls"
+				})
+				It("returns a meaningful error", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(config.Process()).To(MatchError(`router.max_tls_version should be one of "", "TLSv1.3", "TLSv1.2", "TLSv1.1", "TLSv1.0"`))
+				})
+			})
+			Context("when max_tls_version is lower than min_tls_version", func() {
+				BeforeEach(func() {
+					configSnippet.MinTLSVersionString = "TLSv1.3"
+					configSnippet.MaxTLSVersionString = "TLSv1.2"
+				})
+				It("returns a meaningful error", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(config.Process()).To(MatchError("router.max_tls_version must not be lower than router.min_tls_version"))
+				})
+			})
+			Context("when max_tls_version is not set", func() {
+				It("leaves the maximum to crypto/tls", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(config.Process()).To(Succeed())
+					Expect(config.MaxTLSVersion).To(BeZero())
+				})
+			})
 
 			Context("when a valid CACerts is provided", func() {
 				BeforeEach(func() {
@@ -867,6 +2801,39 @@ routing_api:
 				})
 			})
 
+			Context("when a tls_pem value is the default", func() {
+				BeforeEach(func() {
+					configSnippet.TLSPEM[1].Default = true
+				})
+
+				It("serves its certificate first", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(config.Process()).To(Succeed())
+					Expect(config.SSLCertificates).To(Equal([]tls.Certificate{
+						expectedSSLCertificates[1],
+						expectedSSLCertificates[0],
+						expectedSSLCertificates[2],
+					}))
+				})
+
+				Context("when more than one tls_pem value is the default", func() {
+					BeforeEach(func() {
+						configSnippet.TLSPEM[2].Default = true
+					})
+
+					It("returns a meaningful error", func() {
+						configBytes := createYMLSnippet(configSnippet)
+						err := config.Initialize(configBytes)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(config.Process()).To(MatchError("router.tls_pem must not have more than one default certificate"))
+					})
+				})
+			})
+
 			Context("PEM with ECDSA cipher algorithm", func() {
 				BeforeEach(func() {
 					keyPEM, certPEM := test_util.CreateECKeyPair("parsnip.com")
@@ -1066,6 +3033,136 @@ routing_api:
 					Expect(config.Process()).To(MatchError("must specify list of cipher suite when ssl is enabled"))
 				})
 			})
+
+			Context("when HTTP/2 is enabled", func() {
+				BeforeEach(func() {
+					configSnippet.EnableHTTP2 = true
+				})
+
+				It("accepts the configuration", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(config.Process()).To(Succeed())
+					Expect(config.EnableHTTP2).To(BeTrue())
+				})
+
+				Context("when the cipher suites do not include one required by HTTP/2", func() {
+					BeforeEach(func() {
+						configSnippet.CipherString = "ECDHE-RSA-AES256-GCM-SHA384"
+					})
+
+					It("returns a meaningful error", func() {
+						configBytes := createYMLSnippet(configSnippet)
+						err := config.Initialize(configBytes)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(config.Process()).To(MatchError("router.cipher_suites must include ECDHE-RSA-AES128-GCM-SHA256 or ECDHE-ECDSA-AES128-GCM-SHA256 if router.enable_http2 is set to true"))
+					})
+
+					Context("when the minimum TLS version is TLSv1.3", func() {
+						BeforeEach(func() {
+							configSnippet.MinTLSVersionString = "TLSv1.3"
+						})
+
+						It("accepts the configuration", func() {
+							configBytes := createYMLSnippet(configSnippet)
+							err := config.Initialize(configBytes)
+							Expect(err).ToNot(HaveOccurred())
+
+							Expect(config.Process()).To(Succeed())
+						})
+					})
+				})
+
+				Context("when the max concurrent streams is zero", func() {
+					It("returns a meaningful error", func() {
+						configBytes := createYMLSnippet(configSnippet)
+						err := config.Initialize(configBytes)
+						Expect(err).ToNot(HaveOccurred())
+						config.HTTP2MaxConcurrentStreams = 0
+
+						Expect(config.Process()).To(MatchError("router.http2_max_concurrent_streams must be greater than zero if router.enable_http2 is set to true"))
+					})
+				})
+			})
+
+			Context("when OCSP stapling is enabled", func() {
+				BeforeEach(func() {
+					configSnippet.OCSPStapling = OCSPStaplingConfig{
+						Enabled:         true,
+						RefreshInterval: time.Minute,
+						Timeout:         time.Second,
+					}
+				})
+
+				It("succeeds", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(config.Process()).To(Succeed())
+				})
+
+				It("returns a meaningful error when the refresh interval is not positive", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).ToNot(HaveOccurred())
+					config.OCSPStapling.RefreshInterval = 0
+
+					Expect(config.Process()).To(MatchError("router.ocsp_stapling.refresh_interval must be greater than zero"))
+				})
+
+				It("returns a meaningful error when the timeout is not positive", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).ToNot(HaveOccurred())
+					config.OCSPStapling.Timeout = -time.Second
+
+					Expect(config.Process()).To(MatchError("router.ocsp_stapling.timeout must be greater than zero"))
+				})
+			})
+
+			Context("when HTTP/3 is enabled", func() {
+				BeforeEach(func() {
+					configSnippet.EnableHTTP3 = true
+				})
+
+				It("listens for HTTP/3 on the TLS port by default", func() {
+					configBytes := createYMLSnippet(configSnippet)
+					err := config.Initialize(configBytes)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(config.Process()).To(Succeed())
+					Expect(config.HTTP3Port).To(Equal(config.SSLPort))
+				})
+
+				Context("when the Alt-Svc max age is negative", func() {
+					It("returns a meaningful error", func() {
+						configBytes := createYMLSnippet(configSnippet)
+						err := config.Initialize(configBytes)
+						Expect(err).ToNot(HaveOccurred())
+						config.HTTP3AltSvcMaxAge = -time.Second
+
+						Expect(config.Process()).To(MatchError("router.http3_alt_svc_max_age must not be negative"))
+					})
+				})
+
+				Context("when the maximum TLS version is lower than TLSv1.3", func() {
+					BeforeEach(func() {
+						configSnippet.MaxTLSVersionString = "TLSv1.2"
+					})
+
+					It("returns a meaningful error", func() {
+						configBytes := createYMLSnippet(configSnippet)
+						err := config.Initialize(configBytes)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(config.Process()).To(MatchError("router.max_tls_version must be TLSv1.3 if router.enable_http3 is set to true"))
+					})
+				})
+			})
 		})
 
 		Context("When enable_ssl is set to false", func() {
@@ -1092,6 +3189,29 @@ disable_http: true
 					Expect(config.Process()).To(MatchError(HavePrefix("neither http nor https listener is enabled")))
 				})
 			})
+			Context("When enable_http3 is set to true", func() {
+				It("returns a meaningful error", func() {
+					var b = []byte(`
+enable_ssl: false
+enable_http3: true
+`)
+					err := config.Initialize(b)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(config.Process()).To(MatchError("router.enable_ssl must be set to true if router.enable_http3 is set to true"))
+				})
+			})
+			Context("When ocsp_stapling is enabled", func() {
+				It("returns a meaningful error", func() {
+					var b = []byte(`
+enable_ssl: false
+ocsp_stapling:
+  enabled: true
+`)
+					err := config.Initialize(b)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(config.Process()).To(MatchError("router.enable_ssl must be set to true if router.ocsp_stapling.enabled is set to true"))
+				})
+			})
 		})
 
 		Context("When given a routing_table_sharding_mode that is supported ", func() {
@@ -1287,7 +3407,96 @@ endpoint_timeout: 10s
 					})
 				})
 			})
+
+			Context("when provided named backend client certificates", func() {
+				var certChainA, certChainB test_util.CertChain
+
+				clientCert := func(name string, certChain test_util.CertChain) BackendClientCert {
+					return BackendClientCert{
+						Name: name,
+						TLSPem: TLSPem{
+							CertChain:  string(certChain.CertPEM),
+							PrivateKey: string(certChain.PrivKeyPEM),
+						},
+					}
+				}
+
+				BeforeEach(func() {
+					certChainA = test_util.CreateSignedCertWithRootCA(test_util.CertNames{CommonName: "a"})
+					certChainB = test_util.CreateSignedCertWithRootCA(test_util.CertNames{CommonName: "b"})
+				})
+
+				It("populates the NamedClientAuthCertificates", func() {
+					cfgYaml, _ := yaml.Marshal(map[string]interface{}{
+						"backends": map[string]interface{}{
+							"client_certs": []BackendClientCert{
+								clientCert("cert-a", certChainA),
+								clientCert("cert-b", certChainB),
+							},
+						},
+					})
+					Expect(config.Initialize(cfgYaml)).To(Succeed())
+					Expect(config.Process()).To(Succeed())
+
+					Expect(config.Backends.NamedClientAuthCertificates).To(HaveLen(2))
+					Expect(config.Backends.NamedClientAuthCertificates["cert-a"]).To(Equal(certChainA.AsTLSConfig().Certificates[0]))
+					Expect(config.Backends.NamedClientAuthCertificates["cert-b"]).To(Equal(certChainB.AsTLSConfig().Certificates[0]))
+				})
+
+				It("returns a meaningful error when a name is used twice", func() {
+					cfgYaml, _ := yaml.Marshal(map[string]interface{}{
+						"backends": map[string]interface{}{
+							"client_certs": []BackendClientCert{
+								clientCert("cert-a", certChainA),
+								clientCert("cert-a", certChainB),
+							},
+						},
+					})
+					Expect(config.Initialize(cfgYaml)).To(Succeed())
+					Expect(config.Process()).To(MatchError("Duplicate backend client certificate name: cert-a"))
+				})
+
+				It("returns a meaningful error when a name is missing", func() {
+					cfgYaml, _ := yaml.Marshal(map[string]interface{}{
+						"backends": map[string]interface{}{
+							"client_certs": []BackendClientCert{clientCert("", certChainA)},
+						},
+					})
+					Expect(config.Initialize(cfgYaml)).To(Succeed())
+					Expect(config.Process()).To(MatchError("Backend client certificates must have a name"))
+				})
+			})
+		})
+
+	})
+
+	Describe("ReloadableChanges", func() {
+		It("returns nothing when the reloadable settings are the same", func() {
+			other, err := DefaultConfig()
+			Expect(err).ToNot(HaveOccurred())
+			other.Port = 8081
+
+			Expect(config.ReloadableChanges(other)).To(BeEmpty())
 		})
 
+		It("returns the reloadable settings that changed", func() {
+			other, err := DefaultConfig()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(other.Initialize([]byte(`
+endpoint_timeout: 30s
+logging:
+  level: info
+rate_limit:
+  requests_per_second: 10
+header_rules:
+- request:
+    remove: [X-Internal-Token]
+`))).To(Succeed())
+			Expect(other.Process()).To(Succeed())
+
+			Expect(config.ReloadableChanges(other)).To(ConsistOf(
+				"endpoint_timeout", "logging.level", "rate_limit", "header_rules",
+			))
+		})
 	})
 })
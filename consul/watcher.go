@@ -0,0 +1,225 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+)
+
+// Watcher registers the healthy instances of the services in a Consul catalog
+// that are tagged with routes. It waits for changes to the catalog with
+// blocking queries, which return at least every quarter of the droplet stale
+// threshold, so that the routes are registered again before they are pruned.
+type Watcher struct {
+	RouteRegistry registry.Registry
+
+	cfg        config.ConsulConfig
+	wait       time.Duration
+	client     *http.Client
+	logger     logger.Logger
+	registered map[string]registration
+}
+
+type registration struct {
+	uri      route.Uri
+	endpoint *route.Endpoint
+}
+
+// serviceEntry is an element of the response of /v1/health/service/:service.
+type serviceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		ID      string
+		Service string
+		Tags    []string
+		Address string
+		Port    uint16
+	}
+}
+
+func NewWatcher(routeRegistry registry.Registry, c *config.Config, l logger.Logger) *Watcher {
+	return &Watcher{
+		RouteRegistry: routeRegistry,
+		cfg:           c.Consul,
+		wait:          c.DropletStaleThreshold / 4,
+		client:        &http.Client{},
+		logger:        l,
+		registered:    map[string]registration{},
+	}
+}
+
+func (w *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.watch(ctx)
+		close(done)
+	}()
+
+	close(ready)
+	w.logger.Info("consul-watcher-started", zap.String("address", w.cfg.Address))
+
+	<-signals
+	cancel()
+	<-done
+	w.logger.Info("exited")
+	return nil
+}
+
+func (w *Watcher) watch(ctx context.Context) {
+	var index uint64
+	for {
+		services, newIndex, err := w.services(ctx, index)
+		if err == nil {
+			err = w.Sync(ctx, services)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.logger.Error("consul-sync-failed", zap.Error(err))
+			index = 0
+			select {
+			case <-time.After(w.cfg.RetryInterval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// an index that goes backwards means the state of Consul was reset
+		if newIndex < index {
+			newIndex = 0
+		}
+		index = newIndex
+	}
+}
+
+// services returns the names of the services with route tags, once the index
+// of the catalog differs from index or the wait time has passed.
+func (w *Watcher) services(ctx context.Context, index uint64) ([]string, uint64, error) {
+	query := url.Values{}
+	query.Set("index", strconv.FormatUint(index, 10))
+	query.Set("wait", fmt.Sprintf("%dms", w.wait.Milliseconds()))
+
+	var catalog map[string][]string
+	newIndex, err := w.get(ctx, "/v1/catalog/services", query, &catalog)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var services []string
+	for name, tags := range catalog {
+		if len(w.routes(tags)) > 0 {
+			services = append(services, name)
+		}
+	}
+	return services, newIndex, nil
+}
+
+// Sync registers the routes of the healthy instances of the services, and
+// unregisters the routes it registered before that are no longer among them.
+// Nothing changes if the instances of any service cannot be fetched.
+func (w *Watcher) Sync(ctx context.Context, services []string) error {
+	current := map[string]registration{}
+	for _, name := range services {
+		query := url.Values{}
+		query.Set("passing", "true")
+
+		var entries []serviceEntry
+		if _, err := w.get(ctx, "/v1/health/service/"+url.PathEscape(name), query, &entries); err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			host := entry.Service.Address
+			if host == "" {
+				host = entry.Node.Address
+			}
+			endpoint := route.NewEndpoint(&route.EndpointOpts{
+				Host:              host,
+				Port:              entry.Service.Port,
+				PrivateInstanceId: entry.Service.ID,
+				Tags:              map[string]string{"consul_service": entry.Service.Service},
+			})
+			for _, uri := range w.routes(entry.Service.Tags) {
+				current[uri.RouteKey().String()+" "+endpoint.CanonicalAddr()] = registration{uri: uri, endpoint: endpoint}
+			}
+		}
+	}
+
+	for _, r := range current {
+		w.RouteRegistry.Register(r.uri, r.endpoint)
+	}
+	unregistered := 0
+	for key, r := range w.registered {
+		if _, ok := current[key]; !ok {
+			w.RouteRegistry.Unregister(r.uri, r.endpoint)
+			unregistered++
+		}
+	}
+	w.registered = current
+
+	w.logger.Debug("consul-synced", zap.Int("registered", len(current)), zap.Int("unregistered", unregistered))
+	return nil
+}
+
+func (w *Watcher) routes(tags []string) []route.Uri {
+	var uris []route.Uri
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, w.cfg.RouteTagPrefix) && len(tag) > len(w.cfg.RouteTagPrefix) {
+			uris = append(uris, route.Uri(strings.TrimPrefix(tag, w.cfg.RouteTagPrefix)))
+		}
+	}
+	return uris
+}
+
+// get decodes the response of a request to the HTTP API of Consul into v,
+// and returns its index.
+func (w *Watcher) get(ctx context.Context, path string, query url.Values, v interface{}) (uint64, error) {
+	if w.cfg.Datacenter != "" {
+		query.Set("dc", w.cfg.Datacenter)
+	}
+
+	// Consul adds up to a sixteenth of the wait time to blocking queries
+	ctx, cancel := context.WithTimeout(ctx, 2*w.wait+10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(w.cfg.Address, "/")+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if w.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", w.cfg.Token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return 0, fmt.Errorf("GET %s: %s", path, err)
+	}
+
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return index, nil
+}
@@ -0,0 +1,185 @@
+package consul_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/consul"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeConsul answers blocking queries on the catalog once its index changes,
+// or after 100ms.
+type fakeConsul struct {
+	sync.Mutex
+	index     uint64
+	status    int
+	catalog   map[string][]string
+	instances map[string]string
+	headers   []http.Header
+	queries   []string
+}
+
+func (f *fakeConsul) set(catalog map[string][]string, instances map[string]string) {
+	f.Lock()
+	defer f.Unlock()
+	f.index++
+	f.catalog = catalog
+	f.instances = instances
+}
+
+func (f *fakeConsul) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	f.headers = append(f.headers, r.Header)
+	f.queries = append(f.queries, r.URL.RawQuery)
+	f.Unlock()
+
+	if r.URL.Path == "/v1/catalog/services" {
+		index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+		for deadline := time.Now().Add(100 * time.Millisecond); time.Now().Before(deadline); {
+			f.Lock()
+			changed := f.index != index
+			f.Unlock()
+			if changed {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if f.status != 0 {
+		rw.WriteHeader(f.status)
+		return
+	}
+	rw.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	switch r.URL.Path {
+	case "/v1/catalog/services":
+		json.NewEncoder(rw).Encode(f.catalog)
+	default:
+		name := r.URL.Path[len("/v1/health/service/"):]
+		fmt.Fprint(rw, f.instances[name])
+	}
+}
+
+var _ = Describe("Watcher", func() {
+	var (
+		fake    *fakeConsul
+		server  *httptest.Server
+		cfg     *config.Config
+		r       *registry.RouteRegistry
+		process ifrit.Process
+	)
+
+	addresses := func(uri string) []string {
+		pool := r.Lookup(route.Uri(uri))
+		if pool == nil {
+			return nil
+		}
+		var addrs []string
+		pool.Each(func(e *route.Endpoint) {
+			addrs = append(addrs, e.CanonicalAddr())
+		})
+		sort.Strings(addrs)
+		return addrs
+	}
+
+	BeforeEach(func() {
+		fake = &fakeConsul{}
+		fake.set(map[string][]string{
+			"web": {"gorouter.route=web.example.com", "gorouter.route=www.example.com/web"},
+			"db":  {"primary"},
+		}, map[string]string{
+			"web": `[
+				{"Node": {"Address": "10.0.0.1"}, "Service": {"ID": "web-1", "Service": "web", "Tags": ["gorouter.route=web.example.com", "gorouter.route=www.example.com/web"], "Port": 8080}},
+				{"Node": {"Address": "10.0.0.2"}, "Service": {"ID": "web-2", "Service": "web", "Tags": ["gorouter.route=web.example.com"], "Address": "10.0.1.2", "Port": 8080}}
+			]`,
+		})
+		server = httptest.NewServer(fake)
+
+		var err error
+		cfg, err = config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+		cfg.Consul.Address = server.URL
+		cfg.Consul.RetryInterval = 10 * time.Millisecond
+
+		l := test_util.NewTestZapLogger("consul-test")
+		r = registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(consul.NewWatcher(r, cfg, test_util.NewTestZapLogger("consul-test")))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		server.Close()
+	})
+
+	It("registers the healthy instances of services under the routes they are tagged with", func() {
+		Eventually(func() []string { return addresses("web.example.com") }).Should(Equal([]string{"10.0.0.1:8080", "10.0.1.2:8080"}))
+		Eventually(func() []string { return addresses("www.example.com/web") }).Should(Equal([]string{"10.0.0.1:8080"}))
+
+		fake.Lock()
+		defer fake.Unlock()
+		Expect(fake.queries).To(ContainElement("passing=true"))
+	})
+
+	It("unregisters instances that are no longer in the catalog", func() {
+		Eventually(func() []string { return addresses("web.example.com") }).Should(HaveLen(2))
+
+		fake.set(map[string][]string{
+			"web": {"gorouter.route=web.example.com"},
+		}, map[string]string{
+			"web": `[{"Node": {"Address": "10.0.0.1"}, "Service": {"ID": "web-1", "Service": "web", "Tags": ["gorouter.route=web.example.com"], "Port": 8080}}]`,
+		})
+
+		Eventually(func() []string { return addresses("web.example.com") }).Should(Equal([]string{"10.0.0.1:8080"}))
+		Eventually(func() []string { return addresses("www.example.com/web") }).Should(BeEmpty())
+	})
+
+	It("keeps the routes while Consul fails", func() {
+		Eventually(func() []string { return addresses("web.example.com") }).Should(HaveLen(2))
+
+		fake.Lock()
+		fake.status = http.StatusInternalServerError
+		fake.Unlock()
+
+		Consistently(func() []string { return addresses("web.example.com") }, 200*time.Millisecond).Should(HaveLen(2))
+	})
+
+	Context("when a token and datacenter are configured", func() {
+		BeforeEach(func() {
+			cfg.Consul.Token = "secret"
+			cfg.Consul.Datacenter = "dc2"
+		})
+
+		It("sends them with every request", func() {
+			Eventually(func() []string { return addresses("web.example.com") }).Should(HaveLen(2))
+
+			fake.Lock()
+			defer fake.Unlock()
+			for i := range fake.headers {
+				Expect(fake.headers[i].Get("X-Consul-Token")).To(Equal("secret"))
+				Expect(fake.queries[i]).To(ContainSubstring("dc=dc2"))
+			}
+		})
+	})
+})
@@ -60,6 +60,7 @@ func (a *accessLog) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http
 	alr.BodyBytesSent = proxyWriter.Size()
 	alr.FinishedAt = time.Now()
 	alr.StatusCode = proxyWriter.Status()
+	alr.ResponseHeaders = proxyWriter.Header()
 	a.accessLogger.Log(*alr)
 }
 
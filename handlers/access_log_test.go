@@ -47,6 +47,7 @@ var _ = Describe("AccessLog", func() {
 		_, err := ioutil.ReadAll(req.Body)
 		Expect(err).NotTo(HaveOccurred())
 
+		rw.Header().Set("X-Teapot", "short")
 		rw.WriteHeader(http.StatusTeapot)
 		rw.Write([]byte("I'm a little teapot, short and stout."))
 
@@ -112,6 +113,7 @@ var _ = Describe("AccessLog", func() {
 		Expect(alr.RequestBytesReceived).To(Equal(13))
 		Expect(alr.BodyBytesSent).To(Equal(37))
 		Expect(alr.StatusCode).To(Equal(http.StatusTeapot))
+		Expect(alr.ResponseHeaders.Get("X-Teapot")).To(Equal("short"))
 		Expect(alr.RouteEndpoint).To(Equal(testEndpoint))
 		Expect(alr.HeadersOverride).To(BeNil())
 	})
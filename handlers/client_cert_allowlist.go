@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type clientCertAllowlist struct {
+	skip   func(req *http.Request) (bool, error)
+	logger logger.Logger
+}
+
+// NewClientCertAllowlist creates a handler that rejects requests to routes
+// that registered a client certificate allowlist, unless the client presented
+// a certificate the allowlist allows. Requests for which skip returns true,
+// such as those coming back from a route service, are not checked again.
+func NewClientCertAllowlist(skip func(req *http.Request) (bool, error), logger logger.Logger) negroni.Handler {
+	return &clientCertAllowlist{
+		skip:   skip,
+		logger: logger,
+	}
+}
+
+func (c *clientCertAllowlist) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		c.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if reqInfo.RoutePool == nil {
+		c.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+		return
+	}
+
+	allowlist := reqInfo.RoutePool.AllowedClientCerts()
+	if allowlist.IsEmpty() {
+		next(rw, r)
+		return
+	}
+
+	skip, err := c.skip(r)
+	if err != nil {
+		c.logger.Error("signature-validation-failed", zap.Error(err))
+		writeStatus(
+			rw,
+			http.StatusBadRequest,
+			"Failed to validate Route Service Signature",
+			c.logger,
+		)
+		return
+	}
+	if skip {
+		next(rw, r)
+		return
+	}
+
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		c.logger.Info("client-cert-missing")
+		rw.Header().Set("X-Cf-RouterError", "client_cert_not_allowed")
+		writeStatus(
+			rw,
+			http.StatusForbidden,
+			"A client certificate is required for this route.",
+			c.logger,
+		)
+		return
+	}
+	cert := r.TLS.PeerCertificates[0]
+	if !allowlist.Allows(cert) {
+		c.logger.Info("client-cert-not-allowed", zap.String("subject", cert.Subject.String()))
+		rw.Header().Set("X-Cf-RouterError", "client_cert_not_allowed")
+		writeStatus(
+			rw,
+			http.StatusForbidden,
+			"The client certificate is not allowed for this route.",
+			c.logger,
+		)
+		return
+	}
+
+	next(rw, r)
+}
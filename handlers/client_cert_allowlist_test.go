@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("ClientCertAllowlist", func() {
+	var (
+		endpointOpts *route.EndpointOpts
+		skip         func(req *http.Request) (bool, error)
+		req          *http.Request
+		nextCalled   bool
+	)
+
+	process := func() *httptest.ResponseRecorder {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool")})
+		pool.Put(route.NewEndpoint(endpointOpts))
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		n.Use(handlers.NewClientCertAllowlist(skip, new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(http.ResponseWriter, *http.Request) { nextCalled = true })
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	withClientCert := func(cert *x509.Certificate) {
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	}
+
+	BeforeEach(func() {
+		endpointOpts = &route.EndpointOpts{
+			Host:                  "1.1.1.1",
+			Port:                  8080,
+			AllowedClientCertSANs: []string{"billing.internal"},
+			AllowedClientCertOUs:  []string{"payments"},
+		}
+		skip = func(*http.Request) (bool, error) { return false, nil }
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		nextCalled = false
+	})
+
+	It("allows a client certificate with an allowed SAN", func() {
+		withClientCert(&x509.Certificate{DNSNames: []string{"other.internal", "billing.internal"}})
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	It("allows a client certificate with an allowed OU", func() {
+		withClientCert(&x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"payments"}}})
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	It("rejects a client certificate the allowlist does not allow", func() {
+		withClientCert(&x509.Certificate{
+			DNSNames: []string{"other.internal"},
+			Subject:  pkix.Name{OrganizationalUnit: []string{"marketing"}},
+		})
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusForbidden))
+		Expect(res.Header().Get("X-Cf-RouterError")).To(Equal("client_cert_not_allowed"))
+		Expect(nextCalled).To(BeFalse())
+	})
+
+	It("rejects requests without a client certificate", func() {
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusForbidden))
+		Expect(nextCalled).To(BeFalse())
+	})
+
+	Context("when the route registered no allowlist", func() {
+		BeforeEach(func() {
+			endpointOpts = &route.EndpointOpts{Host: "1.1.1.1", Port: 8080}
+		})
+
+		It("allows requests without a client certificate", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+
+	Context("when the request is not checked again", func() {
+		BeforeEach(func() {
+			skip = func(*http.Request) (bool, error) { return true, nil }
+		})
+
+		It("allows requests without a client certificate", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+
+	Context("when the route service signature fails to validate", func() {
+		BeforeEach(func() {
+			skip = func(*http.Request) (bool, error) { return false, errors.New("bad signature") }
+		})
+
+		It("responds with a bad request", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusBadRequest))
+			Expect(nextCalled).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type clientIPAccess struct {
+	platform route.IPAccessList
+	proxies  TrustedProxies
+	skip     func(req *http.Request) (bool, error)
+	logger   logger.Logger
+}
+
+// NewClientIPAccess creates a handler that rejects requests from client IPs
+// that the platform list in cfg, or the list the route registered, does not
+// allow. The client IP is the address of the peer, or the address that a
+// trusted proxy forwarded for. Requests for which skip returns true, such as
+// those coming back from a route service, are not checked again.
+func NewClientIPAccess(cfg config.ClientIPAccessConfig, proxies TrustedProxies, skip func(req *http.Request) (bool, error), logger logger.Logger) negroni.Handler {
+	return &clientIPAccess{
+		platform: route.IPAccessList{Allow: cfg.AllowedNets, Deny: cfg.DeniedNets},
+		proxies:  proxies,
+		skip:     skip,
+		logger:   logger,
+	}
+}
+
+func (c *clientIPAccess) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		c.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if reqInfo.RoutePool == nil {
+		c.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+		return
+	}
+
+	routeList := reqInfo.RoutePool.ClientIPAccess()
+	if c.platform.IsEmpty() && routeList.IsEmpty() {
+		next(rw, r)
+		return
+	}
+
+	skip, err := c.skip(r)
+	if err != nil {
+		c.logger.Error("signature-validation-failed", zap.Error(err))
+		writeStatus(
+			rw,
+			http.StatusBadRequest,
+			"Failed to validate Route Service Signature",
+			c.logger,
+		)
+		return
+	}
+	if skip {
+		next(rw, r)
+		return
+	}
+
+	ip := c.proxies.ClientIP(r)
+	if ip == nil || !c.platform.Allows(ip) || !routeList.Allows(ip) {
+		c.logger.Info("client-ip-not-allowed", zap.String("client-ip", ip.String()))
+		rw.Header().Set("X-Cf-RouterError", "client_ip_not_allowed")
+		writeStatus(
+			rw,
+			http.StatusForbidden,
+			"The client IP is not allowed for this route.",
+			c.logger,
+		)
+		return
+	}
+
+	next(rw, r)
+}
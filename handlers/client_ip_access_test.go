@@ -0,0 +1,200 @@
+package handlers_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("ClientIPAccess", func() {
+	var (
+		cfg          config.ClientIPAccessConfig
+		proxies      handlers.TrustedProxies
+		endpointOpts *route.EndpointOpts
+		skip         func(req *http.Request) (bool, error)
+		req          *http.Request
+		nextCalled   bool
+	)
+
+	cidrs := func(cidrs ...string) []*net.IPNet {
+		var nets []*net.IPNet
+		for _, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			Expect(err).ToNot(HaveOccurred())
+			nets = append(nets, ipNet)
+		}
+		return nets
+	}
+
+	process := func() *httptest.ResponseRecorder {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool")})
+		pool.Put(route.NewEndpoint(endpointOpts))
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		n.Use(handlers.NewClientIPAccess(cfg, proxies, skip, new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(http.ResponseWriter, *http.Request) { nextCalled = true })
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	BeforeEach(func() {
+		cfg = config.ClientIPAccessConfig{}
+		proxies = handlers.TrustedProxies{}
+		endpointOpts = &route.EndpointOpts{
+			Host:              "1.1.1.1",
+			Port:              8080,
+			AllowedClientNets: cidrs("10.0.0.0/8"),
+			DeniedClientNets:  cidrs("10.1.0.0/16"),
+		}
+		skip = func(*http.Request) (bool, error) { return false, nil }
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		nextCalled = false
+	})
+
+	It("allows clients in the allowlist of the route", func() {
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	It("rejects clients outside of the allowlist of the route", func() {
+		req.RemoteAddr = "192.168.0.1:1234"
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusForbidden))
+		Expect(res.Header().Get("X-Cf-RouterError")).To(Equal("client_ip_not_allowed"))
+		Expect(nextCalled).To(BeFalse())
+	})
+
+	It("rejects clients in the denylist of the route, even if it allows them", func() {
+		req.RemoteAddr = "10.1.2.3:1234"
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusForbidden))
+		Expect(nextCalled).To(BeFalse())
+	})
+
+	It("ignores X-Forwarded-For from untrusted peers", func() {
+		req.RemoteAddr = "192.168.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusForbidden))
+	})
+
+	Context("when the platform denies a client", func() {
+		BeforeEach(func() {
+			cfg.DeniedNets = cidrs("10.0.0.0/24")
+		})
+
+		It("rejects it whatever the route allows", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("still applies to routes without lists", func() {
+			endpointOpts = &route.EndpointOpts{Host: "1.1.1.1", Port: 8080}
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Context("when the request comes from a trusted proxy", func() {
+		BeforeEach(func() {
+			proxies = handlers.TrustedProxies{
+				Nets: cidrs("172.16.0.0/12"),
+			}
+			req.RemoteAddr = "172.16.0.5:1234"
+		})
+
+		It("checks the last address in X-Forwarded-For that is not trusted", func() {
+			req.Header.Set("X-Forwarded-For", "192.168.0.1, 10.0.0.1, 172.16.0.4")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+		})
+
+		It("does not let clients make up their address", func() {
+			req.Header.Set("X-Forwarded-For", "10.0.0.1, 192.168.0.1")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("rejects malformed addresses", func() {
+			req.Header.Set("X-Forwarded-For", "10.0.0.1, unknown")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusForbidden))
+		})
+
+		Context("when the proxy reports clients in Forwarded", func() {
+			BeforeEach(func() {
+				proxies.ForwardedHeader = config.ForwardedHeaderForwarded
+				req.Header.Set("X-Forwarded-For", "192.168.0.1")
+			})
+
+			It("checks the last address in Forwarded that is not trusted", func() {
+				req.Header.Add("Forwarded", `for=192.168.0.1;proto=https, for="10.0.0.1:4711"`)
+				req.Header.Add("Forwarded", "for=172.16.0.4")
+
+				res := process()
+				Expect(res.Code).To(Equal(http.StatusOK))
+			})
+
+			It("does not let clients make up their address", func() {
+				req.Header.Set("Forwarded", "for=10.0.0.1, for=192.168.0.1")
+
+				res := process()
+				Expect(res.Code).To(Equal(http.StatusForbidden))
+			})
+
+			It("rejects nodes that are not addresses", func() {
+				req.Header.Set("Forwarded", "for=10.0.0.1, for=unknown")
+
+				res := process()
+				Expect(res.Code).To(Equal(http.StatusForbidden))
+			})
+
+			It("rejects a malformed header", func() {
+				req.Header.Set("Forwarded", `for="10.0.0.1`)
+
+				res := process()
+				Expect(res.Code).To(Equal(http.StatusForbidden))
+			})
+		})
+	})
+
+	Context("when the request is not checked again", func() {
+		BeforeEach(func() {
+			skip = func(*http.Request) (bool, error) { return true, nil }
+			req.RemoteAddr = "192.168.0.1:1234"
+		})
+
+		It("allows it", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+})
@@ -43,7 +43,7 @@ func (c *clientCert) ServeHTTP(rw http.ResponseWriter, r *http.Request, next htt
 		return
 	}
 	if !skip {
-		switch c.forwardingMode {
+		switch ForwardedClientCertMode(r, c.forwardingMode) {
 		case config.FORWARD:
 			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
 				r.Header.Del(xfcc)
@@ -73,6 +73,17 @@ func (c *clientCert) ServeHTTP(rw http.ResponseWriter, r *http.Request, next htt
 	next(rw, r)
 }
 
+// ForwardedClientCertMode returns the X-Forwarded-Client-Cert mode that the
+// route of the request registered, or defaultMode if it registered none or
+// the route has not been looked up.
+func ForwardedClientCertMode(r *http.Request, defaultMode string) string {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil || reqInfo.RoutePool == nil {
+		return defaultMode
+	}
+	return reqInfo.RoutePool.ForwardedClientCert(defaultMode)
+}
+
 func sanitizeHeader(r *http.Request) {
 	// we only care about the first cert at this moment
 	if len(r.TLS.PeerCertificates) > 0 {
@@ -11,6 +11,7 @@ import (
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -191,6 +192,37 @@ var _ = Describe("Clientcert", func() {
 		Entry("when dontForceDeleteHeader, dontSkipSanitization, and config.FORWARD", dontForceDeleteHeader, dontSkipSanitization, config.FORWARD, stripCertNoTLS, stripCertTLS, xfccSanitizeMTLS),
 		Entry("when dontForceDeleteHeader, dontSkipSanitization, and config.ALWAYS_FORWARD", dontForceDeleteHeader, dontSkipSanitization, config.ALWAYS_FORWARD, noStripCertNoTLS, noStripCertTLS, xfccSanitizeMTLS),
 	)
+
+	DescribeTable("Client Cert mode registered by the route", func(forwardedClientCert, registeredMode string, stripped bool) {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool")})
+		pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 8080, ForwardedClientCert: registeredMode}))
+
+		nextReq := &http.Request{}
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		n.Use(handlers.NewClientCert(dontSkipSanitization, dontForceDeleteHeader, forwardedClientCert, new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(_ http.ResponseWriter, r *http.Request) { nextReq = r })
+
+		req := test_util.NewRequest("GET", "xyz.com", "", nil)
+		req.Header.Add("X-Forwarded-Client-Cert", "trusted-xfcc-header")
+		n.ServeHTTP(httptest.NewRecorder(), req)
+
+		if stripped {
+			Expect(nextReq.Header).NotTo(HaveKey("X-Forwarded-Client-Cert"))
+		} else {
+			Expect(nextReq.Header["X-Forwarded-Client-Cert"]).To(Equal([]string{"trusted-xfcc-header"}))
+		}
+	},
+		Entry("when the route registered no mode", config.ALWAYS_FORWARD, "", false),
+		Entry("when the route registered config.SANITIZE_SET", config.ALWAYS_FORWARD, config.SANITIZE_SET, true),
+		Entry("when the route registered config.ALWAYS_FORWARD", config.SANITIZE_SET, config.ALWAYS_FORWARD, false),
+	)
 })
 
 func sanitize(cert []byte) string {
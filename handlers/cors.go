@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+// methods and headers that browsers send cross-origin without asking first
+var (
+	corsSimpleMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	corsSimpleHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type"}
+)
+
+type cors struct {
+	logger logger.Logger
+}
+
+// NewCORS creates a handler that answers CORS preflight requests to routes
+// that registered a CORS policy, without proxying them to a backend, and sets
+// Access-Control-Allow-Origin on the responses to other requests from allowed
+// origins. Requests to routes without a policy are left to the backends.
+func NewCORS(logger logger.Logger) negroni.Handler {
+	return &cors{
+		logger: logger,
+	}
+}
+
+func (c *cors) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		c.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if reqInfo.RoutePool == nil {
+		c.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+		return
+	}
+
+	policy := reqInfo.RoutePool.CORS()
+	origin := r.Header.Get("Origin")
+	if policy.IsEmpty() || origin == "" {
+		next(rw, r)
+		return
+	}
+
+	allowOrigin := ""
+	if corsAllows(policy.AllowedOrigins, origin, false) {
+		allowOrigin = origin
+		if corsAllows(policy.AllowedOrigins, "*", false) {
+			allowOrigin = "*"
+		}
+	}
+
+	requestMethod := r.Header.Get("Access-Control-Request-Method")
+	if r.Method == http.MethodOptions && requestMethod != "" {
+		c.preflight(rw, r, policy, allowOrigin, requestMethod)
+		return
+	}
+
+	// the policy of the route replaces whatever the backend allows
+	rw.(utils.ProxyResponseWriter).AddHeaderRewriter(&corsHeaderRewriter{allowOrigin: allowOrigin})
+	next(rw, r)
+}
+
+func (c *cors) preflight(rw http.ResponseWriter, r *http.Request, policy route.CORSPolicy, allowOrigin, requestMethod string) {
+	methods := policy.AllowedMethods
+	if len(methods) == 0 {
+		methods = corsSimpleMethods
+	}
+
+	var requestHeaders []string
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				requestHeaders = append(requestHeaders, header)
+			}
+		}
+	}
+
+	allowed := allowOrigin != "" && corsAllows(methods, requestMethod, false)
+	for _, header := range requestHeaders {
+		if !corsAllows(corsSimpleHeaders, header, true) && !corsAllows(policy.AllowedHeaders, header, true) {
+			allowed = false
+		}
+	}
+	if !allowed {
+		c.logger.Info("cors-preflight-not-allowed",
+			zap.String("origin", r.Header.Get("Origin")),
+			zap.String("method", requestMethod),
+		)
+		rw.Header().Set("X-Cf-RouterError", "cors_not_allowed")
+		writeStatus(
+			rw,
+			http.StatusForbidden,
+			"The cross-origin request is not allowed for this route.",
+			c.logger,
+		)
+		return
+	}
+
+	header := rw.Header()
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if allowOrigin != "*" {
+		header.Add("Vary", "Origin")
+	}
+	header.Set("Access-Control-Allow-Methods", requestMethod)
+	if len(requestHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+	}
+	if policy.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// corsAllows reports whether value is in allowed, or allowed contains "*".
+// Header names are compared without regard to case, and methods and origins
+// as written.
+func corsAllows(allowed []string, value string, ignoreCase bool) bool {
+	for _, a := range allowed {
+		if a == "*" || a == value || (ignoreCase && strings.EqualFold(a, value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsHeaderRewriter replaces the Access-Control-Allow-Origin header of a
+// response, removing it when the origin is not allowed.
+type corsHeaderRewriter struct {
+	allowOrigin string
+}
+
+func (c *corsHeaderRewriter) RewriteHeader(header http.Header) {
+	header.Del("Access-Control-Allow-Origin")
+	if c.allowOrigin == "" {
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", c.allowOrigin)
+	if c.allowOrigin != "*" {
+		header.Add("Vary", "Origin")
+	}
+}
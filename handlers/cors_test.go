@@ -0,0 +1,177 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("CORS", func() {
+	var (
+		endpointOpts *route.EndpointOpts
+		req          *http.Request
+		nextCalled   bool
+	)
+
+	process := func() *httptest.ResponseRecorder {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool")})
+		pool.Put(route.NewEndpoint(endpointOpts))
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.Use(handlers.NewProxyWriter(new(logger_fakes.FakeLogger)))
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		n.Use(handlers.NewCORS(new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			nextCalled = true
+			rw.Header().Set("Access-Control-Allow-Origin", "https://backend.example.com")
+			rw.WriteHeader(http.StatusOK)
+		})
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	BeforeEach(func() {
+		endpointOpts = &route.EndpointOpts{
+			Host:                "1.1.1.1",
+			Port:                8080,
+			CORSAllowedOrigins:  []string{"https://app.example.com"},
+			CORSAllowedMethods:  []string{"GET", "PUT"},
+			CORSAllowedHeaders:  []string{"Authorization"},
+			CORSMaxAgeInSeconds: 600,
+		}
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		nextCalled = false
+	})
+
+	Context("when the request is a preflight", func() {
+		BeforeEach(func() {
+			req.Method = http.MethodOptions
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+			req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		})
+
+		It("answers it without calling the next handler", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusNoContent))
+			Expect(res.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.com"))
+			Expect(res.Header().Get("Access-Control-Allow-Methods")).To(Equal("PUT"))
+			Expect(res.Header().Get("Access-Control-Allow-Headers")).To(Equal("authorization, content-type"))
+			Expect(res.Header().Get("Access-Control-Max-Age")).To(Equal("600"))
+			Expect(res.Header().Get("Vary")).To(Equal("Origin"))
+			Expect(nextCalled).To(BeFalse())
+		})
+
+		It("rejects origins that are not allowed", func() {
+			req.Header.Set("Origin", "https://evil.example.com")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusForbidden))
+			Expect(res.Header().Get("X-Cf-RouterError")).To(Equal("cors_not_allowed"))
+			Expect(res.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+			Expect(nextCalled).To(BeFalse())
+		})
+
+		It("rejects methods that are not allowed", func() {
+			req.Header.Set("Access-Control-Request-Method", "DELETE")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("rejects headers that are not allowed", func() {
+			req.Header.Set("Access-Control-Request-Headers", "x-api-key")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusForbidden))
+		})
+
+		Context("when the route allows any origin, method and header", func() {
+			BeforeEach(func() {
+				endpointOpts.CORSAllowedOrigins = []string{"*"}
+				endpointOpts.CORSAllowedMethods = []string{"*"}
+				endpointOpts.CORSAllowedHeaders = []string{"*"}
+				req.Header.Set("Access-Control-Request-Method", "DELETE")
+				req.Header.Set("Access-Control-Request-Headers", "x-api-key")
+			})
+
+			It("allows them", func() {
+				res := process()
+				Expect(res.Code).To(Equal(http.StatusNoContent))
+				Expect(res.Header().Get("Access-Control-Allow-Origin")).To(Equal("*"))
+				Expect(res.Header().Get("Vary")).To(BeEmpty())
+			})
+		})
+
+		Context("when the route does not list methods", func() {
+			BeforeEach(func() {
+				endpointOpts.CORSAllowedMethods = nil
+			})
+
+			It("only allows simple methods", func() {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+				Expect(process().Code).To(Equal(http.StatusNoContent))
+
+				req.Header.Set("Access-Control-Request-Method", "PUT")
+				Expect(process().Code).To(Equal(http.StatusForbidden))
+			})
+		})
+	})
+
+	It("replaces the allowed origin of the backend on other requests", func() {
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.com"))
+		Expect(res.Header().Get("Vary")).To(Equal("Origin"))
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	It("removes the allowed origin of the backend for origins that are not allowed", func() {
+		req.Header.Set("Origin", "https://evil.example.com")
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+		Expect(nextCalled).To(BeTrue())
+	})
+
+	Context("when the route has no CORS policy", func() {
+		BeforeEach(func() {
+			endpointOpts = &route.EndpointOpts{Host: "1.1.1.1", Port: 8080}
+			req.Method = http.MethodOptions
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+		})
+
+		It("leaves requests to the backend", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://backend.example.com"))
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+
+	It("sets no max age when the route registered none", func() {
+		endpointOpts.CORSMaxAgeInSeconds = 0
+		req.Method = http.MethodOptions
+		req.Header.Set("Access-Control-Request-Method", "GET")
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusNoContent))
+		Expect(res.Header()).ToNot(HaveKey("Access-Control-Max-Age"))
+	})
+})
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type drainRequestBody struct {
+	maxBytes int64
+	logger   logger.Logger
+}
+
+// NewDrainRequestBody creates a handler that reads and discards up to maxBytes
+// of the request body before the router writes an error response for a
+// request that was never forwarded to a backend. This lets the client reuse
+// its keep-alive connection instead of having it closed because of unread
+// request data.
+func NewDrainRequestBody(maxBytes int64, logger logger.Logger) negroni.Handler {
+	return &drainRequestBody{
+		maxBytes: maxBytes,
+		logger:   logger,
+	}
+}
+
+func (d *drainRequestBody) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		d.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	next(&drainingResponseWriter{
+		ProxyResponseWriter: rw.(utils.ProxyResponseWriter),
+		request:             r,
+		reqInfo:             reqInfo,
+		maxBytes:            d.maxBytes,
+		logger:              d.logger,
+	}, r)
+}
+
+type drainingResponseWriter struct {
+	utils.ProxyResponseWriter
+	request  *http.Request
+	reqInfo  *RequestInfo
+	maxBytes int64
+	logger   logger.Logger
+}
+
+func (w *drainingResponseWriter) WriteHeader(s int) {
+	// Responses from backends are left alone: the transport owns the request
+	// body once the request has been forwarded.
+	if s >= http.StatusBadRequest && w.reqInfo.RouteEndpoint == nil && w.request.Body != nil {
+		n, err := io.CopyN(ioutil.Discard, w.request.Body, w.maxBytes)
+		if err != nil && err != io.EOF {
+			w.logger.Debug("drain-request-body-failed", zap.Error(err), zap.Int64("bytes-drained", n))
+		}
+	}
+
+	w.ProxyResponseWriter.WriteHeader(s)
+}
@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("DrainRequestBody", func() {
+	const bodySize = 512 * 1024
+
+	var (
+		server     *httptest.Server
+		conn       net.Conn
+		respReader *bufio.Reader
+		endpoint   *route.Endpoint
+		maxBytes   int64
+		useDrain   bool
+	)
+
+	sendRequest := func(path string) *http.Response {
+		body := strings.Repeat("a", bodySize)
+		_, err := fmt.Fprintf(conn, "POST %s HTTP/1.1\r\nHost: example.com\r\nContent-Length: %d\r\n\r\n%s", path, len(body), body)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := http.ReadResponse(respReader, nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		return resp
+	}
+
+	BeforeEach(func() {
+		maxBytes = 1024 * 1024
+		useDrain = true
+		endpoint = nil
+	})
+
+	JustBeforeEach(func() {
+		logger := test_util.NewTestZapLogger("drain-request-body")
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.Use(handlers.NewProxyWriter(logger))
+		if useDrain {
+			n.Use(handlers.NewDrainRequestBody(maxBytes, logger))
+		}
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RouteEndpoint = endpoint
+			next(rw, r)
+		})
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			http.Error(rw, "404 Not Found: Requested route does not exist.", http.StatusNotFound)
+		})
+
+		server = httptest.NewServer(n)
+
+		var err error
+		conn, err = net.Dial("tcp", server.Listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		respReader = bufio.NewReader(conn)
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		server.Close()
+	})
+
+	It("reuses the connection after a router-generated 404", func() {
+		resp := sendRequest("/first")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(resp.Close).To(BeFalse())
+
+		resp = sendRequest("/second")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	Context("when the handler is not used", func() {
+		BeforeEach(func() {
+			useDrain = false
+		})
+
+		It("closes the connection after the 404", func() {
+			resp := sendRequest("/first")
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(resp.Close).To(BeTrue())
+		})
+	})
+
+	Context("when the body is larger than the drain limit", func() {
+		BeforeEach(func() {
+			maxBytes = 1024
+		})
+
+		It("closes the connection after the 404", func() {
+			resp := sendRequest("/first")
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(resp.Close).To(BeTrue())
+		})
+	})
+
+	Context("when the request was forwarded to a backend", func() {
+		BeforeEach(func() {
+			endpoint = route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 8080})
+		})
+
+		It("does not drain the request body", func() {
+			resp := sendRequest("/first")
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(resp.Close).To(BeTrue())
+		})
+	})
+})
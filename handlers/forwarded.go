@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+var errInvalidForwarded = errors.New("invalid Forwarded header")
+
+// forwardedElement is one element of a Forwarded header (RFC 7239), added by
+// one proxy that handled the request.
+type forwardedElement struct {
+	For   string
+	By    string
+	Host  string
+	Proto string
+}
+
+// parseForwarded parses the elements of the Forwarded header values, in the
+// order the proxies added them.
+func parseForwarded(values []string) ([]forwardedElement, error) {
+	var elements []forwardedElement
+	for _, s := range values {
+		var element forwardedElement
+		pending := false
+		for {
+			s = strings.TrimLeft(s, " \t")
+			if s == "" {
+				break
+			}
+
+			i := tokenLen(s)
+			if i == 0 || i == len(s) || s[i] != '=' {
+				return nil, errInvalidForwarded
+			}
+			name := strings.ToLower(s[:i])
+			s = s[i+1:]
+
+			var value string
+			if strings.HasPrefix(s, `"`) {
+				var err error
+				value, s, err = readQuotedString(s)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				j := tokenLen(s)
+				if j == 0 {
+					return nil, errInvalidForwarded
+				}
+				value, s = s[:j], s[j:]
+			}
+
+			switch name {
+			case "for":
+				element.For = value
+			case "by":
+				element.By = value
+			case "host":
+				element.Host = value
+			case "proto":
+				element.Proto = value
+			}
+			pending = true
+
+			s = strings.TrimLeft(s, " \t")
+			if s == "" {
+				break
+			}
+			switch s[0] {
+			case ';':
+			case ',':
+				elements = append(elements, element)
+				element = forwardedElement{}
+				pending = false
+			default:
+				return nil, errInvalidForwarded
+			}
+			s = s[1:]
+		}
+		if pending {
+			elements = append(elements, element)
+		}
+	}
+	return elements, nil
+}
+
+// ip returns the address of the for node of e, without its port, or nil when
+// it is not an IP address, such as "unknown" or an obfuscated identifier.
+func (e forwardedElement) ip() net.IP {
+	node := e.For
+	if strings.HasPrefix(node, "[") {
+		i := strings.Index(node, "]")
+		if i < 0 {
+			return nil
+		}
+		return net.ParseIP(node[1:i])
+	}
+	if i := strings.Index(node, ":"); i >= 0 {
+		node = node[:i]
+	}
+	return net.ParseIP(node)
+}
+
+// clientForwardedElement returns the element added by the proxy that
+// received the request from the client, that is the last element whose for
+// node is not a trusted proxy itself, since earlier elements may have been
+// made up by the client. Elements whose for node is not an IP address are
+// returned as they cannot be trusted either.
+func clientForwardedElement(elements []forwardedElement, nets []*net.IPNet) forwardedElement {
+	for i := len(elements) - 1; i >= 0; i-- {
+		ip := elements[i].ip()
+		if ip == nil || !trusted(ip, nets) {
+			return elements[i]
+		}
+	}
+	return elements[0]
+}
+
+func tokenLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isTokenChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// readQuotedString reads the quoted string at the start of s, and returns it
+// unquoted along with the rest of s.
+func readQuotedString(s string) (string, string, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", errInvalidForwarded
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return "", "", errInvalidForwarded
+}
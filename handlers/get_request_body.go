@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type getRequestBody struct {
+	policy string
+	logger logger.Logger
+}
+
+// NewGetRequestBody creates a handler that applies the configured policy to
+// GET requests carrying a body. The body is either forwarded untouched,
+// stripped before the request is forwarded, or the request is rejected.
+func NewGetRequestBody(policy string, logger logger.Logger) negroni.Handler {
+	return &getRequestBody{
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func (g *getRequestBody) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.Method != http.MethodGet || !hasRequestBody(r) {
+		next(rw, r)
+		return
+	}
+
+	switch g.policy {
+	case config.GET_BODY_DROP:
+		g.logger.Debug("dropping-get-request-body", zap.Int64("content-length", r.ContentLength))
+		r.Body = http.NoBody
+		r.ContentLength = 0
+		r.TransferEncoding = nil
+		r.Header.Del("Content-Length")
+		r.Header.Del("Transfer-Encoding")
+	case config.GET_BODY_REJECT:
+		writeStatus(
+			rw,
+			http.StatusBadRequest,
+			"GET requests with a body are not allowed",
+			g.logger,
+		)
+		return
+	}
+
+	next(rw, r)
+}
+
+func hasRequestBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
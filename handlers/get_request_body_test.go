@@ -0,0 +1,94 @@
+package handlers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	"github.com/urfave/negroni"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GetRequestBody", func() {
+	var (
+		nextCalled  bool
+		nextBody    string
+		nextRequest *http.Request
+	)
+
+	process := func(policy string, req *http.Request) *httptest.ResponseRecorder {
+		nextCalled = false
+		nextBody = ""
+		nextRequest = nil
+
+		n := negroni.New()
+		n.Use(handlers.NewGetRequestBody(policy, test_util.NewTestZapLogger("get-request-body")))
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			nextCalled = true
+			nextRequest = r
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).ToNot(HaveOccurred())
+			nextBody = string(body)
+		})
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	getWithBody := func() *http.Request {
+		req := httptest.NewRequest("GET", "/foo", strings.NewReader("some body"))
+		req.Header.Set("Content-Length", "9")
+		return req
+	}
+
+	Context("when the policy is forward", func() {
+		It("forwards the body", func() {
+			res := process(config.GET_BODY_FORWARD, getWithBody())
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+			Expect(nextBody).To(Equal("some body"))
+			Expect(nextRequest.ContentLength).To(Equal(int64(9)))
+		})
+	})
+
+	Context("when the policy is drop", func() {
+		It("strips the body before calling the next handler", func() {
+			res := process(config.GET_BODY_DROP, getWithBody())
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+			Expect(nextBody).To(BeEmpty())
+			Expect(nextRequest.ContentLength).To(BeZero())
+			Expect(nextRequest.Header.Get("Content-Length")).To(BeEmpty())
+		})
+
+		It("does not touch the body of other methods", func() {
+			req := httptest.NewRequest("POST", "/foo", strings.NewReader("some body"))
+			process(config.GET_BODY_DROP, req)
+			Expect(nextCalled).To(BeTrue())
+			Expect(nextBody).To(Equal("some body"))
+		})
+	})
+
+	Context("when the policy is reject", func() {
+		It("responds with a 400 and does not call the next handler", func() {
+			res := process(config.GET_BODY_REJECT, getWithBody())
+			Expect(res.Code).To(Equal(http.StatusBadRequest))
+			Expect(res.Body.String()).To(ContainSubstring("GET requests with a body are not allowed"))
+			Expect(nextCalled).To(BeFalse())
+		})
+
+		It("allows GET requests without a body", func() {
+			res := process(config.GET_BODY_REJECT, httptest.NewRequest("GET", "/foo", nil))
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+// HeaderRules is the handler NewHeaderRules creates.
+type HeaderRules struct {
+	mu     sync.RWMutex
+	rules  []headerRule
+	logger logger.Logger
+}
+
+type headerRule struct {
+	domains  []string
+	routes   []string
+	request  []utils.HeaderRewriter
+	response []utils.HeaderRewriter
+}
+
+// NewHeaderRules creates a handler that applies the header rules that match
+// the domain and route of a request to its headers before it is proxied, and
+// to the headers of its response.
+func NewHeaderRules(cfg []config.HeaderRule, logger logger.Logger) negroni.Handler {
+	h := &HeaderRules{logger: logger}
+	h.Update(cfg)
+	return h
+}
+
+// Update replaces the rules with those of cfg. Requests in flight keep the
+// rules they started with.
+func (h *HeaderRules) Update(cfg []config.HeaderRule) {
+	rules := make([]headerRule, 0, len(cfg))
+	for _, c := range cfg {
+		rule := headerRule{
+			request:  headerRuleRewriters(c.Request),
+			response: headerRuleRewriters(c.Response),
+		}
+		for _, domain := range c.Domains {
+			rule.domains = append(rule.domains, strings.ToLower(domain))
+		}
+		for _, route := range c.Routes {
+			rule.routes = append(rule.routes, strings.TrimSuffix(strings.ToLower(route), "/"))
+		}
+		rules = append(rules, rule)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rules = rules
+}
+
+func (h *HeaderRules) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		h.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if reqInfo.RoutePool == nil {
+		h.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+		return
+	}
+
+	host := requestDomain(r)
+	route := strings.TrimSuffix(strings.ToLower(reqInfo.RoutePool.Host()+reqInfo.RoutePool.ContextPath()), "/")
+	h.mu.RLock()
+	rules := h.rules
+	h.mu.RUnlock()
+
+	proxyWriter := rw.(utils.ProxyResponseWriter)
+	for _, rule := range rules {
+		if len(rule.domains) > 0 && !domainMatches(rule.domains, host) {
+			continue
+		}
+		if len(rule.routes) > 0 && !routeMatches(rule.routes, route) {
+			continue
+		}
+		for _, rewriter := range rule.request {
+			rewriter.RewriteHeader(r.Header)
+		}
+		for _, rewriter := range rule.response {
+			proxyWriter.AddHeaderRewriter(rewriter)
+		}
+	}
+	next(rw, r)
+}
+
+func routeMatches(routes []string, route string) bool {
+	for _, r := range routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+func headerRuleRewriters(actions config.HeaderRuleActions) []utils.HeaderRewriter {
+	var rewriters []utils.HeaderRewriter
+	if len(actions.Remove) > 0 {
+		remove := http.Header{}
+		for _, name := range actions.Remove {
+			remove.Add(name, "")
+		}
+		rewriters = append(rewriters, &utils.RemoveHeaderRewriter{Header: remove})
+	}
+	if len(actions.Set) > 0 {
+		rewriters = append(rewriters, &utils.SetHeaderRewriter{Header: headerNameValuesToHTTPHeader(actions.Set)})
+	}
+	if len(actions.Add) > 0 {
+		rewriters = append(rewriters, &utils.AddHeaderRewriter{Header: headerNameValuesToHTTPHeader(actions.Add)})
+	}
+	return rewriters
+}
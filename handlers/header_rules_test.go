@@ -0,0 +1,156 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("HeaderRules", func() {
+	var (
+		cfg            []config.HeaderRule
+		contextPath    string
+		backendRequest *http.Request
+		headerRules    *handlers.HeaderRules
+	)
+
+	process := func(req *http.Request) *httptest.ResponseRecorder {
+		pool := route.NewPool(&route.PoolOpts{
+			Logger:      test_util.NewTestZapLogger("pool"),
+			Host:        "app.example.com",
+			ContextPath: contextPath,
+		})
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.Use(handlers.NewProxyWriter(new(logger_fakes.FakeLogger)))
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		if headerRules == nil {
+			headerRules = handlers.NewHeaderRules(cfg, new(logger_fakes.FakeLogger)).(*handlers.HeaderRules)
+		}
+		n.Use(headerRules)
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			backendRequest = r
+			rw.Header().Set("Server", "nginx/1.2.3")
+			rw.Header().Set("Cache-Control", "no-cache")
+			rw.WriteHeader(http.StatusOK)
+		})
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	newRequest := func(host string) *http.Request {
+		req := test_util.NewRequest("GET", host, "/", nil)
+		req.Header.Set("X-Internal-Token", "secret")
+		req.Header.Set("X-Platform", "client")
+		return req
+	}
+
+	BeforeEach(func() {
+		contextPath = "/"
+		backendRequest = nil
+		headerRules = nil
+		cfg = []config.HeaderRule{
+			{
+				Request: config.HeaderRuleActions{
+					Remove: []string{"X-Internal-Token"},
+					Set:    []config.HeaderNameValue{{Name: "X-Platform", Value: "cf"}},
+				},
+				Response: config.HeaderRuleActions{
+					Remove: []string{"Server"},
+					Add:    []config.HeaderNameValue{{Name: "Cache-Control", Value: "private"}},
+				},
+			},
+		}
+	})
+
+	It("applies rules without matchers to every request and response", func() {
+		res := process(newRequest("app.example.com"))
+
+		Expect(backendRequest.Header).ToNot(HaveKey("X-Internal-Token"))
+		Expect(backendRequest.Header.Values("X-Platform")).To(Equal([]string{"cf"}))
+		Expect(res.Header()).ToNot(HaveKey("Server"))
+		Expect(res.Header().Values("Cache-Control")).To(Equal([]string{"no-cache", "private"}))
+	})
+
+	Context("when the rules are updated", func() {
+		It("applies the new rules to later requests", func() {
+			process(newRequest("app.example.com"))
+			Expect(backendRequest.Header.Get("X-Platform")).To(Equal("cf"))
+
+			headerRules.Update([]config.HeaderRule{{
+				Request: config.HeaderRuleActions{
+					Set: []config.HeaderNameValue{{Name: "X-Platform", Value: "cf-2"}},
+				},
+			}})
+			res := process(newRequest("app.example.com"))
+			Expect(backendRequest.Header.Get("X-Platform")).To(Equal("cf-2"))
+			Expect(backendRequest.Header.Get("X-Internal-Token")).To(Equal("secret"))
+			Expect(res.Header().Get("Server")).To(Equal("nginx/1.2.3"))
+		})
+	})
+
+	Context("when a rule matches domains", func() {
+		BeforeEach(func() {
+			cfg[0].Domains = []string{"*.example.com"}
+		})
+
+		It("applies it to requests for the domains", func() {
+			process(newRequest("APP.example.com:443"))
+			Expect(backendRequest.Header.Get("X-Platform")).To(Equal("cf"))
+		})
+
+		It("does not apply it to other requests", func() {
+			res := process(newRequest("app.example.org"))
+			Expect(backendRequest.Header.Get("X-Platform")).To(Equal("client"))
+			Expect(res.Header().Get("Server")).To(Equal("nginx/1.2.3"))
+		})
+	})
+
+	Context("when a rule matches routes", func() {
+		BeforeEach(func() {
+			cfg[0].Routes = []string{"app.example.com/api"}
+		})
+
+		It("applies it to requests to the routes", func() {
+			contextPath = "/api"
+			process(newRequest("app.example.com"))
+			Expect(backendRequest.Header.Get("X-Platform")).To(Equal("cf"))
+		})
+
+		It("does not apply it to requests to other routes", func() {
+			process(newRequest("app.example.com"))
+			Expect(backendRequest.Header.Get("X-Platform")).To(Equal("client"))
+		})
+	})
+
+	Context("when several rules match", func() {
+		BeforeEach(func() {
+			cfg = append(cfg, config.HeaderRule{
+				Request: config.HeaderRuleActions{
+					Add: []config.HeaderNameValue{{Name: "X-Platform", Value: "edge"}},
+				},
+			})
+		})
+
+		It("applies them in order", func() {
+			process(newRequest("app.example.com"))
+			Expect(backendRequest.Header.Values("X-Platform")).To(Equal([]string{"cf", "edge"}))
+		})
+	})
+})
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"fmt"
+	"mime"
 	"net/http"
 	"strings"
 
 	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/route"
 	"github.com/uber-go/zap"
 )
 
@@ -43,6 +45,48 @@ func IsTcpUpgrade(request *http.Request) bool {
 	return upgradeHeader(request) == "tcp"
 }
 
+// IsGRPCRequest reports whether the request is a gRPC call. gRPC-Web requests
+// are not, since they do not depend on HTTP/2 or trailers.
+func IsGRPCRequest(request *http.Request) bool {
+	contentType := request.Header.Get("Content-Type")
+	return contentType == "application/grpc" ||
+		strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc;")
+}
+
+// StreamingTag is the route tag that marks the responses of a route as
+// streams, which are flushed to the client as they arrive. Its value must be
+// "true".
+const StreamingTag = "Streaming"
+
+// IsStreamingRoute reports whether the route is tagged with StreamingTag.
+func IsStreamingRoute(pool *route.Pool) bool {
+	var tagged bool
+	pool.Each(func(e *route.Endpoint) {
+		tagged = tagged || e.Tags[StreamingTag] == "true"
+	})
+	return tagged
+}
+
+// IsEventStream reports whether the content type is that of Server-Sent
+// Events.
+func IsEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// AcceptsEventStream reports whether the client asked for Server-Sent Events.
+func AcceptsEventStream(request *http.Request) bool {
+	for _, v := range request.Header["Accept"] {
+		for _, mediaType := range strings.Split(v, ",") {
+			if IsEventStream(strings.TrimSpace(mediaType)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func upgradeHeader(request *http.Request) string {
 	// handle multiple Connection field-values, either in a comma-separated string or multiple field-headers
 	for _, v := range request.Header[http.CanonicalHeaderKey("Connection")] {
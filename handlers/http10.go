@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type http10 struct {
+	policy      string
+	defaultHost string
+	logger      logger.Logger
+}
+
+// NewHTTP10 creates a handler that applies the configured policy to HTTP/1.0
+// requests without a Host header. They are either rejected, or routed to the
+// default host.
+func NewHTTP10(policy, defaultHost string, logger logger.Logger) negroni.Handler {
+	return &http10{
+		policy:      policy,
+		defaultHost: defaultHost,
+		logger:      logger,
+	}
+}
+
+func (h *http10) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.ProtoAtLeast(1, 1) || r.Host != "" {
+		next(rw, r)
+		return
+	}
+
+	switch h.policy {
+	case config.HTTP10_ROUTE:
+		h.logger.Debug("routing-http10-request-to-default-host", zap.String("host", h.defaultHost))
+		r.Host = h.defaultHost
+	default:
+		writeStatus(
+			rw,
+			http.StatusBadRequest,
+			"HTTP/1.0 requests must include a Host header",
+			h.logger,
+		)
+		return
+	}
+
+	next(rw, r)
+}
@@ -0,0 +1,83 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	"github.com/urfave/negroni"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTP10", func() {
+	var (
+		nextCalled bool
+		nextHost   string
+	)
+
+	process := func(policy string, req *http.Request) *httptest.ResponseRecorder {
+		nextCalled = false
+		nextHost = ""
+
+		n := negroni.New()
+		n.Use(handlers.NewHTTP10(policy, "default.example.com", test_util.NewTestZapLogger("http10")))
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			nextCalled = true
+			nextHost = r.Host
+		})
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	newRequest := func(proto, host string) *http.Request {
+		req := httptest.NewRequest("GET", "/foo", nil)
+		req.Proto = proto
+		req.ProtoMajor, req.ProtoMinor, _ = http.ParseHTTPVersion(proto)
+		req.Host = host
+		return req
+	}
+
+	Context("when the policy is reject", func() {
+		It("responds with a 400 to HTTP/1.0 requests without a Host", func() {
+			res := process(config.HTTP10_REJECT, newRequest("HTTP/1.0", ""))
+			Expect(res.Code).To(Equal(http.StatusBadRequest))
+			Expect(res.Body.String()).To(ContainSubstring("HTTP/1.0 requests must include a Host header"))
+			Expect(nextCalled).To(BeFalse())
+		})
+
+		It("calls the next handler for HTTP/1.0 requests with a Host", func() {
+			res := process(config.HTTP10_REJECT, newRequest("HTTP/1.0", "app.example.com"))
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+			Expect(nextHost).To(Equal("app.example.com"))
+		})
+	})
+
+	Context("when the policy is route", func() {
+		It("routes HTTP/1.0 requests without a Host to the default host", func() {
+			res := process(config.HTTP10_ROUTE, newRequest("HTTP/1.0", ""))
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+			Expect(nextHost).To(Equal("default.example.com"))
+		})
+
+		It("keeps the Host of HTTP/1.0 requests that have one", func() {
+			process(config.HTTP10_ROUTE, newRequest("HTTP/1.0", "app.example.com"))
+			Expect(nextCalled).To(BeTrue())
+			Expect(nextHost).To(Equal("app.example.com"))
+		})
+
+		It("does not touch HTTP/1.1 requests", func() {
+			process(config.HTTP10_ROUTE, newRequest("HTTP/1.1", ""))
+			Expect(nextCalled).To(BeTrue())
+			Expect(nextHost).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/common/jwt"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type jwtValidation struct {
+	validator    *jwt.Validator
+	required     bool
+	audiences    []string
+	claimsHeader string
+	failOpen     bool
+	reporter     metrics.ProxyReporter
+	logger       logger.Logger
+}
+
+// NewJWT creates a handler that validates the bearer tokens of requests to
+// routes that require one, and forwards their claims to the backend. The
+// claims header is removed from all other requests. The validator is nil
+// when no key set is configured, and requests that require a token are then
+// rejected. When the keys cannot be fetched, requests are rejected, or with
+// the fail_open failureMode proxied without validation.
+func NewJWT(validator *jwt.Validator, cfg config.JWTConfig, failureMode string, reporter metrics.ProxyReporter, logger logger.Logger) negroni.Handler {
+	return &jwtValidation{
+		validator:    validator,
+		required:     cfg.Enabled,
+		audiences:    cfg.Audiences,
+		claimsHeader: cfg.ClaimsHeader,
+		failOpen:     failureMode == config.OAuthFailureModeFailOpen,
+		reporter:     reporter,
+		logger:       logger,
+	}
+}
+
+func (j *jwtValidation) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if j.claimsHeader != "" {
+		r.Header.Del(j.claimsHeader)
+	}
+
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		j.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if reqInfo.RoutePool == nil {
+		j.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+		return
+	}
+
+	required, audiences := reqInfo.RoutePool.JWTValidation(j.required)
+	if !required {
+		next(rw, r)
+		return
+	}
+	// the endpoints of the route registered audiences that no token can be
+	// for all of
+	noAudience := audiences != nil && len(audiences) == 0
+	if audiences == nil {
+		audiences = j.audiences
+	}
+
+	if j.validator == nil {
+		j.logger.Error("jwt-validation-not-configured")
+		writeStatus(
+			rw,
+			http.StatusServiceUnavailable,
+			"JWT validation is not configured.",
+			j.logger,
+		)
+		return
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		writeStatus(
+			rw,
+			http.StatusUnauthorized,
+			"A bearer token is required for this route.",
+			j.logger,
+		)
+		return
+	}
+
+	_, err = j.validator.Validate(token, audiences)
+	if err == nil && noAudience {
+		err = errors.New("jwt: invalid token: the route registered no audience in common")
+	}
+	if err == jwt.ErrKeySetUnavailable {
+		j.reporter.CaptureTokenValidationFailure()
+		if j.failOpen {
+			j.logger.Error("jwt-key-set-unavailable-failing-open", zap.Error(err))
+			next(rw, r)
+			return
+		}
+		j.logger.Error("jwt-key-set-unavailable", zap.Error(err))
+		writeStatus(
+			rw,
+			http.StatusServiceUnavailable,
+			"Unable to fetch the keys to validate the bearer token.",
+			j.logger,
+		)
+		return
+	}
+	if err != nil {
+		j.logger.Info("jwt-invalid", zap.Error(err))
+		rw.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeStatus(
+			rw,
+			http.StatusUnauthorized,
+			"The bearer token is not valid.",
+			j.logger,
+		)
+		return
+	}
+
+	if j.claimsHeader != "" {
+		// the claims segment of the token is the base64url-encoded JSON of
+		// the claims
+		r.Header.Set(j.claimsHeader, strings.Split(token, ".")[1])
+	}
+	next(rw, r)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
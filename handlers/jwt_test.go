@@ -0,0 +1,267 @@
+package handlers_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/jwt"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+type staticKeys struct {
+	key crypto.PublicKey
+	err error
+}
+
+func (k *staticKeys) Key(string) (crypto.PublicKey, error) {
+	return k.key, k.err
+}
+
+var _ = Describe("JWT", func() {
+	const issuer = "https://uaa.example.com/oauth/token"
+
+	var (
+		key          *rsa.PrivateKey
+		keys         *staticKeys
+		validator    *jwt.Validator
+		cfg          config.JWTConfig
+		failureMode  string
+		reporter     *fakes.FakeProxyReporter
+		endpointOpts *route.EndpointOpts
+		otherOpts    []*route.EndpointOpts
+		req          *http.Request
+		nextReq      *http.Request
+	)
+
+	signedToken := func(claims map[string]interface{}) string {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+		payload, err := json.Marshal(claims)
+		Expect(err).ToNot(HaveOccurred())
+		signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		Expect(err).ToNot(HaveOccurred())
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	validToken := func(audience string) string {
+		return signedToken(map[string]interface{}{
+			"iss": issuer,
+			"sub": "billing-client",
+			"aud": audience,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+	}
+
+	process := func() *httptest.ResponseRecorder {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool")})
+		pool.Put(route.NewEndpoint(endpointOpts))
+		for _, opts := range otherOpts {
+			pool.Put(route.NewEndpoint(opts))
+		}
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		n.Use(handlers.NewJWT(validator, cfg, failureMode, reporter, new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(_ http.ResponseWriter, r *http.Request) { nextReq = r })
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		keys = &staticKeys{key: &key.PublicKey}
+		validator = jwt.NewValidator(keys, issuer, time.Minute)
+
+		cfg = config.JWTConfig{
+			Enabled:      true,
+			Audiences:    []string{"billing"},
+			ClaimsHeader: "X-Jwt-Claims",
+		}
+		failureMode = config.OAuthFailureModeFailClosed
+		reporter = new(fakes.FakeProxyReporter)
+		endpointOpts = &route.EndpointOpts{Host: "1.1.1.1", Port: 8080}
+		otherOpts = nil
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		nextReq = nil
+	})
+
+	It("forwards the claims of a valid token", func() {
+		token := validToken("billing")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(nextReq).ToNot(BeNil())
+		Expect(nextReq.Header.Get("X-Jwt-Claims")).To(Equal(strings.Split(token, ".")[1]))
+		Expect(nextReq.Header.Get("Authorization")).To(Equal("Bearer " + token))
+	})
+
+	It("rejects requests without a token", func() {
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusUnauthorized))
+		Expect(res.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+		Expect(nextReq).To(BeNil())
+	})
+
+	It("rejects requests with an invalid token", func() {
+		req.Header.Set("Authorization", "Bearer "+validToken("reports"))
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusUnauthorized))
+		Expect(res.Header().Get("WWW-Authenticate")).To(Equal(`Bearer error="invalid_token"`))
+		Expect(nextReq).To(BeNil())
+	})
+
+	It("responds with service unavailable when the keys cannot be fetched", func() {
+		keys.err = jwt.ErrKeySetUnavailable
+		req.Header.Set("Authorization", "Bearer "+validToken("billing"))
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(nextReq).To(BeNil())
+	})
+
+	Context("when the token endpoint cannot be reached", func() {
+		BeforeEach(func() {
+			// a port that nothing listens on
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			url := "http://" + listener.Addr().String() + "/token_keys"
+			Expect(listener.Close()).To(Succeed())
+
+			keySet := jwt.NewKeySet(url, &http.Client{Timeout: time.Second}, time.Minute)
+			validator = jwt.NewValidator(keySet, issuer, time.Minute)
+			req.Header.Set("Authorization", "Bearer "+validToken("billing"))
+		})
+
+		It("rejects requests that require a token when it fails closed", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(nextReq).To(BeNil())
+			Expect(reporter.CaptureTokenValidationFailureCallCount()).To(Equal(1))
+		})
+
+		It("proxies requests without validating their token when it fails open", func() {
+			failureMode = config.OAuthFailureModeFailOpen
+			req.Header.Set("X-Jwt-Claims", "forged")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextReq).ToNot(BeNil())
+			Expect(nextReq.Header).ToNot(HaveKey("X-Jwt-Claims"))
+			Expect(reporter.CaptureTokenValidationFailureCallCount()).To(Equal(1))
+		})
+
+		It("still rejects requests without a token when it fails open", func() {
+			failureMode = config.OAuthFailureModeFailOpen
+			req.Header.Del("Authorization")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusUnauthorized))
+			Expect(nextReq).To(BeNil())
+		})
+	})
+
+	Context("when the route registered its own audiences", func() {
+		BeforeEach(func() {
+			endpointOpts.JWTAudiences = []string{"reports"}
+		})
+
+		It("validates the token for them", func() {
+			req.Header.Set("Authorization", "Bearer "+validToken("reports"))
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("when the endpoints of the route registered no audience in common", func() {
+		BeforeEach(func() {
+			endpointOpts.JWTAudiences = []string{"reports"}
+			otherOpts = []*route.EndpointOpts{{Host: "1.1.1.2", Port: 8080, JWTAudiences: []string{"billing"}}}
+		})
+
+		It("rejects every token", func() {
+			req.Header.Set("Authorization", "Bearer "+validToken("reports"))
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusUnauthorized))
+			Expect(res.Header().Get("WWW-Authenticate")).To(Equal(`Bearer error="invalid_token"`))
+			Expect(nextReq).To(BeNil())
+		})
+	})
+
+	Context("when the route opted out", func() {
+		BeforeEach(func() {
+			endpointOpts.JWTValidation = route.JWTValidationNone
+		})
+
+		It("does not require a token and removes the claims header", func() {
+			req.Header.Set("X-Jwt-Claims", "forged")
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextReq.Header).ToNot(HaveKey("X-Jwt-Claims"))
+		})
+	})
+
+	Context("when validation is not enabled for all routes", func() {
+		BeforeEach(func() {
+			cfg.Enabled = false
+		})
+
+		It("does not require a token", func() {
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+		})
+
+		It("requires a token for routes that registered to require one", func() {
+			endpointOpts.JWTValidation = route.JWTValidationRequired
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Context("when no key set is configured", func() {
+		BeforeEach(func() {
+			validator = nil
+		})
+
+		It("rejects requests to routes that require a token", func() {
+			req.Header.Set("Authorization", "Bearer "+validToken("billing"))
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(nextReq).To(BeNil())
+		})
+	})
+})
@@ -97,10 +97,19 @@ func (l *lookupHandler) lookup(r *http.Request) *route.Pool {
 			return nil
 		}
 
-		return l.registry.LookupWithInstance(uri, appID, appIndex)
+		return forRequest(l.registry.LookupWithInstance(uri, appID, appIndex), r)
 	}
 
-	return l.registry.Lookup(uri)
+	return forRequest(l.registry.Lookup(uri), r)
+}
+
+// forRequest selects the endpoints of the pool that match the method and
+// headers of the request.
+func forRequest(pool *route.Pool, r *http.Request) *route.Pool {
+	if pool == nil {
+		return nil
+	}
+	return pool.ForRequest(r)
 }
 
 func validateCfAppInstance(appInstanceHeader string) (string, string, error) {
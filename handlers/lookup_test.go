@@ -201,6 +201,48 @@ var _ = Describe("Lookup", func() {
 			})
 		})
 
+		Context("when endpoints of the route registered a match", func() {
+			var v2Endpoint *route.Endpoint
+
+			BeforeEach(func() {
+				pool := route.NewPool(&route.PoolOpts{
+					Logger:             logger,
+					RetryAfterFailure:  2 * time.Minute,
+					Host:               "example.com",
+					ContextPath:        "/",
+					MaxConnsPerBackend: 0,
+				})
+				v2Endpoint = route.NewEndpoint(&route.EndpointOpts{
+					Host:         "1.3.5.6",
+					Port:         5679,
+					MatchHeaders: map[string]string{"X-Api-Version": "2"},
+				})
+				pool.Put(v2Endpoint)
+				reg.LookupReturns(pool)
+			})
+
+			Context("when the request matches", func() {
+				BeforeEach(func() {
+					req.Header.Set("X-Api-Version", "2")
+				})
+
+				It("calls next with the endpoints of the match", func() {
+					Expect(nextCalled).To(BeTrue())
+					requestInfo, err := handlers.ContextRequestInfo(nextRequest)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(requestInfo.RoutePool.Endpoints("", "").Next()).To(Equal(v2Endpoint))
+				})
+			})
+
+			Context("when the request does not match and no endpoint registered without a match", func() {
+				It("returns a 404 NotFound and does not call next", func() {
+					Expect(nextCalled).To(BeFalse())
+					Expect(resp.Code).To(Equal(http.StatusNotFound))
+					Expect(resp.Header().Get("X-Cf-RouterError")).To(Equal("unknown_route"))
+				})
+			})
+		})
+
 		Context("when a specific instance is requested", func() {
 			BeforeEach(func() {
 				pool := route.NewPool(&route.PoolOpts{
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type maxInFlight struct {
+	inFlight   int64
+	max        int64
+	retryAfter string
+	reporter   metrics.ProxyReporter
+	logger     logger.Logger
+}
+
+// NewMaxInFlight creates a handler that rejects requests with a 503 while max
+// requests are already being handled, rather than letting them queue up. It
+// reports the number of requests in flight whenever it changes.
+func NewMaxInFlight(max int, retryAfter time.Duration, reporter metrics.ProxyReporter, logger logger.Logger) negroni.Handler {
+	return &maxInFlight{
+		max:        int64(max),
+		retryAfter: strconv.Itoa(int(retryAfter.Seconds())),
+		reporter:   reporter,
+		logger:     logger,
+	}
+}
+
+func (m *maxInFlight) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	inFlight := atomic.AddInt64(&m.inFlight, 1)
+	if inFlight > m.max {
+		atomic.AddInt64(&m.inFlight, -1)
+		m.reporter.CaptureRequestShed()
+		m.logger.Info("request-shed", zap.Int64("in-flight", inFlight-1))
+		rw.Header().Set("Retry-After", m.retryAfter)
+		rw.Header().Set("X-Cf-RouterError", "overloaded")
+		writeStatus(
+			rw,
+			http.StatusServiceUnavailable,
+			"Too many requests in flight.",
+			m.logger,
+		)
+		return
+	}
+	m.reporter.CaptureInFlightRequests(int(inFlight))
+
+	defer func() {
+		m.reporter.CaptureInFlightRequests(int(atomic.AddInt64(&m.inFlight, -1)))
+	}()
+	next(rw, r)
+}
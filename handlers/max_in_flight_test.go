@@ -0,0 +1,75 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("MaxInFlight", func() {
+	var (
+		reporter *fakes.FakeProxyReporter
+		n        *negroni.Negroni
+		started  chan struct{}
+		release  chan struct{}
+	)
+
+	request := func() *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, test_util.NewRequest("GET", "example.com", "/", nil))
+		return res
+	}
+
+	BeforeEach(func() {
+		reporter = new(fakes.FakeProxyReporter)
+		started = make(chan struct{}, 10)
+		release = make(chan struct{})
+
+		n = negroni.New()
+		n.Use(handlers.NewMaxInFlight(2, 3*time.Second, reporter, new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(http.ResponseWriter, *http.Request) {
+			started <- struct{}{}
+			<-release
+		})
+	})
+
+	It("rejects requests beyond the cap with a 503", func() {
+		done := make(chan int, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				defer GinkgoRecover()
+				done <- request().Code
+			}()
+			Eventually(started).Should(Receive())
+		}
+
+		res := request()
+		Expect(res.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(res.Header().Get("Retry-After")).To(Equal("3"))
+		Expect(res.Header().Get("X-Cf-RouterError")).To(Equal("overloaded"))
+		Expect(reporter.CaptureRequestShedCallCount()).To(Equal(1))
+
+		close(release)
+		Eventually(done).Should(Receive(Equal(http.StatusOK)))
+		Eventually(done).Should(Receive(Equal(http.StatusOK)))
+
+		Expect(request().Code).To(Equal(http.StatusOK))
+	})
+
+	It("reports the number of requests in flight", func() {
+		close(release)
+		request()
+
+		Expect(reporter.CaptureInFlightRequestsCallCount()).To(Equal(2))
+		Expect(reporter.CaptureInFlightRequestsArgsForCall(0)).To(Equal(1))
+		Expect(reporter.CaptureInFlightRequestsArgsForCall(1)).To(Equal(0))
+	})
+})
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+// ErrRequestBodyTooLarge is returned when reading more of a request body than
+// the route allows.
+var ErrRequestBodyTooLarge = errors.New("request body too large")
+
+type maxRequestBody struct {
+	maxBytes int64
+	logger   logger.Logger
+}
+
+// NewMaxRequestBody creates a handler that limits the size of request bodies
+// to the limit the route registered, or maxBytes. Requests whose
+// Content-Length is over the limit are rejected with a 413 right away. The
+// bodies of other requests fail with ErrRequestBodyTooLarge once they go over
+// the limit while they are proxied.
+func NewMaxRequestBody(maxBytes int64, logger logger.Logger) negroni.Handler {
+	return &maxRequestBody{
+		maxBytes: maxBytes,
+		logger:   logger,
+	}
+}
+
+func (m *maxRequestBody) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		m.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if reqInfo.RoutePool == nil {
+		m.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+		return
+	}
+
+	maxBytes := reqInfo.RoutePool.MaxRequestBodyBytes()
+	if maxBytes == 0 {
+		maxBytes = m.maxBytes
+	}
+	if maxBytes == 0 || r.Body == nil || r.Body == http.NoBody {
+		next(rw, r)
+		return
+	}
+
+	if r.ContentLength > maxBytes {
+		m.logger.Info("request-body-too-large", zap.Int64("content-length", r.ContentLength), zap.Int64("max-bytes", maxBytes))
+		rw.Header().Set("X-Cf-RouterError", "request_body_too_large")
+		writeStatus(
+			rw,
+			http.StatusRequestEntityTooLarge,
+			"Request body too large.",
+			m.logger,
+		)
+		return
+	}
+
+	r.Body = &limitedBody{ReadCloser: r.Body, remaining: maxBytes}
+	next(rw, r)
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrRequestBodyTooLarge
+	}
+	// read one byte more than allowed to tell a body of exactly the limit
+	// from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrRequestBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,117 @@
+package handlers_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("MaxRequestBody", func() {
+	var (
+		maxBytes     int64
+		endpointOpts *route.EndpointOpts
+		req          *http.Request
+		body         []byte
+		readErr      error
+		nextCalled   bool
+	)
+
+	process := func() *httptest.ResponseRecorder {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool")})
+		pool.Put(route.NewEndpoint(endpointOpts))
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		n.Use(handlers.NewMaxRequestBody(maxBytes, new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			nextCalled = true
+			body, readErr = ioutil.ReadAll(r.Body)
+		})
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	// streamed hides the length of the body, as with chunked requests
+	streamed := func(s string) *http.Request {
+		r := test_util.NewRequest("POST", "example.com", "/", ioutil.NopCloser(strings.NewReader(s)))
+		r.ContentLength = -1
+		return r
+	}
+
+	BeforeEach(func() {
+		maxBytes = 10
+		endpointOpts = &route.EndpointOpts{Host: "1.1.1.1", Port: 8080}
+		nextCalled = false
+		body, readErr = nil, nil
+	})
+
+	It("rejects requests whose Content-Length is over the limit", func() {
+		req = test_util.NewRequest("POST", "example.com", "/", bytes.NewReader(make([]byte, 11)))
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(res.Header().Get("X-Cf-RouterError")).To(Equal("request_body_too_large"))
+		Expect(nextCalled).To(BeFalse())
+	})
+
+	It("passes bodies of exactly the limit", func() {
+		req = streamed("0123456789")
+
+		process()
+		Expect(readErr).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("0123456789"))
+	})
+
+	It("fails to read bodies that go over the limit", func() {
+		req = streamed("0123456789a")
+
+		process()
+		Expect(readErr).To(Equal(handlers.ErrRequestBodyTooLarge))
+		Expect(string(body)).To(Equal("0123456789"))
+	})
+
+	Context("when the route registered a limit", func() {
+		BeforeEach(func() {
+			endpointOpts.MaxRequestBodyBytes = 20
+		})
+
+		It("uses the limit of the route", func() {
+			req = streamed("0123456789abcdef")
+
+			process()
+			Expect(readErr).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when no limit is configured", func() {
+		BeforeEach(func() {
+			maxBytes = 0
+		})
+
+		It("does not limit the body", func() {
+			req = streamed(strings.Repeat("a", 1024))
+
+			process()
+			Expect(readErr).ToNot(HaveOccurred())
+			Expect(body).To(HaveLen(1024))
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type maxRequestHeaders struct {
+	maxBytes int
+	maxCount int
+	reporter metrics.ProxyReporter
+	logger   logger.Logger
+}
+
+// NewMaxRequestHeaders creates a handler that rejects requests with a 431
+// when their request line and headers are larger than maxBytes, or when they
+// have more than maxCount headers, before they are proxied to a backend.
+// Zero does not limit either. The server rejects requests far over maxBytes
+// before they reach the handler, so maxBytes should match its limit.
+func NewMaxRequestHeaders(maxBytes, maxCount int, reporter metrics.ProxyReporter, logger logger.Logger) negroni.Handler {
+	return &maxRequestHeaders{
+		maxBytes: maxBytes,
+		maxCount: maxCount,
+		reporter: reporter,
+		logger:   logger,
+	}
+}
+
+func (m *maxRequestHeaders) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if m.maxBytes == 0 && m.maxCount == 0 {
+		next(rw, r)
+		return
+	}
+
+	size, count := requestHeaderSize(r)
+	if (m.maxBytes > 0 && size > m.maxBytes) || (m.maxCount > 0 && count > m.maxCount) {
+		m.reporter.CaptureRequestHeadersTooLarge()
+		m.logger.Info("request-headers-too-large", zap.Int("size", size), zap.Int("count", count))
+		rw.Header().Set("X-Cf-RouterError", "request_headers_too_large")
+		writeStatus(
+			rw,
+			http.StatusRequestHeaderFieldsTooLarge,
+			"Request headers too large.",
+			m.logger,
+		)
+		return
+	}
+
+	next(rw, r)
+}
+
+// requestHeaderSize returns the size of the request line and headers of r as
+// they are sent over HTTP/1.1, and the number of header lines, including
+// Host.
+func requestHeaderSize(r *http.Request) (int, int) {
+	size := len(r.Method) + len(r.RequestURI) + len(r.Proto) + len("  \r\n")
+	count := 0
+	if r.Host != "" {
+		size += len("Host: \r\n") + len(r.Host)
+		count++
+	}
+	for name, values := range r.Header {
+		for _, value := range values {
+			size += len(name) + len(value) + len(": \r\n")
+			count++
+		}
+	}
+	return size, count
+}
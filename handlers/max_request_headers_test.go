@@ -0,0 +1,98 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("MaxRequestHeaders", func() {
+	var (
+		reporter   *fakes.FakeProxyReporter
+		maxBytes   int
+		maxCount   int
+		req        *http.Request
+		nextCalled bool
+	)
+
+	process := func() *httptest.ResponseRecorder {
+		n := negroni.New()
+		n.Use(handlers.NewMaxRequestHeaders(maxBytes, maxCount, reporter, new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(http.ResponseWriter, *http.Request) { nextCalled = true })
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	BeforeEach(func() {
+		reporter = new(fakes.FakeProxyReporter)
+		maxBytes = 1024
+		maxCount = 5
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		req.RequestURI = "/"
+		req.Header.Set("Accept", "*/*")
+		nextCalled = false
+	})
+
+	It("passes requests within the limits on", func() {
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(nextCalled).To(BeTrue())
+		Expect(reporter.CaptureRequestHeadersTooLargeCallCount()).To(BeZero())
+	})
+
+	It("rejects requests whose headers are too large with a 431", func() {
+		req.Header.Set("Cookie", strings.Repeat("a", 1024))
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+		Expect(res.Header().Get("X-Cf-RouterError")).To(Equal("request_headers_too_large"))
+		Expect(nextCalled).To(BeFalse())
+		Expect(reporter.CaptureRequestHeadersTooLargeCallCount()).To(Equal(1))
+	})
+
+	It("counts the request line", func() {
+		req.RequestURI = "/" + strings.Repeat("a", 1024)
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+	})
+
+	It("rejects requests with too many headers with a 431", func() {
+		req.Header.Add("X-Foo", "1")
+		req.Header.Add("X-Foo", "2")
+		req.Header.Add("X-Foo", "3")
+		req.Header.Add("X-Foo", "4")
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+		Expect(reporter.CaptureRequestHeadersTooLargeCallCount()).To(Equal(1))
+	})
+
+	Context("when there are no limits", func() {
+		BeforeEach(func() {
+			maxBytes = 0
+			maxCount = 0
+		})
+
+		It("passes all requests on", func() {
+			req.Header.Set("Cookie", strings.Repeat("a", 1024))
+			for i := 0; i < 10; i++ {
+				req.Header.Add("X-Foo", "bar")
+			}
+
+			res := process()
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+})
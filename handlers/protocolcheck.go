@@ -55,6 +55,11 @@ func (p *protocolCheck) hijack(rw http.ResponseWriter) (net.Conn, *bufio.ReadWri
 	return hijacker.Hijack()
 }
 
+// isProtocolSupported accepts HTTP/2 only over TLS, where the router serves it
+// when it is negotiated with ALPN, and HTTP/3, which QUIC always encrypts.
 func isProtocolSupported(request *http.Request) bool {
+	if (request.ProtoMajor == 2 || request.ProtoMajor == 3) && request.ProtoMinor == 0 {
+		return request.TLS != nil
+	}
 	return request.ProtoMajor == 1 && (request.ProtoMinor == 0 || request.ProtoMinor == 1)
 }
@@ -2,8 +2,10 @@ package handlers_test
 
 import (
 	"bufio"
+	"crypto/tls"
 	"net"
 	"net/http"
+	"net/http/httptest"
 
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/logger"
@@ -105,4 +107,35 @@ var _ = Describe("Protocolcheck", func() {
 			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
 		})
 	})
+
+	Context("http2 over tls", func() {
+		It("passes the request through", func() {
+			req := httptest.NewRequest("GET", "https://example.com/", nil)
+			req.Proto = "HTTP/2.0"
+			req.ProtoMajor = 2
+			req.ProtoMinor = 0
+			req.TLS = &tls.ConnectionState{NegotiatedProtocol: "h2"}
+			resp := httptest.NewRecorder()
+
+			n.ServeHTTP(resp, req)
+
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
+	Context("http3", func() {
+		It("passes the request through", func() {
+			req := httptest.NewRequest("GET", "https://example.com/", nil)
+			req.Proto = "HTTP/3.0"
+			req.ProtoMajor = 3
+			req.ProtoMinor = 0
+			req.TLS = &tls.ConnectionState{NegotiatedProtocol: "h3"}
+			resp := httptest.NewRecorder()
+
+			n.ServeHTTP(resp, req)
+
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(nextCalled).To(BeTrue())
+		})
+	})
 })
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+// rateLimitSweepInterval is how often buckets that have filled up again are
+// dropped, so that clients that stopped sending requests are forgotten.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit is the handler NewRateLimit creates.
+type RateLimit struct {
+	proxies  TrustedProxies
+	skip     func(req *http.Request) (bool, error)
+	reporter metrics.ProxyReporter
+	logger   logger.Logger
+
+	mu        sync.Mutex
+	limit     route.RateLimit
+	key       string
+	header    string
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   route.RateLimit
+}
+
+// NewRateLimit creates a handler that limits the rate of requests to each
+// route with a token bucket. Routes use the rate and burst they registered,
+// or those of cfg. Depending on cfg.Key, all clients of a route share a
+// bucket, or each client IP or value of cfg.Header has its own. The client
+// IP is the address of the peer, or the address that a trusted proxy
+// forwarded for. Requests over the limit get a 429 response. Requests for
+// which skip returns true, such as those coming back from a route service,
+// are not counted again.
+func NewRateLimit(cfg config.RateLimitConfig, proxies TrustedProxies, skip func(req *http.Request) (bool, error), reporter metrics.ProxyReporter, logger logger.Logger) negroni.Handler {
+	return &RateLimit{
+		limit:    route.RateLimit{PerSecond: cfg.RequestsPerSecond, Burst: cfg.Burst},
+		key:      cfg.Key,
+		header:   cfg.Header,
+		proxies:  proxies,
+		skip:     skip,
+		reporter: reporter,
+		logger:   logger,
+		buckets:  map[string]*tokenBucket{},
+	}
+}
+
+// Update replaces the rate, burst and key of cfg. Buckets keep the tokens
+// they hold, unless the key changes and they are all dropped.
+func (l *RateLimit) Update(cfg config.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cfg.Key != l.key || cfg.Header != l.header {
+		l.buckets = map[string]*tokenBucket{}
+	}
+	l.limit = route.RateLimit{PerSecond: cfg.RequestsPerSecond, Burst: cfg.Burst}
+	l.key = cfg.Key
+	l.header = cfg.Header
+}
+
+func (l *RateLimit) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		l.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if reqInfo.RoutePool == nil {
+		l.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+		return
+	}
+
+	defaultLimit, key, header := l.settings()
+	limit := reqInfo.RoutePool.RateLimit()
+	if limit.PerSecond <= 0 {
+		limit = defaultLimit
+	}
+	if limit.PerSecond <= 0 {
+		next(rw, r)
+		return
+	}
+
+	skip, err := l.skip(r)
+	if err != nil {
+		l.logger.Error("signature-validation-failed", zap.Error(err))
+		writeStatus(
+			rw,
+			http.StatusBadRequest,
+			"Failed to validate Route Service Signature",
+			l.logger,
+		)
+		return
+	}
+	if skip {
+		next(rw, r)
+		return
+	}
+
+	routeKey := reqInfo.RoutePool.Host() + reqInfo.RoutePool.ContextPath()
+	retryAfter, ok := l.take(routeKey+"\x00"+clientKey(r, key, header, l.proxies), limit, time.Now())
+	if !ok {
+		l.reporter.CaptureRateLimited()
+		l.logger.Info("rate-limited", zap.String("route", routeKey))
+		rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		rw.Header().Set("X-Cf-RouterError", "rate_limited")
+		writeStatus(
+			rw,
+			http.StatusTooManyRequests,
+			"Too many requests for this route.",
+			l.logger,
+		)
+		return
+	}
+
+	next(rw, r)
+}
+
+func (l *RateLimit) settings() (route.RateLimit, string, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit, l.key, l.header
+}
+
+// clientKey returns the part of the bucket key that tells clients of a route
+// apart, which is empty when they share a bucket.
+func clientKey(r *http.Request, key, header string, proxies TrustedProxies) string {
+	switch key {
+	case config.RateLimitKeyClientIP:
+		if ip := proxies.ClientIP(r); ip != nil {
+			return ip.String()
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	case config.RateLimitKeyHeader:
+		return r.Header.Get(header)
+	}
+	return ""
+}
+
+// take takes a token from the bucket for key. When the bucket is empty it
+// returns how long it takes for the next token to arrive.
+func (l *RateLimit) take(key string, limit route.RateLimit, now time.Time) (time.Duration, bool) {
+	burst := bucketSize(limit)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*limit.PerSecond)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / limit.PerSecond
+		return time.Duration(wait * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// sweep drops the buckets that would be full by now, since a new bucket
+// behaves the same.
+func (l *RateLimit) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*b.limit.PerSecond >= bucketSize(b.limit) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// bucketSize returns the number of tokens a bucket holds, which is the burst
+// of limit, or one second's worth of requests if it has none.
+func bucketSize(limit route.RateLimit) float64 {
+	if limit.Burst > 0 {
+		return float64(limit.Burst)
+	}
+	return math.Max(1, math.Ceil(limit.PerSecond))
+}
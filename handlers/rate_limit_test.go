@@ -0,0 +1,240 @@
+package handlers_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RateLimit", func() {
+	var (
+		cfg          config.RateLimitConfig
+		proxies      handlers.TrustedProxies
+		endpointOpts *route.EndpointOpts
+		skip         func(req *http.Request) (bool, error)
+		reporter     *fakes.FakeProxyReporter
+		n            *negroni.Negroni
+		rateLimit    *handlers.RateLimit
+		nextCalls    int
+	)
+
+	request := func(remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+		req := test_util.NewRequest("GET", "example.com", "/", nil)
+		req.RemoteAddr = remoteAddr
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	BeforeEach(func() {
+		cfg = config.RateLimitConfig{RequestsPerSecond: 1, Burst: 2, Key: config.RateLimitKeyClientIP}
+		proxies = handlers.TrustedProxies{}
+		endpointOpts = &route.EndpointOpts{Host: "1.1.1.1", Port: 8080}
+		skip = func(*http.Request) (bool, error) { return false, nil }
+		reporter = new(fakes.FakeProxyReporter)
+		nextCalls = 0
+	})
+
+	JustBeforeEach(func() {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool"), Host: "example.com"})
+		pool.Put(route.NewEndpoint(endpointOpts))
+
+		n = negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		rateLimit = handlers.NewRateLimit(cfg, proxies, skip, reporter, new(logger_fakes.FakeLogger)).(*handlers.RateLimit)
+		n.Use(rateLimit)
+		n.UseHandlerFunc(func(http.ResponseWriter, *http.Request) { nextCalls++ })
+	})
+
+	It("allows a burst of requests and then rejects them with a 429", func() {
+		Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+		Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+
+		res := request("10.0.0.1:1234", nil)
+		Expect(res.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(res.Header().Get("Retry-After")).To(Equal("1"))
+		Expect(res.Header().Get("X-Cf-RouterError")).To(Equal("rate_limited"))
+		Expect(nextCalls).To(Equal(2))
+		Expect(reporter.CaptureRateLimitedCallCount()).To(Equal(1))
+	})
+
+	It("gives each client IP its own bucket", func() {
+		Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+		Expect(request("10.0.0.1:1235", nil).Code).To(Equal(http.StatusOK))
+		Expect(request("10.0.0.1:1236", nil).Code).To(Equal(http.StatusTooManyRequests))
+
+		Expect(request("10.0.0.2:1234", nil).Code).To(Equal(http.StatusOK))
+	})
+
+	Context("when the requests come from a trusted proxy", func() {
+		BeforeEach(func() {
+			_, trustedNet, err := net.ParseCIDR("172.16.0.0/12")
+			Expect(err).ToNot(HaveOccurred())
+			proxies.Nets = []*net.IPNet{trustedNet}
+		})
+
+		It("gives each client the proxy forwarded for its own bucket", func() {
+			alice := http.Header{"X-Forwarded-For": {"10.0.0.1"}}
+			bob := http.Header{"X-Forwarded-For": {"10.0.0.2"}}
+
+			Expect(request("172.16.0.5:1234", alice).Code).To(Equal(http.StatusOK))
+			Expect(request("172.16.0.6:1234", alice).Code).To(Equal(http.StatusOK))
+			Expect(request("172.16.0.5:1234", alice).Code).To(Equal(http.StatusTooManyRequests))
+
+			Expect(request("172.16.0.5:1234", bob).Code).To(Equal(http.StatusOK))
+		})
+
+		It("ignores X-Forwarded-For from other peers", func() {
+			Expect(request("192.168.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.1"}}).Code).To(Equal(http.StatusOK))
+			Expect(request("192.168.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.2"}}).Code).To(Equal(http.StatusOK))
+			Expect(request("192.168.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.3"}}).Code).To(Equal(http.StatusTooManyRequests))
+		})
+	})
+
+	Context("when the bucket is empty", func() {
+		BeforeEach(func() {
+			cfg.RequestsPerSecond = 20
+			cfg.Burst = 1
+		})
+
+		It("refills the bucket over time", func() {
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusTooManyRequests))
+
+			time.Sleep(100 * time.Millisecond)
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("when the key is the route", func() {
+		BeforeEach(func() {
+			cfg.Key = config.RateLimitKeyRoute
+		})
+
+		It("shares the bucket between clients", func() {
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.2:1234", nil).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.3:1234", nil).Code).To(Equal(http.StatusTooManyRequests))
+		})
+	})
+
+	Context("when the key is a header", func() {
+		BeforeEach(func() {
+			cfg.Key = config.RateLimitKeyHeader
+			cfg.Header = "X-Api-Key"
+		})
+
+		It("gives each value of the header its own bucket", func() {
+			alice := http.Header{"X-Api-Key": {"alice"}}
+			bob := http.Header{"X-Api-Key": {"bob"}}
+
+			Expect(request("10.0.0.1:1234", alice).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.2:1234", alice).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.3:1234", alice).Code).To(Equal(http.StatusTooManyRequests))
+
+			Expect(request("10.0.0.1:1234", bob).Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("when the rate limit is updated", func() {
+		It("refills the buckets at the new rate", func() {
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusTooManyRequests))
+
+			rateLimit.Update(config.RateLimitConfig{RequestsPerSecond: 20, Burst: 2, Key: config.RateLimitKeyClientIP})
+			time.Sleep(100 * time.Millisecond)
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+		})
+
+		It("drops the buckets when the key changes", func() {
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+
+			rateLimit.Update(config.RateLimitConfig{RequestsPerSecond: 1, Burst: 2, Key: config.RateLimitKeyRoute})
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.2:1234", nil).Code).To(Equal(http.StatusOK))
+			Expect(request("10.0.0.3:1234", nil).Code).To(Equal(http.StatusTooManyRequests))
+		})
+
+		It("stops limiting requests when the rate is zero", func() {
+			rateLimit.Update(config.RateLimitConfig{Key: config.RateLimitKeyClientIP})
+			for i := 0; i < 5; i++ {
+				Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			}
+		})
+	})
+
+	Context("when the route registered a rate limit", func() {
+		BeforeEach(func() {
+			endpointOpts.RateLimitPerSecond = 0.5
+			endpointOpts.RateLimitBurst = 1
+		})
+
+		It("uses the limit of the route", func() {
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+
+			res := request("10.0.0.1:1234", nil)
+			Expect(res.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(strconv.Atoi(res.Header().Get("Retry-After"))).To(Equal(2))
+		})
+	})
+
+	Context("when no rate limit is configured", func() {
+		BeforeEach(func() {
+			cfg.RequestsPerSecond = 0
+		})
+
+		It("does not limit requests", func() {
+			for i := 0; i < 10; i++ {
+				Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			}
+			Expect(nextCalls).To(Equal(10))
+		})
+	})
+
+	Context("when the request is not counted again", func() {
+		BeforeEach(func() {
+			skip = func(*http.Request) (bool, error) { return true, nil }
+		})
+
+		It("does not limit requests", func() {
+			for i := 0; i < 5; i++ {
+				Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusOK))
+			}
+		})
+	})
+
+	Context("when the route service signature is invalid", func() {
+		BeforeEach(func() {
+			skip = func(*http.Request) (bool, error) { return false, errors.New("invalid signature") }
+		})
+
+		It("returns a 400", func() {
+			Expect(request("10.0.0.1:1234", nil).Code).To(Equal(http.StatusBadRequest))
+			Expect(nextCalls).To(Equal(0))
+		})
+	})
+})
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/gorouter/metrics"
@@ -48,8 +49,14 @@ func (rh *reporterHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request, ne
 	if requestInfo.StoppedAt.Equal(time.Time{}) {
 		return
 	}
+	latency := requestInfo.StoppedAt.Sub(requestInfo.StartedAt)
 	rh.reporter.CaptureRoutingResponseLatency(
 		requestInfo.RouteEndpoint, proxyWriter.Status(),
-		requestInfo.StartedAt, requestInfo.StoppedAt.Sub(requestInfo.StartedAt),
+		requestInfo.StartedAt, latency,
 	)
+
+	if requestInfo.RoutePool != nil {
+		uri := strings.TrimSuffix(requestInfo.RoutePool.Host()+requestInfo.RoutePool.ContextPath(), "/")
+		rh.reporter.CaptureRouteResponse(uri, proxyWriter.Status(), latency)
+	}
 }
@@ -87,6 +87,29 @@ var _ = Describe("Reporter Handler", func() {
 		Expect(nextCalled).To(BeTrue(), "Expected the next handler to be called.")
 	})
 
+	Context("when the route pool is known", func() {
+		BeforeEach(func() {
+			next := nextHandler
+			nextHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				reqInfo, err := handlers.ContextRequestInfo(req)
+				Expect(err).NotTo(HaveOccurred())
+				reqInfo.RoutePool = route.NewPool(&route.PoolOpts{Host: "example.com", ContextPath: "/api"})
+
+				next.ServeHTTP(rw, req)
+			})
+		})
+
+		It("emits the response metrics of the route", func() {
+			handler.ServeHTTP(resp, req)
+
+			Expect(fakeReporter.CaptureRouteResponseCallCount()).To(Equal(1))
+			uri, code, latency := fakeReporter.CaptureRouteResponseArgsForCall(0)
+			Expect(uri).To(Equal("example.com/api"))
+			Expect(code).To(Equal(http.StatusTeapot))
+			Expect(latency).To(BeNumerically(">", 0))
+		})
+	})
+
 	Context("when reqInfo.StoppedAt is 0", func() {
 		BeforeEach(func() {
 			nextHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
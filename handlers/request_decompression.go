@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+// DecompressRequestTag makes Gorouter decompress the gzip request bodies of
+// a route before proxying them, when it is set to "true".
+const DecompressRequestTag = "DecompressRequest"
+
+type requestDecompression struct {
+	maxBytes int64
+	logger   logger.Logger
+}
+
+// NewRequestDecompression creates a handler that decompresses the gzip
+// request bodies of routes tagged with DecompressRequestTag, for backends
+// that cannot handle Content-Encoding. Decompressed bodies fail with
+// ErrRequestBodyTooLarge once they grow over maxBytes while they are proxied.
+func NewRequestDecompression(maxBytes int64, logger logger.Logger) negroni.Handler {
+	return &requestDecompression{
+		maxBytes: maxBytes,
+		logger:   logger,
+	}
+}
+
+func (d *requestDecompression) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		d.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+	if reqInfo.RoutePool == nil {
+		d.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+	default:
+		next(rw, r)
+		return
+	}
+	if r.Body == nil || r.Body == http.NoBody || !isDecompressRequestRoute(reqInfo.RoutePool) {
+		next(rw, r)
+		return
+	}
+
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		d.logger.Info("request-decompression-failed", zap.Error(err))
+		rw.Header().Set("X-Cf-RouterError", "invalid_request_body_encoding")
+		writeStatus(
+			rw,
+			http.StatusBadRequest,
+			"Request body is not valid gzip.",
+			d.logger,
+		)
+		return
+	}
+
+	r.Body = &limitedBody{
+		ReadCloser: &gzipBody{Reader: reader, body: r.Body},
+		remaining:  d.maxBytes,
+	}
+	r.ContentLength = -1
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	next(rw, r)
+}
+
+func isDecompressRequestRoute(pool *route.Pool) bool {
+	var tagged bool
+	pool.Each(func(e *route.Endpoint) {
+		tagged = tagged || e.Tags[DecompressRequestTag] == "true"
+	})
+	return tagged
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
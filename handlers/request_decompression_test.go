@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("RequestDecompression", func() {
+	var (
+		maxBytes     int64
+		endpointOpts *route.EndpointOpts
+		req          *http.Request
+		body         []byte
+		readErr      error
+		nextReq      *http.Request
+	)
+
+	process := func() *httptest.ResponseRecorder {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool")})
+		pool.Put(route.NewEndpoint(endpointOpts))
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		n.Use(handlers.NewRequestDecompression(maxBytes, new(logger_fakes.FakeLogger)))
+		n.UseHandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			nextReq = r
+			body, readErr = ioutil.ReadAll(r.Body)
+		})
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write([]byte(s))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		return buf.Bytes()
+	}
+
+	newRequest := func(data []byte) *http.Request {
+		r := test_util.NewRequest("POST", "example.com", "/", bytes.NewReader(data))
+		r.Header.Set("Content-Encoding", "gzip")
+		r.Header.Set("Content-Length", "42")
+		return r
+	}
+
+	BeforeEach(func() {
+		maxBytes = 100
+		endpointOpts = &route.EndpointOpts{
+			Host: "1.1.1.1",
+			Port: 8080,
+			Tags: map[string]string{handlers.DecompressRequestTag: "true"},
+		}
+		req = newRequest(gzipped("hello"))
+		nextReq = nil
+	})
+
+	It("decompresses gzip bodies for tagged routes", func() {
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(readErr).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("hello"))
+		Expect(nextReq.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(nextReq.Header.Get("Content-Length")).To(BeEmpty())
+		Expect(nextReq.ContentLength).To(BeEquivalentTo(-1))
+	})
+
+	It("fails bodies that decompress to more than the limit", func() {
+		req = newRequest(gzipped(strings.Repeat("a", 101)))
+
+		process()
+		Expect(readErr).To(Equal(handlers.ErrRequestBodyTooLarge))
+		Expect(len(body)).To(BeNumerically("<=", 100))
+	})
+
+	It("rejects bodies that are not gzip with a 400", func() {
+		req = newRequest([]byte("hello"))
+
+		res := process()
+		Expect(res.Code).To(Equal(http.StatusBadRequest))
+		Expect(res.Header().Get("X-Cf-RouterError")).To(Equal("invalid_request_body_encoding"))
+		Expect(nextReq).To(BeNil())
+	})
+
+	It("leaves bodies with other encodings alone", func() {
+		req.Header.Set("Content-Encoding", "br")
+
+		process()
+		Expect(body).To(Equal(gzipped("hello")))
+		Expect(nextReq.Header.Get("Content-Encoding")).To(Equal("br"))
+	})
+
+	Context("when the route is not tagged", func() {
+		BeforeEach(func() {
+			endpointOpts.Tags = nil
+		})
+
+		It("leaves the body alone", func() {
+			process()
+			Expect(body).To(Equal(gzipped("hello")))
+			Expect(nextReq.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		})
+	})
+})
@@ -9,6 +9,7 @@ import (
 
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/tracing"
 
 	"github.com/urfave/negroni"
 )
@@ -26,6 +27,18 @@ type RequestInfo struct {
 	ProxyResponseWriter    utils.ProxyResponseWriter
 	RouteServiceURL        *url.URL
 	IsInternalRouteService bool
+	// UpstreamTLS is set when a trusted terminating proxy reports that the
+	// client connected over HTTPS.
+	UpstreamTLS bool
+	// TraceSpan is the server span of the request, or nil when tracing is
+	// disabled.
+	TraceSpan *tracing.Span
+	// HashKey is the request attribute that endpoints are selected by when
+	// the load balancing algorithm is consistent-hash.
+	HashKey string
+	// RequestedHost is the Host that the client sent, when the request to
+	// the backend is sent with the Host that the route registered instead.
+	RequestedHost string
 
 	BackendReqHeaders http.Header
 }
@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+)
+
+// CacheTTLTag is the route tag that opts a route in to response caching. Its
+// value is a duration such as "30s", or a number of seconds.
+const CacheTTLTag = "CacheTTL"
+
+// ResponseCache caches the responses of routes that opt in with the
+// CacheTTL tag.
+type ResponseCache struct {
+	memory *lruCache
+	disk   *diskCache
+	logger logger.Logger
+}
+
+// NewResponseCache creates a handler that caches successful GET responses
+// for routes tagged with CacheTTL in memory, using at most cfg.MaxSizeBytes.
+// Cached responses are served without contacting the backend until they
+// expire, after which they are revalidated with If-None-Match when they have
+// an ETag. HEAD requests are answered from cached GET responses. Responses
+// marked no-store, no-cache or private are never cached. When cfg.DiskPath is
+// set, responses evicted from memory are kept in files there, using at most
+// cfg.DiskMaxSizeBytes, until they are requested again.
+func NewResponseCache(cfg config.ResponseCacheConfig, logger logger.Logger) (*ResponseCache, error) {
+	c := &ResponseCache{
+		memory: newLRUCache(cfg.MaxSizeBytes),
+		logger: logger,
+	}
+	if cfg.DiskPath != "" {
+		disk, err := newDiskCache(cfg.DiskPath, cfg.DiskMaxSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+	}
+	return c, nil
+}
+
+func (c *ResponseCache) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		c.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	if !cacheableRequest(r) || reqInfo.RoutePool == nil || reqInfo.RouteServiceURL != nil {
+		next(rw, r)
+		return
+	}
+
+	// responses are cached by URI, so they cannot be cached for routes
+	// whose endpoints are selected by method or headers
+	if IsStreamingRoute(reqInfo.RoutePool) || reqInfo.RoutePool.Matched() {
+		next(rw, r)
+		return
+	}
+
+	ttl := routeCacheTTL(reqInfo.RoutePool)
+	if ttl <= 0 {
+		next(rw, r)
+		return
+	}
+
+	key := cacheKey(r.Host, r.URL.RequestURI())
+	entry := c.get(key)
+	now := time.Now()
+	if entry != nil && now.Before(entry.expires) {
+		c.logger.Debug("response-cache-hit", zap.String("key", key))
+		entry.serve(rw, r, now)
+		return
+	}
+
+	// only GET responses are stored, HEAD requests just make use of them
+	if r.Method == http.MethodHead {
+		next(rw, r)
+		return
+	}
+
+	revalidating := false
+	if entry != nil && entry.etag != "" && r.Header.Get("If-None-Match") == "" {
+		r.Header.Set("If-None-Match", entry.etag)
+		revalidating = true
+	}
+
+	crw := &cachingResponseWriter{
+		ProxyResponseWriter: rw.(utils.ProxyResponseWriter),
+		revalidating:        revalidating,
+		maxBytes:            c.memory.maxBytes,
+	}
+	next(crw, r)
+	if revalidating {
+		r.Header.Del("If-None-Match")
+	}
+
+	if crw.notModified {
+		c.logger.Debug("response-cache-revalidated", zap.String("key", key))
+		entry = c.memory.refresh(key, entryTTL(ttl, entry.header), now)
+		if entry != nil {
+			entry.serve(rw, r, now)
+		}
+		return
+	}
+
+	if !crw.capturing {
+		return
+	}
+
+	entryTTL := entryTTL(ttl, crw.header)
+	if entryTTL <= 0 {
+		return
+	}
+	c.store(&cacheEntry{
+		key:     key,
+		header:  crw.header,
+		body:    crw.body,
+		etag:    crw.header.Get("ETag"),
+		stored:  now,
+		expires: now.Add(entryTTL),
+	})
+}
+
+// Purge removes the cached responses for host whose path starts with
+// pathPrefix, and returns how many were removed. An empty pathPrefix removes
+// all responses for host.
+func (c *ResponseCache) Purge(host, pathPrefix string) int {
+	prefix := cacheKey(host, pathPrefix)
+	purged := c.memory.purge(prefix)
+	if c.disk != nil {
+		purged += c.disk.purge(prefix)
+	}
+	c.logger.Info("response-cache-purged", zap.String("prefix", prefix), zap.Int("count", purged))
+	return purged
+}
+
+// PurgeHandler returns a handler that purges cached responses on DELETE
+// requests, with the host and optional path prefix given in the host and
+// path query parameters, and responds with the number of responses purged.
+func (c *ResponseCache) PurgeHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			rw.Header().Set("Allow", http.MethodDelete)
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		host := r.URL.Query().Get("host")
+		if host == "" {
+			http.Error(rw, "host is required", http.StatusBadRequest)
+			return
+		}
+
+		purged := c.Purge(host, r.URL.Query().Get("path"))
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]int{"purged": purged})
+	})
+}
+
+// get returns the cached response for key, moving it back into memory if it
+// was evicted to disk.
+func (c *ResponseCache) get(key string) *cacheEntry {
+	if entry := c.memory.get(key); entry != nil {
+		return entry
+	}
+	if c.disk == nil {
+		return nil
+	}
+	entry, err := c.disk.take(key)
+	if err != nil {
+		c.logger.Error("response-cache-disk-read-failed", zap.String("key", key), zap.Error(err))
+		return nil
+	}
+	if entry != nil {
+		c.store(entry)
+	}
+	return entry
+}
+
+func (c *ResponseCache) store(entry *cacheEntry) {
+	if c.disk != nil {
+		c.disk.remove(entry.key)
+	}
+	for _, evicted := range c.memory.add(entry) {
+		if c.disk == nil {
+			continue
+		}
+		if err := c.disk.put(evicted); err != nil {
+			c.logger.Error("response-cache-disk-write-failed", zap.String("key", evicted.key), zap.Error(err))
+		}
+	}
+}
+
+// cacheKey ignores the case of the host, so that purges match regardless of
+// how clients spell it.
+func cacheKey(host, requestURI string) string {
+	return strings.ToLower(host) + requestURI
+}
+
+type cachingResponseWriter struct {
+	utils.ProxyResponseWriter
+	revalidating bool
+	maxBytes     int64
+
+	notModified bool
+	capturing   bool
+	header      http.Header
+	body        []byte
+}
+
+func (w *cachingResponseWriter) WriteHeader(s int) {
+	if w.revalidating && s == http.StatusNotModified {
+		// The cached response is served once the handler chain returns.
+		w.notModified = true
+		return
+	}
+
+	if s == http.StatusOK && cacheableResponse(w.Header()) {
+		w.capturing = true
+		w.header = cloneHeader(w.Header())
+	}
+
+	w.ProxyResponseWriter.WriteHeader(s)
+}
+
+func (w *cachingResponseWriter) Write(b []byte) (int, error) {
+	if w.notModified {
+		return len(b), nil
+	}
+	if w.Status() == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.capturing {
+		if int64(len(w.body)+len(b)) > w.maxBytes {
+			w.capturing = false
+			w.body = nil
+		} else {
+			w.body = append(w.body, b...)
+		}
+	}
+
+	return w.ProxyResponseWriter.Write(b)
+}
+
+func (w *cachingResponseWriter) Flush() {
+	if w.notModified {
+		return
+	}
+	w.ProxyResponseWriter.Flush()
+}
+
+type cacheEntry struct {
+	key     string
+	header  http.Header
+	body    []byte
+	etag    string
+	stored  time.Time
+	expires time.Time
+}
+
+func (e *cacheEntry) size() int64 {
+	size := int64(len(e.key) + len(e.body))
+	for k, vs := range e.header {
+		size += int64(len(k))
+		for _, v := range vs {
+			size += int64(len(v))
+		}
+	}
+	return size
+}
+
+func (e *cacheEntry) serve(rw http.ResponseWriter, r *http.Request, now time.Time) {
+	header := rw.Header()
+	for k := range header {
+		delete(header, k)
+	}
+	for k, vs := range e.header {
+		header[k] = append([]string(nil), vs...)
+	}
+	header.Set("Age", strconv.Itoa(int(now.Sub(e.stored).Seconds())))
+
+	if e.etag != "" && r.Header.Get("If-None-Match") == e.etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(e.body)))
+	rw.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		rw.Write(e.body)
+	}
+}
+
+// lruCache holds cache entries up to a total size, evicting the least
+// recently used entries first.
+type lruCache struct {
+	sync.Mutex
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+func newLRUCache(maxBytes int64) *lruCache {
+	return &lruCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key string) *cacheEntry {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+// add stores e and returns the entries evicted to make room for it.
+func (c *lruCache) add(e *cacheEntry) []*cacheEntry {
+	size := e.size()
+	if size > c.maxBytes {
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.items[e.key]; ok {
+		c.removeElement(el)
+	}
+	c.items[e.key] = c.ll.PushFront(e)
+	c.size += size
+
+	var evicted []*cacheEntry
+	for c.size > c.maxBytes {
+		evicted = append(evicted, c.removeElement(c.ll.Back()))
+	}
+	return evicted
+}
+
+// purge removes the entries whose key starts with prefix and returns how
+// many were removed.
+func (c *lruCache) purge(prefix string) int {
+	c.Lock()
+	defer c.Unlock()
+
+	purged := 0
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+			purged++
+		}
+	}
+	return purged
+}
+
+// refresh extends the expiry of a revalidated entry and returns it, or nil
+// if it has been evicted in the meantime.
+func (c *lruCache) refresh(key string, ttl time.Duration, now time.Time) *cacheEntry {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	old := el.Value.(*cacheEntry)
+	e := *old
+	e.stored = now
+	e.expires = now.Add(ttl)
+	el.Value = &e
+	return &e
+}
+
+func (c *lruCache) removeElement(el *list.Element) *cacheEntry {
+	e := c.ll.Remove(el).(*cacheEntry)
+	delete(c.items, e.key)
+	c.size -= e.size()
+	return e
+}
+
+func cacheableRequest(r *http.Request) bool {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" || upgradeHeader(r) != "" {
+		return false
+	}
+	directives := cacheControl(r.Header)
+	_, noStore := directives["no-store"]
+	_, noCache := directives["no-cache"]
+	return !noStore && !noCache
+}
+
+func cacheableResponse(header http.Header) bool {
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") != "" || IsEventStream(header.Get("Content-Type")) {
+		return false
+	}
+	directives := cacheControl(header)
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// entryTTL returns the route TTL, shortened to the max-age of the response
+// if it has a lower one.
+func entryTTL(routeTTL time.Duration, header http.Header) time.Duration {
+	directives := cacheControl(header)
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[d]; ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil {
+				return 0
+			}
+			if maxAge := time.Duration(seconds) * time.Second; maxAge < routeTTL {
+				return maxAge
+			}
+			return routeTTL
+		}
+	}
+	return routeTTL
+}
+
+func cacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if i := strings.Index(directive, "="); i >= 0 {
+				name, arg = directive[:i], strings.Trim(directive[i+1:], `"`)
+			}
+			directives[strings.ToLower(name)] = arg
+		}
+	}
+	return directives
+}
+
+func routeCacheTTL(pool *route.Pool) time.Duration {
+	var ttl time.Duration
+	pool.Each(func(e *route.Endpoint) {
+		if ttl != 0 {
+			return
+		}
+		ttl = parseCacheTTL(e.Tags[CacheTTLTag])
+	})
+	return ttl
+}
+
+func parseCacheTTL(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+func cloneHeader(h http.Header) http.Header {
+	clone := make(http.Header, len(h))
+	for k, vs := range h {
+		clone[k] = append([]string(nil), vs...)
+	}
+	return clone
+}
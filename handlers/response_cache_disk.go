@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const diskCacheFileSuffix = ".cache"
+
+// diskCache keeps cache entries evicted from memory in files in a directory,
+// up to a total size, evicting the least recently stored entries first. The
+// index of the files is only held in memory, so files left over from a
+// previous run are removed when it is created.
+type diskCache struct {
+	sync.Mutex
+	dir      string
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type diskCacheItem struct {
+	key  string
+	size int64
+}
+
+// diskCacheEntry is the encoding of a cache entry in its file.
+type diskCacheEntry struct {
+	Key     string
+	Header  http.Header
+	Body    []byte
+	ETag    string
+	Stored  time.Time
+	Expires time.Time
+}
+
+func newDiskCache(dir string, maxBytes int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating response cache directory: %s", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"+diskCacheFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			return nil, fmt.Errorf("removing stale response cache file: %s", err)
+		}
+	}
+
+	return &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}, nil
+}
+
+func (d *diskCache) put(e *cacheEntry) error {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(diskCacheEntry{
+		Key:     e.key,
+		Header:  e.header,
+		Body:    e.body,
+		ETag:    e.etag,
+		Stored:  e.stored,
+		Expires: e.expires,
+	})
+	if err != nil {
+		return err
+	}
+	size := int64(buf.Len())
+	if size > d.maxBytes {
+		return nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if el, ok := d.items[e.key]; ok {
+		d.removeElement(el)
+	}
+	if err := ioutil.WriteFile(d.path(e.key), buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	d.items[e.key] = d.ll.PushFront(&diskCacheItem{key: e.key, size: size})
+	d.size += size
+
+	for d.size > d.maxBytes {
+		d.removeElement(d.ll.Back())
+	}
+	return nil
+}
+
+// take removes the entry for key from disk and returns it, or nil if there
+// is none.
+func (d *diskCache) take(key string) (*cacheEntry, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	el, ok := d.items[key]
+	if !ok {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(d.path(key))
+	d.removeElement(el)
+	if err != nil {
+		return nil, err
+	}
+
+	var de diskCacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&de); err != nil {
+		return nil, err
+	}
+	return &cacheEntry{
+		key:     de.Key,
+		header:  de.Header,
+		body:    de.Body,
+		etag:    de.ETag,
+		stored:  de.Stored,
+		expires: de.Expires,
+	}, nil
+}
+
+func (d *diskCache) remove(key string) {
+	d.Lock()
+	defer d.Unlock()
+
+	if el, ok := d.items[key]; ok {
+		d.removeElement(el)
+	}
+}
+
+// purge removes the entries whose key starts with prefix and returns how
+// many were removed.
+func (d *diskCache) purge(prefix string) int {
+	d.Lock()
+	defer d.Unlock()
+
+	purged := 0
+	for key, el := range d.items {
+		if strings.HasPrefix(key, prefix) {
+			d.removeElement(el)
+			purged++
+		}
+	}
+	return purged
+}
+
+func (d *diskCache) removeElement(el *list.Element) {
+	item := d.ll.Remove(el).(*diskCacheItem)
+	delete(d.items, item.key)
+	d.size -= item.size
+	os.Remove(d.path(item.key))
+}
+
+// path names files by a hash of the key, since keys contain characters that
+// are not valid in file names.
+func (d *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+diskCacheFileSuffix)
+}
@@ -0,0 +1,448 @@
+package handlers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("ResponseCache", func() {
+	var (
+		handler      *handlers.ResponseCache
+		maxBytes     int64
+		diskPath     string
+		diskMaxBytes int64
+		cacheTTL     string
+		streaming    string
+		matchHeaders map[string]string
+		backendCalls int
+		backend      http.HandlerFunc
+	)
+
+	process := func(req *http.Request) *httptest.ResponseRecorder {
+		pool := route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("pool")})
+		pool.Put(route.NewEndpoint(&route.EndpointOpts{
+			Host: "1.1.1.1",
+			Port: 8080,
+			Tags: map[string]string{handlers.CacheTTLTag: cacheTTL, handlers.StreamingTag: streaming},
+
+			MatchHeaders: matchHeaders,
+		}))
+
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.Use(handlers.NewProxyWriter(test_util.NewTestZapLogger("response-cache")))
+		n.UseFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			reqInfo.RoutePool = pool
+			next(rw, r)
+		})
+		n.Use(handler)
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			backendCalls++
+			backend(rw, r)
+		})
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, req)
+		return res
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		return process(httptest.NewRequest("GET", "http://example.com"+path, nil))
+	}
+
+	body := func(res *httptest.ResponseRecorder) string {
+		b, err := ioutil.ReadAll(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return string(b)
+	}
+
+	BeforeEach(func() {
+		maxBytes = 1024 * 1024
+		diskPath = ""
+		diskMaxBytes = 0
+		cacheTTL = "1m"
+		streaming = ""
+		matchHeaders = nil
+		backendCalls = 0
+		backend = func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "text/css")
+			rw.Header().Set("ETag", `"v1"`)
+			rw.WriteHeader(http.StatusOK)
+			rw.Write([]byte("body { color: red }"))
+		}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		handler, err = handlers.NewResponseCache(config.ResponseCacheConfig{
+			MaxSizeBytes:     maxBytes,
+			DiskPath:         diskPath,
+			DiskMaxSizeBytes: diskMaxBytes,
+		}, test_util.NewTestZapLogger("response-cache"))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("serves a second request for a cacheable asset from the cache", func() {
+		res := get("/app.css")
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(body(res)).To(Equal("body { color: red }"))
+
+		res = get("/app.css")
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(body(res)).To(Equal("body { color: red }"))
+		Expect(res.Header().Get("Content-Type")).To(Equal("text/css"))
+		Expect(res.Header().Get("Age")).ToNot(BeEmpty())
+
+		Expect(backendCalls).To(Equal(1))
+	})
+
+	It("caches each path separately", func() {
+		get("/app.css")
+		get("/other.css")
+		Expect(backendCalls).To(Equal(2))
+	})
+
+	It("responds with a 304 from the cache when the client has the current version", func() {
+		get("/app.css")
+
+		req := httptest.NewRequest("GET", "http://example.com/app.css", nil)
+		req.Header.Set("If-None-Match", `"v1"`)
+		res := process(req)
+
+		Expect(res.Code).To(Equal(http.StatusNotModified))
+		Expect(backendCalls).To(Equal(1))
+	})
+
+	It("answers HEAD requests from a cached GET response", func() {
+		get("/app.css")
+
+		res := process(httptest.NewRequest("HEAD", "http://example.com/app.css", nil))
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Content-Length")).To(Equal("19"))
+		Expect(body(res)).To(BeEmpty())
+		Expect(backendCalls).To(Equal(1))
+	})
+
+	It("does not cache responses to HEAD requests", func() {
+		process(httptest.NewRequest("HEAD", "http://example.com/app.css", nil))
+		get("/app.css")
+		Expect(backendCalls).To(Equal(2))
+	})
+
+	It("does not cache requests other than GET", func() {
+		process(httptest.NewRequest("POST", "http://example.com/app.css", strings.NewReader("a")))
+		process(httptest.NewRequest("POST", "http://example.com/app.css", strings.NewReader("a")))
+		Expect(backendCalls).To(Equal(2))
+	})
+
+	It("does not cache requests with an Authorization header", func() {
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("GET", "http://example.com/app.css", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			process(req)
+		}
+		Expect(backendCalls).To(Equal(2))
+	})
+
+	Context("when the route is not tagged with CacheTTL", func() {
+		BeforeEach(func() {
+			cacheTTL = ""
+		})
+
+		It("does not cache responses", func() {
+			get("/app.css")
+			get("/app.css")
+			Expect(backendCalls).To(Equal(2))
+		})
+	})
+
+	Context("when the response must not be stored", func() {
+		for _, directive := range []string{"no-store", "private", "no-cache"} {
+			directive := directive
+
+			It("does not cache responses with Cache-Control: "+directive, func() {
+				backend = func(rw http.ResponseWriter, r *http.Request) {
+					rw.Header().Set("Cache-Control", directive)
+					rw.Write([]byte("secret"))
+				}
+
+				get("/app.css")
+				res := get("/app.css")
+				Expect(body(res)).To(Equal("secret"))
+				Expect(backendCalls).To(Equal(2))
+			})
+		}
+	})
+
+	Context("when the response is an event stream", func() {
+		BeforeEach(func() {
+			backend = func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("Content-Type", "text/event-stream")
+				rw.WriteHeader(http.StatusOK)
+				rw.Write([]byte("data: hello\n\n"))
+			}
+		})
+
+		It("does not cache it", func() {
+			get("/events")
+			get("/events")
+			Expect(backendCalls).To(Equal(2))
+		})
+	})
+
+	Context("when the route is tagged as streaming", func() {
+		BeforeEach(func() {
+			streaming = "true"
+		})
+
+		It("does not cache responses", func() {
+			get("/app.css")
+			get("/app.css")
+			Expect(backendCalls).To(Equal(2))
+		})
+	})
+
+	Context("when the endpoints of the route are selected by headers", func() {
+		BeforeEach(func() {
+			matchHeaders = map[string]string{"X-Api-Version": "2"}
+		})
+
+		It("does not cache responses", func() {
+			get("/app.css")
+			get("/app.css")
+			Expect(backendCalls).To(Equal(2))
+		})
+	})
+
+	Context("when the response is not successful", func() {
+		BeforeEach(func() {
+			backend = func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusNotFound)
+			}
+		})
+
+		It("does not cache it", func() {
+			get("/missing.css")
+			res := get("/missing.css")
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(backendCalls).To(Equal(2))
+		})
+	})
+
+	Context("when the cached response has expired", func() {
+		BeforeEach(func() {
+			cacheTTL = "20ms"
+		})
+
+		It("revalidates with If-None-Match and serves the cached response on a 304", func() {
+			get("/app.css")
+			time.Sleep(30 * time.Millisecond)
+
+			backend = func(rw http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("If-None-Match")).To(Equal(`"v1"`))
+				rw.WriteHeader(http.StatusNotModified)
+			}
+			res := get("/app.css")
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(body(res)).To(Equal("body { color: red }"))
+			Expect(backendCalls).To(Equal(2))
+
+			res = get("/app.css")
+			Expect(body(res)).To(Equal("body { color: red }"))
+			Expect(backendCalls).To(Equal(2))
+		})
+
+		It("replaces the cached response when the asset has changed", func() {
+			get("/app.css")
+			time.Sleep(30 * time.Millisecond)
+
+			backend = func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("ETag", `"v2"`)
+				rw.Write([]byte("body { color: blue }"))
+			}
+			res := get("/app.css")
+			Expect(body(res)).To(Equal("body { color: blue }"))
+
+			res = get("/app.css")
+			Expect(body(res)).To(Equal("body { color: blue }"))
+			Expect(backendCalls).To(Equal(2))
+		})
+	})
+
+	Context("when the response max-age is lower than the route TTL", func() {
+		BeforeEach(func() {
+			backend = func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("Cache-Control", "public, max-age=0")
+				rw.Write([]byte("fresh"))
+			}
+		})
+
+		It("uses the max-age", func() {
+			get("/app.css")
+			get("/app.css")
+			Expect(backendCalls).To(Equal(2))
+		})
+	})
+
+	Context("when the cache is full", func() {
+		BeforeEach(func() {
+			maxBytes = 200
+			backend = func(rw http.ResponseWriter, r *http.Request) {
+				rw.Write([]byte(strings.Repeat("a", 80)))
+			}
+		})
+
+		It("evicts the least recently used response", func() {
+			get("/a")
+			get("/b")
+			get("/a")
+			get("/c")
+			Expect(backendCalls).To(Equal(3))
+
+			get("/a")
+			Expect(backendCalls).To(Equal(3))
+
+			get("/b")
+			Expect(backendCalls).To(Equal(4))
+		})
+
+		It("does not cache responses larger than the cache", func() {
+			backend = func(rw http.ResponseWriter, r *http.Request) {
+				rw.Write([]byte(strings.Repeat("a", 300)))
+			}
+
+			get("/large")
+			res := get("/large")
+			Expect(body(res)).To(HaveLen(300))
+			Expect(backendCalls).To(Equal(2))
+		})
+
+		Context("when a disk path is configured", func() {
+			BeforeEach(func() {
+				var err error
+				diskPath, err = ioutil.TempDir("", "response-cache")
+				Expect(err).ToNot(HaveOccurred())
+				diskMaxBytes = 1024 * 1024
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(diskPath)
+			})
+
+			It("serves evicted responses from disk", func() {
+				get("/a")
+				get("/b")
+				get("/c")
+				Expect(backendCalls).To(Equal(3))
+
+				files, err := ioutil.ReadDir(diskPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(files).To(HaveLen(1))
+
+				res := get("/a")
+				Expect(body(res)).To(Equal(strings.Repeat("a", 80)))
+				Expect(backendCalls).To(Equal(3))
+			})
+
+			It("removes files left over from a previous run", func() {
+				stale := filepath.Join(diskPath, "stale.cache")
+				Expect(ioutil.WriteFile(stale, []byte("stale"), 0600)).To(Succeed())
+
+				_, err := handlers.NewResponseCache(config.ResponseCacheConfig{
+					MaxSizeBytes:     maxBytes,
+					DiskPath:         diskPath,
+					DiskMaxSizeBytes: diskMaxBytes,
+				}, test_util.NewTestZapLogger("response-cache"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stale).ToNot(BeAnExistingFile())
+			})
+
+			It("purges responses on disk", func() {
+				get("/a")
+				get("/b")
+				get("/c")
+
+				Expect(handler.Purge("example.com", "/a")).To(Equal(1))
+				get("/a")
+				Expect(backendCalls).To(Equal(4))
+			})
+		})
+	})
+
+	Describe("Purge", func() {
+		JustBeforeEach(func() {
+			get("/static/app.css")
+			get("/static/app.js")
+			get("/index.html")
+			Expect(backendCalls).To(Equal(3))
+		})
+
+		It("removes the responses whose path starts with the prefix", func() {
+			Expect(handler.Purge("example.com", "/static/")).To(Equal(2))
+
+			get("/static/app.css")
+			get("/index.html")
+			Expect(backendCalls).To(Equal(4))
+		})
+
+		It("removes all responses for the host without a prefix", func() {
+			Expect(handler.Purge("EXAMPLE.com", "")).To(Equal(3))
+
+			get("/index.html")
+			Expect(backendCalls).To(Equal(4))
+		})
+
+		It("does not remove responses for other hosts", func() {
+			Expect(handler.Purge("other.example.com", "")).To(Equal(0))
+
+			get("/index.html")
+			Expect(backendCalls).To(Equal(3))
+		})
+	})
+
+	Describe("PurgeHandler", func() {
+		var purge = func(method, target string) *httptest.ResponseRecorder {
+			res := httptest.NewRecorder()
+			handler.PurgeHandler().ServeHTTP(res, httptest.NewRequest(method, target, nil))
+			return res
+		}
+
+		It("purges responses and responds with their number", func() {
+			get("/static/app.css")
+
+			res := purge("DELETE", "/response_cache?host=example.com&path=/static/")
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(body(res)).To(MatchJSON(`{"purged": 1}`))
+
+			get("/static/app.css")
+			Expect(backendCalls).To(Equal(2))
+		})
+
+		It("requires the host", func() {
+			res := purge("DELETE", "/response_cache?path=/static/")
+			Expect(res.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("only accepts DELETE requests", func() {
+			res := purge("GET", "/response_cache?host=example.com")
+			Expect(res.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(res.Header().Get("Allow")).To(Equal("DELETE"))
+		})
+	})
+})
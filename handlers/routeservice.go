@@ -179,6 +179,24 @@ func (r *RouteService) ArrivedViaRouteService(req *http.Request) (bool, error) {
 	return false, nil
 }
 
+// HasValidSignature reports whether req carries a route service signature
+// that the router made and that has not expired. Unlike
+// ArrivedViaRouteService it does not check the route that the signature was
+// made for, so that it can be used before the route of req is looked up.
+func (r *RouteService) HasValidSignature(req *http.Request) bool {
+	if !r.config.RouteServiceEnabled() || req.Header.Get(routeservice.HeaderKeySignature) == "" {
+		return false
+	}
+
+	recommendedScheme := "http"
+	if r.config.RouteServiceRecommendHttps() {
+		recommendedScheme = "https"
+	}
+	forwardedURLRaw := recommendedScheme + "://" + hostWithoutPort(req.Host) + req.RequestURI
+	_, err := r.config.ValidatedSignature(&req.Header, forwardedURLRaw)
+	return err == nil
+}
+
 func (r *RouteService) validateRouteServicePool(validatedSig *routeservice.Signature, reqInfo *RequestInfo) error {
 	forwardedURL, err := url.Parse(validatedSig.ForwardedUrl)
 	if err != nil {
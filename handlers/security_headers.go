@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"github.com/urfave/negroni"
+)
+
+type securityHeaders struct {
+	policies []securityHeadersPolicy
+}
+
+type securityHeadersPolicy struct {
+	domains []string
+	header  http.Header
+}
+
+// NewSecurityHeaders creates a handler that sets the security headers of the
+// first policy that matches the host of a request on its response, replacing
+// those sent by the backend. They are also set on responses from Gorouter
+// itself, such as 404s for unknown routes.
+func NewSecurityHeaders(cfg []config.SecurityHeadersConfig) negroni.Handler {
+	policies := make([]securityHeadersPolicy, 0, len(cfg))
+	for _, c := range cfg {
+		header := http.Header{}
+		if c.HSTS.MaxAge > 0 {
+			value := "max-age=" + strconv.Itoa(int(c.HSTS.MaxAge.Seconds()))
+			if c.HSTS.IncludeSubDomains {
+				value += "; includeSubDomains"
+			}
+			header.Set("Strict-Transport-Security", value)
+		}
+		if c.ContentTypeOptions {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if c.FrameOptions != "" {
+			header.Set("X-Frame-Options", c.FrameOptions)
+		}
+		domains := make([]string, 0, len(c.Domains))
+		for _, domain := range c.Domains {
+			domains = append(domains, strings.ToLower(domain))
+		}
+		policies = append(policies, securityHeadersPolicy{domains: domains, header: header})
+	}
+	return &securityHeaders{
+		policies: policies,
+	}
+}
+
+func (s *securityHeaders) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	host := requestDomain(r)
+	for _, policy := range s.policies {
+		if domainMatches(policy.domains, host) {
+			rw.(utils.ProxyResponseWriter).AddHeaderRewriter(&utils.SetHeaderRewriter{Header: policy.header})
+			break
+		}
+	}
+	next(rw, r)
+}
+
+// requestDomain returns the host of r without its port, in lower case.
+func requestDomain(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// domainMatches reports whether host is one of domains, which must be in
+// lower case, is a subdomain of one of their wildcards, or domains contains
+// "*".
+func domainMatches(domains []string, host string) bool {
+	for _, domain := range domains {
+		if domain == "*" || domain == host {
+			return true
+		}
+		if strings.HasPrefix(domain, "*.") && strings.HasSuffix(host, domain[1:]) {
+			return true
+		}
+	}
+	return false
+}
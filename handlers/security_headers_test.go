@@ -0,0 +1,81 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("SecurityHeaders", func() {
+	var cfg []config.SecurityHeadersConfig
+
+	process := func(host string) *httptest.ResponseRecorder {
+		n := negroni.New()
+		n.Use(handlers.NewRequestInfo())
+		n.Use(handlers.NewProxyWriter(new(logger_fakes.FakeLogger)))
+		n.Use(handlers.NewSecurityHeaders(cfg))
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("X-Frame-Options", "ALLOW-FROM https://example.org")
+			rw.WriteHeader(http.StatusOK)
+		})
+
+		res := httptest.NewRecorder()
+		n.ServeHTTP(res, test_util.NewRequest("GET", host, "/", nil))
+		return res
+	}
+
+	BeforeEach(func() {
+		cfg = []config.SecurityHeadersConfig{
+			{
+				Domains:            []string{"*.apps.example.com", "example.com"},
+				HSTS:               config.HSTSConfig{MaxAge: 365 * 24 * time.Hour, IncludeSubDomains: true},
+				ContentTypeOptions: true,
+				FrameOptions:       config.FrameOptionsDeny,
+			},
+			{
+				Domains:            []string{"*"},
+				ContentTypeOptions: true,
+			},
+		}
+	})
+
+	It("sets the headers of the first matching policy, replacing those of the backend", func() {
+		res := process("app.apps.example.com")
+		Expect(res.Header().Get("Strict-Transport-Security")).To(Equal("max-age=31536000; includeSubDomains"))
+		Expect(res.Header().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		Expect(res.Header().Values("X-Frame-Options")).To(ConsistOf("DENY"))
+	})
+
+	It("matches domains without regard to case or port", func() {
+		res := process("EXAMPLE.com:443")
+		Expect(res.Header().Get("X-Frame-Options")).To(Equal("DENY"))
+	})
+
+	It("does not match the parent domain of a wildcard", func() {
+		res := process("apps.example.com")
+		Expect(res.Header().Get("Strict-Transport-Security")).To(BeEmpty())
+		Expect(res.Header().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		Expect(res.Header().Get("X-Frame-Options")).To(Equal("ALLOW-FROM https://example.org"))
+	})
+
+	Context("when no policy matches", func() {
+		BeforeEach(func() {
+			cfg = cfg[:1]
+		})
+
+		It("leaves the response alone", func() {
+			res := process("example.org")
+			Expect(res.Header()).ToNot(HaveKey("Strict-Transport-Security"))
+			Expect(res.Header()).ToNot(HaveKey("X-Content-Type-Options"))
+			Expect(res.Header().Get("X-Frame-Options")).To(Equal("ALLOW-FROM https://example.org"))
+		})
+	})
+})
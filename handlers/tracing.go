@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/tracing"
+	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
+)
+
+type traceSpan struct {
+	tracer *tracing.Tracer
+	logger logger.Logger
+}
+
+// NewTracing creates a handler that records a server span for each request,
+// continuing the trace of the incoming traceparent header when there is one.
+func NewTracing(tracer *tracing.Tracer, logger logger.Logger) negroni.Handler {
+	return &traceSpan{
+		tracer: tracer,
+		logger: logger,
+	}
+}
+
+func (t *traceSpan) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	reqInfo, err := ContextRequestInfo(r)
+	if err != nil {
+		t.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	var parent *tracing.TraceContext
+	if ctx, ok := tracing.Extract(r.Header); ok {
+		parent = &ctx
+	}
+
+	span := t.tracer.StartSpan("HTTP "+r.Method, tracing.SpanKindServer, parent)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.host", r.Host)
+	span.SetAttribute("http.target", r.URL.RequestURI())
+	span.SetAttribute("gorouter.request_id", r.Header.Get(VcapRequestIdHeader))
+	reqInfo.TraceSpan = span
+
+	next(rw, r)
+
+	status := http.StatusOK
+	if reqInfo.ProxyResponseWriter != nil && reqInfo.ProxyResponseWriter.Status() != 0 {
+		status = reqInfo.ProxyResponseWriter.Status()
+	}
+	span.SetAttribute("http.status_code", status)
+	if status >= http.StatusInternalServerError {
+		span.SetError(http.StatusText(status))
+	}
+	span.End()
+}
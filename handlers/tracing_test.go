@@ -0,0 +1,113 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/gorouter/tracing"
+	"code.cloudfoundry.org/gorouter/tracing/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		exporter  *fakes.FakeExporter
+		process   ifrit.Process
+		handler   *negroni.Negroni
+		resp      *httptest.ResponseRecorder
+		req       *http.Request
+		status    int
+		traceSpan *tracing.Span
+	)
+
+	exportedSpans := func() []*tracing.Span {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		Expect(exporter.ExportCallCount()).To(Equal(1))
+		return exporter.ExportArgsForCall(0)
+	}
+
+	BeforeEach(func() {
+		logger := test_util.NewTestZapLogger("tracing")
+		exporter = new(fakes.FakeExporter)
+		tracer := tracing.NewTracer(exporter, logger)
+		process = ifrit.Invoke(tracer)
+
+		status = http.StatusOK
+		traceSpan = nil
+
+		handler = negroni.New()
+		handler.Use(handlers.NewRequestInfo())
+		handler.Use(handlers.NewProxyWriter(logger))
+		handler.Use(handlers.NewTracing(tracer, logger))
+		handler.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			reqInfo, err := handlers.ContextRequestInfo(r)
+			Expect(err).ToNot(HaveOccurred())
+			traceSpan = reqInfo.TraceSpan
+			rw.WriteHeader(status)
+		})
+
+		resp = httptest.NewRecorder()
+		req = test_util.NewRequest("GET", "example.com", "/foo?bar=baz", nil)
+		req.Header.Set(handlers.VcapRequestIdHeader, "some-request-id")
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("records a server span for the request", func() {
+		handler.ServeHTTP(resp, req)
+
+		Expect(traceSpan).NotTo(BeNil())
+		spans := exportedSpans()
+		Expect(spans).To(Equal([]*tracing.Span{traceSpan}))
+
+		span := spans[0]
+		Expect(span.Name()).To(Equal("HTTP GET"))
+		Expect(span.Kind()).To(Equal(tracing.SpanKindServer))
+		Expect(span.ParentSpanID()).To(BeEmpty())
+		Expect(span.Attribute("http.method")).To(Equal("GET"))
+		Expect(span.Attribute("http.host")).To(Equal("example.com"))
+		Expect(span.Attribute("http.target")).To(Equal("/foo?bar=baz"))
+		Expect(span.Attribute("gorouter.request_id")).To(Equal("some-request-id"))
+		Expect(span.Attribute("http.status_code")).To(Equal(http.StatusOK))
+		Expect(span.Failed()).To(BeFalse())
+	})
+
+	It("continues the trace of the traceparent header", func() {
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(resp, req)
+
+		span := exportedSpans()[0]
+		Expect(span.Context().TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(span.ParentSpanID()).To(Equal("00f067aa0ba902b7"))
+	})
+
+	It("does not export the span when the caller did not sample the trace", func() {
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		handler.ServeHTTP(resp, req)
+
+		Expect(traceSpan).NotTo(BeNil())
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		Expect(exporter.ExportCallCount()).To(Equal(0))
+	})
+
+	It("marks the span as failed when the response is a server error", func() {
+		status = http.StatusBadGateway
+		handler.ServeHTTP(resp, req)
+
+		span := exportedSpans()[0]
+		Expect(span.Attribute("http.status_code")).To(Equal(http.StatusBadGateway))
+		Expect(span.Failed()).To(BeTrue())
+	})
+})
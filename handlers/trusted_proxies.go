@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/urfave/negroni"
+)
+
+// TrustedProxies are the load balancers in front of gorouter that may report
+// the client of a request, in X-Forwarded-For, or in Forwarded when
+// ForwardedHeader is "forwarded".
+type TrustedProxies struct {
+	Nets            []*net.IPNet
+	ForwardedHeader string
+}
+
+// ClientIP returns the IP of the client that sent r. When the peer is a
+// trusted proxy, that is the last address in X-Forwarded-For, or in
+// Forwarded if the proxies report clients in it, that is not a trusted proxy
+// itself, since earlier addresses may have been made up by the client. It
+// returns nil if the header is malformed.
+func (t TrustedProxies) ClientIP(r *http.Request) net.IP {
+	ip := peerIP(r)
+	if ip == nil || !trusted(ip, t.Nets) {
+		return ip
+	}
+
+	if t.ForwardedHeader == config.ForwardedHeaderForwarded {
+		elements, err := parseForwarded(r.Header.Values("Forwarded"))
+		if err != nil {
+			return nil
+		}
+		if len(elements) == 0 {
+			return ip
+		}
+		return clientForwardedElement(elements, t.Nets).ip()
+	}
+
+	values := r.Header.Values("X-Forwarded-For")
+	if len(values) == 0 {
+		return ip
+	}
+	forwarded := strings.Split(strings.Join(values, ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			return nil
+		}
+		if !trusted(ip, t.Nets) {
+			return ip
+		}
+	}
+	return ip
+}
+
+type untrustedPeer struct {
+	proxies TrustedProxies
+	skip    func(req *http.Request) bool
+}
+
+// NewUntrustedPeer creates a handler that removes the X-Forwarded-For,
+// X-Forwarded-Proto and Forwarded headers of requests from peers that are not
+// trusted proxies, so that clients cannot make up their address or scheme
+// for the handlers, access logs and backends after it. It keeps the headers
+// of all requests when no proxies are trusted, and of requests for which
+// skip returns true, such as those coming back from a route service.
+func NewUntrustedPeer(proxies TrustedProxies, skip func(req *http.Request) bool) negroni.Handler {
+	return &untrustedPeer{
+		proxies: proxies,
+		skip:    skip,
+	}
+}
+
+func (u *untrustedPeer) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if len(u.proxies.Nets) > 0 && !u.skip(r) {
+		ip := peerIP(r)
+		if ip == nil || !trusted(ip, u.proxies.Nets) {
+			r.Header.Del("X-Forwarded-For")
+			r.Header.Del("X-Forwarded-Proto")
+			r.Header.Del("Forwarded")
+		}
+	}
+
+	next(rw, r)
+}
+
+func trusted(ip net.IP, nets []*net.IPNet) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func peerIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,76 @@
+package handlers_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/negroni"
+)
+
+var _ = Describe("UntrustedPeer", func() {
+	var (
+		proxies handlers.TrustedProxies
+		skip    func(req *http.Request) bool
+		req     *http.Request
+	)
+
+	process := func() http.Header {
+		var headers http.Header
+		n := negroni.New()
+		n.Use(handlers.NewUntrustedPeer(proxies, skip))
+		n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) { headers = r.Header })
+		n.ServeHTTP(httptest.NewRecorder(), req)
+		return headers
+	}
+
+	BeforeEach(func() {
+		_, trustedNet, err := net.ParseCIDR("10.0.0.0/8")
+		Expect(err).ToNot(HaveOccurred())
+		proxies = handlers.TrustedProxies{Nets: []*net.IPNet{trustedNet}}
+		skip = func(*http.Request) bool { return false }
+
+		req = test_util.NewRequest("GET", "example.com", "/", nil)
+		req.Header.Set("X-Forwarded-For", "192.0.2.60")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("Forwarded", "for=192.0.2.60;proto=https")
+	})
+
+	It("keeps the headers of trusted proxies", func() {
+		req.RemoteAddr = "10.0.0.5:43210"
+
+		headers := process()
+		Expect(headers.Get("X-Forwarded-For")).To(Equal("192.0.2.60"))
+		Expect(headers.Get("X-Forwarded-Proto")).To(Equal("https"))
+		Expect(headers.Get("Forwarded")).To(Equal("for=192.0.2.60;proto=https"))
+	})
+
+	It("removes the headers of other peers", func() {
+		req.RemoteAddr = "192.168.0.5:43210"
+
+		headers := process()
+		Expect(headers).ToNot(HaveKey("X-Forwarded-For"))
+		Expect(headers).ToNot(HaveKey("X-Forwarded-Proto"))
+		Expect(headers).ToNot(HaveKey("Forwarded"))
+	})
+
+	It("does not remove the headers of requests that skip it", func() {
+		skip = func(*http.Request) bool { return true }
+		req.RemoteAddr = "192.168.0.5:43210"
+
+		headers := process()
+		Expect(headers.Get("X-Forwarded-For")).To(Equal("192.0.2.60"))
+	})
+
+	It("keeps the headers of all peers when no proxies are trusted", func() {
+		proxies = handlers.TrustedProxies{}
+		req.RemoteAddr = "192.168.0.5:43210"
+
+		headers := process()
+		Expect(headers.Get("X-Forwarded-For")).To(Equal("192.0.2.60"))
+	})
+})
@@ -1,16 +1,24 @@
 package handlers
 
 import (
+	"net"
 	"net/http"
+	"strings"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
 	"github.com/uber-go/zap"
 )
 
+// XForwardedProto sets the X-Forwarded-Proto header of requests to the
+// scheme they were received with, or to the scheme that a terminating proxy
+// in TrustedProxyNets reported.
 type XForwardedProto struct {
 	SkipSanitization         func(req *http.Request) (bool, error)
 	ForceForwardedProtoHttps bool
 	SanitizeForwardedProto   bool
+	TerminatingProxy         config.TerminatingProxyConfig
+	TrustedProxyNets         []*net.IPNet
 	Logger                   logger.Logger
 }
 
@@ -31,6 +39,11 @@ func (h *XForwardedProto) ServeHTTP(rw http.ResponseWriter, r *http.Request, nex
 	if !skip {
 		if h.ForceForwardedProtoHttps {
 			newReq.Header.Set("X-Forwarded-Proto", "https")
+		} else if scheme, ok := h.upstreamScheme(newReq); ok {
+			newReq.Header.Set("X-Forwarded-Proto", scheme)
+			if reqInfo, err := ContextRequestInfo(newReq); err == nil {
+				reqInfo.UpstreamTLS = scheme == "https"
+			}
 		} else if h.SanitizeForwardedProto || newReq.Header.Get("X-Forwarded-Proto") == "" {
 			scheme := "http"
 			if newReq.TLS != nil {
@@ -42,3 +55,36 @@ func (h *XForwardedProto) ServeHTTP(rw http.ResponseWriter, r *http.Request, nex
 
 	next(rw, newReq)
 }
+
+// upstreamScheme returns the scheme reported by a trusted terminating proxy
+// for a plaintext request, in its scheme header or in Forwarded. It returns false when the request did not come
+// from a trusted peer or the reported scheme is not recognized.
+func (h *XForwardedProto) upstreamScheme(r *http.Request) (string, bool) {
+	if !h.TerminatingProxy.Enabled || r.TLS != nil {
+		return "", false
+	}
+
+	ip := peerIP(r)
+	if ip == nil || !trusted(ip, h.TrustedProxyNets) {
+		return "", false
+	}
+
+	var value string
+	if h.TerminatingProxy.ForwardedHeader == config.ForwardedHeaderForwarded {
+		elements, err := parseForwarded(r.Header.Values("Forwarded"))
+		if err != nil || len(elements) == 0 {
+			return "", false
+		}
+		value = clientForwardedElement(elements, h.TrustedProxyNets).Proto
+	} else {
+		value = r.Header.Get(h.TerminatingProxy.SchemeHeader)
+		if i := strings.Index(value, ","); i >= 0 {
+			value = value[:i]
+		}
+	}
+	scheme := strings.ToLower(strings.TrimSpace(value))
+	if scheme != "http" && scheme != "https" {
+		return "", false
+	}
+	return scheme, true
+}
@@ -3,15 +3,18 @@ package handlers_test
 import (
 	"crypto/tls"
 	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	logger_fakes "code.cloudfoundry.org/gorouter/logger/fakes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/uber-go/zap"
+	"github.com/urfave/negroni"
 )
 
 var _ = Describe("X-Forwarded-Proto", func() {
@@ -147,6 +150,98 @@ var _ = Describe("X-Forwarded-Proto", func() {
 		})
 	})
 
+	Context("when behind a trusted terminating proxy", func() {
+		var handler *handlers.XForwardedProto
+		BeforeEach(func() {
+			_, trustedNet, err := net.ParseCIDR("10.0.0.0/8")
+			Expect(err).ToNot(HaveOccurred())
+
+			handler = &handlers.XForwardedProto{
+				SkipSanitization:         func(req *http.Request) (bool, error) { return false, nil },
+				ForceForwardedProtoHttps: false,
+				SanitizeForwardedProto:   true,
+				TerminatingProxy: config.TerminatingProxyConfig{
+					Enabled:      true,
+					SchemeHeader: "X-Forwarded-Proto",
+				},
+				TrustedProxyNets: []*net.IPNet{trustedNet},
+				Logger:           logger,
+			}
+			req.RemoteAddr = "10.0.0.5:43210"
+		})
+
+		It("treats the request as https when the proxy reports https", func() {
+			req.Header.Set("X-Forwarded-Proto", "https")
+
+			var reqInfo *handlers.RequestInfo
+			n := negroni.New()
+			n.Use(handlers.NewRequestInfo())
+			n.Use(handler)
+			n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+				var err error
+				reqInfo, err = handlers.ContextRequestInfo(r)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(r.Header.Get("X-Forwarded-Proto")).To(Equal("https"))
+			})
+			n.ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(nextCalled).To(BeTrue())
+			Expect(reqInfo.UpstreamTLS).To(BeTrue())
+		})
+
+		It("uses the first value of a comma-separated header", func() {
+			req.Header.Set("X-Forwarded-Proto", "HTTPS, http")
+			Expect(processAndGetUpdatedHeader(handler)).To(Equal("https"))
+		})
+
+		It("reads the scheme from the configured header", func() {
+			handler.TerminatingProxy.SchemeHeader = "X-Forwarded-Scheme"
+			req.Header.Set("X-Forwarded-Scheme", "https")
+			req.Header.Set("X-Forwarded-Proto", "http")
+			Expect(processAndGetUpdatedHeader(handler)).To(Equal("https"))
+		})
+
+		It("sanitizes the header when the peer is not trusted", func() {
+			req.RemoteAddr = "192.168.0.5:43210"
+			req.Header.Set("X-Forwarded-Proto", "https")
+			Expect(processAndGetUpdatedHeader(handler)).To(Equal("http"))
+		})
+
+		It("sanitizes unrecognized schemes", func() {
+			req.Header.Set("X-Forwarded-Proto", "gopher")
+			Expect(processAndGetUpdatedHeader(handler)).To(Equal("http"))
+		})
+
+		It("ignores the proxy when the terminating proxy is disabled", func() {
+			handler.TerminatingProxy.Enabled = false
+			req.Header.Set("X-Forwarded-Proto", "https")
+			Expect(processAndGetUpdatedHeader(handler)).To(Equal("http"))
+		})
+
+		Context("when the proxy reports the scheme in Forwarded", func() {
+			BeforeEach(func() {
+				handler.TerminatingProxy.ForwardedHeader = config.ForwardedHeaderForwarded
+				req.Header.Set("X-Forwarded-Proto", "http")
+			})
+
+			It("uses the scheme of the element for the client", func() {
+				req.Header.Set("Forwarded", "for=192.0.2.60;proto=https;by=10.0.0.1, for=10.0.0.2;proto=http")
+				Expect(processAndGetUpdatedHeader(handler)).To(Equal("https"))
+			})
+
+			It("sanitizes the header when Forwarded is missing", func() {
+				req.Header.Set("X-Forwarded-Proto", "https")
+				Expect(processAndGetUpdatedHeader(handler)).To(Equal("http"))
+			})
+
+			It("sanitizes the header when Forwarded is malformed", func() {
+				req.Header.Set("Forwarded", "for=192.0.2.60;proto")
+				Expect(processAndGetUpdatedHeader(handler)).To(Equal("http"))
+			})
+		})
+	})
+
 	Context("When SkipSanitization returns an error", func() {
 		var handler *handlers.XForwardedProto
 		BeforeEach(func() {
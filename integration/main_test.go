@@ -1107,6 +1107,23 @@ var _ = Describe("Router Integration", func() {
 					Eventually(session, 30*time.Second).Should(Say("unable-to-fetch-token"))
 					Eventually(session, 5*time.Second).Should(Exit(1))
 				})
+
+				Context("when the failure mode is fail_open", func() {
+					BeforeEach(func() {
+						cfg.OAuth.FailureMode = config.OAuthFailureModeFailOpen
+					})
+
+					It("does not exit", func() {
+						writeConfig(cfg, cfgFile)
+
+						gorouterCmd := exec.Command(gorouterPath, "-c", cfgFile)
+						session, err := Start(gorouterCmd, GinkgoWriter, GinkgoWriter)
+						Expect(err).ToNot(HaveOccurred())
+						defer session.Kill()
+						Eventually(session, 30*time.Second).Should(Say("unable-to-fetch-token"))
+						Consistently(session, 5*time.Second).ShouldNot(Exit())
+					})
+				})
 			})
 
 			Context("when routing api is not available", func() {
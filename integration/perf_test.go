@@ -40,7 +40,7 @@ var _ = Describe("AccessLogRecord", func() {
 		rss, err := router.NewRouteServicesServer()
 		Expect(err).ToNot(HaveOccurred())
 		proxy.NewProxy(logger, accesslog, c, r, combinedReporter, &routeservice.RouteServiceConfig{},
-			&tls.Config{}, nil, rss.GetRoundTripper(), rss.ArrivedViaARouteServicesServer)
+			&tls.Config{}, nil, nil, rss.GetRoundTripper(), rss.ArrivedViaARouteServicesServer, nil, nil)
 
 		b.Time("RegisterTime", func() {
 			for i := 0; i < 1000; i++ {
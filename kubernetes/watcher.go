@@ -0,0 +1,386 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+)
+
+// serviceNameLabel is set on EndpointSlices to the name of their Service.
+const serviceNameLabel = "kubernetes.io/service-name"
+
+// Watcher registers the ready endpoints of the Services of a Kubernetes
+// cluster that are annotated with routes. It lists and then watches Services
+// and EndpointSlices, and registers the endpoints again every quarter of the
+// droplet stale threshold, so that they are not pruned.
+type Watcher struct {
+	RouteRegistry registry.Registry
+
+	cfg             config.KubernetesConfig
+	apiServer       string
+	refreshInterval time.Duration
+	client          *http.Client
+	logger          logger.Logger
+
+	lock       sync.Mutex
+	services   map[string]object
+	slices     map[string]object
+	changed    chan struct{}
+	registered map[string]registration
+}
+
+type registration struct {
+	uri      route.Uri
+	endpoint *route.Endpoint
+}
+
+// object holds the fields of Services and EndpointSlices that the watcher
+// uses.
+type object struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		ResourceVersion string            `json:"resourceVersion"`
+		Labels          map[string]string `json:"labels"`
+		Annotations     map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	AddressType string `json:"addressType"`
+	Endpoints   []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+		TargetRef *struct {
+			Name string `json:"name"`
+		} `json:"targetRef"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name string `json:"name"`
+		Port *int32 `json:"port"`
+	} `json:"ports"`
+}
+
+func (o object) key() string {
+	return o.Metadata.Namespace + "/" + o.Metadata.Name
+}
+
+// port returns the port of an EndpointSlice with the name or number, or its
+// first port if name is empty.
+func (o object) port(name string) (uint16, bool) {
+	for _, p := range o.Ports {
+		if p.Port == nil {
+			continue
+		}
+		if name == "" || name == p.Name || name == strconv.Itoa(int(*p.Port)) {
+			return uint16(*p.Port), true
+		}
+	}
+	return 0, false
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+func NewWatcher(routeRegistry registry.Registry, c *config.Config, l logger.Logger) (*Watcher, error) {
+	cfg := c.Kubernetes
+
+	apiServer := cfg.APIServer
+	if apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("kubernetes api_server is not set and gorouter is not running in a pod")
+		}
+		apiServer = "https://" + net.JoinHostPort(host, port)
+	}
+
+	tlsConfig := &tls.Config{}
+	if strings.HasPrefix(apiServer, "https:") && cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+		}
+	}
+
+	return &Watcher{
+		RouteRegistry:   routeRegistry,
+		cfg:             cfg,
+		apiServer:       strings.TrimSuffix(apiServer, "/"),
+		refreshInterval: c.DropletStaleThreshold / 4,
+		client:          &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+		logger:          l,
+		services:        map[string]object{},
+		slices:          map[string]object{},
+		changed:         make(chan struct{}, 1),
+		registered:      map[string]registration{},
+	}, nil
+}
+
+func (w *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		w.watch(ctx, w.path("/api/v1", "services"), w.services, func(o object) bool {
+			return o.Metadata.Annotations[w.cfg.RouteAnnotation] != ""
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		w.watch(ctx, w.path("/apis/discovery.k8s.io/v1", "endpointslices"), w.slices, func(o object) bool {
+			return o.Metadata.Labels[serviceNameLabel] != ""
+		})
+	}()
+
+	close(ready)
+	w.logger.Info("kubernetes-watcher-started", zap.String("api-server", w.apiServer))
+
+	ticker := time.NewTicker(w.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.changed:
+			w.Sync()
+		case <-ticker.C:
+			w.Sync()
+		case <-signals:
+			cancel()
+			wg.Wait()
+			w.logger.Info("exited")
+			return nil
+		}
+	}
+}
+
+func (w *Watcher) path(group, resource string) string {
+	if w.cfg.Namespace != "" {
+		return group + "/namespaces/" + url.PathEscape(w.cfg.Namespace) + "/" + resource
+	}
+	return group + "/" + resource
+}
+
+// watch keeps the objects of path that keep reports true for in cache, until
+// ctx is done.
+func (w *Watcher) watch(ctx context.Context, path string, cache map[string]object, keep func(object) bool) {
+	for {
+		err := w.listAndWatch(ctx, path, cache, keep)
+		if ctx.Err() != nil {
+			return
+		}
+		w.logger.Error("kubernetes-watch-failed", zap.String("path", path), zap.Error(err))
+		select {
+		case <-time.After(w.cfg.RetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// listAndWatch replaces the objects in cache with those listed, and then
+// applies the changes to them from watches until a watch fails. The last
+// resource version is kept across watches, which the API server ends
+// periodically.
+func (w *Watcher) listAndWatch(ctx context.Context, path string, cache map[string]object, keep func(object) bool) error {
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []object `json:"items"`
+	}
+	resp, err := w.get(ctx, path, url.Values{})
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("GET %s: %s", path, err)
+	}
+
+	w.lock.Lock()
+	for key := range cache {
+		delete(cache, key)
+	}
+	for _, o := range list.Items {
+		if keep(o) {
+			cache[o.key()] = o
+		}
+	}
+	w.lock.Unlock()
+	w.notify()
+
+	resourceVersion := list.Metadata.ResourceVersion
+	for {
+		query := url.Values{}
+		query.Set("watch", "true")
+		query.Set("resourceVersion", resourceVersion)
+		query.Set("allowWatchBookmarks", "true")
+		query.Set("timeoutSeconds", "300")
+		resp, err := w.get(ctx, path, query)
+		if err != nil {
+			return err
+		}
+		resourceVersion, err = w.applyEvents(resp, cache, keep, resourceVersion)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("watch %s: %s", path, err)
+		}
+	}
+}
+
+// applyEvents applies the events of a watch to cache until it ends, and
+// returns the last resource version seen.
+func (w *Watcher) applyEvents(resp *http.Response, cache map[string]object, keep func(object) bool, resourceVersion string) (string, error) {
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				return resourceVersion, nil
+			}
+			return resourceVersion, err
+		}
+		if event.Type == "ERROR" {
+			// most often 410 Gone, when the resource version is too old
+			return resourceVersion, errors.New(string(bytes.TrimSpace(event.Object)))
+		}
+
+		var o object
+		if err := json.Unmarshal(event.Object, &o); err != nil {
+			return resourceVersion, err
+		}
+		resourceVersion = o.Metadata.ResourceVersion
+		if event.Type == "BOOKMARK" {
+			continue
+		}
+
+		w.lock.Lock()
+		if event.Type == "DELETED" || !keep(o) {
+			delete(cache, o.key())
+		} else {
+			cache[o.key()] = o
+		}
+		w.lock.Unlock()
+		w.notify()
+	}
+}
+
+func (w *Watcher) notify() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.apiServer+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// service account tokens are rotated, so the file is read every time
+	if token, err := os.ReadFile(w.cfg.TokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return resp, nil
+}
+
+// Sync registers the ready endpoints of the annotated Services, and
+// unregisters the endpoints it registered before that are no longer among
+// them.
+func (w *Watcher) Sync() {
+	current := map[string]registration{}
+
+	w.lock.Lock()
+	for _, slice := range w.slices {
+		service, ok := w.services[slice.Metadata.Namespace+"/"+slice.Metadata.Labels[serviceNameLabel]]
+		if !ok || slice.AddressType == "FQDN" {
+			continue
+		}
+		port, ok := slice.port(service.Metadata.Annotations[w.cfg.PortAnnotation])
+		if !ok {
+			continue
+		}
+		uris := routes(service.Metadata.Annotations[w.cfg.RouteAnnotation])
+
+		for _, e := range slice.Endpoints {
+			if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+				continue
+			}
+			var instanceID string
+			if e.TargetRef != nil {
+				instanceID = e.TargetRef.Name
+			}
+			for _, addr := range e.Addresses {
+				endpoint := route.NewEndpoint(&route.EndpointOpts{
+					Host:              addr,
+					Port:              port,
+					PrivateInstanceId: instanceID,
+					Tags:              map[string]string{"kubernetes_service": service.key()},
+				})
+				for _, uri := range uris {
+					current[uri.RouteKey().String()+" "+endpoint.CanonicalAddr()] = registration{uri: uri, endpoint: endpoint}
+				}
+			}
+		}
+	}
+	w.lock.Unlock()
+
+	for _, r := range current {
+		w.RouteRegistry.Register(r.uri, r.endpoint)
+	}
+	unregistered := 0
+	for key, r := range w.registered {
+		if _, ok := current[key]; !ok {
+			w.RouteRegistry.Unregister(r.uri, r.endpoint)
+			unregistered++
+		}
+	}
+	w.registered = current
+
+	w.logger.Debug("kubernetes-synced", zap.Int("registered", len(current)), zap.Int("unregistered", unregistered))
+}
+
+// routes parses a comma-separated list of routes.
+func routes(annotation string) []route.Uri {
+	var uris []route.Uri
+	for _, r := range strings.Split(annotation, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			uris = append(uris, route.Uri(r))
+		}
+	}
+	return uris
+}
@@ -0,0 +1,162 @@
+package kubernetes_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/kubernetes"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	servicesPath       = "/api/v1/services"
+	endpointSlicesPath = "/apis/discovery.k8s.io/v1/endpointslices"
+
+	webService = `{"metadata": {"name": "web", "namespace": "default", "resourceVersion": "1", "annotations": {"gorouter.cloudfoundry.org/routes": "web.example.com, www.example.com/web", "gorouter.cloudfoundry.org/port": "http"}}}`
+	dbService  = `{"metadata": {"name": "db", "namespace": "default", "resourceVersion": "1"}}`
+
+	webSlice = `{"metadata": {"name": "web-abc", "namespace": "default", "resourceVersion": "1", "labels": {"kubernetes.io/service-name": "web"}},
+		"addressType": "IPv4",
+		"ports": [{"name": "metrics", "port": 9090}, {"name": "http", "port": 8080}],
+		"endpoints": [
+			{"addresses": ["10.0.0.1"], "conditions": {"ready": true}, "targetRef": {"name": "web-0"}},
+			{"addresses": ["10.0.0.2"], "conditions": {"ready": false}, "targetRef": {"name": "web-1"}},
+			{"addresses": ["10.0.0.3"], "targetRef": {"name": "web-2"}}
+		]}`
+	dbSlice = `{"metadata": {"name": "db-abc", "namespace": "default", "resourceVersion": "1", "labels": {"kubernetes.io/service-name": "db"}},
+		"addressType": "IPv4",
+		"ports": [{"port": 5432}],
+		"endpoints": [{"addresses": ["10.0.1.1"]}]}`
+)
+
+// fakeAPIServer lists objects, and streams the events sent to it to watches.
+type fakeAPIServer struct {
+	sync.Mutex
+	lists          map[string]string
+	events         map[string]chan string
+	authorizations []string
+}
+
+func (f *fakeAPIServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	f.authorizations = append(f.authorizations, r.Header.Get("Authorization"))
+	list, events := f.lists[r.URL.Path], f.events[r.URL.Path]
+	f.Unlock()
+
+	if r.URL.Query().Get("watch") != "true" {
+		fmt.Fprint(rw, list)
+		return
+	}
+
+	rw.(http.Flusher).Flush()
+	for {
+		select {
+		case event := <-events:
+			fmt.Fprintln(rw, event)
+			rw.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+var _ = Describe("Watcher", func() {
+	var (
+		fake    *fakeAPIServer
+		server  *httptest.Server
+		cfg     *config.Config
+		r       *registry.RouteRegistry
+		process ifrit.Process
+	)
+
+	addresses := func(uri string) []string {
+		pool := r.Lookup(route.Uri(uri))
+		if pool == nil {
+			return nil
+		}
+		var addrs []string
+		pool.Each(func(e *route.Endpoint) {
+			addrs = append(addrs, e.CanonicalAddr())
+		})
+		sort.Strings(addrs)
+		return addrs
+	}
+
+	BeforeEach(func() {
+		fake = &fakeAPIServer{
+			lists: map[string]string{
+				servicesPath:       `{"metadata": {"resourceVersion": "1"}, "items": [` + webService + `, ` + dbService + `]}`,
+				endpointSlicesPath: `{"metadata": {"resourceVersion": "1"}, "items": [` + webSlice + `, ` + dbSlice + `]}`,
+			},
+			events: map[string]chan string{
+				servicesPath:       make(chan string, 10),
+				endpointSlicesPath: make(chan string, 10),
+			},
+		}
+		server = httptest.NewServer(fake)
+
+		tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("some-token\n"), 0600)).To(Succeed())
+
+		var err error
+		cfg, err = config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+		cfg.Kubernetes.Enabled = true
+		cfg.Kubernetes.APIServer = server.URL
+		cfg.Kubernetes.TokenFile = tokenFile
+
+		l := test_util.NewTestZapLogger("kubernetes-test")
+		r = registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+
+		watcher, err := kubernetes.NewWatcher(r, cfg, l)
+		Expect(err).ToNot(HaveOccurred())
+		process = ifrit.Invoke(watcher)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		server.Close()
+	})
+
+	It("registers the ready endpoints of annotated services under their routes", func() {
+		Eventually(func() []string { return addresses("web.example.com") }).Should(Equal([]string{"10.0.0.1:8080", "10.0.0.3:8080"}))
+		Eventually(func() []string { return addresses("www.example.com/web") }).Should(Equal([]string{"10.0.0.1:8080", "10.0.0.3:8080"}))
+		Expect(r.NumEndpoints()).To(Equal(2))
+	})
+
+	It("applies the changes from watches", func() {
+		Eventually(func() []string { return addresses("web.example.com") }).Should(HaveLen(2))
+
+		fake.events[endpointSlicesPath] <- `{"type": "MODIFIED", "object": {"metadata": {"name": "web-abc", "namespace": "default", "resourceVersion": "2", "labels": {"kubernetes.io/service-name": "web"}},
+			"addressType": "IPv4", "ports": [{"name": "http", "port": 8080}], "endpoints": [{"addresses": ["10.0.0.1"]}]}}`
+		Eventually(func() []string { return addresses("web.example.com") }).Should(Equal([]string{"10.0.0.1:8080"}))
+
+		fake.events[servicesPath] <- `{"type": "DELETED", "object": ` + webService + `}`
+		Eventually(func() []string { return addresses("web.example.com") }).Should(BeEmpty())
+		Eventually(func() []string { return addresses("www.example.com/web") }).Should(BeEmpty())
+	})
+
+	It("authenticates with the token file", func() {
+		Eventually(func() []string { return addresses("web.example.com") }).Should(HaveLen(2))
+
+		fake.Lock()
+		defer fake.Unlock()
+		for _, authorization := range fake.authorizations {
+			Expect(authorization).To(Equal("Bearer some-token"))
+		}
+	})
+})
@@ -101,6 +101,11 @@ type FakeLogger struct {
 	sessionNameReturnsOnCall map[int]struct {
 		result1 string
 	}
+	SetLevelStub        func(zap.Level)
+	setLevelMutex       sync.RWMutex
+	setLevelArgsForCall []struct {
+		arg1 zap.Level
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -491,6 +496,30 @@ func (fake *FakeLogger) SessionNameReturnsOnCall(i int, result1 string) {
 	}{result1}
 }
 
+func (fake *FakeLogger) SetLevel(arg1 zap.Level) {
+	fake.setLevelMutex.Lock()
+	fake.setLevelArgsForCall = append(fake.setLevelArgsForCall, struct {
+		arg1 zap.Level
+	}{arg1})
+	fake.recordInvocation("SetLevel", []interface{}{arg1})
+	fake.setLevelMutex.Unlock()
+	if fake.SetLevelStub != nil {
+		fake.SetLevelStub(arg1)
+	}
+}
+
+func (fake *FakeLogger) SetLevelCallCount() int {
+	fake.setLevelMutex.RLock()
+	defer fake.setLevelMutex.RUnlock()
+	return len(fake.setLevelArgsForCall)
+}
+
+func (fake *FakeLogger) SetLevelArgsForCall(i int) zap.Level {
+	fake.setLevelMutex.RLock()
+	defer fake.setLevelMutex.RUnlock()
+	return fake.setLevelArgsForCall[i].arg1
+}
+
 func (fake *FakeLogger) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -518,6 +547,8 @@ func (fake *FakeLogger) Invocations() map[string][][]interface{} {
 	defer fake.sessionMutex.RUnlock()
 	fake.sessionNameMutex.RLock()
 	defer fake.sessionNameMutex.RUnlock()
+	fake.setLevelMutex.RLock()
+	defer fake.setLevelMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/uber-go/zap"
+)
+
+// maxLevelBytes is more than the longest level name.
+const maxLevelBytes = 16
+
+// LevelHandler serves PUT requests that set the level of l, and the sessions
+// it shares its level with, to the level named in the request body, such as
+// "debug" or "info".
+func LevelHandler(l Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.Header().Set("Allow", http.MethodPut)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxLevelBytes))
+		if err != nil {
+			http.Error(w, "log level too long", http.StatusBadRequest)
+			return
+		}
+
+		var level zap.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(string(body)))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		l.SetLevel(level)
+		l.Info("log-level-changed", zap.String("level", level.String()))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,65 @@
+package logger_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/uber-go/zap"
+)
+
+var _ = Describe("LevelHandler", func() {
+	var (
+		logger   Logger
+		testSink *test_util.TestZapSink
+		handler  http.Handler
+	)
+
+	setLevel := func(method, level string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(method, "/log-level", strings.NewReader(level)))
+		return res
+	}
+
+	BeforeEach(func() {
+		testSink = &test_util.TestZapSink{Buffer: gbytes.NewBuffer()}
+		logger = NewLogger("my-component", zap.InfoLevel, zap.Output(testSink))
+		handler = LevelHandler(logger)
+	})
+
+	It("sets the level of the logger and its sessions", func() {
+		session := logger.Session("my-subcomponent")
+		session.Debug("before")
+
+		Expect(setLevel("PUT", "debug\n").Code).To(Equal(http.StatusNoContent))
+		session.Debug("after")
+
+		Expect(testSink.Lines()).To(HaveLen(2))
+		Expect(testSink.Lines()[0]).To(ContainSubstring(`"message":"log-level-changed"`))
+		Expect(testSink.Lines()[1]).To(ContainSubstring(`"message":"after"`))
+
+		Expect(setLevel("PUT", "info").Code).To(Equal(http.StatusNoContent))
+		session.Debug("ignored")
+		Expect(testSink.Lines()).To(HaveLen(3))
+	})
+
+	It("rejects unknown levels", func() {
+		res := setLevel("PUT", "verbose")
+		Expect(res.Code).To(Equal(http.StatusBadRequest))
+
+		logger.Debug("ignored")
+		Expect(testSink.Lines()).To(BeEmpty())
+	})
+
+	It("only allows PUT", func() {
+		res := setLevel("GET", "")
+		Expect(res.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(res.Header().Get("Allow")).To(Equal("PUT"))
+	})
+})
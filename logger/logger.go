@@ -17,6 +17,7 @@ type Logger interface {
 	Fatal(string, ...zap.Field)
 	Session(string) Logger
 	SessionName() string
+	SetLevel(zap.Level)
 }
 
 type logger struct {
@@ -58,6 +59,12 @@ func (l *logger) SessionName() string {
 	return l.source
 }
 
+// SetLevel sets the minimum level of the logger, its sessions, and the
+// logger it is a session of.
+func (l *logger) SetLevel(level zap.Level) {
+	l.origLogger.SetLevel(level)
+}
+
 func (l *logger) wrapDataFields(fields ...zap.Field) zap.Field {
 	finalFields := append(l.context, fields...)
 	return zap.Nest("data", finalFields...)
@@ -84,6 +84,21 @@ var _ = Describe("Logger", func() {
 		})
 	})
 
+	Describe("SetLevel", func() {
+		It("sets the level of the logger and its sessions", func() {
+			session := logger.Session("my-subcomponent")
+			session.SetLevel(zap.InfoLevel)
+
+			logger.Debug(action)
+			session.Debug(action)
+			Expect(testSink.Lines()).To(HaveLen(0))
+
+			logger.SetLevel(zap.DebugLevel)
+			session.Debug(action)
+			Expect(testSink.Lines()).To(HaveLen(1))
+		})
+	})
+
 	Describe("With", func() {
 		BeforeEach(func() {
 			logger = logger.With(testField)
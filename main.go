@@ -5,7 +5,9 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
 	"syscall"
 	"time"
@@ -13,18 +15,24 @@ import (
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/gorouter/accesslog"
+	"code.cloudfoundry.org/gorouter/common"
 	"code.cloudfoundry.org/gorouter/common/schema"
 	"code.cloudfoundry.org/gorouter/common/secure"
 	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/consul"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/kubernetes"
 	goRouterLogger "code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/mbus"
 	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/metrics/monitor"
 	"code.cloudfoundry.org/gorouter/proxy"
+	"code.cloudfoundry.org/gorouter/proxy/utils"
 	rregistry "code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route_fetcher"
 	"code.cloudfoundry.org/gorouter/router"
 	"code.cloudfoundry.org/gorouter/routeservice"
+	"code.cloudfoundry.org/gorouter/tracing"
 	rvarz "code.cloudfoundry.org/gorouter/varz"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/routing-api"
@@ -34,9 +42,11 @@ import (
 	"github.com/cloudfoundry/dropsonde/log_sender"
 	"github.com/cloudfoundry/dropsonde/metric_sender"
 	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	dropsondemetrics "github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/nats-io/go-nats"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
+	"github.com/tedsuo/ifrit/http_server"
 	"github.com/tedsuo/ifrit/sigmon"
 	"github.com/uber-go/zap"
 )
@@ -94,9 +104,17 @@ func main() {
 		debugserver.Run(c.DebugAddr, reconfigurableSink)
 	}
 
-	logger.Info("setting-up-nats-connection")
+	// without NATS servers, routes are only registered over HTTP, by the
+	// routing API or by a preloaded snapshot
+	var natsClient *nats.Conn
+	var natsHealth *mbus.ConnectionHealth
 	natsReconnected := make(chan mbus.Signal)
-	natsClient := mbus.Connect(c, natsReconnected, logger.Session("nats"))
+	if len(c.Nats) > 0 {
+		logger.Info("setting-up-nats-connection")
+		natsClient, natsHealth = mbus.Connect(c, natsReconnected, logger.Session("nats"))
+	} else {
+		logger.Info("nats-disabled")
+	}
 
 	var routingAPIClient routing_api.Client
 
@@ -111,12 +129,60 @@ func main() {
 	}
 
 	sender := metric_sender.NewMetricSender(dropsonde.AutowiredEmitter())
-	metricsReporter := initializeMetrics(sender)
-	fdMonitor := initializeFDMonitor(sender, logger)
+	var prometheusRegistry *metrics.PrometheusRegistry
+	if c.Prometheus.Enabled {
+		prometheusRegistry = metrics.NewPrometheusRegistry()
+	}
+	var statsd *metrics.StatsD
+	if c.StatsD.Enabled {
+		statsd, err = metrics.NewStatsD(c.StatsD)
+		if err != nil {
+			logger.Fatal("error-creating-statsd", zap.Error(err))
+		}
+	}
+	metricsReporter := initializeMetrics(sender, prometheusRegistry, statsd)
+	if c.PerRouteMetrics.Enabled {
+		metricsReporter.PerRoute = metrics.NewRouteSelector(c.PerRouteMetrics.TopN, c.PerRouteMetrics.MaxRoutes, c.PerRouteMetrics.Window, c.PerRouteMetricsAllowlist)
+	}
+	fdMonitor := initializeFDMonitor(metricsReporter.Sender, logger)
 	registry := rregistry.NewRouteRegistry(logger.Session("registry"), c, metricsReporter)
-	if c.SuspendPruningIfNatsUnavailable {
+	if c.SuspendPruningIfNatsUnavailable && natsClient != nil {
 		registry.SuspendPruning(func() bool { return !(natsClient.Status() == nats.CONNECTED) })
 	}
+	if len(c.PrunePolicies) > 0 {
+		var pruneSignal rregistry.PruneSignal
+		if natsClient != nil {
+			registry.TrackNatsConnection(func() bool { return natsClient.Status() == nats.CONNECTED })
+			for _, policy := range c.PrunePolicies {
+				if policy.Policy == config.PrunePolicyQuorum {
+					signal, err := mbus.NewPruneSignal(natsClient, c.DropletStaleThreshold, logger.Session("prune-signal"))
+					if err != nil {
+						logger.Fatal("prune-signal-error", zap.Error(err))
+					}
+					pruneSignal = signal
+					break
+				}
+			}
+		}
+		registry.SetPrunePolicy(rregistry.NewDomainPrunePolicy(c.PrunePolicies, pruneSignal))
+	}
+
+	if c.PreloadRoutesFile != "" {
+		preloaded, err := mbus.PreloadRoutes(c.PreloadRoutesFile, registry, logger.Session("preload"))
+		if err != nil {
+			logger.Error("failed-to-preload-routes", zap.Error(err))
+		} else {
+			logger.Info("preloaded-routes", zap.Int("count", preloaded))
+		}
+	}
+	if c.RouteSnapshot.Path != "" && c.RouteSnapshot.Path != c.PreloadRoutesFile {
+		loaded, err := mbus.LoadRouteSnapshot(c.RouteSnapshot.Path, c.RouteSnapshot.MaxAge, registry, logger.Session("route-snapshot"))
+		if err != nil {
+			logger.Error("failed-to-load-route-snapshot", zap.Error(err))
+		} else {
+			logger.Info("loaded-route-snapshot", zap.Int("count", loaded))
+		}
+	}
 
 	varz := rvarz.NewVarz(registry)
 	compositeReporter := &metrics.CompositeReporter{VarzReporter: varz, ProxyReporter: metricsReporter}
@@ -150,36 +216,126 @@ func main() {
 
 	backendTLSConfig := &tls.Config{
 		CipherSuites:       c.CipherSuites,
+		MinVersion:         c.Backends.MinTLSVersion,
+		MaxVersion:         c.Backends.MaxTLSVersion,
 		InsecureSkipVerify: c.SkipSSLValidation,
 		RootCAs:            c.CAPool,
-		Certificates:       []tls.Certificate{c.Backends.ClientAuthCertificate},
 	}
+	backendClientCerts := utils.NewClientCertificates(c.Backends.ClientAuthCertificate, c.Backends.NamedClientAuthCertificates)
 
 	rss, err := router.NewRouteServicesServer()
 	if err != nil {
 		logger.Fatal("new-route-services-server", zap.Error(err))
 	}
+	var tracer *tracing.Tracer
+	if c.Tracing.OTLP.Enabled {
+		tracer = tracing.NewTracer(
+			tracing.NewOTLPExporter(c.Tracing.OTLP.Endpoint, c.Tracing.OTLP.ServiceName),
+			logger.Session("tracer"),
+		)
+	}
+
+	var responseCache *handlers.ResponseCache
+	if c.ResponseCache.MaxSizeBytes > 0 {
+		responseCache, err = handlers.NewResponseCache(c.ResponseCache, logger.Session("response-cache"))
+		if err != nil {
+			logger.Fatal("initialize-response-cache-error", zap.Error(err))
+		}
+	}
+
 	healthCheck = 0
-	proxy := proxy.NewProxy(logger, accessLogger, c, registry, compositeReporter, routeServiceConfig, backendTLSConfig, &healthCheck, rss.GetRoundTripper(), rss.ArrivedViaARouteServicesServer)
-	goRouter, err := router.NewRouter(logger.Session("router"), c, proxy, natsClient, registry, varz, &healthCheck, logCounter, nil, rss)
+	proxyHandler := proxy.NewProxy(logger, accessLogger, c, registry, compositeReporter, routeServiceConfig, backendTLSConfig, backendClientCerts, &healthCheck, rss.GetRoundTripper(), rss.ArrivedViaARouteServicesServer, tracer, responseCache)
+	statusHandlers := map[string]http.Handler{}
+	if prometheusRegistry != nil && c.Prometheus.Port == 0 {
+		statusHandlers[c.Prometheus.Path] = prometheusRegistry
+	}
+	if responseCache != nil {
+		statusHandlers["/response_cache"] = responseCache.PurgeHandler()
+	}
+	if c.Status.EnableBulkRoutes {
+		statusHandlers["/routes/bulk"] = mbus.BulkRoutesHandler(registry, logger.Session("bulk-routes"))
+	}
+	if c.Status.EnableRouteRegistration {
+		statusHandlers["/routes/register"] = mbus.RegisterHandler(registry, logger.Session("route-registration"))
+		statusHandlers["/routes/unregister"] = mbus.UnregisterHandler(registry, logger.Session("route-registration"))
+	}
+	if c.Status.EnablePprof {
+		for path, handler := range common.PprofHandlers() {
+			statusHandlers[path] = handler
+		}
+	}
+	if c.Status.EnableLogLevel {
+		statusHandlers["/log-level"] = goRouterLogger.LevelHandler(logger)
+	}
+	goRouter, err := router.NewRouter(logger.Session("router"), c, proxyHandler, natsClient, registry, varz, &healthCheck, logCounter, nil, rss, statusHandlers)
 	if err != nil {
 		logger.Fatal("initialize-router-error", zap.Error(err))
 	}
 
 	members := grouper.Members{}
 
+	// the ordered group stops its members in reverse, so the tracer exports
+	// the spans of draining requests after the router has stopped
+	if tracer != nil {
+		members = append(members, grouper.Member{Name: "tracer", Runner: tracer})
+	}
+
 	if c.RoutingApiEnabled() {
 		routeFetcher := setupRouteFetcher(logger.Session("route-fetcher"), c, registry, routingAPIClient)
 		members = append(members, grouper.Member{Name: "router-fetcher", Runner: routeFetcher})
 	}
 
-	subscriber := mbus.NewSubscriber(natsClient, registry, c, natsReconnected, logger.Session("subscriber"))
-	natsMonitor := initializeNATSMonitor(subscriber, sender, logger)
-
 	members = append(members, grouper.Member{Name: "fdMonitor", Runner: fdMonitor})
-	members = append(members, grouper.Member{Name: "subscriber", Runner: subscriber})
-	members = append(members, grouper.Member{Name: "natsMonitor", Runner: natsMonitor})
+	if natsClient != nil {
+		subscriber := mbus.NewSubscriber(natsClient, registry, c, natsReconnected, metricsReporter, logger.Session("subscriber"))
+		natsMonitor := initializeNATSMonitor(subscriber, sender, logger)
+		if c.EmitNatsHealth {
+			natsMonitor.Connection = natsHealth
+		}
+
+		members = append(members, grouper.Member{Name: "subscriber", Runner: subscriber})
+		members = append(members, grouper.Member{Name: "natsMonitor", Runner: natsMonitor})
+
+		if len(c.NatsSecondary) > 0 {
+			natsFailover := mbus.NewFailover(natsClient, c, natsReconnected, metricsReporter, logger.Session("nats-failover"))
+			subscriber.FollowFailover(natsFailover.Clients())
+			members = append(members, grouper.Member{Name: "natsFailover", Runner: natsFailover})
+		}
+	}
+	if c.Status.RegistrationGRPCPort != 0 {
+		members = append(members, routeRegistrationServerMember(registry, c, logger))
+	}
+	if c.Consul.Address != "" {
+		consulWatcher := consul.NewWatcher(registry, c, logger.Session("consul-watcher"))
+		members = append(members, grouper.Member{Name: "consulWatcher", Runner: consulWatcher})
+	}
+	if c.Kubernetes.Enabled {
+		kubernetesWatcher, err := kubernetes.NewWatcher(registry, c, logger.Session("kubernetes-watcher"))
+		if err != nil {
+			logger.Fatal("failed-to-create-kubernetes-watcher", zap.Error(err))
+		}
+		members = append(members, grouper.Member{Name: "kubernetesWatcher", Runner: kubernetesWatcher})
+	}
+	if c.RouteSnapshot.Path != "" {
+		routeSnapshotter := mbus.NewRouteSnapshotter(registry, c.RouteSnapshot.Path, c.RouteSnapshot.Interval, logger.Session("route-snapshotter"))
+		members = append(members, grouper.Member{Name: "routeSnapshotter", Runner: routeSnapshotter})
+	}
+	if prometheusRegistry != nil && c.Prometheus.Port != 0 {
+		mux := http.NewServeMux()
+		mux.Handle(c.Prometheus.Path, prometheusRegistry)
+		members = append(members, grouper.Member{Name: "prometheus", Runner: http_server.New(fmt.Sprintf(":%d", c.Prometheus.Port), mux)})
+	}
 	members = append(members, grouper.Member{Name: "router", Runner: goRouter})
+	if c.OCSPStapling.Enabled {
+		ocspClient := &http.Client{Timeout: c.OCSPStapling.Timeout}
+		stapler := router.NewOCSPStapler(goRouter, ocspClient, c.OCSPStapling.RefreshInterval, metricsReporter, logger.Session("ocsp-stapler"))
+		members = append(members, grouper.Member{Name: "ocspStapler", Runner: stapler})
+	}
+	members = append(members, grouper.Member{Name: "lameDuckToggler", Runner: lameDuckToggler(goRouter)})
+	if configFile != "" {
+		reloader := configReloader(logger, configFile, c, proxyHandler.(proxy.Reloader), goRouter, backendClientCerts)
+		members = append(members, grouper.Member{Name: "configReloader", Runner: reloader})
+	}
 
 	group := grouper.NewOrdered(os.Interrupt, members)
 
@@ -191,6 +347,7 @@ func main() {
 	}()
 
 	err = <-monitor.Wait()
+	accessLogger.Stop()
 	if err != nil {
 		logger.Error("gorouter.exited-with-failure", zap.Error(err))
 		os.Exit(1)
@@ -199,7 +356,72 @@ func main() {
 	os.Exit(0)
 }
 
-func initializeFDMonitor(sender *metric_sender.MetricSender, logger goRouterLogger.Logger) *monitor.FileDescriptor {
+// configReloader reads the configuration file again on SIGHUP. It replaces
+// the certificates of the TLS listeners and the client certificates presented
+// to backends with the ones it configures, and applies the settings that
+// config.ReloadableChanges lists, logging those that changed. A configuration
+// that fails to load or validate leaves everything as it is.
+func configReloader(logger goRouterLogger.Logger, path string, current *config.Config, p proxy.Reloader, goRouter *router.Router, backendClientCerts *utils.ClientCertificates) ifrit.Runner {
+	reloaderLogger := logger.Session("config-reloader")
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		defer signal.Stop(reload)
+		close(ready)
+
+		for {
+			select {
+			case <-signals:
+				return nil
+			case <-reload:
+				c, err := config.InitConfigFromFile(path)
+				if err != nil {
+					reloaderLogger.Error("config-reload-failed", zap.Error(err))
+					continue
+				}
+				var level zap.Level
+				if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+					reloaderLogger.Error("config-reload-failed", zap.Error(err))
+					continue
+				}
+
+				if c.EnableSSL {
+					goRouter.UpdateCertificates(c.SSLCertificates)
+				}
+				backendClientCerts.Update(c.Backends.ClientAuthCertificate, c.Backends.NamedClientAuthCertificates)
+
+				logger.SetLevel(level)
+				goRouter.UpdateCipherSuites(c.CipherSuites)
+				p.Reload(c)
+
+				reloaderLogger.Info("config-reloaded", zap.Object("changed", current.ReloadableChanges(c)))
+				current = c
+			}
+		}
+	})
+}
+
+// lameDuckToggler puts the router in lame duck mode on SIGUSR2, and takes it
+// out of it on the next one.
+func lameDuckToggler(goRouter *router.Router) ifrit.Runner {
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		toggle := make(chan os.Signal, 1)
+		signal.Notify(toggle, syscall.SIGUSR2)
+		defer signal.Stop(toggle)
+		close(ready)
+
+		for {
+			select {
+			case <-signals:
+				return nil
+			case <-toggle:
+				goRouter.ToggleLameDuck()
+			}
+		}
+	})
+}
+
+func initializeFDMonitor(sender dropsondemetrics.MetricSender, logger goRouterLogger.Logger) *monitor.FileDescriptor {
 	pid := os.Getpid()
 	path := fmt.Sprintf("/proc/%d/fd", pid)
 	ticker := time.NewTicker(time.Second * 5)
@@ -216,7 +438,7 @@ func initializeNATSMonitor(subscriber *mbus.Subscriber, sender *metric_sender.Me
 	}
 }
 
-func initializeMetrics(sender *metric_sender.MetricSender) *metrics.MetricsReporter {
+func initializeMetrics(sender *metric_sender.MetricSender, prometheusRegistry *metrics.PrometheusRegistry, statsd *metrics.StatsD) *metrics.MetricsReporter {
 	// 5 sec is dropsonde default batching interval
 	batcher := metricbatcher.New(sender, 5*time.Second)
 	batcher.AddConsistentlyEmittedMetrics("bad_gateways",
@@ -224,6 +446,7 @@ func initializeMetrics(sender *metric_sender.MetricSender) *metrics.MetricsRepor
 		"backend_invalid_id",
 		"backend_invalid_tls_cert",
 		"backend_tls_handshake_failed",
+		"backend_connections_opened",
 		"rejected_requests",
 		"total_requests",
 		"responses",
@@ -238,7 +461,16 @@ func initializeMetrics(sender *metric_sender.MetricSender) *metrics.MetricsRepor
 		"websocket_upgrades",
 	)
 
-	return &metrics.MetricsReporter{Sender: sender, Batcher: batcher}
+	reporter := &metrics.MetricsReporter{Sender: sender, Batcher: batcher}
+	if prometheusRegistry != nil {
+		reporter.Sender = prometheusRegistry.Sender(reporter.Sender)
+		reporter.Batcher = prometheusRegistry.Batcher(reporter.Batcher)
+	}
+	if statsd != nil {
+		reporter.Sender = statsd.Sender(reporter.Sender)
+		reporter.Batcher = statsd.Batcher(reporter.Batcher)
+	}
+	return reporter
 }
 
 func createCrypto(logger goRouterLogger.Logger, secret string) *secure.AesGCM {
@@ -262,11 +494,16 @@ func setupRoutingAPIClient(logger goRouterLogger.Logger, c *config.Config) (rout
 
 	if !c.RoutingApi.AuthDisabled {
 		token, err := uaaClient.FetchToken(true)
-		if err != nil {
-			return nil, fmt.Errorf("unable-to-fetch-token: %s", err.Error())
+		if err == nil && token.AccessToken == "" {
+			err = errors.New("empty token fetched")
 		}
-		if token.AccessToken == "" {
-			return nil, fmt.Errorf("empty token fetched")
+		if err != nil {
+			if c.OAuth.FailureMode != config.OAuthFailureModeFailOpen {
+				return nil, fmt.Errorf("unable-to-fetch-token: %s", err.Error())
+			}
+			// the route fetcher sets the token once it fetches one
+			logger.Error("unable-to-fetch-token", zap.Error(err))
+			return client, nil
 		}
 		client.SetToken(token.AccessToken)
 	}
@@ -286,7 +523,10 @@ func setupRouteFetcher(logger goRouterLogger.Logger, c *config.Config, registry
 
 	_, err := uaaClient.FetchToken(true)
 	if err != nil {
-		logger.Fatal("unable-to-fetch-token", zap.Error(err))
+		if c.OAuth.FailureMode != config.OAuthFailureModeFailOpen {
+			logger.Fatal("unable-to-fetch-token", zap.Error(err))
+		}
+		logger.Error("unable-to-fetch-token", zap.Error(err))
 	}
 
 	routeFetcher := route_fetcher.NewRouteFetcher(logger, uaaClient, registry, c, routingAPIClient, 1, cl)
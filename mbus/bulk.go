@@ -0,0 +1,94 @@
+package mbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+)
+
+// maxBulkRoutesBytes bounds the body of a bulk route request, which is read
+// into memory in full so that it can be validated before any route is
+// registered.
+const maxBulkRoutesBytes = 64 * 1024 * 1024
+
+// RouteSyncer is implemented by registries that can register the endpoints of
+// many routes at once.
+type RouteSyncer interface {
+	Sync(routes map[route.Uri][]*route.Endpoint, replace bool) (int, int)
+}
+
+// BulkRoutesHandler returns a handler that registers the routes of a JSON
+// array of RegistryMessages POSTed to it, in the format read by PreloadRoutes,
+// all at once. When the mode query parameter is "replace", the endpoints of
+// those routes that are not in the array are unregistered, and when it is
+// "merge", the default, they are left alone. Every message is validated as if
+// validate_registration_messages were enabled, and no route is registered if
+// any of them is invalid.
+func BulkRoutesHandler(registry RouteSyncer, l logger.Logger) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var replace bool
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "", "merge":
+		case "replace":
+			replace = true
+		default:
+			http.Error(rw, fmt.Sprintf("invalid mode: %q", mode), http.StatusBadRequest)
+			return
+		}
+
+		var msgs []RegistryMessage
+		err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxBulkRoutesBytes)).Decode(&msgs)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("invalid routes: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		routes, err := bulkRoutes(msgs)
+		if err != nil {
+			l.Warn("invalid-bulk-routes", zap.Error(err))
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		registered, unregistered := registry.Sync(routes, replace)
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]int{
+			"registered":   registered,
+			"unregistered": unregistered,
+		})
+	})
+}
+
+// bulkRoutes validates the messages and returns the endpoints they register
+// for each route, or the error of the first invalid message.
+func bulkRoutes(msgs []RegistryMessage) (map[route.Uri][]*route.Endpoint, error) {
+	routes := map[route.Uri][]*route.Endpoint{}
+	for i := range msgs {
+		msg := &msgs[i]
+		if !msg.ValidateMessage() {
+			return nil, fmt.Errorf("routes[%d]: route_service_url must use https", i)
+		}
+		if err := msg.validateRegistration(); err != nil {
+			return nil, fmt.Errorf("routes[%d]: %s", i, err)
+		}
+		endpoint, err := msg.makeEndpoint()
+		if err != nil {
+			return nil, fmt.Errorf("routes[%d]: %s", i, err)
+		}
+		for _, uri := range msg.Uris {
+			key := uri.RouteKey()
+			routes[key] = append(routes[key], endpoint)
+		}
+	}
+	return routes, nil
+}
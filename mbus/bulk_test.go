@@ -0,0 +1,99 @@
+package mbus_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/mbus"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BulkRoutesHandler", func() {
+	var (
+		r       *registry.RouteRegistry
+		l       logger.Logger
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		cfg, err := config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+
+		l = test_util.NewTestZapLogger("bulk-test")
+		r = registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+		r.Register("existing.example.com", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.9", Port: 61000}))
+
+		handler = mbus.BulkRoutesHandler(r, l)
+	})
+
+	post := func(query, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/routes/bulk"+query, strings.NewReader(body))
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	addrs := func(uri route.Uri) []string {
+		var addrs []string
+		r.Lookup(uri).Each(func(e *route.Endpoint) {
+			addrs = append(addrs, e.CanonicalAddr())
+		})
+		return addrs
+	}
+
+	It("registers the routes of every message", func() {
+		rw := post("", `[
+			{"host": "10.0.0.1", "port": 61000, "uris": ["bulk.example.com", "existing.example.com"]},
+			{"host": "10.0.0.2", "tls_port": 61001, "uris": ["bulk.example.com"]}
+		]`)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+
+		var counts map[string]int
+		Expect(json.Unmarshal(rw.Body.Bytes(), &counts)).To(Succeed())
+		Expect(counts).To(Equal(map[string]int{"registered": 3, "unregistered": 0}))
+
+		Expect(addrs("bulk.example.com")).To(ConsistOf("10.0.0.1:61000", "10.0.0.2:61001"))
+		Expect(addrs("existing.example.com")).To(ConsistOf("10.0.0.1:61000", "10.0.0.9:61000"))
+	})
+
+	It("unregisters the other endpoints of the routes in replace mode", func() {
+		rw := post("?mode=replace", `[{"host": "10.0.0.1", "port": 61000, "uris": ["existing.example.com"]}]`)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(MatchJSON(`{"registered": 1, "unregistered": 1}`))
+
+		Expect(addrs("existing.example.com")).To(ConsistOf("10.0.0.1:61000"))
+	})
+
+	It("registers no route when any message is invalid", func() {
+		rw := post("", `[
+			{"host": "10.0.0.1", "port": 61000, "uris": ["bulk.example.com"]},
+			{"host": "10.0.0.2", "uris": ["bulk.example.com"]}
+		]`)
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+		Expect(rw.Body.String()).To(ContainSubstring("routes[1]: port or tls_port must be set"))
+
+		Expect(r.Lookup("bulk.example.com")).To(BeNil())
+	})
+
+	It("rejects an unknown mode", func() {
+		rw := post("?mode=overwrite", `[]`)
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("only accepts POST", func() {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/routes/bulk", nil))
+		Expect(rw.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rw.Header().Get("Allow")).To(Equal(http.MethodPost))
+	})
+})
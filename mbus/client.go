@@ -18,14 +18,37 @@ type Signal struct{}
 type Client interface {
 	Subscribe(subj string, cb nats.MsgHandler) (*nats.Subscription, error)
 	Publish(subj string, data []byte) error
+	Request(subj string, data []byte, timeout time.Duration) (*nats.Msg, error)
 }
 
-func Connect(c *config.Config, reconnected chan<- Signal, l logger.Logger) *nats.Conn {
+// ConnectionHealth reports the state of the NATS connection.
+type ConnectionHealth struct {
+	conn        *nats.Conn
+	lastConnect atomic.Value
+}
+
+func (h *ConnectionHealth) Status() nats.Status {
+	return h.conn.Status()
+}
+
+// Reconnects returns the number of times the connection has been
+// reestablished.
+func (h *ConnectionHealth) Reconnects() uint64 {
+	return h.conn.Stats().Reconnects
+}
+
+// LastConnect returns the time the connection was last established.
+func (h *ConnectionHealth) LastConnect() time.Time {
+	return h.lastConnect.Load().(time.Time)
+}
+
+func Connect(c *config.Config, reconnected chan<- Signal, l logger.Logger) (*nats.Conn, *ConnectionHealth) {
 	var natsClient *nats.Conn
 	var natsHost atomic.Value
 	var err error
 
-	options := natsOptions(l, c, &natsHost, reconnected)
+	health := &ConnectionHealth{}
+	options := natsOptions(l, c, c.NatsServers(), &natsHost, health, reconnected)
 	attempts := 3
 	for attempts > 0 {
 		natsClient, err = options.Connect()
@@ -50,16 +73,51 @@ func Connect(c *config.Config, reconnected chan<- Signal, l logger.Logger) *nats
 	l.Info("Successfully-connected-to-nats", zap.String("host", natsHostStr))
 
 	natsHost.Store(natsHostStr)
-	return natsClient
+	health.conn = natsClient
+	health.lastConnect.Store(time.Now())
+	return natsClient, health
 }
 
-func natsOptions(l logger.Logger, c *config.Config, natsHost *atomic.Value, reconnected chan<- Signal) nats.Options {
-	natsServers := c.NatsServers()
+// ConnectSecondary connects to the secondary NATS cluster. Unlike the
+// primary connection, it may be closed.
+func ConnectSecondary(c *config.Config, reconnected chan<- Signal, l logger.Logger) (*nats.Conn, error) {
+	var natsHost atomic.Value
+	health := &ConnectionHealth{}
+	options := natsOptions(l, c, c.NatsSecondaryServers(), &natsHost, health, reconnected)
+	options.NoCallbacksAfterClientClose = true
+
+	natsClient, err := options.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	var natsHostStr string
+	natsURL, err := url.Parse(natsClient.ConnectedUrl())
+	if err == nil {
+		natsHostStr = natsURL.Host
+	}
+	l.Info("Successfully-connected-to-secondary-nats", zap.String("host", natsHostStr))
 
+	natsHost.Store(natsHostStr)
+	health.conn = natsClient
+	health.lastConnect.Store(time.Now())
+	return natsClient, nil
+}
+
+func natsOptions(l logger.Logger, c *config.Config, natsServers []string, natsHost *atomic.Value, health *ConnectionHealth, reconnected chan<- Signal) nats.Options {
 	options := nats.DefaultOptions
 	options.Servers = natsServers
 	options.PingInterval = c.NatsClientPingInterval
 	options.MaxReconnect = -1
+
+	authOption, err := natsAuthOption(c)
+	if err != nil {
+		l.Fatal("nats-credentials-error", zap.Error(err))
+	}
+	if authOption != nil {
+		_ = authOption(&options)
+	}
+
 	notDisconnected := make(chan Signal)
 
 	options.ClosedCB = func(conn *nats.Conn) {
@@ -90,6 +148,7 @@ func natsOptions(l logger.Logger, c *config.Config, natsHost *atomic.Value, reco
 
 	options.ReconnectedCB = func(conn *nats.Conn) {
 		notDisconnected <- Signal{}
+		health.lastConnect.Store(time.Now())
 
 		natsURL, err := url.Parse(conn.ConnectedUrl())
 		natsHostStr := ""
@@ -0,0 +1,110 @@
+package mbus
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/nats-io/go-nats"
+	"github.com/nats-io/nkeys"
+)
+
+// natsAuthOption returns the option that authenticates to NATS with the
+// credentials file or NKey seed of the config, or nil if neither is set. The
+// files are read whenever the client connects, so that credentials rotated on
+// disk are used from the next reconnect on, such as when the server
+// disconnects a client whose user JWT expired.
+func natsAuthOption(c *config.Config) (nats.Option, error) {
+	switch {
+	case c.NatsCredentialsFile != "":
+		path := c.NatsCredentialsFile
+		// an unusable file fails at startup rather than at every connect
+		_, kp, err := readCredentials(path)
+		if err != nil {
+			return nil, err
+		}
+		kp.Wipe()
+		return nats.UserJWT(
+			func() (string, error) {
+				jwt, kp, err := readCredentials(path)
+				if err != nil {
+					return "", err
+				}
+				kp.Wipe()
+				return jwt, nil
+			},
+			func(nonce []byte) ([]byte, error) {
+				_, kp, err := readCredentials(path)
+				if err != nil {
+					return nil, err
+				}
+				defer kp.Wipe()
+				return kp.Sign(nonce)
+			},
+		), nil
+
+	case c.NatsNkeySeedFile != "":
+		path := c.NatsNkeySeedFile
+		seed, err := readSeed(path)
+		if err != nil {
+			return nil, err
+		}
+		kp, err := nkeys.FromSeed(seed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		defer kp.Wipe()
+		// the public key identifies the user, so it does not change with
+		// the seed file
+		pub, err := kp.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return nats.Nkey(pub, func(nonce []byte) ([]byte, error) {
+			seed, err := readSeed(path)
+			if err != nil {
+				return nil, err
+			}
+			return sign(seed, nonce)
+		}), nil
+	}
+	return nil, nil
+}
+
+func sign(seed, nonce []byte) ([]byte, error) {
+	kp, err := nkeys.FromSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	defer kp.Wipe()
+	return kp.Sign(nonce)
+}
+
+func readSeed(path string) ([]byte, error) {
+	seed, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(seed), nil
+}
+
+// readCredentials returns the user JWT and the key pair of the NKey seed of a
+// credentials file, in which each follows a decorated line such as
+// "-----BEGIN NATS USER JWT-----".
+func readCredentials(path string) (string, nkeys.KeyPair, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	jwt, err := nkeys.ParseDecoratedJWT(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: not a NATS credentials file: %s", path, err)
+	}
+	kp, err := nkeys.ParseDecoratedNKey(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: not a NATS credentials file: %s", path, err)
+	}
+	return jwt, kp, nil
+}
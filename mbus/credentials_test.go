@@ -0,0 +1,139 @@
+package mbus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/nats-io/go-nats"
+	"github.com/nats-io/nkeys"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("natsAuthOption", func() {
+	var (
+		cfg   *config.Config
+		dir   string
+		nonce = []byte("some-nonce")
+	)
+
+	newUser := func() (nkeys.KeyPair, []byte) {
+		kp, err := nkeys.CreateUser()
+		Expect(err).NotTo(HaveOccurred())
+		seed, err := kp.Seed()
+		Expect(err).NotTo(HaveOccurred())
+		return kp, seed
+	}
+
+	writeCredentials := func(path, jwt string, seed []byte) {
+		creds := fmt.Sprintf(`-----BEGIN NATS USER JWT-----
+%s
+------END NATS USER JWT------
+
+************************* IMPORTANT *************************
+NKEY Seed printed below can be used to sign and prove identity.
+
+-----BEGIN USER NKEY SEED-----
+%s
+------END USER NKEY SEED------
+`, jwt, seed)
+		Expect(ioutil.WriteFile(path, []byte(creds), 0600)).To(Succeed())
+	}
+
+	apply := func() nats.Options {
+		option, err := natsAuthOption(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(option).NotTo(BeNil())
+
+		options := nats.GetDefaultOptions()
+		Expect(option(&options)).To(Succeed())
+		return options
+	}
+
+	BeforeEach(func() {
+		var err error
+		cfg, err = config.DefaultConfig()
+		Expect(err).NotTo(HaveOccurred())
+		dir = GinkgoT().TempDir()
+	})
+
+	It("returns no option without credentials", func() {
+		option, err := natsAuthOption(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(option).To(BeNil())
+	})
+
+	Context("with a credentials file", func() {
+		BeforeEach(func() {
+			cfg.NatsCredentialsFile = filepath.Join(dir, "nats.creds")
+		})
+
+		It("sends the user JWT and signs the nonce with the seed", func() {
+			kp, seed := newUser()
+			writeCredentials(cfg.NatsCredentialsFile, "some-jwt", seed)
+
+			options := apply()
+			jwt, err := options.UserJWT()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jwt).To(Equal("some-jwt"))
+
+			sig, err := options.SignatureCB(nonce)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kp.Verify(nonce, sig)).To(Succeed())
+		})
+
+		It("uses rotated credentials on the next connect", func() {
+			_, seed := newUser()
+			writeCredentials(cfg.NatsCredentialsFile, "old-jwt", seed)
+			options := apply()
+
+			kp, seed := newUser()
+			writeCredentials(cfg.NatsCredentialsFile, "new-jwt", seed)
+
+			jwt, err := options.UserJWT()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jwt).To(Equal("new-jwt"))
+
+			sig, err := options.SignatureCB(nonce)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kp.Verify(nonce, sig)).To(Succeed())
+		})
+
+		It("errors when the file is not a credentials file", func() {
+			Expect(ioutil.WriteFile(cfg.NatsCredentialsFile, []byte("some-jwt"), 0600)).To(Succeed())
+
+			_, err := natsAuthOption(cfg)
+			Expect(err).To(MatchError(HavePrefix(cfg.NatsCredentialsFile + ": not a NATS credentials file")))
+		})
+	})
+
+	Context("with an NKey seed file", func() {
+		BeforeEach(func() {
+			cfg.NatsNkeySeedFile = filepath.Join(dir, "nats.nk")
+		})
+
+		It("sends the public key and signs the nonce with the seed", func() {
+			kp, seed := newUser()
+			Expect(ioutil.WriteFile(cfg.NatsNkeySeedFile, append(seed, '\n'), 0600)).To(Succeed())
+
+			options := apply()
+			pub, err := kp.PublicKey()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(options.Nkey).To(Equal(pub))
+
+			sig, err := options.SignatureCB(nonce)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kp.Verify(nonce, sig)).To(Succeed())
+		})
+
+		It("errors when the file is not a seed", func() {
+			Expect(ioutil.WriteFile(cfg.NatsNkeySeedFile, []byte("not-a-seed"), 0600)).To(Succeed())
+
+			_, err := natsAuthOption(cfg)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
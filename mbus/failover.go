@@ -0,0 +1,117 @@
+package mbus
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"github.com/nats-io/go-nats"
+	"github.com/uber-go/zap"
+)
+
+// Failover moves the subscriber to the secondary NATS cluster once the
+// primary connection has been down for the failover period, and back once
+// it has been up again for as long. The primary connection keeps
+// reconnecting meanwhile; the secondary is only connected while it is used.
+type Failover struct {
+	primary          *nats.Conn
+	connectSecondary func() (*nats.Conn, error)
+	after            time.Duration
+	clients          chan Client
+	reporter         metrics.SubscriberReporter
+	logger           logger.Logger
+}
+
+func NewFailover(primary *nats.Conn, c *config.Config, reconnected chan<- Signal, reporter metrics.SubscriberReporter, l logger.Logger) *Failover {
+	return &Failover{
+		primary: primary,
+		connectSecondary: func() (*nats.Conn, error) {
+			return ConnectSecondary(c, reconnected, l)
+		},
+		after:    c.NatsFailoverAfter,
+		clients:  make(chan Client),
+		reporter: reporter,
+		logger:   l,
+	}
+}
+
+// Clients receives the client that the subscriber should use whenever the
+// failover switches clusters.
+func (f *Failover) Clients() <-chan Client {
+	return f.clients
+}
+
+func (f *Failover) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(f.after / 10)
+	defer ticker.Stop()
+
+	close(ready)
+
+	var (
+		secondary *nats.Conn
+		// since is when the primary connection went down, while on the
+		// primary, or came back up, while on the secondary
+		since time.Time
+	)
+	for {
+		select {
+		case now := <-ticker.C:
+			connected := f.primary.Status() == nats.CONNECTED
+			if (secondary == nil) == connected {
+				since = time.Time{}
+				continue
+			}
+			if since.IsZero() {
+				since = now
+			}
+			if now.Sub(since) < f.after {
+				continue
+			}
+
+			if secondary == nil {
+				conn, err := f.connectSecondary()
+				if err != nil {
+					f.logger.Error("nats-failover-failed", zap.Error(err))
+					continue
+				}
+				if !f.switchTo(conn, signals) {
+					conn.Close()
+					return nil
+				}
+				secondary = conn
+				f.reporter.CaptureNATSFailover()
+				f.logger.Info("nats-failed-over-to-secondary", zap.Duration("primary-down-for", now.Sub(since)))
+			} else {
+				if !f.switchTo(f.primary, signals) {
+					secondary.Close()
+					return nil
+				}
+				secondary.Close()
+				secondary = nil
+				f.reporter.CaptureNATSFailback()
+				f.logger.Info("nats-failed-back-to-primary")
+			}
+			since = time.Time{}
+		case <-signals:
+			if secondary != nil {
+				secondary.Close()
+			}
+			f.logger.Info("exited")
+			return nil
+		}
+	}
+}
+
+// switchTo hands the client to the subscriber, and reports false if the
+// failover was signaled to exit first.
+func (f *Failover) switchTo(client Client, signals <-chan os.Signal) bool {
+	select {
+	case f.clients <- client:
+		return true
+	case <-signals:
+		f.logger.Info("exited")
+		return false
+	}
+}
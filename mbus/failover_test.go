@@ -0,0 +1,124 @@
+package mbus_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/mbus"
+	metricsFakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	registryFakes "code.cloudfoundry.org/gorouter/registry/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	"github.com/nats-io/go-nats"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Failover", func() {
+	var (
+		primaryRunner   *test_util.NATSRunner
+		secondaryRunner *test_util.NATSRunner
+		primary         *nats.Conn
+
+		registry *registryFakes.FakeRegistry
+		reporter *metricsFakes.FakeSubscriberReporter
+
+		subscriber ifrit.Process
+		failover   ifrit.Process
+	)
+
+	registeredURIs := func() []route.Uri {
+		var uris []route.Uri
+		for i := 0; i < registry.RegisterCallCount(); i++ {
+			uri, _ := registry.RegisterArgsForCall(i)
+			uris = append(uris, uri)
+		}
+		return uris
+	}
+
+	register := func(conn *nats.Conn, uri route.Uri) []route.Uri {
+		data, err := json.Marshal(mbus.RegistryMessage{
+			Host: "host",
+			App:  "app",
+			Port: 1111,
+			Uris: []route.Uri{uri},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.Publish("router.register", data)).To(Succeed())
+		Expect(conn.Flush()).To(Succeed())
+		return registeredURIs()
+	}
+
+	BeforeEach(func() {
+		primaryPort := test_util.NextAvailPort()
+		primaryRunner = test_util.NewNATSRunner(int(primaryPort))
+		primaryRunner.Start()
+
+		secondaryPort := test_util.NextAvailPort()
+		secondaryRunner = test_util.NewNATSRunner(int(secondaryPort))
+		secondaryRunner.Start()
+
+		var err error
+		primary, err = nats.Connect(
+			fmt.Sprintf("nats://127.0.0.1:%d", primaryPort),
+			nats.MaxReconnects(-1),
+			nats.ReconnectWait(50*time.Millisecond),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg, err := config.DefaultConfig()
+		Expect(err).NotTo(HaveOccurred())
+		cfg.NatsSecondary = []config.NatsConfig{{Host: "127.0.0.1", Port: secondaryPort}}
+		cfg.NatsFailoverAfter = 200 * time.Millisecond
+
+		registry = new(registryFakes.FakeRegistry)
+		reporter = new(metricsFakes.FakeSubscriberReporter)
+		l := test_util.NewTestZapLogger("failover-test")
+
+		reconnected := make(chan mbus.Signal)
+		sub := mbus.NewSubscriber(primary, registry, cfg, reconnected, reporter, l)
+		f := mbus.NewFailover(primary, cfg, reconnected, reporter, l)
+		sub.FollowFailover(f.Clients())
+
+		subscriber = ifrit.Invoke(sub)
+		failover = ifrit.Invoke(f)
+	})
+
+	AfterEach(func() {
+		failover.Signal(os.Interrupt)
+		Eventually(failover.Wait()).Should(Receive())
+		subscriber.Signal(os.Interrupt)
+		Eventually(subscriber.Wait()).Should(Receive())
+
+		primary.Close()
+		primaryRunner.Stop()
+		secondaryRunner.Stop()
+	})
+
+	It("fails over to the secondary cluster while the primary is down, and then back", func() {
+		Eventually(func() []route.Uri { return register(primaryRunner.MessageBus, "primary.example.com") }).Should(ContainElement(route.Uri("primary.example.com")))
+
+		primaryRunner.Stop()
+		Eventually(reporter.CaptureNATSFailoverCallCount).Should(Equal(1))
+		Eventually(func() []route.Uri { return register(secondaryRunner.MessageBus, "secondary.example.com") }).Should(ContainElement(route.Uri("secondary.example.com")))
+
+		primaryRunner.Start()
+		Eventually(reporter.CaptureNATSFailbackCallCount).Should(Equal(1))
+		Eventually(func() []route.Uri { return register(primaryRunner.MessageBus, "failed-back.example.com") }).Should(ContainElement(route.Uri("failed-back.example.com")))
+
+		Consistently(func() []route.Uri { return register(secondaryRunner.MessageBus, "stale.example.com") }, 200*time.Millisecond).ShouldNot(ContainElement(route.Uri("stale.example.com")))
+	})
+
+	It("stays on the primary cluster through short outages", func() {
+		primaryRunner.Stop()
+		primaryRunner.Start()
+
+		Consistently(reporter.CaptureNATSFailoverCallCount, 500*time.Millisecond).Should(Equal(0))
+		Eventually(func() []route.Uri { return register(primaryRunner.MessageBus, "primary.example.com") }).Should(ContainElement(route.Uri("primary.example.com")))
+	})
+})
@@ -3,6 +3,7 @@ package fakes
 
 import (
 	"sync"
+	"time"
 
 	"code.cloudfoundry.org/gorouter/mbus"
 	nats "github.com/nats-io/go-nats"
@@ -35,6 +36,21 @@ type FakeClient struct {
 	publishReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RequestStub        func(subj string, data []byte, timeout time.Duration) (*nats.Msg, error)
+	requestMutex       sync.RWMutex
+	requestArgsForCall []struct {
+		subj    string
+		data    []byte
+		timeout time.Duration
+	}
+	requestReturns struct {
+		result1 *nats.Msg
+		result2 error
+	}
+	requestReturnsOnCall map[int]struct {
+		result1 *nats.Msg
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -145,6 +161,64 @@ func (fake *FakeClient) PublishReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeClient) Request(subj string, data []byte, timeout time.Duration) (*nats.Msg, error) {
+	var dataCopy []byte
+	if data != nil {
+		dataCopy = make([]byte, len(data))
+		copy(dataCopy, data)
+	}
+	fake.requestMutex.Lock()
+	ret, specificReturn := fake.requestReturnsOnCall[len(fake.requestArgsForCall)]
+	fake.requestArgsForCall = append(fake.requestArgsForCall, struct {
+		subj    string
+		data    []byte
+		timeout time.Duration
+	}{subj, dataCopy, timeout})
+	fake.recordInvocation("Request", []interface{}{subj, dataCopy, timeout})
+	fake.requestMutex.Unlock()
+	if fake.RequestStub != nil {
+		return fake.RequestStub(subj, data, timeout)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.requestReturns.result1, fake.requestReturns.result2
+}
+
+func (fake *FakeClient) RequestCallCount() int {
+	fake.requestMutex.RLock()
+	defer fake.requestMutex.RUnlock()
+	return len(fake.requestArgsForCall)
+}
+
+func (fake *FakeClient) RequestArgsForCall(i int) (string, []byte, time.Duration) {
+	fake.requestMutex.RLock()
+	defer fake.requestMutex.RUnlock()
+	return fake.requestArgsForCall[i].subj, fake.requestArgsForCall[i].data, fake.requestArgsForCall[i].timeout
+}
+
+func (fake *FakeClient) RequestReturns(result1 *nats.Msg, result2 error) {
+	fake.RequestStub = nil
+	fake.requestReturns = struct {
+		result1 *nats.Msg
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) RequestReturnsOnCall(i int, result1 *nats.Msg, result2 error) {
+	fake.RequestStub = nil
+	if fake.requestReturnsOnCall == nil {
+		fake.requestReturnsOnCall = make(map[int]struct {
+			result1 *nats.Msg
+			result2 error
+		})
+	}
+	fake.requestReturnsOnCall[i] = struct {
+		result1 *nats.Msg
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -152,6 +226,8 @@ func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	defer fake.subscribeMutex.RUnlock()
 	fake.publishMutex.RLock()
 	defer fake.publishMutex.RUnlock()
+	fake.requestMutex.RLock()
+	defer fake.requestMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,199 @@
+// +build grpc
+
+package mbus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// RouteRegistrationServer serves the gorouter.RouteRegistration gRPC service
+// of route_registration.proto, which registers routes for as long as a
+// client keeps a stream open. The routes of a stream are registered again
+// periodically, so that they are not pruned while it is open, and are
+// unregistered when it ends.
+type RouteRegistrationServer struct {
+	registry        registry.Registry
+	addr            string
+	user            string
+	pass            string
+	refreshInterval time.Duration
+	logger          logger.Logger
+}
+
+// NewRouteRegistrationServer returns a server for the gRPC port of the status
+// listener.
+func NewRouteRegistrationServer(routeRegistry registry.Registry, c *config.Config, l logger.Logger) *RouteRegistrationServer {
+	return &RouteRegistrationServer{
+		registry:        routeRegistry,
+		addr:            net.JoinHostPort(c.Status.Host, strconv.Itoa(int(c.Status.RegistrationGRPCPort))),
+		user:            c.Status.User,
+		pass:            c.Status.Pass,
+		refreshInterval: c.DropletStaleThreshold / 4,
+		logger:          l,
+	}
+}
+
+var routeRegistrationServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gorouter.RouteRegistration",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Register",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*RouteRegistrationServer).register(stream)
+			},
+			ClientStreams: true,
+		},
+	},
+	Metadata: "route_registration.proto",
+}
+
+// Run manages the lifecycle of the server. Open streams are cut when the
+// process is signaled to exit.
+func (s *RouteRegistrationServer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	// keepalives find clients that went away without closing their stream
+	server := grpc.NewServer(grpc.KeepaliveParams(keepalive.ServerParameters{
+		Time:    30 * time.Second,
+		Timeout: 10 * time.Second,
+	}))
+	server.RegisterService(&routeRegistrationServiceDesc, s)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	close(ready)
+	s.logger.Info("route-registration-server-started", zap.String("address", s.addr))
+
+	select {
+	case <-signals:
+		server.Stop()
+		s.logger.Info("exited")
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+type streamRegistration struct {
+	uri      route.Uri
+	endpoint *route.Endpoint
+}
+
+func (s *RouteRegistrationServer) register(stream grpc.ServerStream) error {
+	if !s.authenticated(stream.Context()) {
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+
+	msgs := make(chan *RegistryMessage)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := receiveRegistryMessage(stream)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	// keyed by route and address, so that an endpoint registered again
+	// replaces the one before
+	registrations := map[string]streamRegistration{}
+	defer func() {
+		for _, r := range registrations {
+			s.registry.Unregister(r.uri, r.endpoint)
+		}
+		s.logger.Debug("route-registration-stream-closed", zap.Int("routes", len(registrations)))
+	}()
+
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-msgs:
+			endpoint, err := msg.makeEndpoint()
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			for _, uri := range msg.Uris {
+				registrations[uri.RouteKey().String()+" "+endpoint.CanonicalAddr()] = streamRegistration{uri: uri, endpoint: endpoint}
+				s.registry.Register(uri, endpoint)
+			}
+		case <-ticker.C:
+			for _, r := range registrations {
+				s.registry.Register(r.uri, r.endpoint)
+			}
+		case err := <-errs:
+			if err == io.EOF {
+				return stream.SendMsg(&emptypb.Empty{})
+			}
+			return err
+		}
+	}
+}
+
+// receiveRegistryMessage reads the next message of the stream as a
+// RegistryMessage. Its stale threshold is ignored, since the routes of a
+// stream are registered for as long as the stream is open.
+func receiveRegistryMessage(stream grpc.ServerStream) (*RegistryMessage, error) {
+	var st structpb.Struct
+	if err := stream.RecvMsg(&st); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(st.AsMap())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	msg, err := createRegistryMessage(data)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := msg.validateRegistration(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	msg.StaleThresholdInSeconds = 0
+	return msg, nil
+}
+
+// authenticated reports whether the stream carries the credentials of the
+// status listener as basic authorization.
+func (s *RouteRegistrationServer) authenticated(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	req := &http.Request{Header: http.Header{"Authorization": md.Get("authorization")}}
+	user, pass, ok := req.BasicAuth()
+	return ok && user == s.user && pass == s.pass
+}
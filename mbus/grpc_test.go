@@ -0,0 +1,118 @@
+// +build grpc
+
+package mbus_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/mbus"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/test_util"
+	"github.com/tedsuo/ifrit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RouteRegistrationServer", func() {
+	var (
+		r       *registry.RouteRegistry
+		process ifrit.Process
+		conn    *grpc.ClientConn
+	)
+
+	BeforeEach(func() {
+		cfg, err := config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+		cfg.Status.Host = "127.0.0.1"
+		cfg.Status.RegistrationGRPCPort = test_util.NextAvailPort()
+		cfg.Status.User = "user"
+		cfg.Status.Pass = "pass"
+
+		l := test_util.NewTestZapLogger("grpc-test")
+		r = registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+		process = ifrit.Invoke(mbus.NewRouteRegistrationServer(r, cfg, l))
+
+		conn, err = grpc.NewClient(
+			fmt.Sprintf("127.0.0.1:%d", cfg.Status.RegistrationGRPCPort),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	openStream := func(ctx context.Context, user, pass string) grpc.ClientStream {
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", auth)
+		stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, "/gorouter.RouteRegistration/Register")
+		Expect(err).ToNot(HaveOccurred())
+		return stream
+	}
+
+	send := func(stream grpc.ClientStream, msg map[string]interface{}) {
+		st, err := structpb.NewStruct(msg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stream.SendMsg(st)).To(Succeed())
+	}
+
+	registration := map[string]interface{}{
+		"host": "10.0.0.1",
+		"port": 61000,
+		"uris": []interface{}{"grpc.example.com"},
+	}
+
+	It("registers routes while the stream is open", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream := openStream(ctx, "user", "pass")
+		send(stream, registration)
+		Eventually(func() interface{} { return r.Lookup("grpc.example.com") }).ShouldNot(BeNil())
+
+		cancel()
+		Eventually(func() interface{} { return r.Lookup("grpc.example.com") }).Should(BeNil())
+	})
+
+	It("unregisters routes when the client closes the stream", func() {
+		stream := openStream(context.Background(), "user", "pass")
+		send(stream, registration)
+		Eventually(func() interface{} { return r.Lookup("grpc.example.com") }).ShouldNot(BeNil())
+
+		Expect(stream.CloseSend()).To(Succeed())
+		Expect(stream.RecvMsg(&emptypb.Empty{})).To(Succeed())
+		Expect(r.Lookup("grpc.example.com")).To(BeNil())
+	})
+
+	It("rejects invalid registrations", func() {
+		stream := openStream(context.Background(), "user", "pass")
+		send(stream, map[string]interface{}{"host": "10.0.0.1", "uris": []interface{}{"grpc.example.com"}})
+
+		err := stream.RecvMsg(&emptypb.Empty{})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		Expect(err.Error()).To(ContainSubstring("port or tls_port must be set"))
+	})
+
+	It("rejects streams without the credentials of the status listener", func() {
+		stream := openStream(context.Background(), "user", "wrong")
+
+		err := stream.RecvMsg(&emptypb.Empty{})
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+	})
+})
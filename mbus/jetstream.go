@@ -0,0 +1,104 @@
+package mbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/go-nats"
+)
+
+// jetStreamRequestTimeout bounds requests to the JetStream API.
+const jetStreamRequestTimeout = 5 * time.Second
+
+// consumerCreateRequest is the request of the JetStream API that creates a
+// durable consumer, or leaves it as it is if it exists with the same config.
+type consumerCreateRequest struct {
+	StreamName string         `json:"stream_name"`
+	Config     consumerConfig `json:"config"`
+}
+
+type consumerConfig struct {
+	DurableName    string        `json:"durable_name"`
+	DeliverSubject string        `json:"deliver_subject"`
+	DeliverPolicy  string        `json:"deliver_policy"`
+	AckPolicy      string        `json:"ack_policy"`
+	AckWait        time.Duration `json:"ack_wait"`
+}
+
+type jetStreamResponse struct {
+	Error *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// jetStreamDeliverSubject is where the consumer pushes messages. It does not
+// change across restarts, so that the existing consumer can be reused.
+func (s *Subscriber) jetStreamDeliverSubject() string {
+	return "gorouter.jetstream." + s.jetStream.Durable
+}
+
+// subscribeJetStream receives registrations from the durable consumer of the
+// configured stream. The consumer delivers messages with their original
+// subjects, which are acknowledged once handled; those published while the
+// subscription was gone are delivered once it is back. Messages shed by the
+// maximum registration rate are not acknowledged, so that the consumer
+// delivers them again after AckWait.
+func (s *Subscriber) subscribeJetStream() (*nats.Subscription, error) {
+	natsSubscription, err := s.client().Subscribe(s.jetStreamDeliverSubject(), func(message *nats.Msg) {
+		if s.handleMessage(message) && message.Reply != "" {
+			_ = s.client().Publish(message.Reply, nil)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = natsSubscription.SetPendingLimits(s.natsPendingLimit, s.natsPendingLimit*1024)
+	if err != nil {
+		_ = natsSubscription.Unsubscribe()
+		return nil, fmt.Errorf("subscriber: SetPendingLimits: %s", err)
+	}
+
+	if err := s.createJetStreamConsumer(); err != nil {
+		_ = natsSubscription.Unsubscribe()
+		return nil, err
+	}
+	return natsSubscription, nil
+}
+
+func (s *Subscriber) createJetStreamConsumer() error {
+	data, err := json.Marshal(consumerCreateRequest{
+		StreamName: s.jetStream.Stream,
+		Config: consumerConfig{
+			DurableName:    s.jetStream.Durable,
+			DeliverSubject: s.jetStreamDeliverSubject(),
+			// a new consumer starts with the next message, since older
+			// registrations are refreshed by their clients anyway
+			DeliverPolicy: "new",
+			AckPolicy:     "explicit",
+			AckWait:       s.jetStream.AckWait,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("$JS.API.CONSUMER.DURABLE.CREATE.%s.%s", s.jetStream.Stream, s.jetStream.Durable)
+	msg, err := s.client().Request(subject, data, jetStreamRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("subscriber: create JetStream consumer: %s", err)
+	}
+
+	var resp jetStreamResponse
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		return fmt.Errorf("subscriber: create JetStream consumer: %s", err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("subscriber: create JetStream consumer: %s (%d)", resp.Error.Description, resp.Error.Code)
+	}
+
+	s.logger.Info("jetstream-consumer-created")
+	return nil
+}
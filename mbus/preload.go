@@ -0,0 +1,57 @@
+package mbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"github.com/uber-go/zap"
+)
+
+// PreloadRoutes reads a JSON array of RegistryMessages from path and registers
+// each of them with the route registry. It is intended to run at startup,
+// before the subscriber is listening on NATS, so that routes can be served
+// while the registry is being repopulated by NATS. Preloaded endpoints are
+// provisional until they are registered again: they are only selected while
+// no registered endpoint of the route is available, and are pruned once they
+// are stale, even when they use TLS.
+func PreloadRoutes(path string, routeRegistry registry.Registry, l logger.Logger) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("preload: reading %s: %s", path, err)
+	}
+
+	var msgs []RegistryMessage
+	err = json.Unmarshal(data, &msgs)
+	if err != nil {
+		return 0, fmt.Errorf("preload: parsing %s: %s", path, err)
+	}
+
+	registered := 0
+	for i := range msgs {
+		msg := &msgs[i]
+		if !msg.ValidateMessage() {
+			l.Error("preload-validation-error", zap.Object("message", msg))
+			continue
+		}
+
+		endpoint, err := msg.makeEndpoint()
+		if err != nil {
+			l.Error("preload-unable-to-register-route",
+				zap.Error(err),
+				zap.Object("message", msg),
+			)
+			continue
+		}
+		endpoint.Provisional = true
+
+		for _, uri := range msg.Uris {
+			routeRegistry.Register(uri, endpoint)
+		}
+		registered++
+	}
+
+	return registered, nil
+}
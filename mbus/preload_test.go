@@ -0,0 +1,132 @@
+package mbus_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/mbus"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PreloadRoutes", func() {
+	var (
+		r       *registry.RouteRegistry
+		cfg     *config.Config
+		l       logger.Logger
+		tmpDir  string
+		preload string
+	)
+
+	BeforeEach(func() {
+		var err error
+		cfg, err = config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+		cfg.PruneStaleDropletsInterval = 50 * time.Millisecond
+		cfg.DropletStaleThreshold = 100 * time.Millisecond
+
+		l = test_util.NewTestZapLogger("preload-test")
+		r = registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+
+		tmpDir, err = ioutil.TempDir("", "preload")
+		Expect(err).ToNot(HaveOccurred())
+		preload = filepath.Join(tmpDir, "routes.json")
+	})
+
+	AfterEach(func() {
+		r.StopPruningCycle()
+		os.RemoveAll(tmpDir)
+	})
+
+	writePreloadFile := func(contents string) {
+		err := ioutil.WriteFile(preload, []byte(contents), 0644)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("registers the routes so they are servable before any NATS message", func() {
+		writePreloadFile(`[
+			{"host": "10.0.0.1", "port": 61000, "uris": ["preloaded.example.com"], "app": "app-guid"},
+			{"host": "10.0.0.2", "tls_port": 61001, "uris": ["preloaded.example.com", "other.example.com"], "server_cert_domain_san": "san"}
+		]`)
+
+		registered, err := mbus.PreloadRoutes(preload, r, l)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(registered).To(Equal(2))
+
+		pool := r.Lookup("preloaded.example.com")
+		Expect(pool).ToNot(BeNil())
+		addrs := []string{}
+		pool.Each(func(e *route.Endpoint) {
+			addrs = append(addrs, e.CanonicalAddr())
+		})
+		Expect(addrs).To(ConsistOf("10.0.0.1:61000", "10.0.0.2:61001"))
+
+		Expect(r.Lookup("other.example.com")).ToNot(BeNil())
+	})
+
+	It("marks the endpoints provisional until they are registered again", func() {
+		writePreloadFile(`[{"host": "10.0.0.1", "port": 61000, "uris": ["preloaded.example.com"]}]`)
+
+		_, err := mbus.PreloadRoutes(preload, r, l)
+		Expect(err).ToNot(HaveOccurred())
+
+		var provisional bool
+		r.Lookup("preloaded.example.com").Each(func(e *route.Endpoint) {
+			provisional = e.Provisional
+		})
+		Expect(provisional).To(BeTrue())
+
+		r.Register("preloaded.example.com", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.1", Port: 61000}))
+		r.Lookup("preloaded.example.com").Each(func(e *route.Endpoint) {
+			provisional = e.Provisional
+		})
+		Expect(provisional).To(BeFalse())
+	})
+
+	It("skips messages that fail validation", func() {
+		writePreloadFile(`[
+			{"host": "10.0.0.1", "port": 61000, "uris": ["insecure.example.com"], "route_service_url": "http://rs.example.com"},
+			{"host": "10.0.0.2", "port": 61000, "uris": ["valid.example.com"]}
+		]`)
+
+		registered, err := mbus.PreloadRoutes(preload, r, l)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(registered).To(Equal(1))
+		Expect(r.Lookup("insecure.example.com")).To(BeNil())
+		Expect(r.Lookup("valid.example.com")).ToNot(BeNil())
+	})
+
+	It("prunes preloaded routes once they become stale", func() {
+		writePreloadFile(`[{"host": "10.0.0.1", "port": 61000, "uris": ["stale.example.com"]}]`)
+
+		_, err := mbus.PreloadRoutes(preload, r, l)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Lookup("stale.example.com")).ToNot(BeNil())
+
+		r.StartPruningCycle()
+		Eventually(func() *route.Pool {
+			return r.Lookup("stale.example.com")
+		}).Should(BeNil())
+	})
+
+	It("returns an error when the file does not exist", func() {
+		_, err := mbus.PreloadRoutes(filepath.Join(tmpDir, "missing.json"), r, l)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when the file is not valid JSON", func() {
+		writePreloadFile(`{not json`)
+
+		_, err := mbus.PreloadRoutes(preload, r, l)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,113 @@
+package mbus
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/uuid"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/nats-io/go-nats"
+	"github.com/uber-go/zap"
+)
+
+// PruneSubject is where gorouters vote to prune the stale endpoints of
+// routes.
+const PruneSubject = "router.prune"
+
+type pruneVote struct {
+	Router string    `json:"router"`
+	Uri    route.Uri `json:"uri"`
+}
+
+// PruneSignal is a registry.PruneSignal that gorouters share over NATS. Votes
+// count for window, after which gorouters that still find stale endpoints of
+// a route vote again.
+type PruneSignal struct {
+	client Client
+	id     string
+	window time.Duration
+	logger logger.Logger
+
+	lock  sync.Mutex
+	votes map[route.Uri]map[string]time.Time
+}
+
+// NewPruneSignal subscribes to the votes of other gorouters, whose votes
+// count for window.
+func NewPruneSignal(client Client, window time.Duration, l logger.Logger) (*PruneSignal, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PruneSignal{
+		client: client,
+		id:     id,
+		window: window,
+		logger: l,
+		votes:  map[route.Uri]map[string]time.Time{},
+	}
+	_, err = client.Subscribe(PruneSubject, func(msg *nats.Msg) {
+		var vote pruneVote
+		if err := json.Unmarshal(msg.Data, &vote); err != nil {
+			s.logger.Error("prune-vote-unmarshal-failed", zap.Error(err), zap.String("payload", string(msg.Data)))
+			return
+		}
+		s.record(vote)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *PruneSignal) Vote(uri route.Uri) {
+	vote := pruneVote{Router: s.id, Uri: uri.RouteKey()}
+	s.record(vote)
+
+	data, err := json.Marshal(vote)
+	if err != nil {
+		s.logger.Error("prune-vote-marshal-failed", zap.Error(err))
+		return
+	}
+	if err := s.client.Publish(PruneSubject, data); err != nil {
+		s.logger.Error("prune-vote-publish-failed", zap.Error(err))
+	}
+}
+
+func (s *PruneSignal) Votes(uri route.Uri) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expire(time.Now())
+	return len(s.votes[uri.RouteKey()])
+}
+
+func (s *PruneSignal) record(vote pruneVote) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	routers, ok := s.votes[vote.Uri]
+	if !ok {
+		routers = map[string]time.Time{}
+		s.votes[vote.Uri] = routers
+	}
+	routers[vote.Router] = time.Now()
+}
+
+// expire forgets the votes older than the window. It must be called with the
+// lock held.
+func (s *PruneSignal) expire(now time.Time) {
+	for uri, routers := range s.votes {
+		for router, votedAt := range routers {
+			if now.Sub(votedAt) > s.window {
+				delete(routers, router)
+			}
+		}
+		if len(routers) == 0 {
+			delete(s.votes, uri)
+		}
+	}
+}
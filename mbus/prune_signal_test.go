@@ -0,0 +1,56 @@
+package mbus_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/mbus"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PruneSignal", func() {
+	var (
+		natsRunner *test_util.NATSRunner
+		signal     *mbus.PruneSignal
+		other      *mbus.PruneSignal
+	)
+
+	BeforeEach(func() {
+		natsRunner = test_util.NewNATSRunner(int(test_util.NextAvailPort()))
+		natsRunner.Start()
+
+		var err error
+		signal, err = mbus.NewPruneSignal(natsRunner.MessageBus, 200*time.Millisecond, test_util.NewTestZapLogger("prune-signal"))
+		Expect(err).ToNot(HaveOccurred())
+		other, err = mbus.NewPruneSignal(natsRunner.MessageBus, 200*time.Millisecond, test_util.NewTestZapLogger("other-prune-signal"))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		natsRunner.Stop()
+	})
+
+	It("counts its own vote", func() {
+		signal.Vote("foo.com")
+		Expect(signal.Votes("foo.com")).To(Equal(1))
+		Expect(signal.Votes("bar.com")).To(Equal(0))
+	})
+
+	It("counts the votes of other gorouters once", func() {
+		signal.Vote("Foo.com")
+		other.Vote("foo.com")
+		other.Vote("foo.com")
+
+		Eventually(func() int { return signal.Votes("foo.com") }).Should(Equal(2))
+		Consistently(func() int { return signal.Votes("foo.com") }, 100*time.Millisecond).Should(Equal(2))
+	})
+
+	It("forgets votes older than the window", func() {
+		other.Vote("foo.com")
+		Eventually(func() int { return signal.Votes("foo.com") }).Should(Equal(1))
+
+		Eventually(func() int { return signal.Votes("foo.com") }, time.Second).Should(Equal(0))
+	})
+})
@@ -0,0 +1,80 @@
+package mbus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"github.com/uber-go/zap"
+)
+
+// maxRegistrationBytes bounds the body of a request that registers or
+// unregisters routes.
+const maxRegistrationBytes = 1024 * 1024
+
+// RegisterHandler returns a handler that registers the routes of a
+// RegistryMessage POSTed to it, as a router.register message would. The
+// message is validated as if validate_registration_messages were enabled.
+// Like routes registered over NATS, the routes must be registered again
+// within their stale threshold, or they are pruned.
+func RegisterHandler(routeRegistry registry.Registry, l logger.Logger) http.Handler {
+	return registrationHandler(l, func(msg *RegistryMessage) error {
+		if err := msg.validateRegistration(); err != nil {
+			return err
+		}
+		endpoint, err := msg.makeEndpoint()
+		if err != nil {
+			return err
+		}
+		for _, uri := range msg.Uris {
+			routeRegistry.Register(uri, endpoint)
+		}
+		return nil
+	})
+}
+
+// UnregisterHandler returns a handler that unregisters the routes of a
+// RegistryMessage POSTed to it, as a router.unregister message would.
+func UnregisterHandler(routeRegistry registry.Registry, l logger.Logger) http.Handler {
+	return registrationHandler(l, func(msg *RegistryMessage) error {
+		endpoint, err := msg.makeEndpoint()
+		if err != nil {
+			return err
+		}
+		for _, uri := range msg.Uris {
+			routeRegistry.Unregister(uri, endpoint)
+		}
+		return nil
+	})
+}
+
+// registrationHandler decodes the RegistryMessage POSTed to it and passes it
+// to apply. It responds with 204 No Content, or with 400 Bad Request and the
+// error if the message is malformed or apply fails.
+func registrationHandler(l logger.Logger, apply func(msg *RegistryMessage) error) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxRegistrationBytes))
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("reading message: %s", err), http.StatusBadRequest)
+			return
+		}
+		msg, err := createRegistryMessage(data)
+		if err == nil {
+			err = apply(msg)
+		}
+		if err != nil {
+			l.Warn("invalid-registration-request", zap.Error(err), zap.String("path", r.URL.Path))
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,73 @@
+package mbus_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/mbus"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Route registration handlers", func() {
+	var (
+		r          *registry.RouteRegistry
+		l          logger.Logger
+		register   http.Handler
+		unregister http.Handler
+	)
+
+	BeforeEach(func() {
+		cfg, err := config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+
+		l = test_util.NewTestZapLogger("registration-test")
+		r = registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+
+		register = mbus.RegisterHandler(r, l)
+		unregister = mbus.UnregisterHandler(r, l)
+	})
+
+	post := func(handler http.Handler, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/routes/register", strings.NewReader(body)))
+		return rw
+	}
+
+	It("registers and unregisters the routes of a message", func() {
+		msg := `{"host": "10.0.0.1", "port": 61000, "uris": ["http.example.com"], "stale_threshold_in_seconds": 30}`
+
+		rw := post(register, msg)
+		Expect(rw.Code).To(Equal(http.StatusNoContent))
+		Expect(r.Lookup("http.example.com")).NotTo(BeNil())
+
+		rw = post(unregister, msg)
+		Expect(rw.Code).To(Equal(http.StatusNoContent))
+		Expect(r.Lookup("http.example.com")).To(BeNil())
+	})
+
+	It("rejects an invalid message", func() {
+		rw := post(register, `{"host": "10.0.0.1", "uris": ["http.example.com"]}`)
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+		Expect(rw.Body.String()).To(ContainSubstring("port or tls_port must be set"))
+		Expect(r.Lookup("http.example.com")).To(BeNil())
+	})
+
+	It("rejects a malformed message", func() {
+		rw := post(register, `{"host": `)
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("only accepts POST", func() {
+		rw := httptest.NewRecorder()
+		register.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/routes/register", nil))
+		Expect(rw.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,221 @@
+package mbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+)
+
+// EndpointIterator is implemented by registries whose routing table can be
+// walked endpoint by endpoint.
+type EndpointIterator interface {
+	EachEndpoint(f func(uri route.Uri, endpoint *route.Endpoint))
+}
+
+// RouteSnapshotter periodically writes the routing table to a file as a list
+// of RegistryMessages, in the format read by PreloadRoutes. A final snapshot is
+// written when the process is signaled to exit.
+type RouteSnapshotter struct {
+	registry EndpointIterator
+	path     string
+	interval time.Duration
+	logger   logger.Logger
+}
+
+// NewRouteSnapshotter returns a new RouteSnapshotter
+func NewRouteSnapshotter(registry EndpointIterator, path string, interval time.Duration, l logger.Logger) *RouteSnapshotter {
+	return &RouteSnapshotter{
+		registry: registry,
+		path:     path,
+		interval: interval,
+		logger:   l,
+	}
+}
+
+// Run manages the lifecycle of the snapshotter process
+func (s *RouteSnapshotter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	close(ready)
+	s.logger.Info("route-snapshotter-started", zap.String("path", s.path))
+
+	for {
+		select {
+		case <-ticker.C:
+			s.snapshot()
+		case <-signals:
+			s.snapshot()
+			s.logger.Info("exited")
+			return nil
+		}
+	}
+}
+
+func (s *RouteSnapshotter) snapshot() {
+	err := s.WriteSnapshot()
+	if err != nil {
+		s.logger.Error("failed-to-write-route-snapshot", zap.Error(err))
+	}
+}
+
+// WriteSnapshot serializes the routing table and atomically replaces the
+// snapshot file with it.
+func (s *RouteSnapshotter) WriteSnapshot() error {
+	msgs := s.registryMessages()
+
+	data, err := json.Marshal(msgs)
+	if err != nil {
+		return fmt.Errorf("snapshot: marshaling routes: %s", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("snapshot: creating temp file: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot: writing %s: %s", tmp.Name(), err)
+	}
+
+	err = os.Rename(tmp.Name(), s.path)
+	if err != nil {
+		return fmt.Errorf("snapshot: renaming to %s: %s", s.path, err)
+	}
+
+	s.logger.Debug("route-snapshot-written", zap.Int("endpoints", len(msgs)))
+	return nil
+}
+
+func (s *RouteSnapshotter) registryMessages() []*RegistryMessage {
+	msgs := []*RegistryMessage{}
+	byEndpoint := map[*route.Endpoint]*RegistryMessage{}
+
+	s.registry.EachEndpoint(func(uri route.Uri, endpoint *route.Endpoint) {
+		if msg, ok := byEndpoint[endpoint]; ok {
+			msg.Uris = append(msg.Uris, uri)
+			return
+		}
+
+		msg, err := newRegistryMessage(endpoint)
+		if err != nil {
+			s.logger.Error("skipping-endpoint-in-snapshot",
+				zap.Error(err),
+				zap.String("uri", uri.String()),
+			)
+			return
+		}
+		msg.Uris = []route.Uri{uri}
+		byEndpoint[endpoint] = msg
+		msgs = append(msgs, msg)
+	})
+
+	return msgs
+}
+
+// LoadRouteSnapshot registers the routes of the snapshot at path with the
+// route registry, so that a restarted router can serve them before NATS
+// registrations repopulate the registry. Snapshots last written more than
+// maxAge ago are ignored, since their backends may have moved since, unless
+// maxAge is zero. A missing snapshot is not an error.
+func LoadRouteSnapshot(path string, maxAge time.Duration, routeRegistry registry.Registry, l logger.Logger) (int, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("snapshot: reading %s: %s", path, err)
+	}
+	if age := time.Since(info.ModTime()); maxAge > 0 && age > maxAge {
+		l.Info("route-snapshot-too-old", zap.String("path", path), zap.Duration("age", age))
+		return 0, nil
+	}
+
+	return PreloadRoutes(path, routeRegistry, l)
+}
+
+func newRegistryMessage(endpoint *route.Endpoint) (*RegistryMessage, error) {
+	host, portStr, err := net.SplitHostPort(endpoint.CanonicalAddr())
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &RegistryMessage{
+		Host:                    host,
+		Tags:                    endpoint.Tags,
+		App:                     endpoint.ApplicationId,
+		StaleThresholdInSeconds: int(endpoint.StaleThreshold.Seconds()),
+		RouteServiceURL:         endpoint.RouteServiceUrl,
+		PrivateInstanceID:       endpoint.PrivateInstanceId,
+		ServerCertDomainSAN:     endpoint.ServerCertDomainSAN,
+		PrivateInstanceIndex:    endpoint.PrivateInstanceIndex,
+		IsolationSegment:        endpoint.IsolationSegment,
+		BackendClientCertName:   endpoint.ClientCertName,
+		Protocol:                endpoint.Protocol,
+		ProxyProtocol:           endpoint.ProxyProtocol,
+		ForwardedClientCert:     endpoint.ForwardedClientCert,
+		AllowedClientCertSANs:   endpoint.AllowedClientCerts.SANs,
+		AllowedClientCertOUs:    endpoint.AllowedClientCerts.OUs,
+		JWTValidation:           endpoint.JWTValidation,
+		JWTAudiences:            endpoint.JWTAudiences,
+		RateLimitPerSecond:      endpoint.RateLimit.PerSecond,
+		RateLimitBurst:          endpoint.RateLimit.Burst,
+		MaxRequestBodyBytes:     endpoint.MaxRequestBodyBytes,
+		CORSAllowedOrigins:      endpoint.CORS.AllowedOrigins,
+		CORSAllowedMethods:      endpoint.CORS.AllowedMethods,
+		CORSAllowedHeaders:      endpoint.CORS.AllowedHeaders,
+		CORSMaxAgeInSeconds:     int(endpoint.CORS.MaxAge.Seconds()),
+		HostRewrite:             endpoint.HostRewrite,
+		StripPathPrefix:         endpoint.StripPathPrefix,
+		RewritePath:             endpoint.RewritePath,
+		MatchMethods:            endpoint.Match.Methods,
+		MatchHeaders:            endpoint.Match.Headers,
+		EndpointGroup:           endpoint.Group,
+		EndpointGroupPercent:    endpoint.GroupPercent,
+		MirrorHost:              endpoint.MirrorHost,
+		MirrorPercent:           endpoint.MirrorPercent,
+		Weight:                  endpoint.Weight,
+		BalancingAlgorithm:      endpoint.BalancingAlgorithm,
+		AvailabilityZone:        endpoint.AvailabilityZone,
+		TimeoutInSeconds:        int(endpoint.Timeout.Seconds()),
+	}
+	for _, ipNet := range endpoint.ClientIPAccess.Allow {
+		msg.AllowedClientCIDRs = append(msg.AllowedClientCIDRs, ipNet.String())
+	}
+	for _, ipNet := range endpoint.ClientIPAccess.Deny {
+		msg.DeniedClientCIDRs = append(msg.DeniedClientCIDRs, ipNet.String())
+	}
+	if endpoint.IsTLS() {
+		msg.TLSPort = uint16(port)
+	} else {
+		msg.Port = uint16(port)
+	}
+	if !endpoint.UpdatedAt.IsZero() {
+		msg.EndpointUpdatedAtNs = endpoint.UpdatedAt.UnixNano()
+	}
+
+	return msg, nil
+}
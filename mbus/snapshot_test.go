@@ -0,0 +1,313 @@
+package mbus_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/mbus"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("RouteSnapshotter", func() {
+	var (
+		r           *registry.RouteRegistry
+		l           logger.Logger
+		tmpDir      string
+		path        string
+		snapshotter *mbus.RouteSnapshotter
+	)
+
+	readSnapshot := func() []mbus.RegistryMessage {
+		data, err := ioutil.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		var msgs []mbus.RegistryMessage
+		Expect(json.Unmarshal(data, &msgs)).To(Succeed())
+		return msgs
+	}
+
+	BeforeEach(func() {
+		cfg, err := config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+
+		l = test_util.NewTestZapLogger("snapshot-test")
+		r = registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+
+		tmpDir, err = ioutil.TempDir("", "snapshot")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(tmpDir, "routes.json")
+
+		endpoint := route.NewEndpoint(&route.EndpointOpts{
+			AppId:                   "app-guid",
+			Host:                    "10.0.0.1",
+			Port:                    61001,
+			UseTLS:                  true,
+			ServerCertDomainSAN:     "instance-guid",
+			PrivateInstanceId:       "instance-guid",
+			PrivateInstanceIndex:    "2",
+			StaleThresholdInSeconds: 120,
+			Tags:                    map[string]string{"component": "app"},
+		})
+		r.Register("snapshot.example.com", endpoint)
+		r.Register("snapshot.example.com/path", endpoint)
+		r.Register("other.example.com", route.NewEndpoint(&route.EndpointOpts{
+			Host: "10.0.0.2",
+			Port: 61000,
+		}))
+
+		snapshotter = mbus.NewRouteSnapshotter(r, path, 20*time.Millisecond, l)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("writes the registered routes as registry messages", func() {
+		Expect(snapshotter.WriteSnapshot()).To(Succeed())
+
+		msgs := readSnapshot()
+		Expect(msgs).To(HaveLen(2))
+
+		var tlsMsg mbus.RegistryMessage
+		for _, m := range msgs {
+			if m.Host == "10.0.0.1" {
+				tlsMsg = m
+			}
+		}
+		Expect(tlsMsg.TLSPort).To(Equal(uint16(61001)))
+		Expect(tlsMsg.Port).To(BeZero())
+		Expect(tlsMsg.App).To(Equal("app-guid"))
+		Expect(tlsMsg.ServerCertDomainSAN).To(Equal("instance-guid"))
+		Expect(tlsMsg.PrivateInstanceIndex).To(Equal("2"))
+		Expect(tlsMsg.StaleThresholdInSeconds).To(Equal(120))
+		Expect(tlsMsg.Tags).To(HaveKeyWithValue("component", "app"))
+		Expect(tlsMsg.Uris).To(ConsistOf(route.Uri("snapshot.example.com"), route.Uri("snapshot.example.com/path")))
+	})
+
+	It("does not leave temporary files behind", func() {
+		Expect(snapshotter.WriteSnapshot()).To(Succeed())
+
+		files, err := ioutil.ReadDir(tmpDir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(files[0].Name()).To(Equal("routes.json"))
+	})
+
+	It("can be loaded back with PreloadRoutes", func() {
+		Expect(snapshotter.WriteSnapshot()).To(Succeed())
+
+		cfg, err := config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+		fresh := registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+
+		registered, err := mbus.PreloadRoutes(path, fresh, l)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(registered).To(Equal(2))
+		Expect(fresh.Lookup("snapshot.example.com/path")).ToNot(BeNil())
+		Expect(fresh.Lookup("other.example.com")).ToNot(BeNil())
+	})
+
+	It("keeps the options the endpoints registered", func() {
+		_, ipNet, err := net.ParseCIDR("10.0.0.0/8")
+		Expect(err).ToNot(HaveOccurred())
+		r.Register("options.example.com", route.NewEndpoint(&route.EndpointOpts{
+			Host:               "10.0.0.3",
+			Port:               61000,
+			ProxyProtocol:      "v2",
+			AllowedClientNets:  []*net.IPNet{ipNet},
+			RateLimitPerSecond: 10,
+			HostRewrite:        "legacy.internal",
+			MatchMethods:       []string{"GET"},
+			Group:              "canary",
+			GroupPercent:       5,
+			MirrorHost:         "mirror.example.com",
+			MirrorPercent:      10,
+			TimeoutInSeconds:   30,
+		}))
+		Expect(snapshotter.WriteSnapshot()).To(Succeed())
+
+		var msg mbus.RegistryMessage
+		for _, m := range readSnapshot() {
+			if m.Host == "10.0.0.3" {
+				msg = m
+			}
+		}
+		Expect(msg.ProxyProtocol).To(Equal("v2"))
+		Expect(msg.AllowedClientCIDRs).To(Equal([]string{"10.0.0.0/8"}))
+		Expect(msg.RateLimitPerSecond).To(Equal(10.0))
+		Expect(msg.HostRewrite).To(Equal("legacy.internal"))
+		Expect(msg.MatchMethods).To(Equal([]string{"GET"}))
+		Expect(msg.EndpointGroup).To(Equal("canary"))
+		Expect(msg.EndpointGroupPercent).To(Equal(5))
+		Expect(msg.MirrorHost).To(Equal("mirror.example.com"))
+		Expect(msg.MirrorPercent).To(Equal(10))
+		Expect(msg.TimeoutInSeconds).To(Equal(30))
+	})
+
+	It("restores every option the endpoints registered when loaded back", func() {
+		_, allowed, err := net.ParseCIDR("10.0.0.0/8")
+		Expect(err).ToNot(HaveOccurred())
+		_, denied, err := net.ParseCIDR("10.1.0.0/16")
+		Expect(err).ToNot(HaveOccurred())
+		r.Register("options.example.com", route.NewEndpoint(&route.EndpointOpts{
+			Host:                  "10.0.0.3",
+			Port:                  61000,
+			RouteServiceUrl:       "https://rs.example.com",
+			IsolationSegment:      "segment",
+			ClientCertName:        "backend-cert",
+			Protocol:              "http2",
+			ProxyProtocol:         "v1",
+			ForwardedClientCert:   "forward",
+			AllowedClientCertSANs: []string{"client.example.com"},
+			AllowedClientCertOUs:  []string{"ops"},
+			AllowedClientNets:     []*net.IPNet{allowed},
+			DeniedClientNets:      []*net.IPNet{denied},
+			JWTValidation:         "required",
+			JWTAudiences:          []string{"api"},
+			RateLimitPerSecond:    10,
+			RateLimitBurst:        20,
+			MaxRequestBodyBytes:   1024,
+			CORSAllowedOrigins:    []string{"https://app.example.com"},
+			CORSAllowedMethods:    []string{"PUT"},
+			CORSAllowedHeaders:    []string{"X-Api-Key"},
+			CORSMaxAgeInSeconds:   600,
+			HostRewrite:           "legacy.internal",
+			StripPathPrefix:       true,
+			RewritePath:           "/v2",
+			MatchMethods:          []string{"GET"},
+			MatchHeaders:          map[string]string{"X-Canary": "true"},
+			Group:                 "canary",
+			GroupPercent:          5,
+			MirrorHost:            "mirror.example.com",
+			MirrorPercent:         10,
+			Weight:                3,
+			BalancingAlgorithm:    "least-connection",
+			AvailabilityZone:      "z1",
+			TimeoutInSeconds:      30,
+		}))
+		Expect(snapshotter.WriteSnapshot()).To(Succeed())
+
+		cfg, err := config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+		fresh := registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+		_, err = mbus.PreloadRoutes(path, fresh, l)
+		Expect(err).ToNot(HaveOccurred())
+
+		var endpoint *route.Endpoint
+		fresh.Lookup("options.example.com").Each(func(e *route.Endpoint) {
+			endpoint = e
+		})
+		Expect(endpoint).ToNot(BeNil())
+		Expect(endpoint.RouteServiceUrl).To(Equal("https://rs.example.com"))
+		Expect(endpoint.IsolationSegment).To(Equal("segment"))
+		Expect(endpoint.ClientCertName).To(Equal("backend-cert"))
+		Expect(endpoint.Protocol).To(Equal("http2"))
+		Expect(endpoint.ProxyProtocol).To(Equal("v1"))
+		Expect(endpoint.ForwardedClientCert).To(Equal("forward"))
+		Expect(endpoint.AllowedClientCerts.SANs).To(Equal([]string{"client.example.com"}))
+		Expect(endpoint.AllowedClientCerts.OUs).To(Equal([]string{"ops"}))
+		Expect(endpoint.ClientIPAccess.Allow).To(Equal([]*net.IPNet{allowed}))
+		Expect(endpoint.ClientIPAccess.Deny).To(Equal([]*net.IPNet{denied}))
+		Expect(endpoint.JWTValidation).To(Equal("required"))
+		Expect(endpoint.JWTAudiences).To(Equal([]string{"api"}))
+		Expect(endpoint.RateLimit).To(Equal(route.RateLimit{PerSecond: 10, Burst: 20}))
+		Expect(endpoint.MaxRequestBodyBytes).To(Equal(int64(1024)))
+		Expect(endpoint.CORS.AllowedOrigins).To(Equal([]string{"https://app.example.com"}))
+		Expect(endpoint.CORS.AllowedMethods).To(Equal([]string{"PUT"}))
+		Expect(endpoint.CORS.AllowedHeaders).To(Equal([]string{"X-Api-Key"}))
+		Expect(endpoint.CORS.MaxAge).To(Equal(10 * time.Minute))
+		Expect(endpoint.HostRewrite).To(Equal("legacy.internal"))
+		Expect(endpoint.StripPathPrefix).To(BeTrue())
+		Expect(endpoint.RewritePath).To(Equal("/v2"))
+		Expect(endpoint.Match.Methods).To(Equal([]string{"GET"}))
+		Expect(endpoint.Match.Headers).To(Equal(map[string]string{"X-Canary": "true"}))
+		Expect(endpoint.Group).To(Equal("canary"))
+		Expect(endpoint.GroupPercent).To(Equal(5))
+		Expect(endpoint.MirrorHost).To(Equal("mirror.example.com"))
+		Expect(endpoint.MirrorPercent).To(Equal(10))
+		Expect(endpoint.Weight).To(Equal(3))
+		Expect(endpoint.BalancingAlgorithm).To(Equal("least-connection"))
+		Expect(endpoint.AvailabilityZone).To(Equal("z1"))
+		Expect(endpoint.Timeout).To(Equal(30 * time.Second))
+		Expect(endpoint.Provisional).To(BeTrue())
+	})
+
+	Describe("LoadRouteSnapshot", func() {
+		var fresh *registry.RouteRegistry
+
+		BeforeEach(func() {
+			cfg, err := config.DefaultConfig()
+			Expect(err).ToNot(HaveOccurred())
+			fresh = registry.NewRouteRegistry(l, cfg, new(fakes.FakeRouteRegistryReporter))
+		})
+
+		It("registers the routes of a recent snapshot", func() {
+			Expect(snapshotter.WriteSnapshot()).To(Succeed())
+
+			loaded, err := mbus.LoadRouteSnapshot(path, time.Minute, fresh, l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded).To(Equal(2))
+			Expect(fresh.Lookup("snapshot.example.com")).ToNot(BeNil())
+		})
+
+		It("ignores a snapshot written longer than the max age ago", func() {
+			Expect(snapshotter.WriteSnapshot()).To(Succeed())
+			old := time.Now().Add(-time.Hour)
+			Expect(os.Chtimes(path, old, old)).To(Succeed())
+
+			loaded, err := mbus.LoadRouteSnapshot(path, time.Minute, fresh, l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded).To(BeZero())
+			Expect(fresh.Lookup("snapshot.example.com")).To(BeNil())
+		})
+
+		It("loads nothing when there is no snapshot", func() {
+			loaded, err := mbus.LoadRouteSnapshot(path, time.Minute, fresh, l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded).To(BeZero())
+		})
+	})
+
+	It("writes the snapshot on the interval", func() {
+		process := ifrit.Invoke(snapshotter)
+		defer func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		}()
+
+		Eventually(func() error {
+			_, err := os.Stat(path)
+			return err
+		}).Should(Succeed())
+		Expect(readSnapshot()).To(HaveLen(2))
+	})
+
+	It("writes a final snapshot when signaled", func() {
+		snapshotter = mbus.NewRouteSnapshotter(r, path, time.Hour, l)
+		process := ifrit.Invoke(snapshotter)
+		Consistently(func() error {
+			_, err := os.Stat(path)
+			return err
+		}, 50*time.Millisecond).ShouldNot(Succeed())
+
+		process.Signal(os.Interrupt)
+		var err error
+		Eventually(process.Wait()).Should(Receive(&err))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readSnapshot()).To(HaveLen(2))
+	})
+})
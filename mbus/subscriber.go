@@ -1,17 +1,22 @@
 package mbus
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/gorouter/common"
 	"code.cloudfoundry.org/gorouter/common/uuid"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/localip"
@@ -39,6 +44,36 @@ type RegistryMessage struct {
 	PrivateInstanceIndex    string            `json:"private_instance_index"`
 	IsolationSegment        string            `json:"isolation_segment"`
 	EndpointUpdatedAtNs     int64             `json:"endpoint_updated_at_ns"`
+	BackendClientCertName   string            `json:"backend_client_cert_name"`
+	Protocol                string            `json:"protocol"`
+	ProxyProtocol           string            `json:"proxy_protocol"`
+	ForwardedClientCert     string            `json:"forwarded_client_cert"`
+	AllowedClientCertSANs   []string          `json:"allowed_client_cert_sans"`
+	AllowedClientCertOUs    []string          `json:"allowed_client_cert_ous"`
+	AllowedClientCIDRs      []string          `json:"allowed_client_cidrs"`
+	DeniedClientCIDRs       []string          `json:"denied_client_cidrs"`
+	JWTValidation           string            `json:"jwt_validation"`
+	JWTAudiences            []string          `json:"jwt_audiences"`
+	RateLimitPerSecond      float64           `json:"rate_limit_per_second"`
+	RateLimitBurst          int               `json:"rate_limit_burst"`
+	MaxRequestBodyBytes     int64             `json:"max_request_body_bytes"`
+	CORSAllowedOrigins      []string          `json:"cors_allowed_origins"`
+	CORSAllowedMethods      []string          `json:"cors_allowed_methods"`
+	CORSAllowedHeaders      []string          `json:"cors_allowed_headers"`
+	CORSMaxAgeInSeconds     int               `json:"cors_max_age_in_seconds"`
+	HostRewrite             string            `json:"host_rewrite"`
+	StripPathPrefix         bool              `json:"strip_path_prefix"`
+	RewritePath             string            `json:"rewrite_path"`
+	MatchMethods            []string          `json:"match_methods"`
+	MatchHeaders            map[string]string `json:"match_headers"`
+	EndpointGroup           string            `json:"endpoint_group"`
+	EndpointGroupPercent    int               `json:"endpoint_group_percent"`
+	MirrorHost              string            `json:"mirror_host"`
+	MirrorPercent           int               `json:"mirror_percent"`
+	Weight                  int               `json:"weight"`
+	BalancingAlgorithm      string            `json:"balancing_algorithm"`
+	AvailabilityZone        string            `json:"availability_zone"`
+	TimeoutInSeconds        int               `json:"timeout_in_seconds"`
 }
 
 func (rm *RegistryMessage) makeEndpoint() (*route.Endpoint, error) {
@@ -50,6 +85,14 @@ func (rm *RegistryMessage) makeEndpoint() (*route.Endpoint, error) {
 	if rm.EndpointUpdatedAtNs != 0 {
 		updatedAt = time.Unix(0, rm.EndpointUpdatedAtNs).UTC()
 	}
+	allowedClientNets, err := parseCIDRs(rm.AllowedClientCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowed_client_cidrs: %s", err)
+	}
+	deniedClientNets, err := parseCIDRs(rm.DeniedClientCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid denied_client_cidrs: %s", err)
+	}
 
 	return route.NewEndpoint(&route.EndpointOpts{
 		AppId:                   rm.App,
@@ -65,6 +108,36 @@ func (rm *RegistryMessage) makeEndpoint() (*route.Endpoint, error) {
 		IsolationSegment:        rm.IsolationSegment,
 		UseTLS:                  useTLS,
 		UpdatedAt:               updatedAt,
+		ClientCertName:          rm.BackendClientCertName,
+		Protocol:                rm.Protocol,
+		ProxyProtocol:           rm.ProxyProtocol,
+		ForwardedClientCert:     rm.ForwardedClientCert,
+		AllowedClientCertSANs:   rm.AllowedClientCertSANs,
+		AllowedClientCertOUs:    rm.AllowedClientCertOUs,
+		AllowedClientNets:       allowedClientNets,
+		DeniedClientNets:        deniedClientNets,
+		JWTValidation:           rm.JWTValidation,
+		JWTAudiences:            rm.JWTAudiences,
+		RateLimitPerSecond:      rm.RateLimitPerSecond,
+		RateLimitBurst:          rm.RateLimitBurst,
+		MaxRequestBodyBytes:     rm.MaxRequestBodyBytes,
+		CORSAllowedOrigins:      rm.CORSAllowedOrigins,
+		CORSAllowedMethods:      rm.CORSAllowedMethods,
+		CORSAllowedHeaders:      rm.CORSAllowedHeaders,
+		CORSMaxAgeInSeconds:     rm.CORSMaxAgeInSeconds,
+		HostRewrite:             rm.HostRewrite,
+		StripPathPrefix:         rm.StripPathPrefix,
+		RewritePath:             rm.RewritePath,
+		MatchMethods:            rm.MatchMethods,
+		MatchHeaders:            rm.MatchHeaders,
+		Group:                   rm.EndpointGroup,
+		GroupPercent:            rm.EndpointGroupPercent,
+		MirrorHost:              rm.MirrorHost,
+		MirrorPercent:           rm.MirrorPercent,
+		Weight:                  rm.Weight,
+		BalancingAlgorithm:      rm.BalancingAlgorithm,
+		AvailabilityZone:        rm.AvailabilityZone,
+		TimeoutInSeconds:        rm.TimeoutInSeconds,
 	}), nil
 }
 
@@ -73,6 +146,200 @@ func (rm *RegistryMessage) ValidateMessage() bool {
 	return rm.RouteServiceURL == "" || strings.HasPrefix(rm.RouteServiceURL, "https")
 }
 
+// validateRegistration checks that a registration would create an endpoint
+// that can be routed to.
+func (rm *RegistryMessage) validateRegistration() error {
+	if rm.Host == "" {
+		return errors.New("host must not be empty")
+	}
+	if rm.Port == 0 && rm.TLSPort == 0 {
+		return errors.New("port or tls_port must be set")
+	}
+	if len(rm.Uris) == 0 {
+		return errors.New("uris must not be empty")
+	}
+	for _, uri := range rm.Uris {
+		if !validURI(string(uri)) {
+			return fmt.Errorf("invalid uri: %q", uri)
+		}
+	}
+	switch rm.Protocol {
+	case "", route.ProtocolHTTP1, route.ProtocolHTTP2:
+	default:
+		return fmt.Errorf("invalid protocol: %q", rm.Protocol)
+	}
+	switch rm.ProxyProtocol {
+	case "", route.ProxyProtocolNone, route.ProxyProtocolV1, route.ProxyProtocolV2:
+	default:
+		return fmt.Errorf("invalid proxy_protocol: %q", rm.ProxyProtocol)
+	}
+	if rm.ForwardedClientCert != "" && !validForwardedClientCert(rm.ForwardedClientCert) {
+		return fmt.Errorf("invalid forwarded_client_cert: %q", rm.ForwardedClientCert)
+	}
+	for _, san := range rm.AllowedClientCertSANs {
+		if san == "" {
+			return errors.New("allowed_client_cert_sans must not contain empty values")
+		}
+	}
+	for _, ou := range rm.AllowedClientCertOUs {
+		if ou == "" {
+			return errors.New("allowed_client_cert_ous must not contain empty values")
+		}
+	}
+	switch rm.JWTValidation {
+	case "", route.JWTValidationRequired, route.JWTValidationNone:
+	default:
+		return fmt.Errorf("invalid jwt_validation: %q", rm.JWTValidation)
+	}
+	if rm.RateLimitPerSecond < 0 {
+		return errors.New("rate_limit_per_second must not be negative")
+	}
+	if rm.RateLimitBurst < 0 {
+		return errors.New("rate_limit_burst must not be negative")
+	}
+	if rm.MaxRequestBodyBytes < 0 {
+		return errors.New("max_request_body_bytes must not be negative")
+	}
+	for _, origin := range rm.CORSAllowedOrigins {
+		if origin == "" {
+			return errors.New("cors_allowed_origins must not contain empty values")
+		}
+	}
+	if len(rm.CORSAllowedOrigins) == 0 && (len(rm.CORSAllowedMethods) > 0 || len(rm.CORSAllowedHeaders) > 0) {
+		return errors.New("cors_allowed_origins must be set if cors_allowed_methods or cors_allowed_headers are")
+	}
+	if rm.CORSMaxAgeInSeconds < 0 {
+		return errors.New("cors_max_age_in_seconds must not be negative")
+	}
+	if rm.HostRewrite != "" && !validHost(rm.HostRewrite) {
+		return fmt.Errorf("invalid host_rewrite: %q", rm.HostRewrite)
+	}
+	if rm.RewritePath != "" && (!strings.HasPrefix(rm.RewritePath, "/") || strings.ContainsAny(rm.RewritePath, "?#")) {
+		return fmt.Errorf("invalid rewrite_path: %q", rm.RewritePath)
+	}
+	if rm.StripPathPrefix && rm.RewritePath != "" {
+		return errors.New("strip_path_prefix and rewrite_path must not both be set")
+	}
+	for _, method := range rm.MatchMethods {
+		if !validToken(method) {
+			return fmt.Errorf("invalid match_methods: %q", method)
+		}
+	}
+	for name := range rm.MatchHeaders {
+		if !validToken(name) {
+			return fmt.Errorf("invalid match_headers name: %q", name)
+		}
+	}
+	if rm.EndpointGroupPercent < 0 || rm.EndpointGroupPercent > 100 {
+		return errors.New("endpoint_group_percent must be between 0 and 100")
+	}
+	if rm.EndpointGroupPercent > 0 && rm.EndpointGroup == "" {
+		return errors.New("endpoint_group must be set if endpoint_group_percent is")
+	}
+	if rm.MirrorHost != "" && !validHost(rm.MirrorHost) {
+		return fmt.Errorf("invalid mirror_host: %q", rm.MirrorHost)
+	}
+	if rm.MirrorPercent < 0 || rm.MirrorPercent > 100 {
+		return errors.New("mirror_percent must be between 0 and 100")
+	}
+	if rm.MirrorPercent > 0 && rm.MirrorHost == "" {
+		return errors.New("mirror_host must be set if mirror_percent is")
+	}
+	if rm.Weight < 0 {
+		return errors.New("weight must not be negative")
+	}
+	if rm.BalancingAlgorithm != "" && !validBalancingAlgorithm(rm.BalancingAlgorithm) {
+		return fmt.Errorf("invalid balancing_algorithm: %q", rm.BalancingAlgorithm)
+	}
+	if rm.TimeoutInSeconds < 0 {
+		return errors.New("timeout_in_seconds must not be negative")
+	}
+	return nil
+}
+
+// parseCIDRs parses the client CIDRs of a registration. Unlike the other
+// fields, they are always checked, since dropping an invalid entry from an
+// allowlist could open a route to every client.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func validBalancingAlgorithm(algorithm string) bool {
+	for _, lb := range config.LoadBalancingStrategies {
+		if algorithm == lb {
+			return true
+		}
+	}
+	return false
+}
+
+func validForwardedClientCert(mode string) bool {
+	for _, m := range config.AllowedForwardedClientCertModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// validURI reports whether uri is non-empty and only contains characters
+// allowed in a URI by RFC 3986.
+func validURI(uri string) bool {
+	if uri == "" {
+		return false
+	}
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._~:/?#[]@!$&'()*+,;=%", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validHost reports whether host is a host name or IP address, optionally
+// with a port, that can be sent in a Host header.
+func validHost(host string) bool {
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._:[]", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validToken reports whether s is a token, such as a method or header name.
+func validToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // Prefer TLS Port instead of HTTP Port in Registrty Message
 func (rm *RegistryMessage) port() (uint16, bool, error) {
 	if rm.TLSPort != 0 {
@@ -83,11 +350,31 @@ func (rm *RegistryMessage) port() (uint16, bool, error) {
 
 // Subscriber subscribes to NATS for all router.* messages and handles them
 type Subscriber struct {
-	mbusClient       Client
-	routeRegistry    registry.Registry
-	subscription     *nats.Subscription
-	reconnected      <-chan Signal
-	natsPendingLimit int
+	mbusClient        Client
+	routeRegistry     registry.Registry
+	subscription      *nats.Subscription
+	greetSubscription *nats.Subscription
+	reconnected       <-chan Signal
+	natsPendingLimit  int
+
+	// clientLock guards the client and subscriptions, which change when
+	// failing over to another NATS cluster. It is a pointer since the
+	// generated easyjson methods take Subscriber by value.
+	clientLock *sync.RWMutex
+	clients    <-chan Client
+
+	reporter            metrics.SubscriberReporter
+	maxRegistrationRate int
+	registrationLimiter *rateLimiter
+
+	backendClientCerts map[string]tls.Certificate
+
+	validateRegistrations bool
+
+	jetStream config.NatsJetStreamConfig
+
+	shedCount       int64
+	lastShedWarning int64
 
 	params startMessageParams
 
@@ -106,6 +393,7 @@ func NewSubscriber(
 	routeRegistry registry.Registry,
 	c *config.Config,
 	reconnected <-chan Signal,
+	reporter metrics.SubscriberReporter,
 	l logger.Logger,
 ) *Subscriber {
 	guid, err := uuid.GenerateUUID()
@@ -113,8 +401,14 @@ func NewSubscriber(
 		l.Fatal("failed-to-generate-uuid", zap.Error(err))
 	}
 
+	var limiter *rateLimiter
+	if c.NatsMaxRegistrationRate > 0 {
+		limiter = newRateLimiter(c.NatsMaxRegistrationRate)
+	}
+
 	return &Subscriber{
 		mbusClient:    mbusClient,
+		clientLock:    new(sync.RWMutex),
 		routeRegistry: routeRegistry,
 		params: startMessageParams{
 			id:                               fmt.Sprintf("%d-%s", c.Index, guid),
@@ -124,6 +418,16 @@ func NewSubscriber(
 		reconnected:      reconnected,
 		natsPendingLimit: c.NatsClientMessageBufferSize,
 		logger:           l,
+
+		reporter:            reporter,
+		maxRegistrationRate: c.NatsMaxRegistrationRate,
+		registrationLimiter: limiter,
+
+		backendClientCerts: c.Backends.NamedClientAuthCertificates,
+
+		validateRegistrations: c.ValidateRegistrationMessages,
+
+		jetStream: c.NatsJetStream,
 	}
 }
 
@@ -137,11 +441,7 @@ func (s *Subscriber) Run(signals <-chan os.Signal, ready chan<- struct{}) error
 	if err != nil {
 		return err
 	}
-	err = s.subscribeToGreetMessage()
-	if err != nil {
-		return err
-	}
-	s.subscription, err = s.subscribeRoutes()
+	err = s.subscribe()
 	if err != nil {
 		return err
 	}
@@ -156,6 +456,18 @@ func (s *Subscriber) Run(signals <-chan os.Signal, ready chan<- struct{}) error
 			if err != nil {
 				s.logger.Error("failed-to-send-start-message", zap.Error(err))
 			}
+			if s.jetStream.Stream != "" {
+				// the consumer is gone if the stream was not persisted
+				err = s.createJetStreamConsumer()
+				if err != nil {
+					s.logger.Error("failed-to-create-jetstream-consumer", zap.Error(err))
+				}
+			}
+		case client := <-s.clients:
+			err := s.switchClient(client)
+			if err != nil {
+				s.logger.Error("failed-to-switch-nats-client", zap.Error(err))
+			}
 		case <-signals:
 			s.logger.Info("exited")
 			return nil
@@ -163,7 +475,65 @@ func (s *Subscriber) Run(signals <-chan os.Signal, ready chan<- struct{}) error
 	}
 }
 
+// FollowFailover makes the subscriber move to the clients received, which a
+// Failover sends when it switches NATS clusters. It must be called before
+// the subscriber runs.
+func (s *Subscriber) FollowFailover(clients <-chan Client) {
+	s.clients = clients
+}
+
+func (s *Subscriber) client() Client {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	return s.mbusClient
+}
+
+func (s *Subscriber) subscribe() error {
+	greetSubscription, err := s.subscribeToGreetMessage()
+	if err != nil {
+		return err
+	}
+	var subscription *nats.Subscription
+	if s.jetStream.Stream != "" {
+		subscription, err = s.subscribeJetStream()
+	} else {
+		subscription, err = s.subscribeRoutes()
+	}
+	if err != nil {
+		return err
+	}
+
+	s.clientLock.Lock()
+	s.greetSubscription = greetSubscription
+	s.subscription = subscription
+	s.clientLock.Unlock()
+	return nil
+}
+
+// switchClient moves the subscriptions of the subscriber to client, and
+// announces the router to the clients of its cluster. The subscriptions on
+// the previous client are removed, so that its messages are not also handled
+// once it reconnects.
+func (s *Subscriber) switchClient(client Client) error {
+	s.clientLock.Lock()
+	for _, subscription := range []*nats.Subscription{s.greetSubscription, s.subscription} {
+		if subscription != nil {
+			_ = subscription.Unsubscribe()
+		}
+	}
+	s.mbusClient = client
+	s.clientLock.Unlock()
+
+	err := s.sendStartMessage()
+	if err != nil {
+		s.logger.Error("failed-to-send-start-message", zap.Error(err))
+	}
+	return s.subscribe()
+}
+
 func (s *Subscriber) Pending() (int, error) {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
 	if s.subscription == nil {
 		s.logger.Error("failed-to-get-subscription")
 		return -1, errors.New("NATS subscription is nil, Subscriber must be invoked")
@@ -174,6 +544,8 @@ func (s *Subscriber) Pending() (int, error) {
 }
 
 func (s *Subscriber) Dropped() (int, error) {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
 	if s.subscription == nil {
 		s.logger.Error("failed-to-get-subscription")
 		return -1, errors.New("NATS subscription is nil, Subscriber must be invoked")
@@ -183,36 +555,17 @@ func (s *Subscriber) Dropped() (int, error) {
 	return msgs, err
 }
 
-func (s *Subscriber) subscribeToGreetMessage() error {
-	_, err := s.mbusClient.Subscribe("router.greet", func(msg *nats.Msg) {
+func (s *Subscriber) subscribeToGreetMessage() (*nats.Subscription, error) {
+	return s.client().Subscribe("router.greet", func(msg *nats.Msg) {
 		response, _ := s.startMessage()
-		_ = s.mbusClient.Publish(msg.Reply, response)
+		_ = s.client().Publish(msg.Reply, response)
 	})
-
-	return err
 }
 
 func (s *Subscriber) subscribeRoutes() (*nats.Subscription, error) {
-	natsSubscription, err := s.mbusClient.Subscribe("router.*", func(message *nats.Msg) {
-		msg, regErr := createRegistryMessage(message.Data)
-		if regErr != nil {
-			s.logger.Error("validation-error",
-				zap.Error(regErr),
-				zap.String("payload", string(message.Data)),
-				zap.String("subject", message.Subject),
-			)
-			return
-		}
-		switch message.Subject {
-		case "router.register":
-			s.registerEndpoint(msg)
-		case "router.unregister":
-			s.unregisterEndpoint(msg)
-			s.logger.Info("unregister-route", zap.String("message", string(message.Data)))
-		default:
-		}
+	natsSubscription, err := s.client().Subscribe("router.*", func(message *nats.Msg) {
+		s.handleMessage(message)
 	})
-
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +578,76 @@ func (s *Subscriber) subscribeRoutes() (*nats.Subscription, error) {
 	return natsSubscription, nil
 }
 
+// handleMessage registers or unregisters the endpoint of a message, and
+// reports whether it handled the message, which it did unless the message was
+// shed by the maximum registration rate. Unregistrations are never shed, so
+// that routes to endpoints that are gone are removed even during a flood of
+// registrations. Malformed and invalid messages are handled by dropping them.
+func (s *Subscriber) handleMessage(message *nats.Msg) bool {
+	msg, regErr := createRegistryMessage(message.Data)
+	if regErr != nil {
+		if _, malformed := regErr.(*malformedMessageError); malformed && isRegistrationSubject(message.Subject) {
+			s.reporter.CaptureMalformedRegistrationMessage()
+			s.logger.Warn("malformed-registration-message",
+				zap.Error(regErr),
+				zap.String("payload", truncatePayload(message.Data)),
+				zap.String("subject", message.Subject),
+			)
+			return true
+		}
+		s.logger.Error("validation-error",
+			zap.Error(regErr),
+			zap.String("payload", string(message.Data)),
+			zap.String("subject", message.Subject),
+		)
+		return true
+	}
+	if message.Subject == "router.register" && s.registrationLimiter != nil && !s.registrationLimiter.Allow() {
+		s.shedMessage(message.Subject)
+		return false
+	}
+	switch message.Subject {
+	case "router.register":
+		s.registerEndpoint(msg)
+	case "router.unregister":
+		s.unregisterEndpoint(msg)
+		s.logger.Info("unregister-route", zap.String("message", string(message.Data)))
+	default:
+	}
+	return true
+}
+
+// shedMessage records a registration message dropped because the configured
+// maximum registration rate was exceeded. The warning is logged at most once
+// per second so that a flood of messages does not also flood the logs.
+func (s *Subscriber) shedMessage(subject string) {
+	s.reporter.CaptureRegistrationMessageShed()
+	atomic.AddInt64(&s.shedCount, 1)
+
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&s.lastShedWarning)
+	if now-last < int64(time.Second) || !atomic.CompareAndSwapInt64(&s.lastShedWarning, last, now) {
+		return
+	}
+	s.logger.Warn("registration-message-rate-exceeded",
+		zap.String("subject", subject),
+		zap.Int("max-registration-rate", s.maxRegistrationRate),
+		zap.Int64("shed-messages", atomic.SwapInt64(&s.shedCount, 0)),
+	)
+}
+
 func (s *Subscriber) registerEndpoint(msg *RegistryMessage) {
+	if s.validateRegistrations {
+		if err := msg.validateRegistration(); err != nil {
+			s.reporter.CaptureInvalidRegistrationMessage()
+			s.logger.Warn("invalid-registration-message",
+				zap.Error(err),
+				zap.Object("message", msg),
+			)
+			return
+		}
+	}
+
 	endpoint, err := msg.makeEndpoint()
 	if err != nil {
 		s.logger.Error("Unable to register route",
@@ -235,6 +657,15 @@ func (s *Subscriber) registerEndpoint(msg *RegistryMessage) {
 		return
 	}
 
+	if msg.BackendClientCertName != "" {
+		if _, ok := s.backendClientCerts[msg.BackendClientCertName]; !ok {
+			s.logger.Warn("unknown-backend-client-cert",
+				zap.String("backend-client-cert-name", msg.BackendClientCertName),
+				zap.Object("message", msg),
+			)
+		}
+	}
+
 	for _, uri := range msg.Uris {
 		s.routeRegistry.Register(uri, endpoint)
 	}
@@ -280,7 +711,7 @@ func (s *Subscriber) sendStartMessage() error {
 		return err
 	}
 	// Send start message once at start
-	return s.mbusClient.Publish("router.start", message)
+	return s.client().Publish("router.start", message)
 }
 
 func createRegistryMessage(data []byte) (*RegistryMessage, error) {
@@ -288,7 +719,7 @@ func createRegistryMessage(data []byte) (*RegistryMessage, error) {
 
 	jsonErr := easyjson.Unmarshal(data, &msg)
 	if jsonErr != nil {
-		return nil, jsonErr
+		return nil, &malformedMessageError{err: jsonErr}
 	}
 
 	if !msg.ValidateMessage() {
@@ -297,3 +728,25 @@ func createRegistryMessage(data []byte) (*RegistryMessage, error) {
 
 	return &msg, nil
 }
+
+// maxLoggedPayloadBytes bounds how much of a malformed message is logged.
+const maxLoggedPayloadBytes = 512
+
+type malformedMessageError struct {
+	err error
+}
+
+func (e *malformedMessageError) Error() string {
+	return e.err.Error()
+}
+
+func isRegistrationSubject(subject string) bool {
+	return subject == "router.register" || subject == "router.unregister"
+}
+
+func truncatePayload(data []byte) string {
+	if len(data) <= maxLoggedPayloadBytes {
+		return string(data)
+	}
+	return string(data[:maxLoggedPayloadBytes]) + "...(truncated)"
+}
@@ -220,6 +220,273 @@ func easyjson639f989aDecodeCodeCloudfoundryOrgGorouterMbus2(in *jlexer.Lexer, ou
 			out.IsolationSegment = string(in.String())
 		case "endpoint_updated_at_ns":
 			out.EndpointUpdatedAtNs = int64(in.Int64())
+		case "backend_client_cert_name":
+			out.BackendClientCertName = string(in.String())
+		case "protocol":
+			out.Protocol = string(in.String())
+		case "proxy_protocol":
+			out.ProxyProtocol = string(in.String())
+		case "forwarded_client_cert":
+			out.ForwardedClientCert = string(in.String())
+		case "allowed_client_cert_sans":
+			if in.IsNull() {
+				in.Skip()
+				out.AllowedClientCertSANs = nil
+			} else {
+				in.Delim('[')
+				if out.AllowedClientCertSANs == nil {
+					if !in.IsDelim(']') {
+						out.AllowedClientCertSANs = make([]string, 0, 4)
+					} else {
+						out.AllowedClientCertSANs = []string{}
+					}
+				} else {
+					out.AllowedClientCertSANs = (out.AllowedClientCertSANs)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v6 string
+					v6 = string(in.String())
+					out.AllowedClientCertSANs = append(out.AllowedClientCertSANs, v6)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "allowed_client_cert_ous":
+			if in.IsNull() {
+				in.Skip()
+				out.AllowedClientCertOUs = nil
+			} else {
+				in.Delim('[')
+				if out.AllowedClientCertOUs == nil {
+					if !in.IsDelim(']') {
+						out.AllowedClientCertOUs = make([]string, 0, 4)
+					} else {
+						out.AllowedClientCertOUs = []string{}
+					}
+				} else {
+					out.AllowedClientCertOUs = (out.AllowedClientCertOUs)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v7 string
+					v7 = string(in.String())
+					out.AllowedClientCertOUs = append(out.AllowedClientCertOUs, v7)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "allowed_client_cidrs":
+			if in.IsNull() {
+				in.Skip()
+				out.AllowedClientCIDRs = nil
+			} else {
+				in.Delim('[')
+				if out.AllowedClientCIDRs == nil {
+					if !in.IsDelim(']') {
+						out.AllowedClientCIDRs = make([]string, 0, 4)
+					} else {
+						out.AllowedClientCIDRs = []string{}
+					}
+				} else {
+					out.AllowedClientCIDRs = (out.AllowedClientCIDRs)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v15 string
+					v15 = string(in.String())
+					out.AllowedClientCIDRs = append(out.AllowedClientCIDRs, v15)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "denied_client_cidrs":
+			if in.IsNull() {
+				in.Skip()
+				out.DeniedClientCIDRs = nil
+			} else {
+				in.Delim('[')
+				if out.DeniedClientCIDRs == nil {
+					if !in.IsDelim(']') {
+						out.DeniedClientCIDRs = make([]string, 0, 4)
+					} else {
+						out.DeniedClientCIDRs = []string{}
+					}
+				} else {
+					out.DeniedClientCIDRs = (out.DeniedClientCIDRs)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v16 string
+					v16 = string(in.String())
+					out.DeniedClientCIDRs = append(out.DeniedClientCIDRs, v16)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "jwt_validation":
+			out.JWTValidation = string(in.String())
+		case "jwt_audiences":
+			if in.IsNull() {
+				in.Skip()
+				out.JWTAudiences = nil
+			} else {
+				in.Delim('[')
+				if out.JWTAudiences == nil {
+					if !in.IsDelim(']') {
+						out.JWTAudiences = make([]string, 0, 4)
+					} else {
+						out.JWTAudiences = []string{}
+					}
+				} else {
+					out.JWTAudiences = (out.JWTAudiences)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v12 string
+					v12 = string(in.String())
+					out.JWTAudiences = append(out.JWTAudiences, v12)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "rate_limit_per_second":
+			out.RateLimitPerSecond = float64(in.Float64())
+		case "rate_limit_burst":
+			out.RateLimitBurst = int(in.Int())
+		case "max_request_body_bytes":
+			out.MaxRequestBodyBytes = int64(in.Int64())
+		case "cors_allowed_origins":
+			if in.IsNull() {
+				in.Skip()
+				out.CORSAllowedOrigins = nil
+			} else {
+				in.Delim('[')
+				if out.CORSAllowedOrigins == nil {
+					if !in.IsDelim(']') {
+						out.CORSAllowedOrigins = make([]string, 0, 4)
+					} else {
+						out.CORSAllowedOrigins = []string{}
+					}
+				} else {
+					out.CORSAllowedOrigins = (out.CORSAllowedOrigins)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v21 string
+					v21 = string(in.String())
+					out.CORSAllowedOrigins = append(out.CORSAllowedOrigins, v21)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "cors_allowed_methods":
+			if in.IsNull() {
+				in.Skip()
+				out.CORSAllowedMethods = nil
+			} else {
+				in.Delim('[')
+				if out.CORSAllowedMethods == nil {
+					if !in.IsDelim(']') {
+						out.CORSAllowedMethods = make([]string, 0, 4)
+					} else {
+						out.CORSAllowedMethods = []string{}
+					}
+				} else {
+					out.CORSAllowedMethods = (out.CORSAllowedMethods)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v22 string
+					v22 = string(in.String())
+					out.CORSAllowedMethods = append(out.CORSAllowedMethods, v22)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "cors_allowed_headers":
+			if in.IsNull() {
+				in.Skip()
+				out.CORSAllowedHeaders = nil
+			} else {
+				in.Delim('[')
+				if out.CORSAllowedHeaders == nil {
+					if !in.IsDelim(']') {
+						out.CORSAllowedHeaders = make([]string, 0, 4)
+					} else {
+						out.CORSAllowedHeaders = []string{}
+					}
+				} else {
+					out.CORSAllowedHeaders = (out.CORSAllowedHeaders)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v23 string
+					v23 = string(in.String())
+					out.CORSAllowedHeaders = append(out.CORSAllowedHeaders, v23)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "cors_max_age_in_seconds":
+			out.CORSMaxAgeInSeconds = int(in.Int())
+		case "host_rewrite":
+			out.HostRewrite = string(in.String())
+		case "strip_path_prefix":
+			out.StripPathPrefix = bool(in.Bool())
+		case "rewrite_path":
+			out.RewritePath = string(in.String())
+		case "match_methods":
+			if in.IsNull() {
+				in.Skip()
+				out.MatchMethods = nil
+			} else {
+				in.Delim('[')
+				if out.MatchMethods == nil {
+					if !in.IsDelim(']') {
+						out.MatchMethods = make([]string, 0, 4)
+					} else {
+						out.MatchMethods = []string{}
+					}
+				} else {
+					out.MatchMethods = (out.MatchMethods)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v30 string
+					v30 = string(in.String())
+					out.MatchMethods = append(out.MatchMethods, v30)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "match_headers":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				if !in.IsDelim('}') {
+					out.MatchHeaders = make(map[string]string)
+				} else {
+					out.MatchHeaders = nil
+				}
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v31 string
+					v31 = string(in.String())
+					(out.MatchHeaders)[key] = v31
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		case "endpoint_group":
+			out.EndpointGroup = string(in.String())
+		case "endpoint_group_percent":
+			out.EndpointGroupPercent = int(in.Int())
+		case "mirror_host":
+			out.MirrorHost = string(in.String())
+		case "mirror_percent":
+			out.MirrorPercent = int(in.Int())
+		case "weight":
+			out.Weight = int(in.Int())
+		case "balancing_algorithm":
+			out.BalancingAlgorithm = string(in.String())
+		case "availability_zone":
+			out.AvailabilityZone = string(in.String())
+		case "timeout_in_seconds":
+			out.TimeoutInSeconds = int(in.Int())
 		default:
 			in.SkipRecursive()
 		}
@@ -338,6 +605,300 @@ func easyjson639f989aEncodeCodeCloudfoundryOrgGorouterMbus2(out *jwriter.Writer,
 	first = false
 	out.RawString("\"endpoint_updated_at_ns\":")
 	out.Int64(int64(in.EndpointUpdatedAtNs))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"backend_client_cert_name\":")
+	out.String(string(in.BackendClientCertName))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"protocol\":")
+	out.String(string(in.Protocol))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"proxy_protocol\":")
+	out.String(string(in.ProxyProtocol))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"forwarded_client_cert\":")
+	out.String(string(in.ForwardedClientCert))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"allowed_client_cert_sans\":")
+	if in.AllowedClientCertSANs == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v8, v9 := range in.AllowedClientCertSANs {
+			if v8 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v9))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"allowed_client_cert_ous\":")
+	if in.AllowedClientCertOUs == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v10, v11 := range in.AllowedClientCertOUs {
+			if v10 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v11))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"allowed_client_cidrs\":")
+	if in.AllowedClientCIDRs == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v17, v18 := range in.AllowedClientCIDRs {
+			if v17 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v18))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"denied_client_cidrs\":")
+	if in.DeniedClientCIDRs == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v19, v20 := range in.DeniedClientCIDRs {
+			if v19 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v20))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"jwt_validation\":")
+	out.String(string(in.JWTValidation))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"jwt_audiences\":")
+	if in.JWTAudiences == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v13, v14 := range in.JWTAudiences {
+			if v13 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v14))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"rate_limit_per_second\":")
+	out.Float64(float64(in.RateLimitPerSecond))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"rate_limit_burst\":")
+	out.Int(int(in.RateLimitBurst))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"max_request_body_bytes\":")
+	out.Int64(int64(in.MaxRequestBodyBytes))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"cors_allowed_origins\":")
+	if in.CORSAllowedOrigins == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v24, v25 := range in.CORSAllowedOrigins {
+			if v24 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v25))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"cors_allowed_methods\":")
+	if in.CORSAllowedMethods == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v26, v27 := range in.CORSAllowedMethods {
+			if v26 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v27))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"cors_allowed_headers\":")
+	if in.CORSAllowedHeaders == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v28, v29 := range in.CORSAllowedHeaders {
+			if v28 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v29))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"cors_max_age_in_seconds\":")
+	out.Int(int(in.CORSMaxAgeInSeconds))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"host_rewrite\":")
+	out.String(string(in.HostRewrite))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"strip_path_prefix\":")
+	out.Bool(bool(in.StripPathPrefix))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"rewrite_path\":")
+	out.String(string(in.RewritePath))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"match_methods\":")
+	if in.MatchMethods == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v32, v33 := range in.MatchMethods {
+			if v32 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v33))
+		}
+		out.RawByte(']')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"match_headers\":")
+	if in.MatchHeaders == nil && (out.Flags&jwriter.NilMapAsEmpty) == 0 {
+		out.RawString(`null`)
+	} else {
+		out.RawByte('{')
+		v34First := true
+		for v34Name, v34Value := range in.MatchHeaders {
+			if !v34First {
+				out.RawByte(',')
+			}
+			v34First = false
+			out.String(string(v34Name))
+			out.RawByte(':')
+			out.String(string(v34Value))
+		}
+		out.RawByte('}')
+	}
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"endpoint_group\":")
+	out.String(string(in.EndpointGroup))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"endpoint_group_percent\":")
+	out.Int(int(in.EndpointGroupPercent))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"mirror_host\":")
+	out.String(string(in.MirrorHost))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"mirror_percent\":")
+	out.Int(int(in.MirrorPercent))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"weight\":")
+	out.Int(int(in.Weight))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"balancing_algorithm\":")
+	out.String(string(in.BalancingAlgorithm))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"availability_zone\":")
+	out.String(string(in.AvailabilityZone))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"timeout_in_seconds\":")
+	out.Int(int(in.TimeoutInSeconds))
 	out.RawByte('}')
 }
 
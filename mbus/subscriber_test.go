@@ -3,7 +3,9 @@ package mbus_test
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -12,14 +14,19 @@ import (
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/mbus"
 	mbusFakes "code.cloudfoundry.org/gorouter/mbus/fakes"
+	metricsFakes "code.cloudfoundry.org/gorouter/metrics/fakes"
+	rregistry "code.cloudfoundry.org/gorouter/registry"
 	registryFakes "code.cloudfoundry.org/gorouter/registry/fakes"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
 
 	"github.com/nats-io/go-nats"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/tedsuo/ifrit"
+	"github.com/uber-go/zap"
 )
 
 var _ = Describe("Subscriber", func() {
@@ -29,6 +36,7 @@ var _ = Describe("Subscriber", func() {
 		process ifrit.Process
 
 		registry *registryFakes.FakeRegistry
+		reporter *metricsFakes.FakeSubscriberReporter
 
 		natsRunner  *test_util.NATSRunner
 		natsPort    uint16
@@ -46,6 +54,7 @@ var _ = Describe("Subscriber", func() {
 		natsClient = natsRunner.MessageBus
 
 		registry = new(registryFakes.FakeRegistry)
+		reporter = new(metricsFakes.FakeSubscriberReporter)
 
 		l = test_util.NewTestZapLogger("mbus-test")
 
@@ -57,7 +66,7 @@ var _ = Describe("Subscriber", func() {
 		cfg.StartResponseDelayInterval = 60 * time.Second
 		cfg.DropletStaleThreshold = 120 * time.Second
 
-		sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, l)
+		sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, l)
 	})
 
 	AfterEach(func() {
@@ -106,7 +115,7 @@ var _ = Describe("Subscriber", func() {
 	})
 
 	It("errors when mbus client is nil", func() {
-		sub = mbus.NewSubscriber(nil, registry, cfg, reconnected, l)
+		sub = mbus.NewSubscriber(nil, registry, cfg, reconnected, reporter, l)
 		process = ifrit.Invoke(sub)
 
 		var err error
@@ -116,7 +125,7 @@ var _ = Describe("Subscriber", func() {
 
 	It("errors when pending limit is 0", func() {
 		cfg.NatsClientMessageBufferSize = 0
-		sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, l)
+		sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, l)
 		process = ifrit.Invoke(sub)
 
 		var err error
@@ -171,7 +180,7 @@ var _ = Describe("Subscriber", func() {
 		var droppedMsgs func() int
 		BeforeEach(func() {
 			cfg.NatsClientMessageBufferSize = 1
-			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, l)
+			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, l)
 			droppedMsgs = func() int {
 				msgs, errs := sub.Dropped()
 				Expect(errs).ToNot(HaveOccurred())
@@ -225,7 +234,7 @@ var _ = Describe("Subscriber", func() {
 			fakeClient.PublishReturns(errors.New("potato"))
 		})
 		It("errors", func() {
-			sub = mbus.NewSubscriber(fakeClient, registry, cfg, reconnected, l)
+			sub = mbus.NewSubscriber(fakeClient, registry, cfg, reconnected, reporter, l)
 			process = ifrit.Invoke(sub)
 
 			var err error
@@ -312,8 +321,11 @@ var _ = Describe("Subscriber", func() {
 	})
 
 	Context("when the message cannot be unmarshaled", func() {
+		var testLogger *test_util.TestZapLogger
+
 		BeforeEach(func() {
-			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, l)
+			testLogger = test_util.NewTestZapLogger("mbus-malformed-test")
+			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, testLogger)
 			process = ifrit.Invoke(sub)
 			Eventually(process.Ready()).Should(BeClosed())
 		})
@@ -322,11 +334,185 @@ var _ = Describe("Subscriber", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Consistently(registry.RegisterCallCount).Should(BeZero())
 		})
+
+		It("counts the malformed message and logs a warning", func() {
+			err := natsClient.Publish("router.register", []byte(`{"host": "host", "port": `))
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(reporter.CaptureMalformedRegistrationMessageCallCount).Should(Equal(1))
+			Eventually(func() []string {
+				return testLogger.Lines(zap.WarnLevel)
+			}).Should(ContainElement(ContainSubstring("malformed-registration-message")))
+			Expect(registry.RegisterCallCount()).To(BeZero())
+		})
+
+		It("truncates large payloads in the log", func() {
+			payload := `{"host": "` + strings.Repeat("a", 4096)
+			err := natsClient.Publish("router.unregister", []byte(payload))
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(reporter.CaptureMalformedRegistrationMessageCallCount).Should(Equal(1))
+			Eventually(func() []string {
+				return testLogger.Lines(zap.WarnLevel)
+			}).Should(ContainElement(ContainSubstring("(truncated)")))
+			Expect(testLogger.Lines(zap.WarnLevel)[0]).ToNot(ContainSubstring(strings.Repeat("a", 1024)))
+		})
+	})
+
+	Context("when registration message validation is enabled", func() {
+		var testLogger *test_util.TestZapLogger
+
+		BeforeEach(func() {
+			cfg.ValidateRegistrationMessages = true
+			testLogger = test_util.NewTestZapLogger("mbus-validation-test")
+			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, testLogger)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+		})
+
+		publish := func(msg mbus.RegistryMessage) {
+			data, err := json.Marshal(msg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(natsClient.Publish("router.register", data)).To(Succeed())
+		}
+
+		It("registers valid messages", func() {
+			publish(mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com/path"}})
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+			Expect(reporter.CaptureInvalidRegistrationMessageCallCount()).To(BeZero())
+		})
+
+		It("registers valid messages with only a tls port", func() {
+			publish(mbus.RegistryMessage{Host: "host", TLSPort: 1999, Uris: []route.Uri{"test.example.com"}})
+
+			Eventually(registry.RegisterCallCount).Should(Equal(1))
+		})
+
+		DescribeTable("rejects invalid registrations",
+			func(msg mbus.RegistryMessage, reason string) {
+				publish(msg)
+
+				Eventually(reporter.CaptureInvalidRegistrationMessageCallCount).Should(Equal(1))
+				Eventually(func() []string {
+					return testLogger.Lines(zap.WarnLevel)
+				}).Should(ContainElement(SatisfyAll(
+					ContainSubstring("invalid-registration-message"),
+					ContainSubstring(reason),
+				)))
+				Expect(registry.RegisterCallCount()).To(BeZero())
+			},
+			Entry("with an empty host",
+				mbus.RegistryMessage{Port: 1111, Uris: []route.Uri{"test.example.com"}},
+				"host must not be empty"),
+			Entry("with no port or tls port",
+				mbus.RegistryMessage{Host: "host", Uris: []route.Uri{"test.example.com"}},
+				"port or tls_port must be set"),
+			Entry("with no uris",
+				mbus.RegistryMessage{Host: "host", Port: 1111},
+				"uris must not be empty"),
+			Entry("with an empty uri",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{""}},
+				"invalid uri"),
+			Entry("with whitespace in a uri",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test example.com"}},
+				"invalid uri"),
+			Entry("with invalid characters in a uri",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com/<script>"}},
+				"invalid uri"),
+			Entry("with an unknown protocol",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, Protocol: "spdy"},
+				"invalid protocol"),
+			Entry("with a negative weight",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, Weight: -1},
+				"weight must not be negative"),
+			Entry("with an unknown PROXY protocol version",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, ProxyProtocol: "v3"},
+				"invalid proxy_protocol"),
+			Entry("with an unknown forwarded client cert mode",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, ForwardedClientCert: "strip"},
+				"invalid forwarded_client_cert"),
+			Entry("with an empty allowed client cert SAN",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, AllowedClientCertSANs: []string{""}},
+				"allowed_client_cert_sans must not contain empty values"),
+			Entry("with an empty allowed client cert OU",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, AllowedClientCertOUs: []string{""}},
+				"allowed_client_cert_ous must not contain empty values"),
+			Entry("with an unknown JWT validation policy",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, JWTValidation: "optional"},
+				"invalid jwt_validation"),
+			Entry("with a negative rate limit",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, RateLimitPerSecond: -1},
+				"rate_limit_per_second must not be negative"),
+			Entry("with a negative rate limit burst",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, RateLimitBurst: -1},
+				"rate_limit_burst must not be negative"),
+			Entry("with a negative request body limit",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, MaxRequestBodyBytes: -1},
+				"max_request_body_bytes must not be negative"),
+			Entry("with an empty CORS origin",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, CORSAllowedOrigins: []string{""}},
+				"cors_allowed_origins must not contain empty values"),
+			Entry("with CORS methods but no origins",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, CORSAllowedMethods: []string{"PUT"}},
+				"cors_allowed_origins must be set if cors_allowed_methods or cors_allowed_headers are"),
+			Entry("with a negative CORS max age",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, CORSAllowedOrigins: []string{"*"}, CORSMaxAgeInSeconds: -1},
+				"cors_max_age_in_seconds must not be negative"),
+			Entry("with a negative timeout",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, TimeoutInSeconds: -1},
+				"timeout_in_seconds must not be negative"),
+			Entry("with an invalid host rewrite",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, HostRewrite: "legacy.internal/path"},
+				"invalid host_rewrite"),
+			Entry("with a rewrite path that is not absolute",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, RewritePath: "api"},
+				"invalid rewrite_path"),
+			Entry("with a rewrite path that has a query",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, RewritePath: "/api?v=2"},
+				"invalid rewrite_path"),
+			Entry("with both a stripped and a rewritten path",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, StripPathPrefix: true, RewritePath: "/api"},
+				"strip_path_prefix and rewrite_path must not both be set"),
+			Entry("with an invalid match method",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, MatchMethods: []string{"GET POST"}},
+				"invalid match_methods"),
+			Entry("with an invalid match header name",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, MatchHeaders: map[string]string{"X-Api-Version:": "2"}},
+				"invalid match_headers name"),
+			Entry("with an endpoint group percentage over 100",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, EndpointGroup: "canary", EndpointGroupPercent: 101},
+				"endpoint_group_percent must be between 0 and 100"),
+			Entry("with an endpoint group percentage but no group",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, EndpointGroupPercent: 5},
+				"endpoint_group must be set if endpoint_group_percent is"),
+			Entry("with an invalid mirror host",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, MirrorHost: "v2.example.com/path", MirrorPercent: 10},
+				"invalid mirror_host"),
+			Entry("with a mirror percentage over 100",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, MirrorHost: "v2.example.com", MirrorPercent: 101},
+				"mirror_percent must be between 0 and 100"),
+			Entry("with a mirror percentage but no mirror host",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, MirrorPercent: 10},
+				"mirror_host must be set if mirror_percent is"),
+			Entry("with an unknown balancing algorithm",
+				mbus.RegistryMessage{Host: "host", Port: 1111, Uris: []route.Uri{"test.example.com"}, BalancingAlgorithm: "random"},
+				"invalid balancing_algorithm"),
+		)
+
+		It("does not validate unregistrations", func() {
+			data, err := json.Marshal(mbus.RegistryMessage{Port: 1111, Uris: []route.Uri{"test.example.com"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(natsClient.Publish("router.unregister", data)).To(Succeed())
+
+			Eventually(registry.UnregisterCallCount).Should(Equal(1))
+			Expect(reporter.CaptureInvalidRegistrationMessageCallCount()).To(BeZero())
+		})
 	})
 
 	Context("when the message contains a tls port for route", func() {
 		BeforeEach(func() {
-			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, l)
+			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, l)
 			process = ifrit.Invoke(sub)
 			Eventually(process.Ready()).Should(BeClosed())
 		})
@@ -367,6 +553,453 @@ var _ = Describe("Subscriber", func() {
 		})
 	})
 
+	It("passes the backend client certificate name to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:                  "host",
+			TLSPort:               1999,
+			ServerCertDomainSAN:   "san",
+			Uris:                  []route.Uri{"test.example.com"},
+			BackendClientCertName: "cert-a",
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"backend_client_cert_name":"cert-a"`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.ClientCertName).To(Equal("cert-a"))
+	})
+
+	It("passes the protocol to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:     "host",
+			Port:     1111,
+			Uris:     []route.Uri{"test.example.com"},
+			Protocol: "http2",
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"protocol":"http2"`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.IsHTTP2()).To(BeTrue())
+	})
+
+	It("passes the weight to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:   "host",
+			Port:   1111,
+			Uris:   []route.Uri{"test.example.com"},
+			Weight: 5,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"weight":5`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.Weight).To(Equal(5))
+	})
+
+	It("passes the balancing algorithm to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:               "host",
+			Port:               1111,
+			Uris:               []route.Uri{"test.example.com"},
+			BalancingAlgorithm: "least-connection",
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"balancing_algorithm":"least-connection"`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.BalancingAlgorithm).To(Equal("least-connection"))
+	})
+
+	It("passes the availability zone to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:             "host",
+			Port:             1111,
+			Uris:             []route.Uri{"test.example.com"},
+			AvailabilityZone: "z1",
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"availability_zone":"z1"`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.AvailabilityZone).To(Equal("z1"))
+	})
+
+	It("passes the timeout to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:             "host",
+			Port:             1111,
+			Uris:             []route.Uri{"test.example.com"},
+			TimeoutInSeconds: 300,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"timeout_in_seconds":300`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.Timeout).To(Equal(5 * time.Minute))
+	})
+
+	It("passes the PROXY protocol version to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:          "host",
+			Port:          1111,
+			Uris:          []route.Uri{"test.example.com"},
+			ProxyProtocol: route.ProxyProtocolV2,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"proxy_protocol":"v2"`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.ProxyProtocol).To(Equal(route.ProxyProtocolV2))
+	})
+
+	It("passes the forwarded client cert mode to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:                "host",
+			Port:                1111,
+			Uris:                []route.Uri{"test.example.com"},
+			ForwardedClientCert: "always_forward",
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"forwarded_client_cert":"always_forward"`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.ForwardedClientCert).To(Equal("always_forward"))
+	})
+
+	It("passes the client cert allowlist to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:                  "host",
+			Port:                  1111,
+			Uris:                  []route.Uri{"test.example.com"},
+			AllowedClientCertSANs: []string{"billing.internal"},
+			AllowedClientCertOUs:  []string{"payments"},
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"allowed_client_cert_sans":["billing.internal"]`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.AllowedClientCerts).To(Equal(route.ClientCertAllowlist{
+			SANs: []string{"billing.internal"},
+			OUs:  []string{"payments"},
+		}))
+	})
+
+	It("passes the client CIDRs to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:               "host",
+			Port:               1111,
+			Uris:               []route.Uri{"test.example.com"},
+			AllowedClientCIDRs: []string{"10.0.0.0/8"},
+			DeniedClientCIDRs:  []string{"10.1.0.0/16", "fd00::/8"},
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"denied_client_cidrs":["10.1.0.0/16","fd00::/8"]`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.ClientIPAccess.Allow).To(HaveLen(1))
+		Expect(originalEndpoint.ClientIPAccess.Allow[0].String()).To(Equal("10.0.0.0/8"))
+		Expect(originalEndpoint.ClientIPAccess.Deny).To(HaveLen(2))
+		Expect(originalEndpoint.ClientIPAccess.Deny[1].String()).To(Equal("fd00::/8"))
+	})
+
+	It("passes the rate limit to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:               "host",
+			Port:               1111,
+			Uris:               []route.Uri{"test.example.com"},
+			RateLimitPerSecond: 2.5,
+			RateLimitBurst:     10,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.RateLimit).To(Equal(route.RateLimit{PerSecond: 2.5, Burst: 10}))
+	})
+
+	It("passes the request body limit to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:                "host",
+			Port:                1111,
+			Uris:                []route.Uri{"test.example.com"},
+			MaxRequestBodyBytes: 1048576,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"max_request_body_bytes":1048576`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.MaxRequestBodyBytes).To(Equal(int64(1048576)))
+	})
+
+	It("passes the host rewrite to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:        "host",
+			Port:        1111,
+			Uris:        []route.Uri{"test.example.com"},
+			HostRewrite: "legacy.internal",
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"host_rewrite":"legacy.internal"`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.HostRewrite).To(Equal("legacy.internal"))
+	})
+
+	It("passes the path rewrite to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:            "host",
+			Port:            1111,
+			Uris:            []route.Uri{"test.example.com/team-a"},
+			StripPathPrefix: true,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"strip_path_prefix":true`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.StripPathPrefix).To(BeTrue())
+		Expect(originalEndpoint.RewritePath).To(BeEmpty())
+	})
+
+	It("passes the match to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:         "host",
+			Port:         1111,
+			Uris:         []route.Uri{"test.example.com"},
+			MatchMethods: []string{"get"},
+			MatchHeaders: map[string]string{"x-api-version": "2"},
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"match_methods":["get"]`))
+		Expect(string(data)).To(ContainSubstring(`"match_headers":{"x-api-version":"2"}`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.Match).To(Equal(route.RouteMatch{
+			Methods: []string{"GET"},
+			Headers: map[string]string{"X-Api-Version": "2"},
+		}))
+	})
+
+	It("passes the endpoint group to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:                 "host",
+			Port:                 1111,
+			Uris:                 []route.Uri{"test.example.com"},
+			EndpointGroup:        "canary",
+			EndpointGroupPercent: 5,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"endpoint_group":"canary","endpoint_group_percent":5`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.Group).To(Equal("canary"))
+		Expect(originalEndpoint.GroupPercent).To(Equal(5))
+	})
+
+	It("passes the mirror to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:          "host",
+			Port:          1111,
+			Uris:          []route.Uri{"test.example.com"},
+			MirrorHost:    "v2.example.com",
+			MirrorPercent: 10,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"mirror_host":"v2.example.com","mirror_percent":10`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.MirrorHost).To(Equal("v2.example.com"))
+		Expect(originalEndpoint.MirrorPercent).To(Equal(10))
+	})
+
+	It("passes the CORS policy to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:                "host",
+			Port:                1111,
+			Uris:                []route.Uri{"test.example.com"},
+			CORSAllowedOrigins:  []string{"https://app.example.com"},
+			CORSAllowedMethods:  []string{"GET", "PUT"},
+			CORSAllowedHeaders:  []string{"Authorization"},
+			CORSMaxAgeInSeconds: 600,
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"cors_allowed_methods":["GET","PUT"]`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.CORS).To(Equal(route.CORSPolicy{
+			AllowedOrigins: []string{"https://app.example.com"},
+			AllowedMethods: []string{"GET", "PUT"},
+			AllowedHeaders: []string{"Authorization"},
+			MaxAge:         10 * time.Minute,
+		}))
+	})
+
+	It("passes the JWT validation policy to the endpoint", func() {
+		process = ifrit.Invoke(sub)
+		Eventually(process.Ready()).Should(BeClosed())
+		msg := mbus.RegistryMessage{
+			Host:          "host",
+			Port:          1111,
+			Uris:          []route.Uri{"test.example.com"},
+			JWTValidation: route.JWTValidationRequired,
+			JWTAudiences:  []string{"billing"},
+		}
+
+		data, err := json.Marshal(msg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"jwt_audiences":["billing"]`))
+
+		err = natsClient.Publish("router.register", data)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(registry.RegisterCallCount).Should(Equal(1))
+		_, originalEndpoint := registry.RegisterArgsForCall(0)
+		Expect(originalEndpoint.JWTValidation).To(Equal(route.JWTValidationRequired))
+		Expect(originalEndpoint.JWTAudiences).To(Equal([]string{"billing"}))
+	})
+
 	It("converts endpoint_updated_at_ns", func() {
 		process = ifrit.Invoke(sub)
 		Eventually(process.Ready()).Should(BeClosed())
@@ -447,7 +1080,7 @@ var _ = Describe("Subscriber", func() {
 
 	Context("when the message contains an http url for route services", func() {
 		BeforeEach(func() {
-			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, l)
+			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, l)
 			process = ifrit.Invoke(sub)
 			Eventually(process.Ready()).Should(BeClosed())
 		})
@@ -475,9 +1108,34 @@ var _ = Describe("Subscriber", func() {
 		})
 	})
 
+	Context("when the message contains an invalid client CIDR", func() {
+		BeforeEach(func() {
+			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, l)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+		})
+
+		It("does not update the registry", func() {
+			msg := mbus.RegistryMessage{
+				Host:               "host",
+				Port:               1111,
+				Uris:               []route.Uri{"test.example.com"},
+				AllowedClientCIDRs: []string{"10.0.0.0/8", "10.0.0.1"},
+			}
+
+			data, err := json.Marshal(msg)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = natsClient.Publish("router.register", data)
+			Expect(err).ToNot(HaveOccurred())
+
+			Consistently(registry.RegisterCallCount).Should(BeZero())
+		})
+	})
+
 	Context("when a route is unregistered", func() {
 		BeforeEach(func() {
-			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, l)
+			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, l)
 			process = ifrit.Invoke(sub)
 			Eventually(process.Ready()).Should(BeClosed())
 		})
@@ -586,4 +1244,231 @@ var _ = Describe("Subscriber", func() {
 		})
 	})
 
+	Context("when a maximum registration rate is configured", func() {
+		var (
+			routeRegistry *rregistry.RouteRegistry
+			testLogger    *test_util.TestZapLogger
+		)
+
+		BeforeEach(func() {
+			testLogger = test_util.NewTestZapLogger("mbus-rate-test")
+			cfg.NatsMaxRegistrationRate = 10
+
+			routeRegistry = rregistry.NewRouteRegistry(testLogger, cfg, new(metricsFakes.FakeRouteRegistryReporter))
+			routeRegistry.Register("existing.example.com", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.1", Port: 8080}))
+			registry.RegisterStub = routeRegistry.Register
+			registry.LookupStub = routeRegistry.Lookup
+
+			sub = mbus.NewSubscriber(natsClient, registry, cfg, reconnected, reporter, testLogger)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+		})
+
+		It("sheds registrations above the rate without blocking lookups", func() {
+			const messages = 200
+
+			start := time.Now()
+			for i := 0; i < messages; i++ {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: uint16(2000 + i),
+					Uris: []route.Uri{"flood.example.com"},
+				}
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = natsClient.Publish("router.register", data)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(registry.Lookup("existing.example.com")).ToNot(BeNil())
+			}
+			Expect(natsClient.Flush()).To(Succeed())
+
+			Eventually(func() int {
+				return registry.RegisterCallCount() + reporter.CaptureRegistrationMessageShedCallCount()
+			}).Should(Equal(messages))
+			elapsed := time.Since(start)
+
+			Expect(reporter.CaptureRegistrationMessageShedCallCount()).To(BeNumerically(">", 0))
+			Expect(registry.RegisterCallCount()).To(BeNumerically("<=", cfg.NatsMaxRegistrationRate+int(elapsed.Seconds()*float64(cfg.NatsMaxRegistrationRate))+1))
+			Expect(routeRegistry.Lookup("existing.example.com")).ToNot(BeNil())
+			Expect(testLogger).To(gbytes.Say("registration-message-rate-exceeded"))
+		})
+
+		It("does not apply the rate to unregistrations", func() {
+			msg := mbus.RegistryMessage{
+				Host: "host",
+				App:  "app",
+				Port: 1111,
+				Uris: []route.Uri{"existing.example.com"},
+			}
+			data, err := json.Marshal(msg)
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < 50; i++ {
+				err = natsClient.Publish("router.unregister", data)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			Eventually(registry.UnregisterCallCount).Should(Equal(50))
+			Expect(reporter.CaptureRegistrationMessageShedCallCount()).To(BeZero())
+		})
+
+		It("does not shed unregistrations during a flood of registrations", func() {
+			for i := 0; i < 50; i++ {
+				msg := mbus.RegistryMessage{
+					Host: "host",
+					App:  "app",
+					Port: uint16(2000 + i),
+					Uris: []route.Uri{"flood.example.com"},
+				}
+				data, err := json.Marshal(msg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(natsClient.Publish("router.register", data)).To(Succeed())
+			}
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host: "host",
+				App:  "app",
+				Port: 1111,
+				Uris: []route.Uri{"existing.example.com"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(natsClient.Publish("router.unregister", data)).To(Succeed())
+
+			Eventually(registry.UnregisterCallCount).Should(Equal(1))
+			Expect(reporter.CaptureRegistrationMessageShedCallCount()).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("when a JetStream stream is configured", func() {
+		var (
+			fakeClient    *mbusFakes.FakeClient
+			handlers      map[string]nats.MsgHandler
+			subscriptions map[string]*nats.Subscription
+		)
+
+		acks := func() int {
+			n := 0
+			for i := 0; i < fakeClient.PublishCallCount(); i++ {
+				if subject, _ := fakeClient.PublishArgsForCall(i); strings.HasPrefix(subject, "$JS.ACK.") {
+					n++
+				}
+			}
+			return n
+		}
+
+		BeforeEach(func() {
+			cfg.NatsJetStream.Stream = "routes"
+			cfg.NatsJetStream.Durable = "gorouter-0"
+
+			handlers = map[string]nats.MsgHandler{}
+			subscriptions = map[string]*nats.Subscription{}
+			fakeClient = new(mbusFakes.FakeClient)
+			fakeClient.SubscribeStub = func(subj string, cb nats.MsgHandler) (*nats.Subscription, error) {
+				handlers[subj] = cb
+				subscription, err := natsClient.Subscribe(subj, func(*nats.Msg) {})
+				subscriptions[subj] = subscription
+				return subscription, err
+			}
+			fakeClient.RequestReturns(&nats.Msg{Data: []byte(`{"type": "io.nats.jetstream.api.v1.consumer_create_response"}`)}, nil)
+
+			sub = mbus.NewSubscriber(fakeClient, registry, cfg, reconnected, reporter, l)
+		})
+
+		It("creates a durable consumer that pushes to the subscriber", func() {
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+
+			Expect(fakeClient.RequestCallCount()).To(Equal(1))
+			subject, data, _ := fakeClient.RequestArgsForCall(0)
+			Expect(subject).To(Equal("$JS.API.CONSUMER.DURABLE.CREATE.routes.gorouter-0"))
+			Expect(data).To(MatchJSON(`{
+				"stream_name": "routes",
+				"config": {
+					"durable_name": "gorouter-0",
+					"deliver_subject": "gorouter.jetstream.gorouter-0",
+					"deliver_policy": "new",
+					"ack_policy": "explicit",
+					"ack_wait": 30000000000
+				}
+			}`))
+
+			Expect(handlers).To(HaveKey("gorouter.jetstream.gorouter-0"))
+			Expect(handlers).NotTo(HaveKey("router.*"))
+		})
+
+		It("registers the delivered messages and acknowledges them", func() {
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host: "host",
+				App:  "app",
+				Port: 1111,
+				Uris: []route.Uri{"jetstream.example.com"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			handlers["gorouter.jetstream.gorouter-0"](&nats.Msg{
+				Subject: "router.register",
+				Reply:   "$JS.ACK.routes.gorouter-0.1.7.7.1700000000000000000.0",
+				Data:    data,
+			})
+
+			Expect(registry.RegisterCallCount()).To(Equal(1))
+			uri, _ := registry.RegisterArgsForCall(0)
+			Expect(uri).To(Equal(route.Uri("jetstream.example.com")))
+
+			subject, ack := fakeClient.PublishArgsForCall(fakeClient.PublishCallCount() - 1)
+			Expect(subject).To(Equal("$JS.ACK.routes.gorouter-0.1.7.7.1700000000000000000.0"))
+			Expect(ack).To(BeEmpty())
+		})
+
+		It("does not acknowledge the messages shed by the maximum registration rate", func() {
+			cfg.NatsMaxRegistrationRate = 1
+			sub = mbus.NewSubscriber(fakeClient, registry, cfg, reconnected, reporter, l)
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+
+			data, err := json.Marshal(mbus.RegistryMessage{
+				Host: "host",
+				App:  "app",
+				Port: 1111,
+				Uris: []route.Uri{"jetstream.example.com"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < 10; i++ {
+				handlers["gorouter.jetstream.gorouter-0"](&nats.Msg{
+					Subject: "router.register",
+					Reply:   fmt.Sprintf("$JS.ACK.routes.gorouter-0.1.%d.%d.1700000000000000000.0", i+1, i+1),
+					Data:    data,
+				})
+			}
+
+			Expect(reporter.CaptureRegistrationMessageShedCallCount()).To(BeNumerically(">", 0))
+			Expect(acks()).To(Equal(registry.RegisterCallCount()))
+			Expect(acks()).To(Equal(10 - reporter.CaptureRegistrationMessageShedCallCount()))
+		})
+
+		It("creates the consumer again when reconnecting", func() {
+			process = ifrit.Invoke(sub)
+			Eventually(process.Ready()).Should(BeClosed())
+
+			reconnected <- mbus.Signal{}
+			Eventually(fakeClient.RequestCallCount).Should(Equal(2))
+		})
+
+		It("errors when the consumer cannot be created", func() {
+			fakeClient.RequestReturns(&nats.Msg{Data: []byte(`{"error": {"code": 404, "description": "stream not found"}}`)}, nil)
+
+			process = ifrit.Invoke(sub)
+
+			var err error
+			Eventually(process.Wait()).Should(Receive(&err))
+			Expect(err).To(MatchError("subscriber: create JetStream consumer: stream not found (404)"))
+			Expect(subscriptions["gorouter.jetstream.gorouter-0"].IsValid()).To(BeFalse())
+		})
+	})
 })
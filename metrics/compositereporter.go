@@ -23,14 +23,27 @@ type ProxyReporter interface {
 	CaptureBackendInvalidID()
 	CaptureBackendInvalidTLSCert()
 	CaptureBackendTLSHandshakeFailed()
+	CaptureBackendConnectionOpened()
+	CaptureBackendConnectionReused()
+	CaptureBackendDialTime(d time.Duration)
+	CaptureBackendTLSHandshakeTime(d time.Duration)
+	CaptureBackendIdleConnections(total, max int)
+	CaptureBackendEjected()
+	CaptureBackendCircuitOpened()
 	CaptureBadRequest()
 	CaptureBadGateway()
 	CaptureRoutingRequest(b *route.Endpoint)
 	CaptureRoutingResponse(statusCode int)
 	CaptureRoutingResponseLatency(b *route.Endpoint, statusCode int, t time.Time, d time.Duration)
+	CaptureRouteResponse(uri string, statusCode int, d time.Duration)
 	CaptureRouteServiceResponse(res *http.Response)
 	CaptureWebSocketUpdate()
 	CaptureWebSocketFailure()
+	CaptureRateLimited()
+	CaptureTokenValidationFailure()
+	CaptureInFlightRequests(count int)
+	CaptureRequestShed()
+	CaptureRequestHeadersTooLarge()
 }
 
 type ComponentTagged interface {
@@ -46,6 +59,23 @@ type RouteRegistryReporter interface {
 	CaptureRouteRegistrationLatency(t time.Duration)
 	UnmuzzleRouteRegistrationLatency()
 	CaptureUnregistryMessage(msg ComponentTagged)
+	CaptureRoutePoolSize(uri string, size int)
+	CaptureRoutePoolSizes(sizes []int)
+}
+
+//go:generate counterfeiter -o fakes/fake_subscriber_reporter.go . SubscriberReporter
+type SubscriberReporter interface {
+	CaptureRegistrationMessageShed()
+	CaptureMalformedRegistrationMessage()
+	CaptureInvalidRegistrationMessage()
+	CaptureNATSFailover()
+	CaptureNATSFailback()
+}
+
+//go:generate counterfeiter -o fakes/fake_ocsp_reporter.go . OCSPReporter
+type OCSPReporter interface {
+	CaptureOCSPStapleAge(age time.Duration)
+	CaptureOCSPStapleFailure()
 }
 
 type CompositeReporter struct {
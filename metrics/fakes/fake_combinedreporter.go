@@ -23,15 +23,43 @@ type FakeCombinedReporter struct {
 	CaptureBackendTLSHandshakeFailedStub        func()
 	captureBackendTLSHandshakeFailedMutex       sync.RWMutex
 	captureBackendTLSHandshakeFailedArgsForCall []struct{}
-	CaptureBadRequestStub                       func()
-	captureBadRequestMutex                      sync.RWMutex
-	captureBadRequestArgsForCall                []struct{}
-	CaptureBadGatewayStub                       func()
-	captureBadGatewayMutex                      sync.RWMutex
-	captureBadGatewayArgsForCall                []struct{}
-	CaptureRoutingRequestStub                   func(b *route.Endpoint)
-	captureRoutingRequestMutex                  sync.RWMutex
-	captureRoutingRequestArgsForCall            []struct {
+	CaptureBackendConnectionOpenedStub          func()
+	captureBackendConnectionOpenedMutex         sync.RWMutex
+	captureBackendConnectionOpenedArgsForCall   []struct{}
+	CaptureBackendConnectionReusedStub          func()
+	captureBackendConnectionReusedMutex         sync.RWMutex
+	captureBackendConnectionReusedArgsForCall   []struct{}
+	CaptureBackendDialTimeStub                  func(d time.Duration)
+	captureBackendDialTimeMutex                 sync.RWMutex
+	captureBackendDialTimeArgsForCall           []struct {
+		d time.Duration
+	}
+	CaptureBackendTLSHandshakeTimeStub        func(d time.Duration)
+	captureBackendTLSHandshakeTimeMutex       sync.RWMutex
+	captureBackendTLSHandshakeTimeArgsForCall []struct {
+		d time.Duration
+	}
+	CaptureBackendIdleConnectionsStub        func(total int, max int)
+	captureBackendIdleConnectionsMutex       sync.RWMutex
+	captureBackendIdleConnectionsArgsForCall []struct {
+		total int
+		max   int
+	}
+	CaptureBackendEjectedStub              func()
+	captureBackendEjectedMutex             sync.RWMutex
+	captureBackendEjectedArgsForCall       []struct{}
+	CaptureBackendCircuitOpenedStub        func()
+	captureBackendCircuitOpenedMutex       sync.RWMutex
+	captureBackendCircuitOpenedArgsForCall []struct{}
+	CaptureBadRequestStub                  func()
+	captureBadRequestMutex                 sync.RWMutex
+	captureBadRequestArgsForCall           []struct{}
+	CaptureBadGatewayStub                  func()
+	captureBadGatewayMutex                 sync.RWMutex
+	captureBadGatewayArgsForCall           []struct{}
+	CaptureRoutingRequestStub              func(b *route.Endpoint)
+	captureRoutingRequestMutex             sync.RWMutex
+	captureRoutingRequestArgsForCall       []struct {
 		b *route.Endpoint
 	}
 	CaptureRoutingResponseStub        func(statusCode int)
@@ -47,19 +75,43 @@ type FakeCombinedReporter struct {
 		t          time.Time
 		d          time.Duration
 	}
+	CaptureRouteResponseStub        func(uri string, statusCode int, d time.Duration)
+	captureRouteResponseMutex       sync.RWMutex
+	captureRouteResponseArgsForCall []struct {
+		uri        string
+		statusCode int
+		d          time.Duration
+	}
 	CaptureRouteServiceResponseStub        func(res *http.Response)
 	captureRouteServiceResponseMutex       sync.RWMutex
 	captureRouteServiceResponseArgsForCall []struct {
 		res *http.Response
 	}
-	CaptureWebSocketUpdateStub         func()
-	captureWebSocketUpdateMutex        sync.RWMutex
-	captureWebSocketUpdateArgsForCall  []struct{}
-	CaptureWebSocketFailureStub        func()
-	captureWebSocketFailureMutex       sync.RWMutex
-	captureWebSocketFailureArgsForCall []struct{}
-	invocations                        map[string][][]interface{}
-	invocationsMutex                   sync.RWMutex
+	CaptureWebSocketUpdateStub               func()
+	captureWebSocketUpdateMutex              sync.RWMutex
+	captureWebSocketUpdateArgsForCall        []struct{}
+	CaptureWebSocketFailureStub              func()
+	captureWebSocketFailureMutex             sync.RWMutex
+	captureWebSocketFailureArgsForCall       []struct{}
+	CaptureRateLimitedStub                   func()
+	captureRateLimitedMutex                  sync.RWMutex
+	captureRateLimitedArgsForCall            []struct{}
+	CaptureTokenValidationFailureStub        func()
+	captureTokenValidationFailureMutex       sync.RWMutex
+	captureTokenValidationFailureArgsForCall []struct{}
+	CaptureInFlightRequestsStub              func(count int)
+	captureInFlightRequestsMutex             sync.RWMutex
+	captureInFlightRequestsArgsForCall       []struct {
+		count int
+	}
+	CaptureRequestShedStub                   func()
+	captureRequestShedMutex                  sync.RWMutex
+	captureRequestShedArgsForCall            []struct{}
+	CaptureRequestHeadersTooLargeStub        func()
+	captureRequestHeadersTooLargeMutex       sync.RWMutex
+	captureRequestHeadersTooLargeArgsForCall []struct{}
+	invocations                              map[string][][]interface{}
+	invocationsMutex                         sync.RWMutex
 }
 
 func (fake *FakeCombinedReporter) CaptureBackendExhaustedConns() {
@@ -126,6 +178,143 @@ func (fake *FakeCombinedReporter) CaptureBackendTLSHandshakeFailedCallCount() in
 	return len(fake.captureBackendTLSHandshakeFailedArgsForCall)
 }
 
+func (fake *FakeCombinedReporter) CaptureBackendConnectionOpened() {
+	fake.captureBackendConnectionOpenedMutex.Lock()
+	fake.captureBackendConnectionOpenedArgsForCall = append(fake.captureBackendConnectionOpenedArgsForCall, struct{}{})
+	fake.recordInvocation("CaptureBackendConnectionOpened", []interface{}{})
+	fake.captureBackendConnectionOpenedMutex.Unlock()
+	if fake.CaptureBackendConnectionOpenedStub != nil {
+		fake.CaptureBackendConnectionOpenedStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendConnectionOpenedCallCount() int {
+	fake.captureBackendConnectionOpenedMutex.RLock()
+	defer fake.captureBackendConnectionOpenedMutex.RUnlock()
+	return len(fake.captureBackendConnectionOpenedArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendConnectionReused() {
+	fake.captureBackendConnectionReusedMutex.Lock()
+	fake.captureBackendConnectionReusedArgsForCall = append(fake.captureBackendConnectionReusedArgsForCall, struct{}{})
+	fake.recordInvocation("CaptureBackendConnectionReused", []interface{}{})
+	fake.captureBackendConnectionReusedMutex.Unlock()
+	if fake.CaptureBackendConnectionReusedStub != nil {
+		fake.CaptureBackendConnectionReusedStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendConnectionReusedCallCount() int {
+	fake.captureBackendConnectionReusedMutex.RLock()
+	defer fake.captureBackendConnectionReusedMutex.RUnlock()
+	return len(fake.captureBackendConnectionReusedArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendDialTime(d time.Duration) {
+	fake.captureBackendDialTimeMutex.Lock()
+	fake.captureBackendDialTimeArgsForCall = append(fake.captureBackendDialTimeArgsForCall, struct {
+		d time.Duration
+	}{d})
+	fake.recordInvocation("CaptureBackendDialTime", []interface{}{d})
+	fake.captureBackendDialTimeMutex.Unlock()
+	if fake.CaptureBackendDialTimeStub != nil {
+		fake.CaptureBackendDialTimeStub(d)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendDialTimeCallCount() int {
+	fake.captureBackendDialTimeMutex.RLock()
+	defer fake.captureBackendDialTimeMutex.RUnlock()
+	return len(fake.captureBackendDialTimeArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendDialTimeArgsForCall(i int) time.Duration {
+	fake.captureBackendDialTimeMutex.RLock()
+	defer fake.captureBackendDialTimeMutex.RUnlock()
+	return fake.captureBackendDialTimeArgsForCall[i].d
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendTLSHandshakeTime(d time.Duration) {
+	fake.captureBackendTLSHandshakeTimeMutex.Lock()
+	fake.captureBackendTLSHandshakeTimeArgsForCall = append(fake.captureBackendTLSHandshakeTimeArgsForCall, struct {
+		d time.Duration
+	}{d})
+	fake.recordInvocation("CaptureBackendTLSHandshakeTime", []interface{}{d})
+	fake.captureBackendTLSHandshakeTimeMutex.Unlock()
+	if fake.CaptureBackendTLSHandshakeTimeStub != nil {
+		fake.CaptureBackendTLSHandshakeTimeStub(d)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendTLSHandshakeTimeCallCount() int {
+	fake.captureBackendTLSHandshakeTimeMutex.RLock()
+	defer fake.captureBackendTLSHandshakeTimeMutex.RUnlock()
+	return len(fake.captureBackendTLSHandshakeTimeArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendTLSHandshakeTimeArgsForCall(i int) time.Duration {
+	fake.captureBackendTLSHandshakeTimeMutex.RLock()
+	defer fake.captureBackendTLSHandshakeTimeMutex.RUnlock()
+	return fake.captureBackendTLSHandshakeTimeArgsForCall[i].d
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendIdleConnections(total int, max int) {
+	fake.captureBackendIdleConnectionsMutex.Lock()
+	fake.captureBackendIdleConnectionsArgsForCall = append(fake.captureBackendIdleConnectionsArgsForCall, struct {
+		total int
+		max   int
+	}{total, max})
+	fake.recordInvocation("CaptureBackendIdleConnections", []interface{}{total, max})
+	fake.captureBackendIdleConnectionsMutex.Unlock()
+	if fake.CaptureBackendIdleConnectionsStub != nil {
+		fake.CaptureBackendIdleConnectionsStub(total, max)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendIdleConnectionsCallCount() int {
+	fake.captureBackendIdleConnectionsMutex.RLock()
+	defer fake.captureBackendIdleConnectionsMutex.RUnlock()
+	return len(fake.captureBackendIdleConnectionsArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendIdleConnectionsArgsForCall(i int) (int, int) {
+	fake.captureBackendIdleConnectionsMutex.RLock()
+	defer fake.captureBackendIdleConnectionsMutex.RUnlock()
+	return fake.captureBackendIdleConnectionsArgsForCall[i].total, fake.captureBackendIdleConnectionsArgsForCall[i].max
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendEjected() {
+	fake.captureBackendEjectedMutex.Lock()
+	fake.captureBackendEjectedArgsForCall = append(fake.captureBackendEjectedArgsForCall, struct{}{})
+	fake.recordInvocation("CaptureBackendEjected", []interface{}{})
+	fake.captureBackendEjectedMutex.Unlock()
+	if fake.CaptureBackendEjectedStub != nil {
+		fake.CaptureBackendEjectedStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendEjectedCallCount() int {
+	fake.captureBackendEjectedMutex.RLock()
+	defer fake.captureBackendEjectedMutex.RUnlock()
+	return len(fake.captureBackendEjectedArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendCircuitOpened() {
+	fake.captureBackendCircuitOpenedMutex.Lock()
+	fake.captureBackendCircuitOpenedArgsForCall = append(fake.captureBackendCircuitOpenedArgsForCall, struct{}{})
+	fake.recordInvocation("CaptureBackendCircuitOpened", []interface{}{})
+	fake.captureBackendCircuitOpenedMutex.Unlock()
+	if fake.CaptureBackendCircuitOpenedStub != nil {
+		fake.CaptureBackendCircuitOpenedStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureBackendCircuitOpenedCallCount() int {
+	fake.captureBackendCircuitOpenedMutex.RLock()
+	defer fake.captureBackendCircuitOpenedMutex.RUnlock()
+	return len(fake.captureBackendCircuitOpenedArgsForCall)
+}
+
 func (fake *FakeCombinedReporter) CaptureBadRequest() {
 	fake.captureBadRequestMutex.Lock()
 	fake.captureBadRequestArgsForCall = append(fake.captureBadRequestArgsForCall, struct{}{})
@@ -137,6 +326,20 @@ func (fake *FakeCombinedReporter) CaptureBadRequest() {
 }
 
 func (fake *FakeCombinedReporter) CaptureBadRequestCallCount() int {
+	fake.captureBackendConnectionOpenedMutex.RLock()
+	defer fake.captureBackendConnectionOpenedMutex.RUnlock()
+	fake.captureBackendConnectionReusedMutex.RLock()
+	defer fake.captureBackendConnectionReusedMutex.RUnlock()
+	fake.captureBackendDialTimeMutex.RLock()
+	defer fake.captureBackendDialTimeMutex.RUnlock()
+	fake.captureBackendTLSHandshakeTimeMutex.RLock()
+	defer fake.captureBackendTLSHandshakeTimeMutex.RUnlock()
+	fake.captureBackendIdleConnectionsMutex.RLock()
+	defer fake.captureBackendIdleConnectionsMutex.RUnlock()
+	fake.captureBackendEjectedMutex.RLock()
+	defer fake.captureBackendEjectedMutex.RUnlock()
+	fake.captureBackendCircuitOpenedMutex.RLock()
+	defer fake.captureBackendCircuitOpenedMutex.RUnlock()
 	fake.captureBadRequestMutex.RLock()
 	defer fake.captureBadRequestMutex.RUnlock()
 	return len(fake.captureBadRequestArgsForCall)
@@ -233,6 +436,32 @@ func (fake *FakeCombinedReporter) CaptureRoutingResponseLatencyArgsForCall(i int
 	return fake.captureRoutingResponseLatencyArgsForCall[i].b, fake.captureRoutingResponseLatencyArgsForCall[i].statusCode, fake.captureRoutingResponseLatencyArgsForCall[i].t, fake.captureRoutingResponseLatencyArgsForCall[i].d
 }
 
+func (fake *FakeCombinedReporter) CaptureRouteResponse(uri string, statusCode int, d time.Duration) {
+	fake.captureRouteResponseMutex.Lock()
+	fake.captureRouteResponseArgsForCall = append(fake.captureRouteResponseArgsForCall, struct {
+		uri        string
+		statusCode int
+		d          time.Duration
+	}{uri, statusCode, d})
+	fake.recordInvocation("CaptureRouteResponse", []interface{}{uri, statusCode, d})
+	fake.captureRouteResponseMutex.Unlock()
+	if fake.CaptureRouteResponseStub != nil {
+		fake.CaptureRouteResponseStub(uri, statusCode, d)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureRouteResponseCallCount() int {
+	fake.captureRouteResponseMutex.RLock()
+	defer fake.captureRouteResponseMutex.RUnlock()
+	return len(fake.captureRouteResponseArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureRouteResponseArgsForCall(i int) (string, int, time.Duration) {
+	fake.captureRouteResponseMutex.RLock()
+	defer fake.captureRouteResponseMutex.RUnlock()
+	return fake.captureRouteResponseArgsForCall[i].uri, fake.captureRouteResponseArgsForCall[i].statusCode, fake.captureRouteResponseArgsForCall[i].d
+}
+
 func (fake *FakeCombinedReporter) CaptureRouteServiceResponse(res *http.Response) {
 	fake.captureRouteServiceResponseMutex.Lock()
 	fake.captureRouteServiceResponseArgsForCall = append(fake.captureRouteServiceResponseArgsForCall, struct {
@@ -289,6 +518,94 @@ func (fake *FakeCombinedReporter) CaptureWebSocketFailureCallCount() int {
 	return len(fake.captureWebSocketFailureArgsForCall)
 }
 
+func (fake *FakeCombinedReporter) CaptureRateLimited() {
+	fake.captureRateLimitedMutex.Lock()
+	fake.captureRateLimitedArgsForCall = append(fake.captureRateLimitedArgsForCall, struct{}{})
+	fake.recordInvocation("CaptureRateLimited", []interface{}{})
+	fake.captureRateLimitedMutex.Unlock()
+	if fake.CaptureRateLimitedStub != nil {
+		fake.CaptureRateLimitedStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureRateLimitedCallCount() int {
+	fake.captureRateLimitedMutex.RLock()
+	defer fake.captureRateLimitedMutex.RUnlock()
+	return len(fake.captureRateLimitedArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureTokenValidationFailure() {
+	fake.captureTokenValidationFailureMutex.Lock()
+	fake.captureTokenValidationFailureArgsForCall = append(fake.captureTokenValidationFailureArgsForCall, struct{}{})
+	fake.recordInvocation("CaptureTokenValidationFailure", []interface{}{})
+	fake.captureTokenValidationFailureMutex.Unlock()
+	if fake.CaptureTokenValidationFailureStub != nil {
+		fake.CaptureTokenValidationFailureStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureTokenValidationFailureCallCount() int {
+	fake.captureTokenValidationFailureMutex.RLock()
+	defer fake.captureTokenValidationFailureMutex.RUnlock()
+	return len(fake.captureTokenValidationFailureArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureInFlightRequests(count int) {
+	fake.captureInFlightRequestsMutex.Lock()
+	fake.captureInFlightRequestsArgsForCall = append(fake.captureInFlightRequestsArgsForCall, struct {
+		count int
+	}{count})
+	fake.recordInvocation("CaptureInFlightRequests", []interface{}{count})
+	fake.captureInFlightRequestsMutex.Unlock()
+	if fake.CaptureInFlightRequestsStub != nil {
+		fake.CaptureInFlightRequestsStub(count)
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureInFlightRequestsCallCount() int {
+	fake.captureInFlightRequestsMutex.RLock()
+	defer fake.captureInFlightRequestsMutex.RUnlock()
+	return len(fake.captureInFlightRequestsArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureInFlightRequestsArgsForCall(i int) int {
+	fake.captureInFlightRequestsMutex.RLock()
+	defer fake.captureInFlightRequestsMutex.RUnlock()
+	return fake.captureInFlightRequestsArgsForCall[i].count
+}
+
+func (fake *FakeCombinedReporter) CaptureRequestShed() {
+	fake.captureRequestShedMutex.Lock()
+	fake.captureRequestShedArgsForCall = append(fake.captureRequestShedArgsForCall, struct{}{})
+	fake.recordInvocation("CaptureRequestShed", []interface{}{})
+	fake.captureRequestShedMutex.Unlock()
+	if fake.CaptureRequestShedStub != nil {
+		fake.CaptureRequestShedStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureRequestShedCallCount() int {
+	fake.captureRequestShedMutex.RLock()
+	defer fake.captureRequestShedMutex.RUnlock()
+	return len(fake.captureRequestShedArgsForCall)
+}
+
+func (fake *FakeCombinedReporter) CaptureRequestHeadersTooLarge() {
+	fake.captureRequestHeadersTooLargeMutex.Lock()
+	fake.captureRequestHeadersTooLargeArgsForCall = append(fake.captureRequestHeadersTooLargeArgsForCall, struct{}{})
+	fake.recordInvocation("CaptureRequestHeadersTooLarge", []interface{}{})
+	fake.captureRequestHeadersTooLargeMutex.Unlock()
+	if fake.CaptureRequestHeadersTooLargeStub != nil {
+		fake.CaptureRequestHeadersTooLargeStub()
+	}
+}
+
+func (fake *FakeCombinedReporter) CaptureRequestHeadersTooLargeCallCount() int {
+	fake.captureRequestHeadersTooLargeMutex.RLock()
+	defer fake.captureRequestHeadersTooLargeMutex.RUnlock()
+	return len(fake.captureRequestHeadersTooLargeArgsForCall)
+}
+
 func (fake *FakeCombinedReporter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -310,12 +627,24 @@ func (fake *FakeCombinedReporter) Invocations() map[string][][]interface{} {
 	defer fake.captureRoutingResponseMutex.RUnlock()
 	fake.captureRoutingResponseLatencyMutex.RLock()
 	defer fake.captureRoutingResponseLatencyMutex.RUnlock()
+	fake.captureRouteResponseMutex.RLock()
+	defer fake.captureRouteResponseMutex.RUnlock()
 	fake.captureRouteServiceResponseMutex.RLock()
 	defer fake.captureRouteServiceResponseMutex.RUnlock()
 	fake.captureWebSocketUpdateMutex.RLock()
 	defer fake.captureWebSocketUpdateMutex.RUnlock()
 	fake.captureWebSocketFailureMutex.RLock()
 	defer fake.captureWebSocketFailureMutex.RUnlock()
+	fake.captureRateLimitedMutex.RLock()
+	defer fake.captureRateLimitedMutex.RUnlock()
+	fake.captureTokenValidationFailureMutex.RLock()
+	defer fake.captureTokenValidationFailureMutex.RUnlock()
+	fake.captureInFlightRequestsMutex.RLock()
+	defer fake.captureInFlightRequestsMutex.RUnlock()
+	fake.captureRequestShedMutex.RLock()
+	defer fake.captureRequestShedMutex.RUnlock()
+	fake.captureRequestHeadersTooLargeMutex.RLock()
+	defer fake.captureRequestHeadersTooLargeMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -43,6 +43,17 @@ type FakeRouteRegistryReporter struct {
 	captureUnregistryMessageArgsForCall         []struct {
 		msg metrics.ComponentTagged
 	}
+	CaptureRoutePoolSizeStub        func(uri string, size int)
+	captureRoutePoolSizeMutex       sync.RWMutex
+	captureRoutePoolSizeArgsForCall []struct {
+		uri  string
+		size int
+	}
+	CaptureRoutePoolSizesStub        func(sizes []int)
+	captureRoutePoolSizesMutex       sync.RWMutex
+	captureRoutePoolSizesArgsForCall []struct {
+		sizes []int
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -208,6 +219,60 @@ func (fake *FakeRouteRegistryReporter) CaptureUnregistryMessageArgsForCall(i int
 	return fake.captureUnregistryMessageArgsForCall[i].msg
 }
 
+func (fake *FakeRouteRegistryReporter) CaptureRoutePoolSize(uri string, size int) {
+	fake.captureRoutePoolSizeMutex.Lock()
+	fake.captureRoutePoolSizeArgsForCall = append(fake.captureRoutePoolSizeArgsForCall, struct {
+		uri  string
+		size int
+	}{uri, size})
+	fake.recordInvocation("CaptureRoutePoolSize", []interface{}{uri, size})
+	fake.captureRoutePoolSizeMutex.Unlock()
+	if fake.CaptureRoutePoolSizeStub != nil {
+		fake.CaptureRoutePoolSizeStub(uri, size)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRoutePoolSizeCallCount() int {
+	fake.captureRoutePoolSizeMutex.RLock()
+	defer fake.captureRoutePoolSizeMutex.RUnlock()
+	return len(fake.captureRoutePoolSizeArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRoutePoolSizeArgsForCall(i int) (string, int) {
+	fake.captureRoutePoolSizeMutex.RLock()
+	defer fake.captureRoutePoolSizeMutex.RUnlock()
+	return fake.captureRoutePoolSizeArgsForCall[i].uri, fake.captureRoutePoolSizeArgsForCall[i].size
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRoutePoolSizes(sizes []int) {
+	var sizesCopy []int
+	if sizes != nil {
+		sizesCopy = make([]int, len(sizes))
+		copy(sizesCopy, sizes)
+	}
+	fake.captureRoutePoolSizesMutex.Lock()
+	fake.captureRoutePoolSizesArgsForCall = append(fake.captureRoutePoolSizesArgsForCall, struct {
+		sizes []int
+	}{sizesCopy})
+	fake.recordInvocation("CaptureRoutePoolSizes", []interface{}{sizesCopy})
+	fake.captureRoutePoolSizesMutex.Unlock()
+	if fake.CaptureRoutePoolSizesStub != nil {
+		fake.CaptureRoutePoolSizesStub(sizes)
+	}
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRoutePoolSizesCallCount() int {
+	fake.captureRoutePoolSizesMutex.RLock()
+	defer fake.captureRoutePoolSizesMutex.RUnlock()
+	return len(fake.captureRoutePoolSizesArgsForCall)
+}
+
+func (fake *FakeRouteRegistryReporter) CaptureRoutePoolSizesArgsForCall(i int) []int {
+	fake.captureRoutePoolSizesMutex.RLock()
+	defer fake.captureRoutePoolSizesMutex.RUnlock()
+	return fake.captureRoutePoolSizesArgsForCall[i].sizes
+}
+
 func (fake *FakeRouteRegistryReporter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -225,6 +290,10 @@ func (fake *FakeRouteRegistryReporter) Invocations() map[string][][]interface{}
 	defer fake.unmuzzleRouteRegistrationLatencyMutex.RUnlock()
 	fake.captureUnregistryMessageMutex.RLock()
 	defer fake.captureUnregistryMessageMutex.RUnlock()
+	fake.captureRoutePoolSizeMutex.RLock()
+	defer fake.captureRoutePoolSizeMutex.RUnlock()
+	fake.captureRoutePoolSizesMutex.RLock()
+	defer fake.captureRoutePoolSizesMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -12,8 +12,11 @@ import (
 )
 
 type MetricsReporter struct {
-	Sender    metrics.MetricSender
-	Batcher   metrics.MetricBatcher
+	Sender  metrics.MetricSender
+	Batcher metrics.MetricBatcher
+	// PerRoute selects the routes that CaptureRouteResponse emits metrics
+	// for. Per-route response metrics are disabled when it is nil.
+	PerRoute  *RouteSelector
 	unmuzzled uint64
 }
 
@@ -33,6 +36,37 @@ func (m *MetricsReporter) CaptureBackendInvalidTLSCert() {
 	m.Batcher.BatchIncrementCounter("backend_invalid_tls_cert")
 }
 
+func (m *MetricsReporter) CaptureBackendConnectionOpened() {
+	m.Batcher.BatchIncrementCounter("backend_connections_opened")
+}
+
+func (m *MetricsReporter) CaptureBackendConnectionReused() {
+	m.Batcher.BatchIncrementCounter("backend_connections_reused")
+}
+
+func (m *MetricsReporter) CaptureBackendDialTime(d time.Duration) {
+	m.Sender.SendValue("backend_dial_latency", float64(d)/float64(time.Millisecond), "ms")
+}
+
+func (m *MetricsReporter) CaptureBackendTLSHandshakeTime(d time.Duration) {
+	m.Sender.SendValue("backend_tls_handshake_latency", float64(d)/float64(time.Millisecond), "ms")
+}
+
+// CaptureBackendIdleConnections emits the idle connections kept to all
+// backends, and the most kept to one backend.
+func (m *MetricsReporter) CaptureBackendIdleConnections(total, max int) {
+	m.Sender.SendValue("backend_idle_connections", float64(total), "Connection")
+	m.Sender.SendValue("backend_idle_connections_max", float64(max), "Connection")
+}
+
+func (m *MetricsReporter) CaptureBackendEjected() {
+	m.Batcher.BatchIncrementCounter("backend_ejections")
+}
+
+func (m *MetricsReporter) CaptureBackendCircuitOpened() {
+	m.Batcher.BatchIncrementCounter("backend_circuits_opened")
+}
+
 func (m *MetricsReporter) CaptureBadRequest() {
 	m.Batcher.BatchIncrementCounter("rejected_requests")
 }
@@ -79,6 +113,18 @@ func (m *MetricsReporter) CaptureRoutingResponseLatency(b *route.Endpoint, _ int
 	}
 }
 
+// CaptureRouteResponse emits the latency and the status code class of a
+// response of the route, or of OtherRoutes if the route is not selected.
+func (m *MetricsReporter) CaptureRouteResponse(uri string, statusCode int, d time.Duration) {
+	if m.PerRoute == nil {
+		return
+	}
+
+	uri = m.PerRoute.Select(uri)
+	m.Sender.SendValue(fmt.Sprintf("route_latency.%s", uri), float64(d/time.Millisecond), "ms")
+	m.Batcher.BatchIncrementCounter(fmt.Sprintf("route_responses.%s.%s", uri, getResponseCounterName(statusCode)))
+}
+
 func (m *MetricsReporter) CaptureLookupTime(t time.Duration) {
 	unit := "ns"
 	m.Sender.SendValue("route_lookup_time", float64(t.Nanoseconds()), unit)
@@ -99,6 +145,33 @@ func (m *MetricsReporter) CaptureRouteStats(totalRoutes int, msSinceLastUpdate u
 	m.Sender.SendValue("ms_since_last_registry_update", float64(msSinceLastUpdate), "ms")
 }
 
+// CaptureRoutePoolSize emits a gauge of the number of endpoints backing a
+// single route.
+func (m *MetricsReporter) CaptureRoutePoolSize(uri string, size int) {
+	m.Sender.SendValue(fmt.Sprintf("route_endpoints.%s", uri), float64(size), "")
+}
+
+// routePoolSizeBuckets are the upper bounds of the route pool size histogram.
+var routePoolSizeBuckets = []int{1, 2, 5, 10, 25, 50, 100}
+
+// CaptureRoutePoolSizes emits a cumulative histogram of the number of
+// endpoints backing each route, with one gauge per bucket.
+func (m *MetricsReporter) CaptureRoutePoolSizes(sizes []int) {
+	counts := make([]int, len(routePoolSizeBuckets))
+	for _, size := range sizes {
+		for i, bound := range routePoolSizeBuckets {
+			if size <= bound {
+				counts[i]++
+			}
+		}
+	}
+
+	for i, bound := range routePoolSizeBuckets {
+		m.Sender.SendValue(fmt.Sprintf("route_pool_size.le_%d", bound), float64(counts[i]), "")
+	}
+	m.Sender.SendValue("route_pool_size.le_inf", float64(len(sizes)), "")
+}
+
 func (m *MetricsReporter) CaptureRoutesPruned(routesPruned uint64) {
 	m.Batcher.BatchAddCounter("routes_pruned", routesPruned)
 }
@@ -123,6 +196,26 @@ func (m *MetricsReporter) CaptureUnregistryMessage(msg ComponentTagged) {
 	m.Sender.IncrementCounter(componentName)
 }
 
+func (m *MetricsReporter) CaptureRegistrationMessageShed() {
+	m.Batcher.BatchIncrementCounter("registration_messages_shed")
+}
+
+func (m *MetricsReporter) CaptureMalformedRegistrationMessage() {
+	m.Batcher.BatchIncrementCounter("malformed_registration")
+}
+
+func (m *MetricsReporter) CaptureInvalidRegistrationMessage() {
+	m.Batcher.BatchIncrementCounter("invalid_registration")
+}
+
+func (m *MetricsReporter) CaptureNATSFailover() {
+	m.Batcher.BatchIncrementCounter("nats_failovers")
+}
+
+func (m *MetricsReporter) CaptureNATSFailback() {
+	m.Batcher.BatchIncrementCounter("nats_failbacks")
+}
+
 func (m *MetricsReporter) CaptureWebSocketUpdate() {
 	m.Batcher.BatchIncrementCounter("websocket_upgrades")
 }
@@ -131,6 +224,36 @@ func (m *MetricsReporter) CaptureWebSocketFailure() {
 	m.Batcher.BatchIncrementCounter("websocket_failures")
 }
 
+func (m *MetricsReporter) CaptureRateLimited() {
+	m.Batcher.BatchIncrementCounter("rate_limited_requests")
+}
+
+// CaptureTokenValidationFailure counts the requests whose bearer token could
+// not be validated because the keys could not be fetched.
+func (m *MetricsReporter) CaptureTokenValidationFailure() {
+	m.Batcher.BatchIncrementCounter("token_validation_failures")
+}
+
+func (m *MetricsReporter) CaptureInFlightRequests(count int) {
+	m.Sender.SendValue("in_flight_requests", float64(count), "Request")
+}
+
+func (m *MetricsReporter) CaptureRequestShed() {
+	m.Batcher.BatchIncrementCounter("shed_requests")
+}
+
+func (m *MetricsReporter) CaptureRequestHeadersTooLarge() {
+	m.Batcher.BatchIncrementCounter("request_headers_too_large")
+}
+
+func (m *MetricsReporter) CaptureOCSPStapleAge(age time.Duration) {
+	m.Sender.SendValue("ocsp_staple_age", age.Seconds(), "s")
+}
+
+func (m *MetricsReporter) CaptureOCSPStapleFailure() {
+	m.Batcher.BatchIncrementCounter("ocsp_staple_failures")
+}
+
 func getResponseCounterName(statusCode int) string {
 	statusCode = statusCode / 100
 	if statusCode >= 2 && statusCode <= 5 {
@@ -337,6 +337,33 @@ var _ = Describe("MetricsReporter", func() {
 		Expect(unit).To(Equal("ms"))
 	})
 
+	Context("per-route response metrics", func() {
+		It("does not send them without a route selector", func() {
+			metricReporter.CaptureRouteResponse("example.com", 200, 2*time.Second)
+
+			Expect(sender.SendValueCallCount()).To(Equal(0))
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(0))
+		})
+
+		It("sends the latency and status code class of the selected routes", func() {
+			metricReporter.PerRoute = metrics.NewRouteSelector(1, 10, time.Minute, nil)
+			metricReporter.CaptureRouteResponse("example.com/api", 503, 2*time.Second)
+			metricReporter.CaptureRouteResponse("example.org", 200, time.Second)
+
+			Expect(sender.SendValueCallCount()).To(Equal(2))
+			name, value, unit := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("route_latency.example.com/api"))
+			Expect(value).To(BeEquivalentTo(2000))
+			Expect(unit).To(Equal("ms"))
+			name, _, _ = sender.SendValueArgsForCall(1)
+			Expect(name).To(Equal("route_latency.other"))
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("route_responses.example.com/api.5xx"))
+			Expect(batcher.BatchIncrementCounterArgsForCall(1)).To(Equal("route_responses.other.2xx"))
+		})
+	})
+
 	Context("sends route metrics", func() {
 		var endpoint *route.Endpoint
 
@@ -384,6 +411,36 @@ var _ = Describe("MetricsReporter", func() {
 			Expect(unit).To(Equal("ms"))
 		})
 
+		It("sends the number of endpoints of a route", func() {
+			metricReporter.CaptureRoutePoolSize("foo.example.com", 3)
+
+			Expect(sender.SendValueCallCount()).To(Equal(1))
+			name, value, unit := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("route_endpoints.foo.example.com"))
+			Expect(value).To(BeEquivalentTo(3))
+			Expect(unit).To(Equal(""))
+		})
+
+		It("sends a cumulative histogram of route pool sizes", func() {
+			metricReporter.CaptureRoutePoolSizes([]int{1, 1, 3, 12, 400})
+
+			values := map[string]float64{}
+			for i := 0; i < sender.SendValueCallCount(); i++ {
+				name, value, _ := sender.SendValueArgsForCall(i)
+				values[name] = value
+			}
+			Expect(values).To(Equal(map[string]float64{
+				"route_pool_size.le_1":   2,
+				"route_pool_size.le_2":   2,
+				"route_pool_size.le_5":   3,
+				"route_pool_size.le_10":  3,
+				"route_pool_size.le_25":  4,
+				"route_pool_size.le_50":  4,
+				"route_pool_size.le_100": 4,
+				"route_pool_size.le_inf": 5,
+			}))
+		})
+
 		It("sends the lookup time for routing table", func() {
 			metricReporter.CaptureLookupTime(time.Duration(9) * time.Second)
 
@@ -409,6 +466,92 @@ var _ = Describe("MetricsReporter", func() {
 		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("backend_tls_handshake_failed"))
 	})
 
+	It("increments the backend_connections_opened metric", func() {
+		metricReporter.CaptureBackendConnectionOpened()
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("backend_connections_opened"))
+	})
+
+	It("increments the backend_connections_reused metric", func() {
+		metricReporter.CaptureBackendConnectionReused()
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("backend_connections_reused"))
+	})
+
+	It("sends the dial and TLS handshake latencies of backend connections", func() {
+		metricReporter.CaptureBackendDialTime(1500 * time.Microsecond)
+		metricReporter.CaptureBackendTLSHandshakeTime(4 * time.Millisecond)
+
+		Expect(sender.SendValueCallCount()).To(Equal(2))
+		name, value, unit := sender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("backend_dial_latency"))
+		Expect(value).To(BeNumerically("==", 1.5))
+		Expect(unit).To(Equal("ms"))
+
+		name, value, unit = sender.SendValueArgsForCall(1)
+		Expect(name).To(Equal("backend_tls_handshake_latency"))
+		Expect(value).To(BeNumerically("==", 4))
+		Expect(unit).To(Equal("ms"))
+	})
+
+	It("sends the idle backend connections", func() {
+		metricReporter.CaptureBackendIdleConnections(12, 3)
+
+		Expect(sender.SendValueCallCount()).To(Equal(2))
+		name, value, _ := sender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("backend_idle_connections"))
+		Expect(value).To(BeNumerically("==", 12))
+
+		name, value, _ = sender.SendValueArgsForCall(1)
+		Expect(name).To(Equal("backend_idle_connections_max"))
+		Expect(value).To(BeNumerically("==", 3))
+	})
+
+	It("increments the backend_ejections metric", func() {
+		metricReporter.CaptureBackendEjected()
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("backend_ejections"))
+	})
+
+	It("increments the backend_circuits_opened metric", func() {
+		metricReporter.CaptureBackendCircuitOpened()
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("backend_circuits_opened"))
+	})
+
+	It("sends the number of requests in flight", func() {
+		metricReporter.CaptureInFlightRequests(12)
+		Expect(sender.SendValueCallCount()).To(Equal(1))
+		name, value, unit := sender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("in_flight_requests"))
+		Expect(value).To(BeEquivalentTo(12))
+		Expect(unit).To(Equal("Request"))
+	})
+
+	It("increments the shed_requests metric", func() {
+		metricReporter.CaptureRequestShed()
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("shed_requests"))
+	})
+
+	It("increments the request_headers_too_large metric", func() {
+		metricReporter.CaptureRequestHeadersTooLarge()
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("request_headers_too_large"))
+	})
+
+	It("increments the rate_limited_requests metric", func() {
+		metricReporter.CaptureRateLimited()
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("rate_limited_requests"))
+	})
+
+	It("increments the token_validation_failures metric", func() {
+		metricReporter.CaptureTokenValidationFailure()
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("token_validation_failures"))
+	})
+
 	Describe("Unregister messages", func() {
 		var endpoint *route.Endpoint
 		Context("when unregister msg with component name is incremented", func() {
@@ -446,6 +589,41 @@ var _ = Describe("MetricsReporter", func() {
 		})
 	})
 
+	It("increments the registration_messages_shed metric", func() {
+		metricReporter.CaptureRegistrationMessageShed()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("registration_messages_shed"))
+	})
+
+	It("increments the malformed_registration metric", func() {
+		metricReporter.CaptureMalformedRegistrationMessage()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("malformed_registration"))
+	})
+
+	It("increments the invalid_registration metric", func() {
+		metricReporter.CaptureInvalidRegistrationMessage()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("invalid_registration"))
+	})
+
+	It("increments the nats_failovers metric", func() {
+		metricReporter.CaptureNATSFailover()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("nats_failovers"))
+	})
+
+	It("increments the nats_failbacks metric", func() {
+		metricReporter.CaptureNATSFailback()
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("nats_failbacks"))
+	})
+
 	Context("websocket metrics", func() {
 		It("increments the total responses metric", func() {
 			metricReporter.CaptureWebSocketUpdate()
@@ -459,6 +637,25 @@ var _ = Describe("MetricsReporter", func() {
 		})
 	})
 
+	Describe("OCSP stapling", func() {
+		It("sends the age of the oldest staple", func() {
+			metricReporter.CaptureOCSPStapleAge(90 * time.Second)
+
+			Expect(sender.SendValueCallCount()).To(Equal(1))
+			name, value, unit := sender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("ocsp_staple_age"))
+			Expect(value).To(BeEquivalentTo(90))
+			Expect(unit).To(Equal("s"))
+		})
+
+		It("increments the ocsp_staple_failures metric", func() {
+			metricReporter.CaptureOCSPStapleFailure()
+
+			Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+			Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("ocsp_staple_failures"))
+		})
+	})
+
 	Describe("CaptureRouteRegistrationLatency", func() {
 		It("is muzzled by default", func() {
 			metricReporter.CaptureRouteRegistrationLatency(2 * time.Second)
@@ -6,6 +6,7 @@ import (
 
 	"code.cloudfoundry.org/gorouter/logger"
 	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/nats-io/go-nats"
 	"github.com/uber-go/zap"
 )
 
@@ -15,11 +16,22 @@ type Subscriber interface {
 	Dropped() (int, error)
 }
 
+//go:generate counterfeiter -o ../fakes/fake_nats_connection.go . NATSConnection
+type NATSConnection interface {
+	Status() nats.Status
+	Reconnects() uint64
+	LastConnect() time.Time
+}
+
 type NATSMonitor struct {
 	Subscriber Subscriber
 	Sender     metrics.MetricSender
 	TickChan   <-chan time.Time
 	Logger     logger.Logger
+
+	// Connection is optional. When set, the state of the NATS connection is
+	// also emitted.
+	Connection NATSConnection
 }
 
 func (n *NATSMonitor) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
@@ -46,9 +58,41 @@ func (n *NATSMonitor) Run(signals <-chan os.Signal, ready chan<- struct{}) error
 			if err != nil {
 				n.Logger.Error("error-sending-total-dropped-messages-metric", zap.Error(err))
 			}
+
+			if n.Connection != nil {
+				n.sendConnectionMetrics()
+			}
 		case <-signals:
 			n.Logger.Info("exited")
 			return nil
 		}
 	}
 }
+
+func (n *NATSMonitor) sendConnectionMetrics() {
+	status := n.Connection.Status()
+	connected, reconnecting := 0.0, 0.0
+	switch status {
+	case nats.CONNECTED:
+		connected = 1
+	case nats.RECONNECTING:
+		reconnecting = 1
+	}
+
+	values := []struct {
+		name  string
+		value float64
+		unit  string
+	}{
+		{"nats_connected", connected, "boolean"},
+		{"nats_reconnecting", reconnecting, "boolean"},
+		{"nats_reconnects", float64(n.Connection.Reconnects()), "reconnect"},
+		{"nats_last_connect_time", float64(n.Connection.LastConnect().Unix()), "s"},
+	}
+	for _, v := range values {
+		err := n.Sender.Value(v.name, v.value, v.unit).Send()
+		if err != nil {
+			n.Logger.Error("error-sending-nats-connection-metric", zap.String("metric", v.name), zap.Error(err))
+		}
+	}
+}
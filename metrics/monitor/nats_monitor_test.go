@@ -9,6 +9,7 @@ import (
 	"code.cloudfoundry.org/gorouter/metrics/fakes"
 	"code.cloudfoundry.org/gorouter/metrics/monitor"
 	"code.cloudfoundry.org/gorouter/test_util"
+	"github.com/nats-io/go-nats"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
@@ -133,6 +134,73 @@ var _ = Describe("NATSMonitor", func() {
 		})
 	})
 
+	Context("when the NATS connection is monitored", func() {
+		var (
+			connection  *fakes.FakeNATSConnection
+			tick        chan time.Time
+			connProcess ifrit.Process
+			lastConnect time.Time
+		)
+
+		sentValues := func() map[string]float64 {
+			values := map[string]float64{}
+			for i := 0; i < sender.ValueCallCount(); i++ {
+				name, val, _ := sender.ValueArgsForCall(i)
+				values[name] = val
+			}
+			return values
+		}
+
+		BeforeEach(func() {
+			lastConnect = time.Unix(1500000000, 0)
+			connection = new(fakes.FakeNATSConnection)
+			connection.StatusReturns(nats.CONNECTED)
+			connection.ReconnectsReturns(3)
+			connection.LastConnectReturns(lastConnect)
+
+			tick = make(chan time.Time)
+			connProcess = ifrit.Invoke(&monitor.NATSMonitor{
+				Subscriber: subscriber,
+				Sender:     sender,
+				TickChan:   tick,
+				Logger:     logger,
+				Connection: connection,
+			})
+			Eventually(connProcess.Ready()).Should(BeClosed())
+		})
+
+		AfterEach(func() {
+			connProcess.Signal(os.Interrupt)
+			Eventually(connProcess.Wait()).Should(Receive())
+		})
+
+		It("sends the connection state", func() {
+			tick <- time.Time{}
+			tick <- time.Time{}
+
+			values := sentValues()
+			Expect(values).To(HaveKeyWithValue("nats_connected", float64(1)))
+			Expect(values).To(HaveKeyWithValue("nats_reconnecting", float64(0)))
+			Expect(values).To(HaveKeyWithValue("nats_reconnects", float64(3)))
+			Expect(values).To(HaveKeyWithValue("nats_last_connect_time", float64(lastConnect.Unix())))
+		})
+
+		Context("when the connection is lost", func() {
+			BeforeEach(func() {
+				connection.StatusReturns(nats.RECONNECTING)
+			})
+
+			It("sends a degraded connection state", func() {
+				tick <- time.Time{}
+				tick <- time.Time{}
+
+				values := sentValues()
+				Expect(values).To(HaveKeyWithValue("nats_connected", float64(0)))
+				Expect(values).To(HaveKeyWithValue("nats_reconnecting", float64(1)))
+			})
+		})
+	})
+
 	Context("when it fails to retrieve dropped messages", func() {
 		BeforeEach(func() {
 			subscriber.DroppedReturns(-1, errors.New("failed"))
@@ -0,0 +1,285 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PrometheusLatencyBuckets are the upper bounds, in seconds, of the latency
+// histograms.
+var PrometheusLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusRegistry keeps the metrics sent through the MetricSender and
+// MetricBatcher it wraps, and serves them in the Prometheus text format.
+// Counters are exposed with a _total suffix, and values as gauges, except for
+// the latencies declared as histograms in prometheusMetrics, which are
+// exposed in seconds.
+type PrometheusRegistry struct {
+	lock     sync.Mutex
+	families map[string]*prometheusFamily
+}
+
+// prometheusFamily is a metric and its series, by their labels.
+type prometheusFamily struct {
+	kind   string
+	series map[string]*prometheusSeries
+}
+
+type prometheusSeries struct {
+	counter   uint64
+	gauge     float64
+	histogram *histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// prometheusMetric declares how the dropsonde metrics called
+// dropsondeName, or dropsondeName.<label values> when it has labels, are
+// exposed. The label values are the parts of the name after dropsondeName
+// that are separated by dots, except for the first label, which takes the
+// parts left over, as routes have dots of their own.
+type prometheusMetric struct {
+	dropsondeName string
+	name          string
+	labels        []string
+	histogram     bool
+}
+
+// prometheusLabelEscaper escapes label values in the text format.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var prometheusMetrics = []prometheusMetric{
+	{dropsondeName: "latency", name: "latency", histogram: true},
+	{dropsondeName: "latency", name: "component_latency", labels: []string{"component"}, histogram: true},
+	{dropsondeName: "backend_dial_latency", name: "backend_dial_latency", histogram: true},
+	{dropsondeName: "backend_tls_handshake_latency", name: "backend_tls_handshake_latency", histogram: true},
+	{dropsondeName: "route_registration_latency", name: "route_registration_latency", histogram: true},
+	{dropsondeName: "route_latency", name: "route_latency", labels: []string{"route"}, histogram: true},
+	{dropsondeName: "route_responses", name: "route_responses", labels: []string{"route", "status"}},
+	{dropsondeName: "route_endpoints", name: "route_endpoints", labels: []string{"route"}},
+}
+
+func NewPrometheusRegistry() *PrometheusRegistry {
+	return &PrometheusRegistry{
+		families: map[string]*prometheusFamily{},
+	}
+}
+
+// Sender returns a MetricSender that records values and counters before
+// passing them to s.
+func (p *PrometheusRegistry) Sender(s metrics.MetricSender) metrics.MetricSender {
+	return &prometheusSender{MetricSender: s, registry: p}
+}
+
+// Batcher returns a MetricBatcher that records counters before passing them
+// to b.
+func (p *PrometheusRegistry) Batcher(b metrics.MetricBatcher) metrics.MetricBatcher {
+	return &prometheusBatcher{MetricBatcher: b, registry: p}
+}
+
+func (p *PrometheusRegistry) AddToCounter(name string, delta uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	metric, labels := lookupPrometheusMetric(name)
+	p.series(prometheusName(metric.name)+"_total", "counter", labels).counter += delta
+}
+
+func (p *PrometheusRegistry) RecordValue(name string, value float64, unit string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	metric, labels := lookupPrometheusMetric(name)
+	if !metric.histogram {
+		p.series(prometheusName(metric.name), "gauge", labels).gauge = value
+		return
+	}
+
+	s := p.series(prometheusName(metric.name)+"_seconds", "histogram", labels)
+	if s.histogram == nil {
+		s.histogram = &histogram{counts: make([]uint64, len(PrometheusLatencyBuckets))}
+	}
+
+	seconds := value / 1000
+	for i, bound := range PrometheusLatencyBuckets {
+		if seconds <= bound {
+			s.histogram.counts[i]++
+		}
+	}
+	s.histogram.count++
+	s.histogram.sum += seconds
+}
+
+func (p *PrometheusRegistry) series(name, kind, labels string) *prometheusSeries {
+	f, ok := p.families[name]
+	if !ok {
+		f = &prometheusFamily{kind: kind, series: map[string]*prometheusSeries{}}
+		p.families[name] = f
+	}
+	s, ok := f.series[labels]
+	if !ok {
+		s = &prometheusSeries{}
+		f.series[labels] = s
+	}
+	return s
+}
+
+// lookupPrometheusMetric returns the declared metric for a dropsonde metric,
+// and its labels in the text format, or an undeclared metric of that name.
+func lookupPrometheusMetric(name string) (prometheusMetric, string) {
+	for _, metric := range prometheusMetrics {
+		if len(metric.labels) == 0 {
+			if name == metric.dropsondeName {
+				return metric, ""
+			}
+			continue
+		}
+
+		rest := strings.TrimPrefix(name, metric.dropsondeName+".")
+		if rest == name {
+			continue
+		}
+		values := make([]string, len(metric.labels))
+		for i := len(values) - 1; i > 0; i-- {
+			dot := strings.LastIndexByte(rest, '.')
+			if dot < 0 {
+				break
+			}
+			values[i], rest = rest[dot+1:], rest[:dot]
+		}
+		values[0] = rest
+
+		labels := make([]string, len(values))
+		for i, value := range values {
+			labels[i] = metric.labels[i] + `="` + prometheusLabelEscaper.Replace(value) + `"`
+		}
+		return metric, strings.Join(labels, ",")
+	}
+	return prometheusMetric{name: name}, ""
+}
+
+func (p *PrometheusRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", prometheusContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(p.render())
+}
+
+// render formats the metrics under the lock, so that a slow scrape does not
+// hold up the requests that record them.
+func (p *PrometheusRegistry) render() []byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	w := &bytes.Buffer{}
+
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := p.families[name]
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, f.kind)
+
+		labels := make([]string, 0, len(f.series))
+		for l := range f.series {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			s := f.series[l]
+			switch f.kind {
+			case "counter":
+				fmt.Fprintf(w, "%s%s %d\n", name, withLabels(l), s.counter)
+			case "gauge":
+				fmt.Fprintf(w, "%s%s %s\n", name, withLabels(l), formatFloat(s.gauge))
+			case "histogram":
+				h := s.histogram
+				for i, bound := range PrometheusLatencyBuckets {
+					fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabels(l, `le="`+formatFloat(bound)+`"`), h.counts[i])
+				}
+				fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabels(l, `le="+Inf"`), h.count)
+				fmt.Fprintf(w, "%s_sum%s %s\n", name, withLabels(l), formatFloat(h.sum))
+				fmt.Fprintf(w, "%s_count%s %d\n", name, withLabels(l), h.count)
+			}
+		}
+	}
+	return w.Bytes()
+}
+
+// withLabels formats labels, which are in the text format, for a series.
+func withLabels(labels ...string) string {
+	nonEmpty := labels[:0:0]
+	for _, l := range labels {
+		if l != "" {
+			nonEmpty = append(nonEmpty, l)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(nonEmpty, ",") + "}"
+}
+
+// prometheusName converts a dropsonde metric name such as responses.2xx to
+// a valid Prometheus name such as gorouter_responses_2xx.
+func prometheusName(name string) string {
+	return "gorouter_" + strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type prometheusSender struct {
+	metrics.MetricSender
+	registry *PrometheusRegistry
+}
+
+func (s *prometheusSender) SendValue(name string, value float64, unit string) error {
+	s.registry.RecordValue(name, value, unit)
+	return s.MetricSender.SendValue(name, value, unit)
+}
+
+func (s *prometheusSender) IncrementCounter(name string) error {
+	s.registry.AddToCounter(name, 1)
+	return s.MetricSender.IncrementCounter(name)
+}
+
+func (s *prometheusSender) AddToCounter(name string, delta uint64) error {
+	s.registry.AddToCounter(name, delta)
+	return s.MetricSender.AddToCounter(name, delta)
+}
+
+type prometheusBatcher struct {
+	metrics.MetricBatcher
+	registry *PrometheusRegistry
+}
+
+func (b *prometheusBatcher) BatchIncrementCounter(name string) {
+	b.registry.AddToCounter(name, 1)
+	b.MetricBatcher.BatchIncrementCounter(name)
+}
+
+func (b *prometheusBatcher) BatchAddCounter(name string, delta uint64) {
+	b.registry.AddToCounter(name, delta)
+	b.MetricBatcher.BatchAddCounter(name, delta)
+}
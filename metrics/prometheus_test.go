@@ -0,0 +1,124 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PrometheusRegistry", func() {
+	var (
+		registry       *metrics.PrometheusRegistry
+		sender         *fakes.MetricSender
+		batcher        *fakes.MetricBatcher
+		metricReporter *metrics.MetricsReporter
+	)
+
+	scrape := func() string {
+		res := httptest.NewRecorder()
+		registry.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Content-Type")).To(Equal("text/plain; version=0.0.4; charset=utf-8"))
+		return res.Body.String()
+	}
+
+	BeforeEach(func() {
+		registry = metrics.NewPrometheusRegistry()
+		sender = new(fakes.MetricSender)
+		batcher = new(fakes.MetricBatcher)
+		metricReporter = &metrics.MetricsReporter{
+			Sender:  registry.Sender(sender),
+			Batcher: registry.Batcher(batcher),
+		}
+	})
+
+	It("exposes counters", func() {
+		metricReporter.CaptureRoutingResponse(http.StatusOK)
+		metricReporter.CaptureRoutingResponse(http.StatusOK)
+		metricReporter.CaptureBadGateway()
+
+		body := scrape()
+		Expect(body).To(ContainSubstring("# TYPE gorouter_responses_2xx_total counter\ngorouter_responses_2xx_total 2\n"))
+		Expect(body).To(ContainSubstring("# TYPE gorouter_responses_total counter\ngorouter_responses_total 2\n"))
+		Expect(body).To(ContainSubstring("# TYPE gorouter_bad_gateways_total counter\ngorouter_bad_gateways_total 1\n"))
+	})
+
+	It("exposes latencies as histograms in seconds", func() {
+		endpoint := route.NewEndpoint(&route.EndpointOpts{})
+		metricReporter.CaptureRoutingResponseLatency(endpoint, 0, time.Time{}, 20*time.Millisecond)
+		metricReporter.CaptureRoutingResponseLatency(endpoint, 0, time.Time{}, 2*time.Second)
+
+		body := scrape()
+		Expect(body).To(ContainSubstring("# TYPE gorouter_latency_seconds histogram\n"))
+		Expect(body).To(ContainSubstring(`gorouter_latency_seconds_bucket{le="0.01"} 0` + "\n"))
+		Expect(body).To(ContainSubstring(`gorouter_latency_seconds_bucket{le="0.025"} 1` + "\n"))
+		Expect(body).To(ContainSubstring(`gorouter_latency_seconds_bucket{le="2.5"} 2` + "\n"))
+		Expect(body).To(ContainSubstring(`gorouter_latency_seconds_bucket{le="+Inf"} 2` + "\n"))
+		Expect(body).To(ContainSubstring("gorouter_latency_seconds_sum 2.02\n"))
+		Expect(body).To(ContainSubstring("gorouter_latency_seconds_count 2\n"))
+	})
+
+	It("exposes the route table size as a gauge", func() {
+		metricReporter.CaptureRouteStats(12, 5)
+		metricReporter.CaptureRouteStats(10, 5)
+
+		body := scrape()
+		Expect(body).To(ContainSubstring("# TYPE gorouter_total_routes gauge\ngorouter_total_routes 10\n"))
+		Expect(body).To(ContainSubstring("# TYPE gorouter_ms_since_last_registry_update gauge\n"))
+	})
+
+	It("exposes the latencies of components in one histogram with a label", func() {
+		endpoint := route.NewEndpoint(&route.EndpointOpts{Tags: map[string]string{"component": "CloudController"}})
+		metricReporter.CaptureRoutingResponseLatency(endpoint, 0, time.Time{}, 20*time.Millisecond)
+
+		body := scrape()
+		Expect(body).To(ContainSubstring("# TYPE gorouter_component_latency_seconds histogram\n"))
+		Expect(body).To(ContainSubstring(`gorouter_component_latency_seconds_bucket{component="CloudController",le="0.025"} 1` + "\n"))
+		Expect(body).To(ContainSubstring(`gorouter_component_latency_seconds_count{component="CloudController"} 1` + "\n"))
+	})
+
+	It("exposes per-route metrics with route and status labels", func() {
+		metricReporter.PerRoute = metrics.NewRouteSelector(10, 10, time.Minute, nil)
+		metricReporter.CaptureRouteResponse("dora.example.com/api", http.StatusOK, 20*time.Millisecond)
+		metricReporter.CaptureRouteResponse("dora.example.com/api", http.StatusBadGateway, 2*time.Second)
+		metricReporter.CaptureRoutePoolSize("dora.example.com/api", 3)
+
+		body := scrape()
+		Expect(body).To(ContainSubstring("# TYPE gorouter_route_latency_seconds histogram\n"))
+		Expect(body).To(ContainSubstring(`gorouter_route_latency_seconds_bucket{route="dora.example.com/api",le="0.025"} 1` + "\n"))
+		Expect(body).To(ContainSubstring(`gorouter_route_latency_seconds_count{route="dora.example.com/api"} 2` + "\n"))
+		Expect(body).To(ContainSubstring("# TYPE gorouter_route_responses_total counter\n" +
+			`gorouter_route_responses_total{route="dora.example.com/api",status="2xx"} 1` + "\n" +
+			`gorouter_route_responses_total{route="dora.example.com/api",status="5xx"} 1` + "\n"))
+		Expect(body).To(ContainSubstring("# TYPE gorouter_route_endpoints gauge\n" +
+			`gorouter_route_endpoints{route="dora.example.com/api"} 3` + "\n"))
+	})
+
+	It("escapes label values", func() {
+		metricReporter.CaptureRoutePoolSize(`dora.example.com/"quoted"\`, 1)
+
+		Expect(scrape()).To(ContainSubstring(`gorouter_route_endpoints{route="dora.example.com/\"quoted\"\\"} 1` + "\n"))
+	})
+
+	It("exposes values that are not declared histograms as gauges", func() {
+		metricReporter.CaptureRouteStats(12, 5)
+
+		Expect(scrape()).To(ContainSubstring("# TYPE gorouter_ms_since_last_registry_update gauge\ngorouter_ms_since_last_registry_update 5\n"))
+	})
+
+	It("still sends the metrics to the wrapped sender and batcher", func() {
+		metricReporter.CaptureBadRequest()
+		metricReporter.CaptureRouteStats(12, 5)
+
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(1))
+		Expect(batcher.BatchIncrementCounterArgsForCall(0)).To(Equal("rejected_requests"))
+		Expect(sender.SendValueCallCount()).To(Equal(2))
+	})
+})
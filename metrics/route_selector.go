@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// OtherRoutes is the route that the requests of routes without metrics of
+// their own are reported as.
+const OtherRoutes = "other"
+
+// RouteSelector decides which routes get per-route metrics of their own, to
+// bound the number of metrics. The routes of the allowlist always do. Of the
+// others, the topN with the most requests in the previous window do, and
+// until topN routes are selected, the first routes to get requests in a
+// window do too. At most maxRoutes routes are ever selected, after which only
+// those can be selected again.
+type RouteSelector struct {
+	topN      int
+	maxRoutes int
+	window    time.Duration
+	allowlist map[string]bool
+
+	lock        sync.Mutex
+	windowStart time.Time
+	requests    map[string]uint64
+	selected    map[string]bool
+	seen        map[string]bool
+}
+
+func NewRouteSelector(topN, maxRoutes int, window time.Duration, allowlist []string) *RouteSelector {
+	s := &RouteSelector{
+		topN:        topN,
+		maxRoutes:   maxRoutes,
+		window:      window,
+		allowlist:   map[string]bool{},
+		windowStart: time.Now(),
+		requests:    map[string]uint64{},
+		selected:    map[string]bool{},
+		seen:        map[string]bool{},
+	}
+	for _, uri := range allowlist {
+		s.allowlist[route.Uri(uri).RouteKey().String()] = true
+	}
+	return s
+}
+
+// Select counts a request of the route, and returns the route that it is
+// reported as: the route itself if it is selected, or OtherRoutes.
+func (s *RouteSelector) Select(uri string) string {
+	if s.allowlist[uri] {
+		return uri
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if now := time.Now(); now.Sub(s.windowStart) >= s.window {
+		s.selectTop()
+		s.windowStart = now
+	}
+
+	if len(s.seen) >= s.maxRoutes && !s.seen[uri] {
+		return OtherRoutes
+	}
+	s.requests[uri]++
+
+	if !s.selected[uri] {
+		if len(s.selected) >= s.topN {
+			return OtherRoutes
+		}
+		s.selected[uri] = true
+		s.seen[uri] = true
+	}
+	return uri
+}
+
+// selectTop selects the topN routes with the most requests in the window
+// that ended, and starts counting the requests of the next. The caller must
+// hold the lock.
+func (s *RouteSelector) selectTop() {
+	uris := make([]string, 0, len(s.requests))
+	for uri := range s.requests {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool {
+		if s.requests[uris[i]] != s.requests[uris[j]] {
+			return s.requests[uris[i]] > s.requests[uris[j]]
+		}
+		return uris[i] < uris[j]
+	})
+
+	s.selected = make(map[string]bool, s.topN)
+	for _, uri := range uris {
+		if len(s.selected) >= s.topN {
+			break
+		}
+		if len(s.seen) >= s.maxRoutes && !s.seen[uri] {
+			continue
+		}
+		s.selected[uri] = true
+		s.seen[uri] = true
+	}
+	s.requests = map[string]uint64{}
+}
@@ -0,0 +1,65 @@
+package metrics_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RouteSelector", func() {
+	const window = 100 * time.Millisecond
+
+	var selector *metrics.RouteSelector
+
+	BeforeEach(func() {
+		selector = metrics.NewRouteSelector(2, 3, window, []string{"allowed.example.com"})
+	})
+
+	It("selects the first routes until top_n are selected", func() {
+		Expect(selector.Select("a.example.com")).To(Equal("a.example.com"))
+		Expect(selector.Select("b.example.com")).To(Equal("b.example.com"))
+		Expect(selector.Select("c.example.com")).To(Equal(metrics.OtherRoutes))
+		Expect(selector.Select("a.example.com")).To(Equal("a.example.com"))
+	})
+
+	It("always selects the routes of the allowlist", func() {
+		selector.Select("a.example.com")
+		selector.Select("b.example.com")
+
+		Expect(selector.Select("allowed.example.com")).To(Equal("allowed.example.com"))
+	})
+
+	It("selects the busiest routes of the previous window", func() {
+		selector.Select("a.example.com")
+		selector.Select("b.example.com")
+		for i := 0; i < 3; i++ {
+			selector.Select("c.example.com")
+		}
+		selector.Select("b.example.com")
+
+		time.Sleep(window)
+
+		Expect(selector.Select("c.example.com")).To(Equal("c.example.com"))
+		Expect(selector.Select("b.example.com")).To(Equal("b.example.com"))
+		Expect(selector.Select("a.example.com")).To(Equal(metrics.OtherRoutes))
+	})
+
+	It("selects no more than max_routes routes ever", func() {
+		selector.Select("a.example.com")
+		selector.Select("b.example.com")
+
+		time.Sleep(window)
+		for i := 0; i < 3; i++ {
+			Expect(selector.Select("c.example.com")).To(Equal(metrics.OtherRoutes))
+		}
+		Expect(selector.Select("d.example.com")).To(Equal(metrics.OtherRoutes))
+
+		time.Sleep(window)
+		Expect(selector.Select("c.example.com")).To(Equal("c.example.com"))
+		Expect(selector.Select("d.example.com")).To(Equal(metrics.OtherRoutes))
+		Expect(selector.Select("a.example.com")).To(Equal("a.example.com"))
+	})
+})
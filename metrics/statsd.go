@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/cloudfoundry/dropsonde/metrics"
+)
+
+const (
+	// statsDUDPPacketBytes fits a packet in the MTU of most networks.
+	statsDUDPPacketBytes = 1432
+	// statsDUnixPacketBytes is the buffer size of the DogStatsD agent for
+	// datagrams on its socket.
+	statsDUnixPacketBytes = 8192
+	// statsDQueueLength is the number of metrics that can wait to be sent
+	// before more are dropped.
+	statsDQueueLength = 10000
+)
+
+// StatsD sends the metrics sent through the MetricSender and MetricBatcher
+// it wraps to a StatsD server. Counters are sent as counters, latencies in
+// milliseconds as timers, and other values as gauges. Metrics are sent in
+// packets every flush interval, and are dropped rather than holding up the
+// requests that record them when the server does not keep up.
+type StatsD struct {
+	conn       net.Conn
+	prefix     string
+	tagFormat  string
+	tags       string
+	packetSize int
+	lines      chan string
+}
+
+// NewStatsD connects to the StatsD server at the address of the config and
+// starts sending metrics to it.
+func NewStatsD(cfg config.StatsDConfig) (*StatsD, error) {
+	network, address, packetSize := "udp", cfg.Address, statsDUDPPacketBytes
+	if strings.HasPrefix(cfg.Address, "unix://") {
+		network, address, packetSize = "unixgram", strings.TrimPrefix(cfg.Address, "unix://"), statsDUnixPacketBytes
+	}
+
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StatsD{
+		conn:       conn,
+		prefix:     cfg.Prefix,
+		tagFormat:  cfg.TagFormat,
+		tags:       statsDTags(cfg.TagFormat, cfg.Tags),
+		packetSize: packetSize,
+		lines:      make(chan string, statsDQueueLength),
+	}
+	go s.run(cfg.FlushInterval)
+	return s, nil
+}
+
+// Sender returns a MetricSender that sends values and counters before
+// passing them to s.
+func (s *StatsD) Sender(sender metrics.MetricSender) metrics.MetricSender {
+	return &statsDSender{MetricSender: sender, statsd: s}
+}
+
+// Batcher returns a MetricBatcher that sends counters before passing them to
+// b.
+func (s *StatsD) Batcher(b metrics.MetricBatcher) metrics.MetricBatcher {
+	return &statsDBatcher{MetricBatcher: b, statsd: s}
+}
+
+func (s *StatsD) AddToCounter(name string, delta uint64) {
+	s.send(name, strconv.FormatUint(delta, 10), "c")
+}
+
+func (s *StatsD) RecordValue(name string, value float64, unit string) {
+	metricType := "g"
+	if unit == "ms" && strings.Contains(name, "latency") {
+		metricType = "ms"
+	}
+	s.send(name, strconv.FormatFloat(value, 'f', -1, 64), metricType)
+}
+
+func (s *StatsD) send(name, value, metricType string) {
+	name = statsDName(s.prefix + name)
+
+	var line string
+	switch s.tagFormat {
+	case config.StatsDTagFormatDogStatsD:
+		line = name + ":" + value + "|" + metricType + s.tags
+	case config.StatsDTagFormatInfluxDB:
+		line = name + s.tags + ":" + value + "|" + metricType
+	default:
+		line = name + ":" + value + "|" + metricType
+	}
+
+	select {
+	case s.lines <- line:
+	default:
+	}
+}
+
+// run sends the queued metrics in packets of at most packetSize bytes, once
+// a packet is full or every flush interval.
+func (s *StatsD) run(flushInterval time.Duration) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	packet := &bytes.Buffer{}
+	flush := func() {
+		if packet.Len() > 0 {
+			s.conn.Write(packet.Bytes())
+			packet.Reset()
+		}
+	}
+
+	for {
+		select {
+		case line := <-s.lines:
+			if packet.Len() > 0 && packet.Len()+1+len(line) > s.packetSize {
+				flush()
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// statsDTags formats the tags to append to the names of metrics, or to the
+// lines of metrics for dogstatsd, in sorted order.
+func statsDTags(format string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	switch format {
+	case config.StatsDTagFormatDogStatsD:
+		for _, k := range keys {
+			pairs = append(pairs, statsDName(k)+":"+statsDName(tags[k]))
+		}
+		return "|#" + strings.Join(pairs, ",")
+	case config.StatsDTagFormatInfluxDB:
+		for _, k := range keys {
+			pairs = append(pairs, statsDName(k)+"="+statsDName(tags[k]))
+		}
+		return "," + strings.Join(pairs, ",")
+	default:
+		return ""
+	}
+}
+
+// statsDName replaces the characters that separate the parts of a StatsD
+// line with underscores.
+func statsDName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', ',', '#', '=', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+type statsDSender struct {
+	metrics.MetricSender
+	statsd *StatsD
+}
+
+func (s *statsDSender) SendValue(name string, value float64, unit string) error {
+	s.statsd.RecordValue(name, value, unit)
+	return s.MetricSender.SendValue(name, value, unit)
+}
+
+func (s *statsDSender) IncrementCounter(name string) error {
+	s.statsd.AddToCounter(name, 1)
+	return s.MetricSender.IncrementCounter(name)
+}
+
+func (s *statsDSender) AddToCounter(name string, delta uint64) error {
+	s.statsd.AddToCounter(name, delta)
+	return s.MetricSender.AddToCounter(name, delta)
+}
+
+type statsDBatcher struct {
+	metrics.MetricBatcher
+	statsd *StatsD
+}
+
+func (b *statsDBatcher) BatchIncrementCounter(name string) {
+	b.statsd.AddToCounter(name, 1)
+	b.MetricBatcher.BatchIncrementCounter(name)
+}
+
+func (b *statsDBatcher) BatchAddCounter(name string, delta uint64) {
+	b.statsd.AddToCounter(name, delta)
+	b.MetricBatcher.BatchAddCounter(name, delta)
+}
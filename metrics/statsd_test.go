@@ -0,0 +1,159 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatsD", func() {
+	var (
+		server         net.PacketConn
+		cfg            config.StatsDConfig
+		sender         *fakes.MetricSender
+		batcher        *fakes.MetricBatcher
+		metricReporter *metrics.MetricsReporter
+	)
+
+	receive := func() []string {
+		var lines []string
+		buf := make([]byte, 65536)
+		server.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			n, _, err := server.ReadFrom(buf)
+			if err != nil {
+				return lines
+			}
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+			server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		server, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg = config.StatsDConfig{
+			Enabled:       true,
+			Address:       server.LocalAddr().String(),
+			Prefix:        "gorouter.",
+			TagFormat:     config.StatsDTagFormatNone,
+			FlushInterval: 10 * time.Millisecond,
+		}
+		sender = new(fakes.MetricSender)
+		batcher = new(fakes.MetricBatcher)
+	})
+
+	JustBeforeEach(func() {
+		statsd, err := metrics.NewStatsD(cfg)
+		Expect(err).ToNot(HaveOccurred())
+		metricReporter = &metrics.MetricsReporter{
+			Sender:  statsd.Sender(sender),
+			Batcher: statsd.Batcher(batcher),
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends counters, timers and gauges, and passes them on", func() {
+		metricReporter.CaptureRoutingResponse(200)
+		metricReporter.CaptureRoutingResponseLatency(route.NewEndpoint(&route.EndpointOpts{}), 0, time.Time{}, 20*time.Millisecond)
+		metricReporter.CaptureRouteStats(12, 5)
+
+		Expect(receive()).To(ConsistOf(
+			"gorouter.responses.2xx:1|c",
+			"gorouter.responses:1|c",
+			"gorouter.latency:20|ms",
+			"gorouter.total_routes:12|g",
+			"gorouter.ms_since_last_registry_update:5|g",
+		))
+		Expect(batcher.BatchIncrementCounterCallCount()).To(Equal(2))
+		Expect(sender.SendValueCallCount()).To(Equal(3))
+	})
+
+	It("replaces the separators of the StatsD format in names", func() {
+		metricReporter.CaptureRoutePoolSize("[::1]:8080|x", 3)
+
+		Expect(receive()).To(ConsistOf("gorouter.route_endpoints.[__1]_8080_x:3|g"))
+	})
+
+	Context("with dogstatsd tags", func() {
+		BeforeEach(func() {
+			cfg.TagFormat = config.StatsDTagFormatDogStatsD
+			cfg.Tags = map[string]string{"index": "0", "deployment": "cf"}
+		})
+
+		It("appends the tags to each metric", func() {
+			metricReporter.CaptureBadGateway()
+
+			Expect(receive()).To(ConsistOf("gorouter.bad_gateways:1|c|#deployment:cf,index:0"))
+		})
+	})
+
+	Context("with influxdb tags", func() {
+		BeforeEach(func() {
+			cfg.TagFormat = config.StatsDTagFormatInfluxDB
+			cfg.Tags = map[string]string{"index": "0", "deployment": "cf"}
+		})
+
+		It("appends the tags to the name of each metric", func() {
+			metricReporter.CaptureBadGateway()
+
+			Expect(receive()).To(ConsistOf("gorouter.bad_gateways,deployment=cf,index=0:1|c"))
+		})
+	})
+
+	It("sends many metrics in packets that fit in a datagram", func() {
+		for i := 0; i < 500; i++ {
+			metricReporter.CaptureBadGateway()
+		}
+
+		Expect(receive()).To(HaveLen(500))
+	})
+
+	Context("with a unix socket", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "statsd")
+			Expect(err).ToNot(HaveOccurred())
+
+			server.Close()
+			path := filepath.Join(dir, "dsd.socket")
+			server, err = net.ListenPacket("unixgram", path)
+			Expect(err).ToNot(HaveOccurred())
+			cfg.Address = "unix://" + path
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("sends the metrics to the socket", func() {
+			metricReporter.CaptureBadGateway()
+
+			Expect(receive()).To(ConsistOf("gorouter.bad_gateways:1|c"))
+		})
+	})
+
+	It("fails to start when the address is invalid", func() {
+		cfg.Address = "unix:///nonexistent/dsd.socket"
+		_, err := metrics.NewStatsD(cfg)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,182 @@
+package proxy_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var _ = Describe("Backend HTTP/2", func() {
+	var (
+		registerConfig test_util.RegisterConfig
+		backend        *http.Server
+		ln             net.Listener
+	)
+
+	protoHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	registerAppAndTest := func() (*http.Response, string) {
+		test_util.RegisterAddr(r, "test", ln.Addr().String(), registerConfig)
+
+		conn := dialProxy(proxyServer)
+
+		conn.WriteLines([]string{
+			"GET / HTTP/1.1",
+			"Host: test",
+		})
+
+		return conn.ReadResponse()
+	}
+
+	BeforeEach(func() {
+		var err error
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		registerConfig = test_util.RegisterConfig{
+			InstanceId:     "instance-1",
+			AppId:          "app-1",
+			StaleThreshold: 120,
+		}
+	})
+
+	AfterEach(func() {
+		backend.Close()
+	})
+
+	Context("when the backend serves cleartext HTTP/2", func() {
+		BeforeEach(func() {
+			backend = &http.Server{Handler: h2c.NewHandler(protoHandler, &http2.Server{})}
+			go backend.Serve(ln)
+		})
+
+		It("proxies with HTTP/2 when the backend registers the http2 protocol", func() {
+			registerConfig.Protocol = route.ProtocolHTTP2
+
+			resp, body := registerAppAndTest()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("HTTP/2.0"))
+		})
+
+		It("proxies with HTTP/1.1 when the backend does not register a protocol", func() {
+			resp, body := registerAppAndTest()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("HTTP/1.1"))
+		})
+	})
+
+	Context("when the request is a gRPC call", func() {
+		sendGRPCRequest := func() *http.Response {
+			test_util.RegisterAddr(r, "test", ln.Addr().String(), registerConfig)
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("POST", "test", "/helloworld.Greeter/SayHello", strings.NewReader("\x00\x00\x00\x00\x00"))
+			req.Header.Set("Content-Type", "application/grpc")
+			req.Header.Set("Te", "trailers")
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			return resp
+		}
+
+		BeforeEach(func() {
+			registerConfig.Protocol = route.ProtocolHTTP2
+			backend = &http.Server{Handler: h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/grpc")
+				w.Write([]byte("\x00\x00\x00\x00\x00"))
+				w.(http.Flusher).Flush()
+				w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+				w.Header().Set(http.TrailerPrefix+"Grpc-Message", r.Proto)
+			}), &http2.Server{})}
+			go backend.Serve(ln)
+		})
+
+		It("preserves the trailers of the backend response", func() {
+			resp := sendGRPCRequest()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/grpc"))
+			Expect(resp.Trailer.Get("Grpc-Status")).To(Equal("0"))
+			Expect(resp.Trailer.Get("Grpc-Message")).To(Equal("HTTP/2.0"))
+		})
+
+		It("responds with a gRPC status when the backend cannot be reached", func() {
+			backend.Close()
+
+			resp := sendGRPCRequest()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/grpc"))
+			Expect(resp.Header.Get("Grpc-Status")).To(Equal("14"))
+		})
+
+		It("responds with a gRPC status when the backend does not support HTTP/2", func() {
+			registerConfig.Protocol = ""
+
+			resp := sendGRPCRequest()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Grpc-Status")).To(Equal("12"))
+		})
+	})
+
+	Context("when the backend serves HTTP/2 over TLS", func() {
+		var enableHTTP2 bool
+
+		BeforeEach(func() {
+			enableHTTP2 = true
+			conf.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+
+			certChain := test_util.CreateSignedCertWithRootCA(test_util.CertNames{
+				CommonName: "instance-1",
+				SANs:       test_util.SubjectAltNames{DNS: "instance-1"},
+			})
+			caCertPool = x509.NewCertPool()
+			caCertPool.AddCert(certChain.CACert)
+
+			registerConfig.TLSConfig = certChain.AsTLSConfig()
+			registerConfig.TLSConfig.CipherSuites = conf.CipherSuites
+			registerConfig.ServerCertDomainSAN = "instance-1"
+			registerConfig.Protocol = route.ProtocolHTTP2
+		})
+
+		JustBeforeEach(func() {
+			if enableHTTP2 {
+				registerConfig.TLSConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+			}
+
+			backend = &http.Server{Handler: protoHandler}
+			if enableHTTP2 {
+				Expect(http2.ConfigureServer(backend, nil)).To(Succeed())
+			}
+			go backend.Serve(tls.NewListener(ln, registerConfig.TLSConfig))
+		})
+
+		It("negotiates HTTP/2 with the backend", func() {
+			resp, body := registerAppAndTest()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("HTTP/2.0"))
+		})
+
+		Context("when the backend does not support HTTP/2", func() {
+			BeforeEach(func() {
+				enableHTTP2 = false
+			})
+
+			It("falls back to HTTP/1.1", func() {
+				resp, body := registerAppAndTest()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(body).To(Equal("HTTP/1.1"))
+			})
+		})
+	})
+})
@@ -3,6 +3,7 @@ package proxy_test
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"net"
 	"net/http"
 
 	"code.cloudfoundry.org/gorouter/common/uuid"
@@ -95,6 +96,12 @@ var _ = Describe("Backend TLS", func() {
 			resp := registerAppAndTest()
 			Expect(resp.StatusCode).To(Equal(http.StatusOK))
 		})
+
+		It("reports the time taken by the TLS handshake", func() {
+			registerAppAndTest()
+			Expect(fakeReporter.CaptureBackendTLSHandshakeTimeCallCount()).To(Equal(1))
+			Expect(fakeReporter.CaptureBackendTLSHandshakeTimeArgsForCall(0)).To(BeNumerically(">", 0))
+		})
 	})
 	Context("when the backend requires a client certificate", func() {
 		BeforeEach(func() {
@@ -134,6 +141,83 @@ var _ = Describe("Backend TLS", func() {
 		})
 	})
 
+	Context("when backends select different client certificates by name", func() {
+		var (
+			backendACACertPool *x509.CertPool
+			backendBCACertPool *x509.CertPool
+		)
+
+		registerBackend := func(host string, cfg test_util.RegisterConfig) net.Listener {
+			return test_util.RegisterHandler(r, host, func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				if err != nil {
+					conn.WriteResponse(test_util.NewResponse(http.StatusInternalServerError))
+					return
+				}
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, cfg)
+		}
+
+		request := func(host string) *http.Response {
+			conn := dialProxy(proxyServer)
+			conn.WriteLines([]string{
+				"GET / HTTP/1.1",
+				"Host: " + host,
+			})
+			resp, _ := conn.ReadResponse()
+			return resp
+		}
+
+		BeforeEach(func() {
+			backendACACertPool = x509.NewCertPool()
+			backendBCACertPool = x509.NewCertPool()
+			clientCertA := createCertAndAddCA(test_util.CertNames{CommonName: "gorouter-a"}, backendACACertPool)
+			clientCertB := createCertAndAddCA(test_util.CertNames{CommonName: "gorouter-b"}, backendBCACertPool)
+
+			certA, err := tls.X509KeyPair(clientCertA.CertPEM, clientCertA.PrivKeyPEM)
+			Expect(err).NotTo(HaveOccurred())
+			certB, err := tls.X509KeyPair(clientCertB.CertPEM, clientCertB.PrivKeyPEM)
+			Expect(err).NotTo(HaveOccurred())
+			conf.Backends.NamedClientAuthCertificates = map[string]tls.Certificate{
+				"cert-a": certA,
+				"cert-b": certB,
+			}
+		})
+
+		AfterEach(func() {
+			conf.Backends.NamedClientAuthCertificates = nil
+		})
+
+		backendConfig := func(clientCAs *x509.CertPool, clientCertName, instanceID string) test_util.RegisterConfig {
+			tlsConfig := registerConfig.TLSConfig.Clone()
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			tlsConfig.ClientCAs = clientCAs
+
+			cfg := registerConfig
+			cfg.TLSConfig = tlsConfig
+			cfg.ClientCertName = clientCertName
+			cfg.InstanceId = instanceID
+			return cfg
+		}
+
+		It("presents the named client certificate to each backend", func() {
+			lnA := registerBackend("backend-a", backendConfig(backendACACertPool, "cert-a", "instance-a"))
+			defer lnA.Close()
+			lnB := registerBackend("backend-b", backendConfig(backendBCACertPool, "cert-b", "instance-b"))
+			defer lnB.Close()
+
+			Expect(request("backend-a").StatusCode).To(Equal(http.StatusOK))
+			Expect(request("backend-b").StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("fails the handshake when a backend is registered with the wrong certificate name", func() {
+			ln := registerBackend("backend-a", backendConfig(backendACACertPool, "cert-b", "instance-a"))
+			defer ln.Close()
+
+			Expect(request("backend-a").StatusCode).To(Equal(496))
+		})
+	})
+
 	Context("when the backend instance certificate is signed with an invalid CA", func() {
 		BeforeEach(func() {
 			var err error
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/andybalholm/brotli"
+)
+
+// DisableCompressionTag opts a route out of response compression when it is
+// set to "true".
+const DisableCompressionTag = "DisableCompression"
+
+// levels that trade some compression for speed, since responses are
+// compressed as they are proxied
+const (
+	brotliLevel = 4
+	gzipLevel   = 5
+)
+
+// compressResponse compresses the body of res with the configured encoding
+// that the client prefers. Responses that are already encoded, that
+// are not of a configured content type, or whose body is smaller than the
+// configured minimum are left alone.
+func compressResponse(res *http.Response, cfg config.CompressionConfig) error {
+	if !hasBody(res) || res.StatusCode == http.StatusPartialContent {
+		return nil
+	}
+	switch strings.ToLower(res.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	default:
+		return nil
+	}
+	if strings.Contains(strings.ToLower(res.Header.Get("Cache-Control")), "no-transform") {
+		return nil
+	}
+	if !compressibleType(res.Header.Get("Content-Type"), cfg.ContentTypes) {
+		return nil
+	}
+
+	// the response depends on Accept-Encoding whether it is compressed or not
+	res.Header.Add("Vary", "Accept-Encoding")
+
+	encoding := negotiateEncoding(res.Request.Header.Values("Accept-Encoding"), cfg.Encodings)
+	if encoding == "" {
+		return nil
+	}
+
+	minSize := int64(cfg.MinSize)
+	if res.ContentLength >= 0 && res.ContentLength < minSize {
+		return nil
+	}
+	if res.ContentLength < 0 && minSize > 0 {
+		head, err := ioutil.ReadAll(io.LimitReader(res.Body, minSize))
+		if err != nil {
+			return err
+		}
+		res.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(head), res.Body), Closer: res.Body}
+		if int64(len(head)) < minSize {
+			return nil
+		}
+	}
+
+	res.Body = newCompressedBody(res.Body, encoding)
+	res.ContentLength = -1
+	res.Header.Del("Content-Length")
+	res.Header.Set("Content-Encoding", encoding)
+	// the compressed body is no longer byte for byte the one the tag
+	// validates
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		res.Header.Set("ETag", "W/"+etag)
+	}
+	return nil
+}
+
+// isCompressionDisabled reports whether any endpoint of the route opted out
+// of compression.
+func isCompressionDisabled(pool *route.Pool) bool {
+	var disabled bool
+	pool.Each(func(e *route.Endpoint) {
+		disabled = disabled || e.Tags[DisableCompressionTag] == "true"
+	})
+	return disabled
+}
+
+func compressibleType(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if a == mediaType || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, a[:len(a)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the one of encodings that the Accept-Encoding
+// values give the highest non-zero quality, the first of them on a tie, or ""
+// if they accept none.
+func negotiateEncoding(acceptEncoding []string, encodings []string) string {
+	qualities := map[string]float64{}
+	for _, value := range acceptEncoding {
+		for _, part := range strings.Split(value, ",") {
+			params := strings.Split(part, ";")
+			name := strings.ToLower(strings.TrimSpace(params[0]))
+			if name == "" {
+				continue
+			}
+			quality := 1.0
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if q, err := strconv.ParseFloat(param[len("q="):], 64); err == nil {
+						quality = q
+					}
+				}
+			}
+			qualities[name] = quality
+		}
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range encodings {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressedBody compresses the body it wraps as it is read.
+type compressedBody struct {
+	*io.PipeReader
+	body io.ReadCloser
+}
+
+func newCompressedBody(body io.ReadCloser, encoding string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		var writer io.WriteCloser
+		if encoding == config.CompressionEncodingBrotli {
+			writer = brotli.NewWriterLevel(pw, brotliLevel)
+		} else {
+			writer, _ = gzip.NewWriterLevel(pw, gzipLevel)
+		}
+		_, err := io.Copy(writer, body)
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return &compressedBody{PipeReader: pr, body: body}
+}
+
+func (c *compressedBody) Close() error {
+	c.PipeReader.Close()
+	return c.body.Close()
+}
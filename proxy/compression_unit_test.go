@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"github.com/andybalholm/brotli"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("compressResponse", func() {
+	var (
+		cfg  config.CompressionConfig
+		body string
+	)
+
+	newResponse := func(contentType, acceptEncoding string) *http.Response {
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		Expect(err).ToNot(HaveOccurred())
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		res := &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}
+		res.Header.Set("Content-Type", contentType)
+		res.Header.Set("Content-Length", "2048")
+		return res
+	}
+
+	gunzipped := func(res *http.Response) string {
+		reader, err := gzip.NewReader(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		data, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	BeforeEach(func() {
+		cfg = config.CompressionConfig{
+			Enabled:      true,
+			Encodings:    []string{"br", "gzip"},
+			ContentTypes: []string{"application/json", "text/*"},
+			MinSize:      1024,
+		}
+		body = `{"data":"` + strings.Repeat("a", 2048) + `"}`
+	})
+
+	It("compresses with the first configured encoding that the client accepts", func() {
+		res := newResponse("application/json", "gzip, deflate, br")
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("Content-Encoding")).To(Equal("br"))
+		Expect(res.Header.Get("Content-Length")).To(BeEmpty())
+		Expect(res.ContentLength).To(BeEquivalentTo(-1))
+		Expect(res.Header.Get("Vary")).To(Equal("Accept-Encoding"))
+
+		data, err := ioutil.ReadAll(brotli.NewReader(res.Body))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal(body))
+		Expect(res.Body.Close()).To(Succeed())
+	})
+
+	It("compresses with the configured encoding that the client prefers", func() {
+		res := newResponse("application/json", "br;q=0.5, gzip")
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(gunzipped(res)).To(Equal(body))
+	})
+
+	It("compresses with gzip when the client does not accept brotli", func() {
+		res := newResponse("text/plain; charset=utf-8", "gzip;q=0.5, br;q=0")
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(gunzipped(res)).To(Equal(body))
+	})
+
+	It("weakens strong ETags", func() {
+		res := newResponse("application/json", "gzip")
+		res.Header.Set("ETag", `"abc"`)
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("ETag")).To(Equal(`W/"abc"`))
+	})
+
+	It("does not compress when the client accepts no configured encoding", func() {
+		res := newResponse("application/json", "deflate")
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(res.Header.Get("Vary")).To(Equal("Accept-Encoding"))
+	})
+
+	It("does not compress content types that are not configured", func() {
+		res := newResponse("image/png", "gzip")
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(res.Header.Get("Vary")).To(BeEmpty())
+	})
+
+	It("does not compress responses that are already encoded", func() {
+		res := newResponse("application/json", "gzip")
+		res.Header.Set("Content-Encoding", "deflate")
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("Content-Encoding")).To(Equal("deflate"))
+	})
+
+	It("does not compress responses that must not be transformed", func() {
+		res := newResponse("application/json", "gzip")
+		res.Header.Set("Cache-Control", "public, no-transform")
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("Content-Encoding")).To(BeEmpty())
+	})
+
+	Context("when the body is smaller than the minimum size", func() {
+		BeforeEach(func() {
+			body = `{"data":"a"}`
+		})
+
+		It("does not compress it", func() {
+			res := newResponse("application/json", "gzip")
+			Expect(compressResponse(res, cfg)).To(Succeed())
+
+			Expect(res.Header.Get("Content-Encoding")).To(BeEmpty())
+		})
+
+		It("does not compress it when its length is unknown", func() {
+			res := newResponse("application/json", "gzip")
+			res.ContentLength = -1
+			res.Header.Del("Content-Length")
+			Expect(compressResponse(res, cfg)).To(Succeed())
+
+			Expect(res.Header.Get("Content-Encoding")).To(BeEmpty())
+			data, err := ioutil.ReadAll(res.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(body))
+		})
+	})
+
+	It("compresses bodies of unknown length over the minimum size", func() {
+		res := newResponse("application/json", "gzip")
+		res.ContentLength = -1
+		res.Header.Del("Content-Length")
+		Expect(compressResponse(res, cfg)).To(Succeed())
+
+		Expect(res.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(gunzipped(res)).To(Equal(body))
+	})
+})
+
+var _ = Describe("negotiateEncoding", func() {
+	It("accepts any encoding for *", func() {
+		Expect(negotiateEncoding([]string{"*"}, []string{"br", "gzip"})).To(Equal("br"))
+	})
+
+	It("chooses the encoding with the highest quality", func() {
+		Expect(negotiateEncoding([]string{"br;q=0.8", "gzip;q=0.9"}, []string{"br", "gzip"})).To(Equal("gzip"))
+		Expect(negotiateEncoding([]string{"gzip;q=0.8, *;q=0.5"}, []string{"br", "gzip"})).To(Equal("gzip"))
+		Expect(negotiateEncoding([]string{"gzip;q=0.8, br;q=0.8"}, []string{"br", "gzip"})).To(Equal("br"))
+	})
+
+	It("prefers explicit qualities over *", func() {
+		Expect(negotiateEncoding([]string{"br;q=0, *"}, []string{"br", "gzip"})).To(Equal("gzip"))
+	})
+
+	It("accepts nothing without Accept-Encoding", func() {
+		Expect(negotiateEncoding(nil, []string{"br", "gzip"})).To(BeEmpty())
+	})
+})
@@ -3,6 +3,8 @@ package fails
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"fmt"
 	"net"
 
 	"context"
@@ -26,19 +28,43 @@ var ContextCancelled = ClassifierFunc(func(err error) bool {
 	return err == context.Canceled
 })
 
+// ResponseHeaderTimeout matches the error returned by an http.Transport when
+// the backend does not send response headers within ResponseHeaderTimeout.
+var ResponseHeaderTimeout = ClassifierFunc(func(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout() && err.Error() == "net/http: timeout awaiting response headers"
+})
+
+// RetriableStatusError is the failure of an endpoint that responded with a
+// status code that the retry policy retries.
+type RetriableStatusError struct {
+	StatusCode int
+}
+
+func (e RetriableStatusError) Error() string {
+	return fmt.Sprintf("endpoint responded with retriable status code %d", e.StatusCode)
+}
+
+var RetriableStatus = ClassifierFunc(func(err error) bool {
+	_, ok := err.(RetriableStatusError)
+	return ok
+})
+
 var ConnectionResetOnRead = ClassifierFunc(func(err error) bool {
 	ne, ok := err.(*net.OpError)
 	return ok && ne.Op == "read" && ne.Err.Error() == "read: connection reset by peer"
 })
 
+// TLS handshake errors are wrapped by the tls package, so the classifiers of
+// remote TLS alerts look for them in the error chain.
 var RemoteFailedCertCheck = ClassifierFunc(func(err error) bool {
-	ne, ok := err.(*net.OpError)
-	return ok && ne.Op == "remote error" && ne.Err.Error() == "tls: bad certificate"
+	var ne *net.OpError
+	return errors.As(err, &ne) && ne.Op == "remote error" && ne.Err.Error() == "tls: bad certificate"
 })
 
 var RemoteHandshakeFailure = ClassifierFunc(func(err error) bool {
-	ne, ok := err.(*net.OpError)
-	return ok && ne.Op == "remote error" && ne.Err.Error() == "tls: handshake failure"
+	var ne *net.OpError
+	return errors.As(err, &ne) && ne.Op == "remote error" && ne.Err.Error() == "tls: handshake failure"
 })
 
 var HostnameMismatch = ClassifierFunc(func(err error) bool {
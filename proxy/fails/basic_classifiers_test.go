@@ -112,6 +112,38 @@ var _ = Describe("ErrorClassifiers - enemy tests", func() {
 		})
 	})
 
+	Describe("ResponseHeaderTimeout", func() {
+		var stall chan struct{}
+
+		BeforeEach(func() {
+			stall = make(chan struct{})
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-stall
+			})
+			testTransport.ResponseHeaderTimeout = 50 * time.Millisecond
+		})
+
+		AfterEach(func() {
+			close(stall)
+		})
+
+		It("matches errors when the backend does not send response headers in time", func() {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			_, err := testTransport.RoundTrip(req)
+			Expect(err).To(HaveOccurred())
+			Expect(fails.ResponseHeaderTimeout(err)).To(BeTrue())
+		})
+
+		It("does not match other errors", func() {
+			server.Close()
+			req, _ := http.NewRequest("GET", server.URL, nil)
+
+			_, err := testTransport.RoundTrip(req)
+			Expect(err).To(HaveOccurred())
+			Expect(fails.ResponseHeaderTimeout(err)).To(BeFalse())
+		})
+	})
+
 	Describe("Dial", func() {
 		It("matches errors with TCP connections", func() {
 			server.Close()
@@ -14,6 +14,7 @@ var RetriableClassifiers = ClassifierGroup{
 var FailableClassifiers = ClassifierGroup{
 	RetriableClassifiers,
 	ConnectionResetOnRead,
+	RetriableStatus,
 }
 
 var PrunableClassifiers = RetriableClassifiers
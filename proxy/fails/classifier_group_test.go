@@ -43,6 +43,17 @@ var _ = Describe("ClassifierGroup", func() {
 		})
 	})
 
+	Describe("failable", func() {
+		It("matches retriable errors, connection resets and retriable status codes", func() {
+			fc := fails.FailableClassifiers
+
+			Expect(fc.Classify(&net.OpError{Op: "dial"})).To(BeTrue())
+			Expect(fc.Classify(&net.OpError{Op: "read", Err: errors.New("read: connection reset by peer")})).To(BeTrue())
+			Expect(fc.Classify(fails.RetriableStatusError{StatusCode: 503})).To(BeTrue())
+			Expect(fc.Classify(errors.New("i'm a potato"))).To(BeFalse())
+		})
+	})
+
 	Describe("prunable", func() {
 		It("matches hostname mismatch", func() {
 			pc := fails.PrunableClassifiers
@@ -54,6 +65,7 @@ var _ = Describe("ClassifierGroup", func() {
 			Expect(pc.Classify(tls.RecordHeaderError{})).To(BeTrue())
 			Expect(pc.Classify(x509.HostnameError{})).To(BeTrue())
 			Expect(pc.Classify(x509.UnknownAuthorityError{})).To(BeTrue())
+			Expect(pc.Classify(fails.RetriableStatusError{StatusCode: 503})).To(BeFalse())
 			Expect(pc.Classify(errors.New("i'm a potato"))).To(BeFalse())
 		})
 	})
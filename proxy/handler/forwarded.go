@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedHeaders chooses the headers that report the client of WebSocket
+// and TCP upgrades to backends: Forwarded, and X-Forwarded-For with
+// X-Forwarded-Proto.
+func ForwardedHeaders(forwarded, xForwarded bool) func(*RequestHandler) {
+	return func(h *RequestHandler) {
+		h.emitForwarded = forwarded
+		h.omitXForwarded = !xForwarded
+	}
+}
+
+// SetRequestForwarded appends an element for the client of request to its
+// Forwarded header (RFC 7239), keeping the elements of earlier proxies. The
+// scheme is taken from X-Forwarded-Proto, so it must be called before that
+// is removed.
+func SetRequestForwarded(request *http.Request) {
+	clientIP, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return
+	}
+	if strings.Contains(clientIP, ":") {
+		clientIP = "[" + clientIP + "]"
+	}
+
+	element := "for=" + forwardedValue(clientIP)
+	if request.Host != "" {
+		element += ";host=" + forwardedValue(request.Host)
+	}
+	if proto := request.Header.Get("X-Forwarded-Proto"); proto != "" {
+		element += ";proto=" + forwardedValue(proto)
+	}
+	if prior := request.Header.Values("Forwarded"); len(prior) > 0 {
+		element = strings.Join(prior, ", ") + ", " + element
+	}
+	request.Header.Set("Forwarded", element)
+}
+
+// RemoveRequestXForwarded removes the X-Forwarded headers from request. The
+// nil X-Forwarded-For also keeps httputil.ReverseProxy from adding it again.
+func RemoveRequestXForwarded(request *http.Request) {
+	request.Header.Del("X-Forwarded-Proto")
+	request.Header.Del("X-Forwarded-Host")
+	request.Header["X-Forwarded-For"] = nil
+}
+
+// forwardedValue quotes values that are not tokens, such as IPv6 addresses
+// and hosts with a port.
+func forwardedValue(value string) string {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
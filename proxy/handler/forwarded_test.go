@@ -0,0 +1,59 @@
+package handler_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forwarded", func() {
+	var req *http.Request
+
+	BeforeEach(func() {
+		var err error
+		req, err = http.NewRequest("GET", "http://example.com/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.RemoteAddr = "192.0.2.60:4711"
+		req.Header.Set("X-Forwarded-Proto", "https")
+	})
+
+	Describe("SetRequestForwarded", func() {
+		It("adds an element for the client", func() {
+			handler.SetRequestForwarded(req)
+			Expect(req.Header.Get("Forwarded")).To(Equal("for=192.0.2.60;host=example.com;proto=https"))
+		})
+
+		It("keeps the elements of earlier proxies", func() {
+			req.Header.Add("Forwarded", "for=198.51.100.17")
+			req.Header.Add("Forwarded", "for=203.0.113.43;proto=http")
+
+			handler.SetRequestForwarded(req)
+			Expect(req.Header.Values("Forwarded")).To(Equal([]string{
+				"for=198.51.100.17, for=203.0.113.43;proto=http, for=192.0.2.60;host=example.com;proto=https",
+			}))
+		})
+
+		It("quotes IPv6 addresses and hosts with a port", func() {
+			req.RemoteAddr = "[2001:db8:cafe::17]:4711"
+			req.Host = "example.com:8080"
+
+			handler.SetRequestForwarded(req)
+			Expect(req.Header.Get("Forwarded")).To(Equal(`for="[2001:db8:cafe::17]";host="example.com:8080";proto=https`))
+		})
+	})
+
+	Describe("RemoveRequestXForwarded", func() {
+		It("removes the X-Forwarded headers", func() {
+			req.Header.Set("X-Forwarded-For", "198.51.100.17")
+			req.Header.Set("X-Forwarded-Host", "example.com")
+
+			handler.RemoveRequestXForwarded(req)
+			Expect(req.Header.Get("X-Forwarded-For")).To(BeEmpty())
+			Expect(req.Header.Get("X-Forwarded-Host")).To(BeEmpty())
+			Expect(req.Header.Get("X-Forwarded-Proto")).To(BeEmpty())
+		})
+	})
+})
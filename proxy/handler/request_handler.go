@@ -14,6 +14,7 @@ import (
 	router_http "code.cloudfoundry.org/gorouter/common/http"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/metrics"
+	"code.cloudfoundry.org/gorouter/proxy/fails"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 	"code.cloudfoundry.org/gorouter/route"
 	"github.com/uber-go/zap"
@@ -25,6 +26,21 @@ const (
 
 var NoEndpointsAvailable = errors.New("No endpoints available")
 
+// tlsFailures classify the errors of failing to establish TLS with an
+// endpoint.
+var tlsFailures = fails.ClassifierGroup{
+	fails.AttemptedTLSWithNonTLSBackend,
+	fails.HostnameMismatch,
+	fails.UntrustedCert,
+	fails.RemoteFailedCertCheck,
+	fails.RemoteHandshakeFailure,
+}
+
+// ErrorHandler responds to requests whose endpoint failed.
+type ErrorHandler interface {
+	HandleError(utils.ProxyResponseWriter, *http.Request, error)
+}
+
 type RequestHandler struct {
 	logger   logger.Logger
 	reporter metrics.ProxyReporter
@@ -34,11 +50,19 @@ type RequestHandler struct {
 
 	endpointDialTimeout time.Duration
 
-	tlsConfigTemplate *tls.Config
+	tlsConfigTemplate  *tls.Config
+	clientCertificates *utils.ClientCertificates
 
 	forwarder              *Forwarder
 	disableXFFLogging      bool
 	disableSourceIPLogging bool
+	errorHandler           ErrorHandler
+	proxyProtocol          string
+	emitForwarded          bool
+	omitXForwarded         bool
+	hostRewrite            string
+	pathPrefix             string
+	pathRewrite            string
 }
 
 func NewRequestHandler(request *http.Request, response utils.ProxyResponseWriter, r metrics.ProxyReporter, logger logger.Logger, endpointDialTimeout time.Duration, tlsConfig *tls.Config, opts ...func(*RequestHandler)) *RequestHandler {
@@ -91,12 +115,53 @@ func DisableXFFLogging(t bool) func(*RequestHandler) {
 	}
 }
 
+func BackendClientCertificates(certs *utils.ClientCertificates) func(*RequestHandler) {
+	return func(h *RequestHandler) {
+		h.clientCertificates = certs
+	}
+}
+
 func DisableSourceIPLogging(t bool) func(*RequestHandler) {
 	return func(h *RequestHandler) {
 		h.disableSourceIPLogging = t
 	}
 }
 
+// BackendErrorHandler responds to WebSocket requests that fail to establish
+// TLS with the endpoint, so that they get the same responses as other
+// requests do.
+func BackendErrorHandler(eh ErrorHandler) func(*RequestHandler) {
+	return func(h *RequestHandler) {
+		h.errorHandler = eh
+	}
+}
+
+// BackendProxyProtocol prepends a PROXY protocol header of the given version
+// to backend connections of WebSocket and TCP upgrades, unless the endpoint
+// registered another.
+func BackendProxyProtocol(version string) func(*RequestHandler) {
+	return func(h *RequestHandler) {
+		h.proxyProtocol = version
+	}
+}
+
+// HostRewrite sends WebSocket and TCP upgrades to backends with the given
+// Host header instead of the one the client sent.
+func HostRewrite(host string) func(*RequestHandler) {
+	return func(h *RequestHandler) {
+		h.hostRewrite = host
+	}
+}
+
+// PathRewrite sends WebSocket and TCP upgrades to backends with the given
+// prefix of their path replaced by path, unless path is empty.
+func PathRewrite(prefix, path string) func(*RequestHandler) {
+	return func(h *RequestHandler) {
+		h.pathPrefix = prefix
+		h.pathRewrite = path
+	}
+}
+
 func (h *RequestHandler) Logger() logger.Logger {
 	return h.logger
 }
@@ -139,7 +204,11 @@ func (h *RequestHandler) HandleWebSocketRequest(iter route.EndpointIterator) {
 
 	if err != nil {
 		h.logger.Error("websocket-request-failed", zap.Error(err))
-		h.writeStatus(http.StatusBadGateway, "WebSocket request to endpoint failed.")
+		if h.errorHandler != nil && tlsFailures.Classify(err) {
+			h.errorHandler.HandleError(h.response, h.request, err)
+		} else {
+			h.writeStatus(http.StatusBadGateway, "WebSocket request to endpoint failed.")
+		}
 		h.reporter.CaptureWebSocketFailure()
 		return
 	}
@@ -161,6 +230,58 @@ func (h *RequestHandler) writeStatus(code int, message string) {
 type connSuccessCB func(net.Conn, *route.Endpoint) error
 type connFailureCB func(error)
 
+// dialEndpoint connects to the endpoint, sending it a PROXY protocol header
+// first if it is configured to get one.
+func (h *RequestHandler) dialEndpoint(dialer *net.Dialer, endpoint *route.Endpoint) (net.Conn, error) {
+	var tlsConfigLocal *tls.Config
+	if endpoint.IsTLS() {
+		clientTLSConfig := h.clientCertificates.TLSConfig(endpoint.ClientCertName, h.tlsConfigTemplate)
+		tlsConfigLocal = utils.TLSConfigWithServerName(endpoint.ServerCertDomainSAN, clientTLSConfig)
+		// upgrades are only possible over HTTP/1.1
+		tlsConfigLocal.NextProtos = []string{"http/1.1"}
+	}
+
+	proxyProtocol := route.ProxyProtocolVersion(endpoint.ProxyProtocol, h.proxyProtocol)
+	if proxyProtocol == "" {
+		if tlsConfigLocal != nil {
+			return tls.DialWithDialer(dialer, "tcp", endpoint.CanonicalAddr(), tlsConfigLocal)
+		}
+		return dialer.Dial("tcp", endpoint.CanonicalAddr())
+	}
+
+	conn, err := dialer.Dial("tcp", endpoint.CanonicalAddr())
+	if err != nil {
+		return nil, err
+	}
+
+	src, dst := utils.ProxyProtocolAddrs(h.request)
+	err = utils.WriteProxyProtocolHeader(conn, proxyProtocol, src, dst)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if tlsConfigLocal == nil {
+		return conn, nil
+	}
+
+	if tlsConfigLocal.ServerName == "" {
+		// as tls.DialWithDialer does
+		tlsConfigLocal.ServerName, _, _ = net.SplitHostPort(endpoint.CanonicalAddr())
+	}
+	if h.endpointDialTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(h.endpointDialTimeout))
+	}
+	tlsConn := tls.Client(conn, tlsConfigLocal)
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
 var nilConnSuccessCB = func(net.Conn, *route.Endpoint) error { return nil }
 var nilConnFailureCB = func(error) {}
 
@@ -195,12 +316,7 @@ func (h *RequestHandler) serveTcp(
 
 		iter.PreRequest(endpoint)
 
-		if endpoint.IsTLS() {
-			tlsConfigLocal := utils.TLSConfigWithServerName(endpoint.ServerCertDomainSAN, h.tlsConfigTemplate)
-			backendConnection, err = tls.DialWithDialer(dialer, "tcp", endpoint.CanonicalAddr(), tlsConfigLocal)
-		} else {
-			backendConnection, err = net.DialTimeout("tcp", endpoint.CanonicalAddr(), h.endpointDialTimeout)
-		}
+		backendConnection, err = h.dialEndpoint(dialer, endpoint)
 
 		iter.PostRequest(endpoint)
 		if err == nil {
@@ -237,7 +353,20 @@ func (h *RequestHandler) serveTcp(
 
 func (h *RequestHandler) setupRequest(endpoint *route.Endpoint) {
 	h.setRequestURL(endpoint.CanonicalAddr())
-	h.setRequestXForwardedFor()
+	if h.emitForwarded {
+		SetRequestForwarded(h.request)
+	}
+	if h.hostRewrite != "" {
+		SetRequestHost(h.request, h.hostRewrite)
+	}
+	if h.pathRewrite != "" {
+		SetRequestPath(h.request, h.pathPrefix, h.pathRewrite)
+	}
+	if h.omitXForwarded {
+		RemoveRequestXForwarded(h.request)
+	} else {
+		h.setRequestXForwardedFor()
+	}
 	SetRequestXRequestStart(h.request)
 }
 
@@ -258,6 +387,47 @@ func (h *RequestHandler) setRequestXForwardedFor() {
 	}
 }
 
+// SetRequestHost replaces the Host of request with host, keeping the one
+// the client sent in X-Forwarded-Host.
+func SetRequestHost(request *http.Request, host string) {
+	if request.Host == host {
+		return
+	}
+	request.Header.Set("X-Forwarded-Host", request.Host)
+	request.Host = host
+}
+
+// SetRequestPath sends request to the backend with the prefix of the path
+// the client sent replaced by path. It always starts from the request URI of
+// the client, so it can be called again for each attempt.
+func SetRequestPath(request *http.Request, prefix, path string) {
+	uri := RewritePathPrefix(request.RequestURI, prefix, path)
+	if uri == request.RequestURI {
+		return
+	}
+	request.URL.Opaque = uri
+	request.URL.RawQuery = ""
+}
+
+// RewritePathPrefix replaces prefix at the start of the path of uri with
+// path. Only whole segments of the path match prefix, compared without
+// regard to case, like routes are, and uri is returned as it is when they do
+// not.
+func RewritePathPrefix(uri, prefix, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if len(uri) < len(prefix) || !strings.EqualFold(uri[:len(prefix)], prefix) {
+		return uri
+	}
+	rest := uri[len(prefix):]
+	if rest == "" || rest[0] == '?' {
+		return path + rest
+	}
+	if rest[0] != '/' {
+		return uri
+	}
+	return strings.TrimSuffix(path, "/") + rest
+}
+
 func SetRequestXRequestStart(request *http.Request) {
 	if _, ok := request.Header[http.CanonicalHeaderKey("X-Request-Start")]; !ok {
 		request.Header.Set("X-Request-Start", strconv.FormatInt(time.Now().UnixNano()/1e6, 10))
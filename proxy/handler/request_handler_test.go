@@ -1,19 +1,27 @@
 package handler_test
 
 import (
+	"bufio"
 	"crypto/tls"
+	"crypto/x509"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"time"
 
 	metric "code.cloudfoundry.org/gorouter/metrics/fakes"
+	"code.cloudfoundry.org/gorouter/proxy/fails"
 	"code.cloudfoundry.org/gorouter/proxy/handler"
+	rtfakes "code.cloudfoundry.org/gorouter/proxy/round_tripper/fakes"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/route"
 	iter "code.cloudfoundry.org/gorouter/route/fakes"
 	"code.cloudfoundry.org/gorouter/test_util"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
 )
@@ -129,4 +137,186 @@ var _ = Describe("RequestHandler", func() {
 			})
 		})
 	})
+
+	Describe("HandleWebSocketRequest", func() {
+		var (
+			backend      net.Listener
+			backendTLS   *tls.Config
+			states       chan tls.ConnectionState
+			tlsConfig    *tls.Config
+			san          string
+			reporter     *metric.FakeProxyReporter
+			errorHandler *rtfakes.ErrorHandler
+		)
+
+		BeforeEach(func() {
+			backendCertChain := test_util.CreateSignedCertWithRootCA(test_util.CertNames{
+				SANs: test_util.SubjectAltNames{DNS: "backend.example.com"},
+			})
+			clientCertChain := test_util.CreateSignedCertWithRootCA(test_util.CertNames{CommonName: "gorouter"})
+
+			rootCAs := x509.NewCertPool()
+			rootCAs.AddCert(backendCertChain.CACert)
+			clientCAs := x509.NewCertPool()
+			clientCAs.AddCert(clientCertChain.CACert)
+
+			backendTLS = backendCertChain.AsTLSConfig()
+			backendTLS.ClientCAs = clientCAs
+			backendTLS.ClientAuth = tls.RequireAndVerifyClientCert
+			backendTLS.NextProtos = []string{"h2", "http/1.1"}
+			// the keys of the test certificates are too small for TLS 1.3
+			backendTLS.MaxVersion = tls.VersionTLS12
+
+			tlsConfig = &tls.Config{
+				RootCAs:      rootCAs,
+				Certificates: []tls.Certificate{clientCertChain.TLSCert()},
+			}
+			san = "backend.example.com"
+			states = make(chan tls.ConnectionState, handler.MaxRetries)
+			reporter = &metric.FakeProxyReporter{}
+			errorHandler = &rtfakes.ErrorHandler{}
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			backend, err = tls.Listen("tcp", "127.0.0.1:0", backendTLS)
+			Expect(err).NotTo(HaveOccurred())
+			go func() {
+				for {
+					conn, err := backend.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						defer conn.Close()
+						tlsConn := conn.(*tls.Conn)
+						if _, err := http.ReadRequest(bufio.NewReader(tlsConn)); err == nil {
+							states <- tlsConn.ConnectionState()
+						}
+					}()
+				}
+			}()
+
+			_, portStr, err := net.SplitHostPort(backend.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(portStr)
+			Expect(err).NotTo(HaveOccurred())
+
+			endpoints := &iter.FakeEndpointIterator{}
+			endpoints.NextReturns(route.NewEndpoint(&route.EndpointOpts{
+				Host:                "127.0.0.1",
+				Port:                uint16(port),
+				UseTLS:              true,
+				ServerCertDomainSAN: san,
+			}))
+
+			req = httptest.NewRequest("GET", "http://ws.example.com/chat", nil)
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "Upgrade")
+			rh = handler.NewRequestHandler(
+				req, pr,
+				reporter, logger,
+				time.Second, tlsConfig,
+				handler.BackendErrorHandler(errorHandler),
+			)
+			rh.HandleWebSocketRequest(endpoints)
+		})
+
+		AfterEach(func() {
+			backend.Close()
+		})
+
+		It("connects to TLS endpoints over HTTP/1.1, verifying their SAN and presenting the client certificate", func() {
+			var state tls.ConnectionState
+			Eventually(states).Should(Receive(&state))
+			Expect(state.ServerName).To(Equal("backend.example.com"))
+			Expect(state.NegotiatedProtocol).To(Equal("http/1.1"))
+			Expect(state.PeerCertificates).To(HaveLen(1))
+			Expect(state.PeerCertificates[0].Subject.CommonName).To(Equal("gorouter"))
+		})
+
+		Context("when the endpoint certificate does not match its SAN", func() {
+			BeforeEach(func() {
+				san = "other.example.com"
+			})
+
+			It("does not connect", func() {
+				Expect(reporter.CaptureWebSocketFailureCallCount()).To(Equal(1))
+				Consistently(states).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the endpoint requires a client certificate that is not presented", func() {
+			BeforeEach(func() {
+				tlsConfig.Certificates = nil
+			})
+
+			It("responds the way other requests do", func() {
+				Expect(errorHandler.HandleErrorCallCount()).To(Equal(1))
+				_, _, err := errorHandler.HandleErrorArgsForCall(0)
+				Expect(fails.RetriableClassifiers.Classify(err)).To(BeTrue())
+				Expect(reporter.CaptureWebSocketFailureCallCount()).To(Equal(1))
+				Consistently(states).ShouldNot(Receive())
+			})
+		})
+	})
+
+	Describe("SetRequestHost", func() {
+		var req *http.Request
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "http://example.com/", nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("replaces the Host, keeping the original in X-Forwarded-Host", func() {
+			handler.SetRequestHost(req, "legacy.internal")
+			Expect(req.Host).To(Equal("legacy.internal"))
+			Expect(req.Header.Get("X-Forwarded-Host")).To(Equal("example.com"))
+		})
+
+		It("leaves the request alone when the Host is unchanged", func() {
+			handler.SetRequestHost(req, "example.com")
+			Expect(req.Host).To(Equal("example.com"))
+			Expect(req.Header).NotTo(HaveKey("X-Forwarded-Host"))
+		})
+	})
+
+	Describe("SetRequestPath", func() {
+		var req *http.Request
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "http://example.com/team-a/status?verbose=1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.RequestURI = "/team-a/status?verbose=1"
+		})
+
+		It("sends the request with the prefix of its path replaced", func() {
+			handler.SetRequestPath(req, "/team-a", "/")
+			Expect(req.URL.RequestURI()).To(Equal("/status?verbose=1"))
+		})
+
+		It("starts from the path the client sent when called again", func() {
+			handler.SetRequestPath(req, "/team-a", "/team-a/v2")
+			handler.SetRequestPath(req, "/team-a", "/team-a/v2")
+			Expect(req.URL.RequestURI()).To(Equal("/team-a/v2/status?verbose=1"))
+		})
+	})
+
+	DescribeTable("RewritePathPrefix",
+		func(uri, prefix, path, expected string) {
+			Expect(handler.RewritePathPrefix(uri, prefix, path)).To(Equal(expected))
+		},
+		Entry("strips the prefix", "/team-a/status", "/team-a", "/", "/status"),
+		Entry("strips the whole path", "/team-a", "/team-a", "/", "/"),
+		Entry("keeps the query", "/team-a?verbose=1", "/team-a", "/", "/?verbose=1"),
+		Entry("replaces the prefix", "/team-a/status", "/team-a", "/api/v2", "/api/v2/status"),
+		Entry("replaces the whole path", "/team-a", "/team-a", "/api/v2/", "/api/v2/"),
+		Entry("compares the prefix without regard to case", "/Team-A/status", "/team-a", "/", "/status"),
+		Entry("prepends a path to the root", "/status", "/", "/api", "/api/status"),
+		Entry("does not match part of a segment", "/team-ab/status", "/team-a", "/", "/team-ab/status"),
+		Entry("does not match other paths", "/team-b/status", "/team-a", "/", "/team-b/status"),
+	)
 })
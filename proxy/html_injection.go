@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+const noncePlaceholder = "{{nonce}}"
+
+var headCloseTag = []byte("</head>")
+
+// injectHTML adds the configured headers and snippet to text/html responses.
+// Responses with an unsupported charset or content encoding, or with a body
+// larger than the configured limit, only receive the headers.
+func injectHTML(res *http.Response, cfg config.HTMLInjectionConfig) error {
+	if !isHTML(res.Header.Get("Content-Type")) {
+		return nil
+	}
+
+	nonce := ""
+	if strings.Contains(cfg.Snippet, noncePlaceholder) || headersContainNonce(cfg.Headers) {
+		var err error
+		nonce, err = generateNonce()
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, value := range cfg.Headers {
+		res.Header.Set(name, strings.Replace(value, noncePlaceholder, nonce, -1))
+	}
+
+	if cfg.Snippet == "" || !hasBody(res) {
+		return nil
+	}
+
+	gzipped := false
+	switch strings.ToLower(res.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip":
+		gzipped = true
+	default:
+		return nil
+	}
+
+	if res.ContentLength > cfg.MaxBodySize {
+		return nil
+	}
+
+	raw, err := ioutil.ReadAll(io.LimitReader(res.Body, cfg.MaxBodySize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(raw)) > cfg.MaxBodySize {
+		res.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(raw), res.Body), Closer: res.Body}
+		return nil
+	}
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(raw))
+
+	body := raw
+	if gzipped {
+		body, err = gunzip(raw, cfg.MaxBodySize)
+		if err != nil || body == nil {
+			return nil
+		}
+	}
+
+	snippet := []byte(strings.Replace(cfg.Snippet, noncePlaceholder, nonce, -1))
+	body, ok := insertBeforeHeadClose(body, snippet)
+	if !ok {
+		return nil
+	}
+
+	if gzipped {
+		body, err = gzipBytes(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.TransferEncoding = nil
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// isHTML reports whether the content type is text/html in a charset the
+// ASCII snippet can be safely inserted into.
+func isHTML(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "text/html" {
+		return false
+	}
+
+	switch strings.ToLower(params["charset"]) {
+	case "", "utf-8", "utf8", "us-ascii", "iso-8859-1", "windows-1252":
+		return true
+	default:
+		return false
+	}
+}
+
+func hasBody(res *http.Response) bool {
+	if res.Body == nil || res.Body == http.NoBody {
+		return false
+	}
+	if res.Request != nil && res.Request.Method == http.MethodHead {
+		return false
+	}
+	return res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotModified
+}
+
+func headersContainNonce(headers map[string]string) bool {
+	for _, value := range headers {
+		if strings.Contains(value, noncePlaceholder) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func insertBeforeHeadClose(body, snippet []byte) ([]byte, bool) {
+	i := indexFold(body, headCloseTag)
+	if i < 0 {
+		return nil, false
+	}
+
+	result := make([]byte, 0, len(body)+len(snippet))
+	result = append(result, body[:i]...)
+	result = append(result, snippet...)
+	result = append(result, body[i:]...)
+	return result, true
+}
+
+// indexFold returns the index of the first case-insensitive match of the
+// ASCII sep in s, or -1.
+func indexFold(s, sep []byte) int {
+	for i := 0; i+len(sep) <= len(s); i++ {
+		if bytes.EqualFold(s[i:i+len(sep)], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// gunzip returns nil when the decompressed body is larger than maxSize.
+func gunzip(data []byte, maxSize int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return nil, nil
+	}
+	return body, nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("injectHTML", func() {
+	const page = "<html><head><title>app</title></HEAD><body>hello</body></html>"
+
+	var cfg config.HTMLInjectionConfig
+
+	newResponse := func(contentType string, body []byte) *http.Response {
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		Expect(err).ToNot(HaveOccurred())
+		res := &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}
+		res.Header.Set("Content-Type", contentType)
+		return res
+	}
+
+	readBody := func(res *http.Response) string {
+		body, err := ioutil.ReadAll(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return string(body)
+	}
+
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write([]byte(s))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		return buf.Bytes()
+	}
+
+	BeforeEach(func() {
+		cfg = config.HTMLInjectionConfig{
+			Snippet:     `<script src="/inject.js"></script>`,
+			MaxBodySize: 1024,
+		}
+	})
+
+	It("inserts the snippet before the closing head tag of HTML responses", func() {
+		res := newResponse("text/html; charset=utf-8", []byte(page))
+		Expect(injectHTML(res, cfg)).To(Succeed())
+
+		expected := `<html><head><title>app</title><script src="/inject.js"></script></HEAD><body>hello</body></html>`
+		Expect(readBody(res)).To(Equal(expected))
+		Expect(res.ContentLength).To(Equal(int64(len(expected))))
+		Expect(res.Header.Get("Content-Length")).To(Equal(strconv.Itoa(len(expected))))
+	})
+
+	It("does not modify JSON responses", func() {
+		res := newResponse("application/json", []byte(`{"head": "</head>"}`))
+		cfg.Headers = map[string]string{"Content-Security-Policy": "default-src 'self'"}
+		Expect(injectHTML(res, cfg)).To(Succeed())
+
+		Expect(readBody(res)).To(Equal(`{"head": "</head>"}`))
+		Expect(res.Header.Get("Content-Security-Policy")).To(BeEmpty())
+	})
+
+	It("does not modify HTML in charsets the snippet cannot be inserted into", func() {
+		res := newResponse("text/html; charset=utf-16", []byte(page))
+		Expect(injectHTML(res, cfg)).To(Succeed())
+		Expect(readBody(res)).To(Equal(page))
+	})
+
+	It("leaves bodies larger than the limit untouched", func() {
+		large := "<html><head></head><body>" + strings.Repeat("a", 2048) + "</body></html>"
+		res := newResponse("text/html", []byte(large))
+		res.ContentLength = -1
+		Expect(injectHTML(res, cfg)).To(Succeed())
+		Expect(readBody(res)).To(Equal(large))
+	})
+
+	It("leaves HTML without a head element untouched", func() {
+		res := newResponse("text/html", []byte("<p>fragment</p>"))
+		Expect(injectHTML(res, cfg)).To(Succeed())
+		Expect(readBody(res)).To(Equal("<p>fragment</p>"))
+	})
+
+	It("decompresses and recompresses gzipped responses", func() {
+		res := newResponse("text/html", gzipped(page))
+		res.Header.Set("Content-Encoding", "gzip")
+		Expect(injectHTML(res, cfg)).To(Succeed())
+
+		reader, err := gzip.NewReader(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		body, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring(`<script src="/inject.js"></script></HEAD>`))
+		Expect(res.Header.Get("Content-Encoding")).To(Equal("gzip"))
+	})
+
+	It("skips responses with other content encodings", func() {
+		res := newResponse("text/html", []byte("compressed"))
+		res.Header.Set("Content-Encoding", "br")
+		Expect(injectHTML(res, cfg)).To(Succeed())
+		Expect(readBody(res)).To(Equal("compressed"))
+	})
+
+	It("uses the same nonce in the headers and the snippet", func() {
+		cfg.Snippet = `<script nonce="{{nonce}}"></script>`
+		cfg.Headers = map[string]string{"Content-Security-Policy": "script-src 'nonce-{{nonce}}'"}
+
+		res := newResponse("text/html", []byte(page))
+		Expect(injectHTML(res, cfg)).To(Succeed())
+
+		csp := res.Header.Get("Content-Security-Policy")
+		Expect(csp).To(HavePrefix("script-src 'nonce-"))
+		nonce := strings.TrimSuffix(strings.TrimPrefix(csp, "script-src 'nonce-"), "'")
+		Expect(nonce).ToNot(BeEmpty())
+		Expect(readBody(res)).To(ContainSubstring(`<script nonce="` + nonce + `"></script>`))
+	})
+})
@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+
+	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
+	"code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"github.com/uber-go/zap"
+)
+
+// mirror sends copies of a sample of the requests to routes to the route
+// they registered to mirror them to. Copies are sent in the background and
+// their responses are discarded, so the mirror route never affects the
+// response to the client. The access policies of the mirror route, such as
+// client IP access lists or JWT validation, are not enforced on the copies,
+// which is why their credentials are removed by default.
+type mirror struct {
+	registry            registry.Registry
+	roundTripperFactory round_tripper.RoundTripperFactory
+	defaultLoadBalance  string
+	cfg                 config.TrafficMirroringConfig
+	logger              logger.Logger
+	inFlight            int64
+}
+
+// mirrorRequest sends a copy of the request to the mirror route of its pool,
+// if the pool registered one and the request is sampled. The body of the
+// request is read into memory to be sent twice, and replaced with a reader
+// of the same bytes.
+func (m *mirror) mirrorRequest(request *http.Request, pool *route.Pool) {
+	host, percent := pool.Mirror()
+	if host == "" || percent <= 0 {
+		return
+	}
+	// a copy is never mirrored again, so that routes cannot mirror to each
+	// other in a loop
+	if request.Header.Get(router_http.CfMirroredFrom) != "" {
+		return
+	}
+	if reqInfo, err := handlers.ContextRequestInfo(request); err == nil && reqInfo.RouteServiceURL != nil {
+		return
+	}
+	if rand.Intn(100) >= percent {
+		return
+	}
+
+	if !m.acquire() {
+		m.logger.Debug("mirror-request-dropped", zap.String("mirror-host", host))
+		return
+	}
+	body, ok := m.readBody(request)
+	if !ok {
+		m.release()
+		m.logger.Debug("mirror-request-body-too-large", zap.String("mirror-host", host))
+		return
+	}
+
+	copied := request.Clone(context.Background())
+	copied.Host = host
+	copied.RequestURI = ""
+	for _, h := range hopHeaders {
+		copied.Header.Del(h)
+	}
+	if !m.cfg.ForwardCredentials {
+		for _, h := range credentialHeaders {
+			copied.Header.Del(h)
+		}
+	}
+	copied.Header.Set(router_http.CfMirroredFrom, request.Host)
+	copied.Body = nil
+	if len(body) > 0 {
+		copied.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	copied.ContentLength = int64(len(body))
+
+	go func() {
+		defer m.release()
+		m.send(copied)
+	}()
+}
+
+// hopHeaders are the headers of a connection rather than of a request, which
+// are not copied to the mirror route.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// credentialHeaders are the headers that carry the credentials of the
+// client, which are not copied to the mirror route unless configured to be.
+var credentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"X-Forwarded-Client-Cert",
+}
+
+// acquire reserves one of the copies that may be in flight at once, and
+// reports false if there are none left.
+func (m *mirror) acquire() bool {
+	if atomic.AddInt64(&m.inFlight, 1) > int64(m.cfg.MaxInFlightRequests) && m.cfg.MaxInFlightRequests > 0 {
+		m.release()
+		return false
+	}
+	return true
+}
+
+func (m *mirror) release() {
+	atomic.AddInt64(&m.inFlight, -1)
+}
+
+// readBody reads the body of the request into memory and replaces it with a
+// reader of the same bytes. It reports false, leaving the body to be read as
+// before, if the body is larger than the configured maximum.
+func (m *mirror) readBody(request *http.Request) ([]byte, bool) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, true
+	}
+	if request.ContentLength > m.cfg.MaxBodyBytes {
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(request.Body, m.cfg.MaxBodyBytes+1))
+	original := request.Body
+	if err != nil || int64(len(body)) > m.cfg.MaxBodyBytes {
+		request.Body = readCloser{io.MultiReader(bytes.NewReader(body), original), original}
+		return nil, false
+	}
+	request.Body = readCloser{bytes.NewReader(body), original}
+	return body, true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// send sends the copy of a request to an endpoint of the mirror route,
+// selected by the load balancing algorithm of the route.
+func (m *mirror) send(request *http.Request) {
+	logger := m.logger.With(zap.String("mirror-host", request.Host))
+
+	pool := m.registry.Lookup(route.Uri(request.Host + request.URL.EscapedPath()))
+	if pool != nil {
+		pool = pool.ForRequest(request)
+	}
+	if pool == nil {
+		logger.Debug("mirror-route-unknown")
+		return
+	}
+	iter := pool.Endpoints(m.defaultLoadBalance, "")
+	endpoint := iter.Next()
+	if endpoint == nil {
+		logger.Debug("mirror-route-no-endpoints")
+		return
+	}
+
+	request.URL.Host = endpoint.CanonicalAddr()
+	if endpoint.IsTLS() {
+		request.URL.Scheme = "https"
+	} else {
+		request.URL.Scheme = "http"
+	}
+	ctx, cancel := context.WithTimeout(request.Context(), m.cfg.Timeout)
+	defer cancel()
+
+	iter.PreRequest(endpoint)
+	defer iter.PostRequest(endpoint)
+
+	res, err := round_tripper.GetRoundTripper(endpoint, m.roundTripperFactory).RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		logger.Debug("mirror-request-failed", zap.Error(err))
+		return
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+}
@@ -3,9 +3,12 @@ package proxy
 import (
 	"errors"
 	"net/http"
+	"net/url"
 
 	router_http "code.cloudfoundry.org/gorouter/common/http"
 	"code.cloudfoundry.org/gorouter/handlers"
+	"code.cloudfoundry.org/gorouter/proxy/handler"
+	"code.cloudfoundry.org/gorouter/route"
 )
 
 func (p *proxy) modifyResponse(res *http.Response) error {
@@ -37,5 +40,78 @@ func (p *proxy) modifyResponse(res *http.Response) error {
 		res.Header.Set(router_http.CfRouteEndpointHeader, endpoint.CanonicalAddr())
 	}
 
+	if p.rewriteRedirectLocation && reqInfo.RouteServiceURL == nil {
+		host := req.Host
+		if reqInfo.RequestedHost != "" {
+			host = reqInfo.RequestedHost
+		}
+		rewriteLocation(res, req, host, endpoint)
+		if path := routePool.PathRewrite(); path != "" {
+			rewriteLocationPath(res, host, path, routePool.ContextPath())
+		}
+	}
+
+	// streams are sent as they arrive, so they cannot be read whole
+	streaming := handlers.IsStreamingRoute(routePool) || handlers.IsEventStream(res.Header.Get("Content-Type"))
+	if !streaming && (p.htmlInjection.Snippet != "" || len(p.htmlInjection.Headers) > 0) {
+		if err := injectHTML(res, p.htmlInjection); err != nil {
+			return err
+		}
+	}
+	if !streaming && p.compression.Enabled && !isCompressionDisabled(routePool) {
+		if err := compressResponse(res, p.compression); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// rewriteLocation replaces a Location header pointing at the backend's
+// internal address, or at the Host the request was sent to the backend with,
+// with the public URI the client requested on host. Requests bound to a route
+// service reach this point on their second pass through the router, so the
+// request Host is already the public route.
+func rewriteLocation(res *http.Response, req *http.Request, host string, endpoint *route.Endpoint) {
+	location := res.Header.Get("Location")
+	if location == "" || host == "" {
+		return
+	}
+
+	locationURL, err := url.Parse(location)
+	if err != nil {
+		return
+	}
+	rewrittenHost := host != req.Host && locationURL.Host == req.Host
+	if locationURL.Host != endpoint.CanonicalAddr() && !rewrittenHost {
+		return
+	}
+
+	scheme := req.Header.Get("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	locationURL.Scheme = scheme
+	locationURL.Host = host
+	res.Header.Set("Location", locationURL.String())
+}
+
+// rewriteLocationPath maps the path of a Location header on host, or without
+// a host, from the path the request was sent to the backend under back to
+// the context path of the route.
+func rewriteLocationPath(res *http.Response, host, path, contextPath string) {
+	location := res.Header.Get("Location")
+	if location == "" {
+		return
+	}
+
+	locationURL, err := url.Parse(location)
+	if err != nil || locationURL.Host != "" && locationURL.Host != host || locationURL.Path == "" {
+		return
+	}
+
+	locationURL.Path = handler.RewritePathPrefix(locationURL.Path, path, contextPath)
+	locationURL.RawPath = ""
+	res.Header.Set("Location", locationURL.String())
+}
@@ -2,10 +2,14 @@ package proxy
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/logger/fakes"
 	"code.cloudfoundry.org/gorouter/route"
@@ -136,4 +140,186 @@ var _ = Describe("modifyResponse", func() {
 			})
 		})
 	})
+	Describe("Location header", func() {
+		BeforeEach(func() {
+			resp.StatusCode = http.StatusFound
+			resp.Header.Set("Location", "http://1.2.3.4:5678/login?next=%2Fhome")
+			resp.Request.Host = "foo.com"
+			resp.Request.Header.Set("X-Forwarded-Proto", "https")
+		})
+
+		It("does not rewrite the Location header by default", func() {
+			err := p.modifyResponse(resp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Header.Get("Location")).To(Equal("http://1.2.3.4:5678/login?next=%2Fhome"))
+		})
+
+		Context("when rewriting redirect locations is enabled", func() {
+			BeforeEach(func() {
+				p.rewriteRedirectLocation = true
+			})
+
+			It("rewrites a redirect to the backend address to the public URI", func() {
+				err := p.modifyResponse(resp)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Header.Get("Location")).To(Equal("https://foo.com/login?next=%2Fhome"))
+			})
+
+			It("leaves redirects to other hosts untouched", func() {
+				resp.Header.Set("Location", "https://login.example.com/oauth")
+				err := p.modifyResponse(resp)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Header.Get("Location")).To(Equal("https://login.example.com/oauth"))
+			})
+
+			It("leaves relative redirects untouched", func() {
+				resp.Header.Set("Location", "/login")
+				err := p.modifyResponse(resp)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Header.Get("Location")).To(Equal("/login"))
+			})
+
+			Context("when the request was sent with the Host the route registered", func() {
+				BeforeEach(func() {
+					resp.Request.Host = "legacy.internal"
+					reqInfo.RequestedHost = "foo.com"
+				})
+
+				It("rewrites a redirect to the backend address to the public URI", func() {
+					err := p.modifyResponse(resp)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Header.Get("Location")).To(Equal("https://foo.com/login?next=%2Fhome"))
+				})
+
+				It("rewrites a redirect to the registered Host to the public URI", func() {
+					resp.Header.Set("Location", "http://legacy.internal/home")
+					err := p.modifyResponse(resp)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Header.Get("Location")).To(Equal("https://foo.com/home"))
+				})
+			})
+
+			Context("when the route strips its context path", func() {
+				BeforeEach(func() {
+					reqInfo.RoutePool = route.NewPool(&route.PoolOpts{
+						Logger:      new(fakes.FakeLogger),
+						Host:        "foo.com",
+						ContextPath: "/team-a",
+					})
+					reqInfo.RoutePool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "1.2.3.4", Port: 5678, StripPathPrefix: true}))
+				})
+
+				It("maps the path of a redirect to the backend back to the route", func() {
+					err := p.modifyResponse(resp)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Header.Get("Location")).To(Equal("https://foo.com/team-a/login?next=%2Fhome"))
+				})
+
+				It("maps the path of a relative redirect back to the route", func() {
+					resp.Header.Set("Location", "/home")
+					err := p.modifyResponse(resp)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Header.Get("Location")).To(Equal("/team-a/home"))
+				})
+
+				It("does not map redirects to other hosts", func() {
+					resp.Header.Set("Location", "https://login.example.com/home")
+					err := p.modifyResponse(resp)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Header.Get("Location")).To(Equal("https://login.example.com/home"))
+				})
+			})
+
+			Context("when the response is from a route service", func() {
+				BeforeEach(func() {
+					reqInfo.RouteServiceURL, _ = url.Parse("https://rs.example.com")
+				})
+
+				It("does not rewrite the Location header", func() {
+					err := p.modifyResponse(resp)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Header.Get("Location")).To(Equal("http://1.2.3.4:5678/login?next=%2Fhome"))
+				})
+			})
+		})
+	})
+
+	Describe("HTML injection", func() {
+		BeforeEach(func() {
+			resp.Header.Set("Content-Type", "text/html")
+			resp.Body = ioutil.NopCloser(strings.NewReader("<html><head></head></html>"))
+		})
+
+		It("does not modify the response by default", func() {
+			err := p.modifyResponse(resp)
+			Expect(err).ToNot(HaveOccurred())
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("<html><head></head></html>"))
+		})
+
+		Context("when a snippet is configured", func() {
+			BeforeEach(func() {
+				p.htmlInjection = config.HTMLInjectionConfig{
+					Snippet:     "<meta name=injected>",
+					MaxBodySize: 1024,
+				}
+			})
+
+			It("injects the snippet", func() {
+				err := p.modifyResponse(resp)
+				Expect(err).ToNot(HaveOccurred())
+				body, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("<html><head><meta name=injected></head></html>"))
+			})
+		})
+	})
+
+	Describe("compression", func() {
+		BeforeEach(func() {
+			resp.Header.Set("Content-Type", "text/plain")
+			resp.Body = ioutil.NopCloser(strings.NewReader("hello"))
+			resp.ContentLength = 5
+			resp.Request.Header.Set("Accept-Encoding", "gzip")
+		})
+
+		It("does not compress the response by default", func() {
+			err := p.modifyResponse(resp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		})
+
+		Context("when compression is enabled", func() {
+			BeforeEach(func() {
+				p.compression = config.CompressionConfig{
+					Enabled:      true,
+					Encodings:    []string{"gzip"},
+					ContentTypes: []string{"text/*"},
+				}
+			})
+
+			It("compresses the response", func() {
+				err := p.modifyResponse(resp)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+			})
+
+			Context("when the route opted out", func() {
+				BeforeEach(func() {
+					reqInfo.RoutePool.Put(route.NewEndpoint(&route.EndpointOpts{
+						Host: "1.2.3.4",
+						Port: 5678,
+						Tags: map[string]string{DisableCompressionTag: "true"},
+					}))
+				})
+
+				It("does not compress the response", func() {
+					err := p.modifyResponse(resp)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+				})
+			})
+		})
+	})
 })
@@ -12,6 +12,7 @@ import (
 
 	"code.cloudfoundry.org/gorouter/accesslog"
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/common/jwt"
 	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/logger"
@@ -23,6 +24,7 @@ import (
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/routeservice"
+	"code.cloudfoundry.org/gorouter/tracing"
 	"github.com/cloudfoundry/dropsonde"
 	"github.com/uber-go/zap"
 	"github.com/urfave/negroni"
@@ -46,13 +48,33 @@ type proxy struct {
 	forceForwardedProtoHttps bool
 	sanitizeForwardedProto   bool
 	defaultLoadBalance       string
+	consistentHash           config.ConsistentHashConfig
+	trustedProxies           handlers.TrustedProxies
 	endpointDialTimeout      time.Duration
-	endpointTimeout          time.Duration
+	timeouts                 *round_tripper.Timeouts
 	bufferPool               httputil.BufferPool
 	backendTLSConfig         *tls.Config
+	backendClientCerts       *utils.ClientCertificates
+	backendProxyProtocol     string
 	skipSanitization         func(req *http.Request) bool
 	disableXFFLogging        bool
 	disableSourceIPLogging   bool
+	rewriteRedirectLocation  bool
+	htmlInjection            config.HTMLInjectionConfig
+	compression              config.CompressionConfig
+	emitForwarded            bool
+	emitXForwarded           bool
+	errorHandler             *round_tripper.ErrorHandler
+	mirror                   *mirror
+	rateLimit                *handlers.RateLimit
+	headerRules              *handlers.HeaderRules
+}
+
+// Reloader is implemented by the handler NewProxy returns.
+type Reloader interface {
+	// Reload applies the endpoint timeouts, the rate limit and the HTTP
+	// header rules of cfg to the requests that start after it returns.
+	Reload(cfg *config.Config)
 }
 
 func NewProxy(
@@ -63,10 +85,16 @@ func NewProxy(
 	reporter metrics.ProxyReporter,
 	routeServiceConfig *routeservice.RouteServiceConfig,
 	tlsConfig *tls.Config,
+	backendClientCerts *utils.ClientCertificates,
 	heartbeatOK *int32,
 	routeServicesTransport http.RoundTripper,
 	skipSanitization func(req *http.Request) bool,
+	tracer *tracing.Tracer,
+	responseCache *handlers.ResponseCache,
 ) http.Handler {
+	if backendClientCerts == nil {
+		backendClientCerts = utils.NewClientCertificates(cfg.Backends.ClientAuthCertificate, cfg.Backends.NamedClientAuthCertificates)
+	}
 
 	p := &proxy{
 		accessLogger:             accessLogger,
@@ -81,17 +109,29 @@ func NewProxy(
 		forceForwardedProtoHttps: cfg.ForceForwardedProtoHttps,
 		sanitizeForwardedProto:   cfg.SanitizeForwardedProto,
 		defaultLoadBalance:       cfg.LoadBalance,
+		consistentHash:           cfg.ConsistentHash,
 		endpointDialTimeout:      cfg.EndpointDialTimeout,
-		endpointTimeout:          cfg.EndpointTimeout,
+		timeouts:                 round_tripper.NewTimeouts(cfg.EndpointTimeout, cfg.MaxRouteEndpointTimeout),
 		bufferPool:               NewBufferPool(),
 		backendTLSConfig:         tlsConfig,
+		backendClientCerts:       backendClientCerts,
+		backendProxyProtocol:     cfg.Backends.ProxyProtocol,
 		skipSanitization:         skipSanitization,
 		disableXFFLogging:        cfg.Logging.DisableLogForwardedFor,
 		disableSourceIPLogging:   cfg.Logging.DisableLogSourceIP,
+		rewriteRedirectLocation:  cfg.RewriteRedirectLocation,
+		htmlInjection:            cfg.HTMLInjection,
+		compression:              cfg.Compression,
+		emitForwarded:            emitsHeader(cfg.EmitForwardedHeaders, config.ForwardedHeaderForwarded),
+		emitXForwarded:           emitsHeader(cfg.EmitForwardedHeaders, config.ForwardedHeaderXForwarded),
+		errorHandler: &round_tripper.ErrorHandler{
+			MetricReporter: reporter,
+			ErrorSpecs:     round_tripper.DefaultErrorSpecs,
+		},
 	}
 
-	roundTripperFactory := &round_tripper.FactoryImpl{
-		Template: &http.Transport{
+	transportTemplate := func(tlsConfig *tls.Config) *http.Transport {
+		return &http.Transport{
 			Dial:                (&net.Dialer{Timeout: cfg.EndpointDialTimeout}).Dial,
 			DisableKeepAlives:   cfg.DisableKeepAlives,
 			MaxIdleConns:        cfg.MaxIdleConns,
@@ -99,18 +139,50 @@ func NewProxy(
 			MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
 			DisableCompression:  true,
 			TLSClientConfig:     tlsConfig,
-		},
+
+			ResponseHeaderTimeout: cfg.Backends.ResponseHeaderTimeout,
+		}
+	}
+
+	idleConnections := round_tripper.NewIdleConnections(p.reporter)
+	roundTripperFactory := &round_tripper.FactoryImpl{
+		Template:           transportTemplate(tlsConfig),
+		ClientCertificates: backendClientCerts,
+		MaxRequestsPerConn: cfg.Backends.MaxRequestsPerConn,
+		Reporter:           p.reporter,
+		ProxyProtocol:      cfg.Backends.ProxyProtocol,
+		IdleConnections:    idleConnections,
+	}
+
+	// route services are offered their own TLS versions, and are not sent
+	// PROXY protocol headers meant for backends
+	routeServiceTLSConfig := tlsConfig.Clone()
+	routeServiceTLSConfig.MinVersion = cfg.RouteServicesMinTLSVersion
+	routeServiceTLSConfig.MaxVersion = cfg.RouteServicesMaxTLSVersion
+	routeServiceRoundTripperFactory := &round_tripper.FactoryImpl{
+		Template:           transportTemplate(routeServiceTLSConfig),
+		ClientCertificates: backendClientCerts,
+		MaxRequestsPerConn: cfg.Backends.MaxRequestsPerConn,
+		Reporter:           p.reporter,
+		IdleConnections:    idleConnections,
+	}
+
+	p.mirror = &mirror{
+		registry:            registry,
+		roundTripperFactory: roundTripperFactory,
+		defaultLoadBalance:  cfg.LoadBalance,
+		cfg:                 cfg.TrafficMirroring,
+		logger:              logger.Session("mirror"),
 	}
 
 	prt := round_tripper.NewProxyRoundTripper(
-		roundTripperFactory, fails.RetriableClassifiers, p.logger,
+		roundTripperFactory, routeServiceRoundTripperFactory, fails.RetriableClassifiers, p.logger,
 		p.defaultLoadBalance, p.reporter, p.secureCookies,
-		&round_tripper.ErrorHandler{
-			MetricReporter: p.reporter,
-			ErrorSpecs:     round_tripper.DefaultErrorSpecs,
-		},
+		p.errorHandler,
 		routeServicesTransport,
-		p.endpointTimeout,
+		p.timeouts,
+		cfg.PropagateTimeoutHeader,
+		cfg.RetryPolicy,
 	)
 
 	rproxy := &httputil.ReverseProxy{
@@ -121,13 +193,37 @@ func NewProxy(
 		ModifyResponse: p.modifyResponse,
 	}
 
+	streamingProxy := &httputil.ReverseProxy{
+		Director:       p.setupProxyRequest,
+		Transport:      prt,
+		FlushInterval:  streamingFlushInterval(cfg.Streaming.FlushInterval),
+		BufferPool:     p.bufferPool,
+		ModifyResponse: p.modifyResponse,
+	}
+
 	routeServiceHandler := handlers.NewRouteService(routeServiceConfig, registry, logger)
 	zipkinHandler := handlers.NewZipkin(cfg.Tracing.EnableZipkin, cfg.ExtraHeadersToLog, logger)
+	trustedProxies := handlers.TrustedProxies{
+		Nets:            cfg.TrustedProxyNets,
+		ForwardedHeader: cfg.TerminatingProxy.ForwardedHeader,
+	}
+	p.trustedProxies = trustedProxies
 	n := negroni.New()
 	n.Use(handlers.NewPanicCheck(p.heartbeatOK, logger))
 	n.Use(handlers.NewRequestInfo())
 	n.Use(handlers.NewProxyWriter(logger))
+	// before anything that reports or checks the client of the request
+	n.Use(handlers.NewUntrustedPeer(
+		trustedProxies,
+		SkipUntrustedPeer(p.skipSanitization, routeServiceHandler.(*handlers.RouteService)),
+	))
+	if cfg.DrainRequestBodyOnError {
+		n.Use(handlers.NewDrainRequestBody(cfg.DrainRequestBodyOnErrorMaxBytes, logger))
+	}
 	n.Use(handlers.NewVcapRequestIdHeader(logger))
+	if tracer != nil {
+		n.Use(handlers.NewTracing(tracer, logger))
+	}
 	n.Use(handlers.NewHTTPStartStop(dropsonde.DefaultEmitter, logger))
 	n.Use(handlers.NewAccessLog(accessLogger, zipkinHandler.HeadersToLog(), logger))
 	n.Use(handlers.NewReporter(reporter, logger))
@@ -135,10 +231,46 @@ func NewProxy(
 		logger.Debug("http-rewrite", zap.Object("config", cfg.HTTPRewrite))
 		n.Use(handlers.NewHTTPRewriteHandler(cfg.HTTPRewrite))
 	}
+	if len(cfg.SecurityHeaders) > 0 {
+		n.Use(handlers.NewSecurityHeaders(cfg.SecurityHeaders))
+	}
 	n.Use(handlers.NewProxyHealthcheck(cfg.HealthCheckUserAgent, p.heartbeatOK, logger))
+	if cfg.LoadShedding.MaxInFlightRequests > 0 {
+		n.Use(handlers.NewMaxInFlight(cfg.LoadShedding.MaxInFlightRequests, cfg.LoadShedding.RetryAfter, reporter, logger))
+	}
 	n.Use(zipkinHandler)
 	n.Use(handlers.NewProtocolCheck(logger))
+	n.Use(handlers.NewMaxRequestHeaders(cfg.MaxHeaderBytes, cfg.MaxHeaderCount, reporter, logger))
+	n.Use(handlers.NewGetRequestBody(cfg.GetRequestBodyPolicy, logger))
+	n.Use(handlers.NewHTTP10(cfg.HTTP10Policy, cfg.HTTP10DefaultHost, logger))
 	n.Use(handlers.NewLookup(registry, reporter, logger))
+	n.Use(handlers.NewClientIPAccess(
+		cfg.ClientIPAccess,
+		trustedProxies,
+		SkipSanitizeXFP(p.skipSanitization, routeServiceHandler.(*handlers.RouteService)),
+		logger,
+	))
+	n.Use(handlers.NewMaxRequestBody(cfg.MaxRequestBodyBytes, logger))
+	n.Use(handlers.NewRequestDecompression(cfg.MaxDecompressedRequestBodyBytes, logger))
+	p.rateLimit = handlers.NewRateLimit(
+		cfg.RateLimit,
+		trustedProxies,
+		SkipSanitizeXFP(p.skipSanitization, routeServiceHandler.(*handlers.RouteService)),
+		reporter,
+		logger,
+	).(*handlers.RateLimit)
+	n.Use(p.rateLimit)
+	n.Use(handlers.NewCORS(logger))
+	n.Use(handlers.NewClientCertAllowlist(
+		SkipSanitizeXFP(p.skipSanitization, routeServiceHandler.(*handlers.RouteService)),
+		logger,
+	))
+	var jwtValidator *jwt.Validator
+	if cfg.JWT.JWKSURL != "" {
+		keys := jwt.NewKeySet(cfg.JWT.JWKSURL, &http.Client{Timeout: cfg.JWT.JWKSTimeout}, cfg.JWT.JWKSRefreshInterval)
+		jwtValidator = jwt.NewValidator(keys, cfg.JWT.Issuer, cfg.JWT.ClockSkew)
+	}
+	n.Use(handlers.NewJWT(jwtValidator, cfg.JWT, cfg.OAuth.FailureMode, reporter, logger))
 	n.Use(handlers.NewClientCert(
 		SkipSanitize(p.skipSanitization, routeServiceHandler.(*handlers.RouteService)),
 		ForceDeleteXFCCHeader(routeServiceHandler.(*handlers.RouteService), cfg.ForwardedClientCert),
@@ -149,13 +281,73 @@ func NewProxy(
 		SkipSanitization:         SkipSanitizeXFP(p.skipSanitization, routeServiceHandler.(*handlers.RouteService)),
 		ForceForwardedProtoHttps: p.forceForwardedProtoHttps,
 		SanitizeForwardedProto:   p.sanitizeForwardedProto,
+		TerminatingProxy:         cfg.TerminatingProxy,
+		TrustedProxyNets:         cfg.TrustedProxyNets,
 		Logger:                   logger,
 	})
+	// installed without rules too, so that a reload can add them
+	p.headerRules = handlers.NewHeaderRules(cfg.HeaderRules, logger).(*handlers.HeaderRules)
+	n.Use(p.headerRules)
 	n.Use(routeServiceHandler)
+	if responseCache != nil {
+		n.Use(responseCache)
+	}
 	n.Use(p)
-	n.UseHandler(rproxy)
+	n.UseHandler(&streamingHandler{
+		proxy:          rproxy,
+		streamingProxy: streamingProxy,
+		logger:         logger,
+	})
+
+	return &reloadableHandler{Negroni: n, proxy: p}
+}
+
+// reloadableHandler is the handler of NewProxy, which reloads the settings of
+// its proxy.
+type reloadableHandler struct {
+	*negroni.Negroni
+	proxy *proxy
+}
 
-	return n
+func (h *reloadableHandler) Reload(cfg *config.Config) {
+	h.proxy.Reload(cfg)
+}
+
+func (p *proxy) Reload(cfg *config.Config) {
+	p.timeouts.Update(cfg.EndpointTimeout, cfg.MaxRouteEndpointTimeout)
+	p.rateLimit.Update(cfg.RateLimit)
+	p.headerRules.Update(cfg.HeaderRules)
+}
+
+// streamingFlushInterval returns the flush interval of the reverse proxy
+// for streaming routes, where a negative interval flushes after every write.
+func streamingFlushInterval(interval time.Duration) time.Duration {
+	if interval == 0 {
+		return -1
+	}
+	return interval
+}
+
+// streamingHandler proxies requests to routes tagged with
+// handlers.StreamingTag with streamingProxy, and all others with proxy.
+type streamingHandler struct {
+	proxy          http.Handler
+	streamingProxy http.Handler
+	logger         logger.Logger
+}
+
+func (h *streamingHandler) ServeHTTP(rw http.ResponseWriter, request *http.Request) {
+	reqInfo, err := handlers.ContextRequestInfo(request)
+	if err != nil {
+		h.logger.Fatal("request-info-err", zap.Error(err))
+		return
+	}
+
+	if reqInfo.RoutePool != nil && handlers.IsStreamingRoute(reqInfo.RoutePool) {
+		h.streamingProxy.ServeHTTP(rw, request)
+		return
+	}
+	h.proxy.ServeHTTP(rw, request)
 }
 
 type RouteServiceValidator interface {
@@ -172,6 +364,15 @@ func SkipSanitizeXFP(arrivedViaRouteServicesServer func(*http.Request) bool, rou
 	}
 }
 
+// SkipUntrustedPeer keeps the forwarding headers of requests that come back
+// from a route service. It does not check the route the request was signed
+// for, since it is used before the route is looked up.
+func SkipUntrustedPeer(arrivedViaRouteServicesServer func(*http.Request) bool, routeService *handlers.RouteService) func(*http.Request) bool {
+	return func(req *http.Request) bool {
+		return arrivedViaRouteServicesServer(req) || routeService.HasValidSignature(req)
+	}
+}
+
 func SkipSanitize(arrivedViaRouteServicesServer func(*http.Request) bool, routeServiceValidator RouteServiceValidator) func(*http.Request) (bool, error) {
 	return func(req *http.Request) (bool, error) {
 		valid, err := routeServiceValidator.ArrivedViaRouteService(req)
@@ -188,7 +389,7 @@ func ForceDeleteXFCCHeader(routeServiceValidator RouteServiceValidator, forwarde
 		if err != nil {
 			return false, err
 		}
-		return valid && forwardedClientCert != config.SANITIZE_SET, nil
+		return valid && handlers.ForwardedClientCertMode(req, forwardedClientCert) != config.SANITIZE_SET, nil
 	}
 }
 
@@ -199,6 +400,10 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 	if err != nil {
 		p.logger.Fatal("request-info-err", zap.Error(err))
 	}
+	if reqInfo.RoutePool == nil {
+		p.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
+	}
+
 	handler := handler.NewRequestHandler(
 		request,
 		proxyWriter,
@@ -208,15 +413,18 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 		p.backendTLSConfig,
 		handler.DisableXFFLogging(p.disableXFFLogging),
 		handler.DisableSourceIPLogging(p.disableSourceIPLogging),
+		handler.BackendClientCertificates(p.backendClientCerts),
+		handler.BackendErrorHandler(p.errorHandler),
+		handler.BackendProxyProtocol(p.backendProxyProtocol),
+		handler.ForwardedHeaders(p.emitForwarded, p.emitXForwarded),
+		handler.HostRewrite(reqInfo.RoutePool.HostRewrite()),
+		handler.PathRewrite(reqInfo.RoutePool.ContextPath(), reqInfo.RoutePool.PathRewrite()),
 	)
 
-	if reqInfo.RoutePool == nil {
-		p.logger.Fatal("request-info-err", zap.Error(errors.New("failed-to-access-RoutePool")))
-	}
-
+	reqInfo.HashKey = p.hashKey(request)
 	stickyEndpointId := getStickySession(request)
 	iter := &wrappedIterator{
-		nested: reqInfo.RoutePool.Endpoints(p.defaultLoadBalance, stickyEndpointId),
+		nested: reqInfo.RoutePool.EndpointsForKey(p.defaultLoadBalance, stickyEndpointId, request.URL.Path, reqInfo.HashKey),
 
 		afterNext: func(endpoint *route.Endpoint) {
 			if endpoint != nil {
@@ -236,9 +444,34 @@ func (p *proxy) ServeHTTP(responseWriter http.ResponseWriter, request *http.Requ
 		return
 	}
 
+	p.mirror.mirrorRequest(request, reqInfo.RoutePool)
 	next(responseWriter, request)
 }
 
+// hashKey returns the attribute of the request that endpoints are selected by
+// when the load balancing algorithm is consistent-hash, or an empty string if
+// the request does not have it.
+func (p *proxy) hashKey(request *http.Request) string {
+	switch {
+	case p.consistentHash.Header != "":
+		return request.Header.Get(p.consistentHash.Header)
+	case p.consistentHash.Cookie != "":
+		cookie, err := request.Cookie(p.consistentHash.Cookie)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	case p.consistentHash.ClientIP:
+		// the client behind trusted proxies, not the last proxy
+		ip := p.trustedProxies.ClientIP(request)
+		if ip == nil {
+			return ""
+		}
+		return ip.String()
+	}
+	return ""
+}
+
 func (p *proxy) setupProxyRequest(target *http.Request) {
 	reqInfo, err := handlers.ContextRequestInfo(target)
 	if err != nil {
@@ -259,6 +492,30 @@ func (p *proxy) setupProxyRequest(target *http.Request) {
 
 	handler.SetRequestXRequestStart(target)
 	target.Header.Del(router_http.CfAppInstance)
+	if p.emitForwarded {
+		handler.SetRequestForwarded(target)
+	}
+	// requests to route services keep the Host and path of the route, which
+	// the route service forwards them back to
+	if host := reqInfo.RoutePool.HostRewrite(); host != "" && reqInfo.RouteServiceURL == nil {
+		reqInfo.RequestedHost = target.Host
+		handler.SetRequestHost(target, host)
+	}
+	if path := reqInfo.RoutePool.PathRewrite(); path != "" && reqInfo.RouteServiceURL == nil {
+		handler.SetRequestPath(target, reqInfo.RoutePool.ContextPath(), path)
+	}
+	if !p.emitXForwarded {
+		handler.RemoveRequestXForwarded(target)
+	}
+}
+
+func emitsHeader(headers []string, header string) bool {
+	for _, h := range headers {
+		if h == header {
+			return true
+		}
+	}
+	return false
 }
 
 type wrappedIterator struct {
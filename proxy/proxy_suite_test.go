@@ -18,6 +18,7 @@ import (
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/routeservice"
 	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/gorouter/tracing"
 
 	"testing"
 	"time"
@@ -52,6 +53,7 @@ var (
 	fakeEmitter             *fake.FakeEventEmitter
 	fakeRouteServicesClient *sharedfakes.RoundTripper
 	skipSanitization        func(req *http.Request) bool
+	tracer                  *tracing.Tracer
 )
 
 func TestProxy(t *testing.T) {
@@ -76,6 +78,7 @@ var _ = BeforeEach(func() {
 	conf.EndpointDialTimeout = 50 * time.Millisecond
 	fakeReporter = &fakes.FakeCombinedReporter{}
 	skipSanitization = func(*http.Request) bool { return false }
+	tracer = nil
 })
 
 var _ = JustBeforeEach(func() {
@@ -126,7 +129,7 @@ var _ = JustBeforeEach(func() {
 
 	fakeRouteServicesClient = &sharedfakes.RoundTripper{}
 
-	p = proxy.NewProxy(testLogger, al, conf, r, fakeReporter, routeServiceConfig, tlsConfig, heartbeatOK, fakeRouteServicesClient, skipSanitization)
+	p = proxy.NewProxy(testLogger, al, conf, r, fakeReporter, routeServiceConfig, tlsConfig, nil, heartbeatOK, fakeRouteServicesClient, skipSanitization, tracer, nil)
 
 	server := http.Server{Handler: p}
 	go server.Serve(proxyServer)
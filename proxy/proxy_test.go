@@ -25,9 +25,12 @@ import (
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/gorouter/tracing"
+	tracefakes "code.cloudfoundry.org/gorouter/tracing/fakes"
 	"github.com/cloudfoundry/dropsonde/factories"
 	"github.com/cloudfoundry/sonde-go/events"
 	uuid "github.com/nu7hatch/gouuid"
+	"github.com/tedsuo/ifrit"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -86,6 +89,643 @@ var _ = Describe("Proxy", func() {
 		})
 	})
 
+	Describe("HTTP/1.0 requests without a Host header", func() {
+		Context("when the policy is reject", func() {
+			It("responds with a 400", func() {
+				conn := dialProxy(proxyServer)
+
+				conn.WriteLines([]string{
+					"GET / HTTP/1.0",
+				})
+
+				resp, body := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(body).To(ContainSubstring("HTTP/1.0 requests must include a Host header"))
+			})
+
+			It("routes requests that include a Host header", func() {
+				ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+					conn.CheckLine("GET / HTTP/1.1")
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				conn.WriteLines([]string{
+					"GET / HTTP/1.0",
+					"Host: test",
+				})
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+		})
+
+		Context("when the policy is route", func() {
+			BeforeEach(func() {
+				conf.HTTP10Policy = config.HTTP10_ROUTE
+				conf.HTTP10DefaultHost = "default"
+			})
+
+			It("routes the request to the default host", func() {
+				ln := test_util.RegisterHandler(r, "default", func(conn *test_util.HttpConn) {
+					req, err := http.ReadRequest(conn.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(req.Host).To(Equal("default"))
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				conn.WriteLines([]string{
+					"GET / HTTP/1.0",
+				})
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+
+			It("routes requests that include a Host header to that host", func() {
+				ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+					conn.CheckLine("GET / HTTP/1.1")
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				conn.WriteLines([]string{
+					"GET / HTTP/1.0",
+					"Host: test",
+				})
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+
+			It("keeps the client connection alive when asked to", func() {
+				ln := test_util.RegisterHandler(r, "default", func(conn *test_util.HttpConn) {
+					for i := 0; i < 2; i++ {
+						_, err := http.ReadRequest(conn.Reader)
+						if err != nil {
+							return
+						}
+						conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+					}
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				for i := 0; i < 2; i++ {
+					conn.WriteLines([]string{
+						"GET / HTTP/1.0",
+						"Connection: keep-alive",
+					})
+
+					resp, _ := conn.ReadResponse()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+					Expect(resp.Header.Get("Connection")).To(Equal("keep-alive"))
+					Expect(resp.Close).To(BeFalse())
+				}
+			})
+
+			It("closes the client connection when keep-alive is not requested", func() {
+				ln := test_util.RegisterHandler(r, "default", func(conn *test_util.HttpConn) {
+					conn.ReadRequest()
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				conn.WriteLines([]string{
+					"GET / HTTP/1.0",
+				})
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.Close).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Request framing", func() {
+		It("rejects requests with conflicting Content-Length headers", func() {
+			conn := dialProxy(proxyServer)
+
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: test",
+				"Content-Length: 3",
+				"Content-Length: 13",
+				"",
+				"abcGET / HTTP/1.1",
+			})
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+
+		It("rejects requests with an invalid Content-Length header", func() {
+			conn := dialProxy(proxyServer)
+
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: test",
+				"Content-Length: 3, 13",
+				"",
+				"abc",
+			})
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+
+		It("rejects requests with an unsupported Transfer-Encoding", func() {
+			conn := dialProxy(proxyServer)
+
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: test",
+				"Transfer-Encoding: gzip, chunked",
+				"",
+				"0",
+				"",
+			})
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotImplemented))
+		})
+
+		It("forwards requests with both Content-Length and chunked Transfer-Encoding without the Content-Length", func() {
+			receivedHeaders := make(chan []string, 1)
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				headers := []string{}
+				for {
+					line, err := conn.Reader.ReadString('\n')
+					Expect(err).ToNot(HaveOccurred())
+					line = strings.TrimRight(line, "\r\n")
+					if line == "" {
+						break
+					}
+					headers = append(headers, line)
+				}
+				receivedHeaders <- headers
+
+				body, err := ioutil.ReadAll(httputil.NewChunkedReader(conn.Reader))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("abc"))
+
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: test",
+				"Content-Length: 4",
+				"Transfer-Encoding: chunked",
+				"",
+				"3",
+				"abc",
+				"0",
+				"",
+			})
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var headers []string
+			Eventually(receivedHeaders).Should(Receive(&headers))
+			Expect(headers).To(ContainElement("Transfer-Encoding: chunked"))
+			for _, h := range headers {
+				Expect(strings.ToLower(h)).ToNot(HavePrefix("content-length:"))
+			}
+		})
+	})
+
+	Describe("Request body size limit", func() {
+		BeforeEach(func() {
+			conf.MaxRequestBodyBytes = 10
+		})
+
+		It("rejects requests whose Content-Length is over the limit without proxying them", func() {
+			var backendCalled int32
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				atomic.StoreInt32(&backendCalled, 1)
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: test",
+				"Content-Length: 11",
+				"",
+				"0123456789a",
+			})
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(atomic.LoadInt32(&backendCalled)).To(BeZero())
+		})
+
+		It("responds with a 413 when a chunked body goes over the limit", func() {
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = ioutil.ReadAll(req.Body)
+				Expect(err).To(HaveOccurred())
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: test",
+				"Transfer-Encoding: chunked",
+				"",
+				"b",
+				"0123456789a",
+				"0",
+				"",
+			})
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+		})
+
+		It("proxies bodies within the limit", func() {
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				body, err := ioutil.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(Equal("0123456789"))
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteLines([]string{
+				"POST / HTTP/1.1",
+				"Host: test",
+				"Transfer-Encoding: chunked",
+				"",
+				"a",
+				"0123456789",
+				"0",
+				"",
+			})
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("Request header limits", func() {
+		BeforeEach(func() {
+			conf.MaxHeaderCount = 3
+		})
+
+		It("rejects requests with too many headers without proxying them", func() {
+			var backendCalled int32
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				atomic.StoreInt32(&backendCalled, 1)
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "test", "/", nil)
+			req.Header.Add("X-Foo", "1")
+			req.Header.Add("X-Foo", "2")
+			req.Header.Add("X-Foo", "3")
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestHeaderFieldsTooLarge))
+			Expect(resp.Header.Get("X-Cf-RouterError")).To(Equal("request_headers_too_large"))
+			Expect(atomic.LoadInt32(&backendCalled)).To(BeZero())
+		})
+	})
+
+	Describe("CORS", func() {
+		It("answers preflight requests for routes with a CORS policy without proxying them", func() {
+			var backendCalled int32
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				atomic.StoreInt32(&backendCalled, 1)
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, test_util.RegisterConfig{
+				CORSAllowedOrigins: []string{"https://app.example.com"},
+				CORSAllowedMethods: []string{"PUT"},
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("OPTIONS", "test", "/", nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.com"))
+			Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(Equal("PUT"))
+			Expect(atomic.LoadInt32(&backendCalled)).To(BeZero())
+		})
+
+		It("sets the allowed origin on responses from the backend", func() {
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, test_util.RegisterConfig{
+				CORSAllowedOrigins: []string{"https://app.example.com"},
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "test", "/", nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.com"))
+		})
+	})
+
+	Describe("Host rewrite", func() {
+		It("sends the Host the route registered to the backend", func() {
+			done := make(chan *http.Request, 1)
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				done <- req
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, test_util.RegisterConfig{HostRewrite: "legacy.internal"})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "test", "/", nil))
+
+			var req *http.Request
+			Eventually(done).Should(Receive(&req))
+			Expect(req.Host).To(Equal("legacy.internal"))
+			Expect(req.Header.Get("X-Forwarded-Host")).To(Equal("test"))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("Route matches", func() {
+		It("sends requests to the endpoints whose match they match", func() {
+			v1 := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("X-Backend", "v1")
+				conn.WriteResponse(resp)
+			})
+			defer v1.Close()
+			v2 := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("X-Backend", "v2")
+				conn.WriteResponse(resp)
+			}, test_util.RegisterConfig{MatchHeaders: map[string]string{"X-Api-Version": "2"}})
+			defer v2.Close()
+
+			conn := dialProxy(proxyServer)
+			req := test_util.NewRequest("GET", "test", "/", nil)
+			req.Header.Set("X-Api-Version", "2")
+			conn.WriteRequest(req)
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("X-Backend")).To(Equal("v2"))
+
+			conn = dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("GET", "test", "/", nil))
+			resp, _ = conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("X-Backend")).To(Equal("v1"))
+		})
+	})
+
+	Describe("Endpoint groups", func() {
+		It("sends requests to the group that takes all of them", func() {
+			var stableCalled int32
+			stable := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				atomic.StoreInt32(&stableCalled, 1)
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, test_util.RegisterConfig{Group: "stable"})
+			defer stable.Close()
+			canary := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, test_util.RegisterConfig{Group: "canary", GroupPercent: 100})
+			defer canary.Close()
+
+			for i := 0; i < 5; i++ {
+				conn := dialProxy(proxyServer)
+				conn.WriteRequest(test_util.NewRequest("GET", "test", "/", nil))
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			}
+			Expect(atomic.LoadInt32(&stableCalled)).To(BeZero())
+		})
+	})
+
+	Describe("Traffic mirroring", func() {
+		It("sends a copy of requests to the mirror route and the response of the route to the client", func() {
+			mirrored := make(chan *http.Request, 1)
+			mirror := test_util.RegisterHandler(r, "mirror", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				body, err := ioutil.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(Equal("payload"))
+				mirrored <- req
+				conn.WriteResponse(test_util.NewResponse(http.StatusInternalServerError))
+			})
+			defer mirror.Close()
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				body, err := ioutil.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(Equal("payload"))
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, test_util.RegisterConfig{MirrorHost: "mirror", MirrorPercent: 100})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+			conn.WriteRequest(test_util.NewRequest("POST", "test", "/orders", strings.NewReader("payload")))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var req *http.Request
+			Eventually(mirrored).Should(Receive(&req))
+			Expect(req.Host).To(Equal("mirror"))
+			Expect(req.URL.Path).To(Equal("/orders"))
+			Expect(req.Header.Get("X-Cf-Mirrored-From")).To(Equal("test"))
+		})
+
+		Context("when the request carries credentials", func() {
+			mirrorWithCredentials := func() *http.Request {
+				mirrored := make(chan *http.Request, 1)
+				mirror := test_util.RegisterHandler(r, "mirror", func(conn *test_util.HttpConn) {
+					req, err := http.ReadRequest(conn.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					mirrored <- req
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				})
+				defer mirror.Close()
+				ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+					req, err := http.ReadRequest(conn.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				}, test_util.RegisterConfig{MirrorHost: "mirror", MirrorPercent: 100})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+				req := test_util.NewRequest("GET", "test", "/", nil)
+				req.Header.Set("Authorization", "Bearer token")
+				req.Header.Set("Cookie", "session=secret")
+				conn.WriteRequest(req)
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var copied *http.Request
+				Eventually(mirrored).Should(Receive(&copied))
+				return copied
+			}
+
+			It("removes them from the copy", func() {
+				req := mirrorWithCredentials()
+				Expect(req.Header).ToNot(HaveKey("Authorization"))
+				Expect(req.Header).ToNot(HaveKey("Cookie"))
+				Expect(req.Header).ToNot(HaveKey("X-Forwarded-Client-Cert"))
+			})
+
+			Context("when credentials are forwarded to mirror routes", func() {
+				BeforeEach(func() {
+					conf.TrafficMirroring.ForwardCredentials = true
+				})
+
+				It("keeps them in the copy", func() {
+					req := mirrorWithCredentials()
+					Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+					Expect(req.Header.Get("Cookie")).To(Equal("session=secret"))
+				})
+			})
+		})
+	})
+
+	Describe("Path rewrite", func() {
+		It("strips the context path of the route from requests to the backend", func() {
+			done := make(chan *http.Request, 1)
+			ln := test_util.RegisterHandler(r, "test/team-a", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				done <- req
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, test_util.RegisterConfig{StripPathPrefix: true})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "test", "/team-a/status?verbose=1", nil))
+
+			var req *http.Request
+			Eventually(done).Should(Receive(&req))
+			Expect(req.RequestURI).To(Equal("/status?verbose=1"))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("replaces the context path of the route in requests to the backend", func() {
+			done := make(chan *http.Request, 1)
+			ln := test_util.RegisterHandler(r, "test/team-a", func(conn *test_util.HttpConn) {
+				req, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				done <- req
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			}, test_util.RegisterConfig{RewritePath: "/api/v2"})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "test", "/team-a/status", nil))
+
+			var req *http.Request
+			Eventually(done).Should(Receive(&req))
+			Expect(req.RequestURI).To(Equal("/api/v2/status"))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("Security headers", func() {
+		BeforeEach(func() {
+			conf.SecurityHeaders = []config.SecurityHeadersConfig{{
+				Domains:            []string{"*.example.com"},
+				HSTS:               config.HSTSConfig{MaxAge: time.Hour},
+				ContentTypeOptions: true,
+			}}
+		})
+
+		It("sets them on responses from backends", func() {
+			ln := test_util.RegisterHandler(r, "app.example.com", func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "app.example.com", "/", nil))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Strict-Transport-Security")).To(Equal("max-age=3600"))
+			Expect(resp.Header.Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		})
+
+		It("sets them on responses from the router", func() {
+			conn := dialProxy(proxyServer)
+
+			conn.WriteRequest(test_util.NewRequest("GET", "unknown.example.com", "/", nil))
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(resp.Header.Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		})
+	})
+
 	Describe("URL Handling", func() {
 		It("responds transparently to a trailing slash versus no trailing slash", func() {
 			lnWithoutSlash := test_util.RegisterHandler(r, "test/my%20path/your_path", func(conn *test_util.HttpConn) {
@@ -329,6 +969,71 @@ var _ = Describe("Proxy", func() {
 					Expect(getProxiedHeaders(req).Get("X-Forwarded-For")).To(Equal("1.2.3.4, 127.0.0.1"))
 				})
 			})
+
+			Context("when the client is not a trusted proxy", func() {
+				BeforeEach(func() {
+					_, trustedNet, err := net.ParseCIDR("10.0.0.0/8")
+					Expect(err).ToNot(HaveOccurred())
+					conf.TrustedProxyNets = []*net.IPNet{trustedNet}
+				})
+
+				It("replaces the header with the client IP", func() {
+					req.Header.Add("X-Forwarded-For", "1.2.3.4")
+					Expect(getProxiedHeaders(req).Get("X-Forwarded-For")).To(Equal("127.0.0.1"))
+				})
+			})
+		})
+
+		Describe("header rules", func() {
+			BeforeEach(func() {
+				conf.HeaderRules = []config.HeaderRule{{
+					Domains: []string{"app"},
+					Request: config.HeaderRuleActions{
+						Remove: []string{"X-Internal-Token"},
+						Add:    []config.HeaderNameValue{{Name: "X-Platform", Value: "cf"}},
+					},
+				}}
+			})
+
+			It("applies them to the request sent to the backend", func() {
+				req.Header.Set("X-Internal-Token", "secret")
+				headers := getProxiedHeaders(req)
+				Expect(headers).ToNot(HaveKey("X-Internal-Token"))
+				Expect(headers.Get("X-Platform")).To(Equal("cf"))
+			})
+		})
+
+		Describe("Forwarded", func() {
+			It("does not set Forwarded by default", func() {
+				Expect(getProxiedHeaders(req).Get("Forwarded")).To(BeEmpty())
+			})
+
+			Context("when Forwarded is emitted", func() {
+				BeforeEach(func() {
+					conf.EmitForwardedHeaders = []string{config.ForwardedHeaderForwarded, config.ForwardedHeaderXForwarded}
+				})
+
+				It("appends an element for the client", func() {
+					req.Header.Add("Forwarded", "for=1.2.3.4")
+					headers := getProxiedHeaders(req)
+					Expect(headers.Get("Forwarded")).To(Equal("for=1.2.3.4, for=127.0.0.1;host=app;proto=http"))
+					Expect(headers.Get("X-Forwarded-For")).To(Equal("127.0.0.1"))
+				})
+			})
+
+			Context("when only Forwarded is emitted", func() {
+				BeforeEach(func() {
+					conf.EmitForwardedHeaders = []string{config.ForwardedHeaderForwarded}
+				})
+
+				It("does not send the X-Forwarded headers", func() {
+					req.Header.Add("X-Forwarded-For", "1.2.3.4")
+					headers := getProxiedHeaders(req)
+					Expect(headers.Get("Forwarded")).To(Equal("for=127.0.0.1;host=app;proto=http"))
+					Expect(headers).ToNot(HaveKey("X-Forwarded-For"))
+					Expect(headers).ToNot(HaveKey("X-Forwarded-Proto"))
+				})
+			})
 		})
 
 		Describe("X-Request-Start", func() {
@@ -418,6 +1123,27 @@ var _ = Describe("Proxy", func() {
 					req.Header.Add("X-Forwarded-Client-Cert", "bar")
 					Expect(getProxiedHeaders(req)).To(HaveKeyWithValue("X-Forwarded-Client-Cert", []string{"foo", "bar"}))
 				})
+
+				Context("when the route registered sanitize_set", func() {
+					BeforeEach(func() {
+						extraRegisterCfg = []test_util.RegisterConfig{{ForwardedClientCert: config.SANITIZE_SET}}
+					})
+					It("removes xfcc header", func() {
+						req.Header.Add("X-Forwarded-Client-Cert", "foo")
+						Expect(getProxiedHeaders(req).Get("X-Forwarded-Client-Cert")).To(BeEmpty())
+					})
+				})
+			})
+
+			Context("when the route registered always_forward", func() {
+				BeforeEach(func() {
+					conf.ForwardedClientCert = config.SANITIZE_SET
+					extraRegisterCfg = []test_util.RegisterConfig{{ForwardedClientCert: config.ALWAYS_FORWARD}}
+				})
+				It("leaves the xfcc header intact", func() {
+					req.Header.Add("X-Forwarded-Client-Cert", "foo")
+					Expect(getProxiedHeaders(req)).To(HaveKeyWithValue("X-Forwarded-Client-Cert", []string{"foo"}))
+				})
 			})
 		})
 	})
@@ -513,6 +1239,35 @@ var _ = Describe("Proxy", func() {
 			Expect(resp.Header.Get(handlers.VcapRequestIdHeader)).To(Equal("foobar"))
 		})
 
+		Context("when rewriting redirect locations is enabled", func() {
+			BeforeEach(func() {
+				conf.RewriteRedirectLocation = true
+			})
+
+			It("rewrites a redirect to the internal backend address to the public URI", func() {
+				ln := test_util.RegisterHandler(r, "redirect-test", func(conn *test_util.HttpConn) {
+					_, err := http.ReadRequest(conn.Reader)
+					Expect(err).NotTo(HaveOccurred())
+
+					resp := test_util.NewResponse(http.StatusFound)
+					resp.Header.Set("Location", "http://"+conn.LocalAddr().String()+"/login")
+					conn.WriteResponse(resp)
+					conn.Close()
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				req := test_util.NewRequest("GET", "redirect-test", "/", nil)
+				req.Header.Set("X-Forwarded-Proto", "https")
+				conn.WriteRequest(req)
+
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusFound))
+				Expect(resp.Header.Get("Location")).To(Equal("https://redirect-test/login"))
+			})
+		})
+
 		It("Status No Content returns no Transfer Encoding response header", func() {
 			ln := test_util.RegisterHandler(r, "not-modified", func(conn *test_util.HttpConn) {
 				_, err := http.ReadRequest(conn.Reader)
@@ -589,6 +1344,81 @@ var _ = Describe("Proxy", func() {
 			}
 		})
 
+		Context("when the response is a stream", func() {
+			var contentType string
+
+			streamHandler := func(conn *test_util.HttpConn) {
+				_, err := http.ReadRequest(conn.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				r, w := io.Pipe()
+				go func() {
+					defer w.Close()
+					for i := 0; i < 3; i++ {
+						time.Sleep(100 * time.Millisecond)
+						if _, err := w.Write([]byte("data: hello\n\n")); err != nil {
+							return
+						}
+					}
+				}()
+
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("Content-Type", contentType)
+				resp.TransferEncoding = []string{"chunked"}
+				resp.Body = r
+				resp.Write(conn)
+				r.Close()
+				conn.Close()
+			}
+
+			readStream := func(host string) string {
+				conn := dialProxy(proxyServer)
+				req := test_util.NewRequest("GET", host, "/", nil)
+				req.Header.Set("Accept", "text/event-stream")
+				Expect(req.Write(conn)).To(Succeed())
+
+				resp, err := http.ReadResponse(conn.Reader, &http.Request{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				body, _ := ioutil.ReadAll(resp.Body)
+				return string(body)
+			}
+
+			BeforeEach(func() {
+				conf.EndpointTimeout = 150 * time.Millisecond
+				contentType = "text/event-stream"
+			})
+
+			It("streams Server-Sent Events for longer than the endpoint timeout", func() {
+				ln := test_util.RegisterHandler(r, "events", streamHandler)
+				defer ln.Close()
+
+				Expect(readStream("events")).To(Equal(strings.Repeat("data: hello\n\n", 3)))
+			})
+
+			Context("when the response is not an event stream", func() {
+				BeforeEach(func() {
+					contentType = "text/plain"
+				})
+
+				It("cuts the response off at the endpoint timeout", func() {
+					ln := test_util.RegisterHandler(r, "plain", streamHandler)
+					defer ln.Close()
+
+					Expect(readStream("plain")).NotTo(Equal(strings.Repeat("data: hello\n\n", 3)))
+				})
+
+				It("streams the responses of routes tagged as streaming", func() {
+					ln := test_util.RegisterHandler(r, "tagged", streamHandler, test_util.RegisterConfig{
+						Tags: map[string]string{handlers.StreamingTag: "true"},
+					})
+					defer ln.Close()
+
+					Expect(readStream("tagged")).To(Equal(strings.Repeat("data: hello\n\n", 3)))
+				})
+			})
+		})
+
 		It("disables compression", func() {
 			ln := test_util.RegisterHandler(r, "remote", func(conn *test_util.HttpConn) {
 				request, _ := http.ReadRequest(conn.Reader)
@@ -778,6 +1608,94 @@ var _ = Describe("Proxy", func() {
 			})
 		})
 
+		Context("when backend keep-alives are enabled", func() {
+			var connections int32
+
+			registerKeepAliveHandler := func() net.Listener {
+				connections = 0
+				return test_util.RegisterHandler(r, "keep-alive", func(x *test_util.HttpConn) {
+					atomic.AddInt32(&connections, 1)
+					defer x.Close()
+					for {
+						req, err := http.ReadRequest(x.Reader)
+						if err != nil {
+							return
+						}
+						resp := test_util.NewResponse(http.StatusOK)
+						resp.Close = req.Close
+						x.WriteResponse(resp)
+						if req.Close {
+							return
+						}
+					}
+				})
+			}
+
+			sendRequests := func(n int) {
+				for i := 0; i < n; i++ {
+					x := dialProxy(proxyServer)
+					req := test_util.NewRequest("GET", "keep-alive", "/", nil)
+					x.WriteRequest(req)
+					resp, _ := x.ReadResponse()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+					x.Close()
+				}
+			}
+
+			BeforeEach(func() {
+				conf.DisableKeepAlives = false
+			})
+
+			It("reuses the backend connection", func() {
+				ln := registerKeepAliveHandler()
+				defer ln.Close()
+
+				sendRequests(4)
+
+				Expect(atomic.LoadInt32(&connections)).To(Equal(int32(1)))
+				Expect(fakeReporter.CaptureBackendConnectionOpenedCallCount()).To(Equal(1))
+				Expect(fakeReporter.CaptureBackendConnectionReusedCallCount()).To(Equal(3))
+				Expect(fakeReporter.CaptureBackendDialTimeCallCount()).To(Equal(1))
+			})
+
+			It("reports the idle backend connections", func() {
+				ln := registerKeepAliveHandler()
+				defer ln.Close()
+
+				lastIdleConnections := func() []int {
+					n := fakeReporter.CaptureBackendIdleConnectionsCallCount()
+					if n == 0 {
+						return nil
+					}
+					total, max := fakeReporter.CaptureBackendIdleConnectionsArgsForCall(n - 1)
+					return []int{total, max}
+				}
+
+				sendRequests(1)
+				Eventually(lastIdleConnections).Should(Equal([]int{1, 1}))
+
+				sendRequests(1)
+				Eventually(lastIdleConnections).Should(Equal([]int{1, 1}))
+				Expect(fakeReporter.CaptureBackendIdleConnectionsCallCount()).To(Equal(3))
+			})
+
+			Context("when max requests per backend connection is set", func() {
+				BeforeEach(func() {
+					conf.Backends.MaxRequestsPerConn = 2
+				})
+
+				It("closes the backend connection after the configured number of requests", func() {
+					ln := registerKeepAliveHandler()
+					defer ln.Close()
+
+					sendRequests(5)
+
+					Expect(atomic.LoadInt32(&connections)).To(Equal(int32(3)))
+					Expect(fakeReporter.CaptureBackendConnectionOpenedCallCount()).To(Equal(3))
+				})
+			})
+		})
+
 		It("request terminates with slow response", func() {
 			ln := test_util.RegisterHandler(r, "slow-app", func(conn *test_util.HttpConn) {
 				_, err := http.ReadRequest(conn.Reader)
@@ -803,6 +1721,141 @@ var _ = Describe("Proxy", func() {
 			Expect(time.Since(started)).To(BeNumerically("<", time.Duration(2*time.Second)))
 		})
 
+		Context("when backends are sent the PROXY protocol", func() {
+			var proxyHeaders chan string
+
+			registerProxyProtocolHandler := func(host string, cfg test_util.RegisterConfig) net.Listener {
+				return test_util.RegisterHandler(r, host, func(conn *test_util.HttpConn) {
+					defer conn.Close()
+					line, err := conn.Reader.Peek(6)
+					if err == nil && string(line) == "PROXY " {
+						header, _ := conn.Reader.ReadString('\n')
+						proxyHeaders <- header
+					} else {
+						proxyHeaders <- ""
+					}
+
+					if host == "tcp-handler" {
+						conn.WriteLine("HTTP/1.1 101 Switching Protocols\r\n\r\nhello")
+						return
+					}
+					conn.ReadRequest()
+					conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+				}, cfg)
+			}
+
+			BeforeEach(func() {
+				proxyHeaders = make(chan string, 1)
+				conf.Backends.ProxyProtocol = "v1"
+			})
+
+			It("prepends a header with the client address to backend connections", func() {
+				ln := registerProxyProtocolHandler("proxy-protocol", test_util.RegisterConfig{})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+				conn.WriteRequest(test_util.NewRequest("GET", "proxy-protocol", "/", nil))
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				clientHost, clientPort, _ := net.SplitHostPort(conn.LocalAddr().String())
+				proxyHost, proxyPort, _ := net.SplitHostPort(conn.RemoteAddr().String())
+				Expect(proxyHeaders).To(Receive(Equal(fmt.Sprintf("PROXY TCP4 %s %s %s %s\r\n", clientHost, proxyHost, clientPort, proxyPort))))
+			})
+
+			It("prepends a header to backend connections of TCP upgrades", func() {
+				ln := registerProxyProtocolHandler("tcp-handler", test_util.RegisterConfig{})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+				req := test_util.NewRequest("GET", "tcp-handler", "/chat", nil)
+				req.Header.Set("Upgrade", "tcp")
+				req.Header.Set("Connection", "Upgrade")
+				conn.WriteRequest(req)
+
+				conn.CheckLine("HTTP/1.1 101 Switching Protocols")
+
+				clientHost, _, _ := net.SplitHostPort(conn.LocalAddr().String())
+				Expect(proxyHeaders).To(Receive(HavePrefix(fmt.Sprintf("PROXY TCP4 %s ", clientHost))))
+			})
+
+			It("sends the version the route registered", func() {
+				ln := registerProxyProtocolHandler("proxy-protocol", test_util.RegisterConfig{ProxyProtocol: route.ProxyProtocolNone})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+				conn.WriteRequest(test_util.NewRequest("GET", "proxy-protocol", "/", nil))
+				resp, _ := conn.ReadResponse()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				Expect(proxyHeaders).To(Receive(BeEmpty()))
+			})
+		})
+
+		Context("when a backend response header timeout is configured", func() {
+			BeforeEach(func() {
+				conf.Backends.ResponseHeaderTimeout = 100 * time.Millisecond
+			})
+
+			It("responds with a 504 promptly when the backend stalls before sending headers", func() {
+				stall := make(chan struct{})
+				defer close(stall)
+				ln := test_util.RegisterHandler(r, "stalled-app", func(conn *test_util.HttpConn) {
+					_, err := http.ReadRequest(conn.Reader)
+					Expect(err).NotTo(HaveOccurred())
+
+					<-stall
+					conn.Close()
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				req := test_util.NewRequest("GET", "stalled-app", "/", nil)
+
+				started := time.Now()
+				conn.WriteRequest(req)
+
+				resp, body := readResponse(conn)
+
+				Expect(resp.StatusCode).To(Equal(http.StatusGatewayTimeout))
+				Expect(body).To(ContainSubstring("504 Gateway Timeout"))
+				Expect(time.Since(started)).To(BeNumerically("<", 500*time.Millisecond))
+			})
+
+			It("does not cut off a body that streams for longer than the timeout", func() {
+				ln := test_util.RegisterHandler(r, "streaming-app", func(conn *test_util.HttpConn) {
+					_, err := http.ReadRequest(conn.Reader)
+					Expect(err).NotTo(HaveOccurred())
+
+					timesToTick := 5
+					conn.WriteLines([]string{
+						"HTTP/1.1 200 OK",
+						fmt.Sprintf("Content-Length: %d", timesToTick),
+					})
+
+					for i := 0; i < timesToTick; i++ {
+						_, err := conn.Conn.Write([]byte("x"))
+						Expect(err).NotTo(HaveOccurred())
+
+						time.Sleep(100 * time.Millisecond)
+					}
+					conn.Close()
+				})
+				defer ln.Close()
+
+				conn := dialProxy(proxyServer)
+
+				req := test_util.NewRequest("GET", "streaming-app", "/", nil)
+				conn.WriteRequest(req)
+
+				resp, body := readResponse(conn)
+
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(body).To(Equal("xxxxx"))
+			})
+		})
+
 		It("proxy closes connections with slow apps", func() {
 			serverResult := make(chan error)
 			ln := test_util.RegisterHandler(r, "slow-app", func(conn *test_util.HttpConn) {
@@ -1022,6 +2075,61 @@ var _ = Describe("Proxy", func() {
 		})
 	})
 
+	Describe("Tracing", func() {
+		var (
+			exporter *tracefakes.FakeExporter
+			process  ifrit.Process
+		)
+
+		BeforeEach(func() {
+			exporter = new(tracefakes.FakeExporter)
+			tracer = tracing.NewTracer(exporter, testLogger)
+			process = ifrit.Invoke(tracer)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		})
+
+		It("continues the trace of the client to the backend", func() {
+			traceparents := make(chan string, 1)
+			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
+				req, _ := conn.ReadRequest()
+				traceparents <- req.Header.Get("traceparent")
+				conn.WriteResponse(test_util.NewResponse(http.StatusOK))
+			})
+			defer ln.Close()
+
+			conn := dialProxy(proxyServer)
+
+			req := test_util.NewRequest("GET", "test", "/", nil)
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var traceparent string
+			Eventually(traceparents).Should(Receive(&traceparent))
+
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+			Expect(exporter.ExportCallCount()).To(Equal(1))
+
+			spans := exporter.ExportArgsForCall(0)
+			Expect(spans).To(HaveLen(2))
+
+			backend, server := spans[0], spans[1]
+			Expect(server.Name()).To(Equal("HTTP GET"))
+			Expect(server.Context().TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(server.ParentSpanID()).To(Equal("00f067aa0ba902b7"))
+			Expect(backend.Name()).To(Equal("backend"))
+			Expect(backend.ParentSpanID()).To(Equal(server.Context().SpanID))
+			Expect(traceparent).To(Equal(backend.Context().TraceParent()))
+		})
+	})
+
 	Describe("Access Logging", func() {
 		It("Logs a request", func() {
 			ln := test_util.RegisterHandler(r, "test", func(conn *test_util.HttpConn) {
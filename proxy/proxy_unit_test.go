@@ -69,7 +69,7 @@ var _ = Describe("Proxy Unit tests", func() {
 
 			skipSanitization = func(req *http.Request) bool { return false }
 			proxyObj = proxy.NewProxy(logger, fakeAccessLogger, conf, r, combinedReporter,
-				routeServiceConfig, tlsConfig, nil, rt, skipSanitization)
+				routeServiceConfig, tlsConfig, nil, nil, rt, skipSanitization, nil, nil)
 
 			r.Register(route.Uri("some-app"), &route.Endpoint{Stats: route.NewStats()})
 
@@ -123,7 +123,7 @@ var _ = Describe("Proxy Unit tests", func() {
 			var healthCheck int32
 			BeforeEach(func() {
 				healthCheck = 1
-				proxyObj = proxy.NewProxy(logger, fakeAccessLogger, conf, nil, combinedReporter, routeServiceConfig, tlsConfig, &healthCheck, rt, skipSanitization)
+				proxyObj = proxy.NewProxy(logger, fakeAccessLogger, conf, nil, combinedReporter, routeServiceConfig, tlsConfig, nil, &healthCheck, rt, skipSanitization, nil, nil)
 			})
 
 			It("fails the healthcheck", func() {
@@ -0,0 +1,130 @@
+package round_tripper
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+// IdleConnections counts the idle connections that the transports keep to
+// each backend, and reports the total and the most kept to one backend as
+// they change. A connection that is taken for a request just as it is
+// returned to the idle pool may be counted as idle until it is next used or
+// closed.
+type IdleConnections struct {
+	reporter metrics.ProxyReporter
+
+	lock      sync.Mutex
+	byBackend map[string]int
+	// backendsWith counts the backends with each number of idle connections
+	// above zero, so that the most kept to one backend is known without
+	// going through them all.
+	backendsWith map[int]int
+	total        int
+	max          int
+}
+
+func NewIdleConnections(reporter metrics.ProxyReporter) *IdleConnections {
+	return &IdleConnections{
+		reporter:     reporter,
+		byBackend:    map[string]int{},
+		backendsWith: map[int]int{},
+	}
+}
+
+// add adds delta, 1 or -1, to the idle connections to the backend at addr.
+func (i *IdleConnections) add(addr string, delta int) {
+	if i == nil {
+		return
+	}
+
+	i.lock.Lock()
+	from := i.byBackend[addr]
+	to := from + delta
+	if to < 0 {
+		i.lock.Unlock()
+		return
+	}
+
+	if from > 0 {
+		i.backendsWith[from]--
+		if i.backendsWith[from] == 0 {
+			delete(i.backendsWith, from)
+		}
+	}
+	if to > 0 {
+		i.byBackend[addr] = to
+		i.backendsWith[to]++
+	} else {
+		delete(i.byBackend, addr)
+	}
+
+	i.total += delta
+	if to > i.max {
+		i.max = to
+	} else if from == i.max && i.backendsWith[from] == 0 {
+		i.max = to
+	}
+	total, max := i.total, i.max
+	i.lock.Unlock()
+
+	i.reporter.CaptureBackendIdleConnections(total, max)
+}
+
+// newConnectionMetricsRoundTripper returns a round tripper that reports
+// whether requests reuse a backend connection and how long TLS handshakes
+// with backends take, and marks connections idle when the transport keeps
+// them for later requests.
+func newConnectionMetricsRoundTripper(p ProxyRoundTripper, reporter metrics.ProxyReporter) ProxyRoundTripper {
+	return &connectionMetricsRoundTripper{
+		p:        p,
+		reporter: reporter,
+	}
+}
+
+type connectionMetricsRoundTripper struct {
+	p        ProxyRoundTripper
+	reporter metrics.ProxyReporter
+}
+
+func (c *connectionMetricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		conn           *countedConn
+		handshakeStart time.Time
+	)
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				c.reporter.CaptureBackendConnectionReused()
+			}
+			conn = countedConnFrom(info.Conn)
+			if conn != nil {
+				conn.setIdle(false)
+			}
+		},
+		PutIdleConn: func(err error) {
+			if err == nil && conn != nil {
+				conn.setIdle(true)
+			}
+		},
+		TLSHandshakeStart: func() {
+			handshakeStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				c.reporter.CaptureBackendTLSHandshakeTime(time.Since(handshakeStart))
+			}
+		},
+	}
+
+	return c.p.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+func (c *connectionMetricsRoundTripper) CancelRequest(req *http.Request) {
+	c.p.CancelRequest(req)
+}
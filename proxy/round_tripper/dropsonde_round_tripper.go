@@ -1,10 +1,15 @@
 package round_tripper
 
 import (
+	"crypto/tls"
+	"net"
 	"net/http"
 
+	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/route"
 	"github.com/cloudfoundry/dropsonde"
+	"golang.org/x/net/http2"
 )
 
 func NewDropsondeRoundTripper(p ProxyRoundTripper) ProxyRoundTripper {
@@ -28,20 +33,98 @@ func (d *dropsondeRoundTripper) CancelRequest(r *http.Request) {
 }
 
 type FactoryImpl struct {
-	Template *http.Transport
+	Template           *http.Transport
+	ClientCertificates *utils.ClientCertificates
+	MaxRequestsPerConn int
+	Reporter           metrics.ProxyReporter
+
+	// ProxyProtocol is the version of the PROXY protocol header sent to
+	// backends that do not register one.
+	ProxyProtocol string
+
+	// IdleConnections counts the idle connections of the round trippers, if
+	// set.
+	IdleConnections *IdleConnections
 }
 
-func (t *FactoryImpl) New(expectedServerName string) ProxyRoundTripper {
-	customTLSConfig := utils.TLSConfigWithServerName(expectedServerName, t.Template.TLSClientConfig)
+func (t *FactoryImpl) New(expectedServerName string, clientCertName string, useHTTP2 bool, proxyProtocol string) ProxyRoundTripper {
+	clientTLSConfig := t.ClientCertificates.TLSConfig(clientCertName, t.Template.TLSClientConfig)
+	customTLSConfig := utils.TLSConfigWithServerName(expectedServerName, clientTLSConfig)
 
+	dial := countingDial(t.Template.Dial, t.Reporter, t.IdleConnections)
 	newTransport := &http.Transport{
-		Dial:                t.Template.Dial,
+		Dial:                dial,
 		DisableKeepAlives:   t.Template.DisableKeepAlives,
 		MaxIdleConns:        t.Template.MaxIdleConns,
 		IdleConnTimeout:     t.Template.IdleConnTimeout,
 		MaxIdleConnsPerHost: t.Template.MaxIdleConnsPerHost,
 		DisableCompression:  t.Template.DisableCompression,
 		TLSClientConfig:     customTLSConfig,
+
+		ResponseHeaderTimeout: t.Template.ResponseHeaderTimeout,
+	}
+
+	if useHTTP2 {
+		return t.instrument(t.newHTTP2RoundTripper(newTransport, dial))
+	}
+
+	proxyProtocol = route.ProxyProtocolVersion(proxyProtocol, t.ProxyProtocol)
+	if proxyProtocol != "" {
+		return t.instrument(newProxyProtocolRoundTripper(newTransport, dial, proxyProtocol))
+	}
+
+	var p ProxyRoundTripper = newTransport
+	if t.MaxRequestsPerConn > 0 {
+		p = NewRecyclingRoundTripper(newTransport, t.MaxRequestsPerConn)
+	}
+	return t.instrument(p)
+}
+
+// instrument wraps p in the round trippers that report on its requests and
+// its connections.
+func (t *FactoryImpl) instrument(p ProxyRoundTripper) ProxyRoundTripper {
+	if t.Reporter != nil {
+		p = newConnectionMetricsRoundTripper(p, t.Reporter)
 	}
-	return NewDropsondeRoundTripper(newTransport)
+	return NewDropsondeRoundTripper(p)
+}
+
+// newHTTP2RoundTripper negotiates HTTP/2 with TLS backends, falling back to
+// HTTP/1.1 when the backend does not agree to it, and speaks HTTP/2 with prior
+// knowledge (h2c) to cleartext backends. Requests are multiplexed on a single
+// connection, so MaxRequestsPerConn does not apply.
+func (t *FactoryImpl) newHTTP2RoundTripper(tlsTransport *http.Transport, dial dialFunc) ProxyRoundTripper {
+	// ConfigureTransport only fails for transports already configured for
+	// HTTP/2, which a new transport is not
+	_ = http2.ConfigureTransport(tlsTransport)
+
+	return &http2RoundTripper{
+		tls: tlsTransport,
+		cleartext: &http2.Transport{
+			AllowHTTP:          true,
+			DisableCompression: t.Template.DisableCompression,
+			IdleConnTimeout:    t.Template.IdleConnTimeout,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(network, addr)
+			},
+		},
+	}
+}
+
+type http2RoundTripper struct {
+	tls       *http.Transport
+	cleartext *http2.Transport
+}
+
+func (h *http2RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "https" {
+		return h.tls.RoundTrip(req)
+	}
+	return h.cleartext.RoundTrip(req)
+}
+
+// CancelRequest only cancels requests to TLS backends that fell back to
+// HTTP/1.1. HTTP/2 requests are cancelled through their context.
+func (h *http2RoundTripper) CancelRequest(req *http.Request) {
+	h.tls.CancelRequest(req)
 }
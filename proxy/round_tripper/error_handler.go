@@ -1,19 +1,37 @@
 package round_tripper
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/metrics"
 	"code.cloudfoundry.org/gorouter/proxy/fails"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 )
 
+// gRPC status codes, as defined by
+// https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	GRPCStatusCancelled         = 1
+	GRPCStatusDeadlineExceeded  = 4
+	GRPCStatusResourceExhausted = 8
+	GRPCStatusUnimplemented     = 12
+	GRPCStatusUnavailable       = 14
+)
+
+// ErrorSpec describes the response to an error classified by Classifier.
+// GRPCStatus is the status returned instead of Code to gRPC requests.
 type ErrorSpec struct {
 	Classifier  fails.Classifier
 	Message     string
 	Code        int
 	HandleError func(reporter metrics.ProxyReporter)
+	GRPCStatus  int
 }
 
 func handleHostnameMismatch(reporter metrics.ProxyReporter) {
@@ -28,13 +46,39 @@ func handleUntrustedCert(reporter metrics.ProxyReporter) {
 	reporter.CaptureBackendInvalidTLSCert()
 }
 
+func handleBadGateway(reporter metrics.ProxyReporter) {
+	reporter.CaptureBadGateway()
+}
+
+var http2Required = fails.ClassifierFunc(func(err error) bool {
+	return err == ErrHTTP2Required
+})
+
+// requestBodyTooLarge matches the error of a request body that went over the
+// limit of its route, which the transport may wrap.
+var requestBodyTooLarge = fails.ClassifierFunc(func(err error) bool {
+	return errors.Is(err, handlers.ErrRequestBodyTooLarge)
+})
+
 var DefaultErrorSpecs = []ErrorSpec{
-	{fails.AttemptedTLSWithNonTLSBackend, SSLHandshakeMessage, 525, handleSSLHandshake},
-	{fails.HostnameMismatch, HostnameErrorMessage, http.StatusServiceUnavailable, handleHostnameMismatch},
-	{fails.UntrustedCert, InvalidCertificateMessage, 526, handleUntrustedCert},
-	{fails.RemoteFailedCertCheck, SSLCertRequiredMessage, 496, nil},
-	{fails.ContextCancelled, ContextCancelledMessage, 499, nil},
-	{fails.RemoteHandshakeFailure, SSLHandshakeMessage, 525, handleSSLHandshake},
+	{fails.AttemptedTLSWithNonTLSBackend, SSLHandshakeMessage, 525, handleSSLHandshake, GRPCStatusUnavailable},
+	{fails.HostnameMismatch, HostnameErrorMessage, http.StatusServiceUnavailable, handleHostnameMismatch, GRPCStatusUnavailable},
+	{fails.UntrustedCert, InvalidCertificateMessage, 526, handleUntrustedCert, GRPCStatusUnavailable},
+	{fails.RemoteFailedCertCheck, SSLCertRequiredMessage, 496, nil, GRPCStatusUnavailable},
+	{fails.ContextCancelled, ContextCancelledMessage, 499, nil, GRPCStatusCancelled},
+	{fails.RemoteHandshakeFailure, SSLHandshakeMessage, 525, handleSSLHandshake, GRPCStatusUnavailable},
+	{fails.ResponseHeaderTimeout, GatewayTimeoutMessage, http.StatusGatewayTimeout, nil, GRPCStatusDeadlineExceeded},
+	{http2Required, HTTP2RequiredMessage, http.StatusBadGateway, nil, GRPCStatusUnimplemented},
+	{requestBodyTooLarge, RequestTooLargeMessage, http.StatusRequestEntityTooLarge, nil, GRPCStatusResourceExhausted},
+}
+
+// badGatewaySpec applies to errors that match none of the ErrorSpecs, such as
+// failures to dial the endpoint.
+var badGatewaySpec = ErrorSpec{
+	Message:     BadGatewayMessage,
+	Code:        http.StatusBadGateway,
+	HandleError: handleBadGateway,
+	GRPCStatus:  GRPCStatusUnavailable,
 }
 
 type ErrorHandler struct {
@@ -42,26 +86,53 @@ type ErrorHandler struct {
 	ErrorSpecs     []ErrorSpec
 }
 
-func (eh *ErrorHandler) HandleError(responseWriter utils.ProxyResponseWriter, err error) {
+func (eh *ErrorHandler) HandleError(responseWriter utils.ProxyResponseWriter, request *http.Request, err error) {
 	responseWriter.Header().Set(router_http.CfRouterError, "endpoint_failure")
 
-	eh.writeErrorCode(err, responseWriter)
+	spec := eh.errorSpec(err)
+	if spec.HandleError != nil {
+		spec.HandleError(eh.MetricReporter)
+	}
+
+	if handlers.IsGRPCRequest(request) {
+		writeGRPCStatus(responseWriter, spec.GRPCStatus, spec.Message)
+	} else {
+		http.Error(responseWriter, spec.Message, spec.Code)
+	}
 	responseWriter.Header().Del("Connection")
 	responseWriter.Done()
 }
 
-func (eh *ErrorHandler) writeErrorCode(err error, responseWriter http.ResponseWriter) {
+func (eh *ErrorHandler) errorSpec(err error) ErrorSpec {
 	for _, spec := range eh.ErrorSpecs {
 		if spec.Classifier.Classify(err) {
-			if spec.HandleError != nil {
-				spec.HandleError(eh.MetricReporter)
-			}
-			http.Error(responseWriter, spec.Message, spec.Code)
-			return
+			return spec
 		}
 	}
+	return badGatewaySpec
+}
 
-	// default case
-	http.Error(responseWriter, BadGatewayMessage, http.StatusBadGateway)
-	eh.MetricReporter.CaptureBadGateway()
+// writeGRPCStatus writes a Trailers-Only response, which gRPC clients read as
+// the status of the call, where an HTML error body would be reported as a
+// malformed response.
+func writeGRPCStatus(responseWriter http.ResponseWriter, status int, message string) {
+	responseWriter.Header().Set("Content-Type", "application/grpc")
+	responseWriter.Header().Set("Grpc-Status", strconv.Itoa(status))
+	responseWriter.Header().Set("Grpc-Message", encodeGRPCMessage(message))
+	responseWriter.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage percent-encodes the bytes that gRPC does not allow in the
+// grpc-message header.
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
 }
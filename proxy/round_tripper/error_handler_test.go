@@ -2,10 +2,13 @@ package round_tripper_test
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"net/http"
 	"net/http/httptest"
 
 	router_http "code.cloudfoundry.org/gorouter/common/http"
+	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/metrics/fakes"
 	"code.cloudfoundry.org/gorouter/proxy/round_tripper"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
@@ -28,6 +31,7 @@ var _ = Describe("HandleError", func() {
 		errorHandler     *round_tripper.ErrorHandler
 		responseWriter   utils.ProxyResponseWriter
 		responseRecorder *httptest.ResponseRecorder
+		request          *http.Request
 		errorHandled     bool
 	)
 
@@ -58,43 +62,44 @@ var _ = Describe("HandleError", func() {
 		}
 		responseRecorder = httptest.NewRecorder()
 		responseWriter = utils.NewProxyResponseWriter(responseRecorder)
+		request = httptest.NewRequest("GET", "http://example.com/", nil)
 	})
 
 	It("Sets a header to describe the endpoint_failure", func() {
-		errorHandler.HandleError(responseWriter, errors.New("potato"))
+		errorHandler.HandleError(responseWriter, request, errors.New("potato"))
 		Expect(responseWriter.Header().Get(router_http.CfRouterError)).To(Equal("endpoint_failure"))
 	})
 
 	Context("when the error does not match any of the classifiers", func() {
 		It("sets the http response code to 502", func() {
-			errorHandler.HandleError(responseWriter, errors.New("potato"))
+			errorHandler.HandleError(responseWriter, request, errors.New("potato"))
 			Expect(responseWriter.Status()).To(Equal(502))
 		})
 
 		It("emits a BadGateway metric", func() {
-			errorHandler.HandleError(responseWriter, errors.New("potato"))
+			errorHandler.HandleError(responseWriter, request, errors.New("potato"))
 			Expect(metricReporter.CaptureBadGatewayCallCount()).To(Equal(1))
 		})
 	})
 
 	Context("when the error does match one of the classifiers", func() {
 		It("sets the http response code and message appropriately", func() {
-			errorHandler.HandleError(responseWriter, errors.New("i'm a tomato"))
+			errorHandler.HandleError(responseWriter, request, errors.New("i'm a tomato"))
 			Expect(responseWriter.Status()).To(Equal(419))
 			Expect(responseRecorder.Body.String()).To(Equal("you say tomato\n"))
 		})
 
 		It("does not emit a metric", func() {
-			errorHandler.HandleError(responseWriter, errors.New("i'm a tomato"))
+			errorHandler.HandleError(responseWriter, request, errors.New("i'm a tomato"))
 			Expect(metricReporter.CaptureBadGatewayCallCount()).To(Equal(0))
 		})
 
 		It("calls the handleError callback if it exists", func() {
 			firstResponseWriter := utils.NewProxyResponseWriter(httptest.NewRecorder())
-			errorHandler.HandleError(firstResponseWriter, errors.New("i'm a teapot"))
+			errorHandler.HandleError(firstResponseWriter, request, errors.New("i'm a teapot"))
 			Expect(errorHandled).To(BeFalse())
 
-			errorHandler.HandleError(responseWriter, errors.New("i'm a tomato"))
+			errorHandler.HandleError(responseWriter, request, errors.New("i'm a tomato"))
 			Expect(responseWriter.Status()).To(Equal(419))
 			Expect(errorHandled).To(BeTrue())
 		})
@@ -102,17 +107,47 @@ var _ = Describe("HandleError", func() {
 
 	It("removes any headers named 'Connection'", func() {
 		responseWriter.Header().Add("Connection", "foo")
-		errorHandler.HandleError(responseWriter, errors.New("potato"))
+		errorHandler.HandleError(responseWriter, request, errors.New("potato"))
 		Expect(responseWriter.Header().Get("Connection")).To(BeEmpty())
 	})
 
 	It("calls Done on the responseWriter, preventing further writes from going through", func() {
-		errorHandler.HandleError(responseWriter, errors.New("potato"))
+		errorHandler.HandleError(responseWriter, request, errors.New("potato"))
 		nBytesWritten, err := responseWriter.Write([]byte("foo"))
 		Expect(err).NotTo(HaveOccurred())
 		Expect(nBytesWritten).To(Equal(0))
 	})
 
+	Context("when the request is a gRPC call", func() {
+		BeforeEach(func() {
+			request.Header.Set("Content-Type", "application/grpc")
+			errorHandler.ErrorSpecs[1].GRPCStatus = round_tripper.GRPCStatusUnavailable
+		})
+
+		It("responds with the gRPC status of the error instead of an error body", func() {
+			errorHandler.HandleError(responseWriter, request, errors.New("i'm a tomato"))
+			Expect(responseWriter.Status()).To(Equal(http.StatusOK))
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/grpc"))
+			Expect(responseRecorder.Header().Get("Grpc-Status")).To(Equal("14"))
+			Expect(responseRecorder.Header().Get("Grpc-Message")).To(Equal("you say tomato"))
+			Expect(responseRecorder.Body.String()).To(BeEmpty())
+			Expect(errorHandled).To(BeTrue())
+		})
+
+		It("responds with UNAVAILABLE when the error does not match any of the classifiers", func() {
+			errorHandler.HandleError(responseWriter, request, errors.New("potato"))
+			Expect(responseRecorder.Header().Get("Grpc-Status")).To(Equal("14"))
+			Expect(responseRecorder.Header().Get("Grpc-Message")).To(Equal("502 Bad Gateway: Registered endpoint failed to handle the request."))
+			Expect(metricReporter.CaptureBadGatewayCallCount()).To(Equal(1))
+		})
+
+		It("percent-encodes the message", func() {
+			errorHandler.ErrorSpecs[1].Message = "100% tomato\n"
+			errorHandler.HandleError(responseWriter, request, errors.New("i'm a tomato"))
+			Expect(responseRecorder.Header().Get("Grpc-Message")).To(Equal("100%25 tomato%0A"))
+		})
+	})
+
 	Context("DefaultErrorSpecs", func() {
 		var err error
 
@@ -126,7 +161,7 @@ var _ = Describe("HandleError", func() {
 		Context("HostnameMismatch", func() {
 			BeforeEach(func() {
 				err = x509.HostnameError{Host: "the wrong one"}
-				errorHandler.HandleError(responseWriter, err)
+				errorHandler.HandleError(responseWriter, request, err)
 			})
 
 			It("Has a 503 Status Code", func() {
@@ -141,7 +176,7 @@ var _ = Describe("HandleError", func() {
 		Context("Untrusted Cert", func() {
 			BeforeEach(func() {
 				err = x509.UnknownAuthorityError{}
-				errorHandler.HandleError(responseWriter, err)
+				errorHandler.HandleError(responseWriter, request, err)
 			})
 
 			It("Has a 526 Status Code", func() {
@@ -156,7 +191,7 @@ var _ = Describe("HandleError", func() {
 		Context("Attempted TLS with non-TLS backend error", func() {
 			BeforeEach(func() {
 				err = tls.RecordHeaderError{Msg: "bad handshake"}
-				errorHandler.HandleError(responseWriter, err)
+				errorHandler.HandleError(responseWriter, request, err)
 			})
 
 			It("Has a 525 Status Code", func() {
@@ -171,7 +206,7 @@ var _ = Describe("HandleError", func() {
 		Context("Remote handshake failure", func() {
 			BeforeEach(func() {
 				err = &net.OpError{Op: "remote error", Err: errors.New("tls: handshake failure")}
-				errorHandler.HandleError(responseWriter, err)
+				errorHandler.HandleError(responseWriter, request, err)
 			})
 
 			It("Has a 525 Status Code", func() {
@@ -183,10 +218,47 @@ var _ = Describe("HandleError", func() {
 			})
 		})
 
+		Context("Response header timeout", func() {
+			BeforeEach(func() {
+				err = responseHeaderTimeoutError{}
+				errorHandler.HandleError(responseWriter, request, err)
+			})
+
+			It("Has a 504 Status Code", func() {
+				Expect(responseWriter.Status()).To(Equal(http.StatusGatewayTimeout))
+			})
+		})
+
+		Context("HTTP/2 required", func() {
+			BeforeEach(func() {
+				request.Header.Set("Content-Type", "application/grpc")
+				errorHandler.HandleError(responseWriter, request, round_tripper.ErrHTTP2Required)
+			})
+
+			It("responds with UNIMPLEMENTED", func() {
+				Expect(responseRecorder.Header().Get("Grpc-Status")).To(Equal("12"))
+			})
+		})
+
+		Context("Request body too large", func() {
+			BeforeEach(func() {
+				err = fmt.Errorf("net/http: HTTP/1.x transport connection broken: %w", handlers.ErrRequestBodyTooLarge)
+				errorHandler.HandleError(responseWriter, request, err)
+			})
+
+			It("Has a 413 Status Code", func() {
+				Expect(responseWriter.Status()).To(Equal(http.StatusRequestEntityTooLarge))
+			})
+
+			It("does not emit a bad_gateway metric", func() {
+				Expect(metricReporter.CaptureBadGatewayCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("Context Cancelled Error", func() {
 			BeforeEach(func() {
 				err = context.Canceled
-				errorHandler.HandleError(responseWriter, err)
+				errorHandler.HandleError(responseWriter, request, err)
 			})
 
 			It("Has a 499 Status Code", func() {
@@ -195,3 +267,11 @@ var _ = Describe("HandleError", func() {
 		})
 	})
 })
+
+type responseHeaderTimeoutError struct{}
+
+func (responseHeaderTimeoutError) Error() string {
+	return "net/http: timeout awaiting response headers"
+}
+func (responseHeaderTimeoutError) Timeout() bool   { return true }
+func (responseHeaderTimeoutError) Temporary() bool { return true }
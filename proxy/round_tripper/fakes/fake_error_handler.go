@@ -2,32 +2,35 @@
 package fakes
 
 import (
+	"net/http"
 	"sync"
 
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 )
 
 type ErrorHandler struct {
-	HandleErrorStub        func(utils.ProxyResponseWriter, error)
+	HandleErrorStub        func(utils.ProxyResponseWriter, *http.Request, error)
 	handleErrorMutex       sync.RWMutex
 	handleErrorArgsForCall []struct {
 		arg1 utils.ProxyResponseWriter
-		arg2 error
+		arg2 *http.Request
+		arg3 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *ErrorHandler) HandleError(arg1 utils.ProxyResponseWriter, arg2 error) {
+func (fake *ErrorHandler) HandleError(arg1 utils.ProxyResponseWriter, arg2 *http.Request, arg3 error) {
 	fake.handleErrorMutex.Lock()
 	fake.handleErrorArgsForCall = append(fake.handleErrorArgsForCall, struct {
 		arg1 utils.ProxyResponseWriter
-		arg2 error
-	}{arg1, arg2})
-	fake.recordInvocation("HandleError", []interface{}{arg1, arg2})
+		arg2 *http.Request
+		arg3 error
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("HandleError", []interface{}{arg1, arg2, arg3})
 	fake.handleErrorMutex.Unlock()
 	if fake.HandleErrorStub != nil {
-		fake.HandleErrorStub(arg1, arg2)
+		fake.HandleErrorStub(arg1, arg2, arg3)
 	}
 }
 
@@ -37,10 +40,10 @@ func (fake *ErrorHandler) HandleErrorCallCount() int {
 	return len(fake.handleErrorArgsForCall)
 }
 
-func (fake *ErrorHandler) HandleErrorArgsForCall(i int) (utils.ProxyResponseWriter, error) {
+func (fake *ErrorHandler) HandleErrorArgsForCall(i int) (utils.ProxyResponseWriter, *http.Request, error) {
 	fake.handleErrorMutex.RLock()
 	defer fake.handleErrorMutex.RUnlock()
-	return fake.handleErrorArgsForCall[i].arg1, fake.handleErrorArgsForCall[i].arg2
+	return fake.handleErrorArgsForCall[i].arg1, fake.handleErrorArgsForCall[i].arg2, fake.handleErrorArgsForCall[i].arg3
 }
 
 func (fake *ErrorHandler) Invocations() map[string][][]interface{} {
@@ -0,0 +1,58 @@
+package round_tripper
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+)
+
+type proxyProtocolAddrsKey struct{}
+
+// proxyProtocolAddrs are the addresses of the client connection that a
+// request arrived on.
+type proxyProtocolAddrs struct {
+	src *net.TCPAddr
+	dst *net.TCPAddr
+}
+
+// newProxyProtocolRoundTripper returns a round tripper that prepends a PROXY
+// protocol header of the given version to each backend connection, with the
+// addresses of the client connection of the request it is opened for. The
+// connection then belongs to that client, so it is not kept for other
+// requests.
+func newProxyProtocolRoundTripper(transport *http.Transport, dial dialFunc, version string) ProxyRoundTripper {
+	transport.DisableKeepAlives = true
+	transport.Dial = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, _ := ctx.Value(proxyProtocolAddrsKey{}).(proxyProtocolAddrs)
+		err = utils.WriteProxyProtocolHeader(conn, version, addrs.src, addrs.dst)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+
+	return &proxyProtocolRoundTripper{transport: transport}
+}
+
+type proxyProtocolRoundTripper struct {
+	transport *http.Transport
+}
+
+func (p *proxyProtocolRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	src, dst := utils.ProxyProtocolAddrs(req)
+	ctx := context.WithValue(req.Context(), proxyProtocolAddrsKey{}, proxyProtocolAddrs{src: src, dst: dst})
+	return p.transport.RoundTrip(req.WithContext(ctx))
+}
+
+func (p *proxyProtocolRoundTripper) CancelRequest(req *http.Request) {
+	p.transport.CancelRequest(req)
+}
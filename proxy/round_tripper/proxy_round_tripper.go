@@ -2,14 +2,17 @@ package round_tripper
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/uber-go/zap"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/logger"
 	"code.cloudfoundry.org/gorouter/metrics"
@@ -17,6 +20,7 @@ import (
 	"code.cloudfoundry.org/gorouter/proxy/handler"
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/tracing"
 )
 
 const (
@@ -29,8 +33,32 @@ const (
 	SSLHandshakeMessage       = "525 SSL Handshake Failed"
 	SSLCertRequiredMessage    = "496 SSL Certificate Required"
 	ContextCancelledMessage   = "499 Request Cancelled"
+	GatewayTimeoutMessage     = "504 Gateway Timeout: Registered endpoint did not send response headers in time."
+	HTTP2RequiredMessage      = "502 Bad Gateway: Registered endpoint does not support HTTP/2, which gRPC requires."
+	RequestTooLargeMessage    = "413 Request Entity Too Large"
 )
 
+// RetryNonIdempotentHeader opts a request in to retries regardless of its
+// method when retries are restricted to idempotent methods. Its value must
+// be "true".
+const RetryNonIdempotentHeader = "X-CF-Retry-Non-Idempotent"
+
+// RetryNonIdempotentTag is the route tag that opts all requests of a route
+// in to retries regardless of their method. Its value must be "true".
+const RetryNonIdempotentTag = "RetryNonIdempotent"
+
+// idempotentMethods are the methods whose requests are retried when retries
+// are restricted to idempotent methods.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// ErrHTTP2Required is returned for gRPC requests to endpoints that did not
+// register the http2 protocol.
+var ErrHTTP2Required = errors.New("endpoint does not support HTTP/2")
+
 //go:generate counterfeiter -o fakes/fake_proxy_round_tripper.go . ProxyRoundTripper
 type ProxyRoundTripper interface {
 	http.RoundTripper
@@ -38,12 +66,14 @@ type ProxyRoundTripper interface {
 }
 
 type RoundTripperFactory interface {
-	New(expectedServerName string) ProxyRoundTripper
+	New(expectedServerName string, clientCertName string, useHTTP2 bool, proxyProtocol string) ProxyRoundTripper
 }
 
 func GetRoundTripper(e *route.Endpoint, roundTripperFactory RoundTripperFactory) ProxyRoundTripper {
 	e.RoundTripperInit.Do(func() {
-		e.SetRoundTripperIfNil(func() route.ProxyRoundTripper { return roundTripperFactory.New(e.ServerCertDomainSAN) })
+		e.SetRoundTripperIfNil(func() route.ProxyRoundTripper {
+			return roundTripperFactory.New(e.ServerCertDomainSAN, e.ClientCertName, e.IsHTTP2(), e.ProxyProtocol)
+		})
 	})
 
 	return e.RoundTripper()
@@ -51,11 +81,12 @@ func GetRoundTripper(e *route.Endpoint, roundTripperFactory RoundTripperFactory)
 
 //go:generate counterfeiter -o fakes/fake_error_handler.go --fake-name ErrorHandler . errorHandler
 type errorHandler interface {
-	HandleError(utils.ProxyResponseWriter, error)
+	HandleError(utils.ProxyResponseWriter, *http.Request, error)
 }
 
 func NewProxyRoundTripper(
 	roundTripperFactory RoundTripperFactory,
+	routeServiceRoundTripperFactory RoundTripperFactory,
 	retriableClassifier fails.Classifier,
 	logger logger.Logger,
 	defaultLoadBalance string,
@@ -63,7 +94,9 @@ func NewProxyRoundTripper(
 	secureCookies bool,
 	errorHandler errorHandler,
 	routeServicesTransport http.RoundTripper,
-	endpointTimeout time.Duration,
+	timeouts *Timeouts,
+	timeoutHeader string,
+	retryPolicy config.RetryPolicyConfig,
 ) ProxyRoundTripper {
 	return &roundTripper{
 		logger:                 logger,
@@ -71,10 +104,14 @@ func NewProxyRoundTripper(
 		combinedReporter:       combinedReporter,
 		secureCookies:          secureCookies,
 		roundTripperFactory:    roundTripperFactory,
+		routeServiceFactory:    routeServiceRoundTripperFactory,
 		retriableClassifier:    retriableClassifier,
 		errorHandler:           errorHandler,
 		routeServicesTransport: routeServicesTransport,
-		endpointTimeout:        endpointTimeout,
+		timeouts:               timeouts,
+		timeoutHeader:          timeoutHeader,
+		retryPolicy:            retryPolicy,
+		retryBudget:            newRetryBudget(retryPolicy.Budget),
 	}
 }
 
@@ -84,10 +121,14 @@ type roundTripper struct {
 	combinedReporter       metrics.ProxyReporter
 	secureCookies          bool
 	roundTripperFactory    RoundTripperFactory
+	routeServiceFactory    RoundTripperFactory
 	retriableClassifier    fails.Classifier
 	errorHandler           errorHandler
 	routeServicesTransport http.RoundTripper
-	endpointTimeout        time.Duration
+	timeouts               *Timeouts
+	timeoutHeader          string
+	retryPolicy            config.RetryPolicyConfig
+	retryBudget            *retryBudget
 }
 
 func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
@@ -95,6 +136,8 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 	var res *http.Response
 	var endpoint *route.Endpoint
 
+	// a request whose body was sent cannot be sent again
+	hasBody := request.Body != nil && request.Body != http.NoBody
 	if request.Body != nil {
 		closer := request.Body
 		request.Body = ioutil.NopCloser(request.Body)
@@ -115,12 +158,20 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 		return nil, errors.New("ProxyResponseWriter not set on context")
 	}
 
+	retriable := rt.retriable(request, reqInfo.RoutePool)
 	stickyEndpointID := getStickySession(request)
-	iter := reqInfo.RoutePool.Endpoints(rt.defaultLoadBalance, stickyEndpointID)
+	iter := reqInfo.RoutePool.EndpointsForKey(rt.defaultLoadBalance, stickyEndpointID, request.URL.Path, reqInfo.HashKey)
+	routeTimeout := rt.routeTimeout(reqInfo.RoutePool)
+	deadline := rt.requestDeadline(request, routeTimeout)
+	attemptTimeout := rt.attemptTimeout(routeTimeout)
+	streamingRoute := handlers.IsStreamingRoute(reqInfo.RoutePool)
+	streaming := streamingRoute || handlers.AcceptsEventStream(request)
+
+	rt.retryBudget.request()
 
 	logger := rt.logger
 	var selectEndpointErr error
-	for retry := 0; retry < handler.MaxRetries; retry++ {
+	for retry := 0; retry < rt.retryPolicy.MaxAttempts; retry++ {
 		logger = rt.logger
 
 		if reqInfo.RouteServiceURL == nil {
@@ -131,24 +182,49 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 			logger = logger.With(zap.Nest("route-endpoint", endpoint.ToLogData()...))
 			reqInfo.RouteEndpoint = endpoint
 
+			if handlers.IsGRPCRequest(request) && !endpoint.IsHTTP2() {
+				err = ErrHTTP2Required
+				logger.Error("grpc-endpoint-not-http2", zap.Error(err))
+				break
+			}
+
 			logger.Debug("backend", zap.Int("attempt", retry))
 			if endpoint.IsTLS() {
 				request.URL.Scheme = "https"
 			} else {
 				request.URL.Scheme = "http"
 			}
-			res, err = rt.backendRoundTrip(request, endpoint, iter)
+			rt.setTimeoutHeader(request, deadline)
+			span := reqInfo.TraceSpan.StartChild("backend", tracing.SpanKindClient)
+			span.SetAttribute("net.peer.name", endpoint.CanonicalAddr())
+			span.SetAttribute("gorouter.attempt", retry+1)
+			span.Inject(request.Header)
+			breaker := reqInfo.RoutePool.CircuitBreaker(endpoint)
+			probe := breaker.Begin()
+			res, err = rt.backendRoundTrip(request, endpoint, iter, attemptTimeout, streaming, streamingRoute)
+			endSpan(span, res, err)
+			rt.recordOutcome(request, reqInfo.RoutePool, endpoint, breaker, probe, res, err)
 
 			if err != nil {
 				iter.EndpointFailed(err)
 				logger.Error("backend-endpoint-failed", zap.Error(err), zap.Int("attempt", retry+1), zap.String("vcap_request_id", request.Header.Get(handlers.VcapRequestIdHeader)))
+				if endpoint.IsTLS() {
+					logHostnameMismatch(logger, endpoint, err)
+				}
 
-				if rt.retriableClassifier.Classify(err) {
+				if rt.retriableClassifier.Classify(err) && rt.allowRetry(logger, retry, retriable) {
 					logger.Debug("retriable-error", zap.Object("error", err))
 					continue
 				}
 			}
 
+			if err == nil && res != nil && !hasBody && rt.retriableStatus(res.StatusCode) && rt.allowRetry(logger, retry, retriable) {
+				logger.Debug("retriable-status-code", zap.Int("status-code", res.StatusCode))
+				iter.EndpointFailed(fails.RetriableStatusError{StatusCode: res.StatusCode})
+				res.Body.Close()
+				continue
+			}
+
 			break
 		} else {
 			logger.Debug(
@@ -166,7 +242,7 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 			*request.URL = *reqInfo.RouteServiceURL
 
 			var tr http.RoundTripper
-			tr = GetRoundTripper(endpoint, rt.roundTripperFactory)
+			tr = GetRoundTripper(endpoint, rt.routeServiceFactory)
 			if reqInfo.IsInternalRouteService {
 				// note: this *looks* like it breaks TLS to internal route service backends,
 				// but in fact it is right!  this hairpins back on the gorouter, and the subsequent
@@ -174,11 +250,16 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 				tr = rt.routeServicesTransport
 			}
 
-			res, err = rt.timedRoundTrip(tr, request)
+			span := reqInfo.TraceSpan.StartChild("route_service", tracing.SpanKindClient)
+			span.SetAttribute("http.url", request.URL.String())
+			span.SetAttribute("gorouter.attempt", retry+1)
+			span.Inject(request.Header)
+			res, err = rt.timedRoundTrip(tr, request, attemptTimeout, streaming, streamingRoute)
+			endSpan(span, res, err)
 			if err != nil {
 				logger.Error("route-service-connection-failed", zap.Error(err))
 
-				if rt.retriableClassifier.Classify(err) {
+				if rt.retriableClassifier.Classify(err) && rt.allowRetry(logger, retry, retriable) {
 					continue
 				}
 			}
@@ -203,13 +284,13 @@ func (rt *roundTripper) RoundTrip(request *http.Request) (*http.Response, error)
 	}
 
 	if finalErr != nil {
-		rt.errorHandler.HandleError(reqInfo.ProxyResponseWriter, finalErr)
+		rt.errorHandler.HandleError(reqInfo.ProxyResponseWriter, request, finalErr)
 		return nil, finalErr
 	}
 
 	if res != nil && endpoint.PrivateInstanceId != "" {
 		setupStickySession(
-			res, endpoint, stickyEndpointID, rt.secureCookies,
+			res, endpoint, stickyEndpointID, rt.secureCookies || reqInfo.UpstreamTLS,
 			reqInfo.RoutePool.ContextPath(),
 		)
 	}
@@ -231,6 +312,9 @@ func (rt *roundTripper) backendRoundTrip(
 	request *http.Request,
 	endpoint *route.Endpoint,
 	iter route.EndpointIterator,
+	timeout time.Duration,
+	streaming bool,
+	streamingRoute bool,
 ) (*http.Response, error) {
 	request.URL.Host = endpoint.CanonicalAddr()
 	request.Header.Set("X-CF-ApplicationID", endpoint.ApplicationId)
@@ -242,19 +326,139 @@ func (rt *roundTripper) backendRoundTrip(
 
 	rt.combinedReporter.CaptureRoutingRequest(endpoint)
 	tr := GetRoundTripper(endpoint, rt.roundTripperFactory)
-	res, err := rt.timedRoundTrip(tr, request)
+	res, err := rt.timedRoundTrip(tr, request, timeout, streaming, streamingRoute)
 
 	// decrement connection stats
 	iter.PostRequest(endpoint)
+
+	if err == nil && res != nil && res.StatusCode < http.StatusInternalServerError {
+		endpoint.MarkServed()
+	}
 	return res, err
 }
 
-func (rt *roundTripper) timedRoundTrip(tr http.RoundTripper, request *http.Request) (*http.Response, error) {
-	if rt.endpointTimeout <= 0 {
+// recordOutcome passes the result of a request to an endpoint to its circuit
+// breaker and to outlier detection. Errors and 5xx responses count as
+// failures, unless the client went away, which says nothing about the
+// endpoint.
+func (rt *roundTripper) recordOutcome(
+	request *http.Request,
+	pool *route.Pool,
+	endpoint *route.Endpoint,
+	breaker *route.CircuitBreaker,
+	probe bool,
+	res *http.Response,
+	err error,
+) {
+	// the client, not the endpoint, is at fault
+	if err != nil && (request.Context().Err() == context.Canceled || errors.Is(err, handlers.ErrRequestBodyTooLarge)) {
+		breaker.Cancel(probe)
+		return
+	}
+
+	failed := err != nil || res == nil || res.StatusCode >= http.StatusInternalServerError
+	if breaker.Done(probe, failed) {
+		rt.logger.Info("circuit-breaker-opened", zap.Nest("route-endpoint", endpoint.ToLogData()...))
+		rt.combinedReporter.CaptureBackendCircuitOpened()
+	}
+	if pool.RecordOutcome(endpoint, failed) {
+		rt.combinedReporter.CaptureBackendEjected()
+	}
+}
+
+// retriable reports whether the request may be retried. When retries are
+// restricted to idempotent methods, requests with other methods are only
+// retried when they carry the RetryNonIdempotentHeader, or their route is
+// tagged with RetryNonIdempotentTag.
+func (rt *roundTripper) retriable(request *http.Request, pool *route.Pool) bool {
+	if !rt.retryPolicy.IdempotentMethodsOnly || idempotentMethods[request.Method] {
+		return true
+	}
+	if request.Header.Get(RetryNonIdempotentHeader) == "true" {
+		return true
+	}
+
+	var tagged bool
+	pool.Each(func(e *route.Endpoint) {
+		tagged = tagged || e.Tags[RetryNonIdempotentTag] == "true"
+	})
+	return tagged
+}
+
+// allowRetry reports whether another attempt may be made after the given
+// one, which is the case for retriable requests while attempts and the
+// retry budget remain.
+func (rt *roundTripper) allowRetry(logger logger.Logger, attempt int, retriable bool) bool {
+	if attempt+1 >= rt.retryPolicy.MaxAttempts {
+		return false
+	}
+
+	if !retriable {
+		logger.Debug("non-idempotent-request-not-retried", zap.Int("attempt", attempt+1))
+		return false
+	}
+
+	if !rt.retryBudget.withdraw() {
+		logger.Info("retry-budget-exhausted", zap.Int("attempt", attempt+1))
+		return false
+	}
+	return true
+}
+
+// retriableStatus reports whether responses with the status code are
+// retried.
+func (rt *roundTripper) retriableStatus(statusCode int) bool {
+	for _, code := range rt.retryPolicy.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// routeTimeout returns the timeout that the endpoints of the route
+// registered, bounded by the maximum route timeout, or zero if they
+// registered none.
+func (rt *roundTripper) routeTimeout(pool *route.Pool) time.Duration {
+	timeout := pool.Timeout()
+	if maxRouteTimeout := rt.timeouts.MaxRoute(); maxRouteTimeout > 0 && timeout > maxRouteTimeout {
+		return maxRouteTimeout
+	}
+	return timeout
+}
+
+// attemptTimeout returns the timeout of each attempt of a request. The
+// timeout of the route takes precedence over the per-try timeout of the
+// retry policy, which takes precedence over the endpoint timeout.
+func (rt *roundTripper) attemptTimeout(routeTimeout time.Duration) time.Duration {
+	if routeTimeout > 0 {
+		return routeTimeout
+	}
+	if rt.retryPolicy.PerTryTimeout > 0 {
+		return rt.retryPolicy.PerTryTimeout
+	}
+	return rt.timeouts.Endpoint()
+}
+
+// timedRoundTrip makes the request within the timeout. The timeout of
+// streaming requests only lasts until the response headers arrive when the
+// response is a stream, which then lasts as long as the client and endpoint
+// keep it open.
+func (rt *roundTripper) timedRoundTrip(
+	tr http.RoundTripper,
+	request *http.Request,
+	timeout time.Duration,
+	streaming bool,
+	streamingRoute bool,
+) (*http.Response, error) {
+	if timeout <= 0 {
 		return tr.RoundTrip(request)
 	}
+	if streaming {
+		return streamingRoundTrip(tr, request, timeout, streamingRoute)
+	}
 
-	reqCtx, cancel := context.WithTimeout(request.Context(), rt.endpointTimeout)
+	reqCtx, cancel := context.WithTimeout(request.Context(), timeout)
 	request = request.WithContext(reqCtx)
 
 	// unfortunately if the cancel function above is not called that
@@ -275,6 +479,80 @@ func (rt *roundTripper) timedRoundTrip(tr http.RoundTripper, request *http.Reque
 	return resp, err
 }
 
+func streamingRoundTrip(tr http.RoundTripper, request *http.Request, timeout time.Duration, streamingRoute bool) (*http.Response, error) {
+	reqCtx, cancel := context.WithCancelCause(request.Context())
+	timer := time.AfterFunc(timeout, func() {
+		cancel(context.DeadlineExceeded)
+	})
+	request = request.WithContext(reqCtx)
+
+	resp, err := tr.RoundTrip(request)
+	if err != nil {
+		timer.Stop()
+		if context.Cause(reqCtx) == context.DeadlineExceeded {
+			err = context.DeadlineExceeded
+		}
+		cancel(err)
+		return nil, err
+	}
+
+	if streamingRoute || (resp != nil && handlers.IsEventStream(resp.Header.Get("Content-Type"))) {
+		timer.Stop()
+	}
+	return resp, nil
+}
+
+// requestDeadline returns the time by which the request should be complete,
+// or a zero time when it has no deadline. The timeout of the route takes
+// precedence over the endpoint timeout.
+func (rt *roundTripper) requestDeadline(request *http.Request, routeTimeout time.Duration) time.Time {
+	timeout := rt.timeouts.Endpoint()
+	if routeTimeout > 0 {
+		timeout = routeTimeout
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := request.Context().Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return deadline
+}
+
+// setTimeoutHeader tells the backend how many milliseconds remain until the
+// deadline, so that it can stop work the client will not wait for.
+func (rt *roundTripper) setTimeoutHeader(request *http.Request, deadline time.Time) {
+	if rt.timeoutHeader == "" {
+		return
+	}
+	if deadline.IsZero() {
+		request.Header.Del(rt.timeoutHeader)
+		return
+	}
+
+	remaining := time.Until(deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+	request.Header.Set(rt.timeoutHeader, strconv.FormatInt(int64(remaining/time.Millisecond), 10))
+}
+
+// endSpan records the outcome of an attempt on its span. The span ends when
+// the response headers arrive, before the body is copied to the client.
+func endSpan(span *tracing.Span, res *http.Response, err error) {
+	if err != nil {
+		span.SetError(err.Error())
+	} else if res != nil {
+		span.SetAttribute("http.status_code", res.StatusCode)
+		if res.StatusCode >= http.StatusInternalServerError {
+			span.SetError(http.StatusText(res.StatusCode))
+		}
+	}
+	span.End()
+}
+
 func (rt *roundTripper) selectEndpoint(iter route.EndpointIterator, request *http.Request) (*route.Endpoint, error) {
 	endpoint := iter.Next()
 	if endpoint == nil {
@@ -284,6 +562,38 @@ func (rt *roundTripper) selectEndpoint(iter route.EndpointIterator, request *htt
 	return endpoint, nil
 }
 
+// logHostnameMismatch emits a diagnostic warning when the backend presented a
+// certificate whose SANs do not cover the name gorouter verified against.
+func logHostnameMismatch(logger logger.Logger, endpoint *route.Endpoint, err error) {
+	var hostnameErr x509.HostnameError
+	switch e := err.(type) {
+	case x509.HostnameError:
+		hostnameErr = e
+	case *x509.HostnameError:
+		hostnameErr = *e
+	default:
+		return
+	}
+
+	var dnsSANs, ipSANs []string
+	var commonName string
+	if cert := hostnameErr.Certificate; cert != nil {
+		dnsSANs = cert.DNSNames
+		for _, ip := range cert.IPAddresses {
+			ipSANs = append(ipSANs, ip.String())
+		}
+		commonName = cert.Subject.CommonName
+	}
+
+	logger.Warn("backend-tls-hostname-mismatch",
+		zap.String("attempted-sni", hostnameErr.Host),
+		zap.String("expected-san", endpoint.ServerCertDomainSAN),
+		zap.Object("cert-dns-sans", dnsSANs),
+		zap.Object("cert-ip-sans", ipSANs),
+		zap.String("cert-common-name", commonName),
+	)
+}
+
 func setupStickySession(
 	response *http.Response,
 	endpoint *route.Endpoint,
@@ -2,17 +2,24 @@ package round_tripper_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"code.cloudfoundry.org/gorouter/common/uuid"
+	"code.cloudfoundry.org/gorouter/config"
 	sharedfakes "code.cloudfoundry.org/gorouter/fakes"
 	"code.cloudfoundry.org/gorouter/handlers"
 	"code.cloudfoundry.org/gorouter/metrics/fakes"
@@ -23,10 +30,13 @@ import (
 	"code.cloudfoundry.org/gorouter/proxy/utils"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/gorouter/tracing"
+	tracefakes "code.cloudfoundry.org/gorouter/tracing/fakes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
 	"github.com/uber-go/zap"
 )
 
@@ -47,7 +57,7 @@ type FakeRoundTripperFactory struct {
 	Calls       int
 }
 
-func (f *FakeRoundTripperFactory) New(expectedServerName string) round_tripper.ProxyRoundTripper {
+func (f *FakeRoundTripperFactory) New(expectedServerName string, clientCertName string, useHTTP2 bool, proxyProtocol string) round_tripper.ProxyRoundTripper {
 	f.Calls++
 	return f.ReturnValue
 }
@@ -64,10 +74,14 @@ var _ = Describe("ProxyRoundTripper", func() {
 			resp                   *httptest.ResponseRecorder
 			combinedReporter       *fakes.FakeCombinedReporter
 			roundTripperFactory    *FakeRoundTripperFactory
+			routeServiceFactory    *FakeRoundTripperFactory
 			routeServicesTransport *sharedfakes.RoundTripper
 			retriableClassifier    *errorClassifierFakes.Classifier
 			errorHandler           *roundtripperfakes.ErrorHandler
 			timeout                time.Duration
+			timeoutHeader          string
+			maxRouteTimeout        time.Duration
+			retryPolicy            config.RetryPolicyConfig
 
 			reqInfo *handlers.RequestInfo
 
@@ -95,6 +109,9 @@ var _ = Describe("ProxyRoundTripper", func() {
 			req.URL.Scheme = "http"
 
 			timeout = 0 * time.Millisecond
+			timeoutHeader = ""
+			maxRouteTimeout = 0
+			retryPolicy = config.RetryPolicyConfig{MaxAttempts: 3}
 
 			handlers.NewRequestInfo().ServeHTTP(nil, req, func(_ http.ResponseWriter, transformedReq *http.Request) {
 				req = transformedReq
@@ -125,6 +142,7 @@ var _ = Describe("ProxyRoundTripper", func() {
 			errorHandler = &roundtripperfakes.ErrorHandler{}
 
 			roundTripperFactory = &FakeRoundTripperFactory{ReturnValue: transport}
+			routeServiceFactory = roundTripperFactory
 			retriableClassifier = &errorClassifierFakes.Classifier{}
 			retriableClassifier.ClassifyReturns(false)
 			routeServicesTransport = &sharedfakes.RoundTripper{}
@@ -132,11 +150,12 @@ var _ = Describe("ProxyRoundTripper", func() {
 
 		JustBeforeEach(func() {
 			proxyRoundTripper = round_tripper.NewProxyRoundTripper(
-				roundTripperFactory, retriableClassifier,
+				roundTripperFactory, routeServiceFactory, retriableClassifier,
 				logger, "",
 				combinedReporter, false,
 				errorHandler, routeServicesTransport,
-				timeout,
+				round_tripper.NewTimeouts(timeout, maxRouteTimeout), timeoutHeader,
+				retryPolicy,
 			)
 		})
 
@@ -319,7 +338,7 @@ var _ = Describe("ProxyRoundTripper", func() {
 					_, err := proxyRoundTripper.RoundTrip(req)
 					Expect(err).To(HaveOccurred())
 					Expect(errorHandler.HandleErrorCallCount()).To(Equal(1))
-					_, err = errorHandler.HandleErrorArgsForCall(0)
+					_, _, err = errorHandler.HandleErrorArgsForCall(0)
 					Expect(err).To(MatchError(ContainSubstring("tls: handshake failure")))
 				})
 
@@ -356,6 +375,42 @@ var _ = Describe("ProxyRoundTripper", func() {
 				})
 			})
 
+			Context("when the request is a gRPC call", func() {
+				BeforeEach(func() {
+					req.Header.Set("Content-Type", "application/grpc")
+					transport.RoundTripReturns(resp.Result(), nil)
+				})
+
+				It("does not send it to an endpoint that does not support HTTP/2", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(Equal(round_tripper.ErrHTTP2Required))
+					Expect(transport.RoundTripCallCount()).To(Equal(0))
+
+					Expect(errorHandler.HandleErrorCallCount()).To(Equal(1))
+					_, errReq, err := errorHandler.HandleErrorArgsForCall(0)
+					Expect(errReq).To(Equal(req))
+					Expect(err).To(Equal(round_tripper.ErrHTTP2Required))
+				})
+
+				Context("when the endpoint supports HTTP/2", func() {
+					BeforeEach(func() {
+						routePool.Remove(endpoint)
+						endpoint = route.NewEndpoint(&route.EndpointOpts{
+							Host:     "1.1.1.1",
+							Port:     9090,
+							Protocol: route.ProtocolHTTP2,
+						})
+						routePool.Put(endpoint)
+					})
+
+					It("sends it to the endpoint", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(1))
+					})
+				})
+			})
+
 			Context("when there are no more endpoints available", func() {
 				BeforeEach(func() {
 					removed := routePool.Remove(endpoint)
@@ -376,7 +431,7 @@ var _ = Describe("ProxyRoundTripper", func() {
 				It("calls the error handler", func() {
 					proxyRoundTripper.RoundTrip(req)
 					Expect(errorHandler.HandleErrorCallCount()).To(Equal(1))
-					_, err := errorHandler.HandleErrorArgsForCall(0)
+					_, _, err := errorHandler.HandleErrorArgsForCall(0)
 					Expect(err).To(Equal(handler.NoEndpointsAvailable))
 				})
 
@@ -430,6 +485,33 @@ var _ = Describe("ProxyRoundTripper", func() {
 					Expect(logger.Buffer()).ToNot(gbytes.Say(`route-service`))
 				})
 
+				It("records that the endpoint served the request", func() {
+					before := endpoint.LastServed()
+					time.Sleep(time.Millisecond)
+
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(endpoint.LastServed()).To(BeTemporally(">", before))
+				})
+
+				Context("when the backend responds with a server error", func() {
+					BeforeEach(func() {
+						transport.RoundTripReturns(
+							&http.Response{StatusCode: http.StatusServiceUnavailable}, nil,
+						)
+					})
+
+					It("does not record that the endpoint served the request", func() {
+						before := endpoint.LastServed()
+						time.Sleep(time.Millisecond)
+
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(endpoint.LastServed()).To(Equal(before))
+					})
+				})
 			})
 
 			Context("when there are a mixture of tls and non-tls backends", func() {
@@ -481,6 +563,303 @@ var _ = Describe("ProxyRoundTripper", func() {
 				})
 			})
 
+			Context("with a retry policy", func() {
+				BeforeEach(func() {
+					retriableClassifier.ClassifyReturns(true)
+				})
+
+				Context("when the number of attempts is configured", func() {
+					BeforeEach(func() {
+						retryPolicy.MaxAttempts = 5
+						transport.RoundTripReturns(nil, dialError)
+					})
+
+					It("makes at most the configured number of attempts", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(5))
+					})
+				})
+
+				Context("when responses have a retryable status code", func() {
+					BeforeEach(func() {
+						retryPolicy.RetryableStatusCodes = []int{http.StatusServiceUnavailable}
+						transport.RoundTripReturnsOnCall(0, &http.Response{StatusCode: http.StatusServiceUnavailable, Body: ioutil.NopCloser(strings.NewReader(""))}, nil)
+						transport.RoundTripReturnsOnCall(1, &http.Response{StatusCode: http.StatusOK}, nil)
+					})
+
+					It("retries requests without a body", func() {
+						req.Body = nil
+
+						res, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(res.StatusCode).To(Equal(http.StatusOK))
+						Expect(transport.RoundTripCallCount()).To(Equal(2))
+					})
+
+					It("retries against another endpoint", func() {
+						req.Body = nil
+						other := route.NewEndpoint(&route.EndpointOpts{Host: "2.2.2.2", Port: 9090})
+						Expect(routePool.Put(other)).To(Equal(route.ADDED))
+
+						var hosts []string
+						transport.RoundTripStub = func(r *http.Request) (*http.Response, error) {
+							hosts = append(hosts, r.URL.Host)
+							if len(hosts) == 1 {
+								return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+							}
+							return &http.Response{StatusCode: http.StatusOK}, nil
+						}
+
+						res, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(res.StatusCode).To(Equal(http.StatusOK))
+						Expect(hosts).To(HaveLen(2))
+						Expect(hosts[0]).NotTo(Equal(hosts[1]))
+					})
+
+					It("marks the endpoint as failed before retrying against another endpoint", func() {
+						req.Body = nil
+						other := route.NewEndpoint(&route.EndpointOpts{Host: "2.2.2.2", Port: 9090})
+						Expect(routePool.Put(other)).To(Equal(route.ADDED))
+
+						var hosts []string
+						transport.RoundTripStub = func(r *http.Request) (*http.Response, error) {
+							hosts = append(hosts, r.URL.Host)
+							if len(hosts) == 1 {
+								return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+							}
+							return &http.Response{StatusCode: http.StatusOK}, nil
+						}
+
+						res, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(res.StatusCode).To(Equal(http.StatusOK))
+						Expect(logger.Buffer()).To(gbytes.Say(`endpoint-marked-as-ineligible`))
+
+						for i := 0; i < 3; i++ {
+							res, err = proxyRoundTripper.RoundTrip(req)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(res.StatusCode).To(Equal(http.StatusOK))
+						}
+						Expect(hosts).To(HaveLen(5))
+						Expect(hosts[2:]).To(ConsistOf(hosts[1], hosts[1], hosts[1]))
+						Expect(hosts[1]).NotTo(Equal(hosts[0]))
+					})
+
+					It("does not retry requests with a body", func() {
+						res, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+						Expect(transport.RoundTripCallCount()).To(Equal(1))
+					})
+
+					Context("when no attempts remain", func() {
+						BeforeEach(func() {
+							retryPolicy.MaxAttempts = 1
+						})
+
+						It("returns the response of the last attempt", func() {
+							req.Body = nil
+
+							res, err := proxyRoundTripper.RoundTrip(req)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+							Expect(transport.RoundTripCallCount()).To(Equal(1))
+						})
+					})
+				})
+
+				Context("when retries are restricted to idempotent methods", func() {
+					BeforeEach(func() {
+						retryPolicy.IdempotentMethodsOnly = true
+						transport.RoundTripReturns(nil, dialError)
+					})
+
+					It("retries GET requests", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(3))
+					})
+
+					It("does not retry POST requests", func() {
+						req.Method = http.MethodPost
+
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(1))
+						Expect(logger.Buffer()).To(gbytes.Say(`non-idempotent-request-not-retried`))
+					})
+
+					It("retries POST requests that opt in with a header", func() {
+						req.Method = http.MethodPost
+						req.Header.Set(round_tripper.RetryNonIdempotentHeader, "true")
+
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(3))
+					})
+
+					It("retries POST requests to routes that opt in with a tag", func() {
+						req.Method = http.MethodPost
+						routePool.Put(route.NewEndpoint(&route.EndpointOpts{
+							Host: "1.1.1.1",
+							Port: 9091,
+							Tags: map[string]string{round_tripper.RetryNonIdempotentTag: "true"},
+						}))
+
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(3))
+					})
+				})
+
+				Context("when the retry budget is enabled", func() {
+					BeforeEach(func() {
+						retryPolicy.Budget = config.RetryBudgetConfig{Enabled: true, Percent: 50}
+						transport.RoundTripReturns(nil, dialError)
+					})
+
+					It("limits retries to a share of the requests", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(1))
+						Expect(logger.Buffer()).To(gbytes.Say(`retry-budget-exhausted`))
+
+						_, err = proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(3))
+					})
+
+					Context("with a minimum number of retries", func() {
+						BeforeEach(func() {
+							retryPolicy.Budget.MinRetriesPerSecond = 1
+						})
+
+						It("always allows the minimum number of retries", func() {
+							_, err := proxyRoundTripper.RoundTrip(req)
+							Expect(err).To(HaveOccurred())
+							Expect(transport.RoundTripCallCount()).To(Equal(3))
+						})
+					})
+				})
+
+				Context("with a per-try timeout", func() {
+					BeforeEach(func() {
+						timeout = time.Minute
+						retryPolicy.PerTryTimeout = 10 * time.Millisecond
+						transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+							<-req.Context().Done()
+							return nil, req.Context().Err()
+						}
+						retriableClassifier.ClassifyReturns(false)
+					})
+
+					It("limits each attempt to the per-try timeout", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(MatchError(context.DeadlineExceeded))
+					})
+				})
+			})
+
+			Context("when outlier detection is enabled", func() {
+				BeforeEach(func() {
+					routePool = route.NewPool(&route.PoolOpts{
+						Logger:            logger,
+						RetryAfterFailure: 1 * time.Second,
+						Host:              "myapp.com",
+						OutlierDetection: config.OutlierDetectionConfig{
+							Enabled:             true,
+							ConsecutiveFailures: 2,
+							BaseEjectionTime:    time.Minute,
+							MaxEjectionTime:     time.Minute,
+						},
+					})
+					Expect(routePool.Put(endpoint)).To(Equal(route.ADDED))
+					reqInfo.RoutePool = routePool
+				})
+
+				It("ejects endpoints that respond with 5xx statuses in a row", func() {
+					transport.RoundTripReturns(&http.Response{StatusCode: http.StatusServiceUnavailable}, nil)
+
+					for i := 0; i < 2; i++ {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+					}
+
+					Expect(combinedReporter.CaptureBackendEjectedCallCount()).To(Equal(1))
+					Expect(logger.Buffer()).To(gbytes.Say(`endpoint-ejected`))
+				})
+
+				It("ejects endpoints that fail to connect in a row", func() {
+					transport.RoundTripReturns(nil, dialError)
+
+					for i := 0; i < 2; i++ {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+					}
+
+					Expect(combinedReporter.CaptureBackendEjectedCallCount()).To(Equal(1))
+				})
+
+				It("does not eject endpoints that succeed in between failures", func() {
+					transport.RoundTripReturnsOnCall(0, &http.Response{StatusCode: http.StatusInternalServerError}, nil)
+					transport.RoundTripReturnsOnCall(1, &http.Response{StatusCode: http.StatusOK}, nil)
+					transport.RoundTripReturnsOnCall(2, &http.Response{StatusCode: http.StatusInternalServerError}, nil)
+
+					for i := 0; i < 3; i++ {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+					}
+
+					Expect(combinedReporter.CaptureBackendEjectedCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when circuit breaking is enabled", func() {
+				BeforeEach(func() {
+					routePool = route.NewPool(&route.PoolOpts{
+						Logger:            logger,
+						RetryAfterFailure: 1 * time.Second,
+						Host:              "myapp.com",
+						CircuitBreaker: config.CircuitBreakerConfig{
+							Enabled:             true,
+							ConsecutiveFailures: 2,
+							OpenTimeout:         time.Minute,
+							HalfOpenMaxRequests: 1,
+							SuccessThreshold:    1,
+						},
+					})
+					Expect(routePool.Put(endpoint)).To(Equal(route.ADDED))
+					reqInfo.RoutePool = routePool
+				})
+
+				It("opens the circuit of endpoints whose requests fail in a row", func() {
+					transport.RoundTripReturns(&http.Response{StatusCode: http.StatusBadGateway}, nil)
+
+					for i := 0; i < 2; i++ {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+					}
+
+					Expect(routePool.CircuitBreaker(endpoint).State()).To(Equal(route.CircuitOpen))
+					Expect(combinedReporter.CaptureBackendCircuitOpenedCallCount()).To(Equal(1))
+					Expect(logger.Buffer()).To(gbytes.Say(`circuit-breaker-opened`))
+				})
+
+				It("keeps the circuit closed while requests succeed", func() {
+					transport.RoundTripReturns(&http.Response{StatusCode: http.StatusOK}, nil)
+
+					for i := 0; i < 3; i++ {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+					}
+
+					Expect(routePool.CircuitBreaker(endpoint).State()).To(Equal(route.CircuitClosed))
+					Expect(combinedReporter.CaptureBackendCircuitOpenedCallCount()).To(Equal(0))
+				})
+			})
+
 			Context("when backend is registered with a tls port", func() {
 				BeforeEach(func() {
 					var oldEndpoints []*route.Endpoint
@@ -542,6 +921,54 @@ var _ = Describe("ProxyRoundTripper", func() {
 				})
 			})
 
+			Context("when the backend certificate does not match the server cert domain SAN", func() {
+				BeforeEach(func() {
+					endpoint = route.NewEndpoint(&route.EndpointOpts{
+						Host:                "1.1.1.1",
+						Port:                9090,
+						UseTLS:              true,
+						ServerCertDomainSAN: "expected-instance-id",
+					})
+					added := routePool.Put(endpoint)
+					Expect(added).To(Equal(route.UPDATED))
+
+					transport.RoundTripReturns(nil, x509.HostnameError{
+						Host: "expected-instance-id",
+						Certificate: &x509.Certificate{
+							Subject:     pkix.Name{CommonName: "other-common-name"},
+							DNSNames:    []string{"other-instance-id", "*.example.com"},
+							IPAddresses: []net.IP{net.ParseIP("10.0.0.5")},
+						},
+					})
+				})
+
+				It("logs a diagnostic warning with the attempted SNI, expected SAN and certificate SANs", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).To(HaveOccurred())
+
+					warnings := logger.Lines(zap.WarnLevel)
+					Expect(warnings).To(HaveLen(1))
+					Expect(warnings[0]).To(ContainSubstring(`"message":"backend-tls-hostname-mismatch"`))
+					Expect(warnings[0]).To(ContainSubstring(`"attempted-sni":"expected-instance-id"`))
+					Expect(warnings[0]).To(ContainSubstring(`"expected-san":"expected-instance-id"`))
+					Expect(warnings[0]).To(ContainSubstring(`"cert-dns-sans":["other-instance-id","*.example.com"]`))
+					Expect(warnings[0]).To(ContainSubstring(`"cert-ip-sans":["10.0.0.5"]`))
+					Expect(warnings[0]).To(ContainSubstring(`"cert-common-name":"other-common-name"`))
+				})
+
+				Context("when the error is not a hostname verification failure", func() {
+					BeforeEach(func() {
+						transport.RoundTripReturns(nil, dialError)
+					})
+
+					It("does not log the diagnostic warning", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(HaveOccurred())
+						Expect(logger.Lines(zap.WarnLevel)).To(BeEmpty())
+					})
+				})
+			})
+
 			Context("transport re-use", func() {
 				It("re-uses transports for the same endpoint", func() {
 					_, err := proxyRoundTripper.RoundTrip(req)
@@ -600,6 +1027,25 @@ var _ = Describe("ProxyRoundTripper", func() {
 					Expect(combinedReporter.CaptureRoutingRequestCallCount()).To(Equal(0))
 				})
 
+				Context("when route services have their own round tripper factory", func() {
+					var routeServiceTransport *roundtripperfakes.FakeProxyRoundTripper
+
+					BeforeEach(func() {
+						routeServiceTransport = new(roundtripperfakes.FakeProxyRoundTripper)
+						routeServiceTransport.RoundTripReturns(&http.Response{StatusCode: http.StatusOK}, nil)
+						routeServiceFactory = &FakeRoundTripperFactory{ReturnValue: routeServiceTransport}
+					})
+
+					It("makes requests to the route service with a round tripper from it", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(routeServiceFactory.Calls).To(Equal(1))
+						Expect(roundTripperFactory.Calls).To(Equal(0))
+						Expect(routeServiceTransport.RoundTripCallCount()).To(Equal(1))
+						Expect(transport.RoundTripCallCount()).To(Equal(0))
+					})
+				})
+
 				Context("when the route service returns a non-2xx status code", func() {
 					BeforeEach(func() {
 						transport.RoundTripReturns(
@@ -645,7 +1091,7 @@ var _ = Describe("ProxyRoundTripper", func() {
 						proxyRoundTripper.RoundTrip(req)
 						Expect(errorHandler.HandleErrorCallCount()).To(Equal(1))
 
-						_, err := errorHandler.HandleErrorArgsForCall(0)
+						_, _, err := errorHandler.HandleErrorArgsForCall(0)
 						Expect(err).To(Equal(dialError))
 					})
 
@@ -676,7 +1122,7 @@ var _ = Describe("ProxyRoundTripper", func() {
 						It("calls the error handler", func() {
 							proxyRoundTripper.RoundTrip(req)
 							Expect(errorHandler.HandleErrorCallCount()).To(Equal(1))
-							_, err := errorHandler.HandleErrorArgsForCall(0)
+							_, _, err := errorHandler.HandleErrorArgsForCall(0)
 							Expect(err).To(MatchError("banana"))
 						})
 
@@ -801,6 +1247,145 @@ var _ = Describe("ProxyRoundTripper", func() {
 				})
 			})
 
+			Context("when the route registered a timeout", func() {
+				var reqCh chan *http.Request
+
+				BeforeEach(func() {
+					timeout = 10 * time.Millisecond
+					reqCh = make(chan *http.Request, 1)
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						reqCh <- req
+						return &http.Response{}, nil
+					}
+
+					Expect(routePool.Remove(endpoint)).To(BeTrue())
+					endpoint = route.NewEndpoint(&route.EndpointOpts{
+						Host:             "1.1.1.1",
+						Port:             9090,
+						TimeoutInSeconds: 120,
+					})
+					Expect(routePool.Put(endpoint)).To(Equal(route.ADDED))
+				})
+
+				It("uses the timeout of the route instead of the endpoint timeout", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).NotTo(HaveOccurred())
+
+					var request *http.Request
+					Eventually(reqCh).Should(Receive(&request))
+					deadline, ok := request.Context().Deadline()
+					Expect(ok).To(BeTrue())
+					Expect(deadline).To(BeTemporally("~", time.Now().Add(2*time.Minute), time.Second))
+				})
+
+				Context("when the timeout of the route is above the maximum", func() {
+					BeforeEach(func() {
+						maxRouteTimeout = time.Minute
+					})
+
+					It("limits the timeout to the maximum", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+
+						var request *http.Request
+						Eventually(reqCh).Should(Receive(&request))
+						deadline, ok := request.Context().Deadline()
+						Expect(ok).To(BeTrue())
+						Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+					})
+				})
+			})
+
+			Context("when the request accepts an event stream", func() {
+				var (
+					reqCh       chan *http.Request
+					contentType string
+				)
+
+				BeforeEach(func() {
+					timeout = 10 * time.Millisecond
+					contentType = "text/event-stream; charset=utf-8"
+					reqCh = make(chan *http.Request, 1)
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						reqCh <- req
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     http.Header{"Content-Type": []string{contentType}},
+						}, nil
+					}
+					req.Header.Set("Accept", "text/event-stream")
+				})
+
+				It("does not time out the stream once the response headers arrive", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).NotTo(HaveOccurred())
+
+					var request *http.Request
+					Eventually(reqCh).Should(Receive(&request))
+					Consistently(request.Context().Err, 50*time.Millisecond).Should(BeNil())
+				})
+
+				Context("when the response is not an event stream", func() {
+					BeforeEach(func() {
+						contentType = "text/plain"
+					})
+
+					It("times out the response", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).NotTo(HaveOccurred())
+
+						var request *http.Request
+						Eventually(reqCh).Should(Receive(&request))
+						Eventually(request.Context().Err).Should(HaveOccurred())
+					})
+				})
+
+				Context("when the response headers do not arrive in time", func() {
+					BeforeEach(func() {
+						transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+							<-req.Context().Done()
+							return nil, req.Context().Err()
+						}
+						retriableClassifier.ClassifyReturns(false)
+					})
+
+					It("times out the request", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).To(MatchError(context.DeadlineExceeded))
+					})
+				})
+			})
+
+			Context("when the route is tagged as streaming", func() {
+				var reqCh chan *http.Request
+
+				BeforeEach(func() {
+					timeout = 10 * time.Millisecond
+					reqCh = make(chan *http.Request, 1)
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						reqCh <- req
+						return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+					}
+
+					Expect(routePool.Remove(endpoint)).To(BeTrue())
+					endpoint = route.NewEndpoint(&route.EndpointOpts{
+						Host: "1.1.1.1",
+						Port: 9090,
+						Tags: map[string]string{handlers.StreamingTag: "true"},
+					})
+					Expect(routePool.Put(endpoint)).To(Equal(route.ADDED))
+				})
+
+				It("does not time out the response once its headers arrive", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).NotTo(HaveOccurred())
+
+					var request *http.Request
+					Eventually(reqCh).Should(Receive(&request))
+					Consistently(request.Context().Err, 50*time.Millisecond).Should(BeNil())
+				})
+			})
+
 			Context("when endpoint timeout is not 0", func() {
 				var reqCh chan *http.Request
 				BeforeEach(func() {
@@ -904,6 +1489,165 @@ var _ = Describe("ProxyRoundTripper", func() {
 
 				})
 			})
+
+			Context("when the timeout header is configured", func() {
+				var timeouts []int
+
+				BeforeEach(func() {
+					timeoutHeader = "X-Request-Timeout-Ms"
+					timeout = 2 * time.Second
+					timeouts = nil
+
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						ms, err := strconv.Atoi(req.Header.Get("X-Request-Timeout-Ms"))
+						Expect(err).ToNot(HaveOccurred())
+						timeouts = append(timeouts, ms)
+						return &http.Response{StatusCode: http.StatusOK}, nil
+					}
+				})
+
+				It("sends the remaining time of the endpoint timeout", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(timeouts).To(HaveLen(1))
+					Expect(timeouts[0]).To(BeNumerically("<=", 2000))
+					Expect(timeouts[0]).To(BeNumerically(">", 1900))
+				})
+
+				It("replaces a timeout sent by the client", func() {
+					req.Header.Set("X-Request-Timeout-Ms", "999999")
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(timeouts[0]).To(BeNumerically("<=", 2000))
+				})
+
+				Context("when the request is retried", func() {
+					BeforeEach(func() {
+						transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+							ms, err := strconv.Atoi(req.Header.Get("X-Request-Timeout-Ms"))
+							Expect(err).ToNot(HaveOccurred())
+							timeouts = append(timeouts, ms)
+							if len(timeouts) == 1 {
+								time.Sleep(100 * time.Millisecond)
+								return nil, dialError
+							}
+							return &http.Response{StatusCode: http.StatusOK}, nil
+						}
+						retriableClassifier.ClassifyReturns(true)
+					})
+
+					It("sends the time remaining after the failed attempt", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(timeouts).To(HaveLen(2))
+						Expect(timeouts[1]).To(BeNumerically("<=", timeouts[0]-100))
+					})
+				})
+
+				Context("when there is no endpoint timeout", func() {
+					BeforeEach(func() {
+						timeout = 0
+						transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+							Expect(req.Header).ToNot(HaveKey("X-Request-Timeout-Ms"))
+							return &http.Response{StatusCode: http.StatusOK}, nil
+						}
+					})
+
+					It("removes a timeout sent by the client", func() {
+						req.Header.Set("X-Request-Timeout-Ms", "999999")
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(transport.RoundTripCallCount()).To(Equal(1))
+					})
+				})
+			})
+
+			Context("when the request is traced", func() {
+				var (
+					exporter     *tracefakes.FakeExporter
+					process      ifrit.Process
+					serverSpan   *tracing.Span
+					traceparents []string
+				)
+
+				exportedSpans := func() []*tracing.Span {
+					serverSpan.End()
+					process.Signal(os.Interrupt)
+					Eventually(process.Wait()).Should(Receive())
+					Expect(exporter.ExportCallCount()).To(Equal(1))
+					return exporter.ExportArgsForCall(0)
+				}
+
+				BeforeEach(func() {
+					exporter = new(tracefakes.FakeExporter)
+					tracer := tracing.NewTracer(exporter, logger)
+					process = ifrit.Invoke(tracer)
+
+					serverSpan = tracer.StartSpan("HTTP GET", tracing.SpanKindServer, nil)
+					reqInfo.TraceSpan = serverSpan
+
+					traceparents = nil
+					transport.RoundTripStub = func(req *http.Request) (*http.Response, error) {
+						traceparents = append(traceparents, req.Header.Get("traceparent"))
+						if len(traceparents) < 3 {
+							return nil, dialError
+						}
+						return &http.Response{StatusCode: http.StatusTeapot}, nil
+					}
+					retriableClassifier.ClassifyReturns(true)
+				})
+
+				AfterEach(func() {
+					process.Signal(os.Interrupt)
+					Eventually(process.Wait()).Should(Receive())
+				})
+
+				It("records a client span for each attempt", func() {
+					_, err := proxyRoundTripper.RoundTrip(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					spans := exportedSpans()
+					Expect(spans).To(HaveLen(4))
+					Expect(spans[3]).To(Equal(serverSpan))
+
+					for i, span := range spans[:3] {
+						Expect(span.Name()).To(Equal("backend"))
+						Expect(span.Kind()).To(Equal(tracing.SpanKindClient))
+						Expect(span.Context().TraceID).To(Equal(serverSpan.Context().TraceID))
+						Expect(span.ParentSpanID()).To(Equal(serverSpan.Context().SpanID))
+						Expect(span.Attribute("net.peer.name")).To(Equal("1.1.1.1:9090"))
+						Expect(span.Attribute("gorouter.attempt")).To(Equal(i + 1))
+						Expect(traceparents[i]).To(Equal(span.Context().TraceParent()))
+					}
+
+					Expect(spans[0].Failed()).To(BeTrue())
+					Expect(spans[1].Failed()).To(BeTrue())
+					Expect(spans[2].Failed()).To(BeFalse())
+					Expect(spans[2].Attribute("http.status_code")).To(Equal(http.StatusTeapot))
+				})
+
+				Context("when the request goes to a route service", func() {
+					BeforeEach(func() {
+						routeServiceURL, err := url.Parse("https://foo.com/path")
+						Expect(err).ToNot(HaveOccurred())
+						reqInfo.RouteServiceURL = routeServiceURL
+					})
+
+					It("records a client span for the route service", func() {
+						_, err := proxyRoundTripper.RoundTrip(req)
+						Expect(err).ToNot(HaveOccurred())
+
+						spans := exportedSpans()
+						Expect(spans).To(HaveLen(4))
+						Expect(spans[0].Name()).To(Equal("route_service"))
+						Expect(spans[0].Attribute("http.url")).To(Equal("https://foo.com/path"))
+						Expect(traceparents[0]).To(Equal(spans[0].Context().TraceParent()))
+					})
+				})
+			})
 		})
 
 		Context("CancelRequest", func() {
@@ -0,0 +1,122 @@
+package round_tripper
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/metrics"
+)
+
+// countedConn is a backend connection that counts the requests it has served,
+// and whether it is idle.
+type countedConn struct {
+	net.Conn
+	requests int64
+
+	addr      string
+	idle      int32
+	idleConns *IdleConnections
+}
+
+// setIdle marks the connection idle or in use, and counts it in idleConns.
+func (c *countedConn) setIdle(idle bool) {
+	from, to, delta := int32(1), int32(0), -1
+	if idle {
+		from, to, delta = 0, 1, 1
+	}
+	if atomic.CompareAndSwapInt32(&c.idle, from, to) {
+		c.idleConns.add(c.addr, delta)
+	}
+}
+
+func (c *countedConn) Close() error {
+	c.setIdle(false)
+	return c.Conn.Close()
+}
+
+// netConner is implemented by connections that wrap another, such as
+// *tls.Conn.
+type netConner interface {
+	NetConn() net.Conn
+}
+
+func countedConnFrom(c net.Conn) *countedConn {
+	for c != nil {
+		if cc, ok := c.(*countedConn); ok {
+			return cc
+		}
+		nc, ok := c.(netConner)
+		if !ok {
+			return nil
+		}
+		c = nc.NetConn()
+	}
+	return nil
+}
+
+type dialFunc func(network, addr string) (net.Conn, error)
+
+// countingDial returns connections that count their requests and are counted
+// in idleConns while idle, and reports each connection that is opened and how
+// long it took to dial.
+func countingDial(dial dialFunc, reporter metrics.ProxyReporter, idleConns *IdleConnections) dialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).Dial
+	}
+	return func(network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if reporter != nil {
+			reporter.CaptureBackendConnectionOpened()
+			reporter.CaptureBackendDialTime(time.Since(start))
+		}
+		return &countedConn{Conn: conn, addr: addr, idleConns: idleConns}, nil
+	}
+}
+
+// NewRecyclingRoundTripper returns a round tripper that asks the backend to
+// close a connection with the request that reaches maxRequests on it, so
+// that the connection is not returned to the idle pool.
+func NewRecyclingRoundTripper(p ProxyRoundTripper, maxRequests int) ProxyRoundTripper {
+	return &recyclingRoundTripper{
+		p:           p,
+		maxRequests: int64(maxRequests),
+	}
+}
+
+type recyclingRoundTripper struct {
+	p           ProxyRoundTripper
+	maxRequests int64
+}
+
+func (r *recyclingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The header is copied since the request is reused when retrying another
+	// endpoint, which may be on a connection that is not being recycled.
+	header := make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		header[k] = v
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn := countedConnFrom(info.Conn)
+			if conn != nil && atomic.AddInt64(&conn.requests, 1) >= r.maxRequests {
+				header.Set("Connection", "close")
+			}
+		},
+	}
+
+	outreq := req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	outreq.Header = header
+	return r.p.RoundTrip(outreq)
+}
+
+func (r *recyclingRoundTripper) CancelRequest(req *http.Request) {
+	r.p.CancelRequest(req)
+}
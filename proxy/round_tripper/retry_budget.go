@@ -0,0 +1,89 @@
+package round_tripper
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+// retryBudgetWindow is the number of seconds over which requests and retries
+// are counted.
+const retryBudgetWindow = 10
+
+type retryBudgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+// retryBudget counts requests and retries per second over the last
+// retryBudgetWindow seconds. The methods of a nil retryBudget allow every
+// retry.
+type retryBudget struct {
+	lock       sync.Mutex
+	percent    float64
+	minRetries int
+	buckets    [retryBudgetWindow]retryBudgetBucket
+}
+
+func newRetryBudget(cfg config.RetryBudgetConfig) *retryBudget {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	return &retryBudget{
+		percent:    cfg.Percent,
+		minRetries: cfg.MinRetriesPerSecond * retryBudgetWindow,
+	}
+}
+
+// request records a request, which adds to the retries that are allowed.
+func (b *retryBudget) request() {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.bucket(time.Now()).requests++
+}
+
+// withdraw reports whether a retry is within the budget, and records it if
+// it is.
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	var requests, retries int
+	for _, bucket := range b.buckets {
+		if now.Unix()-bucket.second < retryBudgetWindow {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	allowed := float64(b.minRetries) + b.percent/100*float64(requests)
+	if float64(retries+1) > allowed {
+		return false
+	}
+
+	b.bucket(now).retries++
+	return true
+}
+
+// bucket returns the bucket of the second now is in, which is reset when it
+// last counted an earlier second. The caller must hold the lock.
+func (b *retryBudget) bucket(now time.Time) *retryBudgetBucket {
+	second := now.Unix()
+	bucket := &b.buckets[second%retryBudgetWindow]
+	if bucket.second != second {
+		*bucket = retryBudgetBucket{second: second}
+	}
+	return bucket
+}
@@ -0,0 +1,39 @@
+package round_tripper
+
+import (
+	"sync"
+	"time"
+)
+
+// Timeouts are the endpoint timeout and the bound on the timeouts that routes
+// register. They can be updated while requests are proxied, and requests
+// that start after that use the new timeouts.
+type Timeouts struct {
+	mu       sync.RWMutex
+	endpoint time.Duration
+	maxRoute time.Duration
+}
+
+func NewTimeouts(endpoint, maxRoute time.Duration) *Timeouts {
+	return &Timeouts{endpoint: endpoint, maxRoute: maxRoute}
+}
+
+// Update replaces the timeouts.
+func (t *Timeouts) Update(endpoint, maxRoute time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.endpoint = endpoint
+	t.maxRoute = maxRoute
+}
+
+func (t *Timeouts) Endpoint() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.endpoint
+}
+
+func (t *Timeouts) MaxRoute() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.maxRoute
+}
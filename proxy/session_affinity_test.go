@@ -1,9 +1,12 @@
 package proxy_test
 
 import (
+	"fmt"
+	"net"
 	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/proxy"
 	"code.cloudfoundry.org/gorouter/test_util"
 
@@ -147,6 +150,93 @@ var _ = Describe("Session Affinity", func() {
 
 	})
 
+	Context("consistent hashing", func() {
+		respondWithInstance := func(id string) func(*test_util.HttpConn) {
+			return func(x *test_util.HttpConn) {
+				_, err := http.ReadRequest(x.Reader)
+				Expect(err).ToNot(HaveOccurred())
+
+				resp := test_util.NewResponse(http.StatusOK)
+				resp.Header.Set("X-Instance", id)
+				x.WriteResponse(resp)
+				x.Close()
+			}
+		}
+
+		instanceFor := func(header, value string) string {
+			conn := dialProxy(proxyServer)
+			defer conn.Close()
+			req := test_util.NewRequest("GET", "app", "/", nil)
+			if header != "" {
+				req.Header.Set(header, value)
+			}
+			conn.WriteRequest(req)
+
+			resp, _ := conn.ReadResponse()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			return resp.Header.Get("X-Instance")
+		}
+
+		BeforeEach(func() {
+			conf.LoadBalance = config.LOAD_BALANCE_CH
+			conf.ConsistentHash = config.ConsistentHashConfig{Header: "X-Tenant"}
+		})
+
+		It("sends requests with the same key to the same instance", func() {
+			for _, id := range []string{"instance-id-1", "instance-id-2", "instance-id-3"} {
+				ln := test_util.RegisterHandler(r, "app", respondWithInstance(id), test_util.RegisterConfig{InstanceId: id})
+				defer ln.Close()
+			}
+
+			for _, tenant := range []string{"acme", "initech", "globex"} {
+				expected := instanceFor("X-Tenant", tenant)
+				for i := 0; i < 5; i++ {
+					Expect(instanceFor("X-Tenant", tenant)).To(Equal(expected))
+				}
+			}
+		})
+
+		It("balances requests without the key", func() {
+			for _, id := range []string{"instance-id-1", "instance-id-2"} {
+				ln := test_util.RegisterHandler(r, "app", respondWithInstance(id), test_util.RegisterConfig{InstanceId: id})
+				defer ln.Close()
+			}
+
+			instances := map[string]bool{}
+			for i := 0; i < 10; i++ {
+				instances[instanceFor("", "")] = true
+			}
+			Expect(instances).To(HaveLen(2))
+		})
+
+		Context("by client IP behind a trusted proxy", func() {
+			BeforeEach(func() {
+				_, trustedNet, err := net.ParseCIDR("127.0.0.0/8")
+				Expect(err).ToNot(HaveOccurred())
+				conf.TrustedProxyNets = []*net.IPNet{trustedNet}
+				conf.ConsistentHash = config.ConsistentHashConfig{ClientIP: true}
+			})
+
+			It("sends requests from the same client to the same instance", func() {
+				for _, id := range []string{"instance-id-1", "instance-id-2", "instance-id-3"} {
+					ln := test_util.RegisterHandler(r, "app", respondWithInstance(id), test_util.RegisterConfig{InstanceId: id})
+					defer ln.Close()
+				}
+
+				instances := map[string]bool{}
+				for i := 1; i <= 20; i++ {
+					client := fmt.Sprintf("10.0.0.%d", i)
+					expected := instanceFor("X-Forwarded-For", client)
+					for j := 0; j < 3; j++ {
+						Expect(instanceFor("X-Forwarded-For", client)).To(Equal(expected))
+					}
+					instances[expected] = true
+				}
+				Expect(len(instances)).To(BeNumerically(">", 1))
+			})
+		})
+	})
+
 	Context("first request", func() {
 		Context("when the response does not contain a JESSIONID cookie", func() {
 			It("does not respond with a VCAP_ID cookie", func() {
@@ -243,6 +333,34 @@ var _ = Describe("Session Affinity", func() {
 					Expect(cookie.Expires).To(BeZero())
 				})
 			})
+
+			Context("behind a trusted terminating proxy that received https", func() {
+				BeforeEach(func() {
+					_, trustedNet, err := net.ParseCIDR("127.0.0.0/8")
+					Expect(err).ToNot(HaveOccurred())
+					conf.SanitizeForwardedProto = true
+					conf.TerminatingProxy.Enabled = true
+					conf.TrustedProxyNets = []*net.IPNet{trustedNet}
+					jSessionIdCookie.Secure = false
+				})
+
+				It("marks the cookie as secure", func() {
+					ln := test_util.RegisterHandler(r, "app", responseWithJSessionID, test_util.RegisterConfig{InstanceId: "my-id"})
+					defer ln.Close()
+
+					x := dialProxy(proxyServer)
+					req := test_util.NewRequest("GET", "app", "/", nil)
+					req.Header.Set("X-Forwarded-Proto", "https")
+					x.WriteRequest(req)
+
+					Eventually(done).Should(Receive())
+
+					resp, _ := x.ReadResponse()
+					cookie := getCookie(proxy.VcapCookieId, resp.Cookies())
+					Expect(cookie).ToNot(BeNil())
+					Expect(cookie.Secure).To(BeTrue())
+				})
+			})
 		})
 	})
 
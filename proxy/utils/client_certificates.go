@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// ClientCertificates are the client certificates that the router presents to
+// backends: a default one, and ones that route registrations select by name.
+// They can be replaced while the router runs, and handshakes after that
+// present the new certificates.
+type ClientCertificates struct {
+	mu          sync.RWMutex
+	defaultCert tls.Certificate
+	named       map[string]tls.Certificate
+}
+
+func NewClientCertificates(defaultCert tls.Certificate, named map[string]tls.Certificate) *ClientCertificates {
+	return &ClientCertificates{
+		defaultCert: defaultCert,
+		named:       named,
+	}
+}
+
+// Update replaces the certificates.
+func (c *ClientCertificates) Update(defaultCert tls.Certificate, named map[string]tls.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.defaultCert = defaultCert
+	c.named = named
+}
+
+// TLSConfig returns a copy of template that presents the client certificate
+// with the given name, or the default certificate when there is no
+// certificate with that name, as of the handshake. Without certificates, it
+// returns template.
+func (c *ClientCertificates) TLSConfig(name string, template *tls.Config) *tls.Config {
+	if c == nil {
+		return template
+	}
+
+	tlsConfig := TLSConfigWithServerName(template.ServerName, template)
+	tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return c.certificate(name), nil
+	}
+	return tlsConfig
+}
+
+func (c *ClientCertificates) certificate(name string) *tls.Certificate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cert, ok := c.named[name]
+	if name == "" || !ok {
+		cert = c.defaultCert
+	}
+	return &cert
+}
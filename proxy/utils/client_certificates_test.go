@@ -0,0 +1,57 @@
+package utils_test
+
+import (
+	"crypto/tls"
+
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ClientCertificates", func() {
+	var (
+		defaultCert, namedCert tls.Certificate
+		template               *tls.Config
+		certs                  *utils.ClientCertificates
+	)
+
+	clientCertificate := func(tlsConfig *tls.Config) tls.Certificate {
+		cert, err := tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+		Expect(err).ToNot(HaveOccurred())
+		return *cert
+	}
+
+	BeforeEach(func() {
+		defaultCertChain := test_util.CreateSignedCertWithRootCA(test_util.CertNames{CommonName: "default"})
+		defaultCert = defaultCertChain.TLSCert()
+		namedCertChain := test_util.CreateSignedCertWithRootCA(test_util.CertNames{CommonName: "named"})
+		namedCert = namedCertChain.TLSCert()
+		template = &tls.Config{ServerName: "backend.example.com"}
+		certs = utils.NewClientCertificates(defaultCert, map[string]tls.Certificate{"cert-a": namedCert})
+	})
+
+	It("presents the certificate with the given name", func() {
+		tlsConfig := certs.TLSConfig("cert-a", template)
+		Expect(tlsConfig.ServerName).To(Equal("backend.example.com"))
+		Expect(clientCertificate(tlsConfig)).To(Equal(namedCert))
+	})
+
+	It("presents the default certificate when there is none with the given name", func() {
+		Expect(clientCertificate(certs.TLSConfig("", template))).To(Equal(defaultCert))
+		Expect(clientCertificate(certs.TLSConfig("cert-b", template))).To(Equal(defaultCert))
+	})
+
+	It("presents the certificates it is updated with", func() {
+		tlsConfig := certs.TLSConfig("cert-a", template)
+
+		certs.Update(namedCert, map[string]tls.Certificate{"cert-a": defaultCert})
+		Expect(clientCertificate(tlsConfig)).To(Equal(defaultCert))
+		Expect(clientCertificate(certs.TLSConfig("", template))).To(Equal(namedCert))
+	})
+
+	It("returns the template without certificates", func() {
+		var noCerts *utils.ClientCertificates
+		Expect(noCerts.TLSConfig("cert-a", template)).To(BeIdenticalTo(template))
+	})
+})
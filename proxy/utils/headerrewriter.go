@@ -34,3 +34,29 @@ func (i *RemoveHeaderRewriter) RewriteHeader(header http.Header) {
 		header.Del(h)
 	}
 }
+
+// SetHeaderRewriter: Replaces the values of the headers in the current
+// http.Header with its own.
+// The http.Header must be built using the method Add() to canonalize the keys
+type SetHeaderRewriter struct {
+	Header http.Header
+}
+
+func (i *SetHeaderRewriter) RewriteHeader(header http.Header) {
+	for h, v := range i.Header {
+		header[h] = append([]string(nil), v...)
+	}
+}
+
+// AddHeaderRewriter: Appends the values of its headers to those in the
+// current http.Header.
+// The http.Header must be built using the method Add() to canonalize the keys
+type AddHeaderRewriter struct {
+	Header http.Header
+}
+
+func (i *AddHeaderRewriter) RewriteHeader(header http.Header) {
+	for h, v := range i.Header {
+		header[h] = append(header[h], v...)
+	}
+}
@@ -103,3 +103,42 @@ var _ = Describe("RemoveHeaderRewriter", func() {
 		Expect(header.Get("x-foobar")).To(BeEmpty())
 	})
 })
+
+var _ = Describe("SetHeaderRewriter", func() {
+	It("replaces the values of headers that are present and adds the others", func() {
+		header := http.Header{}
+		header.Add("foo1", "bar1")
+		header.Add("foo1", "bar2")
+		header.Add("foo2", "bar1")
+
+		headerToSet := http.Header{}
+		headerToSet.Add("foo1", "baz")
+		headerToSet.Add("foo3", "baz")
+
+		rewriter := utils.SetHeaderRewriter{Header: headerToSet}
+
+		rewriter.RewriteHeader(header)
+
+		Expect(header["Foo1"]).To(ConsistOf("baz"))
+		Expect(header["Foo2"]).To(ConsistOf("bar1"))
+		Expect(header["Foo3"]).To(ConsistOf("baz"))
+	})
+})
+
+var _ = Describe("AddHeaderRewriter", func() {
+	It("appends values to headers that are present and adds the others", func() {
+		header := http.Header{}
+		header.Add("foo1", "bar1")
+
+		headerToAdd := http.Header{}
+		headerToAdd.Add("foo1", "baz")
+		headerToAdd.Add("foo2", "baz")
+
+		rewriter := utils.AddHeaderRewriter{Header: headerToAdd}
+
+		rewriter.RewriteHeader(header)
+
+		Expect(header["Foo1"]).To(Equal([]string{"bar1", "baz"}))
+		Expect(header["Foo2"]).To(ConsistOf("baz"))
+	})
+})
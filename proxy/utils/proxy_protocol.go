@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ProxyProtocolAddrs returns the addresses of the client connection that
+// request arrived on: the client's and the one of the router it connected to.
+// Either is nil when it is not known.
+func ProxyProtocolAddrs(request *http.Request) (src, dst *net.TCPAddr) {
+	host, port, err := net.SplitHostPort(request.RemoteAddr)
+	if err == nil {
+		src = tcpAddr(net.ParseIP(host), port)
+	}
+
+	if addr, ok := request.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		host, port, err = net.SplitHostPort(addr.String())
+		if err == nil {
+			dst = tcpAddr(net.ParseIP(host), port)
+		}
+	}
+	return src, dst
+}
+
+func tcpAddr(ip net.IP, port string) *net.TCPAddr {
+	p, err := strconv.ParseUint(port, 10, 16)
+	if ip == nil || err != nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}
+}
+
+// WriteProxyProtocolHeader writes a PROXY protocol header of the given
+// version, v1 or v2, for a connection from src to dst. When either address is
+// not known the header says so, and the backend uses the address of the
+// connection from the router instead.
+func WriteProxyProtocolHeader(w io.Writer, version string, src, dst *net.TCPAddr) error {
+	var header []byte
+	switch version {
+	case "v1":
+		header = proxyProtocolV1Header(src, dst)
+	case "v2":
+		header = proxyProtocolV2Header(src, dst)
+	default:
+		return fmt.Errorf("unknown PROXY protocol version: %q", version)
+	}
+
+	_, err := w.Write(header)
+	return err
+}
+
+func proxyProtocolV1Header(src, dst *net.TCPAddr) []byte {
+	if src == nil || dst == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	if src.IP.To4() != nil && dst.IP.To4() != nil {
+		return []byte(fmt.Sprintf("PROXY TCP4 %s %s %d %d\r\n", src.IP, dst.IP, src.Port, dst.Port))
+	}
+	return []byte(fmt.Sprintf("PROXY TCP6 %s %s %d %d\r\n", ipv6String(src.IP), ipv6String(dst.IP), src.Port, dst.Port))
+}
+
+// ipv6String formats ip as an IPv6 address, mapping IPv4 addresses into
+// ::ffff:0:0/96, which net.IP.String would format as IPv4.
+func ipv6String(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return "::ffff:" + ip4.String()
+	}
+	return ip.String()
+}
+
+func proxyProtocolV2Header(src, dst *net.TCPAddr) []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	if src == nil || dst == nil {
+		// LOCAL command, with no addresses
+		return append(header, 0x20, 0x00, 0, 0)
+	}
+
+	// PROXY command over TCP, with IPv4 or IPv6 addresses
+	family := byte(0x11)
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP == nil || dstIP == nil {
+		family = 0x21
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+	}
+
+	length := 2*len(srcIP) + 4
+	header = append(header, 0x21, family, byte(length>>8), byte(length))
+	header = append(header, srcIP...)
+	header = append(header, dstIP...)
+	header = binary.BigEndian.AppendUint16(header, uint16(src.Port))
+	return binary.BigEndian.AppendUint16(header, uint16(dst.Port))
+}
@@ -0,0 +1,103 @@
+package utils_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProxyProtocolAddrs", func() {
+	It("returns the addresses of the client connection", func() {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = "10.0.0.1:54321"
+		local := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 443}
+		req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, local))
+
+		src, dst := utils.ProxyProtocolAddrs(req)
+		Expect(src.String()).To(Equal("10.0.0.1:54321"))
+		Expect(dst.String()).To(Equal("10.0.0.2:443"))
+	})
+
+	It("returns nil for addresses that are not known", func() {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = "@"
+
+		src, dst := utils.ProxyProtocolAddrs(req)
+		Expect(src).To(BeNil())
+		Expect(dst).To(BeNil())
+	})
+})
+
+var _ = Describe("WriteProxyProtocolHeader", func() {
+	var (
+		buf      *bytes.Buffer
+		src, dst *net.TCPAddr
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		src = &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 54321}
+		dst = &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 443}
+	})
+
+	Context("v1", func() {
+		It("writes the addresses of IPv4 connections", func() {
+			Expect(utils.WriteProxyProtocolHeader(buf, "v1", src, dst)).To(Succeed())
+			Expect(buf.String()).To(Equal("PROXY TCP4 10.0.0.1 10.0.0.2 54321 443\r\n"))
+		})
+
+		It("writes the addresses of IPv6 connections", func() {
+			src.IP = net.ParseIP("2001:db8::1")
+
+			Expect(utils.WriteProxyProtocolHeader(buf, "v1", src, dst)).To(Succeed())
+			Expect(buf.String()).To(Equal("PROXY TCP6 2001:db8::1 ::ffff:10.0.0.2 54321 443\r\n"))
+		})
+
+		It("writes UNKNOWN when an address is not known", func() {
+			Expect(utils.WriteProxyProtocolHeader(buf, "v1", nil, dst)).To(Succeed())
+			Expect(buf.String()).To(Equal("PROXY UNKNOWN\r\n"))
+		})
+	})
+
+	Context("v2", func() {
+		signature := []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+		It("writes the addresses of IPv4 connections", func() {
+			Expect(utils.WriteProxyProtocolHeader(buf, "v2", src, dst)).To(Succeed())
+
+			expected := append([]byte{}, signature...)
+			expected = append(expected, 0x21, 0x11, 0, 12)
+			expected = append(expected, 10, 0, 0, 1, 10, 0, 0, 2, 0xd4, 0x31, 0x01, 0xbb)
+			Expect(buf.Bytes()).To(Equal(expected))
+		})
+
+		It("writes the addresses of IPv6 connections", func() {
+			src.IP = net.ParseIP("2001:db8::1")
+
+			Expect(utils.WriteProxyProtocolHeader(buf, "v2", src, dst)).To(Succeed())
+
+			expected := append([]byte{}, signature...)
+			expected = append(expected, 0x21, 0x21, 0, 36)
+			expected = append(expected, net.ParseIP("2001:db8::1")...)
+			expected = append(expected, net.ParseIP("10.0.0.2").To16()...)
+			expected = append(expected, 0xd4, 0x31, 0x01, 0xbb)
+			Expect(buf.Bytes()).To(Equal(expected))
+		})
+
+		It("writes a LOCAL header when an address is not known", func() {
+			Expect(utils.WriteProxyProtocolHeader(buf, "v2", src, nil)).To(Succeed())
+			Expect(buf.Bytes()).To(Equal(append(append([]byte{}, signature...), 0x20, 0x00, 0, 0)))
+		})
+	})
+
+	It("returns an error for unknown versions", func() {
+		Expect(utils.WriteProxyProtocolHeader(buf, "v3", src, dst)).To(MatchError(`unknown PROXY protocol version: "v3"`))
+		Expect(buf.Len()).To(BeZero())
+	})
+})
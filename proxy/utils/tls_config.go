@@ -5,9 +5,13 @@ import "crypto/tls"
 func TLSConfigWithServerName(newServerName string, template *tls.Config) *tls.Config {
 	return &tls.Config{
 		CipherSuites:       template.CipherSuites,
+		MinVersion:         template.MinVersion,
+		MaxVersion:         template.MaxVersion,
 		InsecureSkipVerify: template.InsecureSkipVerify,
 		RootCAs:            template.RootCAs,
 		ServerName:         newServerName,
 		Certificates:       template.Certificates,
+
+		GetClientCertificate: template.GetClientCertificate,
 	}
 }
@@ -0,0 +1,24 @@
+package utils_test
+
+import (
+	"crypto/tls"
+
+	"code.cloudfoundry.org/gorouter/proxy/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLSConfigWithServerName", func() {
+	It("keeps the TLS versions of the template", func() {
+		template := &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			MaxVersion: tls.VersionTLS13,
+			ServerName: "old.example.com",
+		}
+
+		tlsConfig := utils.TLSConfigWithServerName("new.example.com", template)
+		Expect(tlsConfig.ServerName).To(Equal("new.example.com"))
+		Expect(tlsConfig.MinVersion).To(Equal(uint16(tls.VersionTLS12)))
+		Expect(tlsConfig.MaxVersion).To(Equal(uint16(tls.VersionTLS13)))
+	})
+})
@@ -0,0 +1,16 @@
+// +build grpc
+
+package main
+
+import (
+	"code.cloudfoundry.org/gorouter/config"
+	goRouterLogger "code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/mbus"
+	rregistry "code.cloudfoundry.org/gorouter/registry"
+	"github.com/tedsuo/ifrit/grouper"
+)
+
+func routeRegistrationServerMember(registry rregistry.Registry, c *config.Config, logger goRouterLogger.Logger) grouper.Member {
+	registrationServer := mbus.NewRouteRegistrationServer(registry, c, logger.Session("route-registration-grpc"))
+	return grouper.Member{Name: "routeRegistrationServer", Runner: registrationServer}
+}
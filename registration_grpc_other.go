@@ -0,0 +1,20 @@
+// +build !grpc
+
+package main
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/gorouter/config"
+	goRouterLogger "code.cloudfoundry.org/gorouter/logger"
+	rregistry "code.cloudfoundry.org/gorouter/registry"
+	"github.com/tedsuo/ifrit/grouper"
+	"github.com/uber-go/zap"
+)
+
+// routeRegistrationServerMember fails, since the gRPC route registration
+// server and its dependencies are only built with the grpc tag.
+func routeRegistrationServerMember(registry rregistry.Registry, c *config.Config, logger goRouterLogger.Logger) grouper.Member {
+	logger.Fatal("route-registration-grpc-error", zap.Error(errors.New("status.registration_grpc_port requires gorouter to be built with -tags grpc")))
+	return grouper.Member{}
+}
@@ -0,0 +1,96 @@
+package container
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+const snapshotShards = 256
+
+// Snapshot is a copy-on-write view of the pools in a Trie that can be read
+// without locking. Routes are sharded by host, so a change copies only the
+// routes sharing a shard with it, and every prefix of a URI is found in the
+// same immutable shard.
+//
+// Readers may call MatchUri concurrently with writers. Calls to Set and
+// Delete must be serialized by the caller.
+type Snapshot struct {
+	shards [snapshotShards]atomic.Value
+}
+
+type snapshotShard map[string]*route.Pool
+
+func NewSnapshot() *Snapshot {
+	s := &Snapshot{}
+	for i := range s.shards {
+		s.shards[i].Store(snapshotShard{})
+	}
+	return s
+}
+
+// Set publishes the pool for the URI, replacing any pool it had.
+func (s *Snapshot) Set(uri route.Uri, pool *route.Pool) {
+	key := snapshotKey(uri)
+	shard := s.shard(key)
+	old := shard.Load().(snapshotShard)
+
+	next := make(snapshotShard, len(old)+1)
+	for k, v := range old {
+		next[k] = v
+	}
+	next[key] = pool
+	shard.Store(next)
+}
+
+// Delete removes the pool for the URI.
+func (s *Snapshot) Delete(uri route.Uri) {
+	key := snapshotKey(uri)
+	shard := s.shard(key)
+	old := shard.Load().(snapshotShard)
+	if _, ok := old[key]; !ok {
+		return
+	}
+
+	next := make(snapshotShard, len(old))
+	for k, v := range old {
+		if k != key {
+			next[k] = v
+		}
+	}
+	shard.Store(next)
+}
+
+// MatchUri returns the longest route that matches the URI parameter, nil if
+// nothing matches. Matching follows the same rules as Trie.MatchUri.
+func (s *Snapshot) MatchUri(uri route.Uri) *route.Pool {
+	key := snapshotKey(uri)
+	routes := s.shard(key).Load().(snapshotShard)
+
+	for {
+		if pool, ok := routes[key]; ok {
+			return pool
+		}
+		i := strings.LastIndexByte(key, '/')
+		if i < 0 {
+			return nil
+		}
+		key = key[:i]
+	}
+}
+
+func (s *Snapshot) shard(key string) *atomic.Value {
+	host := key
+	if i := strings.IndexByte(key, '/'); i >= 0 {
+		host = key[:i]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	return &s.shards[h.Sum32()%snapshotShards]
+}
+
+func snapshotKey(uri route.Uri) string {
+	return strings.TrimPrefix(uri.String(), "/")
+}
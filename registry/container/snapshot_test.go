@@ -0,0 +1,104 @@
+package container_test
+
+import (
+	"fmt"
+	"sync"
+
+	"code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/registry/container"
+	"code.cloudfoundry.org/gorouter/route"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		s      *container.Snapshot
+		p1, p2 *route.Pool
+	)
+
+	newPool := func(host, contextPath string) *route.Pool {
+		return route.NewPool(&route.PoolOpts{
+			Logger:      new(fakes.FakeLogger),
+			Host:        host,
+			ContextPath: contextPath,
+		})
+	}
+
+	BeforeEach(func() {
+		s = container.NewSnapshot()
+		p1 = newPool("foo", "/")
+		p2 = newPool("foo", "/bar")
+	})
+
+	It("matches the longest route on segment boundaries", func() {
+		s.Set("foo", p1)
+		s.Set("foo/bar", p2)
+
+		Expect(s.MatchUri("foo")).To(Equal(p1))
+		Expect(s.MatchUri("/foo/")).To(Equal(p1))
+		Expect(s.MatchUri("foo/barbaz")).To(Equal(p1))
+		Expect(s.MatchUri("foo/bar")).To(Equal(p2))
+		Expect(s.MatchUri("foo/bar/baz")).To(Equal(p2))
+		Expect(s.MatchUri("bar")).To(BeNil())
+	})
+
+	It("replaces and deletes routes", func() {
+		s.Set("foo", p1)
+		s.Set("foo", p2)
+		Expect(s.MatchUri("foo")).To(Equal(p2))
+
+		s.Delete("foo")
+		Expect(s.MatchUri("foo")).To(BeNil())
+
+		s.Delete("foo")
+		Expect(s.MatchUri("foo")).To(BeNil())
+	})
+
+	It("matches the same routes as the trie", func() {
+		trie := container.NewTrie()
+		for _, uri := range []route.Uri{"foo", "foo/bar", "foo//baz", "foo/bar/baz/qux", "bar.com/a"} {
+			pool := newPool(string(uri), "/")
+			trie.Insert(uri, pool)
+			s.Set(uri, pool)
+		}
+
+		for _, uri := range []route.Uri{"foo", "foo/", "foo/bar/baz", "foo//baz/x", "foo/bar/baz/qux/quux", "bar.com", "bar.com/a/b", "baz"} {
+			Expect(s.MatchUri(uri)).To(Equal(trie.MatchUri(uri)), string(uri))
+		}
+	})
+
+	It("keeps matching unchanged routes while other routes are changed", func() {
+		s.Set("foo", p1)
+		s.Set("foo/bar", p2)
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				uri := route.Uri(fmt.Sprintf("foo/bar/%d", i%10))
+				if i%2 == 0 {
+					s.Set(uri, newPool("foo", "/bar"))
+				} else {
+					s.Delete(uri)
+				}
+				s.Set(route.Uri(fmt.Sprintf("host-%d", i%100)), p1)
+			}
+		}()
+
+		for i := 0; i < 10000; i++ {
+			Expect(s.MatchUri("foo/baz")).To(BeIdenticalTo(p1))
+			Expect(s.MatchUri("foo/bar/x")).To(BeIdenticalTo(p2))
+		}
+		close(done)
+		wg.Wait()
+	})
+})
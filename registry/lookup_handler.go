@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+type routeLookup struct {
+	URI                string            `json:"uri"`
+	Route              string            `json:"route"`
+	ContextPath        string            `json:"context_path"`
+	Wildcard           bool              `json:"wildcard"`
+	Matched            bool              `json:"matched"`
+	BalancingAlgorithm string            `json:"balancing_algorithm"`
+	StickyPathSegment  string            `json:"sticky_path_segment,omitempty"`
+	StickyEndpoint     *route.Endpoint   `json:"sticky_endpoint,omitempty"`
+	Endpoints          []*route.Endpoint `json:"endpoints"`
+}
+
+// LookupHandler serves the route that a request to the host and path query
+// parameters would be sent to, to find out why requests reach an app: the
+// route it matched, whether by a wildcard or a context path, how endpoints are
+// selected, and the endpoints themselves. The method, header ("Name: Value",
+// repeatable) and app_instance ("app_id:index", as in X-CF-APP-INSTANCE)
+// parameters stand in for those of the request, and instance_id for its
+// __VCAP_ID__ cookie, whose endpoint is returned as sticky_endpoint if it is
+// one of the endpoints. It responds with 404 if there is no such route.
+func LookupHandler(r *RouteRegistry, defaultLoadBalance string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		target, err := lookupRequest(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		uri := route.Uri(strings.Split(target.Host, ":")[0] + target.URL.EscapedPath()).RouteKey()
+		var pool *route.Pool
+		if appInstance := req.URL.Query().Get("app_instance"); appInstance != "" {
+			parts := strings.Split(appInstance, ":")
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				http.Error(w, fmt.Sprintf("invalid app_instance: %q", appInstance), http.StatusBadRequest)
+				return
+			}
+			pool = r.LookupWithInstance(uri, parts[0], parts[1])
+		} else {
+			pool = r.lookup(uri)
+		}
+
+		var chosen *route.Pool
+		if pool != nil {
+			chosen = pool.ForRequest(target)
+		}
+		if chosen == nil {
+			http.Error(w, fmt.Sprintf("no route for %s", uri), http.StatusNotFound)
+			return
+		}
+
+		lookup := routeLookup{
+			URI:                string(uri),
+			Route:              chosen.Host(),
+			ContextPath:        chosen.ContextPath(),
+			Wildcard:           strings.HasPrefix(chosen.Host(), "*."),
+			Matched:            pool.Matched(),
+			BalancingAlgorithm: chosen.BalancingAlgorithm(defaultLoadBalance),
+			StickyPathSegment:  chosen.StickyPathSegment(),
+			Endpoints:          []*route.Endpoint{},
+		}
+		if lookup.ContextPath != "/" {
+			lookup.Route += lookup.ContextPath
+		}
+		id := req.URL.Query().Get("instance_id")
+		chosen.EachOwn(func(e *route.Endpoint) {
+			lookup.Endpoints = append(lookup.Endpoints, e)
+			if id != "" && e.PrivateInstanceId == id {
+				lookup.StickyEndpoint = e
+			}
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lookup)
+	})
+}
+
+// lookupRequest returns the request described by the query parameters of
+// the lookup.
+func lookupRequest(req *http.Request) (*http.Request, error) {
+	values := req.URL.Query()
+	host := values.Get("host")
+	if host == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+	path := values.Get("path")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	method := values.Get("method")
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	target, err := http.NewRequest(method, "http://"+host+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %s", err)
+	}
+	for _, header := range values["header"] {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header: %q", header)
+		}
+		target.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return target, nil
+}
@@ -0,0 +1,138 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LookupHandler", func() {
+	type lookup struct {
+		URI                string                   `json:"uri"`
+		Route              string                   `json:"route"`
+		ContextPath        string                   `json:"context_path"`
+		Wildcard           bool                     `json:"wildcard"`
+		Matched            bool                     `json:"matched"`
+		BalancingAlgorithm string                   `json:"balancing_algorithm"`
+		StickyPathSegment  string                   `json:"sticky_path_segment"`
+		StickyEndpoint     map[string]interface{}   `json:"sticky_endpoint"`
+		Endpoints          []map[string]interface{} `json:"endpoints"`
+	}
+
+	var (
+		r       *RouteRegistry
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		cfg, err := config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+		r = NewRouteRegistry(test_util.NewTestZapLogger("test"), cfg, new(fakes.FakeRouteRegistryReporter))
+		handler = LookupHandler(r, config.LOAD_BALANCE_RR)
+
+		r.Register("app.example.com", route.NewEndpoint(&route.EndpointOpts{
+			AppId: "app", Host: "10.0.0.1", Port: 8080, PrivateInstanceId: "instance-0", PrivateInstanceIndex: "0",
+		}))
+		r.Register("app.example.com", route.NewEndpoint(&route.EndpointOpts{
+			AppId: "app", Host: "10.0.0.2", Port: 8080, PrivateInstanceId: "instance-1", PrivateInstanceIndex: "1",
+		}))
+		r.Register("app.example.com/api", route.NewEndpoint(&route.EndpointOpts{
+			Host: "10.0.0.3", Port: 8080, Tags: map[string]string{route.StickyPathSegmentTag: "1"},
+		}))
+		r.Register("*.example.com", route.NewEndpoint(&route.EndpointOpts{
+			Host: "10.0.0.4", Port: 8080, BalancingAlgorithm: config.LOAD_BALANCE_LC,
+		}))
+		r.Register("match.example.com", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.5", Port: 8080}))
+		r.Register("match.example.com", route.NewEndpoint(&route.EndpointOpts{
+			Host: "10.0.0.6", Port: 8080, MatchMethods: []string{"POST"},
+		}))
+	})
+
+	get := func(query string) (*httptest.ResponseRecorder, lookup) {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/routes/lookup"+query, nil))
+
+		var l lookup
+		if rw.Code == http.StatusOK {
+			Expect(json.Unmarshal(rw.Body.Bytes(), &l)).To(Succeed())
+		}
+		return rw, l
+	}
+
+	addresses := func(l lookup) []interface{} {
+		var addrs []interface{}
+		for _, e := range l.Endpoints {
+			addrs = append(addrs, e["address"])
+		}
+		return addrs
+	}
+
+	It("explains an exact match", func() {
+		rw, l := get("?host=App.example.com:443&path=/home")
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(l.URI).To(Equal("app.example.com/home"))
+		Expect(l.Route).To(Equal("app.example.com"))
+		Expect(l.ContextPath).To(Equal("/"))
+		Expect(l.Wildcard).To(BeFalse())
+		Expect(l.BalancingAlgorithm).To(Equal(config.LOAD_BALANCE_RR))
+		Expect(addresses(l)).To(ConsistOf("10.0.0.1:8080", "10.0.0.2:8080"))
+	})
+
+	It("explains a context path match", func() {
+		_, l := get("?host=app.example.com&path=/api/tenants/1")
+		Expect(l.Route).To(Equal("app.example.com/api"))
+		Expect(l.ContextPath).To(Equal("/api"))
+		Expect(l.StickyPathSegment).To(Equal("1"))
+		Expect(addresses(l)).To(ConsistOf("10.0.0.3:8080"))
+	})
+
+	It("explains a wildcard match", func() {
+		_, l := get("?host=other.example.com")
+		Expect(l.Route).To(Equal("*.example.com"))
+		Expect(l.Wildcard).To(BeTrue())
+		Expect(l.BalancingAlgorithm).To(Equal(config.LOAD_BALANCE_LC))
+		Expect(addresses(l)).To(ConsistOf("10.0.0.4:8080"))
+	})
+
+	It("selects the endpoints that match the method and headers", func() {
+		_, l := get("?host=match.example.com")
+		Expect(l.Matched).To(BeTrue())
+		Expect(addresses(l)).To(ConsistOf("10.0.0.5:8080"))
+
+		_, l = get("?host=match.example.com&method=POST")
+		Expect(addresses(l)).To(ConsistOf("10.0.0.6:8080"))
+	})
+
+	It("returns the endpoint of the sticky session and of the app instance", func() {
+		_, l := get("?host=app.example.com&instance_id=instance-1")
+		Expect(l.StickyEndpoint).To(HaveKeyWithValue("address", "10.0.0.2:8080"))
+
+		_, l = get("?host=app.example.com&app_instance=app:0")
+		Expect(addresses(l)).To(ConsistOf("10.0.0.1:8080"))
+		Expect(l.StickyEndpoint).To(BeNil())
+	})
+
+	It("responds with 404 when there is no route", func() {
+		rw, _ := get("?host=example.org")
+		Expect(rw.Code).To(Equal(http.StatusNotFound))
+
+		rw, _ = get("?host=app.example.com&app_instance=app:5")
+		Expect(rw.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects invalid lookups", func() {
+		for _, query := range []string{"", "?path=/", "?host=app.example.com&app_instance=app", "?host=app.example.com&header=nocolon"} {
+			rw, _ := get(query)
+			Expect(rw.Code).To(Equal(http.StatusBadRequest), query)
+		}
+	})
+})
@@ -0,0 +1,163 @@
+package registry
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/registry/container"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// maxConcurrentProbes bounds the number of health checks in flight.
+const maxConcurrentProbes = 32
+
+// endpointHealth counts the consecutive results of the health checks of an
+// endpoint.
+type endpointHealth struct {
+	unhealthy bool
+	successes int
+	failures  int
+}
+
+// observe records the result of a health check and reports whether it moved
+// the endpoint in or out of rotation.
+func (h *endpointHealth) observe(ok bool, cfg config.HealthCheckConfig) bool {
+	if ok {
+		h.successes++
+		h.failures = 0
+		if h.unhealthy && h.successes >= cfg.HealthyThreshold {
+			h.unhealthy = false
+			return true
+		}
+		return false
+	}
+
+	h.failures++
+	h.successes = 0
+	if !h.unhealthy && h.failures >= cfg.UnhealthyThreshold {
+		h.unhealthy = true
+		return true
+	}
+	return false
+}
+
+// probeTarget is an endpoint to health check and the pools it is
+// registered in.
+type probeTarget struct {
+	uri      route.Uri
+	endpoint *route.Endpoint
+	pools    []*route.Pool
+	ok       bool
+}
+
+func (r *RouteRegistry) startProbing() {
+	r.probeTicker = time.NewTicker(r.probeConfig.Interval)
+
+	go func() {
+		for range r.probeTicker.C {
+			r.probeEndpoints()
+		}
+	}()
+}
+
+// probeEndpoints health checks every registered endpoint once, and takes the
+// endpoints that crossed the unhealthy threshold out of rotation in all of
+// their pools. The checks are made without holding the registry lock.
+func (r *RouteRegistry) probeEndpoints() {
+	targets := map[string]*probeTarget{}
+	r.RLock()
+	r.byURI.EachNodeWithPool(func(t *container.Trie) {
+		t.Pool.Each(func(e *route.Endpoint) {
+			target, found := targets[e.CanonicalAddr()]
+			if !found {
+				target = &probeTarget{uri: route.Uri(t.ToPath()), endpoint: e}
+				targets[e.CanonicalAddr()] = target
+			}
+			target.pools = append(target.pools, t.Pool)
+		})
+	})
+	r.RUnlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentProbes)
+	for _, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(target *probeTarget) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			target.ok = r.probe(target.uri, target.endpoint)
+		}(target)
+	}
+	wg.Wait()
+
+	for addr := range r.probeStates {
+		if targets[addr] == nil {
+			delete(r.probeStates, addr)
+		}
+	}
+
+	for addr, target := range targets {
+		health, found := r.probeStates[addr]
+		if !found {
+			health = &endpointHealth{}
+			r.probeStates[addr] = health
+		}
+
+		if health.observe(target.ok, r.probeConfig) {
+			if health.unhealthy {
+				r.logger.Info("endpoint-failed-health-checks", zapData(target.uri, target.endpoint)...)
+			} else {
+				r.logger.Info("endpoint-passed-health-checks", zapData(target.uri, target.endpoint)...)
+			}
+		}
+
+		// pools that are added, or endpoints that registered again, start
+		// out healthy, so the state is applied on every cycle
+		for _, pool := range target.pools {
+			pool.SetEndpointHealthy(target.endpoint, !health.unhealthy)
+		}
+	}
+}
+
+// probe reports whether the endpoint passes a health check. TLS endpoints are
+// checked by connecting to them, as the registry does not hold the
+// certificates to make requests to them.
+func (r *RouteRegistry) probe(uri route.Uri, endpoint *route.Endpoint) bool {
+	if r.probeConfig.Type == config.HEALTH_CHECK_TCP || endpoint.IsTLS() {
+		conn, err := net.DialTimeout("tcp", endpoint.CanonicalAddr(), r.probeConfig.Timeout)
+		if err != nil {
+			r.logger.Debug("endpoint-health-check-failed", zap.String("backend", endpoint.CanonicalAddr()), zap.Error(err))
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	host, _ := splitHostAndContextPath(uri)
+	req, err := http.NewRequest("GET", "http://"+endpoint.CanonicalAddr()+r.probeConfig.Path, nil)
+	if err != nil {
+		return false
+	}
+	req.Host = host
+	req.Header.Set("User-Agent", r.healthCheckUserAgent)
+
+	res, err := r.probeClient.Do(req)
+	if err != nil {
+		r.logger.Debug("endpoint-health-check-failed", zap.String("backend", endpoint.CanonicalAddr()), zap.Error(err))
+		return false
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	return res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusBadRequest
+}
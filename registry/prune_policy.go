@@ -0,0 +1,142 @@
+package registry
+
+import (
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// PruneCycle is the pruning cycle in which a PrunePolicy is consulted.
+type PruneCycle struct {
+	Now time.Time
+
+	// NatsDisconnected is whether the registry found NATS disconnected.
+	NatsDisconnected bool
+	// ReconnectedAt is when the registry first found NATS connected again
+	// after it was disconnected, or zero if it has never been disconnected.
+	ReconnectedAt time.Time
+}
+
+// PrunePolicy decides whether the stale endpoints of a route are pruned. It
+// is only consulted for routes that have stale endpoints, with the registry
+// locked, and must not call back into the registry.
+type PrunePolicy interface {
+	Prune(uri route.Uri, cycle PruneCycle) bool
+}
+
+// StalePolicy prunes endpoints as soon as they are stale.
+type StalePolicy struct{}
+
+func (StalePolicy) Prune(route.Uri, PruneCycle) bool {
+	return true
+}
+
+// NeverPolicy keeps stale endpoints until they are unregistered.
+type NeverPolicy struct{}
+
+func (NeverPolicy) Prune(route.Uri, PruneCycle) bool {
+	return false
+}
+
+// GracePeriodPolicy does not prune stale endpoints while NATS is
+// disconnected, nor until GracePeriod has passed since the registry found it
+// connected again, so that clients have time to register their routes again.
+type GracePeriodPolicy struct {
+	GracePeriod time.Duration
+}
+
+func (p GracePeriodPolicy) Prune(_ route.Uri, cycle PruneCycle) bool {
+	if cycle.NatsDisconnected {
+		return false
+	}
+	return cycle.ReconnectedAt.IsZero() || cycle.Now.Sub(cycle.ReconnectedAt) >= p.GracePeriod
+}
+
+// PruneSignal is shared by gorouters so that they can agree to prune the
+// stale endpoints of a route.
+type PruneSignal interface {
+	// Vote tells the other gorouters that this one found stale endpoints of
+	// the route.
+	Vote(uri route.Uri)
+	// Votes returns the number of gorouters, this one included, that have
+	// recently voted for the route.
+	Votes(uri route.Uri) int
+}
+
+// QuorumPolicy prunes stale endpoints only once Quorum gorouters have found
+// stale endpoints of the route, so that a gorouter that misses registrations
+// on its own keeps routing to them.
+type QuorumPolicy struct {
+	Quorum int
+	Signal PruneSignal
+}
+
+func (p QuorumPolicy) Prune(uri route.Uri, _ PruneCycle) bool {
+	p.Signal.Vote(uri)
+	return p.Signal.Votes(uri) >= p.Quorum
+}
+
+type domainPrunePolicy struct {
+	domains []string
+	policy  PrunePolicy
+}
+
+// DomainPrunePolicy applies the policy of the first of policies with a
+// domain that matches the host of a route, or StalePolicy if none does.
+type DomainPrunePolicy struct {
+	policies []domainPrunePolicy
+}
+
+// NewDomainPrunePolicy returns the DomainPrunePolicy of the configured
+// policies. The quorum policies agree over signal, which may be nil if there
+// are none.
+func NewDomainPrunePolicy(configs []config.PrunePolicyConfig, signal PruneSignal) *DomainPrunePolicy {
+	p := &DomainPrunePolicy{}
+	for _, c := range configs {
+		var policy PrunePolicy
+		switch c.Policy {
+		case config.PrunePolicyNever:
+			policy = NeverPolicy{}
+		case config.PrunePolicyQuorum:
+			policy = QuorumPolicy{Quorum: c.Quorum, Signal: signal}
+		case config.PrunePolicyGracePeriod:
+			policy = GracePeriodPolicy{GracePeriod: c.GracePeriod}
+		default:
+			policy = StalePolicy{}
+		}
+
+		domains := make([]string, 0, len(c.Domains))
+		for _, domain := range c.Domains {
+			domains = append(domains, strings.ToLower(domain))
+		}
+		p.policies = append(p.policies, domainPrunePolicy{domains: domains, policy: policy})
+	}
+	return p
+}
+
+func (p *DomainPrunePolicy) Prune(uri route.Uri, cycle PruneCycle) bool {
+	host, _ := splitHostAndContextPath(uri)
+	host = strings.ToLower(host)
+	for _, dp := range p.policies {
+		if domainMatches(dp.domains, host) {
+			return dp.policy.Prune(uri, cycle)
+		}
+	}
+	return true
+}
+
+// domainMatches reports whether host is one of domains, is a subdomain of one
+// of their wildcards, or domains contains "*".
+func domainMatches(domains []string, host string) bool {
+	for _, domain := range domains {
+		if domain == "*" || domain == host {
+			return true
+		}
+		if strings.HasPrefix(domain, "*.") && strings.HasSuffix(host, domain[1:]) {
+			return true
+		}
+	}
+	return false
+}
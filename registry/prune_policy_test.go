@@ -0,0 +1,90 @@
+package registry_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakePruneSignal struct {
+	voted []route.Uri
+	votes int
+}
+
+func (s *fakePruneSignal) Vote(uri route.Uri) {
+	s.voted = append(s.voted, uri)
+}
+
+func (s *fakePruneSignal) Votes(route.Uri) int {
+	return s.votes
+}
+
+var _ = Describe("PrunePolicy", func() {
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	Describe("GracePeriodPolicy", func() {
+		policy := GracePeriodPolicy{GracePeriod: time.Minute}
+
+		It("prunes when NATS has never been disconnected", func() {
+			Expect(policy.Prune("foo.com", PruneCycle{Now: now})).To(BeTrue())
+		})
+
+		It("does not prune while NATS is disconnected", func() {
+			Expect(policy.Prune("foo.com", PruneCycle{Now: now, NatsDisconnected: true})).To(BeFalse())
+		})
+
+		It("prunes only once the grace period has passed since NATS reconnected", func() {
+			Expect(policy.Prune("foo.com", PruneCycle{Now: now, ReconnectedAt: now.Add(-30 * time.Second)})).To(BeFalse())
+			Expect(policy.Prune("foo.com", PruneCycle{Now: now, ReconnectedAt: now.Add(-time.Minute)})).To(BeTrue())
+		})
+	})
+
+	Describe("QuorumPolicy", func() {
+		var signal *fakePruneSignal
+
+		BeforeEach(func() {
+			signal = &fakePruneSignal{}
+		})
+
+		It("votes for the route and prunes once the quorum has voted", func() {
+			policy := QuorumPolicy{Quorum: 2, Signal: signal}
+
+			signal.votes = 1
+			Expect(policy.Prune("foo.com", PruneCycle{Now: now})).To(BeFalse())
+			signal.votes = 2
+			Expect(policy.Prune("foo.com", PruneCycle{Now: now})).To(BeTrue())
+
+			Expect(signal.voted).To(Equal([]route.Uri{"foo.com", "foo.com"}))
+		})
+	})
+
+	Describe("DomainPrunePolicy", func() {
+		var policy *DomainPrunePolicy
+
+		BeforeEach(func() {
+			policy = NewDomainPrunePolicy([]config.PrunePolicyConfig{
+				{Domains: []string{"api.example.com"}, Policy: config.PrunePolicyStale},
+				{Domains: []string{"*.Example.com"}, Policy: config.PrunePolicyNever},
+			}, nil)
+		})
+
+		It("applies the policy of the first matching domain", func() {
+			Expect(policy.Prune("api.example.com/v1", PruneCycle{Now: now})).To(BeTrue())
+			Expect(policy.Prune("app.example.com/v1", PruneCycle{Now: now})).To(BeFalse())
+		})
+
+		It("prunes routes in other domains", func() {
+			Expect(policy.Prune("example.com", PruneCycle{Now: now})).To(BeTrue())
+			Expect(policy.Prune("app.example.org", PruneCycle{Now: now})).To(BeTrue())
+		})
+	})
+})
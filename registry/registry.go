@@ -2,6 +2,9 @@ package registry
 
 import (
 	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -43,13 +46,30 @@ type RouteRegistry struct {
 	// Access to the Trie datastructure should be governed by the RWMutex of RouteRegistry
 	byURI *container.Trie
 
+	// snapshot holds the pools in byURI for lookups, which read it without
+	// locking so that they never wait for registrations. It is updated under
+	// the write lock whenever a pool is added to or removed from byURI.
+	snapshot *container.Snapshot
+
 	// used for ability to suspend pruning
 	suspendPruning func() bool
 	pruningStatus  PruneStatus
 
+	prunePolicy PrunePolicy
+
+	// natsConnected is checked at every pruning cycle to find when NATS was
+	// reconnected, which is only accessed by the pruning cycle.
+	natsConnected    func() bool
+	natsDisconnected bool
+	reconnectedAt    time.Time
+
 	pruneStaleDropletsInterval time.Duration
 	dropletStaleThreshold      time.Duration
 
+	// the stale thresholds of registrations are kept within these bounds
+	minStaleThreshold time.Duration
+	maxStaleThreshold time.Duration
+
 	reporter metrics.RouteRegistryReporter
 
 	ticker           *time.Ticker
@@ -59,16 +79,46 @@ type RouteRegistry struct {
 	isolationSegments        []string
 
 	maxConnsPerBackend int64
+
+	// localAvailabilityZone is the zone whose endpoints pools prefer, or
+	// empty if they do not prefer any.
+	localAvailabilityZone string
+
+	outlierDetection config.OutlierDetectionConfig
+	circuitBreaker   config.CircuitBreakerConfig
+
+	failedEndpointCooldown time.Duration
+
+	idleEndpointPruneThreshold time.Duration
+	healthCheckClient          *http.Client
+	healthCheckUserAgent       string
+
+	// probeStates is only accessed by the goroutine that runs the active
+	// health checks.
+	probeConfig config.HealthCheckConfig
+	probeClient *http.Client
+	probeTicker *time.Ticker
+	probeStates map[string]*endpointHealth
+
+	perRouteMetricsAllowlist []route.Uri
 }
 
 func NewRouteRegistry(logger logger.Logger, c *config.Config, reporter metrics.RouteRegistryReporter) *RouteRegistry {
 	r := &RouteRegistry{}
 	r.logger = logger
 	r.byURI = container.NewTrie()
+	r.snapshot = container.NewSnapshot()
 
 	r.pruneStaleDropletsInterval = c.PruneStaleDropletsInterval
 	r.dropletStaleThreshold = c.DropletStaleThreshold
+	r.minStaleThreshold = c.MinStaleThreshold
+	r.maxStaleThreshold = c.MaxStaleThreshold
+	if r.maxStaleThreshold < r.dropletStaleThreshold {
+		r.maxStaleThreshold = r.dropletStaleThreshold
+	}
 	r.suspendPruning = func() bool { return false }
+	r.prunePolicy = StalePolicy{}
+	r.natsConnected = func() bool { return true }
 
 	r.reporter = reporter
 
@@ -77,6 +127,39 @@ func NewRouteRegistry(logger logger.Logger, c *config.Config, reporter metrics.R
 
 	r.maxConnsPerBackend = c.Backends.MaxConns
 
+	if c.PreferLocalAvailabilityZone {
+		r.localAvailabilityZone = c.AvailabilityZone
+	}
+	r.outlierDetection = c.OutlierDetection
+	r.circuitBreaker = c.CircuitBreaker
+
+	r.failedEndpointCooldown = c.FailedEndpointCooldown
+	if r.failedEndpointCooldown == 0 {
+		r.failedEndpointCooldown = r.dropletStaleThreshold / 4
+	}
+
+	r.idleEndpointPruneThreshold = c.IdleEndpointPruneThreshold
+	r.healthCheckClient = &http.Client{
+		Timeout: c.IdleEndpointHealthCheckTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	r.healthCheckUserAgent = c.HealthCheckUserAgent
+
+	r.probeConfig = c.HealthCheck
+	r.probeClient = &http.Client{
+		Timeout: c.HealthCheck.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	r.probeStates = map[string]*endpointHealth{}
+
+	for _, uri := range c.PerRouteMetricsAllowlist {
+		r.perRouteMetricsAllowlist = append(r.perRouteMetricsAllowlist, route.Uri(uri).RouteKey())
+	}
+
 	return r
 }
 
@@ -104,6 +187,12 @@ func (r *RouteRegistry) register(uri route.Uri, endpoint *route.Endpoint) route.
 	r.Lock()
 	defer r.Unlock()
 
+	return r.put(uri, endpoint)
+}
+
+// put adds the endpoint to the pool of the route, creating the pool if there
+// is none. The caller must hold the write lock.
+func (r *RouteRegistry) put(uri route.Uri, endpoint *route.Endpoint) route.PoolPutResult {
 	t := time.Now()
 
 	routekey := uri.RouteKey()
@@ -113,17 +202,27 @@ func (r *RouteRegistry) register(uri route.Uri, endpoint *route.Endpoint) route.
 		host, contextPath := splitHostAndContextPath(uri)
 		pool = route.NewPool(&route.PoolOpts{
 			Logger:             r.logger,
-			RetryAfterFailure:  r.dropletStaleThreshold / 4,
+			RetryAfterFailure:  r.failedEndpointCooldown,
 			Host:               host,
 			ContextPath:        contextPath,
 			MaxConnsPerBackend: r.maxConnsPerBackend,
+
+			LocalAvailabilityZone: r.localAvailabilityZone,
+			OutlierDetection:      r.outlierDetection,
+			CircuitBreaker:        r.circuitBreaker,
 		})
 		r.byURI.Insert(routekey, pool)
+		r.snapshot.Set(routekey, pool)
 		r.logger.Debug("uri-added", zap.Stringer("uri", routekey))
 	}
 
-	if endpoint.StaleThreshold > r.dropletStaleThreshold || endpoint.StaleThreshold == 0 {
+	switch {
+	case endpoint.StaleThreshold == 0:
 		endpoint.StaleThreshold = r.dropletStaleThreshold
+	case endpoint.StaleThreshold > r.maxStaleThreshold:
+		endpoint.StaleThreshold = r.maxStaleThreshold
+	case r.minStaleThreshold > 0 && endpoint.StaleThreshold < r.minStaleThreshold:
+		endpoint.StaleThreshold = r.minStaleThreshold
 	}
 
 	endpointAdded := pool.Put(endpoint)
@@ -147,21 +246,80 @@ func (r *RouteRegistry) unregister(uri route.Uri, endpoint *route.Endpoint) {
 	r.Lock()
 	defer r.Unlock()
 
+	r.remove(uri, endpoint)
+}
+
+// remove removes the endpoint from the pool of the route, and the pool if it
+// is left empty. The caller must hold the write lock.
+func (r *RouteRegistry) remove(uri route.Uri, endpoint *route.Endpoint) bool {
 	uri = uri.RouteKey()
 
 	pool := r.byURI.Find(uri)
-	if pool != nil {
-		endpointRemoved := pool.Remove(endpoint)
-		if endpointRemoved {
-			r.logger.Debug("endpoint-unregistered", zapData(uri, endpoint)...)
-		} else {
-			r.logger.Debug("endpoint-not-unregistered", zapData(uri, endpoint)...)
+	if pool == nil {
+		return false
+	}
+
+	endpointRemoved := pool.Remove(endpoint)
+	if endpointRemoved {
+		r.logger.Debug("endpoint-unregistered", zapData(uri, endpoint)...)
+	} else {
+		r.logger.Debug("endpoint-not-unregistered", zapData(uri, endpoint)...)
+	}
+
+	if pool.IsEmpty() {
+		r.byURI.Delete(uri)
+		r.snapshot.Delete(uri)
+	}
+	return endpointRemoved
+}
+
+// Sync registers the endpoints of many routes at once, under a single lock so
+// that no other registration or pruning is interleaved with them. When replace
+// is set, the endpoints of the routes that are not among them are
+// unregistered, while routes that are not in routes are left alone. It returns
+// the number of endpoints registered and unregistered.
+func (r *RouteRegistry) Sync(routes map[route.Uri][]*route.Endpoint, replace bool) (int, int) {
+	r.Lock()
+	defer r.Unlock()
+
+	registered, unregistered := 0, 0
+	for uri, endpoints := range routes {
+		addrs := make(map[string]bool, len(endpoints))
+		for _, endpoint := range endpoints {
+			if !r.endpointInRouterShard(endpoint) {
+				continue
+			}
+			addrs[endpoint.CanonicalAddr()] = true
+			if r.put(uri, endpoint) >= route.UPDATED {
+				registered++
+			}
+		}
+		if !replace {
+			continue
 		}
 
-		if pool.IsEmpty() {
-			r.byURI.Delete(uri)
+		var stale []*route.Endpoint
+		if pool := r.byURI.Find(uri.RouteKey()); pool != nil {
+			pool.Each(func(e *route.Endpoint) {
+				if !addrs[e.CanonicalAddr()] {
+					stale = append(stale, e)
+				}
+			})
+		}
+		for _, endpoint := range stale {
+			if r.remove(uri, endpoint) {
+				unregistered++
+			}
 		}
 	}
+
+	r.logger.Info("routes-synced",
+		zap.Int("routes", len(routes)),
+		zap.Int("registered", registered),
+		zap.Int("unregistered", unregistered),
+		zap.Bool("replace", replace),
+	)
+	return registered, unregistered
 }
 
 func (r *RouteRegistry) Lookup(uri route.Uri) *route.Pool {
@@ -176,15 +334,12 @@ func (r *RouteRegistry) Lookup(uri route.Uri) *route.Pool {
 }
 
 func (r *RouteRegistry) lookup(uri route.Uri) *route.Pool {
-	r.RLock()
-	defer r.RUnlock()
-
 	uri = uri.RouteKey()
 	var err error
-	pool := r.byURI.MatchUri(uri)
+	pool := r.snapshot.MatchUri(uri)
 	for pool == nil && err == nil {
 		uri, err = uri.NextWildcard()
-		pool = r.byURI.MatchUri(uri)
+		pool = r.snapshot.MatchUri(uri)
 	}
 	return pool
 }
@@ -233,7 +388,15 @@ func (r *RouteRegistry) LookupWithInstance(uri route.Uri, appID string, appIndex
 	return surgicalPool
 }
 
+// StartPruningCycle periodically prunes stale endpoints and, when active
+// health checks are enabled, health checks all endpoints.
 func (r *RouteRegistry) StartPruningCycle() {
+	if r.probeConfig.Enabled {
+		r.Lock()
+		r.startProbing()
+		r.Unlock()
+	}
+
 	if r.pruneStaleDropletsInterval > 0 {
 		r.Lock()
 		defer r.Unlock()
@@ -245,9 +408,13 @@ func (r *RouteRegistry) StartPruningCycle() {
 				case <-r.ticker.C:
 					r.logger.Info("start-pruning-routes")
 					r.pruneStaleDroplets()
+					if r.idleEndpointPruneThreshold > 0 {
+						r.pruneIdleEndpoints()
+					}
 					r.logger.Info("finished-pruning-routes")
 					msSinceLastUpdate := uint64(time.Since(r.TimeOfLastUpdate()) / time.Millisecond)
 					r.reporter.CaptureRouteStats(r.NumUris(), msSinceLastUpdate)
+					r.captureRoutePoolSizes()
 				}
 			}
 		}()
@@ -260,6 +427,9 @@ func (r *RouteRegistry) StopPruningCycle() {
 	if r.ticker != nil {
 		r.ticker.Stop()
 	}
+	if r.probeTicker != nil {
+		r.probeTicker.Stop()
+	}
 }
 
 func (registry *RouteRegistry) NumUris() int {
@@ -290,10 +460,26 @@ func (r *RouteRegistry) MarshalJSON() ([]byte, error) {
 	return json.Marshal(r.byURI.ToMap())
 }
 
+// EachEndpoint calls f for every endpoint of every route in the routing
+// table. The registry is read locked for the duration of the iteration, so f
+// must not call back into the registry.
+func (r *RouteRegistry) EachEndpoint(f func(uri route.Uri, endpoint *route.Endpoint)) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for uri, pool := range r.byURI.ToMap() {
+		pool.Each(func(e *route.Endpoint) {
+			f(uri, e)
+		})
+	}
+}
+
 func (r *RouteRegistry) pruneStaleDroplets() {
 	r.Lock()
 	defer r.Unlock()
 
+	cycle := r.pruneCycle()
+
 	// suspend pruning if option enabled and if NATS is unavailable
 	if r.suspendPruning() {
 		r.logger.Info("prune-suspended")
@@ -310,8 +496,14 @@ func (r *RouteRegistry) pruneStaleDroplets() {
 	r.pruningStatus = CONNECTED
 
 	r.byURI.EachNodeWithPool(func(t *container.Trie) {
+		if t.Pool.HasStaleEndpoints() && !r.prunePolicy.Prune(route.Uri(t.ToPath()), cycle) {
+			return
+		}
 		endpoints := t.Pool.PruneEndpoints()
 		t.Snip()
+		if t.Pool == nil {
+			r.snapshot.Delete(route.Uri(t.ToPath()))
+		}
 		if len(endpoints) > 0 {
 			addresses := []string{}
 			for _, e := range endpoints {
@@ -331,12 +523,130 @@ func (r *RouteRegistry) pruneStaleDroplets() {
 	})
 }
 
+// pruneCycle returns the current pruning cycle, noting when NATS was
+// reconnected.
+func (r *RouteRegistry) pruneCycle() PruneCycle {
+	now := time.Now()
+	if !r.natsConnected() {
+		r.natsDisconnected = true
+	} else if r.natsDisconnected {
+		r.natsDisconnected = false
+		r.reconnectedAt = now
+	}
+	return PruneCycle{Now: now, NatsDisconnected: r.natsDisconnected, ReconnectedAt: r.reconnectedAt}
+}
+
+// captureRoutePoolSizes reports the number of endpoints of every route as a
+// histogram, and of each allowlisted route individually.
+func (r *RouteRegistry) captureRoutePoolSizes() {
+	r.RLock()
+	sizes := []int{}
+	r.byURI.EachNodeWithPool(func(t *container.Trie) {
+		sizes = append(sizes, t.Pool.NumEndpoints())
+	})
+
+	perRoute := make(map[route.Uri]int, len(r.perRouteMetricsAllowlist))
+	for _, uri := range r.perRouteMetricsAllowlist {
+		perRoute[uri] = 0
+		if pool := r.byURI.Find(uri); pool != nil {
+			perRoute[uri] = pool.NumEndpoints()
+		}
+	}
+	r.RUnlock()
+
+	r.reporter.CaptureRoutePoolSizes(sizes)
+	for uri, size := range perRoute {
+		r.reporter.CaptureRoutePoolSize(uri.String(), size)
+	}
+}
+
+type idleEndpoint struct {
+	uri      route.Uri
+	endpoint *route.Endpoint
+}
+
+// pruneIdleEndpoints health checks endpoints that have not served a request
+// within the idle threshold and removes those that fail the check. This
+// catches endpoints that keep registering but can no longer serve traffic.
+// The checks are made without holding the registry lock.
+func (r *RouteRegistry) pruneIdleEndpoints() {
+	r.RLock()
+	if r.pruningStatus == DISCONNECTED {
+		r.RUnlock()
+		return
+	}
+	candidates := []idleEndpoint{}
+	r.byURI.EachNodeWithPool(func(t *container.Trie) {
+		for _, e := range t.Pool.IdleEndpoints(r.idleEndpointPruneThreshold) {
+			candidates = append(candidates, idleEndpoint{uri: route.Uri(t.ToPath()), endpoint: e})
+		}
+	})
+	r.RUnlock()
+
+	healthy := map[*route.Endpoint]bool{}
+	for _, c := range candidates {
+		ok, checked := healthy[c.endpoint]
+		if !checked {
+			ok = r.healthCheck(c.uri, c.endpoint)
+			healthy[c.endpoint] = ok
+			if ok {
+				c.endpoint.MarkServed()
+			}
+		}
+		if ok {
+			continue
+		}
+
+		r.unregister(c.uri, c.endpoint)
+		r.logger.Info("pruned-idle-endpoint", zapData(c.uri, c.endpoint)...)
+		r.reporter.CaptureRoutesPruned(1)
+	}
+}
+
+// healthCheck reports whether the endpoint responds to a request for the
+// root path without a server error.
+func (r *RouteRegistry) healthCheck(uri route.Uri, endpoint *route.Endpoint) bool {
+	host, _ := splitHostAndContextPath(uri)
+	req, err := http.NewRequest("GET", "http://"+endpoint.CanonicalAddr()+"/", nil)
+	if err != nil {
+		return false
+	}
+	req.Host = host
+	req.Header.Set("User-Agent", r.healthCheckUserAgent)
+
+	res, err := r.healthCheckClient.Do(req)
+	if err != nil {
+		r.logger.Debug("idle-endpoint-health-check-failed", zap.String("backend", endpoint.CanonicalAddr()), zap.Error(err))
+		return false
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	return res.StatusCode < http.StatusInternalServerError
+}
+
 func (r *RouteRegistry) SuspendPruning(f func() bool) {
 	r.Lock()
 	defer r.Unlock()
 	r.suspendPruning = f
 }
 
+// SetPrunePolicy sets the policy that decides whether the stale endpoints of
+// a route are pruned, which prunes them all by default.
+func (r *RouteRegistry) SetPrunePolicy(policy PrunePolicy) {
+	r.Lock()
+	defer r.Unlock()
+	r.prunePolicy = policy
+}
+
+// TrackNatsConnection has the pruning cycle check whether NATS is connected,
+// so that prune policies know when it was reconnected.
+func (r *RouteRegistry) TrackNatsConnection(connected func() bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.natsConnected = connected
+}
+
 // bulk update to mark pool / endpoints as updated
 func (r *RouteRegistry) freshenRoutes() {
 	now := time.Now()
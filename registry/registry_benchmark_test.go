@@ -81,3 +81,49 @@ func BenchmarkRegisterWithOneRoute(b *testing.B) {
 		r.Register("foo.example.com", fooEndpoint)
 	}
 }
+
+func BenchmarkLookupWith100KRoutes(b *testing.B) {
+	r := registry.NewRouteRegistry(testLogger, configObj, reporter)
+
+	for i := 0; i < 100000; i++ {
+		r.Register(route.Uri(fmt.Sprintf("foo%d.example.com", i)), fooEndpoint)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.Lookup("foo50000.example.com/some/path")
+	}
+}
+
+func BenchmarkLookupWith100KRoutesDuringRegistrationChurn(b *testing.B) {
+	r := registry.NewRouteRegistry(testLogger, configObj, reporter)
+
+	for i := 0; i < 100000; i++ {
+		r.Register(route.Uri(fmt.Sprintf("foo%d.example.com", i)), fooEndpoint)
+	}
+
+	stop := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		go func(w int) {
+			endpoint := route.NewEndpoint(&route.EndpointOpts{Host: fmt.Sprintf("10.0.0.%d", w)})
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				uri := route.Uri(fmt.Sprintf("churn%d-%d.example.com", w, i%1000))
+				r.Register(uri, endpoint)
+				r.Unregister(uri, endpoint)
+			}
+		}(w)
+	}
+	defer close(stop)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.Lookup("foo50000.example.com/some/path")
+	}
+}
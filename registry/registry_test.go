@@ -2,6 +2,11 @@ package registry_test
 
 import (
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
 
 	"code.cloudfoundry.org/gorouter/logger"
 	. "code.cloudfoundry.org/gorouter/registry"
@@ -323,6 +328,36 @@ var _ = Describe("RouteRegistry", func() {
 			})
 		})
 
+		Context("stale thresholds", func() {
+			register := func(threshold time.Duration) time.Duration {
+				endpoint := route.NewEndpoint(&route.EndpointOpts{Host: "192.168.1.1", Port: 1234})
+				endpoint.StaleThreshold = threshold
+				r.Register("foo.com", endpoint)
+				return endpoint.StaleThreshold
+			}
+
+			It("defaults to and is at most the droplet stale threshold", func() {
+				Expect(register(0)).To(Equal(configObj.DropletStaleThreshold))
+				Expect(register(time.Minute)).To(Equal(configObj.DropletStaleThreshold))
+				Expect(register(10 * time.Millisecond)).To(Equal(10 * time.Millisecond))
+			})
+
+			Context("when stale thresholds are bounded", func() {
+				BeforeEach(func() {
+					configObj.MinStaleThreshold = 20 * time.Millisecond
+					configObj.MaxStaleThreshold = time.Hour
+					r = NewRouteRegistry(logger, configObj, reporter)
+				})
+
+				It("keeps the stale thresholds of registrations within the bounds", func() {
+					Expect(register(0)).To(Equal(configObj.DropletStaleThreshold))
+					Expect(register(10 * time.Millisecond)).To(Equal(20 * time.Millisecond))
+					Expect(register(10 * time.Minute)).To(Equal(10 * time.Minute))
+					Expect(register(2 * time.Hour)).To(Equal(time.Hour))
+				})
+			})
+		})
+
 		Context("Modification Tags", func() {
 			var (
 				endpoint *route.Endpoint
@@ -701,6 +736,47 @@ var _ = Describe("RouteRegistry", func() {
 		})
 	})
 
+	Context("Sync", func() {
+		BeforeEach(func() {
+			r.Register("foo", fooEndpoint)
+			r.Register("bar", barEndpoint)
+		})
+
+		It("registers the endpoints of every route and leaves the others when merging", func() {
+			registered, unregistered := r.Sync(map[route.Uri][]*route.Endpoint{
+				"foo": {bar2Endpoint},
+				"baz": {barEndpoint},
+			}, false)
+			Expect(registered).To(Equal(2))
+			Expect(unregistered).To(BeZero())
+
+			Expect(r.Lookup("foo").NumEndpoints()).To(Equal(2))
+			Expect(r.Lookup("bar").NumEndpoints()).To(Equal(1))
+			Expect(r.Lookup("baz").NumEndpoints()).To(Equal(1))
+		})
+
+		It("unregisters the other endpoints of the routes when replacing", func() {
+			registered, unregistered := r.Sync(map[route.Uri][]*route.Endpoint{
+				"foo": {bar2Endpoint},
+			}, true)
+			Expect(registered).To(Equal(1))
+			Expect(unregistered).To(Equal(1))
+
+			var addrs []string
+			r.Lookup("foo").Each(func(e *route.Endpoint) {
+				addrs = append(addrs, e.CanonicalAddr())
+			})
+			Expect(addrs).To(ConsistOf(bar2Endpoint.CanonicalAddr()))
+			Expect(r.Lookup("bar")).NotTo(BeNil())
+		})
+
+		It("removes routes left without endpoints when replacing", func() {
+			_, unregistered := r.Sync(map[route.Uri][]*route.Endpoint{"foo": nil}, true)
+			Expect(unregistered).To(Equal(1))
+			Expect(r.Lookup("foo")).To(BeNil())
+		})
+	})
+
 	Context("Lookup", func() {
 		It("case insensitive lookup", func() {
 			m := route.NewEndpoint(&route.EndpointOpts{Host: "192.168.1.1", Port: 1234})
@@ -850,6 +926,94 @@ var _ = Describe("RouteRegistry", func() {
 			p1 := r.Lookup("foo%")
 			Expect(p1).To(BeNil())
 		})
+
+		It("does not wait for the registry lock", func() {
+			r.Register("foo", fooEndpoint)
+
+			r.Lock()
+			defer r.Unlock()
+
+			done := make(chan *route.Pool)
+			go func() {
+				done <- r.Lookup("foo")
+			}()
+			Eventually(done).Should(Receive(Not(BeNil())))
+		})
+
+		It("finds unchanged routes while other routes are registered and unregistered", func() {
+			r.Register("foo", fooEndpoint)
+			r.Register("foo/bar", barEndpoint)
+			expected := r.Lookup("foo")
+
+			stop := make(chan struct{})
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					uri := route.Uri(fmt.Sprintf("foo/baz/%d", i%10))
+					r.Register(uri, bar2Endpoint)
+					r.Unregister(uri, bar2Endpoint)
+					r.Register(route.Uri(fmt.Sprintf("churn-%d.com", i)), bar2Endpoint)
+				}
+			}()
+
+			for i := 0; i < 5000; i++ {
+				Expect(r.Lookup("foo/baz")).To(BeIdenticalTo(expected))
+				Expect(r.Lookup("foo/bar/qux").Endpoints("", "").Next()).To(Equal(barEndpoint))
+			}
+			close(stop)
+			Eventually(stopped).Should(BeClosed())
+		})
+	})
+
+	Context("FailedEndpointCooldown", func() {
+		BeforeEach(func() {
+			configObj.FailedEndpointCooldown = 200 * time.Millisecond
+			r = NewRouteRegistry(logger, configObj, reporter)
+
+			r.Register("foo", fooEndpoint)
+			r.Register("foo", barEndpoint)
+		})
+
+		It("skips a failed endpoint for the configured cooldown", func() {
+			iter := r.Lookup("foo").Endpoints(config.LOAD_BALANCE_RR, "")
+			failed := iter.Next()
+			iter.EndpointFailed(&net.OpError{Op: "dial"})
+
+			Consistently(func() *route.Endpoint {
+				return r.Lookup("foo").Endpoints(config.LOAD_BALANCE_RR, "").Next()
+			}, 100*time.Millisecond, 10*time.Millisecond).ShouldNot(Equal(failed))
+
+			Eventually(func() *route.Endpoint {
+				return r.Lookup("foo").Endpoints(config.LOAD_BALANCE_RR, "").Next()
+			}).Should(Equal(failed))
+		})
+	})
+
+	Context("PreferLocalAvailabilityZone", func() {
+		var local, remote *route.Endpoint
+
+		BeforeEach(func() {
+			configObj.AvailabilityZone = "z1"
+			configObj.PreferLocalAvailabilityZone = true
+			r = NewRouteRegistry(logger, configObj, reporter)
+
+			local = route.NewEndpoint(&route.EndpointOpts{Host: "192.168.1.1", Port: 1234, AvailabilityZone: "z1"})
+			remote = route.NewEndpoint(&route.EndpointOpts{Host: "192.168.1.2", Port: 1234, AvailabilityZone: "z2"})
+			r.Register("foo", local)
+			r.Register("foo", remote)
+		})
+
+		It("selects endpoints in the availability zone of the router", func() {
+			for i := 0; i < 10; i++ {
+				Expect(r.Lookup("foo").Endpoints(config.LOAD_BALANCE_RR, "").Next()).To(Equal(local))
+			}
+		})
 	})
 
 	Context("LookupWithInstance", func() {
@@ -954,6 +1118,18 @@ var _ = Describe("RouteRegistry", func() {
 			Expect(logger).To(gbytes.Say(`"log_level":1.*prune.*bar.com/path1/path2/path3.*endpoints.*isolation_segment`))
 		})
 
+		It("stops looking up pruned routes", func() {
+			r.Register("bar.com", fooEndpoint)
+			r.Register("bar.com/path1", barEndpoint)
+			Expect(r.Lookup("bar.com/path1")).ToNot(BeNil())
+
+			r.StartPruningCycle()
+			time.Sleep(2 * configObj.PruneStaleDropletsInterval)
+
+			Expect(r.Lookup("bar.com/path1")).To(BeNil())
+			Expect(r.Lookup("bar.com")).To(BeNil())
+		})
+
 		It("removes stale droplets", func() {
 			r.Register("foo", fooEndpoint)
 			r.Register("fooo", fooEndpoint)
@@ -1167,6 +1343,294 @@ var _ = Describe("RouteRegistry", func() {
 			})
 		})
 
+		Context("when a prune policy keeps the stale endpoints of a domain", func() {
+			BeforeEach(func() {
+				r.SetPrunePolicy(NewDomainPrunePolicy([]config.PrunePolicyConfig{
+					{Domains: []string{"*.internal.com"}, Policy: config.PrunePolicyNever},
+				}, nil))
+			})
+
+			It("only prunes the stale endpoints of other domains", func() {
+				r.Register("app.internal.com", fooEndpoint)
+				r.Register("app.external.com", barEndpoint)
+
+				r.StartPruningCycle()
+				time.Sleep(configObj.PruneStaleDropletsInterval + configObj.DropletStaleThreshold)
+
+				Eventually(func() *route.Pool { return r.Lookup("app.external.com") }).Should(BeNil())
+				Expect(r.Lookup("app.internal.com")).ToNot(BeNil())
+			})
+		})
+
+		Context("when a prune policy has a grace period after NATS reconnects", func() {
+			var connected int32
+
+			BeforeEach(func() {
+				atomic.StoreInt32(&connected, 0)
+				r.TrackNatsConnection(func() bool { return atomic.LoadInt32(&connected) == 1 })
+				r.SetPrunePolicy(GracePeriodPolicy{GracePeriod: time.Hour})
+			})
+
+			It("does not prune stale endpoints while NATS is disconnected or within the grace period", func() {
+				r.Register("foo.com", fooEndpoint)
+
+				r.StartPruningCycle()
+				time.Sleep(configObj.PruneStaleDropletsInterval + configObj.DropletStaleThreshold)
+				Expect(r.Lookup("foo.com")).ToNot(BeNil())
+
+				atomic.StoreInt32(&connected, 1)
+				time.Sleep(2 * configObj.PruneStaleDropletsInterval)
+				Expect(r.Lookup("foo.com")).ToNot(BeNil())
+			})
+		})
+
+		Context("when a route is in the per-route metrics allowlist", func() {
+			const numEndpoints = 3
+			var endpoints []*route.Endpoint
+
+			lastPoolSize := func(uri string) int {
+				for i := reporter.CaptureRoutePoolSizeCallCount() - 1; i >= 0; i-- {
+					u, size := reporter.CaptureRoutePoolSizeArgsForCall(i)
+					if u == uri {
+						return size
+					}
+				}
+				return -1
+			}
+
+			BeforeEach(func() {
+				configObj.PruneStaleDropletsInterval = 20 * time.Millisecond
+				configObj.DropletStaleThreshold = 60 * time.Millisecond
+				configObj.PerRouteMetricsAllowlist = []string{"Foo.com"}
+				r = NewRouteRegistry(logger, configObj, reporter)
+
+				endpoints = nil
+				for i := 0; i < numEndpoints; i++ {
+					e := route.NewEndpoint(&route.EndpointOpts{Host: "192.168.1.1", Port: uint16(1000 + i)})
+					endpoints = append(endpoints, e)
+					r.Register("foo.com", e)
+				}
+				r.Register("bar.com", fooEndpoint)
+			})
+
+			It("emits the number of endpoints and decrements it when one is pruned", func() {
+				doneChan := make(chan struct{})
+				defer close(doneChan)
+				go func() {
+					for {
+						select {
+						case <-doneChan:
+							return
+						case <-time.After(5 * time.Millisecond):
+							for _, e := range endpoints[1:] {
+								r.Register("foo.com", e)
+							}
+						}
+					}
+				}()
+
+				r.StartPruningCycle()
+
+				Eventually(func() int { return lastPoolSize("foo.com") }).Should(Equal(numEndpoints))
+				Eventually(func() int { return lastPoolSize("foo.com") }).Should(Equal(numEndpoints - 1))
+			})
+
+			It("emits a histogram of the sizes of all route pools", func() {
+				r.StartPruningCycle()
+
+				Eventually(reporter.CaptureRoutePoolSizesCallCount).Should(BeNumerically(">", 0))
+				Expect(reporter.CaptureRoutePoolSizesArgsForCall(0)).To(ConsistOf(numEndpoints, 1))
+			})
+
+			It("only emits per-route gauges for allowlisted routes", func() {
+				r.StartPruningCycle()
+
+				Eventually(reporter.CaptureRoutePoolSizeCallCount).Should(BeNumerically(">", 0))
+				Expect(lastPoolSize("bar.com")).To(Equal(-1))
+			})
+		})
+
+		Context("when idle endpoint pruning is enabled", func() {
+			var (
+				backend    *httptest.Server
+				statusCode int
+				endpoint   *route.Endpoint
+				doneChan   chan struct{}
+			)
+
+			BeforeEach(func() {
+				statusCode = http.StatusServiceUnavailable
+				backend = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					rw.WriteHeader(statusCode)
+				}))
+
+				host, portStr, err := net.SplitHostPort(backend.Listener.Addr().String())
+				Expect(err).ToNot(HaveOccurred())
+				port, err := strconv.Atoi(portStr)
+				Expect(err).ToNot(HaveOccurred())
+				endpoint = route.NewEndpoint(&route.EndpointOpts{Host: host, Port: uint16(port)})
+
+				configObj.PruneStaleDropletsInterval = 20 * time.Millisecond
+				configObj.DropletStaleThreshold = 1 * time.Second
+				configObj.IdleEndpointPruneThreshold = 50 * time.Millisecond
+				configObj.IdleEndpointHealthCheckTimeout = 100 * time.Millisecond
+				r = NewRouteRegistry(logger, configObj, reporter)
+
+				doneChan = make(chan struct{})
+			})
+
+			JustBeforeEach(func() {
+				r.Register("foo.com", endpoint)
+				registry, done := r, doneChan
+				go func() {
+					for {
+						select {
+						case <-done:
+							return
+						case <-time.After(5 * time.Millisecond):
+							registry.Register("foo.com", endpoint)
+						}
+					}
+				}()
+				r.StartPruningCycle()
+			})
+
+			AfterEach(func() {
+				close(doneChan)
+				backend.Close()
+			})
+
+			It("prunes a registered endpoint that fails the health check", func() {
+				Eventually(func() int { return reporter.CaptureRoutesPrunedCallCount() }).Should(BeNumerically(">", 0))
+				Expect(logger).To(gbytes.Say(`pruned-idle-endpoint.*foo.com`))
+			})
+
+			Context("when the endpoint passes the health check", func() {
+				BeforeEach(func() {
+					statusCode = http.StatusOK
+				})
+
+				It("keeps the endpoint", func() {
+					Consistently(r.NumEndpoints, 200*time.Millisecond).Should(Equal(1))
+					Expect(reporter.CaptureRoutesPrunedCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the endpoint has served requests recently", func() {
+				BeforeEach(func() {
+					go func() {
+						for {
+							select {
+							case <-doneChan:
+								return
+							case <-time.After(5 * time.Millisecond):
+								endpoint.MarkServed()
+							}
+						}
+					}()
+				})
+
+				It("does not health check the endpoint", func() {
+					Consistently(r.NumEndpoints, 200*time.Millisecond).Should(Equal(1))
+					Expect(reporter.CaptureRoutesPrunedCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when active health checks are enabled", func() {
+			var (
+				healthy, failing *route.Endpoint
+				healthyBackend   *httptest.Server
+				failingBackend   *httptest.Server
+				failingStatus    int32
+				paths            chan string
+			)
+
+			endpointFor := func(server *httptest.Server) *route.Endpoint {
+				host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+				Expect(err).ToNot(HaveOccurred())
+				port, err := strconv.Atoi(portStr)
+				Expect(err).ToNot(HaveOccurred())
+				return route.NewEndpoint(&route.EndpointOpts{Host: host, Port: uint16(port)})
+			}
+
+			next := func() *route.Endpoint {
+				return r.Lookup("foo.com").Endpoints(config.LOAD_BALANCE_RR, "").Next()
+			}
+
+			BeforeEach(func() {
+				paths = make(chan string, 100)
+				healthyBackend = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					select {
+					case paths <- req.URL.Path:
+					default:
+					}
+				}))
+
+				atomic.StoreInt32(&failingStatus, http.StatusServiceUnavailable)
+				failingBackend = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					rw.WriteHeader(int(atomic.LoadInt32(&failingStatus)))
+				}))
+
+				healthy = endpointFor(healthyBackend)
+				failing = endpointFor(failingBackend)
+
+				configObj.PruneStaleDropletsInterval = 0
+				configObj.HealthCheck = config.HealthCheckConfig{
+					Enabled:            true,
+					Type:               config.HEALTH_CHECK_HTTP,
+					Path:               "/health",
+					Interval:           10 * time.Millisecond,
+					Timeout:            100 * time.Millisecond,
+					HealthyThreshold:   2,
+					UnhealthyThreshold: 2,
+				}
+			})
+
+			JustBeforeEach(func() {
+				r = NewRouteRegistry(logger, configObj, reporter)
+				r.Register("foo.com", healthy)
+				r.Register("foo.com", failing)
+				r.StartPruningCycle()
+			})
+
+			AfterEach(func() {
+				r.StopPruningCycle()
+				healthyBackend.Close()
+				failingBackend.Close()
+			})
+
+			It("takes endpoints that fail the health checks out of rotation", func() {
+				Eventually(logger).Should(gbytes.Say(`endpoint-failed-health-checks`))
+				Consistently(next, 100*time.Millisecond, 5*time.Millisecond).Should(Equal(healthy))
+			})
+
+			It("checks the configured path", func() {
+				Eventually(paths).Should(Receive(Equal("/health")))
+			})
+
+			It("puts endpoints back in rotation once they pass the health checks", func() {
+				Eventually(logger).Should(gbytes.Say(`endpoint-failed-health-checks`))
+				atomic.StoreInt32(&failingStatus, http.StatusOK)
+
+				Eventually(logger).Should(gbytes.Say(`endpoint-passed-health-checks`))
+				Eventually(next).Should(Equal(failing))
+			})
+
+			Context("when the health checks are tcp", func() {
+				BeforeEach(func() {
+					configObj.HealthCheck.Type = config.HEALTH_CHECK_TCP
+				})
+
+				It("only takes endpoints that do not accept connections out of rotation", func() {
+					Consistently(logger, 100*time.Millisecond).ShouldNot(gbytes.Say(`endpoint-failed-health-checks`))
+
+					failingBackend.Close()
+					Eventually(logger).Should(gbytes.Say(`endpoint-failed-health-checks`))
+					Consistently(next, 100*time.Millisecond, 5*time.Millisecond).Should(Equal(healthy))
+				})
+			})
+		})
 	})
 
 	Context("Varz data", func() {
@@ -1203,6 +1667,25 @@ var _ = Describe("RouteRegistry", func() {
 		})
 	})
 
+	Context("EachEndpoint", func() {
+		It("visits every endpoint of every uri", func() {
+			r.Register("foo", fooEndpoint)
+			r.Register("bar", barEndpoint)
+			r.Register("bar", bar2Endpoint)
+			r.Register("bar/path", barEndpoint)
+
+			visited := map[route.Uri][]string{}
+			r.EachEndpoint(func(uri route.Uri, e *route.Endpoint) {
+				visited[uri] = append(visited[uri], e.CanonicalAddr())
+			})
+
+			Expect(visited).To(HaveLen(3))
+			Expect(visited["foo"]).To(ConsistOf(fooEndpoint.CanonicalAddr()))
+			Expect(visited["bar"]).To(ConsistOf(barEndpoint.CanonicalAddr(), bar2Endpoint.CanonicalAddr()))
+			Expect(visited["bar/path"]).To(ConsistOf(barEndpoint.CanonicalAddr()))
+		})
+	})
+
 	It("marshals", func() {
 		m := route.NewEndpoint(&route.EndpointOpts{
 			Host:                    "192.168.1.1",
@@ -0,0 +1,175 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/route"
+)
+
+// routesQuery selects the routes and endpoints served by the routes endpoint.
+type routesQuery struct {
+	host    string
+	domain  string
+	address string
+	offset  int
+	limit   int
+	fields  map[string]bool
+}
+
+// RoutesHandler serves the routing table as JSON, like MarshalJSON. The query
+// parameters narrow it down: host is a glob, such as *.apps.example.com, that
+// the hostname of the route must match, domain keeps the routes of a domain
+// and its subdomains, and address keeps the endpoints at an address, with or
+// without its port. The routes are ordered by URI, offset skips that many of
+// them, and limit caps how many are returned, while the X-Total-Count header
+// counts all routes that match. fields is a comma-separated list of the fields
+// of the endpoints to return, such as address,tags.
+func RoutesHandler(r *RouteRegistry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		q, err := parseRoutesQuery(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		routes := map[route.Uri][]*route.Endpoint{}
+		r.EachEndpoint(func(uri route.Uri, endpoint *route.Endpoint) {
+			if q.matchesRoute(uri) && q.matchesEndpoint(endpoint) {
+				routes[uri] = append(routes[uri], endpoint)
+			}
+		})
+
+		uris := make([]route.Uri, 0, len(routes))
+		for uri := range routes {
+			uris = append(uris, uri)
+		}
+		sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+
+		total := len(uris)
+		if q.offset < len(uris) {
+			uris = uris[q.offset:]
+		} else {
+			uris = nil
+		}
+		if q.limit > 0 && q.limit < len(uris) {
+			uris = uris[:q.limit]
+		}
+
+		page := make(map[route.Uri][]json.RawMessage, len(uris))
+		for _, uri := range uris {
+			endpoints := make([]json.RawMessage, 0, len(routes[uri]))
+			for _, endpoint := range routes[uri] {
+				b, err := q.marshalEndpoint(endpoint)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				endpoints = append(endpoints, b)
+			}
+			page[uri] = endpoints
+		}
+
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		json.NewEncoder(w).Encode(page)
+	})
+}
+
+func parseRoutesQuery(req *http.Request) (*routesQuery, error) {
+	values := req.URL.Query()
+	q := &routesQuery{
+		host:    strings.ToLower(values.Get("host")),
+		domain:  strings.ToLower(strings.Trim(values.Get("domain"), ".")),
+		address: values.Get("address"),
+	}
+
+	if q.host != "" {
+		if _, err := path.Match(q.host, ""); err != nil {
+			return nil, fmt.Errorf("invalid host: %q", q.host)
+		}
+	}
+
+	for name, n := range map[string]*int{"offset": &q.offset, "limit": &q.limit} {
+		if v := values.Get(name); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*n = i
+		}
+	}
+
+	if v := values.Get("fields"); v != "" {
+		q.fields = map[string]bool{}
+		for _, field := range strings.Split(v, ",") {
+			q.fields[strings.TrimSpace(field)] = true
+		}
+	}
+
+	return q, nil
+}
+
+func (q *routesQuery) matchesRoute(uri route.Uri) bool {
+	hostname := strings.ToLower(uri.String())
+	if i := strings.Index(hostname, "/"); i >= 0 {
+		hostname = hostname[:i]
+	}
+
+	if q.host != "" {
+		if ok, _ := path.Match(q.host, hostname); !ok {
+			return false
+		}
+	}
+	if q.domain != "" && hostname != q.domain && !strings.HasSuffix(hostname, "."+q.domain) {
+		return false
+	}
+	return true
+}
+
+func (q *routesQuery) matchesEndpoint(endpoint *route.Endpoint) bool {
+	if q.address == "" {
+		return true
+	}
+
+	addr := endpoint.CanonicalAddr()
+	if addr == q.address {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && host == q.address
+}
+
+// marshalEndpoint returns the JSON of the endpoint, with only the selected
+// fields if any are.
+func (q *routesQuery) marshalEndpoint(endpoint *route.Endpoint) (json.RawMessage, error) {
+	b, err := json.Marshal(endpoint)
+	if err != nil || q.fields == nil {
+		return b, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	selected := map[string]json.RawMessage{}
+	for field, value := range all {
+		if q.fields[field] {
+			selected[field] = value
+		}
+	}
+	return json.Marshal(selected)
+}
@@ -0,0 +1,124 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/metrics/fakes"
+	. "code.cloudfoundry.org/gorouter/registry"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RoutesHandler", func() {
+	var (
+		r       *RouteRegistry
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		cfg, err := config.DefaultConfig()
+		Expect(err).ToNot(HaveOccurred())
+		r = NewRouteRegistry(test_util.NewTestZapLogger("test"), cfg, new(fakes.FakeRouteRegistryReporter))
+		handler = RoutesHandler(r)
+
+		r.Register("a.apps.example.com", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.1", Port: 8080, Tags: map[string]string{"component": "a"}}))
+		r.Register("a.apps.example.com", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.2", Port: 8080}))
+		r.Register("b.apps.example.com/api", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.1", Port: 9090}))
+		r.Register("c.sys.example.com", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.3", Port: 8080}))
+		r.Register("example.org", route.NewEndpoint(&route.EndpointOpts{Host: "10.0.0.4", Port: 8080}))
+	})
+
+	get := func(query string) (*httptest.ResponseRecorder, map[string][]map[string]interface{}) {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/routes"+query, nil))
+
+		var routes map[string][]map[string]interface{}
+		if rw.Code == http.StatusOK {
+			Expect(json.Unmarshal(rw.Body.Bytes(), &routes)).To(Succeed())
+		}
+		return rw, routes
+	}
+
+	keys := func(routes map[string][]map[string]interface{}) []string {
+		var uris []string
+		for uri := range routes {
+			uris = append(uris, uri)
+		}
+		return uris
+	}
+
+	It("serves the whole routing table without a query", func() {
+		rw, routes := get("")
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header().Get("X-Total-Count")).To(Equal("4"))
+
+		expected, err := r.MarshalJSON()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rw.Body.String()).To(MatchJSON(expected))
+		Expect(routes["a.apps.example.com"]).To(HaveLen(2))
+	})
+
+	It("filters the routes by a hostname glob", func() {
+		_, routes := get("?host=*.APPS.example.com")
+		Expect(keys(routes)).To(ConsistOf("a.apps.example.com", "b.apps.example.com/api"))
+	})
+
+	It("filters the routes by domain", func() {
+		_, routes := get("?domain=example.com")
+		Expect(keys(routes)).To(ConsistOf("a.apps.example.com", "b.apps.example.com/api", "c.sys.example.com"))
+
+		_, routes = get("?domain=sys.example.com")
+		Expect(keys(routes)).To(ConsistOf("c.sys.example.com"))
+	})
+
+	It("filters the endpoints by address, with or without the port", func() {
+		_, routes := get("?address=10.0.0.1")
+		Expect(keys(routes)).To(ConsistOf("a.apps.example.com", "b.apps.example.com/api"))
+		Expect(routes["a.apps.example.com"]).To(HaveLen(1))
+		Expect(routes["a.apps.example.com"][0]["address"]).To(Equal("10.0.0.1:8080"))
+
+		_, routes = get("?address=10.0.0.1:9090")
+		Expect(keys(routes)).To(ConsistOf("b.apps.example.com/api"))
+	})
+
+	It("pages through the routes in order", func() {
+		rw, routes := get("?limit=2")
+		Expect(rw.Header().Get("X-Total-Count")).To(Equal("4"))
+		Expect(keys(routes)).To(ConsistOf("a.apps.example.com", "b.apps.example.com/api"))
+
+		rw, routes = get("?domain=example.com&offset=2&limit=2")
+		Expect(rw.Header().Get("X-Total-Count")).To(Equal("3"))
+		Expect(keys(routes)).To(ConsistOf("c.sys.example.com"))
+
+		_, routes = get("?offset=10")
+		Expect(routes).To(BeEmpty())
+	})
+
+	It("returns only the selected fields", func() {
+		_, routes := get("?host=a.apps.example.com&fields=address,tags")
+		Expect(routes["a.apps.example.com"]).To(ConsistOf(
+			map[string]interface{}{"address": "10.0.0.1:8080", "tags": map[string]interface{}{"component": "a"}},
+			map[string]interface{}{"address": "10.0.0.2:8080", "tags": nil},
+		))
+	})
+
+	It("rejects invalid queries", func() {
+		for _, query := range []string{"?host=[", "?limit=-1", "?offset=x"} {
+			rw, _ := get(query)
+			Expect(rw.Code).To(Equal(http.StatusBadRequest), query)
+		}
+	})
+
+	It("only allows GET", func() {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("POST", "/routes", nil))
+		Expect(rw.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rw.Header().Get("Allow")).To(Equal("GET"))
+	})
+})
@@ -0,0 +1,179 @@
+package route
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+)
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops sending requests to an endpoint whose requests keep
+// failing. The circuit opens after ConsecutiveFailures failed requests in a
+// row, and the endpoint is left alone for OpenTimeout. The circuit is then
+// half-open: up to HalfOpenMaxRequests requests at a time probe the
+// endpoint, and the circuit closes after SuccessThreshold of them succeed,
+// or opens again as soon as one of them fails.
+//
+// The methods of a nil CircuitBreaker do nothing, so that callers need not
+// check whether circuit breaking is enabled.
+type CircuitBreaker struct {
+	lock sync.Mutex
+	cfg  config.CircuitBreakerConfig
+
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+}
+
+func NewCircuitBreaker(cfg config.CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{cfg: cfg}
+}
+
+// State returns the state of the circuit.
+func (c *CircuitBreaker) State() CircuitState {
+	if c == nil {
+		return CircuitClosed
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.halfOpen(time.Now())
+	return c.state
+}
+
+// available reports whether the endpoint can be sent a request, which is
+// the case while the circuit is closed, or half-open with a probe to spare.
+func (c *CircuitBreaker) available(now time.Time) bool {
+	if c == nil {
+		return true
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.halfOpen(now)
+
+	switch c.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		return c.probes < c.cfg.HalfOpenMaxRequests
+	default:
+		return true
+	}
+}
+
+// Begin records that a request to the endpoint started, and reports whether
+// the request probes a half-open circuit. The result must be passed to Done
+// or Cancel when the request finishes.
+func (c *CircuitBreaker) Begin() bool {
+	if c == nil {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.halfOpen(time.Now())
+
+	if c.state != CircuitHalfOpen {
+		return false
+	}
+	c.probes++
+	return true
+}
+
+// Done records whether a request to the endpoint failed, and reports whether
+// the circuit opened as a result.
+func (c *CircuitBreaker) Done(probe, failed bool) bool {
+	if c == nil {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if probe {
+		c.probes--
+		if c.state != CircuitHalfOpen {
+			return false
+		}
+
+		if failed {
+			c.open(time.Now())
+			return true
+		}
+
+		c.successes++
+		if c.successes >= c.cfg.SuccessThreshold {
+			c.state = CircuitClosed
+			c.failures = 0
+		}
+		return false
+	}
+
+	if c.state != CircuitClosed {
+		// requests that started before the circuit opened say nothing about
+		// the endpoint since
+		return false
+	}
+
+	if !failed {
+		c.failures = 0
+		return false
+	}
+
+	c.failures++
+	if c.failures < c.cfg.ConsecutiveFailures {
+		return false
+	}
+	c.open(time.Now())
+	return true
+}
+
+// Cancel records that a request to the endpoint finished without telling
+// whether the endpoint works, such as when the client went away.
+func (c *CircuitBreaker) Cancel(probe bool) {
+	if c == nil || !probe {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.probes--
+}
+
+func (c *CircuitBreaker) open(now time.Time) {
+	c.state = CircuitOpen
+	c.openedAt = now
+	c.failures = 0
+	c.successes = 0
+}
+
+// halfOpen moves an open circuit to half-open once OpenTimeout has passed.
+// The caller must hold the lock.
+func (c *CircuitBreaker) halfOpen(now time.Time) {
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= c.cfg.OpenTimeout {
+		c.state = CircuitHalfOpen
+		c.successes = 0
+	}
+}
@@ -0,0 +1,105 @@
+package route_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/gorouter/config"
+	"code.cloudfoundry.org/gorouter/route"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var breaker *route.CircuitBreaker
+
+	BeforeEach(func() {
+		breaker = route.NewCircuitBreaker(config.CircuitBreakerConfig{
+			Enabled:             true,
+			ConsecutiveFailures: 3,
+			OpenTimeout:         20 * time.Millisecond,
+			HalfOpenMaxRequests: 1,
+			SuccessThreshold:    2,
+		})
+	})
+
+	fail := func(n int) {
+		for i := 0; i < n; i++ {
+			breaker.Done(breaker.Begin(), true)
+		}
+	}
+
+	It("starts closed", func() {
+		Expect(breaker.State()).To(Equal(route.CircuitClosed))
+	})
+
+	It("opens after consecutive failures", func() {
+		fail(2)
+		Expect(breaker.State()).To(Equal(route.CircuitClosed))
+
+		Expect(breaker.Done(breaker.Begin(), true)).To(BeTrue())
+		Expect(breaker.State()).To(Equal(route.CircuitOpen))
+	})
+
+	It("stays closed when failures are interrupted by a success", func() {
+		fail(2)
+		breaker.Done(breaker.Begin(), false)
+		fail(2)
+
+		Expect(breaker.State()).To(Equal(route.CircuitClosed))
+	})
+
+	Context("when the circuit is open", func() {
+		BeforeEach(func() {
+			fail(3)
+		})
+
+		It("becomes half-open after the open timeout", func() {
+			Eventually(breaker.State).Should(Equal(route.CircuitHalfOpen))
+		})
+
+		It("does not count requests that are not probes", func() {
+			Expect(breaker.Begin()).To(BeFalse())
+			Expect(breaker.Done(false, true)).To(BeFalse())
+		})
+	})
+
+	Context("when the circuit is half-open", func() {
+		BeforeEach(func() {
+			fail(3)
+			Eventually(breaker.State).Should(Equal(route.CircuitHalfOpen))
+		})
+
+		It("closes after enough probes succeed", func() {
+			Expect(breaker.Done(breaker.Begin(), false)).To(BeFalse())
+			Expect(breaker.State()).To(Equal(route.CircuitHalfOpen))
+
+			Expect(breaker.Done(breaker.Begin(), false)).To(BeFalse())
+			Expect(breaker.State()).To(Equal(route.CircuitClosed))
+		})
+
+		It("opens again when a probe fails", func() {
+			Expect(breaker.Done(breaker.Begin(), true)).To(BeTrue())
+			Expect(breaker.State()).To(Equal(route.CircuitOpen))
+		})
+
+		It("releases probes that are canceled", func() {
+			probe := breaker.Begin()
+			Expect(probe).To(BeTrue())
+			breaker.Cancel(probe)
+
+			Expect(breaker.Done(breaker.Begin(), false)).To(BeFalse())
+			Expect(breaker.Done(breaker.Begin(), false)).To(BeFalse())
+			Expect(breaker.State()).To(Equal(route.CircuitClosed))
+		})
+	})
+
+	Context("when circuit breaking is disabled", func() {
+		It("does nothing", func() {
+			var breaker *route.CircuitBreaker
+			for i := 0; i < 10; i++ {
+				Expect(breaker.Done(breaker.Begin(), true)).To(BeFalse())
+			}
+			Expect(breaker.State()).To(Equal(route.CircuitClosed))
+		})
+	})
+})
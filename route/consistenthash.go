@@ -0,0 +1,157 @@
+package route
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StickyPathSegmentTag is the route tag that selects endpoints by a segment
+// of the request path. Its value is either the zero-based index of the
+// segment, or a pattern such as "/t/{tenant}" where the segment in braces is
+// the key and the other segments must match the path.
+const StickyPathSegmentTag = "StickyPathSegment"
+
+// ConsistentHash selects endpoints by rendezvous hashing of a key, so that
+// requests with the same key go to the same endpoint while it is available.
+// Adding or removing an endpoint only moves the keys that hash to it.
+type ConsistentHash struct {
+	pool         *Pool
+	key          string
+	tried        map[*Endpoint]bool
+	lastEndpoint *Endpoint
+}
+
+func NewConsistentHash(p *Pool, key string) EndpointIterator {
+	return &ConsistentHash{
+		pool:  p,
+		key:   key,
+		tried: map[*Endpoint]bool{},
+	}
+}
+
+func (r *ConsistentHash) Next() *Endpoint {
+	e := r.next()
+	if e == nil {
+		r.lastEndpoint = nil
+		return nil
+	}
+
+	e.RLock()
+	defer e.RUnlock()
+	r.lastEndpoint = e.endpoint
+	r.tried[e.endpoint] = true
+	return e.endpoint
+}
+
+// next returns the highest ranked endpoint for the key. Endpoints that have
+// failed recently are only used when no other endpoint is available.
+// Preferred endpoints, such as those in the local availability zone, are
+// selected while one of them is available.
+func (r *ConsistentHash) next() *endpointElem {
+	r.pool.Lock()
+	defer r.pool.Unlock()
+
+	now := time.Now()
+	if best, _ := r.rank(now, r.pool.unpreferred(now)); best != nil {
+		return best
+	}
+
+	best, bestFailed := r.rank(now, nil)
+	if best != nil {
+		return best
+	}
+	return bestFailed
+}
+
+// rank returns the highest ranked endpoint that has not been tried, and the
+// highest ranked one among those that failed recently. Endpoints that skip
+// reports are not ranked, unless it is nil.
+func (r *ConsistentHash) rank(now time.Time, skip func(*endpointElem) bool) (best, bestFailed *endpointElem) {
+	var bestScore, bestFailedScore uint64
+	for _, e := range r.pool.endpoints {
+		if r.tried[e.endpoint] || e.isOverloaded() || (skip != nil && skip(e)) {
+			continue
+		}
+
+		score := rendezvousScore(r.key, e.endpoint.CanonicalAddr())
+		if e.failedWithin(r.pool.retryAfterFailure, now) {
+			if bestFailed == nil || score > bestFailedScore {
+				bestFailed, bestFailedScore = e, score
+			}
+			continue
+		}
+		if best == nil || score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	return best, bestFailed
+}
+
+func (r *ConsistentHash) EndpointFailed(err error) {
+	if r.lastEndpoint != nil {
+		r.pool.EndpointFailed(r.lastEndpoint, err)
+	}
+}
+
+func (r *ConsistentHash) PreRequest(e *Endpoint) {
+	e.Stats.NumberConnections.Increment()
+}
+
+func (r *ConsistentHash) PostRequest(e *Endpoint) {
+	e.Stats.NumberConnections.Decrement()
+}
+
+func rendezvousScore(key, addr string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(addr))
+
+	// fnv does not spread similar inputs well, so finish with the
+	// splitmix64 mixer.
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// PathSegmentKey returns the segment of path selected by spec, which has the
+// format of the StickyPathSegment tag. It returns an empty string when spec
+// is invalid or the path does not have the segment.
+func PathSegmentKey(spec, path string) string {
+	if spec == "" {
+		return ""
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	if index, err := strconv.Atoi(spec); err == nil {
+		if index < 0 || index >= len(segments) {
+			return ""
+		}
+		return segments[index]
+	}
+
+	key, found := "", false
+	pattern := strings.Split(strings.TrimPrefix(spec, "/"), "/")
+	if len(pattern) > len(segments) {
+		return ""
+	}
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if found {
+				return ""
+			}
+			key, found = segments[i], true
+			continue
+		}
+		if p != segments[i] {
+			return ""
+		}
+	}
+	return key
+}
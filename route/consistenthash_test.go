@@ -0,0 +1,132 @@
+package route_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsistentHash", func() {
+	var (
+		pool      *route.Pool
+		endpoints []*route.Endpoint
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(&route.PoolOpts{
+			Logger:            test_util.NewTestZapLogger("test"),
+			RetryAfterFailure: 2 * time.Minute,
+		})
+
+		endpoints = nil
+		for i := 0; i < 5; i++ {
+			e := route.NewEndpoint(&route.EndpointOpts{Host: fmt.Sprintf("10.0.1.%d", i), Port: 60000})
+			endpoints = append(endpoints, e)
+			pool.Put(e)
+		}
+	})
+
+	It("selects the same endpoint for the same key", func() {
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("tenant-%d", i)
+			first := route.NewConsistentHash(pool, key).Next()
+			Expect(first).ToNot(BeNil())
+			for j := 0; j < 10; j++ {
+				Expect(route.NewConsistentHash(pool, key).Next()).To(Equal(first))
+			}
+		}
+	})
+
+	It("spreads keys across endpoints", func() {
+		selected := map[*route.Endpoint]bool{}
+		for i := 0; i < 100; i++ {
+			selected[route.NewConsistentHash(pool, fmt.Sprintf("tenant-%d", i)).Next()] = true
+		}
+		Expect(selected).To(HaveLen(len(endpoints)))
+	})
+
+	It("only moves the keys of a removed endpoint", func() {
+		before := map[string]*route.Endpoint{}
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("tenant-%d", i)
+			before[key] = route.NewConsistentHash(pool, key).Next()
+		}
+
+		removed := endpoints[2]
+		pool.Remove(removed)
+
+		for key, e := range before {
+			after := route.NewConsistentHash(pool, key).Next()
+			if e == removed {
+				Expect(after).ToNot(Equal(removed))
+			} else {
+				Expect(after).To(Equal(e))
+			}
+		}
+	})
+
+	It("selects another endpoint on retry", func() {
+		iter := route.NewConsistentHash(pool, "tenant")
+		first := iter.Next()
+		second := iter.Next()
+		Expect(second).ToNot(BeNil())
+		Expect(second).ToNot(Equal(first))
+	})
+
+	It("avoids endpoints that have failed", func() {
+		iter := route.NewConsistentHash(pool, "tenant")
+		first := iter.Next()
+		iter.EndpointFailed(&net.OpError{Op: "dial"})
+
+		Expect(route.NewConsistentHash(pool, "tenant").Next()).ToNot(Equal(first))
+	})
+
+	Context("when all endpoints have failed", func() {
+		It("still selects an endpoint", func() {
+			for range endpoints {
+				iter := route.NewConsistentHash(pool, "tenant")
+				iter.Next()
+				iter.EndpointFailed(&net.OpError{Op: "dial"})
+			}
+			Expect(route.NewConsistentHash(pool, "tenant").Next()).ToNot(BeNil())
+		})
+	})
+
+	Context("when the pool is empty", func() {
+		It("does not select an endpoint", func() {
+			empty := route.NewPool(&route.PoolOpts{Logger: new(fakes.FakeLogger)})
+			Expect(route.NewConsistentHash(empty, "tenant").Next()).To(BeNil())
+		})
+	})
+
+	It("does not mark endpoints as failed for errors that are not failable", func() {
+		iter := route.NewConsistentHash(pool, "tenant")
+		first := iter.Next()
+		iter.EndpointFailed(errors.New("not failable"))
+
+		Expect(route.NewConsistentHash(pool, "tenant").Next()).To(Equal(first))
+	})
+})
+
+var _ = DescribeTable("PathSegmentKey",
+	func(spec, path, expected string) {
+		Expect(route.PathSegmentKey(spec, path)).To(Equal(expected))
+	},
+	Entry("no spec", "", "/t/acme/orders", ""),
+	Entry("an index", "1", "/t/acme/orders", "acme"),
+	Entry("an index past the end of the path", "3", "/t/acme/orders", ""),
+	Entry("a negative index", "-1", "/t/acme/orders", ""),
+	Entry("a pattern", "/t/{tenant}", "/t/acme/orders", "acme"),
+	Entry("a pattern without a leading slash", "t/{tenant}", "/t/acme", "acme"),
+	Entry("a pattern that does not match", "/t/{tenant}", "/u/acme/orders", ""),
+	Entry("a pattern longer than the path", "/t/{tenant}/orders", "/t/acme", ""),
+	Entry("a pattern with two keys", "/{a}/{b}", "/t/acme", ""),
+)
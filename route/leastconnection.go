@@ -85,21 +85,59 @@ func (r *LeastConnection) next() *endpointElem {
 	// random one within the least connection endpoints
 	randIndices := randomize.Perm(total)
 
-	for i := 0; i < total; i++ {
+	selected = r.leastConnected(randIndices, true)
+	if selected == nil {
+		// all endpoints are marked failed so reset everything to available
+		for _, e := range r.pool.endpoints {
+			e.failedAt = nil
+		}
+		selected = r.leastConnected(randIndices, false)
+	}
+	return selected
+}
+
+// leastConnected selects the endpoint with the fewest connections per unit of
+// weight. In a weighted pool, ties are broken at random in proportion to the
+// weights of the tied endpoints.
+func (r *LeastConnection) leastConnected(randIndices []int, skipFailed bool) *endpointElem {
+	var selected *endpointElem
+	var tiedWeight int
+	now := time.Now()
+	weighted := r.pool.isWeighted()
+	unpreferred := r.pool.unpreferred(now)
+
+	for i := 0; i < len(randIndices); i++ {
 		randIdx := randIndices[i]
 		cur := r.pool.endpoints[randIdx]
-		if cur.isOverloaded() {
+		if cur.isOverloaded() || unpreferred(cur) {
 			continue
 		}
 
+		if skipFailed && cur.failedWithin(r.pool.retryAfterFailure, now) {
+			continue
+		}
+
+		weight := cur.endpoint.weight()
+
 		// our first is the least
 		if i == 0 || selected == nil {
 			selected = cur
+			tiedWeight = weight
 			continue
 		}
 
-		if cur.endpoint.Stats.NumberConnections.Count() < selected.endpoint.Stats.NumberConnections.Count() {
+		// compare connections/weight without dividing
+		curLoad := cur.endpoint.Stats.NumberConnections.Count() * int64(selected.endpoint.weight())
+		selectedLoad := selected.endpoint.Stats.NumberConnections.Count() * int64(weight)
+		switch {
+		case curLoad < selectedLoad:
 			selected = cur
+			tiedWeight = weight
+		case curLoad == selectedLoad && weighted:
+			tiedWeight += weight
+			if randomize.Intn(tiedWeight) < weight {
+				selected = cur
+			}
 		}
 	}
 	return selected
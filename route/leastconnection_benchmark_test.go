@@ -40,6 +40,8 @@ func loadBalanceFor(strategy string, b *testing.B) {
 		lb = route.NewRoundRobin(pool, "")
 	case "least-connection":
 		lb = route.NewLeastConnection(pool, "")
+	case "least-latency":
+		lb = route.NewLeastLatency(pool, "")
 	default:
 		panic("invalid load balancing strategy")
 	}
@@ -53,6 +55,10 @@ func BenchmarkLeastConnection(b *testing.B) {
 	loadBalanceFor("least-connection", b)
 }
 
+func BenchmarkLeastLatency(b *testing.B) {
+	loadBalanceFor("least-latency", b)
+}
+
 func BenchmarkRoundRobin(b *testing.B) {
 	loadBalanceFor("round-robin", b)
 }
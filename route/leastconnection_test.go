@@ -2,10 +2,12 @@ package route_test
 
 import (
 	"fmt"
+	"net"
 	"time"
 
 	"code.cloudfoundry.org/gorouter/logger/fakes"
 	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -129,6 +131,45 @@ var _ = Describe("LeastConnection", func() {
 				})
 			})
 
+			Context("when endpoints have weights", func() {
+				var heavy, light *route.Endpoint
+
+				BeforeEach(func() {
+					pool = route.NewPool(&route.PoolOpts{
+						Logger:            new(fakes.FakeLogger),
+						RetryAfterFailure: 2 * time.Minute,
+					})
+					light = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.2.0", Port: 60000, Weight: 1})
+					heavy = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.2.1", Port: 60000, Weight: 4})
+					pool.Put(light)
+					pool.Put(heavy)
+					endpoints = []*route.Endpoint{light, heavy}
+				})
+
+				It("selects the endpoint with the fewest connections per unit of weight", func() {
+					iter := route.NewLeastConnection(pool, "")
+
+					setConnectionCount(endpoints, []int{1, 3})
+					Expect(iter.Next()).To(Equal(heavy))
+
+					setConnectionCount(endpoints, []int{1, 5})
+					Expect(iter.Next()).To(Equal(light))
+				})
+
+				It("breaks ties in proportion to the weights", func() {
+					iter := route.NewLeastConnection(pool, "")
+					setConnectionCount(endpoints, []int{0, 0})
+
+					counts := map[*route.Endpoint]int{}
+					for i := 0; i < 1000; i++ {
+						counts[iter.Next()]++
+					}
+
+					Expect(counts[heavy]).To(BeNumerically(">", 700))
+					Expect(counts[light]).To(BeNumerically(">", 100))
+				})
+			})
+
 			Context("when some endpoints are overloaded", func() {
 				var (
 					epOne, epTwo *route.Endpoint
@@ -210,6 +251,60 @@ var _ = Describe("LeastConnection", func() {
 		})
 	})
 
+	Describe("Failed", func() {
+		var e1, e2 *route.Endpoint
+
+		BeforeEach(func() {
+			pool = route.NewPool(&route.PoolOpts{
+				Logger:             test_util.NewTestZapLogger("test"),
+				RetryAfterFailure:  100 * time.Millisecond,
+				Host:               "",
+				ContextPath:        "",
+				MaxConnsPerBackend: 0,
+			})
+
+			e1 = route.NewEndpoint(&route.EndpointOpts{Host: "1.2.3.4", Port: 1234})
+			e2 = route.NewEndpoint(&route.EndpointOpts{Host: "5.6.7.8", Port: 5678})
+			pool.Put(e1)
+			pool.Put(e2)
+		})
+
+		It("skips a failed endpoint for the cooldown, then selects it again", func() {
+			iter := route.NewLeastConnection(pool, "")
+			failed := iter.Next()
+			Expect(failed).ToNot(BeNil())
+			iter.EndpointFailed(&net.OpError{Op: "dial"})
+
+			healthy := e1
+			if failed == e1 {
+				healthy = e2
+			}
+
+			// keep the healthy endpoint busier so that only the failure
+			// prevents the failed endpoint from being selected
+			healthy.Stats.NumberConnections.Increment()
+
+			Consistently(func() *route.Endpoint {
+				return route.NewLeastConnection(pool, "").Next()
+			}, 50*time.Millisecond, 5*time.Millisecond).Should(Equal(healthy))
+
+			Eventually(func() *route.Endpoint {
+				return route.NewLeastConnection(pool, "").Next()
+			}).Should(Equal(failed))
+		})
+
+		It("resets when all endpoints are failed", func() {
+			iter := route.NewLeastConnection(pool, "")
+			n1 := iter.Next()
+			iter.EndpointFailed(&net.OpError{Op: "dial"})
+			n2 := iter.Next()
+			Expect(n2).ToNot(Equal(n1))
+			iter.EndpointFailed(&net.OpError{Op: "dial"})
+
+			Expect(iter.Next()).ToNot(BeNil())
+		})
+	})
+
 	Context("PreRequest", func() {
 		It("increments the NumberConnections counter", func() {
 			endpointFoo := route.NewEndpoint(&route.EndpointOpts{Host: "1.2.3.4"})
@@ -0,0 +1,133 @@
+package route
+
+import (
+	"time"
+)
+
+// LeastLatency selects the endpoint with the lowest expected latency, which
+// is the moving average of its response times multiplied by the requests it
+// is already serving. Faster endpoints receive more requests until they
+// queue up as much work as the slower ones.
+type LeastLatency struct {
+	pool            *Pool
+	initialEndpoint string
+	lastEndpoint    *Endpoint
+	requestStart    time.Time
+}
+
+func NewLeastLatency(p *Pool, initial string) EndpointIterator {
+	return &LeastLatency{
+		pool:            p,
+		initialEndpoint: initial,
+	}
+}
+
+func (r *LeastLatency) Next() *Endpoint {
+	var e *endpointElem
+	if r.initialEndpoint != "" {
+		e = r.pool.findById(r.initialEndpoint)
+		r.initialEndpoint = ""
+
+		if e != nil && e.isOverloaded() {
+			e = nil
+		}
+	}
+
+	if e == nil {
+		e = r.next()
+	}
+
+	if e != nil {
+		e.RLock()
+		defer e.RUnlock()
+		r.lastEndpoint = e.endpoint
+		return e.endpoint
+	}
+
+	r.lastEndpoint = nil
+	return nil
+}
+
+func (r *LeastLatency) PreRequest(e *Endpoint) {
+	e.Stats.NumberConnections.Increment()
+	r.requestStart = time.Now()
+}
+
+func (r *LeastLatency) PostRequest(e *Endpoint) {
+	e.Stats.NumberConnections.Decrement()
+	e.Stats.ResponseTime.Observe(time.Since(r.requestStart))
+}
+
+func (r *LeastLatency) next() *endpointElem {
+	r.pool.Lock()
+	defer r.pool.Unlock()
+
+	if len(r.pool.endpoints) == 0 {
+		return nil
+	}
+
+	randIndices := randomize.Perm(len(r.pool.endpoints))
+	selected := r.leastLatency(randIndices, true)
+	if selected == nil {
+		// all endpoints are marked failed so reset everything to available
+		for _, e := range r.pool.endpoints {
+			e.failedAt = nil
+		}
+		selected = r.leastLatency(randIndices, false)
+	}
+	return selected
+}
+
+// leastLatency selects the endpoint with the lowest expected latency per unit
+// of weight. Endpoints that have not responded yet are expected to be as fast
+// as the fastest endpoint, so that they are tried without taking all requests
+// until their first response.
+func (r *LeastLatency) leastLatency(randIndices []int, skipFailed bool) *endpointElem {
+	now := time.Now()
+	candidates := make([]*endpointElem, 0, len(randIndices))
+	var fastest time.Duration
+	unpreferred := r.pool.unpreferred(now)
+	for _, i := range randIndices {
+		e := r.pool.endpoints[i]
+		if e.isOverloaded() || unpreferred(e) {
+			continue
+		}
+		if skipFailed && e.failedWithin(r.pool.retryAfterFailure, now) {
+			continue
+		}
+		candidates = append(candidates, e)
+
+		avg := e.endpoint.Stats.ResponseTime.Average()
+		if avg > 0 && (fastest == 0 || avg < fastest) {
+			fastest = avg
+		}
+	}
+
+	if fastest == 0 {
+		// no endpoint has responded yet, so select by connections alone
+		fastest = 1
+	}
+
+	var selected *endpointElem
+	var selectedCost float64
+	for _, e := range candidates {
+		avg := e.endpoint.Stats.ResponseTime.Average()
+		if avg == 0 {
+			avg = fastest
+		}
+		conns := e.endpoint.Stats.NumberConnections.Count()
+		cost := float64(avg) * float64(conns+1) / float64(e.endpoint.weight())
+
+		if selected == nil || cost < selectedCost {
+			selected = e
+			selectedCost = cost
+		}
+	}
+	return selected
+}
+
+func (r *LeastLatency) EndpointFailed(err error) {
+	if r.lastEndpoint != nil {
+		r.pool.EndpointFailed(r.lastEndpoint, err)
+	}
+}
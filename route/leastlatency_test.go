@@ -0,0 +1,146 @@
+package route_test
+
+import (
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/logger/fakes"
+	"code.cloudfoundry.org/gorouter/route"
+	"code.cloudfoundry.org/gorouter/test_util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeastLatency", func() {
+	var (
+		pool       *route.Pool
+		fast, slow *route.Endpoint
+	)
+
+	BeforeEach(func() {
+		pool = route.NewPool(&route.PoolOpts{
+			Logger:             test_util.NewTestZapLogger("test"),
+			RetryAfterFailure:  2 * time.Minute,
+			MaxConnsPerBackend: 0,
+		})
+		fast = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.0", Port: 60000})
+		slow = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000})
+		pool.Put(fast)
+		pool.Put(slow)
+	})
+
+	Describe("Next", func() {
+		It("returns nil when no endpoints exist", func() {
+			iter := route.NewLeastLatency(route.NewPool(&route.PoolOpts{Logger: new(fakes.FakeLogger)}), "")
+			Expect(iter.Next()).To(BeNil())
+		})
+
+		It("selects the endpoint with the lowest response time", func() {
+			fast.Stats.ResponseTime.Observe(10 * time.Millisecond)
+			slow.Stats.ResponseTime.Observe(100 * time.Millisecond)
+
+			iter := route.NewLeastLatency(pool, "")
+			for i := 0; i < 10; i++ {
+				Expect(iter.Next()).To(Equal(fast))
+			}
+		})
+
+		It("selects a slower endpoint once the faster one is busy", func() {
+			fast.Stats.ResponseTime.Observe(10 * time.Millisecond)
+			slow.Stats.ResponseTime.Observe(30 * time.Millisecond)
+			fast.Stats.NumberConnections = route.NewCounter(3)
+
+			iter := route.NewLeastLatency(pool, "")
+			Expect(iter.Next()).To(Equal(slow))
+		})
+
+		It("tries endpoints that have not responded yet", func() {
+			fast.Stats.ResponseTime.Observe(10 * time.Millisecond)
+			slow.Stats.ResponseTime.Observe(100 * time.Millisecond)
+			fresh := route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000})
+			pool.Put(fresh)
+			fast.Stats.NumberConnections = route.NewCounter(1)
+
+			iter := route.NewLeastLatency(pool, "")
+			Expect(iter.Next()).To(Equal(fresh))
+		})
+
+		It("selects by connections when no endpoint has responded", func() {
+			fast.Stats.NumberConnections = route.NewCounter(2)
+
+			iter := route.NewLeastLatency(pool, "")
+			Expect(iter.Next()).To(Equal(slow))
+		})
+
+		It("accounts for the weights of the endpoints", func() {
+			heavy := route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, Weight: 4})
+			pool.Put(heavy)
+			fast.Stats.ResponseTime.Observe(10 * time.Millisecond)
+			heavy.Stats.ResponseTime.Observe(20 * time.Millisecond)
+
+			iter := route.NewLeastLatency(pool, "")
+			Expect(iter.Next()).To(Equal(heavy))
+		})
+
+		It("finds the initial endpoint", func() {
+			fast.Stats.ResponseTime.Observe(10 * time.Millisecond)
+			slow.Stats.ResponseTime.Observe(100 * time.Millisecond)
+
+			iter := route.NewLeastLatency(pool, slow.CanonicalAddr())
+			Expect(iter.Next()).To(Equal(slow))
+			Expect(iter.Next()).To(Equal(fast))
+		})
+
+		It("skips failed endpoints", func() {
+			fast.Stats.ResponseTime.Observe(10 * time.Millisecond)
+			slow.Stats.ResponseTime.Observe(100 * time.Millisecond)
+
+			iter := route.NewLeastLatency(pool, "")
+			Expect(iter.Next()).To(Equal(fast))
+			iter.EndpointFailed(&net.OpError{Op: "dial"})
+
+			Expect(iter.Next()).To(Equal(slow))
+		})
+
+		It("returns nil when all endpoints are overloaded", func() {
+			pool = route.NewPool(&route.PoolOpts{
+				Logger:             new(fakes.FakeLogger),
+				RetryAfterFailure:  2 * time.Minute,
+				MaxConnsPerBackend: 1,
+			})
+			pool.Put(fast)
+			fast.Stats.NumberConnections = route.NewCounter(1)
+
+			iter := route.NewLeastLatency(pool, "")
+			Expect(iter.Next()).To(BeNil())
+		})
+	})
+
+	Describe("PostRequest", func() {
+		It("records the response time of the request", func() {
+			iter := route.NewLeastLatency(pool, "")
+			iter.PreRequest(fast)
+			Expect(fast.Stats.NumberConnections.Count()).To(Equal(int64(1)))
+			time.Sleep(5 * time.Millisecond)
+			iter.PostRequest(fast)
+
+			Expect(fast.Stats.NumberConnections.Count()).To(Equal(int64(0)))
+			Expect(fast.Stats.ResponseTime.Average()).To(BeNumerically(">=", 5*time.Millisecond))
+		})
+	})
+})
+
+var _ = Describe("ResponseTime", func() {
+	It("is zero until a response is observed", func() {
+		Expect((&route.ResponseTime{}).Average()).To(BeZero())
+	})
+
+	It("moves the average toward new observations", func() {
+		r := &route.ResponseTime{}
+		r.Observe(100 * time.Millisecond)
+		Expect(r.Average()).To(Equal(100 * time.Millisecond))
+
+		r.Observe(200 * time.Millisecond)
+		Expect(r.Average()).To(Equal(130 * time.Millisecond))
+	})
+})
@@ -0,0 +1,78 @@
+package route
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RouteMatch restricts the requests that endpoints registered with it are
+// sent to, on top of the host and path of their route, so that endpoints of
+// one route can serve different methods or versions of an API.
+type RouteMatch struct {
+	// Methods are the methods of the requests, or any method if empty.
+	Methods []string
+	// Headers are the values that headers of the requests must have.
+	Headers map[string]string
+}
+
+// NewRouteMatch returns a RouteMatch for the methods and headers, whose case
+// does not matter.
+func NewRouteMatch(methods []string, headers map[string]string) RouteMatch {
+	var m RouteMatch
+	for _, method := range methods {
+		m.Methods = append(m.Methods, strings.ToUpper(method))
+	}
+	sort.Strings(m.Methods)
+	if len(headers) > 0 {
+		m.Headers = make(map[string]string, len(headers))
+		for name, value := range headers {
+			m.Headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	return m
+}
+
+func (m RouteMatch) IsEmpty() bool {
+	return len(m.Methods) == 0 && len(m.Headers) == 0
+}
+
+// Matches reports whether the request has one of the methods, if any, and
+// all of the headers with their values.
+func (m RouteMatch) Matches(req *http.Request) bool {
+	if len(m.Methods) > 0 {
+		i := sort.SearchStrings(m.Methods, req.Method)
+		if i == len(m.Methods) || m.Methods[i] != req.Method {
+			return false
+		}
+	}
+	for name, value := range m.Headers {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// key identifies the endpoints registered with the same match.
+func (m RouteMatch) key() string {
+	headers := make([]string, 0, len(m.Headers))
+	for name, value := range m.Headers {
+		headers = append(headers, strconv.Quote(name)+":"+strconv.Quote(value))
+	}
+	sort.Strings(headers)
+	return strings.Join(m.Methods, ",") + "|" + strings.Join(headers, ",")
+}
+
+// moreSpecific reports whether m is tried before other: matches on more
+// headers are tried first, then matches on methods.
+func (m RouteMatch) moreSpecific(other RouteMatch) bool {
+	if len(m.Headers) != len(other.Headers) {
+		return len(m.Headers) > len(other.Headers)
+	}
+	if (len(m.Methods) > 0) != (len(other.Methods) > 0) {
+		return len(m.Methods) > 0
+	}
+	return m.key() < other.key()
+}
@@ -0,0 +1,43 @@
+package route_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/gorouter/route"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RouteMatch", func() {
+	var req *http.Request
+
+	BeforeEach(func() {
+		var err error
+		req, err = http.NewRequest("GET", "http://example.com/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("X-Api-Version", "2")
+	})
+
+	It("matches any request when it is empty", func() {
+		match := route.NewRouteMatch(nil, nil)
+		Expect(match.IsEmpty()).To(BeTrue())
+		Expect(match.Matches(req)).To(BeTrue())
+	})
+
+	It("matches requests with one of its methods, whatever their case was registered in", func() {
+		Expect(route.NewRouteMatch([]string{"put", "get"}, nil).Matches(req)).To(BeTrue())
+		Expect(route.NewRouteMatch([]string{"PUT", "POST"}, nil).Matches(req)).To(BeFalse())
+	})
+
+	It("matches requests with all of its headers", func() {
+		Expect(route.NewRouteMatch(nil, map[string]string{"x-api-version": "2"}).Matches(req)).To(BeTrue())
+		Expect(route.NewRouteMatch(nil, map[string]string{"X-Api-Version": "1"}).Matches(req)).To(BeFalse())
+		Expect(route.NewRouteMatch(nil, map[string]string{"X-Api-Version": "2", "X-Tenant": "a"}).Matches(req)).To(BeFalse())
+	})
+
+	It("matches requests with both its methods and headers", func() {
+		Expect(route.NewRouteMatch([]string{"GET"}, map[string]string{"X-Api-Version": "2"}).Matches(req)).To(BeTrue())
+		Expect(route.NewRouteMatch([]string{"PUT"}, map[string]string{"X-Api-Version": "2"}).Matches(req)).To(BeFalse())
+	})
+})
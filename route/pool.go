@@ -1,10 +1,14 @@
 package route
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -43,13 +47,315 @@ func (c *Counter) Count() int64 {
 	return atomic.LoadInt64(&c.value)
 }
 
+// responseTimeDecay is the weight of each new sample in the moving average of
+// response times.
+const responseTimeDecay = 0.3
+
+// ResponseTime is an exponentially weighted moving average of the time an
+// endpoint takes to respond.
+type ResponseTime struct {
+	value int64
+}
+
+// Observe adds the time a request took to the average.
+func (r *ResponseTime) Observe(d time.Duration) {
+	if d <= 0 {
+		d = 1
+	}
+	for {
+		old := atomic.LoadInt64(&r.value)
+		next := int64(d)
+		if old != 0 {
+			next = old + int64(responseTimeDecay*float64(int64(d)-old))
+		}
+		if atomic.CompareAndSwapInt64(&r.value, old, next) {
+			return
+		}
+	}
+}
+
+// Average returns the moving average, or zero if no response has been
+// observed.
+func (r *ResponseTime) Average() time.Duration {
+	return time.Duration(atomic.LoadInt64(&r.value))
+}
+
+// Protocols that a backend may register to be proxied with.
+const (
+	ProtocolHTTP1 = "http1"
+	ProtocolHTTP2 = "http2"
+)
+
+// Versions of the PROXY protocol header that Gorouter may prepend to backend
+// connections. ProxyProtocolNone lets a backend opt out of a version
+// configured for all backends.
+const (
+	ProxyProtocolNone = "none"
+	ProxyProtocolV1   = "v1"
+	ProxyProtocolV2   = "v2"
+)
+
+// Values of JWTValidation. Routes that register JWTValidationRequired must be
+// sent a valid bearer token, and routes that register JWTValidationNone are
+// not, even when Gorouter validates tokens for all routes.
+const (
+	JWTValidationRequired = "required"
+	JWTValidationNone     = "none"
+)
+
+// ProxyProtocolVersion returns the version of the PROXY protocol header to
+// send to a backend that registered the given version, which is
+// defaultVersion when it registered none. It returns "" when no header is
+// sent.
+func ProxyProtocolVersion(registered, defaultVersion string) string {
+	switch registered {
+	case "":
+		return defaultVersion
+	case ProxyProtocolNone:
+		return ""
+	default:
+		return registered
+	}
+}
+
+// ClientCertAllowlist lists the subject alternative names and organizational
+// units of the client certificates that may send requests to a route.
+type ClientCertAllowlist struct {
+	SANs []string
+	OUs  []string
+
+	// none is set when allowlists that have nothing in common were combined
+	none bool
+}
+
+// IsEmpty reports whether the allowlist lists nothing, which allows any
+// client.
+func (a ClientCertAllowlist) IsEmpty() bool {
+	return !a.none && len(a.SANs) == 0 && len(a.OUs) == 0
+}
+
+// restrict returns the allowlist of the names and units that both a and o
+// list, which allows no client when they list none in common.
+func (a ClientCertAllowlist) restrict(o ClientCertAllowlist) ClientCertAllowlist {
+	if o.IsEmpty() {
+		return a
+	}
+	if a.IsEmpty() {
+		return o
+	}
+
+	restricted := ClientCertAllowlist{
+		SANs: intersect(a.SANs, o.SANs, true),
+		OUs:  intersect(a.OUs, o.OUs, false),
+	}
+	restricted.none = len(restricted.SANs) == 0 && len(restricted.OUs) == 0
+	return restricted
+}
+
+// Allows reports whether cert has one of the subject alternative names or
+// organizational units of the allowlist.
+func (a ClientCertAllowlist) Allows(cert *x509.Certificate) bool {
+	names := append([]string{}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+
+	for _, allowed := range a.SANs {
+		for _, name := range names {
+			if strings.EqualFold(name, allowed) {
+				return true
+			}
+		}
+	}
+	for _, allowed := range a.OUs {
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if ou == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IPAccessList restricts the client IPs that may send requests to a route.
+// IPs in Deny are rejected, and so are IPs outside of Allow unless it is
+// empty.
+type IPAccessList struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// IsEmpty reports whether the list restricts nothing.
+func (l IPAccessList) IsEmpty() bool {
+	return len(l.Allow) == 0 && len(l.Deny) == 0
+}
+
+// Allows reports whether ip may send requests.
+func (l IPAccessList) Allows(ip net.IP) bool {
+	for _, ipNet := range l.Deny {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	if len(l.Allow) == 0 {
+		return true
+	}
+	for _, ipNet := range l.Allow {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// restrict returns the list that allows only the IPs that both l and o allow.
+func (l IPAccessList) restrict(o IPAccessList) IPAccessList {
+	restricted := IPAccessList{Deny: append([]*net.IPNet(nil), l.Deny...)}
+	for _, denied := range o.Deny {
+		if !containsIPNet(restricted.Deny, denied) {
+			restricted.Deny = append(restricted.Deny, denied)
+		}
+	}
+
+	switch {
+	case len(o.Allow) == 0:
+		restricted.Allow = l.Allow
+	case len(l.Allow) == 0:
+		restricted.Allow = o.Allow
+	default:
+		// CIDRs either contain one another or have no IPs in common
+		for _, a := range l.Allow {
+			for _, b := range o.Allow {
+				switch {
+				case ipNetCovers(a, b):
+					restricted.Allow = append(restricted.Allow, b)
+				case ipNetCovers(b, a):
+					restricted.Allow = append(restricted.Allow, a)
+				}
+			}
+		}
+		if len(restricted.Allow) == 0 {
+			restricted.Deny = append(restricted.Deny, allIPv4, allIPv6)
+		}
+	}
+	return restricted
+}
+
+var (
+	allIPv4 = &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+	allIPv6 = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+)
+
+// ipNetCovers reports whether all the IPs of b are in a.
+func ipNetCovers(a, b *net.IPNet) bool {
+	aOnes, aBits := a.Mask.Size()
+	bOnes, bBits := b.Mask.Size()
+	return aBits == bBits && aOnes <= bOnes && a.Contains(b.IP)
+}
+
+func containsIPNet(nets []*net.IPNet, ipNet *net.IPNet) bool {
+	for _, n := range nets {
+		if n.String() == ipNet.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimit is the rate of requests that a route accepts: PerSecond requests
+// a second on average, and up to Burst at once.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// CORSPolicy is how Gorouter answers cross-origin requests to a route on
+// behalf of its backends. AllowedOrigins, AllowedMethods and AllowedHeaders
+// may contain "*" to allow any value.
+type CORSPolicy struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	MaxAge         time.Duration
+
+	// none is set when policies that allow no origin in common were
+	// combined, so that Gorouter allows no cross-origin request
+	none bool
+}
+
+// IsEmpty reports whether the policy allows no origins, in which case
+// Gorouter leaves cross-origin requests to the backends.
+func (c CORSPolicy) IsEmpty() bool {
+	return !c.none && len(c.AllowedOrigins) == 0
+}
+
+// restrict returns the policy that allows only what both c and o allow.
+// Policies that list no methods or headers allow only the simple ones.
+func (c CORSPolicy) restrict(o CORSPolicy) CORSPolicy {
+	if o.IsEmpty() {
+		return c
+	}
+	if c.IsEmpty() {
+		return o
+	}
+
+	restricted := CORSPolicy{
+		AllowedOrigins: intersectWildcard(c.AllowedOrigins, o.AllowedOrigins, false),
+		AllowedMethods: intersectWildcard(c.AllowedMethods, o.AllowedMethods, false),
+		AllowedHeaders: intersectWildcard(c.AllowedHeaders, o.AllowedHeaders, true),
+		MaxAge:         c.MaxAge,
+	}
+	if o.MaxAge < restricted.MaxAge {
+		restricted.MaxAge = o.MaxAge
+	}
+	restricted.none = len(restricted.AllowedOrigins) == 0
+	return restricted
+}
+
+// intersectWildcard returns the values that both a and b list, where "*"
+// stands for any value.
+func intersectWildcard(a, b []string, fold bool) []string {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	if len(intersect(a, []string{"*"}, false)) > 0 {
+		return b
+	}
+	if len(intersect(b, []string{"*"}, false)) > 0 {
+		return a
+	}
+	return intersect(a, b, fold)
+}
+
+// intersect returns the values of a that b lists too, compared without case
+// when fold is set. It returns an empty slice rather than nil when there are
+// none.
+func intersect(a, b []string, fold bool) []string {
+	common := []string{}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y || fold && strings.EqualFold(x, y) {
+				common = append(common, x)
+				break
+			}
+		}
+	}
+	return common
+}
+
 type Stats struct {
 	NumberConnections *Counter
+	ResponseTime      *ResponseTime
 }
 
 func NewStats() *Stats {
 	return &Stats{
 		NumberConnections: &Counter{},
+		ResponseTime:      &ResponseTime{},
 	}
 }
 
@@ -70,11 +376,36 @@ type Endpoint struct {
 	ModificationTag      models.ModificationTag
 	Stats                *Stats
 	IsolationSegment     string
+	ClientCertName       string
+	Protocol             string
+	ProxyProtocol        string
+	ForwardedClientCert  string
+	AllowedClientCerts   ClientCertAllowlist
+	ClientIPAccess       IPAccessList
+	JWTValidation        string
+	JWTAudiences         []string
+	RateLimit            RateLimit
+	MaxRequestBodyBytes  int64
+	CORS                 CORSPolicy
+	HostRewrite          string
+	StripPathPrefix      bool
+	RewritePath          string
+	Match                RouteMatch
+	Group                string
+	GroupPercent         int
+	MirrorHost           string
+	MirrorPercent        int
+	Provisional          bool
+	Weight               int
+	BalancingAlgorithm   string
+	AvailabilityZone     string
+	Timeout              time.Duration
 	useTls               bool
 	roundTripper         ProxyRoundTripper
 	roundTripperMutex    sync.RWMutex
 	UpdatedAt            time.Time
 	RoundTripperInit     sync.Once
+	lastServed           int64
 }
 
 func (e *Endpoint) RoundTripper() ProxyRoundTripper {
@@ -100,6 +431,19 @@ func (e *Endpoint) SetRoundTripperIfNil(roundTripperCtor func() ProxyRoundTrippe
 	}
 }
 
+// MarkServed records that the endpoint has just handled a request
+// successfully.
+func (e *Endpoint) MarkServed() {
+	atomic.StoreInt64(&e.lastServed, time.Now().UnixNano())
+}
+
+// LastServed returns the last time the endpoint handled a request
+// successfully, or the time it was added to a pool if it has not served one
+// since.
+func (e *Endpoint) LastServed() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&e.lastServed))
+}
+
 //go:generate counterfeiter -o fakes/fake_endpoint_iterator.go . EndpointIterator
 type EndpointIterator interface {
 	Next() *Endpoint
@@ -115,6 +459,21 @@ type endpointElem struct {
 	updated            time.Time
 	failedAt           *time.Time
 	maxConnsPerBackend int64
+
+	// currentWeight is the state of smooth weighted round-robin
+	currentWeight int
+
+	// unhealthy is set while the endpoint fails its active health checks
+	unhealthy bool
+
+	// consecutiveFailures, ejections and ejectedUntil are the state of
+	// outlier detection
+	consecutiveFailures int
+	ejections           int
+	ejectedUntil        time.Time
+
+	// breaker is nil unless circuit breaking is enabled
+	breaker *CircuitBreaker
 }
 
 type Pool struct {
@@ -130,6 +489,25 @@ type Pool struct {
 	nextIdx            int
 	maxConnsPerBackend int64
 
+	// localAvailabilityZone is the zone whose endpoints are preferred, or
+	// empty if none is.
+	localAvailabilityZone string
+
+	outlierDetection config.OutlierDetectionConfig
+	circuitBreaker   config.CircuitBreakerConfig
+
+	// match and group are those of the endpoints of a pool in subpools, and
+	// route is the pool of their route, whose endpoints' policies apply to
+	// them
+	match RouteMatch
+	group string
+	route *Pool
+	// subpools holds the endpoints registered with a match or in a group in
+	// a pool for each match and group, ordered by the precedence of their
+	// match. It is replaced rather than modified, so that it can be read
+	// after releasing the lock.
+	subpools []*Pool
+
 	random *rand.Rand
 	logger logger.Logger
 }
@@ -146,6 +524,36 @@ type EndpointOpts struct {
 	RouteServiceUrl         string
 	ModificationTag         models.ModificationTag
 	IsolationSegment        string
+	ClientCertName          string
+	Protocol                string
+	ProxyProtocol           string
+	ForwardedClientCert     string
+	AllowedClientCertSANs   []string
+	AllowedClientCertOUs    []string
+	AllowedClientNets       []*net.IPNet
+	DeniedClientNets        []*net.IPNet
+	JWTValidation           string
+	JWTAudiences            []string
+	RateLimitPerSecond      float64
+	RateLimitBurst          int
+	MaxRequestBodyBytes     int64
+	CORSAllowedOrigins      []string
+	CORSAllowedMethods      []string
+	CORSAllowedHeaders      []string
+	CORSMaxAgeInSeconds     int
+	HostRewrite             string
+	StripPathPrefix         bool
+	RewritePath             string
+	MatchMethods            []string
+	MatchHeaders            map[string]string
+	Group                   string
+	GroupPercent            int
+	MirrorHost              string
+	MirrorPercent           int
+	Weight                  int
+	BalancingAlgorithm      string
+	AvailabilityZone        string
+	TimeoutInSeconds        int
 	UseTLS                  bool
 	UpdatedAt               time.Time
 }
@@ -164,7 +572,35 @@ func NewEndpoint(opts *EndpointOpts) *Endpoint {
 		ModificationTag:      opts.ModificationTag,
 		Stats:                NewStats(),
 		IsolationSegment:     opts.IsolationSegment,
+		ClientCertName:       opts.ClientCertName,
+		Protocol:             opts.Protocol,
+		ProxyProtocol:        opts.ProxyProtocol,
+		ForwardedClientCert:  opts.ForwardedClientCert,
+		AllowedClientCerts:   ClientCertAllowlist{SANs: opts.AllowedClientCertSANs, OUs: opts.AllowedClientCertOUs},
+		ClientIPAccess:       IPAccessList{Allow: opts.AllowedClientNets, Deny: opts.DeniedClientNets},
+		JWTValidation:        opts.JWTValidation,
+		JWTAudiences:         opts.JWTAudiences,
+		RateLimit:            RateLimit{PerSecond: opts.RateLimitPerSecond, Burst: opts.RateLimitBurst},
+		MaxRequestBodyBytes:  opts.MaxRequestBodyBytes,
+		HostRewrite:          opts.HostRewrite,
+		StripPathPrefix:      opts.StripPathPrefix,
+		RewritePath:          opts.RewritePath,
+		Match:                NewRouteMatch(opts.MatchMethods, opts.MatchHeaders),
+		Group:                opts.Group,
+		GroupPercent:         opts.GroupPercent,
+		MirrorHost:           opts.MirrorHost,
+		MirrorPercent:        opts.MirrorPercent,
+		Weight:               opts.Weight,
+		BalancingAlgorithm:   opts.BalancingAlgorithm,
+		AvailabilityZone:     opts.AvailabilityZone,
+		Timeout:              time.Duration(opts.TimeoutInSeconds) * time.Second,
 		UpdatedAt:            opts.UpdatedAt,
+		CORS: CORSPolicy{
+			AllowedOrigins: opts.CORSAllowedOrigins,
+			AllowedMethods: opts.CORSAllowedMethods,
+			AllowedHeaders: opts.CORSAllowedHeaders,
+			MaxAge:         time.Duration(opts.CORSMaxAgeInSeconds) * time.Second,
+		},
 	}
 }
 
@@ -172,12 +608,40 @@ func (e *Endpoint) IsTLS() bool {
 	return e.useTls
 }
 
+// IsHTTP2 reports whether the backend registered to be proxied with HTTP/2.
+func (e *Endpoint) IsHTTP2() bool {
+	return e.Protocol == ProtocolHTTP2
+}
+
+// weight returns the share of traffic the endpoint receives relative to the
+// other endpoints of its pool. Endpoints that register no weight have a
+// weight of 1.
+func (e *Endpoint) weight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
 type PoolOpts struct {
 	RetryAfterFailure  time.Duration
 	Host               string
 	ContextPath        string
 	MaxConnsPerBackend int64
 	Logger             logger.Logger
+
+	// LocalAvailabilityZone is the zone whose endpoints are selected while
+	// any of them is available. Endpoints are selected from all zones when it
+	// is empty.
+	LocalAvailabilityZone string
+
+	// OutlierDetection ejects endpoints whose requests keep failing. It is
+	// disabled unless Enabled is set.
+	OutlierDetection config.OutlierDetectionConfig
+
+	// CircuitBreaker wraps each endpoint in a circuit breaker. It is
+	// disabled unless Enabled is set.
+	CircuitBreaker config.CircuitBreakerConfig
 }
 
 func NewPool(opts *PoolOpts) *Pool {
@@ -191,6 +655,10 @@ func NewPool(opts *PoolOpts) *Pool {
 		contextPath:        opts.ContextPath,
 		random:             rand.New(rand.NewSource(time.Now().UnixNano())),
 		logger:             opts.Logger,
+
+		localAvailabilityZone: opts.LocalAvailabilityZone,
+		outlierDetection:      opts.OutlierDetection,
+		circuitBreaker:        opts.CircuitBreaker,
 	}
 }
 
@@ -210,8 +678,222 @@ func (p *Pool) MaxConnsPerBackend() int64 {
 	return p.maxConnsPerBackend
 }
 
-// Returns true if endpoint was added or updated, false otherwise
+// Put adds the endpoint to the pool, or to the pool in it for the match and
+// group the endpoint registered, and removes it from the others in case its
+// match or group changed.
 func (p *Pool) Put(endpoint *Endpoint) PoolPutResult {
+	target := p
+	if !endpoint.Match.IsEmpty() || endpoint.Group != "" {
+		target = p.subpool(endpoint.Match, endpoint.Group)
+	}
+
+	result := target.put(endpoint)
+	for _, pool := range p.pools() {
+		if pool != target && pool.removeAddr(endpoint.CanonicalAddr()) {
+			p.removeEmptySubpools()
+		}
+	}
+	return result
+}
+
+// subpool returns the pool of the endpoints registered with match in group,
+// creating it if there is none.
+func (p *Pool) subpool(match RouteMatch, group string) *Pool {
+	p.Lock()
+	defer p.Unlock()
+
+	key := match.key()
+	for _, pool := range p.subpools {
+		if pool.match.key() == key && pool.group == group {
+			return pool
+		}
+	}
+
+	pool := NewPool(&PoolOpts{
+		RetryAfterFailure:     p.retryAfterFailure,
+		Host:                  p.host,
+		ContextPath:           p.contextPath,
+		MaxConnsPerBackend:    p.maxConnsPerBackend,
+		Logger:                p.logger,
+		LocalAvailabilityZone: p.localAvailabilityZone,
+		OutlierDetection:      p.outlierDetection,
+		CircuitBreaker:        p.circuitBreaker,
+	})
+	pool.match = match
+	pool.group = group
+	pool.route = p
+
+	subpools := make([]*Pool, 0, len(p.subpools)+1)
+	subpools = append(subpools, p.subpools...)
+	subpools = append(subpools, pool)
+	sort.Slice(subpools, func(i, j int) bool {
+		if subpools[i].match.key() != subpools[j].match.key() {
+			return subpools[i].match.moreSpecific(subpools[j].match)
+		}
+		return subpools[i].group < subpools[j].group
+	})
+	p.subpools = subpools
+	return pool
+}
+
+// removeEmptySubpools drops the pools of matches and groups that no endpoint
+// is registered with anymore.
+func (p *Pool) removeEmptySubpools() {
+	p.Lock()
+	defer p.Unlock()
+
+	var subpools []*Pool
+	for _, pool := range p.subpools {
+		if !pool.IsEmpty() {
+			subpools = append(subpools, pool)
+		}
+	}
+	p.subpools = subpools
+}
+
+// pools returns the pool and the pools of its matches and groups.
+func (p *Pool) pools() []*Pool {
+	p.Lock()
+	defer p.Unlock()
+
+	return append([]*Pool{p}, p.subpools...)
+}
+
+// routeEndpoints returns the endpoints of the route of the pool, including
+// those registered with a match or in a group, so that the policies that
+// restrict who may send requests to the route are the same whichever of its
+// endpoints requests are sent to.
+func (p *Pool) routeEndpoints() []*Endpoint {
+	route := p
+	if p.route != nil {
+		route = p.route
+	}
+
+	var endpoints []*Endpoint
+	for _, pool := range route.pools() {
+		pool.Lock()
+		for _, e := range pool.endpoints {
+			endpoints = append(endpoints, e.endpoint)
+		}
+		pool.Unlock()
+	}
+	return endpoints
+}
+
+// Matched reports whether the route has endpoints registered with a match,
+// so that the endpoints that requests are sent to depend on their method or
+// headers.
+func (p *Pool) Matched() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.match.IsEmpty() {
+		return true
+	}
+	for _, pool := range p.subpools {
+		if !pool.match.IsEmpty() {
+			return true
+		}
+	}
+	return false
+}
+
+// ForRequest returns the pool of the endpoints that the request is sent to:
+// those registered with the first match that the request matches, or else
+// those registered without a match. When those endpoints are in groups, one
+// of the groups is chosen by split. It returns nil if there are none. The
+// access policies of the pool it returns are those of the whole route, so
+// that neither a match nor a group can be used to avoid them.
+func (p *Pool) ForRequest(req *http.Request) *Pool {
+	p.Lock()
+	subpools := p.subpools
+	empty := len(p.endpoints) == 0
+	p.Unlock()
+
+	var candidates []*Pool
+	for _, pool := range subpools {
+		if len(candidates) > 0 {
+			if pool.match.key() != candidates[0].match.key() {
+				break
+			}
+			candidates = append(candidates, pool)
+		} else if pool.match.Matches(req) {
+			candidates = append(candidates, pool)
+		}
+	}
+	if !empty && (len(candidates) == 0 || candidates[0].match.IsEmpty()) {
+		candidates = append(candidates, p)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	default:
+		return p.split(candidates)
+	}
+}
+
+// split chooses one of the pools of endpoint groups at random, in proportion
+// to the percentage their endpoints registered. Groups that registered none
+// share what the others leave of 100 percent, and percentages that add up to
+// more than 100 are relative to each other.
+func (p *Pool) split(pools []*Pool) *Pool {
+	percents := make([]int, len(pools))
+	total, unset := 0, 0
+	for i, pool := range pools {
+		percents[i] = pool.groupPercent()
+		total += percents[i]
+		if percents[i] == 0 {
+			unset++
+		}
+	}
+
+	// scaled by the number of groups without a percentage, so that they
+	// share the rest evenly in whole numbers
+	weights := make([]int, len(pools))
+	sum := 0
+	for i, percent := range percents {
+		switch {
+		case percent > 0 && unset > 0:
+			weights[i] = percent * unset
+		case percent > 0:
+			weights[i] = percent
+		case total < 100:
+			weights[i] = 100 - total
+		}
+		sum += weights[i]
+	}
+
+	p.Lock()
+	n := p.random.Intn(sum)
+	p.Unlock()
+
+	for i, weight := range weights {
+		if n < weight {
+			return pools[i]
+		}
+		n -= weight
+	}
+	return pools[len(pools)-1]
+}
+
+// groupPercent returns the percentage of requests that the endpoints of the
+// pool registered for their group, or zero if they registered none.
+func (p *Pool) groupPercent() int {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if e.endpoint.GroupPercent > 0 {
+			return e.endpoint.GroupPercent
+		}
+	}
+	return 0
+}
+
+func (p *Pool) put(endpoint *Endpoint) PoolPutResult {
 	p.Lock()
 	defer p.Unlock()
 
@@ -235,9 +917,16 @@ func (p *Pool) Put(endpoint *Endpoint) PoolPutResult {
 				p.index[endpoint.PrivateInstanceId] = e
 			}
 
-			if oldEndpoint.ServerCertDomainSAN == endpoint.ServerCertDomainSAN {
+			if oldEndpoint.ServerCertDomainSAN == endpoint.ServerCertDomainSAN &&
+				oldEndpoint.ClientCertName == endpoint.ClientCertName &&
+				oldEndpoint.Protocol == endpoint.Protocol &&
+				oldEndpoint.ProxyProtocol == endpoint.ProxyProtocol {
 				endpoint.SetRoundTripper(oldEndpoint.RoundTripper())
 			}
+
+			if atomic.LoadInt64(&endpoint.lastServed) == 0 {
+				atomic.StoreInt64(&endpoint.lastServed, atomic.LoadInt64(&oldEndpoint.lastServed))
+			}
 		}
 	} else {
 		result = ADDED
@@ -246,11 +935,16 @@ func (p *Pool) Put(endpoint *Endpoint) PoolPutResult {
 			index:              len(p.endpoints),
 			maxConnsPerBackend: p.maxConnsPerBackend,
 		}
+		if p.circuitBreaker.Enabled {
+			e.breaker = NewCircuitBreaker(p.circuitBreaker)
+		}
 
 		p.endpoints = append(p.endpoints, e)
 
 		p.index[endpoint.CanonicalAddr()] = e
 		p.index[endpoint.PrivateInstanceId] = e
+
+		atomic.CompareAndSwapInt64(&endpoint.lastServed, 0, time.Now().UnixNano())
 	}
 
 	e.updated = time.Now()
@@ -270,7 +964,45 @@ func (p *Pool) RouteServiceUrl() string {
 	}
 }
 
+// PruneEndpoints removes the endpoints that have not been registered again
+// within their stale threshold and returns them.
 func (p *Pool) PruneEndpoints() []*Endpoint {
+	pruned := p.pruneEndpoints()
+	subpools := p.pools()[1:]
+	for _, pool := range subpools {
+		pruned = append(pruned, pool.pruneEndpoints()...)
+	}
+	if len(subpools) > 0 {
+		p.removeEmptySubpools()
+	}
+	return pruned
+}
+
+// HasStaleEndpoints reports whether PruneEndpoints would prune any
+// endpoints.
+func (p *Pool) HasStaleEndpoints() bool {
+	now := time.Now()
+	for _, pool := range p.pools() {
+		if pool.hasStaleEndpoints(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool) hasStaleEndpoints(now time.Time) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if e.stale(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool) pruneEndpoints() []*Endpoint {
 	p.Lock()
 
 	last := len(p.endpoints)
@@ -281,14 +1013,7 @@ func (p *Pool) PruneEndpoints() []*Endpoint {
 	for i := 0; i < last; {
 		e := p.endpoints[i]
 
-		if e.endpoint.useTls {
-			i++
-			continue
-		}
-
-		staleTime := now.Add(-e.endpoint.StaleThreshold)
-
-		if e.updated.Before(staleTime) {
+		if e.stale(now) {
 			p.removeEndpoint(e)
 			prunedEndpoints = append(prunedEndpoints, e.endpoint)
 			last--
@@ -301,8 +1026,34 @@ func (p *Pool) PruneEndpoints() []*Endpoint {
 	return prunedEndpoints
 }
 
+// IdleEndpoints returns the endpoints that have not served a request within
+// threshold. TLS endpoints are skipped as they are already pruned when they
+// fail.
+func (p *Pool) IdleEndpoints(threshold time.Duration) []*Endpoint {
+	idleTime := time.Now().Add(-threshold)
+	idleEndpoints := []*Endpoint{}
+	p.Each(func(e *Endpoint) {
+		if !e.useTls && e.LastServed().Before(idleTime) {
+			idleEndpoints = append(idleEndpoints, e)
+		}
+	})
+	return idleEndpoints
+}
+
 // Returns true if the endpoint was removed from the Pool, false otherwise.
 func (p *Pool) Remove(endpoint *Endpoint) bool {
+	for _, pool := range p.pools() {
+		if pool.remove(endpoint) {
+			if pool != p {
+				p.removeEmptySubpools()
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool) remove(endpoint *Endpoint) bool {
 	var e *endpointElem
 
 	p.Lock()
@@ -319,6 +1070,20 @@ func (p *Pool) Remove(endpoint *Endpoint) bool {
 	return false
 }
 
+// removeAddr removes the endpoint with the address, whatever its
+// modification tag, and reports whether there was one.
+func (p *Pool) removeAddr(addr string) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	e := p.index[addr]
+	if e == nil {
+		return false
+	}
+	p.removeEndpoint(e)
+	return true
+}
+
 func (p *Pool) removeEndpoint(e *endpointElem) {
 	i := e.index
 	es := p.endpoints
@@ -334,36 +1099,327 @@ func (p *Pool) removeEndpoint(e *endpointElem) {
 	delete(p.index, e.endpoint.PrivateInstanceId)
 }
 
+// isWeighted reports whether the endpoints of the pool have different
+// weights. The caller must hold the pool lock.
+func (p *Pool) isWeighted() bool {
+	for _, e := range p.endpoints {
+		if e.endpoint.weight() != p.endpoints[0].endpoint.weight() {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoints returns an iterator over the endpoints of the pool that uses the
+// load balancing algorithm registered for the route, or defaultLoadBalance if
+// none is.
 func (p *Pool) Endpoints(defaultLoadBalance, initial string) EndpointIterator {
-	switch defaultLoadBalance {
+	switch p.BalancingAlgorithm(defaultLoadBalance) {
 	case config.LOAD_BALANCE_LC:
 		return NewLeastConnection(p, initial)
+	case config.LOAD_BALANCE_LL:
+		return NewLeastLatency(p, initial)
 	default:
 		return NewRoundRobin(p, initial)
 	}
 }
 
+// EndpointsForPath returns an iterator like Endpoints. When there is no
+// initial endpoint and the route is tagged with StickyPathSegment, endpoints
+// are selected by consistent hashing of the tagged segment of the path.
+func (p *Pool) EndpointsForPath(defaultLoadBalance, initial, path string) EndpointIterator {
+	if initial == "" {
+		if key := PathSegmentKey(p.StickyPathSegment(), path); key != "" {
+			return NewConsistentHash(p, key)
+		}
+	}
+	return p.Endpoints(defaultLoadBalance, initial)
+}
+
+// EndpointsForKey returns an iterator like EndpointsForPath. When there is no
+// initial endpoint, the load balancing algorithm is consistent-hash and the
+// route does not select endpoints by path, endpoints are selected by
+// consistent hashing of key.
+func (p *Pool) EndpointsForKey(defaultLoadBalance, initial, path, key string) EndpointIterator {
+	if initial == "" && key != "" && p.BalancingAlgorithm(defaultLoadBalance) == config.LOAD_BALANCE_CH &&
+		PathSegmentKey(p.StickyPathSegment(), path) == "" {
+		return NewConsistentHash(p, key)
+	}
+	return p.EndpointsForPath(defaultLoadBalance, initial, path)
+}
+
+// unpreferred returns a function that reports whether an endpoint must not be
+// selected, because an endpoint that is preferred over it is neither
+// overloaded nor failed. Endpoints that registered are preferred over
+// provisional ones, which may no longer exist, and then endpoints in the local
+// availability zone over those in other zones. The caller must hold the pool
+// lock.
+func (p *Pool) unpreferred(now time.Time) func(e *endpointElem) bool {
+	available := func(e *endpointElem) bool {
+		return !e.isOverloaded() && !e.failedWithin(p.retryAfterFailure, now)
+	}
+
+	registeredOnly := false
+	for _, e := range p.endpoints {
+		if !e.endpoint.Provisional && available(e) {
+			registeredOnly = true
+			break
+		}
+	}
+	localOnly := false
+	if p.localAvailabilityZone != "" {
+		for _, e := range p.endpoints {
+			if !p.isRemote(e) && (!registeredOnly || !e.endpoint.Provisional) && available(e) {
+				localOnly = true
+				break
+			}
+		}
+	}
+
+	return func(e *endpointElem) bool {
+		return registeredOnly && e.endpoint.Provisional || localOnly && p.isRemote(e)
+	}
+}
+
+// isRemote reports whether the endpoint is outside the local availability
+// zone.
+func (p *Pool) isRemote(e *endpointElem) bool {
+	return e.endpoint.AvailabilityZone != p.localAvailabilityZone
+}
+
+// BalancingAlgorithm returns the load balancing algorithm registered for the
+// route, or defaultLoadBalance if none is.
+func (p *Pool) BalancingAlgorithm(defaultLoadBalance string) string {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if algorithm := e.endpoint.BalancingAlgorithm; algorithm != "" {
+			return algorithm
+		}
+	}
+	return defaultLoadBalance
+}
+
+// Timeout returns the timeout of requests that the endpoints of the pool
+// registered, or zero if they registered none. When the endpoints register
+// different timeouts, one of them is used.
+func (p *Pool) Timeout() time.Duration {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if e.endpoint.Timeout > 0 {
+			return e.endpoint.Timeout
+		}
+	}
+	return 0
+}
+
+// ForwardedClientCert returns the X-Forwarded-Client-Cert mode that the
+// endpoints of the pool registered, or defaultMode if they registered none.
+func (p *Pool) ForwardedClientCert(defaultMode string) string {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if mode := e.endpoint.ForwardedClientCert; mode != "" {
+			return mode
+		}
+	}
+	return defaultMode
+}
+
+// AllowedClientCerts returns the client certificate allowlist of the route
+// of the pool, which is empty if its endpoints registered none. When they
+// registered different allowlists, only the names and units that all of them
+// list are allowed.
+func (p *Pool) AllowedClientCerts() ClientCertAllowlist {
+	var allowlist ClientCertAllowlist
+	for _, e := range p.routeEndpoints() {
+		allowlist = allowlist.restrict(e.AllowedClientCerts)
+	}
+	return allowlist
+}
+
+// ClientIPAccess returns the client IP access list of the route of the pool,
+// which is empty if its endpoints registered none. When they registered
+// different lists, only the IPs that all of them allow are allowed.
+func (p *Pool) ClientIPAccess() IPAccessList {
+	var list IPAccessList
+	for _, e := range p.routeEndpoints() {
+		list = list.restrict(e.ClientIPAccess)
+	}
+	return list
+}
+
+// JWTValidation reports whether requests to the route of the pool must carry
+// a valid bearer token, and the audiences the token must be for. A token is
+// required when any endpoint of the route registered that it is, or when
+// defaultRequired is set and any endpoint registered nothing. When the
+// endpoints registered different audiences, the token must be for one that
+// all of them registered, and the audiences are empty but not nil when they
+// have none in common.
+func (p *Pool) JWTValidation(defaultRequired bool) (bool, []string) {
+	endpoints := p.routeEndpoints()
+	if len(endpoints) == 0 {
+		return defaultRequired, nil
+	}
+
+	required := false
+	var audiences []string
+	for _, e := range endpoints {
+		switch e.JWTValidation {
+		case JWTValidationRequired:
+			required = true
+		case "":
+			required = required || defaultRequired
+		}
+		if len(e.JWTAudiences) > 0 {
+			if audiences == nil {
+				audiences = e.JWTAudiences
+			} else {
+				audiences = intersect(audiences, e.JWTAudiences, false)
+			}
+		}
+	}
+	return required, audiences
+}
+
+// MaxRequestBodyBytes returns the smallest request body size limit that the
+// endpoints of the route of the pool registered, or zero if they registered
+// none.
+func (p *Pool) MaxRequestBodyBytes() int64 {
+	var limit int64
+	for _, e := range p.routeEndpoints() {
+		if n := e.MaxRequestBodyBytes; n > 0 && (limit == 0 || n < limit) {
+			limit = n
+		}
+	}
+	return limit
+}
+
+// CORS returns the CORS policy of the route of the pool, which is empty if
+// its endpoints registered none. When they registered different policies,
+// only what all of them allow is allowed.
+func (p *Pool) CORS() CORSPolicy {
+	var policy CORSPolicy
+	for _, e := range p.routeEndpoints() {
+		policy = policy.restrict(e.CORS)
+	}
+	return policy
+}
+
+// HostRewrite returns the Host header that the endpoints of the pool
+// registered to receive requests with, or an empty string if they registered
+// none.
+func (p *Pool) HostRewrite() string {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if e.endpoint.HostRewrite != "" {
+			return e.endpoint.HostRewrite
+		}
+	}
+	return ""
+}
+
+// PathRewrite returns the path that the endpoints of the pool registered to
+// receive requests for the context path of the pool under, which is "/" if
+// they strip it, or an empty string if they keep it.
+func (p *Pool) PathRewrite() string {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if e.endpoint.RewritePath != "" {
+			return e.endpoint.RewritePath
+		}
+		if e.endpoint.StripPathPrefix {
+			return "/"
+		}
+	}
+	return ""
+}
+
+// Mirror returns the host of the route that the endpoints of the pool
+// registered to mirror the percentage of their requests to, or an empty host
+// if they registered none.
+func (p *Pool) Mirror() (host string, percent int) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if e.endpoint.MirrorHost != "" {
+			return e.endpoint.MirrorHost, e.endpoint.MirrorPercent
+		}
+	}
+	return "", 0
+}
+
+// RateLimit returns the lowest rate limit that the endpoints of the route of
+// the pool registered, which is zero if they registered none.
+func (p *Pool) RateLimit() RateLimit {
+	var limit RateLimit
+	for _, e := range p.routeEndpoints() {
+		registered := e.RateLimit
+		if registered.PerSecond <= 0 {
+			continue
+		}
+		if limit.PerSecond == 0 || registered.PerSecond < limit.PerSecond {
+			limit.PerSecond = registered.PerSecond
+		}
+		if registered.Burst > 0 && (limit.Burst == 0 || registered.Burst < limit.Burst) {
+			limit.Burst = registered.Burst
+		}
+	}
+	return limit
+}
+
+// StickyPathSegment returns the StickyPathSegmentTag of the endpoints of the
+// pool, or "" if none is tagged with it.
+func (p *Pool) StickyPathSegment() string {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, e := range p.endpoints {
+		if spec := e.endpoint.Tags[StickyPathSegmentTag]; spec != "" {
+			return spec
+		}
+	}
+	return ""
+}
+
 func (p *Pool) findById(id string) *endpointElem {
 	p.Lock()
 	defer p.Unlock()
 	return p.index[id]
 }
 
-func (p *Pool) IsEmpty() bool {
-	p.Lock()
-	l := len(p.endpoints)
-	p.Unlock()
+// NumEndpoints returns the number of endpoints of the pool, including those
+// registered with a match.
+func (p *Pool) NumEndpoints() int {
+	n := 0
+	for _, pool := range p.pools() {
+		pool.Lock()
+		n += len(pool.endpoints)
+		pool.Unlock()
+	}
+	return n
+}
 
-	return l == 0
+func (p *Pool) IsEmpty() bool {
+	return p.NumEndpoints() == 0
 }
 
 func (p *Pool) IsOverloaded() bool {
-	if p.IsEmpty() {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.endpoints) == 0 {
 		return true
 	}
 
-	p.Lock()
-	defer p.Unlock()
 	if p.maxConnsPerBackend == 0 {
 		return false
 	}
@@ -380,11 +1436,13 @@ func (p *Pool) IsOverloaded() bool {
 }
 
 func (p *Pool) MarkUpdated(t time.Time) {
-	p.Lock()
-	for _, e := range p.endpoints {
-		e.updated = t
+	for _, pool := range p.pools() {
+		pool.Lock()
+		for _, e := range pool.endpoints {
+			e.updated = t
+		}
+		pool.Unlock()
 	}
-	p.Unlock()
 }
 
 func (p *Pool) EndpointFailed(endpoint *Endpoint, err error) {
@@ -412,30 +1470,146 @@ func (p *Pool) EndpointFailed(endpoint *Endpoint, err error) {
 	return
 }
 
+// SetEndpointHealthy takes the endpoint out of rotation when it fails its
+// health checks, and puts it back when it passes them. Endpoints out of
+// rotation are only selected when no other endpoint is available.
+func (p *Pool) SetEndpointHealthy(endpoint *Endpoint, healthy bool) {
+	for _, pool := range p.pools() {
+		pool.Lock()
+		if e := pool.index[endpoint.CanonicalAddr()]; e != nil {
+			e.unhealthy = !healthy
+		}
+		pool.Unlock()
+	}
+}
+
+// RecordOutcome records whether a request to the endpoint failed for outlier
+// detection, and reports whether the endpoint was ejected as a result.
+// Ejected endpoints are only selected when no other endpoint is available.
+func (p *Pool) RecordOutcome(endpoint *Endpoint, failed bool) bool {
+	if !p.outlierDetection.Enabled {
+		return false
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	e := p.index[endpoint.CanonicalAddr()]
+	if e == nil {
+		return false
+	}
+
+	if !failed {
+		e.consecutiveFailures = 0
+		e.ejections = 0
+		return false
+	}
+
+	e.consecutiveFailures++
+	now := time.Now()
+	if e.consecutiveFailures < p.outlierDetection.ConsecutiveFailures || now.Before(e.ejectedUntil) {
+		return false
+	}
+
+	e.consecutiveFailures = 0
+	e.ejections++
+	ejectionTime := time.Duration(e.ejections) * p.outlierDetection.BaseEjectionTime
+	if ejectionTime > p.outlierDetection.MaxEjectionTime {
+		ejectionTime = p.outlierDetection.MaxEjectionTime
+	}
+	e.ejectedUntil = now.Add(ejectionTime)
+
+	p.logger.Info("endpoint-ejected",
+		zap.Nest("route-endpoint", endpoint.ToLogData()...),
+		zap.Duration("ejection-time", ejectionTime),
+	)
+	return true
+}
+
+// CircuitBreaker returns the circuit breaker of the endpoint, which is nil
+// when circuit breaking is disabled or the endpoint is not in the pool.
+func (p *Pool) CircuitBreaker(endpoint *Endpoint) *CircuitBreaker {
+	p.Lock()
+	defer p.Unlock()
+
+	if e := p.index[endpoint.CanonicalAddr()]; e != nil {
+		return e.breaker
+	}
+	return nil
+}
+
+// Each calls f with every endpoint of the pool, including those registered
+// with a match.
 func (p *Pool) Each(f func(endpoint *Endpoint)) {
+	for _, pool := range p.pools() {
+		pool.Lock()
+		for _, e := range pool.endpoints {
+			f(e.endpoint)
+		}
+		pool.Unlock()
+	}
+}
+
+// EachOwn calls f for every endpoint of the pool, leaving out those of the
+// pools in it for matches and groups.
+func (p *Pool) EachOwn(f func(endpoint *Endpoint)) {
 	p.Lock()
+	defer p.Unlock()
+
 	for _, e := range p.endpoints {
 		f(e.endpoint)
 	}
-	p.Unlock()
 }
 
 func (p *Pool) MarshalJSON() ([]byte, error) {
-	p.Lock()
-	endpoints := make([]*Endpoint, 0, len(p.endpoints))
-	for _, e := range p.endpoints {
-		endpoints = append(endpoints, e.endpoint)
-	}
-	p.Unlock()
+	endpoints := []*Endpoint{}
+	p.Each(func(e *Endpoint) {
+		endpoints = append(endpoints, e)
+	})
 
 	return json.Marshal(endpoints)
 }
 
+// stale reports whether the endpoint has not been registered within its
+// stale threshold. TLS endpoints never go stale.
+func (e *endpointElem) stale(now time.Time) bool {
+	// TLS endpoints are pruned when they fail instead, unless they are
+	// provisional and were never registered.
+	if e.endpoint.useTls && !e.endpoint.Provisional {
+		return false
+	}
+	return e.updated.Before(now.Add(-e.endpoint.StaleThreshold))
+}
+
 func (e *endpointElem) failed() {
 	t := time.Now()
 	e.failedAt = &t
 }
 
+// failedWithin reports whether the endpoint was marked as failed less than
+// window ago, or is out of rotation. An expired failure is cleared.
+func (e *endpointElem) failedWithin(window time.Duration, now time.Time) bool {
+	if e.outOfRotation(now) {
+		return true
+	}
+
+	if e.failedAt == nil {
+		return false
+	}
+
+	if now.Sub(*e.failedAt) > window {
+		e.failedAt = nil
+		return false
+	}
+
+	return true
+}
+
+// outOfRotation reports whether the endpoint fails its health checks, is
+// ejected by outlier detection, or its circuit does not admit requests.
+func (e *endpointElem) outOfRotation(now time.Time) bool {
+	return e.unhealthy || now.Before(e.ejectedUntil) || !e.breaker.available(now)
+}
+
 func (e *endpointElem) isOverloaded() bool {
 	if e.maxConnsPerBackend == 0 {
 		return false
@@ -454,6 +1628,36 @@ func (e *Endpoint) MarshalJSON() ([]byte, error) {
 		IsolationSegment    string            `json:"isolation_segment,omitempty"`
 		PrivateInstanceId   string            `json:"private_instance_id,omitempty"`
 		ServerCertDomainSAN string            `json:"server_cert_domain_san,omitempty"`
+		Protocol            string            `json:"protocol,omitempty"`
+		ProxyProtocol       string            `json:"proxy_protocol,omitempty"`
+		ForwardedClientCert string            `json:"forwarded_client_cert,omitempty"`
+		AllowedSANs         []string          `json:"allowed_client_cert_sans,omitempty"`
+		AllowedOUs          []string          `json:"allowed_client_cert_ous,omitempty"`
+		AllowedCIDRs        []string          `json:"allowed_client_cidrs,omitempty"`
+		DeniedCIDRs         []string          `json:"denied_client_cidrs,omitempty"`
+		JWTValidation       string            `json:"jwt_validation,omitempty"`
+		JWTAudiences        []string          `json:"jwt_audiences,omitempty"`
+		RateLimitPerSecond  float64           `json:"rate_limit_per_second,omitempty"`
+		RateLimitBurst      int               `json:"rate_limit_burst,omitempty"`
+		MaxRequestBodyBytes int64             `json:"max_request_body_bytes,omitempty"`
+		CORSAllowedOrigins  []string          `json:"cors_allowed_origins,omitempty"`
+		CORSAllowedMethods  []string          `json:"cors_allowed_methods,omitempty"`
+		CORSAllowedHeaders  []string          `json:"cors_allowed_headers,omitempty"`
+		CORSMaxAgeInSeconds int               `json:"cors_max_age_in_seconds,omitempty"`
+		HostRewrite         string            `json:"host_rewrite,omitempty"`
+		StripPathPrefix     bool              `json:"strip_path_prefix,omitempty"`
+		RewritePath         string            `json:"rewrite_path,omitempty"`
+		MatchMethods        []string          `json:"match_methods,omitempty"`
+		MatchHeaders        map[string]string `json:"match_headers,omitempty"`
+		Group               string            `json:"endpoint_group,omitempty"`
+		GroupPercent        int               `json:"endpoint_group_percent,omitempty"`
+		MirrorHost          string            `json:"mirror_host,omitempty"`
+		MirrorPercent       int               `json:"mirror_percent,omitempty"`
+		Provisional         bool              `json:"provisional,omitempty"`
+		Weight              int               `json:"weight,omitempty"`
+		BalancingAlgorithm  string            `json:"balancing_algorithm,omitempty"`
+		AvailabilityZone    string            `json:"availability_zone,omitempty"`
+		TimeoutInSeconds    int               `json:"timeout_in_seconds,omitempty"`
 	}
 
 	jsonObj.Address = e.addr
@@ -464,6 +1668,40 @@ func (e *Endpoint) MarshalJSON() ([]byte, error) {
 	jsonObj.IsolationSegment = e.IsolationSegment
 	jsonObj.PrivateInstanceId = e.PrivateInstanceId
 	jsonObj.ServerCertDomainSAN = e.ServerCertDomainSAN
+	jsonObj.Protocol = e.Protocol
+	jsonObj.ProxyProtocol = e.ProxyProtocol
+	jsonObj.ForwardedClientCert = e.ForwardedClientCert
+	jsonObj.AllowedSANs = e.AllowedClientCerts.SANs
+	jsonObj.AllowedOUs = e.AllowedClientCerts.OUs
+	for _, ipNet := range e.ClientIPAccess.Allow {
+		jsonObj.AllowedCIDRs = append(jsonObj.AllowedCIDRs, ipNet.String())
+	}
+	for _, ipNet := range e.ClientIPAccess.Deny {
+		jsonObj.DeniedCIDRs = append(jsonObj.DeniedCIDRs, ipNet.String())
+	}
+	jsonObj.JWTValidation = e.JWTValidation
+	jsonObj.JWTAudiences = e.JWTAudiences
+	jsonObj.RateLimitPerSecond = e.RateLimit.PerSecond
+	jsonObj.RateLimitBurst = e.RateLimit.Burst
+	jsonObj.MaxRequestBodyBytes = e.MaxRequestBodyBytes
+	jsonObj.CORSAllowedOrigins = e.CORS.AllowedOrigins
+	jsonObj.CORSAllowedMethods = e.CORS.AllowedMethods
+	jsonObj.CORSAllowedHeaders = e.CORS.AllowedHeaders
+	jsonObj.CORSMaxAgeInSeconds = int(e.CORS.MaxAge.Seconds())
+	jsonObj.HostRewrite = e.HostRewrite
+	jsonObj.StripPathPrefix = e.StripPathPrefix
+	jsonObj.RewritePath = e.RewritePath
+	jsonObj.MatchMethods = e.Match.Methods
+	jsonObj.MatchHeaders = e.Match.Headers
+	jsonObj.Group = e.Group
+	jsonObj.GroupPercent = e.GroupPercent
+	jsonObj.MirrorHost = e.MirrorHost
+	jsonObj.MirrorPercent = e.MirrorPercent
+	jsonObj.Provisional = e.Provisional
+	jsonObj.Weight = e.Weight
+	jsonObj.BalancingAlgorithm = e.BalancingAlgorithm
+	jsonObj.AvailabilityZone = e.AvailabilityZone
+	jsonObj.TimeoutInSeconds = int(e.Timeout.Seconds())
 	return json.Marshal(jsonObj)
 }
 
@@ -2,15 +2,19 @@ package route_test
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"crypto/tls"
 
 	"crypto/x509"
+	"crypto/x509/pkix"
 
 	"net"
 
+	"code.cloudfoundry.org/gorouter/config"
 	"code.cloudfoundry.org/gorouter/route"
 	"code.cloudfoundry.org/gorouter/test_util"
 	"code.cloudfoundry.org/routing-api/models"
@@ -226,6 +230,14 @@ var _ = Describe("Pool", func() {
 				})
 			})
 
+			It("clears roundTrippers if the protocol changes", func() {
+				endpointWithSameAddressButDifferentProtocol := route.NewEndpoint(&route.EndpointOpts{Host: "1.2.3.4", Port: 5678, Protocol: route.ProtocolHTTP2})
+				pool.Put(endpointWithSameAddressButDifferentProtocol)
+				pool.Each(func(e *route.Endpoint) {
+					Expect(e.RoundTripper()).To(BeNil())
+				})
+			})
+
 		})
 	})
 
@@ -464,6 +476,1022 @@ var _ = Describe("Pool", func() {
 		})
 	})
 
+	Context("Endpoints", func() {
+		It("uses the default load balancing algorithm", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "1.2.3.4", Port: 5678}))
+
+			Expect(pool.Endpoints(config.LOAD_BALANCE_LC, "")).To(BeAssignableToTypeOf(&route.LeastConnection{}))
+		})
+
+		It("uses the load balancing algorithm registered for the route", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "1.2.3.4", Port: 5678, BalancingAlgorithm: config.LOAD_BALANCE_LC}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "5.6.7.8", Port: 5678}))
+
+			Expect(pool.Endpoints(config.LOAD_BALANCE_RR, "")).To(BeAssignableToTypeOf(&route.LeastConnection{}))
+		})
+
+		It("uses consistent hashing when it is registered for the route", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "1.2.3.4", Port: 5678, BalancingAlgorithm: config.LOAD_BALANCE_CH}))
+
+			Expect(pool.EndpointsForKey(config.LOAD_BALANCE_RR, "", "/", "user-1")).To(BeAssignableToTypeOf(&route.ConsistentHash{}))
+		})
+
+		It("marshals the balancing algorithm of endpoints that register one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{
+				Host:                    "1.2.3.4",
+				Port:                    5678,
+				StaleThresholdInSeconds: -1,
+				BalancingAlgorithm:      config.LOAD_BALANCE_LC,
+			}))
+
+			json, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","tls":false,"ttl":-1,"tags":null,"balancing_algorithm":"least-connection"}]`))
+		})
+	})
+
+	Context("SetEndpointHealthy", func() {
+		var healthy, unhealthy *route.Endpoint
+
+		BeforeEach(func() {
+			healthy = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000})
+			unhealthy = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000})
+			pool.Put(healthy)
+			pool.Put(unhealthy)
+			pool.SetEndpointHealthy(unhealthy, false)
+		})
+
+		for _, algorithm := range config.LoadBalancingStrategies {
+			algorithm := algorithm
+
+			Context("with "+algorithm, func() {
+				next := func() *route.Endpoint {
+					return pool.EndpointsForKey(algorithm, "", "/", "user-1").Next()
+				}
+
+				It("does not select endpoints that fail their health checks", func() {
+					for i := 0; i < 10; i++ {
+						Expect(next()).To(Equal(healthy))
+					}
+				})
+
+				It("selects endpoints that fail their health checks when no other endpoint is available", func() {
+					pool.Remove(healthy)
+
+					Expect(next()).To(Equal(unhealthy))
+				})
+			})
+		}
+
+		It("selects endpoints again once they pass their health checks", func() {
+			pool.SetEndpointHealthy(unhealthy, true)
+
+			selected := map[*route.Endpoint]bool{}
+			for i := 0; i < 10; i++ {
+				selected[pool.Endpoints(config.LOAD_BALANCE_RR, "").Next()] = true
+			}
+			Expect(selected).To(HaveKey(unhealthy))
+		})
+
+		It("keeps endpoints out of rotation when they register again", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000}))
+
+			for i := 0; i < 10; i++ {
+				Expect(pool.Endpoints(config.LOAD_BALANCE_RR, "").Next()).To(Equal(healthy))
+			}
+		})
+	})
+
+	Context("RecordOutcome", func() {
+		var healthy, failing *route.Endpoint
+
+		BeforeEach(func() {
+			pool = route.NewPool(&route.PoolOpts{
+				Logger:            logger,
+				RetryAfterFailure: 2 * time.Minute,
+				OutlierDetection: config.OutlierDetectionConfig{
+					Enabled:             true,
+					ConsecutiveFailures: 3,
+					BaseEjectionTime:    time.Minute,
+					MaxEjectionTime:     time.Hour,
+				},
+			})
+			healthy = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000})
+			failing = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000})
+			pool.Put(healthy)
+			pool.Put(failing)
+		})
+
+		It("ejects endpoints after consecutive failures", func() {
+			Expect(pool.RecordOutcome(failing, true)).To(BeFalse())
+			Expect(pool.RecordOutcome(failing, true)).To(BeFalse())
+			Expect(pool.RecordOutcome(failing, true)).To(BeTrue())
+			Expect(logger.Buffer()).To(gbytes.Say(`endpoint-ejected`))
+
+			for _, algorithm := range config.LoadBalancingStrategies {
+				for i := 0; i < 10; i++ {
+					Expect(pool.EndpointsForKey(algorithm, "", "/", "user-1").Next()).To(Equal(healthy))
+				}
+			}
+		})
+
+		It("does not eject endpoints whose failures are interrupted by a success", func() {
+			pool.RecordOutcome(failing, true)
+			pool.RecordOutcome(failing, true)
+			pool.RecordOutcome(failing, false)
+
+			Expect(pool.RecordOutcome(failing, true)).To(BeFalse())
+		})
+
+		It("does not eject endpoints again while they are ejected", func() {
+			for i := 0; i < 3; i++ {
+				pool.RecordOutcome(failing, true)
+			}
+
+			for i := 0; i < 3; i++ {
+				Expect(pool.RecordOutcome(failing, true)).To(BeFalse())
+			}
+		})
+
+		It("selects ejected endpoints when no other endpoint is available", func() {
+			for i := 0; i < 3; i++ {
+				pool.RecordOutcome(failing, true)
+			}
+			pool.Remove(healthy)
+
+			Expect(pool.Endpoints(config.LOAD_BALANCE_RR, "").Next()).To(Equal(failing))
+		})
+
+		It("does nothing when outlier detection is disabled", func() {
+			pool = route.NewPool(&route.PoolOpts{Logger: logger})
+			pool.Put(failing)
+
+			for i := 0; i < 10; i++ {
+				Expect(pool.RecordOutcome(failing, true)).To(BeFalse())
+			}
+		})
+	})
+
+	Context("CircuitBreaker", func() {
+		var healthy, flaky *route.Endpoint
+
+		BeforeEach(func() {
+			pool = route.NewPool(&route.PoolOpts{
+				Logger:            logger,
+				RetryAfterFailure: 2 * time.Minute,
+				CircuitBreaker: config.CircuitBreakerConfig{
+					Enabled:             true,
+					ConsecutiveFailures: 1,
+					OpenTimeout:         20 * time.Millisecond,
+					HalfOpenMaxRequests: 1,
+					SuccessThreshold:    1,
+				},
+			})
+			healthy = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000})
+			flaky = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000})
+			pool.Put(healthy)
+			pool.Put(flaky)
+
+			breaker := pool.CircuitBreaker(flaky)
+			breaker.Done(breaker.Begin(), true)
+		})
+
+		next := func() *route.Endpoint {
+			return pool.Endpoints(config.LOAD_BALANCE_RR, "").Next()
+		}
+
+		It("does not select endpoints whose circuit is open", func() {
+			for _, algorithm := range config.LoadBalancingStrategies {
+				for i := 0; i < 10; i++ {
+					Expect(pool.EndpointsForKey(algorithm, "", "/", "user-1").Next()).To(Equal(healthy))
+				}
+			}
+		})
+
+		It("selects endpoints whose circuit is half-open while a probe is available", func() {
+			breaker := pool.CircuitBreaker(flaky)
+			Eventually(breaker.State).Should(Equal(route.CircuitHalfOpen))
+			Expect([]*route.Endpoint{next(), next()}).To(ContainElement(flaky))
+
+			probe := breaker.Begin()
+			for i := 0; i < 10; i++ {
+				Expect(next()).To(Equal(healthy))
+			}
+
+			breaker.Done(probe, false)
+			Expect(breaker.State()).To(Equal(route.CircuitClosed))
+		})
+
+		It("has no circuit breakers when circuit breaking is disabled", func() {
+			pool = route.NewPool(&route.PoolOpts{Logger: logger})
+			pool.Put(flaky)
+
+			Expect(pool.CircuitBreaker(flaky)).To(BeNil())
+		})
+	})
+
+	Context("when the pool prefers an availability zone", func() {
+		var local1, local2, remote *route.Endpoint
+
+		BeforeEach(func() {
+			pool = route.NewPool(&route.PoolOpts{
+				Logger:                test_util.NewTestZapLogger("test"),
+				RetryAfterFailure:     2 * time.Minute,
+				MaxConnsPerBackend:    1,
+				LocalAvailabilityZone: "z1",
+			})
+			local1 = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, AvailabilityZone: "z1"})
+			local2 = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, AvailabilityZone: "z1"})
+			remote = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.2.1", Port: 60000, AvailabilityZone: "z2"})
+			pool.Put(local1)
+			pool.Put(local2)
+			pool.Put(remote)
+		})
+
+		for _, algorithm := range config.LoadBalancingStrategies {
+			algorithm := algorithm
+
+			Context("with "+algorithm, func() {
+				next := func() *route.Endpoint {
+					return pool.EndpointsForKey(algorithm, "", "/", "user-1").Next()
+				}
+
+				It("selects endpoints in the local availability zone", func() {
+					for i := 0; i < 10; i++ {
+						Expect(next()).ToNot(Equal(remote))
+					}
+				})
+
+				It("selects endpoints in other zones when the local endpoints are overloaded", func() {
+					local1.Stats.NumberConnections.Increment()
+					local2.Stats.NumberConnections.Increment()
+
+					Expect(next()).To(Equal(remote))
+				})
+
+				It("selects endpoints in other zones when the local endpoints failed", func() {
+					pool.EndpointFailed(local1, &net.OpError{Op: "dial"})
+					pool.EndpointFailed(local2, &net.OpError{Op: "dial"})
+
+					Expect(next()).To(Equal(remote))
+				})
+			})
+		}
+
+		It("selects endpoints in any zone when no zone is preferred", func() {
+			pool = route.NewPool(&route.PoolOpts{
+				Logger:            test_util.NewTestZapLogger("test"),
+				RetryAfterFailure: 2 * time.Minute,
+			})
+			pool.Put(local1)
+			pool.Put(remote)
+
+			selected := map[*route.Endpoint]bool{}
+			for i := 0; i < 10; i++ {
+				selected[pool.Endpoints(config.LOAD_BALANCE_RR, "").Next()] = true
+			}
+			Expect(selected).To(HaveKey(remote))
+		})
+
+		It("marshals the availability zone of endpoints", func() {
+			pool = route.NewPool(&route.PoolOpts{Logger: test_util.NewTestZapLogger("test")})
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{
+				Host:                    "1.2.3.4",
+				Port:                    5678,
+				StaleThresholdInSeconds: -1,
+				AvailabilityZone:        "z1",
+			}))
+
+			json, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","tls":false,"ttl":-1,"tags":null,"availability_zone":"z1"}]`))
+		})
+	})
+
+	Context("when the pool has provisional endpoints", func() {
+		var registered, provisional1, provisional2 *route.Endpoint
+
+		BeforeEach(func() {
+			pool = route.NewPool(&route.PoolOpts{
+				Logger:             test_util.NewTestZapLogger("test"),
+				RetryAfterFailure:  2 * time.Minute,
+				MaxConnsPerBackend: 1,
+			})
+			registered = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000})
+			provisional1 = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000})
+			provisional1.Provisional = true
+			provisional2 = route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.3", Port: 60000})
+			provisional2.Provisional = true
+			pool.Put(registered)
+			pool.Put(provisional1)
+			pool.Put(provisional2)
+		})
+
+		for _, algorithm := range config.LoadBalancingStrategies {
+			algorithm := algorithm
+
+			Context("with "+algorithm, func() {
+				next := func() *route.Endpoint {
+					return pool.EndpointsForKey(algorithm, "", "/", "user-1").Next()
+				}
+
+				It("selects the endpoints that registered", func() {
+					for i := 0; i < 10; i++ {
+						Expect(next()).To(Equal(registered))
+					}
+				})
+
+				It("selects provisional endpoints when the registered endpoints failed", func() {
+					pool.EndpointFailed(registered, &net.OpError{Op: "dial"})
+
+					Expect(next()).ToNot(Equal(registered))
+				})
+
+				It("selects endpoints that registered again", func() {
+					pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000}))
+					registered.Stats.NumberConnections.Increment()
+
+					Expect(next().CanonicalAddr()).To(Equal("10.0.1.2:60000"))
+				})
+			})
+		}
+	})
+
+	Context("Timeout", func() {
+		It("is zero when no endpoint registered a timeout", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			Expect(pool.Timeout()).To(BeZero())
+		})
+
+		It("returns the timeout the endpoints registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, TimeoutInSeconds: 300}))
+
+			Expect(pool.Timeout()).To(Equal(5 * time.Minute))
+		})
+
+		It("marshals the timeout of endpoints", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{
+				Host:                    "1.2.3.4",
+				Port:                    5678,
+				StaleThresholdInSeconds: -1,
+				TimeoutInSeconds:        300,
+			}))
+
+			json, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","tls":false,"ttl":-1,"tags":null,"timeout_in_seconds":300}]`))
+		})
+	})
+
+	Context("ForwardedClientCert", func() {
+		It("is the default mode when no endpoint registered one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			Expect(pool.ForwardedClientCert("sanitize_set")).To(Equal("sanitize_set"))
+		})
+
+		It("returns the mode the endpoints registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, ForwardedClientCert: "always_forward"}))
+
+			Expect(pool.ForwardedClientCert("sanitize_set")).To(Equal("always_forward"))
+		})
+	})
+
+	Context("AllowedClientCerts", func() {
+		It("is empty when no endpoint registered an allowlist", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			Expect(pool.AllowedClientCerts().IsEmpty()).To(BeTrue())
+		})
+
+		It("returns the allowlist the endpoints registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, AllowedClientCertOUs: []string{"payments"}}))
+
+			Expect(pool.AllowedClientCerts()).To(Equal(route.ClientCertAllowlist{OUs: []string{"payments"}}))
+		})
+	})
+
+	Context("ClientIPAccess", func() {
+		It("allows IPs that are not denied when nothing is allowed", func() {
+			_, denied, _ := net.ParseCIDR("10.1.0.0/16")
+			list := route.IPAccessList{Deny: []*net.IPNet{denied}}
+
+			Expect(list.Allows(net.ParseIP("10.0.0.1"))).To(BeTrue())
+			Expect(list.Allows(net.ParseIP("10.1.0.1"))).To(BeFalse())
+		})
+
+		It("only allows IPs in the allowlist when it is not empty", func() {
+			_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+			list := route.IPAccessList{Allow: []*net.IPNet{allowed}}
+
+			Expect(list.Allows(net.ParseIP("10.0.0.1"))).To(BeTrue())
+			Expect(list.Allows(net.ParseIP("192.168.0.1"))).To(BeFalse())
+		})
+
+		It("returns the list an endpoint registered", func() {
+			_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, AllowedClientNets: []*net.IPNet{allowed}}))
+
+			Expect(pool.ClientIPAccess().Allow).To(Equal([]*net.IPNet{allowed}))
+		})
+
+		It("only allows the IPs that all the endpoints allow", func() {
+			_, wide, _ := net.ParseCIDR("10.0.0.0/8")
+			_, narrow, _ := net.ParseCIDR("10.1.0.0/16")
+			_, denied, _ := net.ParseCIDR("10.1.2.0/24")
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, AllowedClientNets: []*net.IPNet{wide}}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, AllowedClientNets: []*net.IPNet{narrow}, DeniedClientNets: []*net.IPNet{denied}}))
+
+			list := pool.ClientIPAccess()
+			Expect(list.Allows(net.ParseIP("10.1.0.1"))).To(BeTrue())
+			Expect(list.Allows(net.ParseIP("10.2.0.1"))).To(BeFalse())
+			Expect(list.Allows(net.ParseIP("10.1.2.1"))).To(BeFalse())
+		})
+
+		It("allows no IP when the endpoints allow none in common", func() {
+			_, a, _ := net.ParseCIDR("10.0.0.0/8")
+			_, b, _ := net.ParseCIDR("192.168.0.0/16")
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, AllowedClientNets: []*net.IPNet{a}}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, AllowedClientNets: []*net.IPNet{b}}))
+
+			list := pool.ClientIPAccess()
+			Expect(list.Allows(net.ParseIP("10.0.0.1"))).To(BeFalse())
+			Expect(list.Allows(net.ParseIP("192.168.0.1"))).To(BeFalse())
+			Expect(list.Allows(net.ParseIP("::1"))).To(BeFalse())
+		})
+
+		It("marshals the CIDRs of an endpoint", func() {
+			_, denied, _ := net.ParseCIDR("10.1.0.0/16")
+			e := route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, DeniedClientNets: []*net.IPNet{denied}})
+
+			data, err := e.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"denied_client_cidrs":["10.1.0.0/16"]`))
+		})
+	})
+
+	Context("MaxRequestBodyBytes", func() {
+		It("returns no limit when no endpoint registered one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			Expect(pool.MaxRequestBodyBytes()).To(BeZero())
+		})
+
+		It("returns the limit an endpoint registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, MaxRequestBodyBytes: 1024}))
+
+			Expect(pool.MaxRequestBodyBytes()).To(Equal(int64(1024)))
+		})
+
+		It("returns the lowest limit the endpoints registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, MaxRequestBodyBytes: 4096}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, MaxRequestBodyBytes: 1024}))
+
+			Expect(pool.MaxRequestBodyBytes()).To(Equal(int64(1024)))
+		})
+	})
+
+	Context("CORS", func() {
+		It("returns no policy when no endpoint registered one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			Expect(pool.CORS().IsEmpty()).To(BeTrue())
+		})
+
+		It("returns the policy an endpoint registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{
+				Host:                "10.0.1.2",
+				Port:                60000,
+				CORSAllowedOrigins:  []string{"*"},
+				CORSMaxAgeInSeconds: 60,
+			}))
+
+			Expect(pool.CORS()).To(Equal(route.CORSPolicy{AllowedOrigins: []string{"*"}, MaxAge: time.Minute}))
+		})
+
+		It("only allows what all the endpoints allow", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, CORSAllowedOrigins: []string{"*"}, CORSMaxAgeInSeconds: 60}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, CORSAllowedOrigins: []string{"https://example.com"}, CORSMaxAgeInSeconds: 30}))
+			Expect(pool.CORS().AllowedOrigins).To(Equal([]string{"https://example.com"}))
+			Expect(pool.CORS().MaxAge).To(Equal(30 * time.Second))
+
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.3", Port: 60000, CORSAllowedOrigins: []string{"https://other.example.com"}}))
+			Expect(pool.CORS().IsEmpty()).To(BeFalse())
+			Expect(pool.CORS().AllowedOrigins).To(BeEmpty())
+		})
+	})
+
+	Context("HostRewrite", func() {
+		It("returns no host when no endpoint registered one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			Expect(pool.HostRewrite()).To(BeEmpty())
+		})
+
+		It("returns the host an endpoint registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, HostRewrite: "legacy.internal"}))
+
+			Expect(pool.HostRewrite()).To(Equal("legacy.internal"))
+		})
+	})
+
+	Context("Mirror", func() {
+		It("returns no host when no endpoint registered one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			host, percent := pool.Mirror()
+			Expect(host).To(BeEmpty())
+			Expect(percent).To(BeZero())
+		})
+
+		It("returns the host and percentage an endpoint registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, MirrorHost: "v2.example.com", MirrorPercent: 10}))
+
+			host, percent := pool.Mirror()
+			Expect(host).To(Equal("v2.example.com"))
+			Expect(percent).To(Equal(10))
+		})
+	})
+
+	Context("PathRewrite", func() {
+		It("returns no path when no endpoint registered one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			Expect(pool.PathRewrite()).To(BeEmpty())
+		})
+
+		It("returns the root when an endpoint strips the context path", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, StripPathPrefix: true}))
+
+			Expect(pool.PathRewrite()).To(Equal("/"))
+		})
+
+		It("returns the path an endpoint registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, RewritePath: "/api/v2"}))
+
+			Expect(pool.PathRewrite()).To(Equal("/api/v2"))
+		})
+	})
+
+	Context("RateLimit", func() {
+		It("returns no limit when no endpoint registered one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			Expect(pool.RateLimit()).To(Equal(route.RateLimit{}))
+		})
+
+		It("returns the limit an endpoint registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, RateLimitPerSecond: 5, RateLimitBurst: 20}))
+
+			Expect(pool.RateLimit()).To(Equal(route.RateLimit{PerSecond: 5, Burst: 20}))
+		})
+
+		It("returns the lowest limit the endpoints registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, RateLimitPerSecond: 10, RateLimitBurst: 5}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, RateLimitPerSecond: 5, RateLimitBurst: 20}))
+
+			Expect(pool.RateLimit()).To(Equal(route.RateLimit{PerSecond: 5, Burst: 5}))
+		})
+	})
+
+	Context("JWTValidation", func() {
+		It("uses the default when no endpoint registered a policy", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+
+			required, audiences := pool.JWTValidation(true)
+			Expect(required).To(BeTrue())
+			Expect(audiences).To(BeNil())
+		})
+
+		It("returns the policy and audiences the endpoints registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{
+				Host:          "10.0.1.2",
+				Port:          60000,
+				JWTValidation: route.JWTValidationRequired,
+				JWTAudiences:  []string{"billing"},
+			}))
+
+			required, audiences := pool.JWTValidation(false)
+			Expect(required).To(BeTrue())
+			Expect(audiences).To(Equal([]string{"billing"}))
+		})
+
+		It("lets endpoints opt out", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, JWTValidation: route.JWTValidationNone}))
+
+			required, _ := pool.JWTValidation(true)
+			Expect(required).To(BeFalse())
+		})
+
+		It("requires a token when any endpoint requires one", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, JWTValidation: route.JWTValidationNone}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000}))
+
+			required, _ := pool.JWTValidation(true)
+			Expect(required).To(BeTrue())
+		})
+
+		It("returns the audiences that all the endpoints registered", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, JWTAudiences: []string{"billing", "reports"}}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, JWTAudiences: []string{"reports"}}))
+			_, audiences := pool.JWTValidation(true)
+			Expect(audiences).To(Equal([]string{"reports"}))
+
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.3", Port: 60000, JWTAudiences: []string{"billing"}}))
+			_, audiences = pool.JWTValidation(true)
+			Expect(audiences).ToNot(BeNil())
+			Expect(audiences).To(BeEmpty())
+		})
+	})
+
+	Context("ClientCertAllowlist", func() {
+		It("allows certificates with an allowed DNS, IP, URI or email SAN", func() {
+			allowlist := route.ClientCertAllowlist{SANs: []string{"billing.internal", "10.0.0.1", "spiffe://example.com/billing", "ops@example.com"}}
+			spiffe, err := url.Parse("spiffe://example.com/billing")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(allowlist.Allows(&x509.Certificate{DNSNames: []string{"Billing.Internal"}})).To(BeTrue())
+			Expect(allowlist.Allows(&x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}})).To(BeTrue())
+			Expect(allowlist.Allows(&x509.Certificate{URIs: []*url.URL{spiffe}})).To(BeTrue())
+			Expect(allowlist.Allows(&x509.Certificate{EmailAddresses: []string{"ops@example.com"}})).To(BeTrue())
+			Expect(allowlist.Allows(&x509.Certificate{DNSNames: []string{"other.internal"}})).To(BeFalse())
+		})
+
+		It("allows certificates with an allowed OU", func() {
+			allowlist := route.ClientCertAllowlist{OUs: []string{"payments"}}
+
+			Expect(allowlist.Allows(&x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"payments"}}})).To(BeTrue())
+			Expect(allowlist.Allows(&x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"Payments"}}})).To(BeFalse())
+		})
+
+		It("only allows what all the endpoints of the pool allow", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.1", Port: 60000, AllowedClientCertOUs: []string{"payments", "billing"}}))
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.2", Port: 60000, AllowedClientCertOUs: []string{"billing"}}))
+
+			allowlist := pool.AllowedClientCerts()
+			Expect(allowlist.Allows(&x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"billing"}}})).To(BeTrue())
+			Expect(allowlist.Allows(&x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"payments"}}})).To(BeFalse())
+
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "10.0.1.3", Port: 60000, AllowedClientCertSANs: []string{"billing.internal"}}))
+			allowlist = pool.AllowedClientCerts()
+			Expect(allowlist.IsEmpty()).To(BeFalse())
+			Expect(allowlist.Allows(&x509.Certificate{DNSNames: []string{"billing.internal"}})).To(BeFalse())
+		})
+	})
+
+	Context("ProxyProtocolVersion", func() {
+		It("uses the default version when the endpoint registered none", func() {
+			Expect(route.ProxyProtocolVersion("", route.ProxyProtocolV1)).To(Equal(route.ProxyProtocolV1))
+			Expect(route.ProxyProtocolVersion("", "")).To(BeEmpty())
+		})
+
+		It("uses the version the endpoint registered", func() {
+			Expect(route.ProxyProtocolVersion(route.ProxyProtocolV2, route.ProxyProtocolV1)).To(Equal(route.ProxyProtocolV2))
+			Expect(route.ProxyProtocolVersion(route.ProxyProtocolV1, "")).To(Equal(route.ProxyProtocolV1))
+		})
+
+		It("sends no header when the endpoint opted out", func() {
+			Expect(route.ProxyProtocolVersion(route.ProxyProtocolNone, route.ProxyProtocolV2)).To(BeEmpty())
+		})
+	})
+
+	Context("EndpointsForPath", func() {
+		var endpoints []*route.Endpoint
+
+		BeforeEach(func() {
+			endpoints = nil
+			for i := 0; i < 5; i++ {
+				e := route.NewEndpoint(&route.EndpointOpts{
+					Host: fmt.Sprintf("10.0.1.%d", i),
+					Port: 60000,
+					Tags: map[string]string{route.StickyPathSegmentTag: "/t/{tenant}"},
+				})
+				endpoints = append(endpoints, e)
+				pool.Put(e)
+			}
+		})
+
+		It("selects the same endpoint for requests of the same tenant", func() {
+			expected := pool.EndpointsForPath("", "", "/t/acme/orders").Next()
+			for i := 0; i < 20; i++ {
+				path := fmt.Sprintf("/t/acme/orders/%d", i)
+				Expect(pool.EndpointsForPath("", "", path).Next()).To(Equal(expected))
+			}
+		})
+
+		It("prefers the sticky session endpoint", func() {
+			tenant := pool.EndpointsForPath("", "", "/t/acme").Next()
+			other := endpoints[0]
+			if other == tenant {
+				other = endpoints[1]
+			}
+
+			Expect(pool.EndpointsForPath("", other.CanonicalAddr(), "/t/acme").Next()).To(Equal(other))
+		})
+
+		It("uses the load balancing algorithm for paths without the segment", func() {
+			selected := map[*route.Endpoint]bool{}
+			for i := 0; i < 10; i++ {
+				selected[pool.EndpointsForPath("", "", "/health").Next()] = true
+			}
+			Expect(len(selected)).To(BeNumerically(">", 1))
+		})
+	})
+
+	Context("EndpointsForKey", func() {
+		var endpoints []*route.Endpoint
+
+		BeforeEach(func() {
+			endpoints = nil
+			for i := 0; i < 5; i++ {
+				e := route.NewEndpoint(&route.EndpointOpts{
+					Host: fmt.Sprintf("10.0.1.%d", i),
+					Port: 60000,
+				})
+				endpoints = append(endpoints, e)
+				pool.Put(e)
+			}
+		})
+
+		It("selects the same endpoint for requests with the same key", func() {
+			expected := pool.EndpointsForKey(config.LOAD_BALANCE_CH, "", "/", "user-1").Next()
+			for i := 0; i < 20; i++ {
+				Expect(pool.EndpointsForKey(config.LOAD_BALANCE_CH, "", fmt.Sprintf("/%d", i), "user-1").Next()).To(Equal(expected))
+			}
+		})
+
+		It("only moves the keys of an endpoint that leaves the pool", func() {
+			before := map[string]*route.Endpoint{}
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("user-%d", i)
+				before[key] = pool.EndpointsForKey(config.LOAD_BALANCE_CH, "", "/", key).Next()
+			}
+
+			pool.Remove(endpoints[0])
+
+			for key, e := range before {
+				if e != endpoints[0] {
+					Expect(pool.EndpointsForKey(config.LOAD_BALANCE_CH, "", "/", key).Next()).To(Equal(e))
+				}
+			}
+		})
+
+		It("prefers the sticky session endpoint", func() {
+			expected := pool.EndpointsForKey(config.LOAD_BALANCE_CH, "", "/", "user-1").Next()
+			other := endpoints[0]
+			if other == expected {
+				other = endpoints[1]
+			}
+
+			Expect(pool.EndpointsForKey(config.LOAD_BALANCE_CH, other.CanonicalAddr(), "/", "user-1").Next()).To(Equal(other))
+		})
+
+		It("uses round-robin for requests without a key", func() {
+			selected := map[*route.Endpoint]bool{}
+			for i := 0; i < 10; i++ {
+				selected[pool.EndpointsForKey(config.LOAD_BALANCE_CH, "", "/", "").Next()] = true
+			}
+			Expect(len(selected)).To(BeNumerically(">", 1))
+		})
+
+		It("ignores the key for other load balancing algorithms", func() {
+			selected := map[*route.Endpoint]bool{}
+			for i := 0; i < 10; i++ {
+				selected[pool.EndpointsForKey(config.LOAD_BALANCE_RR, "", "/", "user-1").Next()] = true
+			}
+			Expect(len(selected)).To(BeNumerically(">", 1))
+		})
+	})
+
+	Context("ForRequest", func() {
+		var (
+			req      *http.Request
+			fallback *route.Endpoint
+			v2       *route.Endpoint
+			writes   *route.Endpoint
+		)
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "http://example.com/", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			fallback = route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678})
+			v2 = route.NewEndpoint(&route.EndpointOpts{Host: "2.2.2.2", Port: 5678, MatchHeaders: map[string]string{"X-Api-Version": "2"}})
+			writes = route.NewEndpoint(&route.EndpointOpts{Host: "3.3.3.3", Port: 5678, MatchMethods: []string{"PUT", "POST"}})
+			pool.Put(fallback)
+			pool.Put(v2)
+			pool.Put(writes)
+		})
+
+		It("returns the pool when the route has no matches", func() {
+			p := route.NewPool(&route.PoolOpts{Logger: logger})
+			p.Put(route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678}))
+
+			Expect(p.ForRequest(req)).To(BeIdenticalTo(p))
+			Expect(p.Matched()).To(BeFalse())
+		})
+
+		It("counts the endpoints registered with a match as endpoints of the pool", func() {
+			Expect(pool.NumEndpoints()).To(Equal(3))
+			Expect(pool.Matched()).To(BeTrue())
+		})
+
+		It("selects the endpoints registered without a match for other requests", func() {
+			p := pool.ForRequest(req)
+			Expect(p).To(BeIdenticalTo(pool))
+			Expect(p.Endpoints("", "").Next()).To(Equal(fallback))
+		})
+
+		It("selects the endpoints of the match the request matches", func() {
+			req.Method = "PUT"
+			p := pool.ForRequest(req)
+			Expect(p.NumEndpoints()).To(Equal(1))
+			Expect(p.Endpoints("", "").Next()).To(Equal(writes))
+			Expect(p.Host()).To(Equal(pool.Host()))
+		})
+
+		It("prefers matches on headers to matches on methods", func() {
+			req.Method = "PUT"
+			req.Header.Set("X-Api-Version", "2")
+			Expect(pool.ForRequest(req).Endpoints("", "").Next()).To(Equal(v2))
+		})
+
+		It("returns nil when no match matches and no endpoint registered without one", func() {
+			pool.Remove(fallback)
+			Expect(pool.ForRequest(req)).To(BeNil())
+		})
+
+		It("moves an endpoint when it registers another match", func() {
+			moved := route.NewEndpoint(&route.EndpointOpts{Host: "3.3.3.3", Port: 5678})
+			pool.Put(moved)
+			Expect(pool.NumEndpoints()).To(Equal(3))
+
+			req.Method = "PUT"
+			Expect(pool.ForRequest(req)).To(BeIdenticalTo(pool))
+		})
+
+		It("removes endpoints registered with a match", func() {
+			Expect(pool.Remove(v2)).To(BeTrue())
+			Expect(pool.NumEndpoints()).To(Equal(2))
+
+			req.Header.Set("X-Api-Version", "2")
+			Expect(pool.ForRequest(req)).To(BeIdenticalTo(pool))
+		})
+
+		It("applies the policies of the route to the endpoints of the match the request matches", func() {
+			_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{
+				Host:                 "1.1.1.1",
+				Port:                 5678,
+				AllowedClientNets:    []*net.IPNet{allowed},
+				AllowedClientCertOUs: []string{"payments"},
+				JWTValidation:        route.JWTValidationRequired,
+				RateLimitPerSecond:   5,
+				MaxRequestBodyBytes:  1024,
+				CORSAllowedOrigins:   []string{"https://example.com"},
+			}))
+
+			req.Header.Set("X-Api-Version", "2")
+			p := pool.ForRequest(req)
+			Expect(p.Endpoints("", "").Next()).To(Equal(v2))
+
+			Expect(p.ClientIPAccess().Allows(net.ParseIP("192.168.0.1"))).To(BeFalse())
+			Expect(p.AllowedClientCerts()).To(Equal(route.ClientCertAllowlist{OUs: []string{"payments"}}))
+			required, _ := p.JWTValidation(false)
+			Expect(required).To(BeTrue())
+			Expect(p.RateLimit()).To(Equal(route.RateLimit{PerSecond: 5}))
+			Expect(p.MaxRequestBodyBytes()).To(Equal(int64(1024)))
+			Expect(p.CORS().AllowedOrigins).To(Equal([]string{"https://example.com"}))
+		})
+
+		It("includes the endpoints registered with a match in its JSON", func() {
+			data, err := pool.MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"match_headers":{"X-Api-Version":"2"}`))
+			Expect(string(data)).To(ContainSubstring(`"match_methods":["POST","PUT"]`))
+		})
+	})
+
+	Context("when endpoints registered in groups", func() {
+		var (
+			req    *http.Request
+			stable *route.Endpoint
+			canary *route.Endpoint
+		)
+
+		split := func(n int) map[*route.Endpoint]int {
+			counts := map[*route.Endpoint]int{}
+			for i := 0; i < n; i++ {
+				counts[pool.ForRequest(req).Endpoints("", "").Next()]++
+			}
+			return counts
+		}
+
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "http://example.com/", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			stable = route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678, Group: "stable", GroupPercent: 90})
+			canary = route.NewEndpoint(&route.EndpointOpts{Host: "2.2.2.2", Port: 5678, Group: "canary", GroupPercent: 10})
+			pool.Put(stable)
+			pool.Put(canary)
+		})
+
+		It("splits requests between the groups by their percentages", func() {
+			counts := split(2000)
+			Expect(counts[stable]).To(BeNumerically("~", 1800, 100))
+			Expect(counts[canary]).To(BeNumerically("~", 200, 100))
+		})
+
+		It("gives endpoints without a percentage what the groups leave", func() {
+			pool.Remove(stable)
+			ungrouped := route.NewEndpoint(&route.EndpointOpts{Host: "3.3.3.3", Port: 5678})
+			pool.Put(ungrouped)
+
+			counts := split(2000)
+			Expect(counts[ungrouped]).To(BeNumerically("~", 1800, 100))
+			Expect(counts[canary]).To(BeNumerically("~", 200, 100))
+		})
+
+		It("does not send requests to groups without a percentage when the others take all of them", func() {
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{Host: "3.3.3.3", Port: 5678, Group: "other"}))
+
+			counts := split(200)
+			Expect(counts).To(HaveLen(2))
+			Expect(counts).To(HaveKey(stable))
+			Expect(counts).To(HaveKey(canary))
+		})
+
+		It("only splits requests between the groups of the match they match", func() {
+			v2 := route.NewEndpoint(&route.EndpointOpts{Host: "3.3.3.3", Port: 5678, MatchHeaders: map[string]string{"X-Api-Version": "2"}})
+			pool.Put(v2)
+			req.Header.Set("X-Api-Version", "2")
+
+			Expect(split(50)).To(Equal(map[*route.Endpoint]int{v2: 50}))
+		})
+
+		It("does not report the route as matched", func() {
+			Expect(pool.Matched()).To(BeFalse())
+		})
+
+		It("applies the policies of the route to the groups that registered none", func() {
+			pool.Remove(stable)
+			pool.Put(route.NewEndpoint(&route.EndpointOpts{
+				Host:                  "1.1.1.1",
+				Port:                  5678,
+				Group:                 "stable",
+				GroupPercent:          90,
+				AllowedClientCertSANs: []string{"billing.internal"},
+				JWTValidation:         route.JWTValidationRequired,
+				JWTAudiences:          []string{"billing"},
+				RateLimitPerSecond:    5,
+				MaxRequestBodyBytes:   1024,
+			}))
+
+			var p *route.Pool
+			Eventually(func() *route.Endpoint {
+				p = pool.ForRequest(req)
+				return p.Endpoints("", "").Next()
+			}).Should(Equal(canary))
+
+			Expect(p.AllowedClientCerts()).To(Equal(route.ClientCertAllowlist{SANs: []string{"billing.internal"}}))
+			required, audiences := p.JWTValidation(false)
+			Expect(required).To(BeTrue())
+			Expect(audiences).To(Equal([]string{"billing"}))
+			Expect(p.RateLimit()).To(Equal(route.RateLimit{PerSecond: 5}))
+			Expect(p.MaxRequestBodyBytes()).To(Equal(int64(1024)))
+		})
+	})
+
+	Context("NumEndpoints", func() {
+		It("counts the endpoints in the pool", func() {
+			Expect(pool.NumEndpoints()).To(Equal(0))
+
+			e1 := route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678})
+			e2 := route.NewEndpoint(&route.EndpointOpts{Host: "2.2.2.2", Port: 5678})
+			pool.Put(e1)
+			pool.Put(e2)
+			Expect(pool.NumEndpoints()).To(Equal(2))
+
+			pool.Remove(e1)
+			Expect(pool.NumEndpoints()).To(Equal(1))
+		})
+	})
+
 	Context("IsEmpty", func() {
 		It("starts empty", func() {
 			Expect(pool.IsEmpty()).To(BeTrue())
@@ -496,12 +1524,38 @@ var _ = Describe("Pool", func() {
 			It("does not prune the tls endpoints", func() {
 				pool.MarkUpdated(time.Now().Add(-2 * defaultThreshold))
 				Expect(pool.IsEmpty()).To(Equal(false))
+				Expect(pool.HasStaleEndpoints()).To(BeFalse())
 				prunedEndpoints := pool.PruneEndpoints()
 				Expect(pool.IsEmpty()).To(Equal(false))
 				Expect(len(prunedEndpoints)).To(Equal(0))
 			})
 		})
 
+		Context("when the pool contains provisional tls endpoints", func() {
+			var provisional *route.Endpoint
+
+			BeforeEach(func() {
+				provisional = route.NewEndpoint(&route.EndpointOpts{Port: 1234, UseTLS: true, StaleThresholdInSeconds: 60})
+				provisional.Provisional = true
+				pool.Put(provisional)
+			})
+
+			It("prunes them once they pass the stale threshold", func() {
+				pool.MarkUpdated(time.Now().Add(-2 * defaultThreshold))
+				Expect(pool.HasStaleEndpoints()).To(BeTrue())
+				prunedEndpoints := pool.PruneEndpoints()
+				Expect(prunedEndpoints).To(ConsistOf(provisional))
+				Expect(pool.IsEmpty()).To(BeTrue())
+			})
+
+			It("does not prune them once they are registered again", func() {
+				pool.Put(route.NewEndpoint(&route.EndpointOpts{Port: 1234, UseTLS: true, StaleThresholdInSeconds: 60}))
+				pool.MarkUpdated(time.Now().Add(-2 * defaultThreshold))
+				Expect(pool.PruneEndpoints()).To(BeEmpty())
+				Expect(pool.IsEmpty()).To(BeFalse())
+			})
+		})
+
 		Context("when an endpoint has passed the stale threshold", func() {
 			It("prunes the endpoint", func() {
 				e1 := route.NewEndpoint(&route.EndpointOpts{UseTLS: false, StaleThresholdInSeconds: 20})
@@ -510,6 +1564,7 @@ var _ = Describe("Pool", func() {
 				pool.MarkUpdated(time.Now().Add(-25 * time.Second))
 
 				Expect(pool.IsEmpty()).To(Equal(false))
+				Expect(pool.HasStaleEndpoints()).To(BeTrue())
 				prunedEndpoints := pool.PruneEndpoints()
 				Expect(pool.IsEmpty()).To(Equal(true))
 				Expect(prunedEndpoints).To(ConsistOf(e1))
@@ -524,6 +1579,7 @@ var _ = Describe("Pool", func() {
 				pool.MarkUpdated(time.Now())
 
 				Expect(pool.IsEmpty()).To(Equal(false))
+				Expect(pool.HasStaleEndpoints()).To(BeFalse())
 				prunedEndpoints := pool.PruneEndpoints()
 				Expect(pool.IsEmpty()).To(Equal(false))
 				Expect(prunedEndpoints).To(BeEmpty())
@@ -595,6 +1651,48 @@ var _ = Describe("Pool", func() {
 		})
 	})
 
+	Context("IdleEndpoints", func() {
+		It("returns endpoints that have not served a request within the threshold", func() {
+			idle := route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678})
+			busy := route.NewEndpoint(&route.EndpointOpts{Host: "2.2.2.2", Port: 5678})
+			pool.Put(idle)
+			pool.Put(busy)
+
+			time.Sleep(20 * time.Millisecond)
+			busy.MarkServed()
+
+			Expect(pool.IdleEndpoints(10 * time.Millisecond)).To(ConsistOf(idle))
+		})
+
+		It("starts the idle time when the endpoint is added", func() {
+			e1 := route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678})
+			pool.Put(e1)
+
+			Expect(pool.IdleEndpoints(time.Minute)).To(BeEmpty())
+			Expect(e1.LastServed()).To(BeTemporally("~", time.Now(), time.Second))
+		})
+
+		It("keeps the last served time when the endpoint is updated", func() {
+			e1 := route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678, ModificationTag: models.ModificationTag{Guid: "abc", Index: 1}})
+			pool.Put(e1)
+			time.Sleep(20 * time.Millisecond)
+
+			e2 := route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678, ModificationTag: models.ModificationTag{Guid: "abc", Index: 2}})
+			pool.Put(e2)
+
+			Expect(e2.LastServed()).To(Equal(e1.LastServed()))
+			Expect(pool.IdleEndpoints(10 * time.Millisecond)).To(ConsistOf(e2))
+		})
+
+		It("does not return tls endpoints", func() {
+			e1 := route.NewEndpoint(&route.EndpointOpts{Host: "1.1.1.1", Port: 5678, UseTLS: true})
+			pool.Put(e1)
+			time.Sleep(20 * time.Millisecond)
+
+			Expect(pool.IdleEndpoints(10 * time.Millisecond)).To(BeEmpty())
+		})
+	})
+
 	Context("MarkUpdated", func() {
 		It("updates all endpoints", func() {
 			e1 := route.NewEndpoint(&route.EndpointOpts{Port: 5678, StaleThresholdInSeconds: 120})
@@ -688,6 +1786,34 @@ var _ = Describe("Pool", func() {
 		Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","tls":false,"ttl":-1,"route_service_url":"https://my-rs.com","tags":null},{"address":"5.6.7.8:5678","tls":true,"ttl":-1,"tags":null,"private_instance_id":"pvt_test_instance_id","server_cert_domain_san":"pvt_test_san"}]`))
 	})
 
+	It("marshals the protocol of endpoints that register one", func() {
+		e := route.NewEndpoint(&route.EndpointOpts{
+			Host:                    "1.2.3.4",
+			Port:                    5678,
+			StaleThresholdInSeconds: -1,
+			Protocol:                route.ProtocolHTTP2,
+		})
+		pool.Put(e)
+
+		json, err := pool.MarshalJSON()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","tls":false,"ttl":-1,"tags":null,"protocol":"http2"}]`))
+	})
+
+	It("marshals the weight of endpoints that register one", func() {
+		e := route.NewEndpoint(&route.EndpointOpts{
+			Host:                    "1.2.3.4",
+			Port:                    5678,
+			StaleThresholdInSeconds: -1,
+			Weight:                  3,
+		})
+		pool.Put(e)
+
+		json, err := pool.MarshalJSON()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(json)).To(Equal(`[{"address":"1.2.3.4:5678","tls":false,"ttl":-1,"tags":null,"weight":3}]`))
+	})
+
 	Context("when endpoints do not have empty tags", func() {
 		var e *route.Endpoint
 		BeforeEach(func() {
@@ -59,12 +59,18 @@ func (r *RoundRobin) next() *endpointElem {
 		return nil
 	}
 
+	if r.pool.isWeighted() {
+		return r.nextWeighted()
+	}
+
 	if r.pool.nextIdx == -1 {
 		r.pool.nextIdx = r.pool.random.Intn(last)
 	} else if r.pool.nextIdx >= last {
 		r.pool.nextIdx = 0
 	}
 
+	unpreferred := r.pool.unpreferred(time.Now())
+	wrapped, includeUnhealthy := false, false
 	startIdx := r.pool.nextIdx
 	curIdx := startIdx
 	for {
@@ -75,7 +81,7 @@ func (r *RoundRobin) next() *endpointElem {
 			curIdx = 0
 		}
 
-		if e.isOverloaded() {
+		if e.isOverloaded() || unpreferred(e) {
 			if curIdx == startIdx {
 				return nil
 			}
@@ -90,7 +96,7 @@ func (r *RoundRobin) next() *endpointElem {
 			}
 		}
 
-		if e.failedAt == nil {
+		if e.failedAt == nil && (!e.outOfRotation(time.Now()) || includeUnhealthy) {
 			r.pool.nextIdx = curIdx
 			return e
 		}
@@ -100,10 +106,57 @@ func (r *RoundRobin) next() *endpointElem {
 			for _, e2 := range r.pool.endpoints {
 				e2.failedAt = nil
 			}
+			// endpoints that fail their health checks or are ejected are used
+			// when no other endpoint is available
+			includeUnhealthy = wrapped
+			wrapped = true
 		}
 	}
 }
 
+// nextWeighted selects endpoints with smooth weighted round-robin, which
+// interleaves the endpoints in proportion to their weights. The caller must
+// hold the pool lock.
+func (r *RoundRobin) nextWeighted() *endpointElem {
+	now := time.Now()
+	selected := r.selectWeighted(now, true)
+	if selected == nil {
+		// all endpoints are overloaded or marked failed, so reset the failed
+		// ones to available
+		for _, e := range r.pool.endpoints {
+			e.failedAt = nil
+		}
+		selected = r.selectWeighted(now, false)
+	}
+	return selected
+}
+
+func (r *RoundRobin) selectWeighted(now time.Time, skipFailed bool) *endpointElem {
+	var selected *endpointElem
+	total := 0
+	unpreferred := r.pool.unpreferred(now)
+	for _, e := range r.pool.endpoints {
+		if e.isOverloaded() || unpreferred(e) {
+			continue
+		}
+		if skipFailed && e.failedWithin(r.pool.retryAfterFailure, now) {
+			continue
+		}
+
+		weight := e.endpoint.weight()
+		e.currentWeight += weight
+		total += weight
+		if selected == nil || e.currentWeight > selected.currentWeight {
+			selected = e
+		}
+	}
+
+	if selected != nil {
+		selected.currentWeight -= total
+	}
+	return selected
+}
+
 func (r *RoundRobin) EndpointFailed(err error) {
 	if r.lastEndpoint != nil {
 		r.pool.EndpointFailed(r.lastEndpoint, err)
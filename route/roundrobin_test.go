@@ -175,6 +175,63 @@ var _ = Describe("RoundRobin", func() {
 			wg.Wait()
 		})
 
+		Context("when endpoints have weights", func() {
+			var e1, e2 *route.Endpoint
+
+			BeforeEach(func() {
+				e1 = route.NewEndpoint(&route.EndpointOpts{Host: "1.2.3.4", Port: 5678, Weight: 1})
+				e2 = route.NewEndpoint(&route.EndpointOpts{Host: "5.6.7.8", Port: 1234, Weight: 3})
+				pool.Put(e1)
+				pool.Put(e2)
+			})
+
+			It("distributes requests in proportion to the weights", func() {
+				iter := route.NewRoundRobin(pool, "")
+
+				counts := map[*route.Endpoint]int{}
+				for i := 0; i < 40; i++ {
+					counts[iter.Next()]++
+				}
+
+				Expect(counts[e1]).To(Equal(10))
+				Expect(counts[e2]).To(Equal(30))
+			})
+
+			It("interleaves the endpoints", func() {
+				iter := route.NewRoundRobin(pool, "")
+
+				picks := []*route.Endpoint{iter.Next(), iter.Next(), iter.Next(), iter.Next()}
+				Expect(picks).To(ContainElement(e1))
+				Expect(picks).To(ContainElement(e2))
+			})
+
+			It("skips failed endpoints", func() {
+				iter := route.NewRoundRobin(pool, "")
+				for iter.Next() != e2 {
+				}
+				iter.EndpointFailed(&net.OpError{Op: "dial"})
+
+				for i := 0; i < 5; i++ {
+					Expect(iter.Next()).To(Equal(e1))
+				}
+			})
+
+			It("treats endpoints without a weight as having a weight of 1", func() {
+				e3 := route.NewEndpoint(&route.EndpointOpts{Host: "1.2.7.8", Port: 1234})
+				pool.Put(e3)
+				iter := route.NewRoundRobin(pool, "")
+
+				counts := map[*route.Endpoint]int{}
+				for i := 0; i < 50; i++ {
+					counts[iter.Next()]++
+				}
+
+				Expect(counts[e1]).To(Equal(10))
+				Expect(counts[e2]).To(Equal(30))
+				Expect(counts[e3]).To(Equal(10))
+			})
+		})
+
 		Context("when some endpoints are overloaded", func() {
 			var (
 				epOne, epTwo *route.Endpoint
@@ -1,6 +1,7 @@
 package route_fetcher
 
 import (
+	"math/rand"
 	"os"
 	"sync/atomic"
 	"time"
@@ -24,8 +25,15 @@ type RouteFetcher struct {
 	FetchRoutesInterval                time.Duration
 	SubscriptionRetryIntervalInSeconds int
 
+	// FetchRoutesJitter is the fraction of FetchRoutesInterval by which each
+	// fetch is randomly brought forward or delayed, so that gorouters that
+	// started together do not all fetch the routing table at once.
+	FetchRoutesJitter float64
+
 	logger          logger.Logger
 	endpoints       []models.Route
+	registeredAt    map[routeKey]time.Time
+	random          *rand.Rand
 	client          routing_api.Client
 	stopEventSource int32
 	eventSource     atomic.Value
@@ -46,10 +54,12 @@ func NewRouteFetcher(logger logger.Logger, uaaClient uaa_client.Client, routeReg
 		UaaClient:                          uaaClient,
 		RouteRegistry:                      routeRegistry,
 		FetchRoutesInterval:                cfg.PruneStaleDropletsInterval / 2,
+		FetchRoutesJitter:                  cfg.RoutingApi.FetchJitter,
 		SubscriptionRetryIntervalInSeconds: subscriptionRetryInterval,
 
 		client:       client,
 		logger:       logger,
+		random:       rand.New(rand.NewSource(time.Now().UnixNano())),
 		eventChannel: make(chan routing_api.Event, 1024),
 		clock:        clock,
 	}
@@ -58,18 +68,19 @@ func NewRouteFetcher(logger logger.Logger, uaaClient uaa_client.Client, routeReg
 func (r *RouteFetcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	r.startEventCycle()
 
-	ticker := r.clock.NewTicker(r.FetchRoutesInterval)
-	r.logger.Debug("created-ticker", zap.Duration("interval", r.FetchRoutesInterval))
+	timer := r.clock.NewTimer(r.fetchRoutesInterval())
+	r.logger.Debug("created-timer", zap.Duration("interval", r.FetchRoutesInterval), zap.Float64("jitter", r.FetchRoutesJitter))
 	r.logger.Info("syncer-started")
 
 	close(ready)
 	for {
 		select {
-		case <-ticker.C():
+		case <-timer.C():
 			err := r.FetchRoutes()
 			if err != nil {
 				r.logger.Error("failed-to-fetch-routes", zap.Error(err))
 			}
+			timer.Reset(r.fetchRoutesInterval())
 		case e := <-r.eventChannel:
 			r.HandleEvent(e)
 
@@ -82,12 +93,22 @@ func (r *RouteFetcher) Run(signals <-chan os.Signal, ready chan<- struct{}) erro
 					r.logger.Error("failed-closing-routing-api-event-source", zap.Error(err))
 				}
 			}
-			ticker.Stop()
+			timer.Stop()
 			return nil
 		}
 	}
 }
 
+// fetchRoutesInterval returns FetchRoutesInterval moved by a random amount of
+// up to FetchRoutesJitter of it in either direction.
+func (r *RouteFetcher) fetchRoutesInterval() time.Duration {
+	if r.FetchRoutesJitter <= 0 {
+		return r.FetchRoutesInterval
+	}
+	jitter := (2*r.random.Float64() - 1) * r.FetchRoutesJitter
+	return r.FetchRoutesInterval + time.Duration(jitter*float64(r.FetchRoutesInterval))
+}
+
 func (r *RouteFetcher) startEventCycle() {
 	go func() {
 		forceUpdate := false
@@ -219,12 +240,35 @@ func (r *RouteFetcher) fetchRoutesWithTokenRefresh() ([]models.Route, error) {
 	return routes, err
 }
 
+// refreshEndpoints registers the routes that were added or changed since the
+// previous fetch, and unregisters the routes that were removed. The routing
+// API serves the whole table on every fetch and has no ETags to ask for
+// changes only, so the routes are compared with the previous fetch instead,
+// using their modification tags. Unchanged routes are registered again once
+// half of their TTL has passed since they were last registered, so that they
+// do not go stale when no events are received.
 func (r *RouteFetcher) refreshEndpoints(validRoutes []models.Route) {
 	r.deleteEndpoints(validRoutes)
 
-	r.endpoints = validRoutes
-
+	previous := make(map[routeKey]models.Route, len(r.endpoints))
 	for _, aRoute := range r.endpoints {
+		previous[keyOf(aRoute)] = aRoute
+	}
+
+	now := r.clock.Now()
+	registeredAt := make(map[routeKey]time.Time, len(validRoutes))
+	unchanged := 0
+	for _, aRoute := range validRoutes {
+		key := keyOf(aRoute)
+		if prevRoute, ok := previous[key]; ok && routeUnchanged(prevRoute, aRoute) {
+			lastRegistered, registered := r.registeredAt[key]
+			if registered && now.Sub(lastRegistered) < time.Duration(aRoute.GetTTL())*time.Second/2 {
+				registeredAt[key] = lastRegistered
+				unchanged++
+				continue
+			}
+		}
+
 		r.RouteRegistry.Register(
 			route.Uri(aRoute.Route),
 			route.NewEndpoint(&route.EndpointOpts{
@@ -238,7 +282,12 @@ func (r *RouteFetcher) refreshEndpoints(validRoutes []models.Route) {
 				UseTLS:                  false,
 			}),
 		)
+		registeredAt[key] = now
 	}
+
+	r.logger.Debug("syncer-skipped-unchanged-routes", zap.Int("number-of-routes", unchanged))
+	r.endpoints = validRoutes
+	r.registeredAt = registeredAt
 }
 
 func (r *RouteFetcher) deleteEndpoints(validRoutes []models.Route) {
@@ -276,6 +325,25 @@ func (r *RouteFetcher) deleteEndpoints(validRoutes []models.Route) {
 	}
 }
 
+type routeKey struct {
+	route string
+	ip    string
+	port  uint16
+}
+
+func keyOf(aRoute models.Route) routeKey {
+	return routeKey{route: aRoute.Route, ip: aRoute.IP, port: uint16(aRoute.Port)}
+}
+
+// routeUnchanged reports whether desired would register the same endpoint as
+// current.
+func routeUnchanged(current, desired models.Route) bool {
+	return current.ModificationTag == desired.ModificationTag &&
+		current.LogGuid == desired.LogGuid &&
+		current.RouteServiceUrl == desired.RouteServiceUrl &&
+		current.GetTTL() == desired.GetTTL()
+}
+
 func routeEquals(current, desired models.Route) bool {
 	if current.Route == desired.Route && current.IP == desired.IP && current.Port == desired.Port {
 		return true
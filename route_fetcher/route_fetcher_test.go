@@ -204,7 +204,7 @@ var _ = Describe("RouteFetcher", func() {
 
 			err = fetcher.FetchRoutes()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(registry.RegisterCallCount()).To(Equal(4))
+			Expect(registry.RegisterCallCount()).To(Equal(3))
 			Expect(registry.UnregisterCallCount()).To(Equal(2))
 
 			expectedUnregisteredRoutes := []models.Route{
@@ -229,6 +229,49 @@ var _ = Describe("RouteFetcher", func() {
 			}
 		})
 
+		Context("when routes have not changed since the previous fetch", func() {
+			BeforeEach(func() {
+				client.RoutesReturns(response, nil)
+
+				err := fetcher.FetchRoutes()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(registry.RegisterCallCount()).To(Equal(3))
+			})
+
+			It("does not register them again", func() {
+				err := fetcher.FetchRoutes()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(registry.RegisterCallCount()).To(Equal(3))
+				Expect(registry.UnregisterCallCount()).To(Equal(0))
+			})
+
+			It("registers the routes whose modification tag changed", func() {
+				changed := response[1]
+				changed.ModificationTag = models.ModificationTag{Guid: "guid", Index: 1}
+				client.RoutesReturns([]models.Route{response[0], changed, response[2]}, nil)
+
+				err := fetcher.FetchRoutes()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(registry.RegisterCallCount()).To(Equal(4))
+
+				uri, endpoint := registry.RegisterArgsForCall(3)
+				Expect(uri).To(Equal(route.Uri(changed.Route)))
+				Expect(endpoint.ModificationTag).To(Equal(changed.ModificationTag))
+			})
+
+			It("registers them again once half of their TTL has passed", func() {
+				clock.Increment(400 * time.Millisecond)
+				err := fetcher.FetchRoutes()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(registry.RegisterCallCount()).To(Equal(3))
+
+				clock.Increment(100 * time.Millisecond)
+				err = fetcher.FetchRoutes()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(registry.RegisterCallCount()).To(Equal(6))
+			})
+		})
+
 		Context("when the routing api returns an error", func() {
 			Context("error is not unauthorized error", func() {
 				It("returns an error", func() {
@@ -308,6 +351,19 @@ var _ = Describe("RouteFetcher", func() {
 				Eventually(client.RoutesCallCount, 2*time.Second, 50*time.Millisecond).Should(Equal(2))
 			})
 
+			Context("with jitter", func() {
+				BeforeEach(func() {
+					fetcher.FetchRoutesJitter = 0.5
+				})
+
+				It("fetches routes within the jitter of the interval", func() {
+					clock.WaitForWatcherAndIncrement(4 * time.Millisecond)
+					Consistently(client.RoutesCallCount, 100*time.Millisecond).Should(Equal(0))
+					clock.Increment(11 * time.Millisecond)
+					Eventually(client.RoutesCallCount, 2*time.Second, 50*time.Millisecond).Should(Equal(1))
+				})
+			})
+
 			It("uses cache when fetching token from uaa", func() {
 				clock.Increment(cfg.PruneStaleDropletsInterval + 100*time.Millisecond)
 				Eventually(client.RoutesCallCount, 2*time.Second, 50*time.Millisecond).Should(Equal(1))
@@ -0,0 +1,123 @@
+package router
+
+import (
+	"crypto/tls"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// serverCertificates are the certificates of the TLS and HTTP/3 listeners.
+// They can be replaced while the router runs, and handshakes after that use
+// the new certificates. OCSP staples are kept apart from the certificates, by
+// leaf, so that they survive a replacement that keeps the same leaf.
+type serverCertificates struct {
+	value atomic.Value // *tls.Config with Certificates and NameToCertificate
+
+	mu           sync.Mutex
+	certificates []tls.Certificate
+	staples      map[string][]byte
+	updated      chan struct{}
+}
+
+func newServerCertificates(certificates []tls.Certificate) *serverCertificates {
+	s := &serverCertificates{
+		staples: map[string][]byte{},
+		updated: make(chan struct{}, 1),
+	}
+	s.update(certificates)
+	return s
+}
+
+func (s *serverCertificates) update(certificates []tls.Certificate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.certificates = certificates
+	leaves := map[string]bool{}
+	for _, cert := range certificates {
+		if len(cert.Certificate) > 0 {
+			leaves[string(cert.Certificate[0])] = true
+		}
+	}
+	for leaf := range s.staples {
+		if !leaves[leaf] {
+			delete(s.staples, leaf)
+		}
+	}
+	s.publish()
+
+	select {
+	case s.updated <- struct{}{}:
+	default:
+	}
+}
+
+// current returns the certificates without their staples.
+func (s *serverCertificates) current() []tls.Certificate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.certificates
+}
+
+// setStaple staples the OCSP response to the certificates with the given
+// leaf. A nil response removes the staple.
+func (s *serverCertificates) setStaple(leaf []byte, staple []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if staple == nil {
+		delete(s.staples, string(leaf))
+	} else {
+		s.staples[string(leaf)] = staple
+	}
+	s.publish()
+}
+
+func (s *serverCertificates) publish() {
+	certificates := make([]tls.Certificate, len(s.certificates))
+	for i, cert := range s.certificates {
+		if len(cert.Certificate) > 0 {
+			if staple, ok := s.staples[string(cert.Certificate[0])]; ok {
+				cert.OCSPStaple = staple
+			}
+		}
+		certificates[i] = cert
+	}
+	c := &tls.Config{Certificates: certificates}
+	c.BuildNameToCertificate()
+	s.value.Store(c)
+}
+
+// getCertificate selects a certificate the way crypto/tls does: by server
+// name, then by a wildcard name, then by what the client supports, and
+// otherwise the first one, which is the default.
+func (s *serverCertificates) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c := s.value.Load().(*tls.Config)
+	if len(c.Certificates) == 0 {
+		return nil, errors.New("no certificates configured")
+	}
+	if len(c.Certificates) == 1 {
+		return &c.Certificates[0], nil
+	}
+
+	name := strings.ToLower(hello.ServerName)
+	if cert, ok := c.NameToCertificate[name]; ok {
+		return cert, nil
+	}
+	if len(name) > 0 {
+		labels := strings.Split(name, ".")
+		labels[0] = "*"
+		if cert, ok := c.NameToCertificate[strings.Join(labels, ".")]; ok {
+			return cert, nil
+		}
+	}
+
+	for i := range c.Certificates {
+		if hello.SupportsCertificate(&c.Certificates[i]) == nil {
+			return &c.Certificates[i], nil
+		}
+	}
+	return &c.Certificates[0], nil
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// The states of a drain, as reported by the drain endpoint.
+const (
+	drainStateServing  = "serving"
+	drainStateWaiting  = "waiting"
+	drainStateDraining = "draining"
+	drainStateDrained  = "drained"
+	drainStateTimedOut = "timed_out"
+)
+
+type drainProgress struct {
+	State             string     `json:"state"`
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	ActiveConnections int        `json:"active_connections"`
+	IdleConnections   int        `json:"idle_connections"`
+}
+
+// DrainHandler serves the drain endpoint of the status listener. POST drains
+// and stops the router like SIGUSR1, and GET reports how far the drain has
+// got: the health check fails while it is waiting for the drain wait, and
+// then it is draining until the active connections complete or the drain
+// timeout passes. The router stops listening on the status port once it is
+// drained.
+func (r *Router) DrainHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		status := http.StatusOK
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			r.requestDrain()
+			status = http.StatusAccepted
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(r.drainProgress())
+	})
+}
+
+func (r *Router) requestDrain() {
+	r.drainOnce.Do(func() {
+		r.logger.Info("gorouter.drain.requested")
+		close(r.drainRequested)
+	})
+}
+
+func (r *Router) setDrainState(state string) {
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+
+	if r.drainStartedAt.IsZero() {
+		r.drainStartedAt = time.Now()
+	}
+	r.drainState = state
+}
+
+func (r *Router) drainProgress() drainProgress {
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+
+	progress := drainProgress{
+		State:             drainStateServing,
+		ActiveConnections: len(r.activeConns),
+		IdleConnections:   len(r.idleConns),
+	}
+	if r.drainState != "" {
+		progress.State = r.drainState
+		startedAt := r.drainStartedAt
+		progress.StartedAt = &startedAt
+	}
+	return progress
+}
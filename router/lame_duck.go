@@ -0,0 +1,88 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/uber-go/zap"
+)
+
+type lameDuckStatus struct {
+	LameDuck bool `json:"lame_duck"`
+}
+
+// LameDuckHandler serves the lame duck endpoint of the status listener. PUT
+// puts the router in lame duck mode, DELETE takes it out again, and GET
+// reports whether it is in it. A router that is draining cannot be taken out
+// of lame duck mode, and answers 409.
+func (r *Router) LameDuckHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodDelete:
+			if !r.SetLameDuck(req.Method == http.MethodPut) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lameDuckStatus{LameDuck: r.LameDuck()})
+	})
+}
+
+// SetLameDuck puts the router in lame duck mode, in which it fails its health
+// check so that the load balancer stops sending it requests, but keeps serving
+// the requests it still receives and keeps its routes up to date until it is
+// taken out of it. It returns false if the router is draining, which it does
+// in lame duck mode anyway.
+func (r *Router) SetLameDuck(enabled bool) bool {
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+
+	if r.drainState != "" {
+		return false
+	}
+	if r.lameDuck == enabled {
+		return true
+	}
+
+	r.lameDuck = enabled
+	if enabled {
+		atomic.StoreInt32(r.HeartbeatOK, 0)
+	} else if r.started {
+		atomic.StoreInt32(r.HeartbeatOK, 1)
+	}
+	r.logger.Info("gorouter.lame-duck", zap.Bool("enabled", enabled))
+	return true
+}
+
+// ToggleLameDuck puts the router in lame duck mode, or takes it out of it.
+func (r *Router) ToggleLameDuck() {
+	r.SetLameDuck(!r.LameDuck())
+}
+
+// LameDuck returns whether the router is in lame duck mode.
+func (r *Router) LameDuck() bool {
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+
+	return r.lameDuck
+}
+
+// markStarted passes the health check once the router is serving, unless it
+// was put in lame duck mode first.
+func (r *Router) markStarted() {
+	r.connLock.Lock()
+	defer r.connLock.Unlock()
+
+	r.started = true
+	if !r.lameDuck {
+		atomic.StoreInt32(r.HeartbeatOK, 1)
+	}
+}
@@ -0,0 +1,32 @@
+package router
+
+import (
+	"context"
+	"net"
+)
+
+// NewListener opens a TCP listener on addr. When reusePort is set the socket
+// is opened with SO_REUSEPORT so that several processes can accept
+// connections on the same port, and a positive backlog overrides the size of
+// the kernel accept queue. Both options are only supported on Linux; the
+// kernel further caps the backlog at net.core.somaxconn.
+func NewListener(addr string, backlog int, reusePort bool) (net.Listener, error) {
+	lc := net.ListenConfig{
+		Control: listenerControl(reusePort),
+	}
+
+	listener, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if backlog > 0 {
+		err = setListenBacklog(listener, backlog)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	return listener, nil
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func listenerControl(reusePort bool) func(network, address string, c syscall.RawConn) error {
+	if !reusePort {
+		return nil
+	}
+
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
+
+// setListenBacklog calls listen(2) again on the already listening socket,
+// which Linux allows in order to resize the accept queue.
+func setListenBacklog(listener net.Listener, backlog int) error {
+	tcpListener, ok := listener.(*net.TCPListener)
+	if !ok {
+		return nil
+	}
+
+	rawConn, err := tcpListener.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var listenErr error
+	err = rawConn.Control(func(fd uintptr) {
+		listenErr = unix.Listen(int(fd), backlog)
+	})
+	if err != nil {
+		return err
+	}
+	return listenErr
+}
@@ -0,0 +1,74 @@
+// +build linux
+
+package router_test
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/gorouter/router"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewListener", func() {
+	var listener net.Listener
+
+	withFD := func(l net.Listener, f func(fd int)) {
+		rawConn, err := l.(*net.TCPListener).SyscallConn()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rawConn.Control(func(fd uintptr) { f(int(fd)) })).To(Succeed())
+	}
+
+	AfterEach(func() {
+		if listener != nil {
+			listener.Close()
+		}
+	})
+
+	It("does not set SO_REUSEPORT by default", func() {
+		var err error
+		listener, err = router.NewListener("127.0.0.1:0", 0, false)
+		Expect(err).ToNot(HaveOccurred())
+
+		withFD(listener, func(fd int) {
+			value, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal(0))
+		})
+
+		_, err = router.NewListener(listener.Addr().String(), 0, false)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("sets SO_REUSEPORT when enabled", func() {
+		var err error
+		listener, err = router.NewListener("127.0.0.1:0", 0, true)
+		Expect(err).ToNot(HaveOccurred())
+
+		withFD(listener, func(fd int) {
+			value, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal(1))
+		})
+
+		second, err := router.NewListener(listener.Addr().String(), 0, true)
+		Expect(err).ToNot(HaveOccurred())
+		second.Close()
+	})
+
+	It("applies the configured backlog", func() {
+		var err error
+		listener, err = router.NewListener("127.0.0.1:0", 17, false)
+		Expect(err).ToNot(HaveOccurred())
+
+		withFD(listener, func(fd int) {
+			// For listening sockets the kernel reports the maximum accept
+			// queue length in tcpi_sacked.
+			info, err := unix.GetsockoptTCPInfo(fd, unix.SOL_TCP, unix.TCP_INFO)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Sacked).To(Equal(uint32(17)))
+		})
+	})
+})
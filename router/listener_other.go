@@ -0,0 +1,23 @@
+// +build !linux
+
+package router
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+func listenerControl(reusePort bool) func(network, address string, c syscall.RawConn) error {
+	if !reusePort {
+		return nil
+	}
+
+	return func(network, address string, c syscall.RawConn) error {
+		return errors.New("SO_REUSEPORT is only supported on Linux")
+	}
+}
+
+func setListenBacklog(listener net.Listener, backlog int) error {
+	return errors.New("setting the listener backlog is only supported on Linux")
+}
@@ -0,0 +1,161 @@
+package router
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"code.cloudfoundry.org/gorouter/metrics"
+	"github.com/uber-go/zap"
+	"golang.org/x/crypto/ocsp"
+)
+
+const maxOCSPResponseSize = 1024 * 1024
+
+// OCSPStapler fetches OCSP responses for the certificates of the router's
+// TLS listeners and staples them to handshakes. It fetches when it starts,
+// every refresh interval and whenever the certificates are replaced.
+type OCSPStapler struct {
+	certificates *serverCertificates
+	client       *http.Client
+	interval     time.Duration
+	reporter     metrics.OCSPReporter
+	logger       logger.Logger
+}
+
+func NewOCSPStapler(r *Router, client *http.Client, interval time.Duration, reporter metrics.OCSPReporter, logger logger.Logger) *OCSPStapler {
+	return &OCSPStapler{
+		certificates: r.certificates,
+		client:       client,
+		interval:     interval,
+		reporter:     reporter,
+		logger:       logger,
+	}
+}
+
+func (s *OCSPStapler) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	select {
+	case <-s.certificates.updated:
+	default:
+	}
+	staples := map[string]*ocsp.Response{}
+	s.refresh(staples)
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+		case <-s.certificates.updated:
+		}
+		s.refresh(staples)
+	}
+}
+
+// refresh fetches a response for every leaf certificate that names an OCSP
+// responder. A staple whose fetch fails is kept until its NextUpdate.
+func (s *OCSPStapler) refresh(staples map[string]*ocsp.Response) {
+	now := time.Now()
+	leaves := map[string]bool{}
+
+	for _, cert := range s.certificates.current() {
+		if len(cert.Certificate) == 0 {
+			continue
+		}
+		leaf := cert.Certificate[0]
+		if leaves[string(leaf)] {
+			continue
+		}
+		leaves[string(leaf)] = true
+
+		resp, err := s.fetch(cert.Certificate)
+		if err != nil {
+			s.logger.Error("ocsp-fetch-failed", zap.Error(err))
+			s.reporter.CaptureOCSPStapleFailure()
+
+			if old, ok := staples[string(leaf)]; ok && !old.NextUpdate.IsZero() && now.After(old.NextUpdate) {
+				delete(staples, string(leaf))
+				s.certificates.setStaple(leaf, nil)
+			}
+			continue
+		}
+		if resp == nil {
+			continue
+		}
+
+		if resp.Status == ocsp.Revoked {
+			s.logger.Error("ocsp-certificate-revoked", zap.String("serial-number", resp.SerialNumber.String()))
+		}
+		staples[string(leaf)] = resp
+		s.certificates.setStaple(leaf, resp.Raw)
+	}
+
+	var oldest time.Time
+	for leaf, resp := range staples {
+		if !leaves[leaf] {
+			delete(staples, leaf)
+			continue
+		}
+		if oldest.IsZero() || resp.ThisUpdate.Before(oldest) {
+			oldest = resp.ThisUpdate
+		}
+	}
+	if !oldest.IsZero() {
+		s.reporter.CaptureOCSPStapleAge(now.Sub(oldest))
+	}
+}
+
+// fetch asks the responder of the leaf in chain for its status. It returns
+// no response if the leaf does not name a responder.
+func (s *OCSPStapler) fetch(chain [][]byte) (*ocsp.Response, error) {
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, nil
+	}
+	if len(chain) < 2 {
+		return nil, fmt.Errorf("certificate %q has no issuer in its chain", leaf.Subject.CommonName)
+	}
+	issuer, err := x509.ParseCertificate(chain[1])
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := s.client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp responder %s returned status %d", leaf.OCSPServer[0], httpResp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ocsp.ParseResponseForCert(body, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == ocsp.Unknown {
+		return nil, errors.New("ocsp responder does not know the certificate")
+	}
+	return resp, nil
+}
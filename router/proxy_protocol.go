@@ -0,0 +1,321 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtocolV1Prefix starts a version 1 (text) PROXY protocol header.
+var proxyProtocolV1Prefix = []byte("PROXY ")
+
+// proxyProtocolV2Signature starts a version 2 (binary) PROXY protocol header.
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	// proxyProtocolV1MaxLength is the longest a version 1 header may be,
+	// including the CRLF.
+	proxyProtocolV1MaxLength = 107
+
+	proxyProtocolV2CommandLocal = 0x0
+	proxyProtocolV2CommandProxy = 0x1
+
+	proxyProtocolV2FamilyInet  = 0x1
+	proxyProtocolV2FamilyInet6 = 0x2
+)
+
+// Types of PROXY protocol version 2 TLVs.
+const (
+	ProxyProtocolTLVALPN      = 0x01
+	ProxyProtocolTLVAuthority = 0x02
+	ProxyProtocolTLVCRC32C    = 0x03
+	ProxyProtocolTLVNoop      = 0x04
+	ProxyProtocolTLVUniqueID  = 0x05
+	ProxyProtocolTLVSSL       = 0x20
+	ProxyProtocolTLVNetNS     = 0x30
+)
+
+// ProxyProtocolTLV is a type-length-value extension of a PROXY protocol
+// version 2 header.
+type ProxyProtocolTLV struct {
+	Type  byte
+	Value []byte
+}
+
+// NewProxyProtocolListener wraps listener so that connections that start with
+// a PROXY protocol header, in version 1 or 2, report the addresses from the
+// header as their remote and local addresses. Connections without a header
+// are passed through unchanged. The header is read on the first use of the
+// connection, and must arrive within headerTimeout unless it is zero.
+func NewProxyProtocolListener(listener net.Listener, headerTimeout time.Duration) net.Listener {
+	return &proxyProtocolListener{
+		Listener:      listener,
+		headerTimeout: headerTimeout,
+	}
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	headerTimeout time.Duration
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProxyProtocolConn{
+		Conn:          conn,
+		reader:        bufio.NewReader(conn),
+		headerTimeout: l.headerTimeout,
+	}, nil
+}
+
+// ProxyProtocolConn is a connection accepted by a listener from
+// NewProxyProtocolListener.
+type ProxyProtocolConn struct {
+	net.Conn
+	reader        *bufio.Reader
+	headerTimeout time.Duration
+
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	tlvs       []ProxyProtocolTLV
+}
+
+func (c *ProxyProtocolConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the source address from the PROXY protocol header, or
+// the remote address of the connection when there is none.
+func (c *ProxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address from the PROXY protocol header,
+// or the local address of the connection when there is none.
+func (c *ProxyProtocolConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// TLVs returns the extensions of a version 2 PROXY protocol header. They are
+// passed on as they were received; a CRC32C checksum is not verified.
+func (c *ProxyProtocolConn) TLVs() []ProxyProtocolTLV {
+	c.once.Do(c.readHeader)
+	return c.tlvs
+}
+
+func (c *ProxyProtocolConn) readHeader() {
+	if c.headerTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.headerTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+	}
+
+	c.err = c.parseHeader()
+	if c.err != nil {
+		c.Conn.Close()
+	}
+}
+
+func (c *ProxyProtocolConn) parseHeader() error {
+	first, err := c.reader.Peek(1)
+	if err != nil {
+		return headerlessErr(err)
+	}
+
+	switch first[0] {
+	case proxyProtocolV1Prefix[0]:
+		if !c.hasPrefix(proxyProtocolV1Prefix) {
+			return nil
+		}
+		return c.parseV1()
+	case proxyProtocolV2Signature[0]:
+		if !c.hasPrefix(proxyProtocolV2Signature) {
+			return nil
+		}
+		return c.parseV2()
+	default:
+		return nil
+	}
+}
+
+// hasPrefix reports whether the connection starts with prefix, reading no
+// more than it takes to tell, so that clients that wait for the router after
+// sending a few bytes are not stuck.
+func (c *ProxyProtocolConn) hasPrefix(prefix []byte) bool {
+	for i := 1; i <= len(prefix); i++ {
+		buf, err := c.reader.Peek(i)
+		if err != nil || buf[i-1] != prefix[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *ProxyProtocolConn) parseV1() error {
+	var line []byte
+	for {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("invalid PROXY protocol header: %s", err)
+		}
+		line = append(line, b)
+		if len(line) > proxyProtocolV1MaxLength {
+			return errors.New("invalid PROXY protocol header: version 1 header is too long")
+		}
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil
+	}
+	if len(fields) != 6 {
+		return fmt.Errorf("invalid PROXY protocol header: %q", line)
+	}
+
+	switch fields[1] {
+	case "TCP4", "TCP6":
+	default:
+		return fmt.Errorf("invalid PROXY protocol header: unknown protocol %q", fields[1])
+	}
+
+	remoteAddr, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+	localAddr, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+
+	c.remoteAddr = remoteAddr
+	c.localAddr = localAddr
+	return nil
+}
+
+func parseV1Addr(ip, port string) (*net.TCPAddr, error) {
+	addr := &net.TCPAddr{IP: net.ParseIP(ip)}
+	if addr.IP == nil {
+		return nil, fmt.Errorf("invalid PROXY protocol header: invalid address %q", ip)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol header: invalid port %q", port)
+	}
+	addr.Port = int(p)
+	return addr, nil
+}
+
+func (c *ProxyProtocolConn) parseV2() error {
+	header := make([]byte, len(proxyProtocolV2Signature)+4)
+	_, err := io.ReadFull(c.reader, header)
+	if err != nil {
+		return fmt.Errorf("invalid PROXY protocol header: %s", err)
+	}
+
+	versionCommand := header[12]
+	if versionCommand>>4 != 2 {
+		return fmt.Errorf("invalid PROXY protocol header: unknown version %d", versionCommand>>4)
+	}
+	command := versionCommand & 0x0f
+	family := header[13] >> 4
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	_, err = io.ReadFull(c.reader, payload)
+	if err != nil {
+		return fmt.Errorf("invalid PROXY protocol header: %s", err)
+	}
+
+	switch command {
+	case proxyProtocolV2CommandLocal:
+		// health checks of the load balancer itself, which carry no
+		// addresses worth reporting
+		return nil
+	case proxyProtocolV2CommandProxy:
+	default:
+		return fmt.Errorf("invalid PROXY protocol header: unknown command %d", command)
+	}
+
+	var addrLength int
+	switch family {
+	case proxyProtocolV2FamilyInet:
+		addrLength = net.IPv4len
+	case proxyProtocolV2FamilyInet6:
+		addrLength = net.IPv6len
+	default:
+		// the addresses of other families are not meaningful to the router
+		return nil
+	}
+
+	if len(payload) < 2*addrLength+4 {
+		return errors.New("invalid PROXY protocol header: addresses are truncated")
+	}
+	c.remoteAddr = &net.TCPAddr{
+		IP:   net.IP(payload[:addrLength]),
+		Port: int(binary.BigEndian.Uint16(payload[2*addrLength:])),
+	}
+	c.localAddr = &net.TCPAddr{
+		IP:   net.IP(payload[addrLength : 2*addrLength]),
+		Port: int(binary.BigEndian.Uint16(payload[2*addrLength+2:])),
+	}
+
+	c.tlvs, err = parseTLVs(payload[2*addrLength+4:])
+	return err
+}
+
+func parseTLVs(b []byte) ([]ProxyProtocolTLV, error) {
+	var tlvs []ProxyProtocolTLV
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, errors.New("invalid PROXY protocol header: TLV is truncated")
+		}
+		length := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+length {
+			return nil, errors.New("invalid PROXY protocol header: TLV is truncated")
+		}
+		tlvs = append(tlvs, ProxyProtocolTLV{Type: b[0], Value: b[3 : 3+length]})
+		b = b[3+length:]
+	}
+	return tlvs, nil
+}
+
+// headerlessErr lets connections that are closed, or that are quiet for the
+// header timeout, before sending anything be handled like connections
+// without a header.
+func headerlessErr(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return nil
+	}
+	return err
+}
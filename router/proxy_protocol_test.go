@@ -0,0 +1,156 @@
+package router_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/router"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProxyProtocolListener", func() {
+	var (
+		listener net.Listener
+		client   net.Conn
+		conn     *router.ProxyProtocolConn
+	)
+
+	v2Header := func(command, family byte, addrs []byte, tlvs ...router.ProxyProtocolTLV) []byte {
+		payload := append([]byte{}, addrs...)
+		for _, tlv := range tlvs {
+			payload = append(payload, tlv.Type, 0, 0)
+			binary.BigEndian.PutUint16(payload[len(payload)-2:], uint16(len(tlv.Value)))
+			payload = append(payload, tlv.Value...)
+		}
+
+		header := []byte("\r\n\r\n\x00\r\nQUIT\n")
+		header = append(header, 0x20|command, family<<4|0x1, 0, 0)
+		binary.BigEndian.PutUint16(header[14:], uint16(len(payload)))
+		return append(header, payload...)
+	}
+
+	v2Addrs := func(src, dst net.IP, srcPort, dstPort uint16) []byte {
+		addrs := append(append([]byte{}, src...), dst...)
+		addrs = append(addrs, 0, 0, 0, 0)
+		binary.BigEndian.PutUint16(addrs[len(addrs)-4:], srcPort)
+		binary.BigEndian.PutUint16(addrs[len(addrs)-2:], dstPort)
+		return addrs
+	}
+
+	BeforeEach(func() {
+		tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		listener = router.NewProxyProtocolListener(tcpListener, 100*time.Millisecond)
+
+		client, err = net.Dial("tcp", listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+
+		accepted, err := listener.Accept()
+		Expect(err).ToNot(HaveOccurred())
+		conn = accepted.(*router.ProxyProtocolConn)
+	})
+
+	AfterEach(func() {
+		client.Close()
+		conn.Close()
+		listener.Close()
+	})
+
+	readLine := func() string {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+		return line
+	}
+
+	It("reports the addresses of a version 1 header", func() {
+		_, err := client.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.2 12345 80\r\nGET / HTTP/1.0\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conn.RemoteAddr().String()).To(Equal("192.168.0.1:12345"))
+		Expect(conn.LocalAddr().String()).To(Equal("192.168.0.2:80"))
+		Expect(readLine()).To(Equal("GET / HTTP/1.0\r\n"))
+	})
+
+	It("reports the addresses of a version 2 header over IPv4", func() {
+		header := v2Header(0x1, 0x1, v2Addrs(net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4(), 54321, 443))
+		_, err := client.Write(append(header, []byte("GET / HTTP/1.1\r\n")...))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conn.RemoteAddr().String()).To(Equal("10.0.0.1:54321"))
+		Expect(conn.LocalAddr().String()).To(Equal("10.0.0.2:443"))
+		Expect(conn.TLVs()).To(BeEmpty())
+		Expect(readLine()).To(Equal("GET / HTTP/1.1\r\n"))
+	})
+
+	It("reports the addresses of a version 2 header over IPv6", func() {
+		header := v2Header(0x1, 0x2, v2Addrs(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 54321, 443))
+		_, err := client.Write(header)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conn.RemoteAddr().String()).To(Equal("[2001:db8::1]:54321"))
+		Expect(conn.LocalAddr().String()).To(Equal("[2001:db8::2]:443"))
+	})
+
+	It("reports the TLVs of a version 2 header", func() {
+		header := v2Header(0x1, 0x1, v2Addrs(net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4(), 54321, 443),
+			router.ProxyProtocolTLV{Type: router.ProxyProtocolTLVAuthority, Value: []byte("example.com")},
+			router.ProxyProtocolTLV{Type: 0xEA, Value: []byte("vpce-0123")},
+		)
+		_, err := client.Write(header)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conn.RemoteAddr().String()).To(Equal("10.0.0.1:54321"))
+		Expect(conn.TLVs()).To(Equal([]router.ProxyProtocolTLV{
+			{Type: router.ProxyProtocolTLVAuthority, Value: []byte("example.com")},
+			{Type: 0xEA, Value: []byte("vpce-0123")},
+		}))
+	})
+
+	It("reports the addresses of the connection for a version 2 LOCAL header", func() {
+		header := v2Header(0x0, 0x0, nil)
+		_, err := client.Write(append(header, []byte("GET / HTTP/1.1\r\n")...))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conn.RemoteAddr().String()).To(Equal(client.LocalAddr().String()))
+		Expect(readLine()).To(Equal("GET / HTTP/1.1\r\n"))
+	})
+
+	It("passes connections without a header through", func() {
+		_, err := client.Write([]byte("GET / HTTP/1.1\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conn.RemoteAddr().String()).To(Equal(client.LocalAddr().String()))
+		Expect(readLine()).To(Equal("GET / HTTP/1.1\r\n"))
+	})
+
+	It("passes connections through that send nothing before the header timeout", func() {
+		Expect(conn.RemoteAddr().String()).To(Equal(client.LocalAddr().String()))
+
+		_, err := client.Write([]byte("GET / HTTP/1.1\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readLine()).To(Equal("GET / HTTP/1.1\r\n"))
+	})
+
+	It("fails to read a connection with an invalid version 1 header", func() {
+		_, err := client.Write([]byte("PROXY TCP4 not-an-ip 192.168.0.2 12345 80\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = conn.Read(make([]byte, 1))
+		Expect(err).To(MatchError(ContainSubstring("invalid PROXY protocol header")))
+	})
+
+	It("fails to read a connection with a truncated version 2 TLV", func() {
+		header := v2Header(0x1, 0x1, v2Addrs(net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4(), 54321, 443))
+		header = append(header, router.ProxyProtocolTLVAuthority, 0, 10, 'a')
+		binary.BigEndian.PutUint16(header[14:], binary.BigEndian.Uint16(header[14:])+4)
+		_, err := client.Write(header)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = conn.Read(make([]byte, 1))
+		Expect(err).To(MatchError("invalid PROXY protocol header: TLV is truncated"))
+	})
+})
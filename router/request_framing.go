@@ -0,0 +1,289 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// requestFramingRejection replaces a request head whose framing is
+// ambiguous. It is not a valid request line, so the server answers it with a
+// 400 Bad Request and closes the connection.
+var requestFramingRejection = []byte("REJECTED-AMBIGUOUS-REQUEST-FRAMING\r\n\r\n")
+
+// The part of the connection that a requestFramingConn reads next.
+const (
+	framingHead = iota
+	framingChunkLine
+	framingTrailer
+	framingPassThrough
+)
+
+// NewRequestFramingListener wraps listener so that requests with ambiguous
+// framing are answered with a 400 Bad Request by the server, instead of being
+// normalized by it. These are requests with more than one Content-Length
+// header, an invalid Content-Length, or both Content-Length and
+// Transfer-Encoding. The Go server cannot reject them itself, as it merges or
+// drops these headers before the handlers see the request.
+//
+// maxHeaderBytes is the MaxHeaderBytes of the server, or zero for the
+// default.
+func NewRequestFramingListener(listener net.Listener, maxHeaderBytes int) net.Listener {
+	if maxHeaderBytes <= 0 {
+		maxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+	return &requestFramingListener{
+		Listener: listener,
+		// The server reads at most 4 KB more than MaxHeaderBytes for a
+		// request head, and may already have buffered up to 4 KB of it.
+		maxHead: maxHeaderBytes + 8192,
+	}
+}
+
+type requestFramingListener struct {
+	net.Listener
+	maxHead int
+}
+
+func (l *requestFramingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &requestFramingConn{
+		Conn:    conn,
+		r:       bufio.NewReader(conn),
+		maxHead: l.maxHead,
+	}, nil
+}
+
+// requestFramingConn peeks at the head of each request before the server
+// reads it, and passes on what it reads unchanged, except for heads with
+// ambiguous framing. It skips bodies by their Content-Length or chunks to find
+// the next head. Anything it does not understand is left to the server, and
+// the rest of the connection is passed through.
+type requestFramingConn struct {
+	net.Conn
+	r       *bufio.Reader
+	maxHead int
+
+	state int
+	// ready is the number of bytes that may be read before the next part
+	// of the connection is peeked at.
+	ready int64
+	// rejection is what is left to read of a rejected request head, after
+	// which the connection reads EOF.
+	rejection []byte
+	rejected  bool
+}
+
+func (c *requestFramingConn) Read(b []byte) (int, error) {
+	for c.ready == 0 && c.state != framingPassThrough && !c.rejected {
+		if err := c.peek(); err != nil {
+			if err != io.EOF || c.r.Buffered() == 0 {
+				return 0, err
+			}
+			// Let the server see what it would have seen without us
+			c.state = framingPassThrough
+		}
+		if c.rejected {
+			c.rejection = requestFramingRejection
+		}
+	}
+	if c.rejected {
+		if len(c.rejection) == 0 {
+			return 0, io.EOF
+		}
+		n := copy(b, c.rejection)
+		c.rejection = c.rejection[n:]
+		return n, nil
+	}
+
+	if c.state != framingPassThrough && int64(len(b)) > c.ready {
+		b = b[:c.ready]
+	}
+	n, err := c.r.Read(b)
+	if c.state != framingPassThrough {
+		c.ready -= int64(n)
+	}
+	return n, err
+}
+
+// peek decides how much of the connection may be read next.
+func (c *requestFramingConn) peek() error {
+	switch c.state {
+	case framingHead:
+		return c.peekHead()
+	case framingChunkLine, framingTrailer:
+		line, err := c.peekLine(0)
+		if err != nil || c.state == framingPassThrough {
+			return err
+		}
+		c.ready = int64(len(line))
+		if c.state == framingTrailer {
+			if len(trimLineEnd(line)) == 0 {
+				c.state = framingHead
+			}
+			return nil
+		}
+		size, _, _ := bytes.Cut(trimLineEnd(line), []byte(";"))
+		n, err := strconv.ParseUint(string(bytes.TrimRight(size, " \t")), 16, 62)
+		if err != nil {
+			c.state = framingPassThrough
+		} else if n == 0 {
+			c.state = framingTrailer
+		} else {
+			// the data of the chunk and its CRLF
+			c.ready += int64(n) + 2
+		}
+		return nil
+	}
+	return nil
+}
+
+// peekHead peeks at the next request head, and rejects it when its framing
+// is ambiguous.
+func (c *requestFramingConn) peekHead() error {
+	// The server skips the empty lines between requests that some clients
+	// send.
+	skip := 0
+	for {
+		if skip == c.r.Size() {
+			c.state = framingPassThrough
+			return nil
+		}
+		b, err := c.r.Peek(skip + 1)
+		if err != nil {
+			return err
+		}
+		if b[skip] != '\r' && b[skip] != '\n' {
+			if !isTokenByte(b[skip]) {
+				// not a request, such as the data of an upgraded
+				// connection
+				c.state = framingPassThrough
+				return nil
+			}
+			break
+		}
+		skip++
+	}
+
+	var lines [][]byte
+	offset := skip
+	for {
+		line, err := c.peekLine(offset)
+		if err != nil || c.state == framingPassThrough {
+			return err
+		}
+		offset += len(line)
+		line = trimLineEnd(line)
+		if len(line) == 0 {
+			break
+		}
+		lines = append(lines, line)
+	}
+	c.ready = int64(offset)
+	c.check(lines)
+	return nil
+}
+
+// check sets what follows a request head, or rejects it.
+func (c *requestFramingConn) check(lines [][]byte) {
+	fields := strings.Fields(string(lines[0]))
+	if len(fields) != 3 {
+		c.state = framingPassThrough
+		return
+	}
+	major, minor, ok := http.ParseHTTPVersion(fields[2])
+	if !ok || major != 1 {
+		c.state = framingPassThrough
+		return
+	}
+
+	var contentLengths, transferEncodings []string
+	for _, line := range lines[1:] {
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		key, value, _ := bytes.Cut(line, []byte(":"))
+		switch textproto.CanonicalMIMEHeaderKey(string(key)) {
+		case "Content-Length":
+			contentLengths = append(contentLengths, textproto.TrimString(string(value)))
+		case "Transfer-Encoding":
+			transferEncodings = append(transferEncodings, textproto.TrimString(string(value)))
+		}
+	}
+
+	if len(contentLengths) > 1 || len(contentLengths) > 0 && len(transferEncodings) > 0 {
+		c.rejected = true
+		return
+	}
+	var length uint64
+	if len(contentLengths) > 0 {
+		var err error
+		length, err = strconv.ParseUint(contentLengths[0], 10, 62)
+		if err != nil {
+			c.rejected = true
+			return
+		}
+	}
+
+	// The server ignores Transfer-Encoding in HTTP/1.0
+	if len(transferEncodings) > 0 && minor > 0 {
+		if len(transferEncodings) != 1 || !strings.EqualFold(transferEncodings[0], "chunked") {
+			// The server answers with a 501 Not Implemented
+			c.state = framingPassThrough
+			return
+		}
+		c.state = framingChunkLine
+		return
+	}
+	c.ready += int64(length)
+	c.state = framingHead
+}
+
+// peekLine peeks at the line that starts offset bytes ahead, including its
+// line ending. Lines that do not fit in the head the server reads leave the
+// rest of the connection to the server.
+func (c *requestFramingConn) peekLine(offset int) ([]byte, error) {
+	scanned := offset
+	for {
+		n := c.r.Buffered()
+		if n <= scanned {
+			n = scanned + 1
+		}
+		if n > c.maxHead {
+			c.state = framingPassThrough
+			return nil, nil
+		}
+		if n > c.r.Size() {
+			// grow the buffer for long heads
+			c.r = bufio.NewReaderSize(c.r, 2*c.r.Size())
+		}
+
+		b, err := c.r.Peek(n)
+		if err != nil {
+			return nil, err
+		}
+		if i := bytes.IndexByte(b[scanned:], '\n'); i >= 0 {
+			return b[offset : scanned+i+1], nil
+		}
+		scanned = n
+	}
+}
+
+func trimLineEnd(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
+
+func isTokenByte(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", b) >= 0
+}
@@ -0,0 +1,147 @@
+package router_test
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/gorouter/router"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequestFramingListener", func() {
+	var (
+		listener net.Listener
+		server   *http.Server
+	)
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Upgrade") != "" {
+			conn, buf, err := rw.(http.Hijacker).Hijack()
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+			io.Copy(conn, buf)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		rw.Write(body)
+	})
+
+	BeforeEach(func() {
+		server = &http.Server{Handler: handler}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	serve := func() {
+		go server.Serve(listener)
+	}
+
+	type response struct {
+		status int
+		body   string
+	}
+
+	send := func(requests string, responses int) []response {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		_, err = conn.Write([]byte(requests))
+		Expect(err).ToNot(HaveOccurred())
+
+		var res []response
+		r := bufio.NewReader(conn)
+		for i := 0; i < responses; i++ {
+			resp, err := http.ReadResponse(r, nil)
+			Expect(err).ToNot(HaveOccurred())
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			res = append(res, response{status: resp.StatusCode, body: string(body)})
+		}
+		return res
+	}
+
+	Context("over TCP", func() {
+		BeforeEach(func() {
+			tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			listener = router.NewRequestFramingListener(tcpListener, 0)
+			serve()
+		})
+
+		DescribeTable("rejects requests with ambiguous framing",
+			func(request string) {
+				Expect(send(request, 1)[0].status).To(Equal(http.StatusBadRequest))
+			},
+			Entry("Content-Length and Transfer-Encoding",
+				"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n"),
+			Entry("Transfer-Encoding and Content-Length",
+				"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\ncontent-length: 4\r\n\r\n0\r\n\r\n"),
+			Entry("duplicate Content-Length",
+				"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nContent-Length: 4\r\n\r\nbody"),
+			Entry("conflicting Content-Length",
+				"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nContent-Length: 5\r\n\r\nbody!"),
+			Entry("invalid Content-Length",
+				"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: +4\r\n\r\nbody"),
+			Entry("Content-Length and Transfer-Encoding in HTTP/1.0",
+				"POST / HTTP/1.0\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\nbody"),
+		)
+
+		It("serves requests with a Content-Length or a chunked body", func() {
+			res := send("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\n\r\nbody"+
+				"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n4;ext\r\nbody\r\n3\r\n!!!\r\n0\r\nTrailer: x\r\n\r\n"+
+				"GET / HTTP/1.1\r\nHost: a\r\n\r\n", 3)
+			Expect(res).To(Equal([]response{
+				{status: http.StatusOK, body: "body"},
+				{status: http.StatusOK, body: "body!!!"},
+				{status: http.StatusOK, body: ""},
+			}))
+		})
+
+		It("serves requests with heads and bodies larger than its buffer", func() {
+			body := strings.Repeat("b", 10000)
+			res := send("POST / HTTP/1.1\r\nHost: a\r\nX-Long: "+strings.Repeat("h", 10000)+"\r\nContent-Length: 10000\r\n\r\n"+body+
+				"GET / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n", 2)
+			Expect(res[0]).To(Equal(response{status: http.StatusOK, body: body}))
+			Expect(res[1].status).To(Equal(http.StatusBadRequest))
+		})
+
+		It("rejects a request with ambiguous framing in a body", func() {
+			res := send("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 65\r\n\r\n"+
+				"GET / HTTP/1.1\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n"+
+				"GET / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n", 2)
+			Expect(res[0].status).To(Equal(http.StatusOK))
+			Expect(res[0].body).To(HavePrefix("GET / HTTP/1.1"))
+			Expect(res[1].status).To(Equal(http.StatusBadRequest))
+		})
+
+		It("passes upgraded connections through", func() {
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			stream := "\x81\x05hello\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n"
+			_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: a\r\nUpgrade: websocket\r\n\r\n" + stream))
+			Expect(err).ToNot(HaveOccurred())
+
+			r := bufio.NewReader(conn)
+			resp, err := http.ReadResponse(r, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+
+			echo := make([]byte, len(stream))
+			_, err = io.ReadFull(r, echo)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(echo)).To(Equal(stream))
+		})
+	})
+})
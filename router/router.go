@@ -26,9 +26,10 @@ import (
 	"code.cloudfoundry.org/gorouter/metrics/monitor"
 	"code.cloudfoundry.org/gorouter/registry"
 	"code.cloudfoundry.org/gorouter/varz"
-	"github.com/armon/go-proxyproto"
 	"github.com/nats-io/go-nats"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/uber-go/zap"
+	"golang.org/x/net/http2"
 )
 
 var DrainTimeout = errors.New("router: Drain timeout")
@@ -61,6 +62,12 @@ type Router struct {
 	idleConns           map[net.Conn]struct{}
 	activeConns         map[net.Conn]struct{}
 	drainDone           chan struct{}
+	drainRequested      chan struct{}
+	drainOnce           sync.Once
+	drainState          string
+	drainStartedAt      time.Time
+	lameDuck            bool
+	started             bool
 	serveDone           chan struct{}
 	tlsServeDone        chan struct{}
 	stopping            bool
@@ -70,10 +77,14 @@ type Router struct {
 	logger              logger.Logger
 	errChan             chan error
 	routeServicesServer rss
+	http3Server         *http3.Server
+	certificates        *serverCertificates
+	cipherSuites        atomic.Value // []uint16 that replace those of the config
 }
 
 func NewRouter(logger logger.Logger, cfg *config.Config, handler http.Handler, mbusClient *nats.Conn, r *registry.RouteRegistry,
-	v varz.Varz, heartbeatOK *int32, logCounter *schema.LogCounter, errChan chan error, routeServicesServer rss) (*Router, error) {
+	v varz.Varz, heartbeatOK *int32, logCounter *schema.LogCounter, errChan chan error, routeServicesServer rss,
+	statusHandlers map[string]http.Handler) (*Router, error) {
 
 	var host string
 	if cfg.Status.Port != 0 {
@@ -92,16 +103,16 @@ func NewRouter(logger logger.Logger, cfg *config.Config, handler http.Handler, m
 	}
 
 	healthz := &health.Healthz{}
+	if cfg.EmitNatsHealth && mbusClient != nil {
+		healthz.NATSConnected = func() bool { return mbusClient.Status() == nats.CONNECTED }
+	}
 	health := handlers.NewHealthcheck(heartbeatOK, logger)
 	component := &common.VcapComponent{
 		Config:  cfg,
 		Varz:    varz,
 		Healthz: healthz,
 		Health:  health,
-		InfoRoutes: map[string]json.Marshaler{
-			"/routes": r,
-		},
-		Logger: logger,
+		Logger:  logger,
 	}
 
 	routerErrChan := errChan
@@ -117,6 +128,7 @@ func NewRouter(logger logger.Logger, cfg *config.Config, handler http.Handler, m
 		varz:                v,
 		component:           component,
 		serveDone:           make(chan struct{}),
+		drainRequested:      make(chan struct{}),
 		tlsServeDone:        make(chan struct{}),
 		idleConns:           make(map[net.Conn]struct{}),
 		activeConns:         make(map[net.Conn]struct{}),
@@ -125,7 +137,22 @@ func NewRouter(logger logger.Logger, cfg *config.Config, handler http.Handler, m
 		HeartbeatOK:         heartbeatOK,
 		stopping:            false,
 		routeServicesServer: routeServicesServer,
+		certificates:        newServerCertificates(cfg.SSLCertificates),
+	}
+
+	withRouter := map[string]http.Handler{}
+	for path, handler := range statusHandlers {
+		withRouter[path] = handler
+	}
+	withRouter["/routes"] = registry.RoutesHandler(r)
+	withRouter["/routes/lookup"] = registry.LookupHandler(r, cfg.LoadBalance)
+	if cfg.Status.EnableDrain {
+		withRouter["/drain"] = router.DrainHandler()
 	}
+	if cfg.Status.EnableLameDuck {
+		withRouter["/lame_duck"] = router.LameDuckHandler()
+	}
+	component.Handlers = withRouter
 
 	if err := router.component.Start(); err != nil {
 		return nil, err
@@ -146,10 +173,28 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	r.logger.Debug("Sleeping before returning success on /health endpoint to preload routing table", zap.Float64("sleep_time_seconds", r.config.StartResponseDelayInterval.Seconds()))
 	time.Sleep(r.config.StartResponseDelayInterval)
 
+	handler := r.handler
+	if r.config.EnableSSL && r.config.EnableHTTP3 {
+		handler = advertiseHTTP3(handler, r.config.HTTP3Port, r.config.HTTP3AltSvcMaxAge)
+	}
+
 	server := &http.Server{
-		Handler:     r.handler,
-		ConnState:   r.HandleConnState,
-		IdleTimeout: r.config.FrontendIdleTimeout,
+		Handler:        handler,
+		ConnState:      r.HandleConnState,
+		IdleTimeout:    r.config.FrontendIdleTimeout,
+		MaxHeaderBytes: r.config.MaxHeaderBytes,
+	}
+
+	// HTTP/2 must be configured before either listener starts serving, since
+	// serving sets up the server's default protocols
+	if r.config.EnableSSL && r.config.EnableHTTP2 {
+		err := http2.ConfigureServer(server, &http2.Server{
+			MaxConcurrentStreams: r.config.HTTP2MaxConcurrentStreams,
+		})
+		if err != nil {
+			r.errChan <- err
+			return err
+		}
 	}
 
 	err := r.serveHTTP(server, r.errChan)
@@ -162,13 +207,18 @@ func (r *Router) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 		r.errChan <- err
 		return err
 	}
+	err = r.serveHTTP3(r.errChan)
+	if err != nil {
+		r.errChan <- err
+		return err
+	}
 	err = r.routeServicesServer.Serve(r.handler, r.errChan)
 	if err != nil {
 		r.errChan <- err
 		return err
 	}
 
-	atomic.StoreInt32(r.HeartbeatOK, 1)
+	r.markStarted()
 
 	r.logger.Info("gorouter.started")
 	go r.uptimeMonitor.Start()
@@ -187,15 +237,12 @@ func (r *Router) OnErrOrSignal(signals <-chan os.Signal, errChan chan error) {
 			r.logger.Error("Error occurred", zap.Error(err))
 			r.DrainAndStop()
 		}
+	case <-r.drainRequested:
+		go r.ignoreSignals(signals)
+		r.DrainAndStop()
+		r.logger.Info("gorouter.exited")
 	case sig := <-signals:
-		go func() {
-			for sig := range signals {
-				r.logger.Info(
-					"gorouter.signal.ignored",
-					zap.String("signal", sig.String()),
-				)
-			}
-		}()
+		go r.ignoreSignals(signals)
 		if sig == syscall.SIGUSR1 {
 			r.DrainAndStop()
 		} else {
@@ -205,6 +252,15 @@ func (r *Router) OnErrOrSignal(signals <-chan os.Signal, errChan chan error) {
 	}
 }
 
+func (r *Router) ignoreSignals(signals <-chan os.Signal) {
+	for sig := range signals {
+		r.logger.Info(
+			"gorouter.signal.ignored",
+			zap.String("signal", sig.String()),
+		)
+	}
+}
+
 func (r *Router) DrainAndStop() {
 	drainWait := r.config.DrainWait
 	drainTimeout := r.config.DrainTimeout
@@ -225,6 +281,42 @@ func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 		return nil
 	}
 
+	tlsConfig := r.tlsConfig()
+	if r.config.EnableHTTP2 {
+		// WebSocket and TCP upgrades are not possible over HTTP/2, so clients
+		// that upgrade negotiate HTTP/1.1 instead
+		tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	}
+
+	listener, err := NewListener(fmt.Sprintf(":%d", r.config.SSLPort), r.config.ListenerBacklog, r.config.EnableReusePort)
+	if err != nil {
+		r.logger.Fatal("tls-listener-error", zap.Error(err))
+		return err
+	}
+
+	if r.config.EnablePROXY {
+		listener = NewProxyProtocolListener(listener, proxyProtocolHeaderTimeout)
+	}
+
+	r.tlsListener = tls.NewListener(listener, tlsConfig)
+
+	r.logger.Info("tls-listener-started", zap.Object("address", r.tlsListener.Addr()))
+
+	go func() {
+		err := server.Serve(r.tlsListener)
+		r.stopLock.Lock()
+		if !r.stopping {
+			errChan <- err
+		}
+		r.stopLock.Unlock()
+		close(r.tlsServeDone)
+	}()
+	return nil
+}
+
+// tlsConfig returns the TLS configuration shared by the TLS and HTTP/3
+// listeners.
+func (r *Router) tlsConfig() *tls.Config {
 	rootCAs, err := x509.SystemCertPool()
 	if err != nil {
 		rootCAs = nil
@@ -239,51 +331,89 @@ func (r *Router) serveHTTPS(server *http.Server, errChan chan error) error {
 	}
 
 	tlsConfig := &tls.Config{
-		Certificates: r.config.SSLCertificates,
-		CipherSuites: r.config.CipherSuites,
-		MinVersion:   r.config.MinTLSVersion,
-		ClientCAs:    rootCAs,
-		ClientAuth:   r.config.ClientCertificateValidation,
+		GetCertificate: r.certificates.getCertificate,
+		CipherSuites:   r.config.CipherSuites,
+		MinVersion:     r.config.MinTLSVersion,
+		MaxVersion:     r.config.MaxTLSVersion,
+		ClientCAs:      rootCAs,
+		ClientAuth:     r.config.ClientCertificateValidation,
 	}
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cipherSuites, ok := r.cipherSuites.Load().([]uint16)
+		if !ok {
+			return nil, nil
+		}
+		c := tlsConfig.Clone()
+		c.CipherSuites = cipherSuites
+		c.GetConfigForClient = nil
+		return c, nil
+	}
+	return tlsConfig
+}
+
+// UpdateCipherSuites replaces the cipher suites that the TLS and HTTP/3
+// listeners offer. Connections that are already established keep theirs.
+func (r *Router) UpdateCipherSuites(cipherSuites []uint16) {
+	r.cipherSuites.Store(cipherSuites)
+}
 
-	tlsConfig.BuildNameToCertificate()
+// UpdateCertificates replaces the certificates of the TLS and HTTP/3
+// listeners. Connections that are already established keep theirs.
+func (r *Router) UpdateCertificates(certificates []tls.Certificate) {
+	r.certificates.update(certificates)
+}
+
+// serveHTTP3 serves HTTP/3 over QUIC on the HTTP/3 port, with the
+// certificates of the TLS listener.
+func (r *Router) serveHTTP3(errChan chan error) error {
+	if !r.config.EnableSSL || !r.config.EnableHTTP3 {
+		return nil
+	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", r.config.SSLPort))
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", r.config.HTTP3Port))
 	if err != nil {
-		r.logger.Fatal("tls-listener-error", zap.Error(err))
+		r.logger.Fatal("http3-listener-error", zap.Error(err))
 		return err
 	}
 
-	if r.config.EnablePROXY {
-		listener = &proxyproto.Listener{
-			Listener:           listener,
-			ProxyHeaderTimeout: proxyProtocolHeaderTimeout,
-		}
+	r.http3Server = &http3.Server{
+		Handler:     r.handler,
+		TLSConfig:   http3.ConfigureTLSConfig(r.tlsConfig()),
+		IdleTimeout: r.config.FrontendIdleTimeout,
 	}
 
-	r.tlsListener = tls.NewListener(listener, tlsConfig)
-
-	r.logger.Info("tls-listener-started", zap.Object("address", r.tlsListener.Addr()))
+	r.logger.Info("http3-listener-started", zap.Object("address", conn.LocalAddr()))
 
 	go func() {
-		err := server.Serve(r.tlsListener)
+		err := r.http3Server.Serve(conn)
 		r.stopLock.Lock()
 		if !r.stopping {
 			errChan <- err
 		}
 		r.stopLock.Unlock()
-		close(r.tlsServeDone)
 	}()
 	return nil
 }
 
+// advertiseHTTP3 tells clients of the TCP listeners that they can switch to
+// HTTP/3, once they reached the router over TLS.
+func advertiseHTTP3(handler http.Handler, port uint16, maxAge time.Duration) http.Handler {
+	altSvc := fmt.Sprintf(`h3=":%d"; ma=%d`, port, int64(maxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.TLS != nil && req.ProtoMajor < 3 {
+			w.Header().Set("Alt-Svc", altSvc)
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
 func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 	if r.config.DisableHTTP {
 		r.logger.Info("tcp-listener-disabled")
 		return nil
 	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", r.config.Port))
+	listener, err := NewListener(fmt.Sprintf(":%d", r.config.Port), r.config.ListenerBacklog, r.config.EnableReusePort)
 	if err != nil {
 		r.logger.Fatal("tcp-listener-error", zap.Error(err))
 		return err
@@ -291,10 +421,10 @@ func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 
 	r.listener = listener
 	if r.config.EnablePROXY {
-		r.listener = &proxyproto.Listener{
-			Listener:           listener,
-			ProxyHeaderTimeout: proxyProtocolHeaderTimeout,
-		}
+		r.listener = NewProxyProtocolListener(listener, proxyProtocolHeaderTimeout)
+	}
+	if r.config.StrictRequestFraming {
+		r.listener = NewRequestFramingListener(r.listener, r.config.MaxHeaderBytes)
 	}
 
 	r.logger.Info("tcp-listener-started", zap.Object("address", r.listener.Addr()))
@@ -313,11 +443,13 @@ func (r *Router) serveHTTP(server *http.Server, errChan chan error) error {
 }
 
 func (r *Router) Drain(drainWait, drainTimeout time.Duration) error {
+	r.setDrainState(drainStateWaiting)
 	atomic.StoreInt32(r.HeartbeatOK, 0)
 
 	<-time.After(drainWait)
 
 	r.stopListening()
+	r.setDrainState(drainStateDraining)
 
 	drained := make(chan struct{})
 
@@ -339,9 +471,11 @@ func (r *Router) Drain(drainWait, drainTimeout time.Duration) error {
 	case <-drained:
 	case <-time.After(drainTimeout):
 		r.logger.Info("router.drain.timed-out")
+		r.setDrainState(drainStateTimedOut)
 		return DrainTimeout
 	}
 
+	r.setDrainState(drainStateDrained)
 	return nil
 }
 
@@ -388,15 +522,22 @@ func (r *Router) stopListening() {
 		<-r.tlsServeDone
 	}
 
+	if r.http3Server != nil {
+		r.http3Server.Close()
+	}
+
 	r.routeServicesServer.Stop()
 }
 
 func (r *Router) RegisterComponent() {
+	if r.mbusClient == nil {
+		return
+	}
 	r.component.Register(r.mbusClient)
 }
 
 func (r *Router) ScheduleFlushApps() {
-	if r.config.PublishActiveAppsInterval == 0 {
+	if r.config.PublishActiveAppsInterval == 0 || r.mbusClient == nil {
 		return
 	}
 
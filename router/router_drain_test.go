@@ -2,6 +2,7 @@ package router_test
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -224,16 +225,16 @@ var _ = Describe("Router", func() {
 		rt := &sharedfakes.RoundTripper{}
 		skipSanitize := func(*http.Request) bool { return false }
 		p = proxy.NewProxy(logger, &accesslog.NullAccessLogger{}, config, registry, combinedReporter,
-			&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck, rt, skipSanitize)
+			&routeservice.RouteServiceConfig{}, &tls.Config{}, nil, &healthCheck, rt, skipSanitize, nil, nil)
 
 		errChan := make(chan error, 2)
 		var err error
 		rss := &sharedfakes.RouteServicesServer{}
-		rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, errChan, rss)
+		rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, errChan, rss, nil)
 		Expect(err).ToNot(HaveOccurred())
 
 		config.Index = 4321
-		subscriber = ifrit.Background(mbus.NewSubscriber(mbusClient, registry, config, nil, logger.Session("subscriber")))
+		subscriber = ifrit.Background(mbus.NewSubscriber(mbusClient, registry, config, nil, new(fakeMetrics.FakeSubscriberReporter), logger.Session("subscriber")))
 		<-subscriber.Ready()
 	})
 
@@ -471,12 +472,12 @@ var _ = Describe("Router", func() {
 				rt := &sharedfakes.RoundTripper{}
 				skipSanitize := func(*http.Request) bool { return false }
 				p := proxy.NewProxy(logger, &accesslog.NullAccessLogger{}, config, registry, combinedReporter,
-					&routeservice.RouteServiceConfig{}, &tls.Config{}, &healthCheck, rt, skipSanitize)
+					&routeservice.RouteServiceConfig{}, &tls.Config{}, nil, &healthCheck, rt, skipSanitize, nil, nil)
 
 				errChan = make(chan error, 2)
 				var err error
 				rss := &sharedfakes.RouteServicesServer{}
-				rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, errChan, rss)
+				rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, errChan, rss, nil)
 				Expect(err).ToNot(HaveOccurred())
 				runRouter(rtr)
 			})
@@ -504,6 +505,155 @@ var _ = Describe("Router", func() {
 			})
 		})
 
+		Context("when a drain is requested on the status listener", func() {
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+			requestDrain := func(method string) (int, map[string]interface{}) {
+				req, err := http.NewRequest(method, fmt.Sprintf("http://%s:%d/drain", config.Ip, config.Status.Port), nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.SetBasicAuth("user", "pass")
+
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				var progress map[string]interface{}
+				Expect(json.NewDecoder(resp.Body).Decode(&progress)).To(Succeed())
+				return resp.StatusCode, progress
+			}
+
+			BeforeEach(func() {
+				// the router without the drain endpoint still listens on
+				// the status port
+				config.Status.Port = test_util.NextAvailPort()
+				config.Status.EnableDrain = true
+
+				logcounter := schema.NewLogCounter()
+				rt := &sharedfakes.RoundTripper{}
+				skipSanitize := func(*http.Request) bool { return false }
+				p := proxy.NewProxy(logger, &accesslog.NullAccessLogger{}, config, registry, combinedReporter,
+					&routeservice.RouteServiceConfig{}, &tls.Config{}, nil, &healthCheck, rt, skipSanitize, nil, nil)
+
+				var err error
+				rss := &sharedfakes.RouteServicesServer{}
+				rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, make(chan error, 2), rss, nil)
+				Expect(err).ToNot(HaveOccurred())
+				runRouter(rtr)
+			})
+
+			It("it drains and stops the router", func() {
+				testRouterDrain(config, mbusClient, registry, func() {
+					defer GinkgoRecover()
+					status, _ := requestDrain("POST")
+					Expect(status).To(Equal(http.StatusAccepted))
+				})
+			})
+
+			It("reports the progress of the drain", func() {
+				config.DrainWait = 500 * time.Millisecond
+
+				status, progress := requestDrain("GET")
+				Expect(status).To(Equal(http.StatusOK))
+				Expect(progress).To(HaveKeyWithValue("state", "serving"))
+				Expect(progress).ToNot(HaveKey("started_at"))
+
+				status, _ = requestDrain("POST")
+				Expect(status).To(Equal(http.StatusAccepted))
+
+				Eventually(func() interface{} {
+					_, progress := requestDrain("GET")
+					return progress["state"]
+				}).Should(Equal("waiting"))
+				_, progress = requestDrain("GET")
+				Expect(progress).To(HaveKey("started_at"))
+				Expect(healthCheckWithEndpointReceives()).To(Equal(http.StatusServiceUnavailable))
+
+				Eventually(func() error {
+					_, err := client.Get(fmt.Sprintf("http://%s:%d/health", config.Ip, config.Status.Port))
+					return err
+				}, 2*time.Second).Should(HaveOccurred())
+			})
+		})
+
+		Context("when lame duck mode is toggled on the status listener", func() {
+			var (
+				signals      chan os.Signal
+				closeChannel chan struct{}
+			)
+
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+			setLameDuck := func(method string) int {
+				req, err := http.NewRequest(method, fmt.Sprintf("http://%s:%d/lame_duck", config.Ip, config.Status.Port), nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.SetBasicAuth("user", "pass")
+
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+
+			BeforeEach(func() {
+				config.Status.Port = test_util.NextAvailPort()
+				config.Status.EnableLameDuck = true
+
+				logcounter := schema.NewLogCounter()
+				rt := &sharedfakes.RoundTripper{}
+				skipSanitize := func(*http.Request) bool { return false }
+				p := proxy.NewProxy(logger, &accesslog.NullAccessLogger{}, config, registry, combinedReporter,
+					&routeservice.RouteServiceConfig{}, &tls.Config{}, nil, &healthCheck, rt, skipSanitize, nil, nil)
+
+				var err error
+				rss := &sharedfakes.RouteServicesServer{}
+				rtr, err = router.NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, make(chan error, 2), rss, nil)
+				Expect(err).ToNot(HaveOccurred())
+				signals, closeChannel = runRouter(rtr)
+			})
+
+			AfterEach(func() {
+				signals <- syscall.SIGTERM
+				Eventually(closeChannel, 5*time.Second).Should(BeClosed())
+			})
+
+			It("fails the health check while it keeps serving requests", func() {
+				app := common.NewTestApp([]route.Uri{"lameduck." + test_util.LocalhostDNS}, config.Port, mbusClient, nil, "")
+				app.AddHandler("/", func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNoContent)
+				})
+				app.RegisterAndListen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				Expect(setLameDuck("PUT")).To(Equal(http.StatusOK))
+				Expect(rtr.LameDuck()).To(BeTrue())
+				Expect(healthCheckWithEndpointReceives()).To(Equal(http.StatusServiceUnavailable))
+
+				req, err := http.NewRequest("GET", fmt.Sprintf("http://%s:%d/", config.Ip, config.Port), nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.Host = "lameduck." + test_util.LocalhostDNS
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				Expect(setLameDuck("DELETE")).To(Equal(http.StatusOK))
+				Expect(rtr.LameDuck()).To(BeFalse())
+				Expect(healthCheckWithEndpointReceives()).To(Equal(http.StatusOK))
+			})
+
+			It("cannot be taken out of lame duck mode while it drains", func() {
+				config.DrainWait = 500 * time.Millisecond
+
+				Expect(setLameDuck("PUT")).To(Equal(http.StatusOK))
+				signals <- syscall.SIGUSR1
+
+				Eventually(func() int { return setLameDuck("DELETE") }).Should(Equal(http.StatusConflict))
+				Expect(healthCheckWithEndpointReceives()).To(Equal(http.StatusServiceUnavailable))
+			})
+		})
+
 		Context("when a SIGTERM signal is sent", func() {
 			It("it drains and stops the router", func() {
 				signals, closeChannel := runRouter(rtr)
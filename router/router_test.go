@@ -17,6 +17,7 @@ import (
 	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/http/httputil"
 	"os"
 	"syscall"
@@ -41,9 +42,12 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
 	"github.com/tedsuo/ifrit/sigmon"
+	"golang.org/x/crypto/ocsp"
+	"golang.org/x/net/http2"
 
 	fakeMetrics "code.cloudfoundry.org/gorouter/metrics/fakes"
 
@@ -103,7 +107,7 @@ var _ = Describe("Router", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		config.Index = 4321
-		subscriber := mbus.NewSubscriber(mbusClient, registry, config, nil, logger.Session("subscriber"))
+		subscriber := mbus.NewSubscriber(mbusClient, registry, config, nil, new(fakeMetrics.FakeSubscriberReporter), logger.Session("subscriber"))
 
 		members := grouper.Members{
 			{Name: "subscriber", Runner: subscriber},
@@ -1321,6 +1325,163 @@ var _ = Describe("Router", func() {
 			resp.Body.Close()
 		})
 
+		Context("HTTP/2", func() {
+			BeforeEach(func() {
+				config.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+			})
+
+			It("is not negotiated by default", func() {
+				tlsClientConfig.NextProtos = []string{"h2", "http/1.1"}
+				tlsClientConfig.ServerName = "test." + test_util.LocalhostDNS
+
+				conn, err := tls.Dial("tcp", fmt.Sprintf("test.%s:%d", test_util.LocalhostDNS, config.SSLPort), tlsClientConfig)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				Expect(conn.ConnectionState().NegotiatedProtocol).To(BeEmpty())
+			})
+
+			Context("when HTTP/2 is enabled", func() {
+				BeforeEach(func() {
+					config.EnableHTTP2 = true
+					config.HTTP2MaxConcurrentStreams = 100
+				})
+
+				It("serves HTTP/2 to clients that negotiate it", func() {
+					app := test.NewGreetApp([]route.Uri{"test." + test_util.LocalhostDNS}, config.Port, mbusClient, nil)
+					app.RegisterAndListen()
+					Eventually(func() bool {
+						return appRegistered(registry, app)
+					}).Should(BeTrue())
+
+					client = &http.Client{Transport: &http2.Transport{
+						TLSClientConfig: tlsClientConfig,
+					}}
+
+					uri := fmt.Sprintf("https://test.%s:%d/", test_util.LocalhostDNS, config.SSLPort)
+					req, _ := http.NewRequest("GET", uri, nil)
+
+					resp, err := client.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					defer resp.Body.Close()
+
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+					Expect(resp.ProtoMajor).To(Equal(2))
+
+					bytes, err := ioutil.ReadAll(resp.Body)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(bytes).To(ContainSubstring("Hello"))
+				})
+
+				It("serves HTTP/1.1 to clients that do not negotiate HTTP/2", func() {
+					app := test.NewGreetApp([]route.Uri{"test." + test_util.LocalhostDNS}, config.Port, mbusClient, nil)
+					app.RegisterAndListen()
+					Eventually(func() bool {
+						return appRegistered(registry, app)
+					}).Should(BeTrue())
+
+					uri := fmt.Sprintf("https://test.%s:%d/", test_util.LocalhostDNS, config.SSLPort)
+					req, _ := http.NewRequest("GET", uri, nil)
+
+					resp, err := client.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					defer resp.Body.Close()
+
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+					Expect(resp.ProtoMajor).To(Equal(1))
+				})
+
+				It("upgrades websockets over HTTP/1.1", func() {
+					app := test.NewWebSocketApp(
+						[]route.Uri{"ws-app." + test_util.LocalhostDNS},
+						config.Port,
+						mbusClient,
+						0,
+						"",
+					)
+					app.RegisterAndListen()
+					Eventually(func() bool {
+						return appRegistered(registry, app)
+					}).Should(BeTrue())
+
+					tlsClientConfig.NextProtos = []string{"http/1.1"}
+					tlsClientConfig.ServerName = "test." + test_util.LocalhostDNS
+					conn, err := tls.Dial("tcp", fmt.Sprintf("ws-app.%s:%d", test_util.LocalhostDNS, config.SSLPort), tlsClientConfig)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(conn.ConnectionState().NegotiatedProtocol).To(Equal("http/1.1"))
+
+					x := test_util.NewHttpConn(conn)
+
+					req := test_util.NewRequest("GET", "ws-app."+test_util.LocalhostDNS, "/chat", nil)
+					req.Header.Set("Upgrade", "websocket")
+					req.Header.Set("Connection", "upgrade")
+
+					x.WriteRequest(req)
+
+					resp, _ := x.ReadResponse()
+					Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+
+					x.WriteLine("hello from client")
+					x.CheckLine("hello from server")
+
+					x.Close()
+				})
+			})
+		})
+
+		Context("when HTTP/3 is enabled", func() {
+			BeforeEach(func() {
+				config.EnableHTTP3 = true
+				config.HTTP3Port = config.SSLPort
+				config.HTTP3AltSvcMaxAge = time.Hour
+			})
+
+			It("advertises HTTP/3 to clients over TLS", func() {
+				app := test.NewGreetApp([]route.Uri{"test." + test_util.LocalhostDNS}, config.Port, mbusClient, nil)
+				app.RegisterAndListen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				uri := fmt.Sprintf("https://test.%s:%d/", test_util.LocalhostDNS, config.SSLPort)
+				req, _ := http.NewRequest("GET", uri, nil)
+
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.Header.Get("Alt-Svc")).To(Equal(fmt.Sprintf(`h3=":%d"; ma=3600`, config.SSLPort)))
+			})
+
+			It("serves HTTP/3 over QUIC", func() {
+				app := test.NewGreetApp([]route.Uri{"test." + test_util.LocalhostDNS}, config.Port, mbusClient, nil)
+				app.RegisterAndListen()
+				Eventually(func() bool {
+					return appRegistered(registry, app)
+				}).Should(BeTrue())
+
+				transport := &http3.Transport{TLSClientConfig: tlsClientConfig}
+				defer transport.Close()
+				client = &http.Client{Transport: transport}
+
+				uri := fmt.Sprintf("https://test.%s:%d/", test_util.LocalhostDNS, config.HTTP3Port)
+				req, _ := http.NewRequest("GET", uri, nil)
+
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.ProtoMajor).To(Equal(3))
+				Expect(resp.Header.Get("Alt-Svc")).To(BeEmpty())
+
+				bytes, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bytes).To(ContainSubstring("Hello"))
+			})
+		})
+
 		Context("when a ca cert is provided", func() {
 			BeforeEach(func() {
 				config.CACerts = string(cert)
@@ -1456,6 +1617,156 @@ var _ = Describe("Router", func() {
 			})
 
 		})
+		Context("when a certificate has a wildcard name", func() {
+			BeforeEach(func() {
+				certChain := test_util.CreateSignedCertWithRootCA(test_util.CertNames{CommonName: "*.wild." + test_util.LocalhostDNS})
+				config.SSLCertificates = append(config.SSLCertificates, certChain.TLSCert())
+			})
+
+			It("returns it for server names that match the wildcard", func() {
+				tlsClientConfig.ServerName = "app.wild." + test_util.LocalhostDNS
+				tlsClientConfig.InsecureSkipVerify = true
+
+				uri := fmt.Sprintf("test.%s:%d", test_util.LocalhostDNS, config.SSLPort)
+
+				conn, err := tls.Dial("tcp", uri, tlsClientConfig)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				certs := conn.ConnectionState().PeerCertificates
+				Expect(len(certs)).To(Equal(1))
+				Expect(certs[0].Subject.CommonName).To(Equal("*.wild." + test_util.LocalhostDNS))
+			})
+		})
+
+		Context("when the certificates are updated", func() {
+			It("returns the new certificates to new connections", func() {
+				tlsClientConfig.ServerName = "not-here.com"
+				tlsClientConfig.InsecureSkipVerify = true
+				uri := fmt.Sprintf("test.%s:%d", test_util.LocalhostDNS, config.SSLPort)
+
+				conn, err := tls.Dial("tcp", uri, tlsClientConfig)
+				Expect(err).ToNot(HaveOccurred())
+				conn.Close()
+				Expect(conn.ConnectionState().PeerCertificates[0].Subject.CommonName).To(Equal("default"))
+
+				certChain := test_util.CreateSignedCertWithRootCA(test_util.CertNames{CommonName: "rotated"})
+				router.UpdateCertificates([]tls.Certificate{certChain.TLSCert()})
+
+				conn, err = tls.Dial("tcp", uri, tlsClientConfig)
+				Expect(err).ToNot(HaveOccurred())
+				conn.Close()
+				Expect(conn.ConnectionState().PeerCertificates[0].Subject.CommonName).To(Equal("rotated"))
+			})
+		})
+
+		Context("when the cipher suites are updated", func() {
+			It("offers the new cipher suites to new connections", func() {
+				tlsClientConfig.ServerName = "test." + test_util.LocalhostDNS
+				tlsClientConfig.InsecureSkipVerify = true
+				tlsClientConfig.MaxVersion = tls.VersionTLS12
+				tlsClientConfig.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+				uri := fmt.Sprintf("127.0.0.1:%d", config.SSLPort)
+
+				_, err := tls.Dial("tcp", uri, tlsClientConfig)
+				Expect(err).To(HaveOccurred())
+
+				router.UpdateCipherSuites([]uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256})
+
+				conn, err := tls.Dial("tcp", uri, tlsClientConfig)
+				Expect(err).ToNot(HaveOccurred())
+				conn.Close()
+				Expect(conn.ConnectionState().CipherSuite).To(Equal(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256))
+			})
+		})
+
+		Context("when OCSP stapling is enabled", func() {
+			var (
+				rootCert     *x509.Certificate
+				stapledCert  tls.Certificate
+				responder    *httptest.Server
+				ocspReporter *fakeMetrics.FakeOCSPReporter
+				stapler      ifrit.Process
+				uri          string
+			)
+
+			BeforeEach(func() {
+				var (
+					rootKey *rsa.PrivateKey
+					rootPEM []byte
+				)
+				rootCert, rootKey, rootPEM, err = createRootCA("ocsp-ca")
+				Expect(err).ToNot(HaveOccurred())
+
+				responder = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					body, err := ioutil.ReadAll(r.Body)
+					Expect(err).ToNot(HaveOccurred())
+					req, err := ocsp.ParseRequest(body)
+					Expect(err).ToNot(HaveOccurred())
+
+					resp, err := ocsp.CreateResponse(rootCert, rootCert, ocsp.Response{
+						Status:       ocsp.Good,
+						SerialNumber: req.SerialNumber,
+						ThisUpdate:   time.Now().Add(-time.Minute),
+						NextUpdate:   time.Now().Add(time.Hour),
+					}, rootKey)
+					Expect(err).ToNot(HaveOccurred())
+					w.Write(resp)
+				}))
+
+				leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+				Expect(err).ToNot(HaveOccurred())
+				leafTmpl, err := certTemplate("stapled")
+				Expect(err).ToNot(HaveOccurred())
+				leafTmpl.OCSPServer = []string{responder.URL}
+				_, leafPEM, err := createCert(leafTmpl, rootCert, &leafKey.PublicKey, rootKey)
+				Expect(err).ToNot(HaveOccurred())
+				keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(leafKey)})
+				stapledCert, err = tls.X509KeyPair(append(leafPEM, rootPEM...), keyPEM)
+				Expect(err).ToNot(HaveOccurred())
+
+				tlsClientConfig.ServerName = "not-here.com"
+				tlsClientConfig.InsecureSkipVerify = true
+				uri = fmt.Sprintf("test.%s:%d", test_util.LocalhostDNS, config.SSLPort)
+				ocspReporter = &fakeMetrics.FakeOCSPReporter{}
+			})
+
+			JustBeforeEach(func() {
+				router.UpdateCertificates([]tls.Certificate{stapledCert})
+				stapler = ifrit.Invoke(NewOCSPStapler(router, &http.Client{Timeout: time.Second}, time.Hour, ocspReporter, logger))
+			})
+
+			AfterEach(func() {
+				stapler.Signal(os.Interrupt)
+				Eventually(stapler.Wait()).Should(Receive())
+				responder.Close()
+			})
+
+			It("staples the response of the certificate's responder", func() {
+				conn, err := tls.Dial("tcp", uri, tlsClientConfig)
+				Expect(err).ToNot(HaveOccurred())
+				conn.Close()
+
+				resp, err := ocsp.ParseResponse(conn.ConnectionState().OCSPResponse, rootCert)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Status).To(Equal(ocsp.Good))
+
+				Expect(ocspReporter.CaptureOCSPStapleAgeCallCount()).To(Equal(1))
+				Expect(ocspReporter.CaptureOCSPStapleAgeArgsForCall(0)).To(BeNumerically(">=", time.Minute))
+				Expect(ocspReporter.CaptureOCSPStapleFailureCallCount()).To(Equal(0))
+			})
+
+			It("keeps a valid staple when the responder fails", func() {
+				responder.Close()
+				router.UpdateCertificates([]tls.Certificate{stapledCert})
+				Eventually(ocspReporter.CaptureOCSPStapleFailureCallCount).Should(Equal(1))
+
+				conn, err := tls.Dial("tcp", uri, tlsClientConfig)
+				Expect(err).ToNot(HaveOccurred())
+				conn.Close()
+				Expect(conn.ConnectionState().OCSPResponse).ToNot(BeEmpty())
+			})
+		})
+
 		Context("when server name does not match anything", func() {
 			It("returns the default certificate", func() {
 				tlsClientConfig.ServerName = "not-here.com"
@@ -1856,12 +2167,12 @@ func initializeRouter(config *cfg.Config, registry *rregistry.RouteRegistry, var
 	rt := &sharedfakes.RoundTripper{}
 	skipSanitize := func(*http.Request) bool { return false }
 	p := proxy.NewProxy(logger, &accesslog.NullAccessLogger{}, config, registry, combinedReporter,
-		routeServiceConfig, &tls.Config{}, nil, rt, skipSanitize)
+		routeServiceConfig, &tls.Config{}, nil, nil, rt, skipSanitize, nil, nil)
 
 	var healthCheck int32
 	healthCheck = 0
 	logcounter := schema.NewLogCounter()
-	return NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, nil, routeServicesServer)
+	return NewRouter(logger, config, p, mbusClient, registry, varz, &healthCheck, logcounter, nil, routeServicesServer, nil)
 }
 
 func readVarz(v vvarz.Varz) map[string]interface{} {
@@ -45,6 +45,24 @@ func RegisterAddr(reg *registry.RouteRegistry, path string, addr string, cfg Reg
 			StaleThresholdInSeconds: cfg.StaleThreshold,
 			RouteServiceUrl:         cfg.RouteServiceUrl,
 			UseTLS:                  cfg.TLSConfig != nil,
+			ClientCertName:          cfg.ClientCertName,
+			Protocol:                cfg.Protocol,
+			ProxyProtocol:           cfg.ProxyProtocol,
+			ForwardedClientCert:     cfg.ForwardedClientCert,
+			AllowedClientCertSANs:   cfg.AllowedClientCertSANs,
+			AllowedClientCertOUs:    cfg.AllowedClientCertOUs,
+			CORSAllowedOrigins:      cfg.CORSAllowedOrigins,
+			CORSAllowedMethods:      cfg.CORSAllowedMethods,
+			HostRewrite:             cfg.HostRewrite,
+			StripPathPrefix:         cfg.StripPathPrefix,
+			RewritePath:             cfg.RewritePath,
+			MatchMethods:            cfg.MatchMethods,
+			MatchHeaders:            cfg.MatchHeaders,
+			Group:                   cfg.Group,
+			GroupPercent:            cfg.GroupPercent,
+			MirrorHost:              cfg.MirrorHost,
+			MirrorPercent:           cfg.MirrorPercent,
+			Tags:                    cfg.Tags,
 		}),
 	)
 }
@@ -89,6 +107,29 @@ type RegisterConfig struct {
 	StaleThreshold      int
 	TLSConfig           *tls.Config
 	IgnoreTLSConfig     bool
+	ClientCertName      string
+	Protocol            string
+	ProxyProtocol       string
+	ForwardedClientCert string
+	HostRewrite         string
+	StripPathPrefix     bool
+	RewritePath         string
+	Tags                map[string]string
+
+	AllowedClientCertSANs []string
+	AllowedClientCertOUs  []string
+
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+
+	MatchMethods []string
+	MatchHeaders map[string]string
+
+	Group        string
+	GroupPercent int
+
+	MirrorHost    string
+	MirrorPercent int
 }
 
 func runBackendInstance(ln net.Listener, handler connHandler) {
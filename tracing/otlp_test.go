@@ -0,0 +1,108 @@
+package tracing_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/gorouter/tracing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OTLPExporter", func() {
+	var (
+		collector *httptest.Server
+		requests  chan *http.Request
+		bodies    chan []byte
+		status    int
+		exporter  *tracing.OTLPExporter
+		tracer    *tracing.Tracer
+	)
+
+	BeforeEach(func() {
+		requests = make(chan *http.Request, 1)
+		bodies = make(chan []byte, 1)
+		status = http.StatusOK
+		collector = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			requests <- r
+			bodies <- body
+			w.WriteHeader(status)
+		}))
+		exporter = tracing.NewOTLPExporter(collector.URL+"/v1/traces", "gorouter")
+		tracer = tracing.NewTracer(exporter, test_util.NewTestZapLogger("test"))
+	})
+
+	AfterEach(func() {
+		collector.Close()
+	})
+
+	It("posts the spans as OTLP JSON", func() {
+		span := tracer.StartSpan("HTTP GET", tracing.SpanKindServer, nil)
+		span.SetAttribute("http.method", "GET")
+		child := span.StartChild("backend", tracing.SpanKindClient)
+		child.SetAttribute("http.status_code", 502)
+		child.SetAttribute("gorouter.retried", true)
+		child.SetError("bad gateway")
+		child.End()
+		span.End()
+
+		Expect(exporter.Export([]*tracing.Span{child, span})).To(Succeed())
+
+		var req *http.Request
+		Eventually(requests).Should(Receive(&req))
+		Expect(req.Method).To(Equal("POST"))
+		Expect(req.URL.Path).To(Equal("/v1/traces"))
+		Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		var body []byte
+		Eventually(bodies).Should(Receive(&body))
+
+		var payload map[string]interface{}
+		Expect(json.Unmarshal(body, &payload)).To(Succeed())
+
+		resourceSpans := payload["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		Expect(resourceSpans["resource"]).To(Equal(map[string]interface{}{
+			"attributes": []interface{}{
+				map[string]interface{}{
+					"key":   "service.name",
+					"value": map[string]interface{}{"stringValue": "gorouter"},
+				},
+			},
+		}))
+
+		spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+		Expect(spans).To(HaveLen(2))
+
+		backend := spans[0].(map[string]interface{})
+		Expect(backend["traceId"]).To(Equal(span.Context().TraceID))
+		Expect(backend["spanId"]).To(Equal(child.Context().SpanID))
+		Expect(backend["parentSpanId"]).To(Equal(span.Context().SpanID))
+		Expect(backend["name"]).To(Equal("backend"))
+		Expect(backend["kind"]).To(BeEquivalentTo(tracing.SpanKindClient))
+		Expect(backend["startTimeUnixNano"]).To(MatchRegexp(`^\d+$`))
+		Expect(backend["endTimeUnixNano"]).To(MatchRegexp(`^\d+$`))
+		Expect(backend["attributes"]).To(ConsistOf(
+			map[string]interface{}{"key": "http.status_code", "value": map[string]interface{}{"intValue": "502"}},
+			map[string]interface{}{"key": "gorouter.retried", "value": map[string]interface{}{"boolValue": true}},
+		))
+		Expect(backend["status"]).To(Equal(map[string]interface{}{"code": float64(2), "message": "bad gateway"}))
+
+		server := spans[1].(map[string]interface{})
+		Expect(server).NotTo(HaveKey("parentSpanId"))
+		Expect(server["kind"]).To(BeEquivalentTo(tracing.SpanKindServer))
+		Expect(server["status"]).To(BeEmpty())
+	})
+
+	It("returns an error when the collector rejects the spans", func() {
+		status = http.StatusBadRequest
+		span := tracer.StartSpan("HTTP GET", tracing.SpanKindServer, nil)
+		span.End()
+
+		Expect(exporter.Export([]*tracing.Span{span})).To(MatchError("collector responded with status 400"))
+	})
+})
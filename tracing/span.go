@@ -0,0 +1,214 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/common/secure"
+)
+
+// TraceParentHeader carries the W3C trace context of a request.
+const TraceParentHeader = "traceparent"
+
+type SpanKind int
+
+// Span kinds as numbered by OTLP.
+const (
+	SpanKindServer SpanKind = 2
+	SpanKindClient SpanKind = 3
+)
+
+// TraceContext identifies a span within a trace. The IDs are lowercase hex.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// Extract returns the trace context of the traceparent header, and false
+// when the header is missing or invalid.
+func Extract(h http.Header) (TraceContext, bool) {
+	parts := strings.Split(h.Get(TraceParentHeader), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return TraceContext{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return TraceContext{}, false
+	}
+
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isHexID(traceID, 32) || !isHexID(spanID, 16) || !isHex(flags, 2) {
+		return TraceContext{}, false
+	}
+
+	f, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Sampled: f[0]&1 == 1}, true
+}
+
+// TraceParent formats the trace context as a traceparent header value.
+func (c TraceContext) TraceParent() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", c.TraceID, c.SpanID, flags)
+}
+
+// isHexID reports whether s is a lowercase hex ID of length n that is not
+// all zeros, which the W3C spec reserves as invalid.
+func isHexID(s string, n int) bool {
+	return isHex(s, n) && strings.Trim(s, "0") != ""
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Span records one operation of a trace. All methods are safe to call on a
+// nil span, which is what a request carries when tracing is disabled.
+type Span struct {
+	tracer       *Tracer
+	context      TraceContext
+	parentSpanID string
+	name         string
+	kind         SpanKind
+	start, end   time.Time
+	attributes   []attribute
+	errMessage   string
+	failed       bool
+}
+
+func newSpan(t *Tracer, name string, kind SpanKind, parent *TraceContext) *Span {
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+
+	if parent != nil {
+		s.context.TraceID = parent.TraceID
+		s.context.Sampled = parent.Sampled
+		s.parentSpanID = parent.SpanID
+	} else {
+		s.context.TraceID = randomID(16)
+		s.context.Sampled = true
+	}
+	s.context.SpanID = randomID(8)
+	return s
+}
+
+func randomID(size uint) string {
+	b, err := secure.RandomBytes(size)
+	if err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// StartChild starts a span whose parent is s.
+func (s *Span) StartChild(name string, kind SpanKind) *Span {
+	if s == nil {
+		return nil
+	}
+	return newSpan(s.tracer, name, kind, &s.context)
+}
+
+func (s *Span) Context() TraceContext {
+	if s == nil {
+		return TraceContext{}
+	}
+	return s.context
+}
+
+func (s *Span) ParentSpanID() string {
+	if s == nil {
+		return ""
+	}
+	return s.parentSpanID
+}
+
+func (s *Span) Name() string {
+	if s == nil {
+		return ""
+	}
+	return s.name
+}
+
+func (s *Span) Kind() SpanKind {
+	if s == nil {
+		return 0
+	}
+	return s.kind
+}
+
+// SetAttribute records a string, bool, integer or float attribute.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// Attribute returns the last value recorded for key.
+func (s *Span) Attribute(key string) interface{} {
+	if s == nil {
+		return nil
+	}
+	for i := len(s.attributes) - 1; i >= 0; i-- {
+		if s.attributes[i].key == key {
+			return s.attributes[i].value
+		}
+	}
+	return nil
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.failed = true
+	s.errMessage = message
+}
+
+func (s *Span) Failed() bool {
+	return s != nil && s.failed
+}
+
+// Inject sets the traceparent header to the context of s, so that the
+// next hop continues the trace as a child of s.
+func (s *Span) Inject(h http.Header) {
+	if s == nil {
+		return
+	}
+	h.Set(TraceParentHeader, s.context.TraceParent())
+}
+
+// End finishes the span and queues it for export when it is sampled.
+func (s *Span) End() {
+	if s == nil || !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	if s.context.Sampled {
+		s.tracer.queue(s)
+	}
+}
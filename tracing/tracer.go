@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/gorouter/logger"
+	"github.com/uber-go/zap"
+)
+
+const (
+	queueSize     = 4096
+	maxBatchSize  = 512
+	flushInterval = 5 * time.Second
+)
+
+//go:generate counterfeiter -o fakes/fake_exporter.go . Exporter
+type Exporter interface {
+	Export(spans []*Span) error
+}
+
+// Tracer starts spans and exports the finished ones in batches. Spans are
+// dropped rather than delaying requests when the export falls behind.
+type Tracer struct {
+	exporter Exporter
+	spans    chan *Span
+	logger   logger.Logger
+}
+
+func NewTracer(exporter Exporter, logger logger.Logger) *Tracer {
+	return &Tracer{
+		exporter: exporter,
+		spans:    make(chan *Span, queueSize),
+		logger:   logger,
+	}
+}
+
+// StartSpan starts a span that continues the trace of parent, or a new trace
+// when parent is nil.
+func (t *Tracer) StartSpan(name string, kind SpanKind, parent *TraceContext) *Span {
+	if t == nil {
+		return nil
+	}
+	return newSpan(t, name, kind, parent)
+}
+
+func (t *Tracer) queue(s *Span) {
+	select {
+	case t.spans <- s:
+	default:
+	}
+}
+
+// Run exports the finished spans until it is signalled, then exports the
+// spans left in the queue.
+func (t *Tracer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	var batch []*Span
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) >= maxBatchSize {
+				batch = t.export(batch)
+			}
+		case <-ticker.C:
+			batch = t.export(batch)
+		case <-signals:
+			for {
+				select {
+				case s := <-t.spans:
+					batch = append(batch, s)
+				default:
+					t.export(batch)
+					t.logger.Info("exited")
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (t *Tracer) export(batch []*Span) []*Span {
+	if len(batch) == 0 {
+		return batch
+	}
+	err := t.exporter.Export(batch)
+	if err != nil {
+		t.logger.Error("failed-to-export-spans", zap.Int("spans", len(batch)), zap.Error(err))
+	}
+	return batch[:0]
+}
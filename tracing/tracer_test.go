@@ -0,0 +1,174 @@
+package tracing_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"code.cloudfoundry.org/gorouter/test_util"
+	"code.cloudfoundry.org/gorouter/tracing"
+	"code.cloudfoundry.org/gorouter/tracing/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Tracer", func() {
+	var (
+		exporter *fakes.FakeExporter
+		tracer   *tracing.Tracer
+	)
+
+	BeforeEach(func() {
+		exporter = new(fakes.FakeExporter)
+		tracer = tracing.NewTracer(exporter, test_util.NewTestZapLogger("test"))
+	})
+
+	Describe("Extract", func() {
+		It("parses a sampled traceparent header", func() {
+			h := http.Header{}
+			h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+			ctx, ok := tracing.Extract(h)
+			Expect(ok).To(BeTrue())
+			Expect(ctx).To(Equal(tracing.TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: true,
+			}))
+		})
+
+		It("parses an unsampled traceparent header", func() {
+			h := http.Header{}
+			h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+			ctx, ok := tracing.Extract(h)
+			Expect(ok).To(BeTrue())
+			Expect(ctx.Sampled).To(BeFalse())
+		})
+
+		DescribeTable("rejects invalid headers",
+			func(value string) {
+				h := http.Header{}
+				h.Set("traceparent", value)
+
+				_, ok := tracing.Extract(h)
+				Expect(ok).To(BeFalse())
+			},
+			Entry("missing", ""),
+			Entry("too few fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-01"),
+			Entry("invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+			Entry("upper case trace id", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"),
+			Entry("zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"),
+			Entry("zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"),
+			Entry("short span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01"),
+		)
+	})
+
+	Describe("StartSpan", func() {
+		It("starts a sampled trace without a parent", func() {
+			span := tracer.StartSpan("HTTP GET", tracing.SpanKindServer, nil)
+
+			Expect(span.Context().TraceID).To(MatchRegexp("^[0-9a-f]{32}$"))
+			Expect(span.Context().SpanID).To(MatchRegexp("^[0-9a-f]{16}$"))
+			Expect(span.Context().Sampled).To(BeTrue())
+			Expect(span.ParentSpanID()).To(BeEmpty())
+		})
+
+		It("continues the trace of the parent", func() {
+			parent := tracing.TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+			}
+			span := tracer.StartSpan("HTTP GET", tracing.SpanKindServer, &parent)
+
+			Expect(span.Context().TraceID).To(Equal(parent.TraceID))
+			Expect(span.Context().SpanID).NotTo(Equal(parent.SpanID))
+			Expect(span.Context().Sampled).To(BeFalse())
+			Expect(span.ParentSpanID()).To(Equal(parent.SpanID))
+		})
+
+		It("returns a nil span from a nil tracer", func() {
+			var t *tracing.Tracer
+			span := t.StartSpan("HTTP GET", tracing.SpanKindServer, nil)
+			Expect(span).To(BeNil())
+
+			h := http.Header{}
+			span.SetAttribute("http.method", "GET")
+			span.Inject(h)
+			span.StartChild("backend", tracing.SpanKindClient).End()
+			span.End()
+			Expect(h).To(BeEmpty())
+		})
+	})
+
+	Describe("Span", func() {
+		It("injects its context into the headers", func() {
+			span := tracer.StartSpan("HTTP GET", tracing.SpanKindServer, nil)
+			child := span.StartChild("backend", tracing.SpanKindClient)
+
+			h := http.Header{}
+			child.Inject(h)
+
+			ctx, ok := tracing.Extract(h)
+			Expect(ok).To(BeTrue())
+			Expect(ctx).To(Equal(child.Context()))
+			Expect(child.Context().TraceID).To(Equal(span.Context().TraceID))
+			Expect(child.ParentSpanID()).To(Equal(span.Context().SpanID))
+		})
+
+		It("records attributes and errors", func() {
+			span := tracer.StartSpan("backend", tracing.SpanKindClient, nil)
+			span.SetAttribute("http.status_code", 200)
+			span.SetAttribute("http.status_code", 502)
+			span.SetError("bad gateway")
+
+			Expect(span.Attribute("http.status_code")).To(Equal(502))
+			Expect(span.Failed()).To(BeTrue())
+		})
+	})
+
+	Describe("Run", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			process = ifrit.Invoke(tracer)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		})
+
+		It("exports the sampled spans when signalled", func() {
+			span := tracer.StartSpan("HTTP GET", tracing.SpanKindServer, nil)
+			child := span.StartChild("backend", tracing.SpanKindClient)
+			child.End()
+			span.End()
+
+			unsampled := tracer.StartSpan("HTTP GET", tracing.SpanKindServer, &tracing.TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+			})
+			unsampled.End()
+
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+
+			Expect(exporter.ExportCallCount()).To(Equal(1))
+			Expect(exporter.ExportArgsForCall(0)).To(Equal([]*tracing.Span{child, span}))
+		})
+
+		It("keeps running when the export fails", func() {
+			exporter.ExportReturns(errors.New("collector unavailable"))
+
+			tracer.StartSpan("HTTP GET", tracing.SpanKindServer, nil).End()
+
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+			Expect(exporter.ExportCallCount()).To(Equal(1))
+		})
+	})
+})